@@ -0,0 +1,149 @@
+package bvh
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/asset/scene"
+)
+
+// The algorithm used for building a bottom-level (mesh) BVH tree.
+type Builder uint8
+
+// Supported BVH builders.
+const (
+	// A top-down builder that scores splits using the surface area
+	// heuristic. It generates high quality trees and is best suited for
+	// static geometry.
+	SAHBuilder Builder = iota
+
+	// A linear BVH builder that partitions items along a morton curve. It
+	// builds trees much faster than SAHBuilder and is best suited for
+	// geometry that is rebuilt frequently.
+	LBVHBuilder
+
+	// A top-down SAH builder that also splits primitives across spatial
+	// split planes. It generates the highest quality trees for geometry
+	// with overlapping bounds (e.g. foliage) at the cost of slower builds
+	// and duplicate primitive references.
+	SBVHBuilder
+)
+
+// Implements Stringer.
+func (b Builder) String() string {
+	switch b {
+	case SAHBuilder:
+		return "sah"
+	case LBVHBuilder:
+		return "lbvh"
+	case SBVHBuilder:
+		return "sbvh"
+	}
+
+	return "invalid"
+}
+
+// Lookup a BVH builder by its name.
+func BuilderFromName(name string) (Builder, error) {
+	switch name {
+	case "sah":
+		return SAHBuilder, nil
+	case "lbvh":
+		return LBVHBuilder, nil
+	case "sbvh":
+		return SBVHBuilder, nil
+	}
+
+	return 0, fmt.Errorf("bvh: unsupported builder %q; supported builders: sah, lbvh, sbvh", name)
+}
+
+// Construct a BVH from a set of bounded volumes using the specified builder.
+// Trees generated by all builders use the same node layout and can be
+// combined into a single node list using BvhNode.OffsetChildNodes.
+//
+// Nodes containing at most minLeafItems items are not split any further so
+// larger values yield trees with fewer nodes at the cost of more intersection
+// tests per leaf. The minLeafItems value must be at least 1.
+//
+// The SBVH builder uses DefaultSplitAlpha as its split budget; use
+// BuildWithSplitAlpha to override it.
+func BuildWith(builder Builder, workList []BoundedVolume, minLeafItems int, leafCb LeafCallback) ([]scene.BvhNode, error) {
+	return BuildWithSplitAlpha(builder, workList, minLeafItems, DefaultSplitAlpha, leafCb)
+}
+
+// Construct a BVH like BuildWith using the specified split budget for the
+// SBVH builder; see BuildSBVH. The splitAlpha value must be in the [0, 1]
+// range and is ignored by the other builders.
+func BuildWithSplitAlpha(builder Builder, workList []BoundedVolume, minLeafItems int, splitAlpha float32, leafCb LeafCallback) ([]scene.BvhNode, error) {
+	if splitAlpha < 0 || splitAlpha > 1 {
+		return nil, fmt.Errorf("bvh: split alpha must be in the [0, 1] range; got %f", splitAlpha)
+	}
+	if minLeafItems < 1 {
+		return nil, fmt.Errorf("bvh: leaf item threshold must be >= 1; got %d", minLeafItems)
+	}
+
+	var nodes []scene.BvhNode
+	switch builder {
+	case SAHBuilder:
+		nodes = Build(workList, minLeafItems, leafCb, SurfaceAreaHeuristic)
+	case LBVHBuilder:
+		nodes = BuildLBVH(workList, minLeafItems, leafCb)
+	case SBVHBuilder:
+		nodes = BuildSBVH(workList, minLeafItems, splitAlpha, leafCb)
+	default:
+		return nil, fmt.Errorf("bvh: unsupported builder %d", builder)
+	}
+
+	if err := Validate(nodes); err != nil {
+		return nil, fmt.Errorf("bvh: %s builder generated an invalid tree: %v", builder, err)
+	}
+	return nodes, nil
+}
+
+// Ensure that a BVH tree uses the node layout expected by the tracers: the
+// root is stored at index 0, inner node child indices are relative to the
+// start of the tree and point to nodes that follow their parent, each node
+// is reachable from the root via a single path and child bounds are contained
+// in the bounds of their parent.
+func Validate(nodes []scene.BvhNode) error {
+	if len(nodes) == 0 {
+		return fmt.Errorf("empty tree")
+	}
+
+	visited := make([]bool, len(nodes))
+	nodeStack := []int32{0}
+	for len(nodeStack) != 0 {
+		nodeIndex := nodeStack[len(nodeStack)-1]
+		nodeStack = nodeStack[:len(nodeStack)-1]
+
+		if visited[nodeIndex] {
+			return fmt.Errorf("node %d is reachable via multiple paths", nodeIndex)
+		}
+		visited[nodeIndex] = true
+
+		node := &nodes[nodeIndex]
+		if node.LData <= 0 {
+			continue
+		}
+
+		for _, child := range []int32{node.LData, node.RData} {
+			if child <= nodeIndex || int(child) >= len(nodes) {
+				return fmt.Errorf("node %d has invalid child index %d", nodeIndex, child)
+			}
+
+			childNode := &nodes[child]
+			for axis := 0; axis < 3; axis++ {
+				if childNode.Min[axis] < node.Min[axis] || childNode.Max[axis] > node.Max[axis] {
+					return fmt.Errorf("bounds of node %d are not contained in the bounds of parent node %d", child, nodeIndex)
+				}
+			}
+			nodeStack = append(nodeStack, child)
+		}
+	}
+
+	for nodeIndex, wasVisited := range visited {
+		if !wasVisited {
+			return fmt.Errorf("node %d is not reachable from the root", nodeIndex)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package bvh
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestBuildersGenerateValidTrees(t *testing.T) {
+	// A 10x10x10 grid of unit cubes
+	itemList := make([]BoundedVolume, 0, 1000)
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			for z := 0; z < 10; z++ {
+				min := types.Vec3{float32(x), float32(y), float32(z)}
+				itemList = append(itemList, &testVolume{
+					bbox:   [2]types.Vec3{min, min.Add(types.Vec3{1, 1, 1})},
+					center: min.Add(types.Vec3{0.5, 0.5, 0.5}),
+				})
+			}
+		}
+	}
+
+	for _, builder := range []Builder{SAHBuilder, LBVHBuilder, SBVHBuilder} {
+		for _, minLeafItems := range []int{1, 10} {
+			var leafItems int
+			nodes, err := BuildWith(builder, itemList, minLeafItems, func(leaf *scene.BvhNode, workList []BoundedVolume) {
+				if len(workList) == 0 || len(workList) > minLeafItems {
+					t.Errorf("[%s builder] expected leaf to contain between 1 and %d items; got %d", builder, minLeafItems, len(workList))
+				}
+				leaf.SetPrimitives(uint32(leafItems), uint32(len(workList)))
+				leafItems += len(workList)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if leafItems != len(itemList) {
+				t.Errorf("[%s builder] expected leaves to contain %d items; got %d", builder, len(itemList), leafItems)
+			}
+			if nodes[0].Min != (types.Vec3{0, 0, 0}) || nodes[0].Max != (types.Vec3{10, 10, 10}) {
+				t.Errorf("[%s builder] expected root bounds to enclose all items; got %v - %v", builder, nodes[0].Min, nodes[0].Max)
+			}
+		}
+	}
+}
+
+func TestBuildersLeafThreshold(t *testing.T) {
+	// A row of 64 unit cubes
+	itemList := make([]BoundedVolume, 64)
+	for index := range itemList {
+		min := types.Vec3{float32(2 * index), 0, 0}
+		itemList[index] = &testVolume{
+			bbox:   [2]types.Vec3{min, min.Add(types.Vec3{1, 1, 1})},
+			center: min.Add(types.Vec3{0.5, 0.5, 0.5}),
+		}
+	}
+
+	countLeafs := func(builder Builder, minLeafItems int) (leafs, maxLeafItems int) {
+		_, err := BuildWith(builder, itemList, minLeafItems, func(leaf *scene.BvhNode, workList []BoundedVolume) {
+			leaf.SetPrimitives(0, uint32(len(workList)))
+			leafs++
+			if len(workList) > maxLeafItems {
+				maxLeafItems = len(workList)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leafs, maxLeafItems
+	}
+
+	for _, builder := range []Builder{SAHBuilder, LBVHBuilder, SBVHBuilder} {
+		leafs1, maxItems1 := countLeafs(builder, 1)
+		leafs8, maxItems8 := countLeafs(builder, 8)
+
+		if leafs1 != len(itemList) || maxItems1 != 1 {
+			t.Errorf("[%s builder] expected a threshold of 1 to generate %d single-item leafs; got %d leafs with up to %d items", builder, len(itemList), leafs1, maxItems1)
+		}
+		if leafs8 >= leafs1 || leafs8 < len(itemList)/8 || maxItems8 > 8 {
+			t.Errorf("[%s builder] expected a threshold of 8 to generate between %d and %d leafs with up to 8 items; got %d leafs with up to %d items", builder, len(itemList)/8, leafs1-1, leafs8, maxItems8)
+		}
+
+		if _, err := BuildWith(builder, itemList, 0, func(*scene.BvhNode, []BoundedVolume) {}); err == nil {
+			t.Errorf("[%s builder] expected to get an error for a leaf threshold of 0", builder)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	unitBox := func(lData, rData int32) scene.BvhNode {
+		return scene.BvhNode{Min: types.Vec3{0, 0, 0}, Max: types.Vec3{1, 1, 1}, LData: lData, RData: rData}
+	}
+
+	type spec struct {
+		nodes    []scene.BvhNode
+		expError bool
+	}
+	specs := []spec{
+		spec{[]scene.BvhNode{unitBox(0, 1)}, false},
+		spec{[]scene.BvhNode{unitBox(1, 2), unitBox(0, 1), unitBox(-1, 1)}, false},
+		// Empty tree
+		spec{[]scene.BvhNode{}, true},
+		// Child index out of range
+		spec{[]scene.BvhNode{unitBox(1, 3), unitBox(0, 1), unitBox(-1, 1)}, true},
+		// Node reachable via multiple paths
+		spec{[]scene.BvhNode{unitBox(1, 1), unitBox(0, 1)}, true},
+		// Unreachable node
+		spec{[]scene.BvhNode{unitBox(1, 2), unitBox(0, 1), unitBox(-1, 1), unitBox(-2, 1)}, true},
+		// Child bounds exceed parent bounds
+		spec{[]scene.BvhNode{unitBox(1, 2), unitBox(0, 1), scene.BvhNode{Max: types.Vec3{2, 1, 1}, LData: -1, RData: 1}}, true},
+	}
+
+	for index, s := range specs {
+		err := Validate(s.nodes)
+		if s.expError && err == nil {
+			t.Errorf("[spec %d] expected validation to fail", index)
+		} else if !s.expError && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+}
+
+func TestBuilderFromName(t *testing.T) {
+	for _, builder := range []Builder{SAHBuilder, LBVHBuilder, SBVHBuilder} {
+		got, err := BuilderFromName(builder.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != builder {
+			t.Fatalf("expected to get builder %s; got %s", builder, got)
+		}
+	}
+
+	if _, err := BuilderFromName("foo"); err == nil {
+		t.Fatal("expected to get an error for an unsupported builder")
+	}
+}
@@ -3,7 +3,7 @@ package bvh
 import (
 	"testing"
 
-	"github.com/achilleasa/polaris/scene"
+	"github.com/achilleasa/polaris/asset/scene"
 	"github.com/achilleasa/polaris/types"
 )
 
@@ -22,10 +22,10 @@ func TestLeafCallback(t *testing.T) {
 
 	itemList := make([]BoundedVolume, len(primSpecs))
 	for idx, ps := range primSpecs {
-		inst := &scene.ParsedMeshInstance{}
-		inst.SetBBox([2]types.Vec3{ps.min, ps.max})
-		inst.SetCenter(ps.min.Add(ps.max).Mul(0.5))
-		itemList[idx] = inst
+		itemList[idx] = &testVolume{
+			bbox:   [2]types.Vec3{ps.min, ps.max},
+			center: ps.min.Add(ps.max).Mul(0.5),
+		}
 	}
 
 	var cbCount = 0
@@ -67,3 +67,17 @@ func TestLeafCallback(t *testing.T) {
 		t.Fatalf("expected bvh tree to have %d nodes; got %d", expCount, len(treeNodes))
 	}
 }
+
+// A bounded volume with a fixed bbox and center.
+type testVolume struct {
+	bbox   [2]types.Vec3
+	center types.Vec3
+}
+
+func (v *testVolume) BBox() [2]types.Vec3 {
+	return v.bbox
+}
+
+func (v *testVolume) Center() types.Vec3 {
+	return v.center
+}
@@ -0,0 +1,184 @@
+package bvh
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/log"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The number of bits used for quantizing each axis when calculating the
+// morton code of an item center.
+const mortonBitsPerAxis = 10
+
+// A bounded volume together with the morton code of its center.
+type mortonItem struct {
+	code uint32
+	item BoundedVolume
+}
+
+type lbvhBuilder struct {
+	logger log.Logger
+
+	// Bvh nodes stored as a contiguous list
+	nodes []scene.BvhNode
+
+	// A callback invoked to set up BVH leafs.
+	leafCb LeafCallback
+
+	// The minimum number of items that are required for creating a leaf.
+	minLeafItems int
+
+	// The work list items sorted by their morton code.
+	items []mortonItem
+
+	// Stats
+	stats stats
+}
+
+// Construct a BVH from a set of bounded volumes using the linear BVH (LBVH)
+// algorithm. Item centers are sorted along a morton curve and the sorted list
+// is recursively split at the highest bit where the morton codes of the
+// items differ.
+//
+// LBVH trees are built much faster than SAH trees but provide lower
+// traversal performance. They are suited for geometry that needs to be
+// rebuilt frequently.
+//
+// The generated nodes use the same layout as the ones generated by Build.
+func BuildLBVH(workList []BoundedVolume, minLeafItems int, leafCb LeafCallback) []scene.BvhNode {
+	b := &lbvhBuilder{
+		logger:       log.New("lbvh builder"),
+		nodes:        make([]scene.BvhNode, 0),
+		leafCb:       leafCb,
+		minLeafItems: minLeafItems,
+		stats: stats{
+			totalItems: len(workList),
+		},
+	}
+
+	start := time.Now()
+	b.sortItems(workList)
+	b.partition(0, len(b.items), 0)
+	b.logger.Debugf(
+		"BVH tree build time: %d ms, maxDepth: %d, nodes: %d, leafs: %d\n",
+		time.Since(start).Nanoseconds()/1e6,
+		b.stats.maxDepth, b.stats.nodes, b.stats.leafs,
+	)
+	return b.nodes
+}
+
+// Calculate the morton code for each work list item and sort the items by
+// their codes. Items with the same code retain their work list order.
+func (b *lbvhBuilder) sortItems(workList []BoundedVolume) {
+	centerMin := types.Vec3{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	centerMax := types.Vec3{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for _, item := range workList {
+		center := item.Center()
+		centerMin = types.MinVec3(centerMin, center)
+		centerMax = types.MaxVec3(centerMax, center)
+	}
+
+	side := centerMax.Sub(centerMin)
+	b.items = make([]mortonItem, len(workList))
+	for index, item := range workList {
+		var quantized [3]uint32
+		center := item.Center()
+		for axis := 0; axis < 3; axis++ {
+			if side[axis] > 0 {
+				quantized[axis] = uint32(math.Min(float64((center[axis]-centerMin[axis])/side[axis]*(1<<mortonBitsPerAxis)), (1<<mortonBitsPerAxis)-1))
+			}
+		}
+
+		b.items[index] = mortonItem{
+			code: expandMortonBits(quantized[0])<<2 | expandMortonBits(quantized[1])<<1 | expandMortonBits(quantized[2]),
+			item: item,
+		}
+	}
+
+	sort.SliceStable(b.items, func(i, j int) bool { return b.items[i].code < b.items[j].code })
+}
+
+// Partition the sorted items in the [first, last) range and return the node
+// index.
+func (b *lbvhBuilder) partition(first, last, depth int) uint32 {
+	if depth > b.stats.maxDepth {
+		b.stats.maxDepth = depth
+	}
+
+	node := scene.BvhNode{
+		Min: types.Vec3{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32},
+		Max: types.Vec3{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32},
+	}
+	for _, mi := range b.items[first:last] {
+		itemBBox := mi.item.BBox()
+		node.Min = types.MinVec3(node.Min, itemBBox[0])
+		node.Max = types.MaxVec3(node.Max, itemBBox[1])
+	}
+
+	if last-first <= b.minLeafItems || last-first == 1 {
+		return b.createLeaf(&node, first, last)
+	}
+
+	// Add node to list
+	nodeIndex := len(b.nodes)
+	b.nodes = append(b.nodes, node)
+	b.stats.nodes++
+
+	split := b.findSplit(first, last)
+	leftNodeIndex := b.partition(first, split, depth+1)
+	rightNodeIndex := b.partition(split, last, depth+1)
+	b.nodes[nodeIndex].SetChildNodes(leftNodeIndex, rightNodeIndex)
+
+	return uint32(nodeIndex)
+}
+
+// Find the index of the first item in the [first, last) range whose morton
+// code differs from the code of the first item at the highest bit where the
+// codes of the range items differ. If all items share the same code, the
+// range is split in the middle.
+func (b *lbvhBuilder) findSplit(first, last int) int {
+	firstCode := b.items[first].code
+	lastCode := b.items[last-1].code
+	if firstCode == lastCode {
+		return (first + last) / 2
+	}
+
+	prefixLen := bits.LeadingZeros32(firstCode ^ lastCode)
+	return first + sort.Search(last-first, func(i int) bool {
+		return bits.LeadingZeros32(firstCode^b.items[first+i].code) <= prefixLen
+	})
+}
+
+// Setup the given node as a leaf containing the items in the [first, last)
+// range. Returns the index to the node in the bvh node array.
+func (b *lbvhBuilder) createLeaf(node *scene.BvhNode, first, last int) uint32 {
+	workList := make([]BoundedVolume, 0, last-first)
+	for _, mi := range b.items[first:last] {
+		workList = append(workList, mi.item)
+	}
+	b.leafCb(node, workList)
+
+	// append node to list
+	nodeIndex := len(b.nodes)
+	b.nodes = append(b.nodes, *node)
+
+	// update stats
+	b.stats.leafs++
+	b.stats.partitionedItems += len(workList)
+
+	return uint32(nodeIndex)
+}
+
+// Insert two zero bits after each one of the lower 10 bits of v.
+func expandMortonBits(v uint32) uint32 {
+	v = (v * 0x00010001) & 0xFF0000FF
+	v = (v * 0x00000101) & 0x0F00F00F
+	v = (v * 0x00000011) & 0xC30C30C3
+	v = (v * 0x00000005) & 0x49249249
+	return v
+}
@@ -0,0 +1,88 @@
+package bvh_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/compiler"
+	"github.com/achilleasa/polaris/asset/compiler/bvh"
+	"github.com/achilleasa/polaris/asset/compiler/input"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestMixedBuilderSceneTraversal(t *testing.T) {
+	const gridSize = 12
+
+	// A floor grid partitioned with SAH and a wall grid partitioned with
+	// LBVH. Each grid cell is split into two triangles.
+	floor := gridMesh("floor", gridSize, func(u, v float32) types.Vec3 { return types.Vec3{u, 0, v} })
+	wall := gridMesh("wall", gridSize, func(u, v float32) types.Vec3 { return types.Vec3{u + 2*gridSize, v, 0} })
+	wall.BvhBuilder = bvh.LBVHBuilder
+
+	parsedScene := input.NewScene()
+	parsedScene.Meshes = append(parsedScene.Meshes, floor, wall)
+	for meshIndex, mesh := range parsedScene.Meshes {
+		bbox := mesh.BBox()
+		mi := &input.MeshInstance{MeshIndex: uint32(meshIndex), Transform: types.Ident4()}
+		mi.SetBBox(bbox)
+		mi.SetCenter(bbox[0].Add(bbox[1]).Mul(0.5))
+		parsedScene.MeshInstances = append(parsedScene.MeshInstances, mi)
+	}
+
+	sc, err := compiler.Compile(parsedScene)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shoot a ray towards the center of each primitive and make sure that
+	// the combined tree reports a hit against the same primitive.
+	rayDirs := []types.Vec3{{0, -1, 0}, {0, 0, -1}}
+	for meshIndex, mesh := range parsedScene.Meshes {
+		for primIndex, prim := range mesh.Primitives {
+			center := prim.Vertices[0].Add(prim.Vertices[1]).Add(prim.Vertices[2]).Mul(1.0 / 3.0)
+			hit, found := sc.Intersect(center.Sub(rayDirs[meshIndex].Mul(5)), rayDirs[meshIndex], math.MaxFloat32)
+			if !found {
+				t.Fatalf("[%s prim %d] expected ray to hit the scene", mesh.Name, primIndex)
+			}
+
+			if hit.MeshInstance != uint32(meshIndex) {
+				t.Fatalf("[%s prim %d] expected ray to hit mesh instance %d; got %d", mesh.Name, primIndex, meshIndex, hit.MeshInstance)
+			}
+			if math.Abs(float64(hit.Dist-5)) > 1e-4 {
+				t.Fatalf("[%s prim %d] expected hit dist to be 5; got %f", mesh.Name, primIndex, hit.Dist)
+			}
+
+			var hitCenter types.Vec3
+			for i := uint32(0); i < 3; i++ {
+				hitCenter = hitCenter.Add(sc.VertexList[3*hit.PrimitiveIndex+i].Vec3())
+			}
+			if hitCenter.Mul(1.0/3.0).Sub(center).Len() > 1e-4 {
+				t.Fatalf("[%s prim %d] ray hit the wrong primitive (%d)", mesh.Name, primIndex, hit.PrimitiveIndex)
+			}
+		}
+	}
+}
+
+// Generate a mesh for a size x size grid whose vertices are generated by
+// the supplied function.
+func gridMesh(name string, size int, vertexFn func(u, v float32) types.Vec3) *input.Mesh {
+	mesh := input.NewMesh(name)
+	for u := 0; u < size; u++ {
+		for v := 0; v < size; v++ {
+			v0 := vertexFn(float32(u), float32(v))
+			v1 := vertexFn(float32(u+1), float32(v))
+			v2 := vertexFn(float32(u+1), float32(v+1))
+			v3 := vertexFn(float32(u), float32(v+1))
+			for _, vertices := range [][3]types.Vec3{{v0, v1, v2}, {v0, v2, v3}} {
+				prim := &input.Primitive{Vertices: vertices}
+				prim.SetBBox([2]types.Vec3{
+					types.MinVec3(vertices[0], types.MinVec3(vertices[1], vertices[2])),
+					types.MaxVec3(vertices[0], types.MaxVec3(vertices[1], vertices[2])),
+				})
+				prim.SetCenter(prim.BBox()[0].Add(prim.BBox()[1]).Mul(0.5))
+				mesh.Primitives = append(mesh.Primitives, prim)
+			}
+		}
+	}
+	return mesh
+}
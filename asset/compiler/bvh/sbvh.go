@@ -0,0 +1,496 @@
+package bvh
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/log"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The default split budget used by the SBVH builder.
+const DefaultSplitAlpha float32 = 1e-5
+
+const (
+	// The number of bins used for evaluating spatial split candidates
+	// along each axis.
+	sbvhSpatialBins = 32
+
+	// The max ratio between the number of generated references and the
+	// number of items in the work list. Once the limit is reached the
+	// SBVH builder only evaluates object splits.
+	sbvhMaxReferenceRatio = 2
+)
+
+// The ClippableVolume interface is optionally implemented by bounded volumes
+// that can calculate the bounds of their part that lies inside an
+// axis-aligned box. The SBVH builder uses it to generate tight bounds for
+// volumes that straddle a spatial split plane; other volumes are clipped
+// using their bounding box.
+type ClippableVolume interface {
+	BoundedVolume
+
+	// Get the bounds of the part of the volume inside the specified
+	// box. Returns false if the volume does not overlap the box.
+	ClipBBox(bounds [2]types.Vec3) ([2]types.Vec3, bool)
+}
+
+// A reference to a bounded volume. References to volumes that straddle a
+// spatial split plane are clipped to the side of the plane they belong to.
+type sbvhRef struct {
+	item   BoundedVolume
+	bbox   [2]types.Vec3
+	center types.Vec3
+}
+
+// Clip the referenced volume to the specified bounds.
+func (r *sbvhRef) clip(bounds [2]types.Vec3) (sbvhRef, bool) {
+	bounds = [2]types.Vec3{types.MaxVec3(bounds[0], r.bbox[0]), types.MinVec3(bounds[1], r.bbox[1])}
+	for axis := 0; axis < 3; axis++ {
+		if bounds[0][axis] > bounds[1][axis] {
+			return sbvhRef{}, false
+		}
+	}
+
+	if cv, isClippable := r.item.(ClippableVolume); isClippable {
+		clipped, overlaps := cv.ClipBBox(bounds)
+		if !overlaps {
+			return sbvhRef{}, false
+		}
+		bounds = [2]types.Vec3{types.MaxVec3(clipped[0], bounds[0]), types.MinVec3(clipped[1], bounds[1])}
+	}
+
+	return sbvhRef{
+		item:   r.item,
+		bbox:   bounds,
+		center: bounds[0].Add(bounds[1]).Mul(0.5),
+	}, true
+}
+
+// A candidate split for an SBVH node.
+type sbvhSplit struct {
+	axis  Axis
+	score float32
+
+	// Object splits partition the references sorted by their center at
+	// the split index while spatial splits use the split plane.
+	spatial    bool
+	splitIndex int
+	splitPoint float32
+
+	leftBBox, rightBBox [2]types.Vec3
+}
+
+type sbvhBuilder struct {
+	logger log.Logger
+
+	// Bvh nodes stored as a contiguous list
+	nodes []scene.BvhNode
+
+	// A callback invoked to set up BVH leafs.
+	leafCb LeafCallback
+
+	// The minimum number of items that are required for creating a leaf.
+	minLeafItems int
+
+	// Spatial splits are only evaluated for nodes whose best object split
+	// generates children whose overlap area exceeds this value.
+	minOverlapArea float32
+
+	// The number of references that can still be duplicated by spatial
+	// splits.
+	refBudget int
+
+	// Stats
+	stats         stats
+	spatialSplits int
+}
+
+// Construct a BVH from a set of bounded volumes using the split BVH (SBVH)
+// algorithm from Stich et al., "Spatial Splits in Bounding Volume
+// Hierarchies", 2009. Besides the object splits evaluated by the SAH builder,
+// the SBVH builder evaluates splitting nodes with planes that clip the
+// volumes straddling them. Clipped volumes are referenced by both children
+// so the same item may be passed to multiple leaf callbacks. Spatial splits
+// generate much tighter trees for geometry with overlapping bounds such as
+// long, thin or diagonal primitives.
+//
+// The splitAlpha value controls the split budget: spatial splits are only
+// evaluated for nodes whose best object split generates children with an
+// overlap area greater than splitAlpha times the surface area of the root
+// node. A value of 0 evaluates spatial splits for all nodes while a value of
+// 1 effectively disables them. Regardless of splitAlpha, the number of
+// generated references never exceeds twice the number of items.
+//
+// The generated nodes use the same layout as the ones generated by Build.
+func BuildSBVH(workList []BoundedVolume, minLeafItems int, splitAlpha float32, leafCb LeafCallback) []scene.BvhNode {
+	b := &sbvhBuilder{
+		logger:       log.New("sbvh builder"),
+		nodes:        make([]scene.BvhNode, 0),
+		leafCb:       leafCb,
+		minLeafItems: minLeafItems,
+		refBudget:    (sbvhMaxReferenceRatio - 1) * len(workList),
+		stats: stats{
+			totalItems: len(workList),
+		},
+	}
+
+	refList := make([]sbvhRef, len(workList))
+	rootBBox := emptyBBox()
+	for index, item := range workList {
+		refList[index] = sbvhRef{item: item, bbox: item.BBox(), center: item.Center()}
+		rootBBox = unionBBox(rootBBox, refList[index].bbox)
+	}
+	if len(workList) != 0 {
+		b.minOverlapArea = splitAlpha * bboxArea(rootBBox)
+	}
+
+	start := time.Now()
+	b.partition(refList, 0)
+	b.logger.Debugf(
+		"BVH tree build time: %d ms, maxDepth: %d, nodes: %d, leafs: %d, spatial splits: %d, references: %d\n",
+		time.Since(start).Nanoseconds()/1e6,
+		b.stats.maxDepth, b.stats.nodes, b.stats.leafs, b.spatialSplits, b.stats.partitionedItems,
+	)
+	return b.nodes
+}
+
+// Partition a reference list and return node index.
+func (b *sbvhBuilder) partition(refList []sbvhRef, depth int) uint32 {
+	if depth > b.stats.maxDepth {
+		b.stats.maxDepth = depth
+	}
+
+	bbox := emptyBBox()
+	for _, ref := range refList {
+		bbox = unionBBox(bbox, ref.bbox)
+	}
+	node := scene.BvhNode{Min: bbox[0], Max: bbox[1]}
+
+	// Do we have enough items for partitioning? If not create a leaf
+	if len(refList) <= b.minLeafItems {
+		return b.createLeaf(&node, refList)
+	}
+
+	split := b.findObjectSplit(refList)
+	if split != nil && b.refBudget > 0 {
+		overlap := [2]types.Vec3{types.MaxVec3(split.leftBBox[0], split.rightBBox[0]), types.MinVec3(split.leftBBox[1], split.rightBBox[1])}
+		if bboxArea(overlap) > b.minOverlapArea {
+			if spatialSplit := b.findSpatialSplit(refList, bbox); spatialSplit != nil && spatialSplit.score < split.score {
+				split = spatialSplit
+			}
+		}
+	}
+
+	// If we can't find a split that improves the current node score create a leaf
+	if split == nil || split.score >= float32(len(refList))*bboxArea(bbox) {
+		return b.createLeaf(&node, refList)
+	}
+
+	var leftRefList, rightRefList []sbvhRef
+	if split.spatial {
+		leftRefList, rightRefList = b.splitSpatial(refList, split)
+	}
+	if leftRefList == nil || rightRefList == nil {
+		sortByCenter(refList, split.axis)
+		if !split.spatial {
+			leftRefList, rightRefList = refList[:split.splitIndex], refList[split.splitIndex:]
+		} else {
+			// Fall back to a median object split if clipping
+			// failed to separate the references
+			leftRefList, rightRefList = refList[:len(refList)/2], refList[len(refList)/2:]
+		}
+	}
+
+	// Add node to list
+	nodeIndex := len(b.nodes)
+	b.nodes = append(b.nodes, node)
+	b.stats.nodes++
+
+	// Partition children and update node indices
+	leftNodeIndex := b.partition(leftRefList, depth+1)
+	rightNodeIndex := b.partition(rightRefList, depth+1)
+	b.nodes[nodeIndex].SetChildNodes(leftNodeIndex, rightNodeIndex)
+
+	return uint32(nodeIndex)
+}
+
+// Find the object split with the best SAH score by sweeping the references
+// sorted by their center along each axis.
+func (b *sbvhBuilder) findObjectSplit(refList []sbvhRef) *sbvhSplit {
+	var best *sbvhSplit
+
+	sorted := make([]sbvhRef, len(refList))
+	rightBBoxes := make([][2]types.Vec3, len(refList))
+	for axis := XAxis; axis <= ZAxis; axis++ {
+		copy(sorted, refList)
+		sortByCenter(sorted, axis)
+
+		rightBBox := emptyBBox()
+		for index := len(sorted) - 1; index > 0; index-- {
+			rightBBox = unionBBox(rightBBox, sorted[index].bbox)
+			rightBBoxes[index] = rightBBox
+		}
+
+		leftBBox := emptyBBox()
+		for splitIndex := 1; splitIndex < len(sorted); splitIndex++ {
+			leftBBox = unionBBox(leftBBox, sorted[splitIndex-1].bbox)
+			score := float32(splitIndex)*bboxArea(leftBBox) + float32(len(sorted)-splitIndex)*bboxArea(rightBBoxes[splitIndex])
+			if best == nil || score < best.score {
+				best = &sbvhSplit{
+					axis:       axis,
+					score:      score,
+					splitIndex: splitIndex,
+					leftBBox:   leftBBox,
+					rightBBox:  rightBBoxes[splitIndex],
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// Find the spatial split with the best SAH score by binning the clipped
+// references along each axis of the node bounds.
+func (b *sbvhBuilder) findSpatialSplit(refList []sbvhRef, nodeBBox [2]types.Vec3) *sbvhSplit {
+	var best *sbvhSplit
+
+	side := nodeBBox[1].Sub(nodeBBox[0])
+	for axis := XAxis; axis <= ZAxis; axis++ {
+		if side[axis] < minSideLength {
+			continue
+		}
+
+		binWidth := side[axis] / sbvhSpatialBins
+		binOf := func(value float32) int {
+			bin := int((value - nodeBBox[0][axis]) / binWidth)
+			if bin < 0 {
+				return 0
+			} else if bin >= sbvhSpatialBins {
+				return sbvhSpatialBins - 1
+			}
+			return bin
+		}
+
+		var binBBoxes [sbvhSpatialBins][2]types.Vec3
+		var entries, exits [sbvhSpatialBins]int
+		for bin := range binBBoxes {
+			binBBoxes[bin] = emptyBBox()
+		}
+
+		for refIndex := range refList {
+			ref := &refList[refIndex]
+			firstBin, lastBin := binOf(ref.bbox[0][axis]), binOf(ref.bbox[1][axis])
+			if firstBin == lastBin {
+				binBBoxes[firstBin] = unionBBox(binBBoxes[firstBin], ref.bbox)
+			} else {
+				for bin := firstBin; bin <= lastBin; bin++ {
+					binBounds := ref.bbox
+					binBounds[0][axis] = nodeBBox[0][axis] + float32(bin)*binWidth
+					binBounds[1][axis] = nodeBBox[0][axis] + float32(bin+1)*binWidth
+					if clipped, overlaps := ref.clip(binBounds); overlaps {
+						binBBoxes[bin] = unionBBox(binBBoxes[bin], clipped.bbox)
+					}
+				}
+			}
+			entries[firstBin]++
+			exits[lastBin]++
+		}
+
+		var rightBBoxes [sbvhSpatialBins][2]types.Vec3
+		var rightCounts [sbvhSpatialBins]int
+		rightBBox, rightCount := emptyBBox(), 0
+		for bin := sbvhSpatialBins - 1; bin > 0; bin-- {
+			rightBBox = unionBBox(rightBBox, binBBoxes[bin])
+			rightCount += exits[bin]
+			rightBBoxes[bin], rightCounts[bin] = rightBBox, rightCount
+		}
+
+		leftBBox, leftCount := emptyBBox(), 0
+		for plane := 1; plane < sbvhSpatialBins; plane++ {
+			leftBBox = unionBBox(leftBBox, binBBoxes[plane-1])
+			leftCount += entries[plane-1]
+			if leftCount == 0 || rightCounts[plane] == 0 || leftCount+rightCounts[plane]-len(refList) > b.refBudget {
+				continue
+			}
+
+			score := float32(leftCount)*bboxArea(leftBBox) + float32(rightCounts[plane])*bboxArea(rightBBoxes[plane])
+			if best == nil || score < best.score {
+				best = &sbvhSplit{
+					axis:       axis,
+					score:      score,
+					spatial:    true,
+					splitPoint: nodeBBox[0][axis] + float32(plane)*binWidth,
+					leftBBox:   leftBBox,
+					rightBBox:  rightBBoxes[plane],
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// Partition a reference list using a spatial split. References straddling
+// the split plane are clipped and added to both lists. As the split plane
+// lies inside the node bounds, the children bounds always shrink along the
+// split axis even if all references straddle the plane. Returns nil lists if
+// clipping leaves one of the lists empty.
+func (b *sbvhBuilder) splitSpatial(refList []sbvhRef, split *sbvhSplit) (leftRefList, rightRefList []sbvhRef) {
+	axis := split.axis
+	for refIndex := range refList {
+		ref := &refList[refIndex]
+		switch {
+		case ref.bbox[1][axis] <= split.splitPoint:
+			leftRefList = append(leftRefList, *ref)
+		case ref.bbox[0][axis] >= split.splitPoint:
+			rightRefList = append(rightRefList, *ref)
+		default:
+			leftBounds, rightBounds := ref.bbox, ref.bbox
+			leftBounds[1][axis] = split.splitPoint
+			rightBounds[0][axis] = split.splitPoint
+			if leftRef, overlaps := ref.clip(leftBounds); overlaps {
+				leftRefList = append(leftRefList, leftRef)
+			}
+			if rightRef, overlaps := ref.clip(rightBounds); overlaps {
+				rightRefList = append(rightRefList, rightRef)
+			}
+		}
+	}
+
+	if len(leftRefList) == 0 || len(rightRefList) == 0 {
+		return nil, nil
+	}
+
+	b.refBudget -= len(leftRefList) + len(rightRefList) - len(refList)
+	b.spatialSplits++
+	return leftRefList, rightRefList
+}
+
+// Setup the given node item as a leaf node containing all referenced items.
+// Returns the index to the node in the bvh node array.
+func (b *sbvhBuilder) createLeaf(node *scene.BvhNode, refList []sbvhRef) uint32 {
+	itemList := make([]BoundedVolume, len(refList))
+	for index, ref := range refList {
+		itemList[index] = ref.item
+	}
+	b.leafCb(node, itemList)
+
+	// append node to list
+	nodeIndex := len(b.nodes)
+	b.nodes = append(b.nodes, *node)
+
+	// update stats
+	b.stats.leafs++
+	b.stats.partitionedItems += len(refList)
+
+	return uint32(nodeIndex)
+}
+
+// Calculate the bounds of the part of a triangle that lies inside an
+// axis-aligned box by clipping the triangle against each side of the box.
+// Returns false if the triangle does not overlap the box.
+func ClipTriangleBBox(vertices [3]types.Vec3, bounds [2]types.Vec3) ([2]types.Vec3, bool) {
+	polygon := vertices[:]
+	for axis := 0; axis < 3 && len(polygon) != 0; axis++ {
+		polygon = clipPolygon(polygon, axis, bounds[0][axis], true)
+		polygon = clipPolygon(polygon, axis, bounds[1][axis], false)
+	}
+	if len(polygon) == 0 {
+		return [2]types.Vec3{}, false
+	}
+
+	bbox := emptyBBox()
+	for _, vertex := range polygon {
+		bbox = unionBBox(bbox, [2]types.Vec3{vertex, vertex})
+	}
+
+	// Guard against rounding errors introduced by clipping
+	return [2]types.Vec3{types.MaxVec3(bbox[0], bounds[0]), types.MinVec3(bbox[1], bounds[1])}, true
+}
+
+// Clip a convex polygon against an axis-aligned plane keeping the part of
+// the polygon above (or below) the plane.
+func clipPolygon(polygon []types.Vec3, axis int, plane float32, keepAbove bool) []types.Vec3 {
+	inside := func(v types.Vec3) bool {
+		if keepAbove {
+			return v[axis] >= plane
+		}
+		return v[axis] <= plane
+	}
+
+	out := make([]types.Vec3, 0, len(polygon)+1)
+	for index, cur := range polygon {
+		next := polygon[(index+1)%len(polygon)]
+		curInside, nextInside := inside(cur), inside(next)
+		if curInside {
+			out = append(out, cur)
+		}
+		if curInside != nextInside {
+			t := (plane - cur[axis]) / (next[axis] - cur[axis])
+			point := cur.Add(next.Sub(cur).Mul(t))
+			point[axis] = plane
+			out = append(out, point)
+		}
+	}
+	return out
+}
+
+// Calculate the SAH cost of a BVH tree: the expected number of node
+// traversal steps and item intersection tests for a ray that hits the root
+// node. The item count for each leaf is read using BvhNode.GetPrimitives so
+// the leaf callback used for building the tree must populate it.
+func SAHCost(nodes []scene.BvhNode) float32 {
+	if len(nodes) == 0 {
+		return 0
+	}
+
+	rootArea := bboxArea([2]types.Vec3{nodes[0].Min, nodes[0].Max})
+	if rootArea <= 0 {
+		return 0
+	}
+
+	var cost float32
+	for index := range nodes {
+		node := &nodes[index]
+		prob := bboxArea([2]types.Vec3{node.Min, node.Max}) / rootArea
+		if node.LData > 0 {
+			cost += prob
+		} else {
+			_, count := node.GetPrimitives()
+			cost += prob * float32(count)
+		}
+	}
+	return cost
+}
+
+// Sort a reference list by the reference centers along an axis.
+func sortByCenter(refList []sbvhRef, axis Axis) {
+	sort.Slice(refList, func(i, j int) bool {
+		return refList[i].center[axis] < refList[j].center[axis]
+	})
+}
+
+func emptyBBox() [2]types.Vec3 {
+	return [2]types.Vec3{
+		{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32},
+		{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32},
+	}
+}
+
+func unionBBox(a, b [2]types.Vec3) [2]types.Vec3 {
+	return [2]types.Vec3{types.MinVec3(a[0], b[0]), types.MaxVec3(a[1], b[1])}
+}
+
+// Calculate half the surface area of a bounding box. Returns 0 for empty
+// or inverted boxes.
+func bboxArea(bbox [2]types.Vec3) float32 {
+	side := bbox[1].Sub(bbox[0])
+	if side[0] < 0 || side[1] < 0 || side[2] < 0 {
+		return 0
+	}
+	return side[0]*side[1] + side[1]*side[2] + side[0]*side[2]
+}
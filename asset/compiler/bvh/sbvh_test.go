@@ -0,0 +1,162 @@
+package bvh_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/compiler"
+	"github.com/achilleasa/polaris/asset/compiler/bvh"
+	"github.com/achilleasa/polaris/asset/compiler/input"
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSBVHCost(t *testing.T) {
+	mesh := diagonalStripMesh("grass", 200)
+	itemList := make([]bvh.BoundedVolume, len(mesh.Primitives))
+	for index, prim := range mesh.Primitives {
+		itemList[index] = prim
+	}
+
+	buildTree := func(builder bvh.Builder) ([]scene.BvhNode, map[bvh.BoundedVolume]int) {
+		refs := make(map[bvh.BoundedVolume]int)
+		nodes, err := bvh.BuildWith(builder, itemList, 4, func(leaf *scene.BvhNode, workList []bvh.BoundedVolume) {
+			leaf.SetPrimitives(0, uint32(len(workList)))
+			for _, item := range workList {
+				refs[item]++
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nodes, refs
+	}
+
+	sahNodes, _ := buildTree(bvh.SAHBuilder)
+	sbvhNodes, sbvhRefs := buildTree(bvh.SBVHBuilder)
+
+	var numRefs int
+	for _, item := range itemList {
+		if sbvhRefs[item] == 0 {
+			t.Fatal("expected each item to be referenced by at least one SBVH leaf")
+		}
+		numRefs += sbvhRefs[item]
+	}
+	if numRefs <= len(itemList) || numRefs > 2*len(itemList) {
+		t.Errorf("expected SBVH to generate between %d and %d references; got %d", len(itemList)+1, 2*len(itemList), numRefs)
+	}
+
+	sahCost, sbvhCost := bvh.SAHCost(sahNodes), bvh.SAHCost(sbvhNodes)
+	if sbvhCost >= 0.5*sahCost {
+		t.Fatalf("expected SBVH cost (%f) to be less than half the SAH cost (%f)", sbvhCost, sahCost)
+	}
+
+	// A split alpha of 1 disables spatial splits
+	nodes, err := bvh.BuildWithSplitAlpha(bvh.SBVHBuilder, itemList, 4, 1, func(leaf *scene.BvhNode, workList []bvh.BoundedVolume) {
+		leaf.SetPrimitives(0, uint32(len(workList)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := bvh.SAHCost(nodes); cost < 0.9*sahCost {
+		t.Errorf("expected SBVH cost without spatial splits (%f) to match the SAH cost (%f)", cost, sahCost)
+	}
+
+	for _, splitAlpha := range []float32{-1, 2} {
+		if _, err = bvh.BuildWithSplitAlpha(bvh.SBVHBuilder, itemList, 4, splitAlpha, func(*scene.BvhNode, []bvh.BoundedVolume) {}); err == nil {
+			t.Errorf("expected to get an error for split alpha %f", splitAlpha)
+		}
+	}
+}
+
+func TestSBVHSceneTraversal(t *testing.T) {
+	mesh := diagonalStripMesh("grass", 100)
+	mesh.BvhBuilder = bvh.SBVHBuilder
+
+	parsedScene := input.NewScene()
+	parsedScene.Meshes = append(parsedScene.Meshes, mesh)
+	bbox := mesh.BBox()
+	mi := &input.MeshInstance{MeshIndex: 0, Transform: types.Ident4()}
+	mi.SetBBox(bbox)
+	mi.SetCenter(bbox[0].Add(bbox[1]).Mul(0.5))
+	parsedScene.MeshInstances = append(parsedScene.MeshInstances, mi)
+
+	sc, err := compiler.Compile(parsedScene)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numPrims := len(sc.VertexList) / 3; numPrims <= len(mesh.Primitives) || len(sc.MaterialIndex) != numPrims {
+		t.Fatalf("expected compiled scene to contain duplicate primitive references; got %d primitives and %d material indices for %d input primitives", numPrims, len(sc.MaterialIndex), len(mesh.Primitives))
+	}
+
+	// Shoot rays towards points along each strip and make sure that the
+	// tree reports a hit against the same primitive.
+	for primIndex, prim := range mesh.Primitives {
+		for _, t0 := range []float32{0.2, 0.5, 0.8} {
+			target := prim.Vertices[0].Mul(1 - t0).Add(prim.Vertices[1].Add(prim.Vertices[2]).Mul(0.5 * t0))
+			hit, found := sc.Intersect(target.Add(types.Vec3{0, 0, 5}), types.Vec3{0, 0, -1}, math.MaxFloat32)
+			if !found {
+				t.Fatalf("[prim %d] expected ray to hit the scene", primIndex)
+			}
+
+			hitVertex := sc.VertexList[3*hit.PrimitiveIndex].Vec3()
+			if hitVertex.Sub(prim.Vertices[0]).Len() > 1e-4 {
+				t.Fatalf("[prim %d] ray hit the wrong primitive (%d)", primIndex, hit.PrimitiveIndex)
+			}
+		}
+	}
+}
+
+func TestClipTriangleBBox(t *testing.T) {
+	triangle := [3]types.Vec3{{0, 0, 0}, {4, 4, 0}, {4, 0, 0}}
+
+	type spec struct {
+		bounds      [2]types.Vec3
+		expOverlaps bool
+		expBBox     [2]types.Vec3
+	}
+	specs := []spec{
+		spec{[2]types.Vec3{{-1, -1, -1}, {5, 5, 1}}, true, [2]types.Vec3{{0, 0, 0}, {4, 4, 0}}},
+		spec{[2]types.Vec3{{-1, -1, -1}, {2, 5, 1}}, true, [2]types.Vec3{{0, 0, 0}, {2, 2, 0}}},
+		spec{[2]types.Vec3{{3, 3, -1}, {5, 5, 1}}, true, [2]types.Vec3{{3, 3, 0}, {4, 4, 0}}},
+		// Box inside the triangle bounds but above the hypotenuse
+		spec{[2]types.Vec3{{0, 3, -1}, {1, 4, 1}}, false, [2]types.Vec3{}},
+		spec{[2]types.Vec3{{0, 0, 1}, {4, 4, 2}}, false, [2]types.Vec3{}},
+	}
+
+	for index, s := range specs {
+		bbox, overlaps := bvh.ClipTriangleBBox(triangle, s.bounds)
+		if overlaps != s.expOverlaps {
+			t.Errorf("[spec %d] expected overlap to be %t; got %t", index, s.expOverlaps, overlaps)
+			continue
+		}
+		if !overlaps {
+			continue
+		}
+		for i := 0; i < 2; i++ {
+			if bbox[i].Sub(s.expBBox[i]).Len() > 1e-5 {
+				t.Errorf("[spec %d] expected clipped bbox to be %v; got %v", index, s.expBBox, bbox)
+				break
+			}
+		}
+	}
+}
+
+// Generate a mesh with count long and thin parallel triangles that run
+// diagonally across the XY plane.
+func diagonalStripMesh(name string, count int) *input.Mesh {
+	mesh := input.NewMesh(name)
+	for index := 0; index < count; index++ {
+		x := float32(index) * 0.01
+		vertices := [3]types.Vec3{{x, 0, 0}, {x + 20, 20, 0}, {x + 20.005, 20, 0}}
+		prim := &input.Primitive{Vertices: vertices}
+		prim.SetBBox([2]types.Vec3{
+			types.MinVec3(vertices[0], types.MinVec3(vertices[1], vertices[2])),
+			types.MaxVec3(vertices[0], types.MaxVec3(vertices[1], vertices[2])),
+		})
+		prim.SetCenter(prim.BBox()[0].Add(prim.BBox()[1]).Mul(0.5))
+		mesh.Primitives = append(mesh.Primitives, prim)
+	}
+	return mesh
+}
@@ -16,12 +16,39 @@ import (
 )
 
 const (
-	minPrimitivesPerLeaf      = 10
+	// The default max number of primitives in a mesh BVH node that is not
+	// split any further.
+	DefaultLeafPrimitives = 10
+
 	SceneDiffuseMaterialName  = "scene_diffuse_material"
 	SceneEmissiveMaterialName = "scene_emissive_material"
+	SceneSunMaterialName      = "scene_sun_material"
 )
 
+// Options for tuning the scene compiler.
+type Options struct {
+	// Fail compilation if a primitive references an unknown material
+	// instead of shading it with the scene default material.
+	StrictMaterials bool
+
+	// Mesh BVH nodes containing at most this many primitives are not split
+	// any further. Larger values reduce the number of BVH nodes at the
+	// cost of more intersection tests per leaf. Meshes may override this
+	// value. If set to 0, DefaultLeafPrimitives is used.
+	LeafPrimitives int
+
+	// Fail compilation if the compiled scene data exceeds this many bytes;
+	// see scene.MemoryUsage. If set to 0, the memory usage is not checked.
+	MemoryBudget int64
+
+	// Primitives whose area does not exceed this value are removed before
+	// building the mesh BVH trees. If set to 0,
+	// scene.DefaultDegenerateAreaEpsilon is used.
+	DegenerateAreaEpsilon float32
+}
+
 type sceneCompiler struct {
+	options        Options
 	parsedScene    *input.Scene
 	optimizedScene *scene.Scene
 	logger         log.Logger
@@ -36,20 +63,43 @@ type sceneCompiler struct {
 	// A map of material indices to an emissive layered material tree node.
 	emissiveIndexCache map[int]int32
 
+	// The index of the scene sun material or -1 if not defined.
+	sunMatIndex int
+
 	// A list of material references for detecting circular loops.
 	matRefList []string
 }
 
 // Compile a scene representation parsed by a scene reader into a GPU-friendly
-// optimized scene format.
+// optimized scene format using the default compiler options.
 func Compile(parsedScene *input.Scene) (*scene.Scene, error) {
+	return CompileWithOptions(parsedScene, Options{})
+}
+
+// Compile a scene representation parsed by a scene reader into a GPU-friendly
+// optimized scene format using the supplied compiler options.
+func CompileWithOptions(parsedScene *input.Scene, options Options) (*scene.Scene, error) {
+	if options.LeafPrimitives < 0 {
+		return nil, fmt.Errorf("BVH leaf primitive threshold must be >= 1; got %d", options.LeafPrimitives)
+	} else if options.LeafPrimitives == 0 {
+		options.LeafPrimitives = DefaultLeafPrimitives
+	}
+
+	if options.DegenerateAreaEpsilon < 0 {
+		return nil, fmt.Errorf("degenerate primitive area epsilon must be >= 0; got %f", options.DegenerateAreaEpsilon)
+	} else if options.DegenerateAreaEpsilon == 0 {
+		options.DegenerateAreaEpsilon = scene.DefaultDegenerateAreaEpsilon
+	}
+
 	compiler := &sceneCompiler{
+		options:     options,
 		parsedScene: parsedScene,
 		optimizedScene: &scene.Scene{
 			SceneDiffuseMatIndex:  -1,
 			SceneEmissiveMatIndex: -1,
 		},
-		logger: log.New("scene compiler"),
+		sunMatIndex: -1,
+		logger:      log.New("scene compiler"),
 	}
 
 	start := time.Now()
@@ -61,6 +111,11 @@ func Compile(parsedScene *input.Scene) (*scene.Scene, error) {
 		return nil, err
 	}
 
+	err = compiler.removeDegeneratePrimitives()
+	if err != nil {
+		return nil, err
+	}
+
 	err = compiler.partitionGeometry()
 	if err != nil {
 		return nil, err
@@ -71,10 +126,38 @@ func Compile(parsedScene *input.Scene) (*scene.Scene, error) {
 		return nil, err
 	}
 
+	err = compiler.optimizedScene.CheckMemoryBudget(options.MemoryBudget)
+	if err != nil {
+		return nil, err
+	}
+
 	compiler.logger.Noticef("compiled scene in %d ms", time.Since(start).Nanoseconds()/1e6)
 	return compiler.optimizedScene, nil
 }
 
+// Drop degenerate mesh primitives which would otherwise produce NaN normals
+// when shaded. Fails if a mesh does not contain any non-degenerate primitives.
+func (sc *sceneCompiler) removeDegeneratePrimitives() error {
+	var totalRemoved int
+	for _, pm := range sc.parsedScene.Meshes {
+		removed := pm.RemoveDegeneratePrimitives(sc.options.DegenerateAreaEpsilon)
+		if removed == 0 {
+			continue
+		}
+		if len(pm.Primitives) == 0 {
+			return fmt.Errorf("mesh %q does not contain any non-degenerate primitives", pm.Name)
+		}
+
+		sc.logger.Infof(`removed %d degenerate primitives from mesh "%s"`, removed, pm.Name)
+		totalRemoved += removed
+	}
+
+	if totalRemoved > 0 {
+		sc.logger.Warningf("removed %d degenerate primitives", totalRemoved)
+	}
+	return nil
+}
+
 // Generate a two-level BVH tree for the scene. The top level BVH tree partitions
 // the mesh instances. An additional BVH tree is also generated for each
 // defined scene mesh. Each mesh instance points to the root BVH node of a mesh.
@@ -103,14 +186,19 @@ func (sc *sceneCompiler) partitionGeometry() error {
 	// Scan all meshes and calculate the size of material, vertex, normal
 	// and uv lists; then pre-allocate them.
 	totalVertices := 0
+	hasVertexColors := false
 	for _, pm := range sc.parsedScene.Meshes {
 		totalVertices += 3 * len(pm.Primitives)
+		hasVertexColors = hasVertexColors || pm.HasVertexColors
 	}
 
 	sc.optimizedScene.VertexList = make([]types.Vec4, totalVertices)
 	sc.optimizedScene.NormalList = make([]types.Vec4, totalVertices)
 	sc.optimizedScene.UvList = make([]types.Vec2, totalVertices)
 	sc.optimizedScene.MaterialIndex = make([]uint32, totalVertices/3)
+	if hasVertexColors {
+		sc.optimizedScene.VertexColorList = make([]types.Vec4, totalVertices)
+	}
 
 	// Partition each mesh into its own BVH. Update all instances to point to this mesh BVH.
 	var vertexOffset uint32 = 0
@@ -124,9 +212,24 @@ func (sc *sceneCompiler) partitionGeometry() error {
 			volList[index] = prim
 		}
 
-		sc.logger.Infof(`building BVH tree for "%s" (%d primitives)`, pm.Name, len(pm.Primitives))
-		bvhNodes := bvh.Build(volList, minPrimitivesPerLeaf, func(node *scene.BvhNode, workList []bvh.BoundedVolume) {
+		leafPrimitives := sc.options.LeafPrimitives
+		if pm.BvhLeafPrimitives != 0 {
+			leafPrimitives = pm.BvhLeafPrimitives
+		}
+
+		sc.logger.Infof(`building %s BVH tree for "%s" (%d primitives, %d primitives per leaf)`, pm.BvhBuilder, pm.Name, len(pm.Primitives), leafPrimitives)
+		splitAlpha := bvh.DefaultSplitAlpha
+		if pm.BvhSplitAlpha != nil {
+			splitAlpha = *pm.BvhSplitAlpha
+		}
+
+		// SBVH leaves may reference primitives that are also referenced by
+		// other leaves. Emissive primitives are only tracked once so that
+		// duplicate references do not skew light sampling.
+		trackedEmissives := make(map[*input.Primitive]bool)
+		bvhNodes, err := bvh.BuildWithSplitAlpha(pm.BvhBuilder, volList, leafPrimitives, splitAlpha, func(node *scene.BvhNode, workList []bvh.BoundedVolume) {
 			node.SetPrimitives(primOffset, uint32(len(workList)))
+			sc.reservePrimitives(int(primOffset) + len(workList))
 
 			// Copy primitive data to flat arrays
 			for _, workItem := range workList {
@@ -145,14 +248,32 @@ func (sc *sceneCompiler) partitionGeometry() error {
 				sc.optimizedScene.UvList[vertexOffset+1] = prim.UVs[1]
 				sc.optimizedScene.UvList[vertexOffset+2] = prim.UVs[2]
 
-				// Lookup root material node for primitive material index
-				matNodeIndex := sc.matIndexToMatRoot[prim.MaterialIndex]
+				// Meshes without vertex colors use white so they are
+				// not tinted by materials that enable vertex colors
+				if hasVertexColors {
+					for i := uint32(0); i < 3; i++ {
+						color := types.Vec4{1, 1, 1, 1}
+						if pm.HasVertexColors {
+							color = prim.Colors[i]
+						}
+						sc.optimizedScene.VertexColorList[vertexOffset+i] = color
+					}
+				}
+
+				// Lookup root material node for primitive material index. Unknown
+				// materials are flagged with an invalid index and resolved
+				// by resolveMissingMaterials.
+				matNodeIndex, exists := sc.matIndexToMatRoot[prim.MaterialIndex]
+				if !exists {
+					matNodeIndex = -1
+				}
 				sc.optimizedScene.MaterialIndex[primOffset] = uint32(matNodeIndex)
 
 				// Check if this an emissive primitive and keep track of it
 				// Since we may use multiple instances of this mesh we need a
 				// separate pass to generate a primitive for each mesh instance
-				if emissiveNodeIndex := sc.emissiveIndexCache[prim.MaterialIndex]; emissiveNodeIndex != -1 {
+				if emissiveNodeIndex, isEmissive := sc.emissiveIndexCache[prim.MaterialIndex]; isEmissive && emissiveNodeIndex != -1 && !trackedEmissives[prim] {
+					trackedEmissives[prim] = true
 					meshEmissivePrimitives = append(meshEmissivePrimitives, &scene.EmissivePrimitive{
 						// area = 0.5 * len(cross(v2-v0, v2-v1))
 						Area:              0.5 * prim.Vertices[2].Sub(prim.Vertices[0]).Cross(prim.Vertices[2].Sub(prim.Vertices[1])).Len(),
@@ -167,7 +288,10 @@ func (sc *sceneCompiler) partitionGeometry() error {
 				vertexOffset += 3
 				primOffset++
 			}
-		}, bvh.SurfaceAreaHeuristic)
+		})
+		if err != nil {
+			return fmt.Errorf("mesh %q: %v", pm.Name, err)
+		}
 
 		// Apply offset to bvh nodes and append them to the scene bvh list
 		offset := int32(len(sc.optimizedScene.BvhNodeList))
@@ -178,6 +302,11 @@ func (sc *sceneCompiler) partitionGeometry() error {
 		sc.optimizedScene.BvhNodeList = append(sc.optimizedScene.BvhNodeList, bvhNodes...)
 	}
 
+	err := sc.resolveMissingMaterials()
+	if err != nil {
+		return err
+	}
+
 	sc.logger.Infof("processing %d mesh instances", len(sc.parsedScene.MeshInstances))
 
 	// Process each mesh instance
@@ -215,6 +344,16 @@ func (sc *sceneCompiler) partitionGeometry() error {
 		emp := scene.EmissivePrimitive{
 			MaterialNodeIndex: uint32(sc.emissiveIndexCache[int(sc.optimizedScene.SceneEmissiveMatIndex)]),
 			Type:              scene.EnvironmentLight,
+			Transform:         scene.EnvironmentRotation(sc.optimizedScene.EnvironmentYaw),
+		}
+		sc.optimizedScene.EmissivePrimitives = append(sc.optimizedScene.EmissivePrimitives, emp)
+	}
+
+	// If a sun light is defined for the scene create an emissive for it
+	if sc.parsedScene.Sun != nil {
+		emp, err := sc.sunEmissive()
+		if err != nil {
+			return err
 		}
 		sc.optimizedScene.EmissivePrimitives = append(sc.optimizedScene.EmissivePrimitives, emp)
 	}
@@ -230,6 +369,56 @@ func (sc *sceneCompiler) partitionGeometry() error {
 	return nil
 }
 
+// Create an emissive primitive for the scene sun light. The sun radiance is
+// defined by the emissive node of the scene sun material.
+func (sc *sceneCompiler) sunEmissive() (scene.EmissivePrimitive, error) {
+	sun := sc.parsedScene.Sun
+	if sun.Direction.Len() == 0 {
+		return scene.EmissivePrimitive{}, fmt.Errorf("sun direction must not be a zero vector")
+	}
+	if sun.AngularDiameter < 0 || sun.AngularDiameter >= 180 {
+		return scene.EmissivePrimitive{}, fmt.Errorf("sun angular diameter must be in the [0, 180) range; got %f", sun.AngularDiameter)
+	}
+	if sc.sunMatIndex == -1 || sc.emissiveIndexCache[sc.sunMatIndex] == -1 {
+		return scene.EmissivePrimitive{}, fmt.Errorf("sun light requires an emissive %q material", SceneSunMaterialName)
+	}
+
+	light := scene.SunLight{Direction: sun.Direction, AngularDiameter: sun.AngularDiameter}
+	return light.Emissive(uint32(sc.emissiveIndexCache[sc.sunMatIndex])), nil
+}
+
+// Grow the flat primitive lists so they can hold at least numPrimitives
+// primitives. The lists are pre-allocated for the number of input primitives
+// but may need to grow when BVH leaves share primitive references.
+func (sc *sceneCompiler) reservePrimitives(numPrimitives int) {
+	extra := numPrimitives - len(sc.optimizedScene.MaterialIndex)
+	if extra <= 0 {
+		return
+	}
+
+	sc.optimizedScene.VertexList = append(sc.optimizedScene.VertexList, make([]types.Vec4, 3*extra)...)
+	sc.optimizedScene.NormalList = append(sc.optimizedScene.NormalList, make([]types.Vec4, 3*extra)...)
+	sc.optimizedScene.UvList = append(sc.optimizedScene.UvList, make([]types.Vec2, 3*extra)...)
+	sc.optimizedScene.MaterialIndex = append(sc.optimizedScene.MaterialIndex, make([]uint32, extra)...)
+	if sc.optimizedScene.VertexColorList != nil {
+		sc.optimizedScene.VertexColorList = append(sc.optimizedScene.VertexColorList, make([]types.Vec4, 3*extra)...)
+	}
+}
+
+// Assign the scene default material to primitives that reference unknown
+// materials or fail if strict material checks are enabled.
+func (sc *sceneCompiler) resolveMissingMaterials() error {
+	numFallbacks, err := sc.optimizedScene.ApplyMaterialFallback(sc.options.StrictMaterials)
+	if err != nil {
+		return err
+	}
+
+	if numFallbacks > 0 {
+		sc.logger.Warningf("%d primitives reference unknown materials; using the default material", numFallbacks)
+	}
+	return nil
+}
+
 // Initialize and position the camera for the scene.
 func (sc *sceneCompiler) setupCamera() error {
 	sc.optimizedScene.Camera = scene.NewCamera(sc.parsedScene.Camera.FOV)
@@ -259,14 +448,28 @@ func (sc *sceneCompiler) findMaterialNodeByBxdf(nodeIndex uint32, bxdf material.
 		return out
 	}
 
-	// If this is a mix node descend into the right child
-	if nodeType == uint32(material.OpMix) {
+	// If this is a mix or layered node descend into the right child
+	if nodeType == uint32(material.OpMix) || nodeType == uint32(material.OpLayered) {
 		out = sc.findMaterialNodeByBxdf(uint32(node.Union1[2]), bxdf)
 	}
 
 	return out
 }
 
+// Estimate the albedo of a material tree for the statistical model used by
+// layered nodes. Textured or nested nodes are assumed to reflect all energy
+// which yields the upper bound for the inter-layer reflections.
+func (sc *sceneCompiler) estimateAlbedo(nodeIndex uint32) float32 {
+	node := sc.optimizedScene.MaterialNodeList[nodeIndex]
+	switch material.BxdfType(node.Union1[0]) {
+	case material.BxdfDiffuse, material.BxdfConductor, material.BxdfRoughtConductor:
+		if node.Union1[3] == -1 {
+			return node.Union2.Vec3().MaxComponent()
+		}
+	}
+	return 1.0
+}
+
 // Parse material definitions into a node-based structure that models a layered material.
 func (sc *sceneCompiler) createLayeredMaterialTrees() error {
 	start := time.Now()
@@ -296,12 +499,23 @@ func (sc *sceneCompiler) createLayeredMaterialTrees() error {
 			return err
 		}
 
+		if mat.Transparency < 0 || mat.Transparency > 1 {
+			return fmt.Errorf("material %q: transparency must be in the [0, 1] range; got %f", mat.Name, mat.Transparency)
+		} else if mat.Transparency > 0 {
+			err = sc.optimizedScene.SetOpacity(uint32(sc.matIndexToMatRoot[matIndex]), 1-mat.Transparency)
+			if err != nil {
+				return err
+			}
+		}
+
 		sc.emissiveIndexCache[matIndex] = sc.findMaterialNodeByBxdf(uint32(sc.matIndexToMatRoot[matIndex]), material.BxdfEmissive)
 
 		if mat.Name == SceneDiffuseMaterialName {
 			sc.optimizedScene.SceneDiffuseMatIndex = sc.matIndexToMatRoot[matIndex]
 		} else if mat.Name == SceneEmissiveMaterialName {
 			sc.optimizedScene.SceneEmissiveMatIndex = sc.matIndexToMatRoot[matIndex]
+		} else if mat.Name == SceneSunMaterialName {
+			sc.sunMatIndex = matIndex
 		}
 	}
 
@@ -338,6 +552,8 @@ func (sc *sceneCompiler) generateMaterialTree(mat *input.Material, exprNode mate
 		Union5: [1]int32{-1},
 		// Default IORs
 		Union4: types.Vec3{material.DefaultIntIOR, material.DefaultExtIOR, 0.0},
+		// Default normal map strength
+		Union6: types.Vec4{0.0, 0.0, 0.0, material.DefaultNormalScale},
 	}
 
 	switch t := exprNode.(type) {
@@ -380,10 +596,15 @@ func (sc *sceneCompiler) generateMaterialTree(mat *input.Material, exprNode mate
 			node.Union2 = material.DefaultSpecularity
 			node.Union3 = material.DefaultTransmittance
 			node.Union4[2] = material.DefaultRoughness
+		case material.BxdfSubsurface:
+			// Default albedo and mean free path
+			node.Union2 = material.DefaultReflectance
+			node.Union4[2] = material.DefaultMeanFreePath
 		case material.BxdfEmissive:
-			// Default radiance and scaler
+			// Default radiance, scaler and emission side
 			node.Union2 = material.DefaultRadiance
 			node.Union4[2] = material.DefaultRadianceScaler
+			node.Union5[0] = int32(material.EmitFront)
 		}
 
 		// Apply parameters
@@ -433,6 +654,7 @@ func (sc *sceneCompiler) generateMaterialTree(mat *input.Material, exprNode mate
 		}
 	case material.NormalMapNode:
 		node.Union1[0] = int32(material.OpNormalMap)
+		node.Union5[0] = int32(mat.NormalMapConvention)
 		node.Union1[1], err = sc.generateMaterialTree(mat, t.Expression)
 		if err != nil {
 			return -1, err
@@ -451,6 +673,41 @@ func (sc *sceneCompiler) generateMaterialTree(mat *input.Material, exprNode mate
 
 		node.Union2 = types.Vec3(t.IntIOR).Vec4(0)
 		node.Union3 = types.Vec3(t.ExtIOR).Vec4(0)
+	case material.LayeredNode:
+		node.Union1[0] = int32(material.OpLayered)
+		node.Union1[1], err = sc.generateMaterialTree(mat, t.Coat)
+		if err != nil {
+			return -1, err
+		}
+		node.Union1[2], err = sc.generateMaterialTree(mat, t.Base)
+		if err != nil {
+			return -1, err
+		}
+
+		// Copy the coat interface IORs and absorption into the op node
+		coat := sc.optimizedScene.MaterialNodeList[node.Union1[1]]
+		coatType := material.BxdfType(coat.Union1[0])
+		if coatType != material.BxdfDielectric && coatType != material.BxdfRoughDielectric {
+			return -1, fmt.Errorf("%q: layered coat must be a dielectric or roughDielectric bxdf; got %q", mat.Name, coatType)
+		}
+		node.Union2 = coat.Union3.Vec3().Vec4(t.Thickness)
+		node.Union3[0] = material.InternalDiffuseReflectance(coat.Union4[0] / coat.Union4[1])
+		node.Union3[1] = sc.estimateAlbedo(uint32(node.Union1[2]))
+		node.Union4 = types.Vec3{coat.Union4[0], coat.Union4[1], 0}
+
+		// Fallback to the material coat normal map if the expression does
+		// not specify one
+		coatNormal := t.CoatNormal
+		if coatNormal == "" {
+			coatNormal = material.TextureNode(mat.CoatNormalTexture)
+		}
+		if coatNormal != "" {
+			node.Union5[0] = int32(mat.NormalMapConvention)
+			node.Union1[3], err = sc.bakeTexture(mat, coatNormal)
+			if err != nil {
+				return -1, err
+			}
+		}
 	default:
 		return -1, fmt.Errorf("%q: unsupported node %#+v\n", mat.Name, exprNode)
 	}
@@ -488,8 +745,29 @@ func (sc *sceneCompiler) setMaterialNodeParameter(mat *input.Material, node *sce
 		case material.MaterialNameNode:
 			node.Union4[index], err = material.IOR(t)
 		}
-	case material.ParamScale:
+	case material.ParamEta:
+		node.Union3 = types.Vec3(param.Value.(material.Vec3Node)).Vec4(0.0)
+	case material.ParamK:
+		node.Union6 = types.Vec3(param.Value.(material.Vec3Node)).Vec4(node.Union6[3])
+	case material.ParamMetal:
+		var ior material.ConductorIOR
+		ior, err = material.ComplexIOR(param.Value.(material.MaterialNameNode))
+		node.Union3 = ior.Eta.Vec4(0.0)
+		node.Union6 = ior.K.Vec4(node.Union6[3])
+	case material.ParamScale, material.ParamMeanFreePath:
 		node.Union4[2] = float32(param.Value.(material.FloatNode))
+	case material.ParamNormalScale:
+		node.Union6[3] = float32(param.Value.(material.FloatNode))
+	case material.ParamFalloff:
+		node.Union3[0] = float32(param.Value.(material.FloatNode))
+	case material.ParamVertexColors:
+		node.Union5[0] = int32(param.Value.(material.FloatNode))
+	case material.ParamEmitSide:
+		var side material.EmissionSide
+		side, err = material.EmissionSideFromName(param.Value.(material.MaterialNameNode))
+		node.Union5[0] = int32(side)
+	case material.ParamTemperature:
+		node.Union2 = material.Blackbody(float32(param.Value.(material.FloatNode))).Vec4(0.0)
 	case material.ParamRoughness:
 		switch t := param.Value.(type) {
 		case material.FloatNode:
@@ -506,23 +784,42 @@ func (sc *sceneCompiler) setMaterialNodeParameter(mat *input.Material, node *sce
 // Texture data is always aligned on a dword boundary.
 func (sc *sceneCompiler) bakeTexture(mat *input.Material, texNode material.TextureNode) (int32, error) {
 	texPath := string(texNode)
-	res, err := asset.NewResource(texPath, mat.AssetRelPath)
-	if err != nil {
-		sc.logger.Warningf("%q: skipping missing texture %q", mat.Name, texPath)
-		return -1, nil
-	}
 
-	// Check if texture is already loaded
-	if texIndex, exists := sc.texIndexCache[res.Path()]; exists {
-		sc.logger.Infof("%q: re-using already loaded texture %q", mat.Name, texPath)
-		return texIndex, nil
-	}
+	var tex *texture.Texture
+	var cacheKey string
+	if texture.IsProcedural(texPath) {
+		cacheKey = texPath
+		if texIndex, exists := sc.texIndexCache[cacheKey]; exists {
+			sc.logger.Infof("%q: re-using procedural texture %q", mat.Name, texPath)
+			return texIndex, nil
+		}
 
-	sc.logger.Infof("%q: processing texture %q", mat.Name, texPath)
+		sc.logger.Infof("%q: processing procedural texture %q", mat.Name, texPath)
+		proc, err := texture.ParseProcedural(texPath)
+		if err != nil {
+			return -1, fmt.Errorf("%q: %v", mat.Name, err)
+		}
+		tex = proc.Texture()
+	} else {
+		res, err := asset.NewResource(texPath, mat.AssetRelPath)
+		if err != nil {
+			sc.logger.Warningf("%q: skipping missing texture %q", mat.Name, texPath)
+			return -1, nil
+		}
 
-	tex, err := texture.New(res)
-	if err != nil {
-		return -1, fmt.Errorf("%q: %v", mat.Name, err)
+		// Check if texture is already loaded
+		cacheKey = res.Path()
+		if texIndex, exists := sc.texIndexCache[cacheKey]; exists {
+			sc.logger.Infof("%q: re-using already loaded texture %q", mat.Name, texPath)
+			return texIndex, nil
+		}
+
+		sc.logger.Infof("%q: processing texture %q", mat.Name, texPath)
+
+		tex, err = texture.New(res)
+		if err != nil {
+			return -1, fmt.Errorf("%q: %v", mat.Name, err)
+		}
 	}
 
 	dataOffset := len(sc.optimizedScene.TextureData)
@@ -548,7 +845,7 @@ func (sc *sceneCompiler) bakeTexture(mat *input.Material, texNode material.Textu
 	)
 
 	texIndex := int32(len(sc.optimizedScene.TextureMetadata) - 1)
-	sc.texIndexCache[res.Path()] = texIndex
+	sc.texIndexCache[cacheKey] = texIndex
 	return texIndex, nil
 }
 
@@ -0,0 +1,26 @@
+package input
+
+import "github.com/achilleasa/polaris/asset/scene"
+
+// Remove the mesh primitives whose area does not exceed epsilon; see
+// scene.IsDegenerateTriangle. The material index of each primitive is stored
+// with the primitive so the remaining primitives keep their materials.
+// Returns the number of removed primitives.
+func (m *Mesh) RemoveDegeneratePrimitives(epsilon float32) int {
+	kept := m.Primitives[:0]
+	for _, prim := range m.Primitives {
+		if !scene.IsDegenerateTriangle(prim.Vertices[0], prim.Vertices[1], prim.Vertices[2], epsilon) {
+			kept = append(kept, prim)
+		}
+	}
+
+	removed := len(m.Primitives) - len(kept)
+	for index := len(kept); index < len(m.Primitives); index++ {
+		m.Primitives[index] = nil
+	}
+	m.Primitives = kept
+	if removed != 0 {
+		m.MarkBBoxDirty()
+	}
+	return removed
+}
@@ -0,0 +1,54 @@
+package input
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRemoveDegeneratePrimitives(t *testing.T) {
+	nan := float32(math.NaN())
+	mesh := NewMesh("dirty")
+	for materialIndex, vertices := range [][3]types.Vec3{
+		{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		// Collinear vertices
+		{{0, 0, 0}, {1, 1, 1}, {2, 2, 2}},
+		{{0, 0, 0}, {0, 0, 1}, {1, 0, 1}},
+		// Repeated vertex
+		{{1, 1, 0}, {1, 1, 0}, {2, 0, 0}},
+		// Non-finite vertex
+		{{0, 0, 0}, {nan, 0, 0}, {0, 1, 0}},
+		// Sliver with an area of 5e-5
+		{{0, 0, 0}, {1, 0, 0}, {0, 1e-4, 0}},
+	} {
+		mesh.Primitives = append(mesh.Primitives, newPrimitive(vertices, [3]types.Vec3{}, [3]types.Vec2{}, materialIndex))
+	}
+
+	type spec struct {
+		epsilon      float32
+		expMaterials []int
+	}
+	specs := []spec{
+		spec{0, []int{0, 2, 5}},
+		spec{1e-4, []int{0, 2}},
+	}
+
+	for index, s := range specs {
+		m := &Mesh{Primitives: append([]*Primitive(nil), mesh.Primitives...)}
+		removed := m.RemoveDegeneratePrimitives(s.epsilon)
+		if expRemoved := len(mesh.Primitives) - len(s.expMaterials); removed != expRemoved {
+			t.Fatalf("[spec %d] expected %d primitives to be removed; got %d", index, expRemoved, removed)
+		}
+		if len(m.Primitives) != len(s.expMaterials) {
+			t.Fatalf("[spec %d] expected %d primitives to remain; got %d", index, len(s.expMaterials), len(m.Primitives))
+		}
+
+		// Remaining primitives should keep their materials
+		for primIndex, prim := range m.Primitives {
+			if prim.MaterialIndex != s.expMaterials[primIndex] {
+				t.Fatalf("[spec %d] expected primitive %d to use material %d; got %d", index, primIndex, s.expMaterials[primIndex], prim.MaterialIndex)
+			}
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"math"
 
 	"github.com/achilleasa/polaris/asset"
+	"github.com/achilleasa/polaris/asset/compiler/bvh"
+	"github.com/achilleasa/polaris/asset/material"
 	"github.com/achilleasa/polaris/types"
 )
 
@@ -16,6 +18,19 @@ type Material struct {
 	// Relative path for textures.
 	AssetRelPath *asset.Resource
 
+	// The probability that a ray passes through surfaces using this
+	// material without interacting with them (1 - opacity). The zero
+	// value describes an opaque material.
+	Transparency float32
+
+	// The green channel convention of any normal maps used by the
+	// material. The zero value selects the OpenGL convention.
+	NormalMapConvention material.NormalMapConvention
+
+	// The normal map used for shading the coat of any layered nodes that
+	// do not specify their own coat normal map.
+	CoatNormalTexture string
+
 	// True if material is referenced by scene geometry.
 	Used bool
 }
@@ -27,6 +42,9 @@ type Primitive struct {
 	UVs           [3]types.Vec2
 	MaterialIndex int
 
+	// Per-vertex colors. Only used if the mesh enables HasVertexColors.
+	Colors [3]types.Vec4
+
 	bbox   [2]types.Vec3
 	center types.Vec3
 }
@@ -51,11 +69,30 @@ func (prim *Primitive) Center() types.Vec3 {
 	return prim.center
 }
 
+// Get the AABB of the part of the primitive that lies inside bounds.
+// Implements bvh.ClippableVolume.
+func (prim *Primitive) ClipBBox(bounds [2]types.Vec3) ([2]types.Vec3, bool) {
+	return bvh.ClipTriangleBBox(prim.Vertices, bounds)
+}
+
 // A mesh is constructed by a list of primitive.
 type Mesh struct {
 	Name       string
 	Primitives []*Primitive
 
+	// The algorithm for building the mesh BVH tree.
+	BvhBuilder bvh.Builder
+
+	// If non-zero, overrides the compiler max number of primitives in a
+	// BVH node that is not split any further.
+	BvhLeafPrimitives int
+
+	// If not nil, overrides the split budget used by the SBVH builder.
+	BvhSplitAlpha *float32
+
+	// True if the mesh primitives define per-vertex colors.
+	HasVertexColors bool
+
 	bbox            [2]types.Vec3
 	bboxNeedsUpdate bool
 }
@@ -136,6 +173,14 @@ type Camera struct {
 	Up   types.Vec3
 }
 
+// A distant sun light. The light radiance is defined by the scene sun material.
+type Sun struct {
+	Direction types.Vec3
+
+	// The angular diameter of the light disk in degrees.
+	AngularDiameter float32
+}
+
 // The scene contains all elements that are processed and optimized by the scene compiler.
 // optimized
 type Scene struct {
@@ -143,6 +188,9 @@ type Scene struct {
 	MeshInstances []*MeshInstance
 	Materials     []*Material
 	Camera        *Camera
+
+	// An optional sun light.
+	Sun *Sun
 }
 
 // Create a new scene.
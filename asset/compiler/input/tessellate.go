@@ -0,0 +1,216 @@
+package input
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+const (
+	// The default max projected edge length (in pixels) for adaptive
+	// tessellation.
+	DefaultTessellationPixelError float32 = 1.0
+
+	// The default max number of times that a triangle can be subdivided.
+	// Each subdivision level splits a triangle into 4 triangles.
+	DefaultMaxTessellationLevel uint32 = 6
+)
+
+// Options for adaptive tessellation.
+type TessellationOptions struct {
+	// The frame dimensions used for projecting edges to screen space.
+	FrameW, FrameH uint32
+
+	// Triangles are subdivided until none of their edges is longer than
+	// PixelError pixels when projected to screen space. If 0,
+	// DefaultTessellationPixelError is used.
+	PixelError float32
+
+	// The max subdivision level. If 0, DefaultMaxTessellationLevel is used.
+	MaxLevel uint32
+}
+
+// Get the combined view/projection matrix for the camera. The matrix matches
+// the one generated by the compiled scene camera for the same aspect ratio.
+func (c *Camera) ViewProjMat(aspect float32) types.Mat4 {
+	return types.Perspective4(c.FOV, aspect, 1, 1000).Mul4(types.LookAtV(c.Eye, c.Look, c.Up))
+}
+
+// Tessellate the scene meshes for the scene camera. Each mesh is tessellated
+// once using all instance transforms that reference it so that the mesh is
+// refined enough for each one of its instances. As the tessellation is view
+// dependent, it should be applied before compiling the scene for a view.
+func (s *Scene) TessellateAdaptive(opts TessellationOptions) {
+	viewProj := s.Camera.ViewProjMat(float32(opts.FrameW) / float32(opts.FrameH))
+
+	transforms := make([][]types.Mat4, len(s.Meshes))
+	for _, mi := range s.MeshInstances {
+		transforms[mi.MeshIndex] = append(transforms[mi.MeshIndex], mi.Transform)
+	}
+
+	for meshIndex, mesh := range s.Meshes {
+		if len(transforms[meshIndex]) != 0 {
+			mesh.TessellateAdaptive(viewProj, transforms[meshIndex], opts)
+		}
+	}
+}
+
+// Subdivide the mesh triangles until their projected edge length falls below
+// the pixel error target for all supplied mesh-to-world transforms. Each
+// subdivision splits a triangle into 4 triangles by inserting a vertex at
+// the midpoint of each edge and interpolating the vertex normals and UVs.
+//
+// Triangles that lie entirely outside the view frustrum are not subdivided.
+// Edges that cross the camera plane cannot be projected and are subdivided
+// up to the max subdivision level.
+func (m *Mesh) TessellateAdaptive(viewProj types.Mat4, meshToWorld []types.Mat4, opts TessellationOptions) {
+	t := tessellator{
+		halfW:      0.5 * float32(opts.FrameW),
+		halfH:      0.5 * float32(opts.FrameH),
+		pixelError: opts.PixelError,
+		maxLevel:   opts.MaxLevel,
+	}
+	if t.pixelError <= 0 {
+		t.pixelError = DefaultTessellationPixelError
+	}
+	if t.maxLevel == 0 {
+		t.maxLevel = DefaultMaxTessellationLevel
+	}
+	for _, transform := range meshToWorld {
+		t.transforms = append(t.transforms, viewProj.Mul4(transform))
+	}
+
+	primitives := make([]*Primitive, 0, len(m.Primitives))
+	for _, prim := range m.Primitives {
+		primitives = t.subdivide(prim, 0, primitives)
+	}
+
+	m.Primitives = primitives
+	m.MarkBBoxDirty()
+}
+
+type tessellator struct {
+	// Mesh to clip space transforms.
+	transforms []types.Mat4
+
+	halfW, halfH float32
+	pixelError   float32
+	maxLevel     uint32
+}
+
+// Recursively subdivide a primitive and append the generated primitives to out.
+func (t *tessellator) subdivide(prim *Primitive, level uint32, out []*Primitive) []*Primitive {
+	if level >= t.maxLevel || !t.needsSubdivision(prim) {
+		return append(out, prim)
+	}
+
+	// Midpoints for edges (0, 1), (1, 2) and (2, 0)
+	var vm [3]types.Vec3
+	var nm [3]types.Vec3
+	var uvm [3]types.Vec2
+	var cm [3]types.Vec4
+	for i := 0; i < 3; i++ {
+		j := (i + 1) % 3
+		vm[i] = prim.Vertices[i].Add(prim.Vertices[j]).Mul(0.5)
+		nm[i] = prim.Normals[i].Add(prim.Normals[j])
+		if nm[i].Len() > 0 {
+			nm[i] = nm[i].Normalize()
+		}
+		uvm[i] = types.Vec2{
+			0.5 * (prim.UVs[i][0] + prim.UVs[j][0]),
+			0.5 * (prim.UVs[i][1] + prim.UVs[j][1]),
+		}
+		cm[i] = prim.Colors[i].Add(prim.Colors[j]).Mul(0.5)
+	}
+
+	children := [4]*Primitive{
+		newPrimitive([3]types.Vec3{prim.Vertices[0], vm[0], vm[2]}, [3]types.Vec3{prim.Normals[0], nm[0], nm[2]}, [3]types.Vec2{prim.UVs[0], uvm[0], uvm[2]}, prim.MaterialIndex),
+		newPrimitive([3]types.Vec3{vm[0], prim.Vertices[1], vm[1]}, [3]types.Vec3{nm[0], prim.Normals[1], nm[1]}, [3]types.Vec2{uvm[0], prim.UVs[1], uvm[1]}, prim.MaterialIndex),
+		newPrimitive([3]types.Vec3{vm[2], vm[1], prim.Vertices[2]}, [3]types.Vec3{nm[2], nm[1], prim.Normals[2]}, [3]types.Vec2{uvm[2], uvm[1], prim.UVs[2]}, prim.MaterialIndex),
+		newPrimitive(vm, nm, uvm, prim.MaterialIndex),
+	}
+	childColors := [4][3]types.Vec4{
+		{prim.Colors[0], cm[0], cm[2]},
+		{cm[0], prim.Colors[1], cm[1]},
+		{cm[2], cm[1], prim.Colors[2]},
+		cm,
+	}
+	for index, child := range children {
+		child.Colors = childColors[index]
+		out = t.subdivide(child, level+1, out)
+	}
+	return out
+}
+
+// Check whether any of the primitive edges exceeds the pixel error target
+// for any of the tessellator transforms.
+func (t *tessellator) needsSubdivision(prim *Primitive) bool {
+	for _, transform := range t.transforms {
+		var clip [3]types.Vec4
+		for i := 0; i < 3; i++ {
+			clip[i] = transform.Mul4x1(prim.Vertices[i].Vec4(1))
+		}
+		if outsideFrustrum(clip) {
+			continue
+		}
+
+		for i := 0; i < 3; i++ {
+			if t.projectedEdgeLen(clip[i], clip[(i+1)%3]) > t.pixelError {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Calculate the screen space length (in pixels) of an edge given the clip
+// space coordinates of its endpoints.
+func (t *tessellator) projectedEdgeLen(a, b types.Vec4) float32 {
+	switch {
+	case a[3] <= 0 && b[3] <= 0:
+		return 0
+	case a[3] <= 0 || b[3] <= 0:
+		return math.MaxFloat32
+	}
+
+	dx := (a[0]/a[3] - b[0]/b[3]) * t.halfW
+	dy := (a[1]/a[3] - b[1]/b[3]) * t.halfH
+	return float32(math.Sqrt(float64(dx*dx + dy*dy)))
+}
+
+// Check whether all triangle vertices lie outside the same clip plane.
+func outsideFrustrum(clip [3]types.Vec4) bool {
+	for axis := 0; axis < 3; axis++ {
+		var below, above int
+		for i := 0; i < 3; i++ {
+			if clip[i][axis] < -clip[i][3] {
+				below++
+			}
+			if clip[i][axis] > clip[i][3] {
+				above++
+			}
+		}
+		if below == 3 || above == 3 {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a primitive and initialize its bbox and center.
+func newPrimitive(vertices, normals [3]types.Vec3, uvs [3]types.Vec2, materialIndex int) *Primitive {
+	prim := &Primitive{
+		Vertices:      vertices,
+		Normals:       normals,
+		UVs:           uvs,
+		MaterialIndex: materialIndex,
+	}
+	prim.SetBBox(
+		[2]types.Vec3{
+			types.MinVec3(vertices[0], types.MinVec3(vertices[1], vertices[2])),
+			types.MaxVec3(vertices[0], types.MaxVec3(vertices[1], vertices[2])),
+		},
+	)
+	prim.SetCenter(vertices[0].Add(vertices[1]).Add(vertices[2]).Mul(1.0 / 3.0))
+	return prim
+}
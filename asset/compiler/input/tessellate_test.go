@@ -0,0 +1,61 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestTessellateAdaptive(t *testing.T) {
+	// A large ground plane that extends away from the camera
+	mesh := NewMesh("plane")
+	corners := [4]types.Vec3{{-2, -1, -2}, {2, -1, -2}, {2, -1, -50}, {-2, -1, -50}}
+	up := types.Vec3{0, 1, 0}
+	for _, tri := range [2][3]int{{0, 1, 2}, {0, 2, 3}} {
+		mesh.Primitives = append(mesh.Primitives, newPrimitive(
+			[3]types.Vec3{corners[tri[0]], corners[tri[1]], corners[tri[2]]},
+			[3]types.Vec3{up, up, up},
+			[3]types.Vec2{},
+			0,
+		))
+	}
+
+	sc := NewScene()
+	sc.Meshes = append(sc.Meshes, mesh)
+	sc.MeshInstances = append(sc.MeshInstances, &MeshInstance{MeshIndex: 0, Transform: types.Ident4()})
+
+	type spec struct {
+		pixelError float32
+	}
+	specs := []spec{
+		spec{8},
+		spec{32},
+	}
+
+	var prevCount int
+	for index, s := range specs {
+		mesh.Primitives = mesh.Primitives[:2]
+		sc.TessellateAdaptive(TessellationOptions{FrameW: 128, FrameH: 128, PixelError: s.pixelError, MaxLevel: 5})
+
+		// Compare the number of triangles in equally sized slabs near
+		// and far from the camera.
+		var near, far int
+		for _, prim := range mesh.Primitives {
+			z := prim.Center()[2]
+			switch {
+			case z > -12:
+				near++
+			case z < -40:
+				far++
+			}
+		}
+
+		if near <= far {
+			t.Errorf("[spec %d] expected near region to get more triangles than the far region; got %d near and %d far", index, near, far)
+		}
+		if index > 0 && len(mesh.Primitives) >= prevCount {
+			t.Errorf("[spec %d] expected a larger pixel error to generate fewer triangles; got %d; previous %d", index, len(mesh.Primitives), prevCount)
+		}
+		prevCount = len(mesh.Primitives)
+	}
+}
@@ -0,0 +1,56 @@
+package material
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+const (
+	// The supported color temperature range (in Kelvin) for the blackbody approximation.
+	MinBlackbodyTemperature float32 = 1000
+	MaxBlackbodyTemperature float32 = 40000
+)
+
+// Approximate the RGB color of a blackbody radiator at the given temperature
+// (in Kelvin). The returned color is normalized so that its max component is
+// equal to 1 which allows callers to control the emitted power independently
+// of the color.
+//
+// This function uses the curve fitting approximation by Tanner Helland:
+// http://www.tannerhelland.com/4435/convert-temperature-rgb-algorithm-code/
+func Blackbody(kelvin float32) types.Vec3 {
+	if kelvin < MinBlackbodyTemperature {
+		kelvin = MinBlackbodyTemperature
+	} else if kelvin > MaxBlackbodyTemperature {
+		kelvin = MaxBlackbodyTemperature
+	}
+
+	t := float64(kelvin) / 100.0
+	var r, g, b float64
+
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	out := types.Vec3{
+		float32(math.Max(0, math.Min(r, 255)) / 255.0),
+		float32(math.Max(0, math.Min(g, 255)) / 255.0),
+		float32(math.Max(0, math.Min(b, 255)) / 255.0),
+	}
+
+	return out.Mul(1.0 / out.MaxComponent())
+}
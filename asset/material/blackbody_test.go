@@ -0,0 +1,20 @@
+package material
+
+import "testing"
+
+func TestBlackbody(t *testing.T) {
+	warm := Blackbody(2000)
+	if warm[0] != 1.0 || warm[0] <= warm[2] {
+		t.Fatalf("expected a 2000K blackbody to be red-dominant; got %v", warm)
+	}
+
+	cool := Blackbody(20000)
+	if cool[2] != 1.0 || cool[2] <= cool[0] {
+		t.Fatalf("expected a 20000K blackbody to be blue-dominant; got %v", cool)
+	}
+
+	// Out of range values should be clamped
+	if v := Blackbody(1); v != Blackbody(MinBlackbodyTemperature) {
+		t.Fatalf("expected out of range temperature to be clamped; got %v", v)
+	}
+}
@@ -12,6 +12,7 @@ const (
 	BxdfRoughtConductor
 	BxdfDielectric
 	BxdfRoughDielectric
+	BxdfSubsurface
 	//
 	bxdfLastEntry
 )
@@ -31,6 +32,8 @@ func bxdfTypeFromName(name string) BxdfType {
 		return BxdfDielectric
 	case "roughDielectric":
 		return BxdfRoughDielectric
+	case "subsurface":
+		return BxdfSubsurface
 	}
 
 	return bxdfInvalid
@@ -50,6 +53,8 @@ func (t BxdfType) String() string {
 		return "dielectric"
 	case BxdfRoughDielectric:
 		return "roughDielectric"
+	case BxdfSubsurface:
+		return "subsurface"
 	}
 
 	return "invalid"
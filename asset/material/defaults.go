@@ -9,6 +9,8 @@ var (
 	DefaultTransmittance          = types.Vec4{1.0, 1.0, 1.0, 0.0}
 	DefaultRadiance               = types.Vec4{1.0, 1.0, 1.0, 0.0}
 	DefaultRadianceScaler float32 = 1.0
+	DefaultNormalScale    float32 = 1.0
+	DefaultMeanFreePath   float32 = 0.1
 	DefaultIntIOR                 = KnownIORs["Glass"]
 	DefaultExtIOR                 = KnownIORs["Air"]
 )
@@ -0,0 +1,61 @@
+package material
+
+import (
+	"fmt"
+	"math"
+)
+
+// EmissionSide selects which faces of an emissive surface emit light. The
+// front face is the face whose geometric normal points towards the viewer.
+type EmissionSide int32
+
+// Supported emission sides.
+const (
+	EmitFront EmissionSide = iota
+	EmitBack
+	EmitBothSides
+)
+
+// Implements Stringer.
+func (s EmissionSide) String() string {
+	switch s {
+	case EmitFront:
+		return "front"
+	case EmitBack:
+		return "back"
+	case EmitBothSides:
+		return "both"
+	}
+
+	return "invalid"
+}
+
+// Lookup an emission side by its name.
+func EmissionSideFromName(name MaterialNameNode) (EmissionSide, error) {
+	switch name {
+	case "front":
+		return EmitFront, nil
+	case "back":
+		return EmitBack, nil
+	case "both":
+		return EmitBothSides, nil
+	}
+
+	return 0, fmt.Errorf("unsupported emission side %q; supported sides: front, back, both", string(name))
+}
+
+// Attenuate the emission of a surface towards a point at distance dist from
+// it. The attenuation smoothly falls from 1 to 0 at the falloff radius; a
+// radius of 0 disables the falloff.
+//
+// Emission falloff is not physically based and breaks energy conservation
+// as the light that an emitter casts no longer depends only on its radiance.
+// It is intended for art direction (e.g. stylized glows).
+func EmissionFalloff(dist, radius float32) float32 {
+	if radius <= 0 {
+		return 1
+	}
+	t := float32(math.Min(float64(dist/radius), 1))
+	w := 1 - t*t
+	return w * w
+}
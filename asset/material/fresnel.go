@@ -0,0 +1,52 @@
+package material
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Calculate the fresnel reflectance for a dielectric interface given the IOR
+// of the medium that the ray travels through (etaI), the IOR of the medium
+// on the other side of the interface (etaT) and the cosine of the angle
+// between the incoming ray and the surface normal. Returns 1 if the ray
+// undergoes total internal reflection.
+func FresnelDielectric(etaI, etaT, cosI float32) float32 {
+	if cosI < 0 {
+		cosI = -cosI
+	}
+
+	eta := etaI / etaT
+	sinTSq := eta * eta * (1.0 - cosI*cosI)
+	if sinTSq >= 1.0 {
+		return 1.0
+	}
+
+	cosT := float32(math.Sqrt(float64(1.0 - sinTSq)))
+	rs := (etaI*cosI - etaT*cosT) / (etaI*cosI + etaT*cosT)
+	rp := (etaT*cosI - etaI*cosT) / (etaT*cosI + etaI*cosT)
+	return 0.5 * (rs*rs + rp*rp)
+}
+
+// Refract a ray pointing away from a dielectric surface with internal IOR
+// intIOR and external IOR extIOR. The IOR ratio is flipped if the ray hits
+// the surface from the inside (dot(inRayDir, normal) < 0). Returns false if
+// the ray undergoes total internal reflection.
+func Refract(inRayDir, normal types.Vec3, intIOR, extIOR float32) (types.Vec3, bool) {
+	etaI, etaT := extIOR, intIOR
+	iDotN := inRayDir.Dot(normal)
+	if iDotN < 0 {
+		etaI, etaT = etaT, etaI
+		normal = normal.Mul(-1)
+		iDotN = -iDotN
+	}
+
+	eta := etaI / etaT
+	sinTSq := eta * eta * (1.0 - iDotN*iDotN)
+	if sinTSq >= 1.0 {
+		return types.Vec3{}, false
+	}
+
+	cosT := float32(math.Sqrt(float64(1.0 - sinTSq)))
+	return normal.Mul(eta*iDotN - cosT).Sub(inRayDir.Mul(eta)), true
+}
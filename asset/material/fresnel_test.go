@@ -0,0 +1,83 @@
+package material
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestDielectricTotalInternalReflection(t *testing.T) {
+	var glassIOR float32 = 1.5
+	normal := types.Vec3{0, 1, 0}
+	criticalAngle := math.Asin(1.0 / float64(glassIOR))
+
+	type spec struct {
+		angle  float64
+		inside bool
+		expTIR bool
+	}
+	specs := []spec{
+		// Entering the glass never results in TIR
+		spec{0, false, false},
+		spec{60, false, false},
+		spec{89, false, false},
+		// Exiting the glass
+		spec{0, true, false},
+		spec{30, true, false},
+		spec{criticalAngle*180/math.Pi - 1, true, false},
+		spec{criticalAngle*180/math.Pi + 1, true, true},
+		spec{60, true, true},
+		spec{89, true, true},
+	}
+
+	for index, s := range specs {
+		theta := s.angle * math.Pi / 180.0
+		// inRayDir points away from the surface
+		inRayDir := types.Vec3{float32(math.Sin(theta)), float32(math.Cos(theta)), 0}
+		if s.inside {
+			inRayDir[1] = -inRayDir[1]
+		}
+
+		var etaI, etaT float32 = 1.0, glassIOR
+		if s.inside {
+			etaI, etaT = etaT, etaI
+		}
+
+		f := FresnelDielectric(etaI, etaT, inRayDir.Dot(normal))
+		outRayDir, refracted := Refract(inRayDir, normal, glassIOR, 1.0)
+		if refracted == s.expTIR {
+			t.Fatalf("[spec %d] expected TIR to be %t", index, s.expTIR)
+		}
+
+		if s.expTIR {
+			if f != 1.0 {
+				t.Fatalf("[spec %d] expected fresnel reflectance for TIR to be 1; got %f", index, f)
+			}
+			continue
+		}
+
+		if f < 0 || f >= 1.0 || math.IsNaN(float64(f)) {
+			t.Fatalf("[spec %d] expected fresnel reflectance to be in [0, 1); got %f", index, f)
+		}
+
+		// The refracted ray should continue on the other side of the surface
+		if math.Abs(float64(outRayDir.Len()-1.0)) > 1e-4 {
+			t.Fatalf("[spec %d] expected refracted ray to be normalized; got length %f", index, outRayDir.Len())
+		}
+		if outRayDir.Dot(normal)*inRayDir.Dot(normal) >= 0 {
+			t.Fatalf("[spec %d] expected refracted ray to cross the surface", index)
+		}
+
+		// Check Snell's law: etaI * sinI = etaT * sinT
+		sinT := math.Sqrt(1.0 - float64(outRayDir.Dot(normal)*outRayDir.Dot(normal)))
+		if delta := float64(etaI)*math.Sin(theta) - float64(etaT)*sinT; math.Abs(delta) > 1e-4 {
+			t.Fatalf("[spec %d] refracted ray violates Snell's law; delta %f", index, delta)
+		}
+	}
+
+	// Normal incidence reflectance for glass: ((1 - 1.5) / (1 + 1.5))^2 = 0.04
+	if f := FresnelDielectric(1.0, glassIOR, 1.0); math.Abs(float64(f-0.04)) > 1e-5 {
+		t.Fatalf("expected normal incidence reflectance to be 0.04; got %f", f)
+	}
+}
@@ -0,0 +1,74 @@
+package material
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The min roughness value used by the opencl rough bxdfs.
+const MinRoughness float32 = 0.1
+
+// Map a roughness value to the alpha parameter of the GGX distribution. The
+// roughness is clamped to the [MinRoughness, 1] range and remapped using the
+// Disney remapping (alpha = roughness^2).
+func GGXAlpha(roughness float32) float32 {
+	if roughness < MinRoughness {
+		roughness = MinRoughness
+	} else if roughness > 1 {
+		roughness = 1
+	}
+	return roughness * roughness
+}
+
+// Evaluate the Smith shadowing-masking term of the GGX distribution for a
+// pair of directions pointing away from the surface.
+func GGXG(alpha float32, inDir, outDir, n, m types.Vec3) float32 {
+	return ggxG1(alpha, inDir, n, m) * ggxG1(alpha, outDir, n, m)
+}
+
+func ggxG1(alpha float32, v, n, m types.Vec3) float32 {
+	nDotV := n.Dot(v)
+	if nDotV*m.Dot(v) <= 0 {
+		return 0
+	}
+	nDotVSq := nDotV * nDotV
+	tanSq := (1 - nDotVSq) / nDotVSq
+	return 2 / (1 + float32(math.Sqrt(float64(1+alpha*alpha*tanSq))))
+}
+
+// Sample a microfacet normal around n with a PDF of D(m) * |dot(m, n)|.
+func GGXSample(alpha float32, n types.Vec3, randSample types.Vec2) types.Vec3 {
+	u, v := types.BuildOrthonormalBasis(n)
+	theta := math.Atan(float64(alpha) * math.Sqrt(float64(randSample[0]/(1-randSample[0]))))
+	phi := 2 * math.Pi * float64(randSample[1])
+	sinTheta, cosTheta := float32(math.Sin(theta)), float32(math.Cos(theta))
+	return u.Mul(sinTheta * float32(math.Cos(phi))).
+		Add(v.Mul(sinTheta * float32(math.Sin(phi)))).
+		Add(n.Mul(cosTheta)).
+		Normalize()
+}
+
+// Increase the roughness of glossy bxdfs to at least minRoughness. Smooth
+// conductors and dielectrics are converted to their rough counterparts while
+// other bxdf types are returned unchanged. Regularizing the bxdfs encountered
+// after a diffuse bounce allows light sampling to resolve caustic paths that
+// would otherwise only be found by chance, trading fireflies for some bias.
+// A minRoughness of 0 disables regularization.
+func RegularizeRoughness(bxdf BxdfType, roughness, minRoughness float32) (BxdfType, float32) {
+	if minRoughness <= 0 {
+		return bxdf, roughness
+	}
+
+	switch bxdf {
+	case BxdfConductor:
+		return BxdfRoughtConductor, minRoughness
+	case BxdfDielectric:
+		return BxdfRoughDielectric, minRoughness
+	case BxdfRoughtConductor, BxdfRoughDielectric:
+		if roughness < minRoughness {
+			return bxdf, minRoughness
+		}
+	}
+	return bxdf, roughness
+}
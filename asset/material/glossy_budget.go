@@ -0,0 +1,68 @@
+package material
+
+import "fmt"
+
+// The action taken for glossy bounces that exceed the glossy bounce budget
+// of a path.
+type GlossyBudgetPolicy uint8
+
+// Supported glossy budget policies. The values must match the ones defined
+// by the opencl kernels.
+const (
+	// Terminate the path at the glossy surface.
+	GlossyBudgetTerminate GlossyBudgetPolicy = iota
+
+	// Shade the glossy surface as a diffuse surface with the same color.
+	GlossyBudgetDiffuse
+)
+
+// Implements Stringer.
+func (p GlossyBudgetPolicy) String() string {
+	switch p {
+	case GlossyBudgetTerminate:
+		return "terminate"
+	case GlossyBudgetDiffuse:
+		return "diffuse"
+	}
+
+	return "invalid"
+}
+
+// Lookup a glossy budget policy by its name.
+func GlossyBudgetPolicyFromName(name string) (GlossyBudgetPolicy, error) {
+	switch name {
+	case "terminate":
+		return GlossyBudgetTerminate, nil
+	case "diffuse":
+		return GlossyBudgetDiffuse, nil
+	}
+
+	return 0, fmt.Errorf("unsupported glossy budget policy %q; supported policies: terminate, diffuse", name)
+}
+
+// Check whether a bxdf counts towards the glossy bounce budget of a path.
+// Only reflective bxdfs are counted as the paths refracted by dielectrics
+// usually remain visible through many bounces.
+func IsGlossyBxdf(bxdf BxdfType) bool {
+	return bxdf == BxdfConductor || bxdf == BxdfRoughtConductor
+}
+
+// Apply a glossy bounce budget to a bxdf that is encountered after
+// glossyBounces glossy bounces along a path. Once the budget is exhausted,
+// glossy bxdfs are handled according to the specified policy. Returns the bxdf
+// to shade the surface with and false if the path should be terminated. A
+// maxGlossyBounces value of 0 disables the budget.
+//
+// Unlike roughness regularization, the budget does not aim to reduce noise but
+// to skip deep glossy interreflections which are expensive to trace and
+// barely visible in the final image.
+func ApplyGlossyBudget(bxdf BxdfType, glossyBounces, maxGlossyBounces uint32, policy GlossyBudgetPolicy) (BxdfType, bool) {
+	if maxGlossyBounces == 0 || glossyBounces < maxGlossyBounces || !IsGlossyBxdf(bxdf) {
+		return bxdf, true
+	}
+
+	if policy == GlossyBudgetDiffuse {
+		return BxdfDiffuse, true
+	}
+	return bxdf, false
+}
@@ -3,8 +3,17 @@ package material
 import (
 	"fmt"
 	"strings"
+
+	"github.com/achilleasa/polaris/types"
 )
 
+// The complex IOR of a conductor. Eta and K contain the real and imaginary
+// part of the IOR for the red, green and blue wavelengths.
+type ConductorIOR struct {
+	Eta types.Vec3
+	K   types.Vec3
+}
+
 // Built-in list of known material IORs.
 // Sourced from: http://forums.cgsociety.org/archive/index.php?t-513458.html
 var (
@@ -256,9 +265,51 @@ var (
 		"Zirconia, Cubic":         2.170,
 	}
 
+	// Built-in list of known conductor complex IORs. The RGB values are
+	// derived from measured spectral data sampled at 650, 550 and 450 nm.
+	KnownConductorIORs = map[string]ConductorIOR{
+		"Aluminum": {
+			Eta: types.Vec3{1.65746, 0.880369, 0.521229},
+			K:   types.Vec3{9.22387, 6.26952, 4.837},
+		},
+		"Copper": {
+			Eta: types.Vec3{0.200438, 0.924033, 1.10221},
+			K:   types.Vec3{3.91295, 2.45285, 2.14219},
+		},
+		"Gold": {
+			Eta: types.Vec3{0.143119, 0.374957, 1.44248},
+			K:   types.Vec3{3.98316, 2.38572, 1.60322},
+		},
+		"Iron": {
+			Eta: types.Vec3{2.9114, 2.9497, 2.5845},
+			K:   types.Vec3{3.0893, 2.9318, 2.767},
+		},
+		"Silver": {
+			Eta: types.Vec3{0.155265, 0.116723, 0.138342},
+			K:   types.Vec3{4.82835, 3.12225, 2.14696},
+		},
+	}
+
+	// Named IOR presets for common dielectrics. Unlike KnownIORs, which
+	// lists measured values, presets use the rounded values that artists
+	// typically author with.
+	IORPresets = map[string]float32{
+		"air":      1.0,
+		"water":    1.33,
+		"ice":      1.31,
+		"glass":    1.5,
+		"plastic":  1.46,
+		"crystal":  2.0,
+		"sapphire": 1.77,
+		"diamond":  2.42,
+	}
+
 	// We initialize this to the contents of the built-in KnownIOR map
 	// but we use capitalized keys so we can perform case-insensitive searches
 	iorLUT map[string]float32
+
+	// Case-insensitive lookup table for KnownConductorIORs
+	conductorIORLUT map[string]ConductorIOR
 )
 
 func IOR(name MaterialNameNode) (float32, error) {
@@ -269,9 +320,32 @@ func IOR(name MaterialNameNode) (float32, error) {
 	return 0.0, fmt.Errorf("unknown material name %q; try specifying the IOR manually", name)
 }
 
+// Lookup the IOR for a named preset. Preset names are case-insensitive.
+func IORPreset(name string) (float32, error) {
+	if ior, exists := IORPresets[strings.ToLower(name)]; exists {
+		return ior, nil
+	}
+
+	return 0.0, fmt.Errorf("unknown IOR preset %q", name)
+}
+
+// Lookup the complex IOR of a known conductor.
+func ComplexIOR(name MaterialNameNode) (ConductorIOR, error) {
+	if ior, exists := conductorIORLUT[strings.ToUpper(string(name))]; exists {
+		return ior, nil
+	}
+
+	return ConductorIOR{}, fmt.Errorf("unknown metal name %q; try specifying the eta and k parameters manually", name)
+}
+
 func init() {
 	iorLUT = make(map[string]float32, len(KnownIORs))
 	for k, v := range KnownIORs {
 		iorLUT[strings.ToUpper(k)] = v
 	}
+
+	conductorIORLUT = make(map[string]ConductorIOR, len(KnownConductorIORs))
+	for k, v := range KnownConductorIORs {
+		conductorIORLUT[strings.ToUpper(k)] = v
+	}
 }
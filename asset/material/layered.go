@@ -0,0 +1,61 @@
+package material
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Layered materials stack a coat bxdf on top of a base expression and are
+// evaluated using a position-free statistical model: instead of tracing the
+// light transport inside the coat, the renderer only tracks the expected
+// energy that is exchanged between the layers.
+//
+// When a ray hits a layered surface, the coat is selected with probability
+// equal to the fresnel reflectance F(cosI) of the coat interface and reflects
+// the ray. Otherwise, the ray is refracted into the coat and the base layer is
+// selected. The base sample is then tinted by the coat transmission:
+//
+//   T = (1 - Fdr) * A / (1 - Fdr * albedo * A),  with A = transmittance^(2 * thickness / cosT)
+//
+// where cosT is the cosine of the refracted ray, transmittance is the coat
+// transmittance per unit of thickness, A is the absorption for the entry and
+// the exit path through the coat (both assumed to have the same length), Fdr
+// is the hemispherical internal reflectance of the coat interface and albedo
+// is the estimated albedo of the base layer. The denominator accounts for light that
+// bounces back and forth between the base and the underside of the coat.
+//
+// As T <= 1 and F + (1 - F) = 1, layered materials never create energy. With
+// a non-absorbing coat over a white base the model is energy preserving.
+
+// Approximate the hemispherical reflectance for diffuse light that hits the
+// inner side of a dielectric interface with relative IOR eta = intIOR/extIOR.
+// This uses the polynomial fit from Jensen et al. "A practical model for
+// subsurface light transport" (2001).
+func InternalDiffuseReflectance(eta float32) float32 {
+	if eta <= 1.0 {
+		return 0
+	}
+	return -1.440/(eta*eta) + 0.710/eta + 0.668 + 0.0636*eta
+}
+
+// Calculate the tint that is applied to the base layer of a layered material
+// for a ray hitting the coat with cosI. The internalReflectance and
+// baseAlbedo arguments correspond to Fdr and albedo in the statistical model
+// documented above.
+func LayerTransmission(transmittance types.Vec3, thickness, intIOR, extIOR, internalReflectance, baseAlbedo, cosI float32) types.Vec3 {
+	if cosI < 0 {
+		cosI = -cosI
+	}
+
+	eta := extIOR / intIOR
+	sinTSq := eta * eta * (1.0 - cosI*cosI)
+	cosT := float32(math.Sqrt(math.Max(1e-4, float64(1.0-sinTSq))))
+
+	var out types.Vec3
+	for c := 0; c < 3; c++ {
+		a := float32(math.Pow(float64(transmittance[c]), float64(2.0*thickness/cosT)))
+		out[c] = (1.0 - internalReflectance) * a / (1.0 - internalReflectance*baseAlbedo*a)
+	}
+	return out
+}
@@ -39,6 +39,15 @@ import (
 %token <sVal> tokEXT_IOR
 %token <sVal> tokSCALE 
 %token <sVal> tokROUGHNESS
+%token <sVal> tokTEMPERATURE
+%token <sVal> tokNORMAL_SCALE
+%token <sVal> tokVERTEX_COLORS
+%token <sVal> tokFALLOFF
+%token <sVal> tokMEAN_FREE_PATH
+%token <sVal> tokETA
+%token <sVal> tokK
+%token <sVal> tokMETAL
+%token <sVal> tokEMIT_SIDE
 
 /* tokBxDF types */
 %token <sVal> tokDIFFUSE 
@@ -47,6 +56,7 @@ import (
 %token <sVal> tokDIELECTRIC
 %token <sVal> tokROUGH_DIELECTRIC
 %token <sVal> tokEMISSIVE 
+%token <sVal> tokSUBSURFACE
 
 /* tokBlend functions */
 %token <sVal> tokMIX
@@ -54,6 +64,7 @@ import (
 %token <sVal> tokBUMP_MAP
 %token <sVal> tokNORMAL_MAP
 %token <sVal> tokDISPERSE
+%token <sVal> tokLAYERED
 
 /* types for non-token items */
 %type <node> material_def
@@ -92,6 +103,7 @@ bxdf_type: tokDIFFUSE
 	 | tokDIELECTRIC
 	 | tokROUGH_DIELECTRIC
 	 | tokEMISSIVE
+	 | tokSUBSURFACE
 
 opt_bxdf_parameter_list: /* empty */
 		       { $$ = make(BxdfParameterList, 0) }
@@ -118,6 +130,24 @@ bxdf_parameter: tokREFLECTANCE tokCOLON float3_or_texture
 	      { $$ = BxdfParamNode{Name: $1, Value: FloatNode($3)} }
 	      | tokROUGHNESS tokCOLON float_or_texture
 	      { $$ = BxdfParamNode{Name: $1, Value: $3} }
+	      | tokTEMPERATURE tokCOLON tokFLOAT
+	      { $$ = BxdfParamNode{Name: $1, Value: FloatNode($3)} }
+	      | tokNORMAL_SCALE tokCOLON tokFLOAT
+	      { $$ = BxdfParamNode{Name: $1, Value: FloatNode($3)} }
+	      | tokVERTEX_COLORS tokCOLON tokFLOAT
+	      { $$ = BxdfParamNode{Name: $1, Value: FloatNode($3)} }
+	      | tokFALLOFF tokCOLON tokFLOAT
+	      { $$ = BxdfParamNode{Name: $1, Value: FloatNode($3)} }
+	      | tokMEAN_FREE_PATH tokCOLON tokFLOAT
+	      { $$ = BxdfParamNode{Name: $1, Value: FloatNode($3)} }
+	      | tokETA tokCOLON float3_or_texture
+	      { $$ = BxdfParamNode{Name: $1, Value: $3} }
+	      | tokK tokCOLON float3_or_texture
+	      { $$ = BxdfParamNode{Name: $1, Value: $3} }
+	      | tokMETAL tokCOLON float_or_name
+	      { $$ = BxdfParamNode{Name: $1, Value: $3} }
+	      | tokEMIT_SIDE tokCOLON float_or_name
+	      { $$ = BxdfParamNode{Name: $1, Value: $3} }
 
 float3_or_texture: float3
 		 | tokTEXTURE { $$ = TextureNode($1) }
@@ -167,6 +197,23 @@ op_spec: tokMIX tokLPAREN bxdf_or_op_spec tokCOMMA bxdf_or_op_spec tokCOMMA tokF
 			ExtIOR: $11.(Vec3Node),
 		}
 	  }
+	  | tokLAYERED tokLPAREN bxdf_or_op_spec tokCOMMA bxdf_or_op_spec tokCOMMA tokFLOAT tokRPAREN
+	  {
+	  	$$ = LayeredNode{
+			Coat: $3,
+			Base: $5,
+			Thickness: $7,
+		}
+	  }
+	  | tokLAYERED tokLPAREN bxdf_or_op_spec tokCOMMA bxdf_or_op_spec tokCOMMA tokFLOAT tokCOMMA tokTEXTURE tokRPAREN
+	  {
+	  	$$ = LayeredNode{
+			Coat: $3,
+			Base: $5,
+			Thickness: $7,
+			CoatNormal: TextureNode($9),
+		}
+	  }
 
 bxdf_or_op_spec: bxdf_spec
 	       | op_spec
@@ -267,7 +314,7 @@ func (x *matExprLexer) lexLiteral(c rune, yylval *exprSymType) int {
 	}
 
 	yylval.sVal = x.tokenBuf.String()
-	if supportedImageRegex.MatchString(yylval.sVal) {
+	if supportedImageRegex.MatchString(yylval.sVal) || proceduralTextureRegex.MatchString(yylval.sVal) {
 		return tokTEXTURE
 	}
 
@@ -300,12 +347,14 @@ func (x *matExprLexer) lexIdentifier(c rune, yylval *exprSymType) int {
 	case "dielectric": return tokDIELECTRIC
 	case "roughDielectric": return tokROUGH_DIELECTRIC
 	case "emissive": return tokEMISSIVE
+	case "subsurface": return tokSUBSURFACE
 	// Operators
 	case "mix": return tokMIX
 	case "mixMap": return tokMIX_MAP
 	case "bumpMap": return tokBUMP_MAP
 	case "normalMap": return tokNORMAL_MAP
 	case "disperse": return tokDISPERSE
+	case "layered": return tokLAYERED
 	// Parameters
 	case ParamReflectance: return tokREFLECTANCE
 	case ParamSpecularity: return tokSPECULARITY
@@ -315,6 +364,15 @@ func (x *matExprLexer) lexIdentifier(c rune, yylval *exprSymType) int {
 	case ParamExtIOR: return tokEXT_IOR
 	case ParamScale: return tokSCALE
 	case ParamRoughness: return tokROUGHNESS
+	case ParamTemperature: return tokTEMPERATURE
+	case ParamNormalScale: return tokNORMAL_SCALE
+	case ParamVertexColors: return tokVERTEX_COLORS
+	case ParamFalloff: return tokFALLOFF
+	case ParamMeanFreePath: return tokMEAN_FREE_PATH
+	case ParamEta: return tokETA
+	case ParamK: return tokK
+	case ParamMetal: return tokMETAL
+	case ParamEmitSide: return tokEMIT_SIDE
 	default:
 		x.Error(fmt.Sprintf("invalid expression %q", yylval.sVal))
 		return tokEOF
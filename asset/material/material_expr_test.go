@@ -13,9 +13,21 @@ func TestParser(t *testing.T) {
 		`conductor(specularity: "texture.jpg")`,
 		`roughConductor(specularity: {.3,.3,.3}, intIOR: "gold", roughness: 1)`,
 		`emissive(radiance: {1,1,1}, scale: 10)`,
+		`emissive(temperature: 6500, scale: 10)`,
 		`bumpMap(conductor(specularity: "texture.jpg"), "foo.jpg")`,
 		`normalMap(conductor(specularity: "texture.jpg"), "foo.jpg")`,
 		`mix(diffuse(reflectance:{0.2, 0.2, 0.2}), conductor(specularity: "texture.jpg"), 0.2, 0.8)`,
+		`diffuse(reflectance: "checker(8, {1,1,1}, {0,0,0})")`,
+		`roughConductor(roughness: "noise(4)")`,
+		`conductor(metal: "gold")`,
+		`roughConductor(eta: {0.2, 0.92, 1.1}, k: {3.9, 2.45, 2.14}, roughness: 0.3)`,
+		`normalMap(diffuse(normalScale: 0.5), "foo.jpg")`,
+		`emissive(radiance: {1,1,1}, emitSide: "both")`,
+		`diffuse(vertexColors: 1)`,
+		`emissive(radiance: {1,1,1}, falloff: 2.5)`,
+		`layered(dielectric(transmittance: {0.9, 0.5, 0.5}, intIOR: 1.5), diffuse(reflectance: {0.8, 0.8, 0.8}), 0.1)`,
+		`layered(roughDielectric(roughness: 0.2), "base", 0)`,
+		`layered(dielectric(intIOR: 1.5), "base", 0, "coat-n.png")`,
 	}
 
 	for index, expr := range validExpr {
@@ -42,6 +54,22 @@ func TestSemanticParseErrors(t *testing.T) {
 		`roughConductor(specularity: {.3,.3,.3}, intIOR: 1.2, extIOR: "foo", roughness: 1)`,
 		`dielectric(transmittance: {1.3,.3,.3})`,
 		`mix(diffuse(), conductor(), 0.2, 1.0)`,
+		`emissive(temperature: 100)`,
+		`emissive(radiance: {1,1,1}, temperature: 6500)`,
+		`conductor(metal: "unobtainium")`,
+		`conductor(metal: "gold", k: {1, 1, 1})`,
+		`conductor(eta: "texture.jpg")`,
+		`dielectric(eta: {1, 1, 1})`,
+		`diffuse(metal: "gold")`,
+		`emissive(normalScale: 0.5)`,
+		`emissive(emitSide: "sideways")`,
+		`emissive(emitSide: 1)`,
+		`diffuse(emitSide: "front")`,
+		`diffuse(vertexColors: 0.5)`,
+		`conductor(vertexColors: 1)`,
+		`diffuse(falloff: 1)`,
+		`layered(conductor(), diffuse(), 0.1)`,
+		`layered(mix(dielectric(), diffuse(), 0.5), diffuse(), 0.1)`,
 	}
 
 	for index, expr := range invalidExpr {
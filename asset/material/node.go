@@ -16,33 +16,56 @@ const (
 	ParamExtIOR        = "extIOR"
 	ParamScale         = "scale"
 	ParamRoughness     = "roughness"
+	ParamTemperature   = "temperature"
+	ParamEta           = "eta"
+	ParamK             = "k"
+	ParamMetal         = "metal"
+	ParamNormalScale   = "normalScale"
+	ParamEmitSide      = "emitSide"
+	ParamVertexColors  = "vertexColors"
+	ParamFalloff       = "falloff"
+	ParamMeanFreePath  = "meanFreePath"
 )
 
 var (
 	bxdfAllowedParameters = map[BxdfType]map[string]struct{}{
 		BxdfEmissive: {
-			ParamRadiance: struct{}{},
-			ParamScale:    struct{}{},
+			ParamRadiance:    struct{}{},
+			ParamScale:       struct{}{},
+			ParamTemperature: struct{}{},
+			ParamEmitSide:    struct{}{},
+			ParamFalloff:     struct{}{},
 		},
 		BxdfDiffuse: {
-			ParamReflectance: struct{}{},
+			ParamReflectance:  struct{}{},
+			ParamNormalScale:  struct{}{},
+			ParamVertexColors: struct{}{},
 		},
 		BxdfConductor: {
 			ParamSpecularity: struct{}{},
 			ParamIntIOR:      struct{}{},
 			ParamExtIOR:      struct{}{},
+			ParamEta:         struct{}{},
+			ParamK:           struct{}{},
+			ParamMetal:       struct{}{},
+			ParamNormalScale: struct{}{},
 		},
 		BxdfRoughtConductor: {
 			ParamSpecularity: struct{}{},
 			ParamIntIOR:      struct{}{},
 			ParamExtIOR:      struct{}{},
 			ParamRoughness:   struct{}{},
+			ParamEta:         struct{}{},
+			ParamK:           struct{}{},
+			ParamMetal:       struct{}{},
+			ParamNormalScale: struct{}{},
 		},
 		BxdfDielectric: {
 			ParamSpecularity:   struct{}{},
 			ParamTransmittance: struct{}{},
 			ParamIntIOR:        struct{}{},
 			ParamExtIOR:        struct{}{},
+			ParamNormalScale:   struct{}{},
 		},
 		BxdfRoughDielectric: {
 			ParamSpecularity:   struct{}{},
@@ -50,6 +73,12 @@ var (
 			ParamIntIOR:        struct{}{},
 			ParamExtIOR:        struct{}{},
 			ParamRoughness:     struct{}{},
+			ParamNormalScale:   struct{}{},
+		},
+		BxdfSubsurface: {
+			ParamReflectance:  struct{}{},
+			ParamMeanFreePath: struct{}{},
+			ParamNormalScale:  struct{}{},
 		},
 	}
 )
@@ -80,6 +109,19 @@ type MixNode struct {
 	Weight      float32
 }
 
+// A coat bxdf layered on top of a base expression. The coat must be a
+// dielectric; its IORs define the coat interface and its transmittance
+// defines the absorption per unit of coat thickness.
+type LayeredNode struct {
+	Coat      ExprNode
+	Base      ExprNode
+	Thickness float32
+
+	// An optional normal map for shading the coat. If empty, the coat
+	// uses the same normal as the base expression.
+	CoatNormal TextureNode
+}
+
 type BumpMapNode struct {
 	Expression ExprNode
 	Texture    TextureNode
@@ -150,6 +192,50 @@ func (n BxdfParamNode) Validate() error {
 		if v, isFloat := n.Value.(FloatNode); isFloat && v > 1.0 {
 			return fmt.Errorf("values for Parameter %q must be in the [0, 1] range", n.Name)
 		}
+	case ParamScale:
+		if v, isFloat := n.Value.(FloatNode); isFloat && v < 0.0 {
+			return fmt.Errorf("values for Parameter %q must be >= 0", n.Name)
+		}
+	case ParamMeanFreePath:
+		if v, isFloat := n.Value.(FloatNode); isFloat && v <= 0.0 {
+			return fmt.Errorf("values for Parameter %q must be > 0", n.Name)
+		}
+	case ParamNormalScale, ParamFalloff:
+		if v, isFloat := n.Value.(FloatNode); isFloat && v < 0.0 {
+			return fmt.Errorf("values for Parameter %q must be >= 0", n.Name)
+		}
+	case ParamTemperature:
+		if v, isFloat := n.Value.(FloatNode); isFloat && (float32(v) < MinBlackbodyTemperature || float32(v) > MaxBlackbodyTemperature) {
+			return fmt.Errorf("values for Parameter %q must be in the [%.0f, %.0f] range", n.Name, MinBlackbodyTemperature, MaxBlackbodyTemperature)
+		}
+	case ParamEta, ParamK:
+		v, isVec := n.Value.(Vec3Node)
+		if !isVec {
+			return fmt.Errorf("values for Parameter %q must be vectors", n.Name)
+		}
+		if v[0] < 0.0 || v[1] < 0.0 || v[2] < 0.0 {
+			return fmt.Errorf("values for Parameter %q must be >= 0", n.Name)
+		}
+	case ParamMetal:
+		v, isMat := n.Value.(MaterialNameNode)
+		if !isMat {
+			return fmt.Errorf("values for Parameter %q must be metal names", n.Name)
+		}
+		if _, err := ComplexIOR(v); err != nil {
+			return err
+		}
+	case ParamVertexColors:
+		if v, isFloat := n.Value.(FloatNode); isFloat && v != 0.0 && v != 1.0 {
+			return fmt.Errorf("values for Parameter %q must be either 0 or 1", n.Name)
+		}
+	case ParamEmitSide:
+		v, isName := n.Value.(MaterialNameNode)
+		if !isName {
+			return fmt.Errorf("values for Parameter %q must be one of front, back or both", n.Name)
+		}
+		if _, err := EmissionSideFromName(v); err != nil {
+			return err
+		}
 	case ParamIntIOR, ParamExtIOR:
 		if v, isMat := n.Value.(MaterialNameNode); isMat {
 			_, err := IOR(v)
@@ -236,6 +322,36 @@ func (n MixNode) Validate() error {
 	return nil
 }
 
+func (n LayeredNode) Validate() error {
+	var err error
+	for argIndex, arg := range [2]ExprNode{n.Coat, n.Base} {
+		if arg == nil {
+			return fmt.Errorf("missing expression argument %d for %q", argIndex, "layered")
+		}
+		err = arg.Validate()
+		if err != nil {
+			return fmt.Errorf("layered argument %d: %v", argIndex, err)
+		}
+	}
+
+	switch t := n.Coat.(type) {
+	case BxdfNode:
+		if t.Type != BxdfDielectric && t.Type != BxdfRoughDielectric {
+			return fmt.Errorf("Layered: coat must be a dielectric or roughDielectric bxdf; got %q", t.Type)
+		}
+	case MaterialRefNode:
+		// Checked by the compiler once the reference is resolved
+	default:
+		return fmt.Errorf("Layered: coat must be a dielectric or roughDielectric bxdf")
+	}
+
+	if n.Thickness < 0 {
+		return fmt.Errorf("Layered: coat thickness must be >= 0")
+	}
+
+	return nil
+}
+
 func (n BxdfNode) Validate() error {
 	if n.Type == bxdfInvalid {
 		return fmt.Errorf("invalid BXDF type")
@@ -243,10 +359,12 @@ func (n BxdfNode) Validate() error {
 
 	// Validate list of allowed Parameter names
 	var err error
+	seen := make(map[string]struct{}, len(n.Parameters))
 	for _, Param := range n.Parameters {
 		if _, isAllowed := bxdfAllowedParameters[n.Type][Param.Name]; !isAllowed {
 			return fmt.Errorf("bxdf type %q does not support Parameter %q", n.Type, Param.Name)
 		}
+		seen[Param.Name] = struct{}{}
 
 		// Validate Parameter
 		if err = Param.Validate(); err != nil {
@@ -254,5 +372,21 @@ func (n BxdfNode) Validate() error {
 		}
 	}
 
+	// Temperature defines the emitted color so it cannot be combined with radiance
+	_, hasRadiance := seen[ParamRadiance]
+	_, hasTemperature := seen[ParamTemperature]
+	if hasRadiance && hasTemperature {
+		return fmt.Errorf("parameters %q and %q are mutually exclusive", ParamRadiance, ParamTemperature)
+	}
+
+	// Metal presets define both the real and imaginary IOR parts
+	if _, hasMetal := seen[ParamMetal]; hasMetal {
+		for _, name := range []string{ParamEta, ParamK} {
+			if _, hasParam := seen[name]; hasParam {
+				return fmt.Errorf("parameters %q and %q are mutually exclusive", ParamMetal, name)
+			}
+		}
+	}
+
 	return nil
 }
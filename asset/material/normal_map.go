@@ -0,0 +1,42 @@
+package material
+
+import "fmt"
+
+// NormalMapConvention selects how the green channel of a normal map is
+// interpreted. Tools disagree on the direction of the tangent-space Y axis;
+// sampling a map with the wrong convention inverts its bumps along Y.
+type NormalMapConvention int32
+
+// Supported normal map conventions.
+const (
+	// The green channel points up (+Y). Used by OpenGL, Blender and Maya.
+	NormalMapOpenGL NormalMapConvention = iota
+
+	// The green channel points down (-Y). Used by DirectX, 3ds Max and
+	// Unreal Engine.
+	NormalMapDirectX
+)
+
+// Implements Stringer.
+func (c NormalMapConvention) String() string {
+	switch c {
+	case NormalMapOpenGL:
+		return "opengl"
+	case NormalMapDirectX:
+		return "directx"
+	}
+
+	return "invalid"
+}
+
+// Lookup a normal map convention by its name.
+func NormalMapConventionFromName(name string) (NormalMapConvention, error) {
+	switch name {
+	case "opengl":
+		return NormalMapOpenGL, nil
+	case "directx":
+		return NormalMapDirectX, nil
+	}
+
+	return 0, fmt.Errorf("unsupported normal map convention %q; supported conventions: opengl, directx", name)
+}
@@ -12,6 +12,7 @@ const (
 	OpBumpMap
 	OpNormalMap
 	OpDisperse
+	OpLayered
 	//
 	lastOpEntry
 )
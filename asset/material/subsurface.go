@@ -0,0 +1,41 @@
+package material
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Calculate the shape parameter (d) of the normalized Burley diffusion profile
+// for each RGB channel given the surface albedo and the mean free path. The
+// scaling factor uses the searchlight configuration fit from Christensen and
+// Burley, "Approximate Reflectance Profiles for Efficient Subsurface
+// Scattering", 2015.
+func BurleyScatterDistance(albedo types.Vec3, meanFreePath float32) types.Vec3 {
+	var out types.Vec3
+	for c := 0; c < 3; c++ {
+		a := albedo[c]
+		s := 1.9 - a + 3.5*(a-0.8)*(a-0.8)
+		out[c] = meanFreePath / s
+	}
+	return out
+}
+
+// Calculate the fraction of the light entering a subsurface scattering medium
+// that diffuses further than dist from its entry point. This is the
+// complement of the cumulative distribution of the normalized Burley
+// diffusion profile and is used for attenuating light that travels through
+// the medium before exiting it.
+func SubsurfaceTransmission(albedo types.Vec3, meanFreePath, dist float32) types.Vec3 {
+	d := BurleyScatterDistance(albedo, meanFreePath)
+
+	var out types.Vec3
+	for c := 0; c < 3; c++ {
+		if d[c] <= 0 {
+			continue
+		}
+		r := float64(dist / d[c])
+		out[c] = float32(0.25*math.Exp(-r) + 0.75*math.Exp(-r/3.0))
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package material
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSubsurfaceTransmission(t *testing.T) {
+	albedo := types.Vec3{0.8, 0.5, 0.2}
+
+	profile := func(r float64) float32 {
+		return float32(0.25*math.Exp(-r) + 0.75*math.Exp(-r/3.0))
+	}
+
+	type spec struct {
+		meanFreePath float32
+		dist         float32
+		exp          types.Vec3
+	}
+	specs := []spec{
+		// All light reaches the entry point
+		spec{1, 0, types.Vec3{1, 1, 1}},
+		// The profile scaling factors for the albedo are 1.1, 1.715 and 2.96
+		spec{1.1, 1, types.Vec3{profile(1.1 / 1.1), profile(1.715 / 1.1), profile(2.96 / 1.1)}},
+	}
+	for index, s := range specs {
+		if got := SubsurfaceTransmission(albedo, s.meanFreePath, s.dist); !types.ApproxEqual(got, s.exp, 1e-5) {
+			t.Errorf("[spec %d] expected transmission to be %v; got %v", index, s.exp, got)
+		}
+	}
+
+	// Transmission decreases with distance and increases with the mean free path
+	near := SubsurfaceTransmission(albedo, 1, 0.5)
+	far := SubsurfaceTransmission(albedo, 1, 2)
+	longer := SubsurfaceTransmission(albedo, 2, 2)
+	for c := 0; c < 3; c++ {
+		if far[c] >= near[c] || longer[c] <= far[c] {
+			t.Errorf("expected transmission to decrease with distance and increase with the mean free path; got %v, %v, %v", near, far, longer)
+		}
+	}
+}
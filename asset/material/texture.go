@@ -5,4 +5,7 @@ import "regexp"
 var (
 	// supported image extensions regex
 	supportedImageRegex = regexp.MustCompile(`(?i)\.(?:jpg|jpeg|gif|png|tga|tiff|bmp|pnm|hdr|exr|webp)$`)
+
+	// procedural texture regex; see texture.ParseProcedural for the full syntax
+	proceduralTextureRegex = regexp.MustCompile(`^\s*(?:checker|noise)\s*\(.*\)\s*$`)
 )
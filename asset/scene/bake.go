@@ -0,0 +1,323 @@
+package scene
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A texel of a bake map. It links the texel center to a surface point via
+// a primitive index and a set of barycentric coordinates.
+type BakeTexel struct {
+	// The primitive covering this texel or -1 if the texel is not covered.
+	Primitive int32
+
+	// The barycentric coordinates of the texel center in the primitive.
+	Barycentric types.Vec3
+}
+
+// A bake map contains the UV-to-surface mapping for all texels of an output
+// texture. Texel (x, y) maps to the uv coordinates ((x+0.5)/W, (y+0.5)/H)
+// which matches the addressing used by the texture samplers.
+type BakeMap struct {
+	Width  uint32
+	Height uint32
+	Texels []BakeTexel
+}
+
+// Options for baking ambient occlusion.
+type AOBakeOptions struct {
+	// Output texture dimensions.
+	Width  uint32
+	Height uint32
+
+	// The number of occlusion rays to trace for each texel.
+	Samples uint32
+
+	// The max distance of an occluder from the surface. If set to 0, all
+	// occluders contribute to the AO term.
+	MaxDistance float32
+
+	// The number of texels to pad around each UV island.
+	Padding uint32
+
+	// A seed for the random number generator.
+	Seed int64
+
+	// The precision for occlusion ray intersection tests.
+	Precision IntersectionPrecision
+
+	// The max number of CPU workers used for baking. If 0, one worker per
+	// CPU is used.
+	Workers int
+}
+
+// Rasterize the primitives of a mesh into UV space. The returned bake map
+// links each covered texel to the primitive that covers its center.
+func RasterizeUV(sc *Scene, meshIndex uint32, width, height uint32) (*BakeMap, error) {
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("scene: invalid bake map dimensions %dx%d", width, height)
+	}
+
+	firstPrim, numPrims, err := meshPrimitives(sc, meshIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	bm := &BakeMap{
+		Width:  width,
+		Height: height,
+		Texels: make([]BakeTexel, width*height),
+	}
+	for index := range bm.Texels {
+		bm.Texels[index].Primitive = -1
+	}
+
+	scale := types.Vec2{float32(width), float32(height)}
+	for primIndex := firstPrim; primIndex < firstPrim+numPrims; primIndex++ {
+		var uv [3]types.Vec2
+		for i := 0; i < 3; i++ {
+			uv[i] = types.Vec2{
+				sc.UvList[3*primIndex+uint32(i)][0] * scale[0],
+				sc.UvList[3*primIndex+uint32(i)][1] * scale[1],
+			}
+		}
+
+		area := edgeFunction(uv[0], uv[1], uv[2])
+		if area == 0 {
+			continue
+		}
+
+		// Clip primitive bounds to the bake map
+		minX := clampTexel(math.Floor(float64(minf(uv[0][0], uv[1][0], uv[2][0]))), width)
+		maxX := clampTexel(math.Ceil(float64(maxf(uv[0][0], uv[1][0], uv[2][0]))), width)
+		minY := clampTexel(math.Floor(float64(minf(uv[0][1], uv[1][1], uv[2][1]))), height)
+		maxY := clampTexel(math.Ceil(float64(maxf(uv[0][1], uv[1][1], uv[2][1]))), height)
+
+		for y := minY; y < maxY; y++ {
+			for x := minX; x < maxX; x++ {
+				p := types.Vec2{float32(x) + 0.5, float32(y) + 0.5}
+				w0 := edgeFunction(uv[1], uv[2], p) / area
+				w1 := edgeFunction(uv[2], uv[0], p) / area
+				w2 := edgeFunction(uv[0], uv[1], p) / area
+				if w0 < 0 || w1 < 0 || w2 < 0 {
+					continue
+				}
+
+				bm.Texels[y*width+x] = BakeTexel{
+					Primitive:   int32(primIndex),
+					Barycentric: types.Vec3{w0, w1, w2},
+				}
+			}
+		}
+	}
+
+	return bm, nil
+}
+
+// Bake ambient occlusion for a mesh instance into a grayscale texture. Texels
+// not covered by any primitive are filled by dilating the surrounding UV
+// islands by opts.Padding texels; any remaining texels are set to black.
+//
+// Occlusion rays are traced on the CPU against the full scene geometry.
+func (sc *Scene) BakeAO(instanceIndex uint32, opts AOBakeOptions) (*image.Gray, error) {
+	if int(instanceIndex) >= len(sc.MeshInstanceList) {
+		return nil, fmt.Errorf("scene: unknown mesh instance %d", instanceIndex)
+	}
+	if opts.Samples == 0 {
+		return nil, fmt.Errorf("scene: AO bake requires at least one sample per texel")
+	}
+	numWorkers, err := resolveWorkers(opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+
+	mi := &sc.MeshInstanceList[instanceIndex]
+	bm, err := RasterizeUV(sc, mi.MeshIndex, opts.Width, opts.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDist := opts.MaxDistance
+	if maxDist <= 0 {
+		maxDist = math.MaxFloat32
+	}
+
+	values := make([]float32, len(bm.Texels))
+	covered := make([]bool, len(bm.Texels))
+	if numWorkers > int(opts.Height) {
+		numWorkers = int(opts.Height)
+	}
+
+	// Process texel rows in parallel. Each worker owns its scratch buffers
+	// so the inner loop does not allocate.
+	workers := make([]*aoBakeWorker, numWorkers)
+	for workerIndex := range workers {
+		workers[workerIndex] = newAOBakeWorker(sc, mi, opts.Samples, maxDist)
+		workers[workerIndex].scratch.Precision = opts.Precision
+	}
+	parallelRows(int(opts.Height), numWorkers, func(workerIndex, row int) {
+		w := workers[workerIndex]
+
+		// Seed per row so the output does not depend on the number of
+		// workers.
+		w.rng.Seed(opts.Seed + int64(row))
+		for index := row * int(opts.Width); index < (row+1)*int(opts.Width); index++ {
+			if bm.Texels[index].Primitive < 0 {
+				continue
+			}
+			values[index] = w.bakeTexel(bm.Texels[index])
+			covered[index] = true
+		}
+	})
+
+	dilate(values, covered, int(opts.Width), int(opts.Height), int(opts.Padding))
+
+	im := image.NewGray(image.Rect(0, 0, int(opts.Width), int(opts.Height)))
+	for index, v := range values {
+		im.SetGray(index%int(opts.Width), index/int(opts.Width), color.Gray{Y: uint8(v*255 + 0.5)})
+	}
+
+	return im, nil
+}
+
+// A worker for baking AO. It owns the scratch buffers that are reused for
+// all texels processed by the worker.
+type aoBakeWorker struct {
+	sc          *Scene
+	worldToMesh types.Mat4
+	meshToWorld types.Mat4
+	maxDist     float32
+
+	rng     *rand.Rand
+	scratch *RayScratch
+
+	// Hemisphere samples for the texel being processed.
+	samples []types.Vec2
+}
+
+func newAOBakeWorker(sc *Scene, mi *MeshInstance, numSamples uint32, maxDist float32) *aoBakeWorker {
+	return &aoBakeWorker{
+		sc:          sc,
+		worldToMesh: mi.Transform,
+		meshToWorld: mi.Transform.Inv(),
+		maxDist:     maxDist,
+		rng:         rand.New(rand.NewSource(0)),
+		scratch:     NewRayScratch(),
+		samples:     make([]types.Vec2, numSamples),
+	}
+}
+
+// Calculate the ambient occlusion term for a covered texel.
+func (w *aoBakeWorker) bakeTexel(texel BakeTexel) float32 {
+	point, normal := w.sc.bakeSurfacePoint(texel, w.worldToMesh, w.meshToWorld)
+	origin := point.Add(normal.Mul(intersectionEpsilon * 10))
+	tangent, bitangent := types.BuildOrthonormalBasis(normal)
+
+	for index := range w.samples {
+		w.samples[index] = types.Vec2{w.rng.Float32(), w.rng.Float32()}
+	}
+
+	var occluded int
+	for _, sample := range w.samples {
+		// Cosine weighted hemisphere sample
+		r := float32(math.Sqrt(float64(sample[0])))
+		phi := 2 * math.Pi * float64(sample[1])
+		x, y := r*float32(math.Cos(phi)), r*float32(math.Sin(phi))
+		z := float32(math.Sqrt(math.Max(0, float64(1-x*x-y*y))))
+		dir := tangent.Mul(x).Add(bitangent.Mul(y)).Add(normal.Mul(z))
+
+		if w.sc.OccludedWithScratch(w.scratch, origin, dir, w.maxDist) {
+			occluded++
+		}
+	}
+
+	return 1 - float32(occluded)/float32(len(w.samples))
+}
+
+// Get the world-space position and normal of the surface point that maps to
+// a bake map texel. The worldToMesh transform is the inverse of meshToWorld.
+func (sc *Scene) bakeSurfacePoint(texel BakeTexel, worldToMesh, meshToWorld types.Mat4) (types.Vec3, types.Vec3) {
+	offset := 3 * uint32(texel.Primitive)
+	var point, normal types.Vec3
+	for i := uint32(0); i < 3; i++ {
+		point = point.Add(sc.VertexList[offset+i].Vec3().Mul(texel.Barycentric[i]))
+		normal = normal.Add(sc.NormalList[offset+i].Vec3().Mul(texel.Barycentric[i]))
+	}
+
+	if normal.Len() == 0 {
+		v0 := sc.VertexList[offset].Vec3()
+		normal = sc.VertexList[offset+1].Vec3().Sub(v0).Cross(sc.VertexList[offset+2].Vec3().Sub(v0))
+	}
+
+	// Normals are transformed by the transpose of the world-to-mesh matrix
+	var worldNormal types.Vec3
+	for axis := 0; axis < 3; axis++ {
+		worldNormal[axis] = worldToMesh.Col(axis).Vec3().Dot(normal)
+	}
+
+	return meshToWorld.Mul4x1(point.Vec4(1)).Vec3(), worldNormal.Normalize()
+}
+
+// Grow the covered regions of a texture by the requested number of texels.
+// Each uncovered texel adjacent to a covered one is set to the average value
+// of its covered neighbors.
+func dilate(values []float32, covered []bool, width, height, passes int) {
+	next := make([]bool, len(covered))
+	for pass := 0; pass < passes; pass++ {
+		copy(next, covered)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if covered[y*width+x] {
+					continue
+				}
+
+				var sum float32
+				var count int
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						if nx < 0 || ny < 0 || nx >= width || ny >= height || !covered[ny*width+nx] {
+							continue
+						}
+						sum += values[ny*width+nx]
+						count++
+					}
+				}
+
+				if count != 0 {
+					values[y*width+x] = sum / float32(count)
+					next[y*width+x] = true
+				}
+			}
+		}
+		copy(covered, next)
+	}
+}
+
+// Evaluate the 2D edge function for point p against the edge (a, b).
+func edgeFunction(a, b, p types.Vec2) float32 {
+	return (p[0]-a[0])*(b[1]-a[1]) - (p[1]-a[1])*(b[0]-a[0])
+}
+
+func clampTexel(v float64, limit uint32) uint32 {
+	if v < 0 {
+		return 0
+	}
+	if v > float64(limit) {
+		return limit
+	}
+	return uint32(v)
+}
+
+func minf(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func maxf(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
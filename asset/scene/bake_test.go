@@ -0,0 +1,160 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestBakeAO(t *testing.T) {
+	sc := makeCreviceTestScene()
+
+	opts := AOBakeOptions{
+		Width:       32,
+		Height:      16,
+		Samples:     256,
+		MaxDistance: 0.5,
+	}
+	im, err := sc.BakeAO(0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The floor occupies the left half of the texture with u increasing
+	// away from the crease; the wall occupies the right half with u
+	// increasing with the wall height.
+	type spec struct {
+		creaseX, farX int
+	}
+	specs := []spec{
+		spec{2, 13},  // floor
+		spec{18, 29}, // wall
+	}
+	for index, s := range specs {
+		for y := 3; y < 13; y++ {
+			crease := im.GrayAt(s.creaseX, y).Y
+			far := im.GrayAt(s.farX, y).Y
+			if crease >= far {
+				t.Fatalf("[spec %d] expected texel (%d, %d) near the crease (%d) to be darker than texel (%d, %d) away from it (%d)", index, s.creaseX, y, crease, s.farX, y, far)
+			}
+			if far != 255 {
+				t.Fatalf("[spec %d] expected texel (%d, %d) to be unoccluded; got %d", index, s.farX, y, far)
+			}
+		}
+	}
+
+	// Texels between the UV islands should only be filled when padding is enabled
+	if v := im.GrayAt(15, 8).Y; v != 0 {
+		t.Fatalf("expected uncovered texel to be black; got %d", v)
+	}
+
+	opts.Padding = 1
+	im, err = sc.BakeAO(0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := im.GrayAt(14, 8).Y; v != 255 {
+		t.Fatalf("expected padding texel to be set to the value of its neighbors; got %d", v)
+	}
+	if v := im.GrayAt(0, 8).Y; v != 0 {
+		t.Fatalf("expected texel outside the padding area to be black; got %d", v)
+	}
+
+	// Unknown instances should be reported as errors
+	if _, err = sc.BakeAO(1, opts); err == nil {
+		t.Fatal("expected to get an error for an unknown mesh instance")
+	}
+}
+
+func TestBakeAOAllocations(t *testing.T) {
+	sc := makeCreviceTestScene()
+	bm, err := RasterizeUV(sc, 0, 32, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newAOBakeWorker(sc, &sc.MeshInstanceList[0], 64, 0.5)
+	texel := bm.Texels[8*32+2]
+	if texel.Primitive < 0 {
+		t.Fatal("expected test texel to be covered")
+	}
+
+	// Warm up the worker scratch buffers
+	w.bakeTexel(texel)
+
+	if allocs := testing.AllocsPerRun(100, func() { w.bakeTexel(texel) }); allocs != 0 {
+		t.Fatalf("expected baking a texel to perform 0 allocations; got %f", allocs)
+	}
+}
+
+func TestOccluded(t *testing.T) {
+	sc := makeCreviceTestScene()
+
+	type spec struct {
+		origin, dir types.Vec3
+		maxDist     float32
+		expHit      bool
+	}
+	specs := []spec{
+		spec{types.Vec3{0.5, 1, 0.5}, types.Vec3{0, -1, 0}, 2, true},
+		spec{types.Vec3{0.5, 1, 0.5}, types.Vec3{0, -1, 0}, 0.5, false},
+		spec{types.Vec3{0.5, 1, 0.5}, types.Vec3{0, 1, 0}, 2, false},
+		spec{types.Vec3{0.5, 0.5, 0.5}, types.Vec3{-1, 0, 0}, 2, true},
+		spec{types.Vec3{2, 0.5, 0.5}, types.Vec3{1, 0, 0}, 2, false},
+	}
+	for index, s := range specs {
+		if hit := sc.Occluded(s.origin, s.dir, s.maxDist); hit != s.expHit {
+			t.Fatalf("[spec %d] expected Occluded() to return %t; got %t", index, s.expHit, hit)
+		}
+	}
+}
+
+// Build a scene with a single instance of an L-shaped mesh consisting of a
+// unit floor quad on the XZ plane and a unit wall quad on the YZ plane. The
+// floor is mapped to the [0.05, 0.45] and the wall to the [0.55, 0.95] U range.
+func makeCreviceTestScene() *Scene {
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()}},
+		BvhNodeList:      make([]BvhNode, 2),
+	}
+
+	addQuad := func(corners [4]types.Vec3, normal types.Vec3, uvs [4]types.Vec2) {
+		for _, i := range []int{0, 1, 2, 0, 2, 3} {
+			sc.VertexList = append(sc.VertexList, corners[i].Vec4(1))
+			sc.NormalList = append(sc.NormalList, normal.Vec4(0))
+			sc.UvList = append(sc.UvList, uvs[i])
+			if len(sc.VertexList)%3 == 0 {
+				sc.MaterialIndex = append(sc.MaterialIndex, 0)
+			}
+		}
+	}
+	addQuad(
+		[4]types.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}},
+		types.Vec3{0, 1, 0},
+		[4]types.Vec2{{0.05, 0.1}, {0.45, 0.1}, {0.45, 0.9}, {0.05, 0.9}},
+	)
+	addQuad(
+		[4]types.Vec3{{0, 0, 0}, {0, 1, 0}, {0, 1, 1}, {0, 0, 1}},
+		types.Vec3{1, 0, 0},
+		[4]types.Vec2{{0.55, 0.1}, {0.95, 0.1}, {0.95, 0.9}, {0.55, 0.9}},
+	)
+
+	bbox := [2]types.Vec3{{0, 0, 0}, {1, 1, 1}}
+	sc.BvhNodeList[0].SetBBox(bbox)
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox(bbox)
+	sc.BvhNodeList[1].SetPrimitives(0, uint32(len(sc.VertexList)/3))
+
+	return sc
+}
+
+func BenchmarkBakeAO(b *testing.B) {
+	sc := makeCreviceTestScene()
+	opts := AOBakeOptions{Width: 64, Height: 64, Samples: 16, MaxDistance: 0.5}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := sc.BakeAO(0, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
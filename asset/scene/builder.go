@@ -0,0 +1,356 @@
+package scene
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The max number of triangles in the leaves of the mesh BVH trees generated
+// by SceneBuilder.
+const builderLeafPrimitives = 4
+
+// A triangle of a SceneBuilder mesh. Triangles without vertex normals are
+// shaded using their geometric normal.
+type BuilderTriangle struct {
+	Vertices [3]types.Vec3
+	Normals  [3]types.Vec3
+	UVs      [3]types.Vec2
+}
+
+// A SceneBuilder assembles a Scene from named textures, materials, meshes and
+// mesh instances. Items reference each other by name and references are
+// resolved when Build is invoked so items can be added in any order. Errors
+// are also reported by Build:
+//
+//	sc, err := NewSceneBuilder().
+//		AddTexture("checker", meta, data).
+//		AddMaterial("floor", node, "checker").
+//		AddMesh("plane", "floor", triangles).
+//		AddInstance("plane", types.Ident4()).
+//		Build()
+type SceneBuilder struct {
+	textures  []builderTexture
+	materials []builderMaterial
+	meshes    []builderMesh
+	instances []builderInstance
+
+	// Triangles whose area does not exceed this value are dropped.
+	degenerateEpsilon float32
+	removedTriangles  int
+
+	names map[string]string
+	err   error
+}
+
+type builderTexture struct {
+	name string
+	meta TextureMetadata
+	data []byte
+}
+
+type builderMaterial struct {
+	name    string
+	node    MaterialNode
+	texture string
+}
+
+type builderMesh struct {
+	name      string
+	material  string
+	triangles []BuilderTriangle
+}
+
+type builderInstance struct {
+	mesh      string
+	transform types.Mat4
+}
+
+// Create a new scene builder.
+func NewSceneBuilder() *SceneBuilder {
+	return &SceneBuilder{
+		degenerateEpsilon: DefaultDegenerateAreaEpsilon,
+		names:             make(map[string]string),
+	}
+}
+
+// Set the area at or below which mesh triangles are considered degenerate
+// and dropped by Build. Defaults to DefaultDegenerateAreaEpsilon.
+func (b *SceneBuilder) SetDegenerateAreaEpsilon(epsilon float32) *SceneBuilder {
+	if epsilon < 0 {
+		return b.fail(fmt.Errorf("scene: degenerate triangle area epsilon must be >= 0; got %f", epsilon))
+	}
+	b.degenerateEpsilon = epsilon
+	return b
+}
+
+// Get the number of degenerate triangles that were dropped by the last call
+// to Build.
+func (b *SceneBuilder) RemovedTriangles() int {
+	return b.removedTriangles
+}
+
+// Add a texture with the given metadata and data. The data offset of the
+// metadata is ignored as the builder packs all texture data into a single
+// block.
+func (b *SceneBuilder) AddTexture(name string, meta TextureMetadata, data []byte) *SceneBuilder {
+	if !b.reserveName("texture", name) {
+		return b
+	}
+
+	if size := texelSize(meta.Format) * meta.Width * meta.Height; uint32(len(data)) < size {
+		return b.fail(fmt.Errorf("scene: texture %q requires %d bytes of data; got %d", name, size, len(data)))
+	}
+	b.textures = append(b.textures, builderTexture{name: name, meta: meta, data: data})
+	return b
+}
+
+// Add a material that consists of a single bxdf node. If texture is not
+// empty, the node samples its reflectance, specularity or radiance from the
+// named texture.
+func (b *SceneBuilder) AddMaterial(name string, node MaterialNode, texture string) *SceneBuilder {
+	if b.reserveName("material", name) {
+		b.materials = append(b.materials, builderMaterial{name: name, node: node, texture: texture})
+	}
+	return b
+}
+
+// Add a mesh whose triangles use the named material.
+func (b *SceneBuilder) AddMesh(name, material string, triangles []BuilderTriangle) *SceneBuilder {
+	if !b.reserveName("mesh", name) {
+		return b
+	}
+
+	if len(triangles) == 0 {
+		return b.fail(fmt.Errorf("scene: mesh %q does not contain any triangles", name))
+	}
+	b.meshes = append(b.meshes, builderMesh{
+		name:      name,
+		material:  material,
+		triangles: append([]BuilderTriangle(nil), triangles...),
+	})
+	return b
+}
+
+// Add an instance of the named mesh positioned using a mesh-to-world
+// transformation matrix.
+func (b *SceneBuilder) AddInstance(mesh string, transform types.Mat4) *SceneBuilder {
+	b.instances = append(b.instances, builderInstance{mesh: mesh, transform: transform})
+	return b
+}
+
+// Resolve the references between the added items and build the scene and its
+// BVH tree. The built scene is validated before it is returned.
+func (b *SceneBuilder) Build() (*Scene, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.instances) == 0 {
+		return nil, fmt.Errorf("scene: scene builder requires at least one mesh instance")
+	}
+
+	sc := &Scene{
+		SceneDiffuseMatIndex:  -1,
+		SceneEmissiveMatIndex: -1,
+	}
+
+	// Pack texture data keeping each texture aligned to 4 bytes
+	texIndices := make(map[string]int32, len(b.textures))
+	for _, tex := range b.textures {
+		meta := tex.meta
+		meta.DataOffset = uint32(len(sc.TextureData))
+		sc.TextureData = append(sc.TextureData, tex.data...)
+		for len(sc.TextureData)%4 != 0 {
+			sc.TextureData = append(sc.TextureData, 0)
+		}
+		texIndices[tex.name] = int32(len(sc.TextureMetadata))
+		sc.TextureMetadata = append(sc.TextureMetadata, meta)
+	}
+
+	matIndices := make(map[string]uint32, len(b.materials))
+	for _, mat := range b.materials {
+		node := mat.node
+		if mat.texture != "" {
+			texIndex, exists := texIndices[mat.texture]
+			if !exists {
+				return nil, fmt.Errorf("scene: material %q references unknown texture %q", mat.name, mat.texture)
+			}
+			node.Union1[3] = texIndex
+		}
+		matIndices[mat.name] = uint32(len(sc.MaterialNodeList))
+		sc.MaterialNodeList = append(sc.MaterialNodeList, node)
+	}
+
+	// Reserve the first node for the top-level BVH and build a BVH for
+	// each mesh. Mesh primitives are stored in BVH leaf order.
+	sc.BvhNodeList = make([]BvhNode, 1)
+	b.removedTriangles = 0
+	meshIndices := make(map[string]uint32, len(b.meshes))
+	meshRoots := make([]uint32, len(b.meshes))
+	meshPrims := make([][2]uint32, len(b.meshes))
+	for meshIndex, mesh := range b.meshes {
+		matIndex, exists := matIndices[mesh.material]
+		if !exists {
+			return nil, fmt.Errorf("scene: mesh %q references unknown material %q", mesh.name, mesh.material)
+		}
+
+		triangles := make([]BuilderTriangle, 0, len(mesh.triangles))
+		for _, tri := range mesh.triangles {
+			if !IsDegenerateTriangle(tri.Vertices[0], tri.Vertices[1], tri.Vertices[2], b.degenerateEpsilon) {
+				triangles = append(triangles, tri)
+			}
+		}
+		if len(triangles) == 0 {
+			return nil, fmt.Errorf("scene: mesh %q does not contain any non-degenerate triangles", mesh.name)
+		}
+		b.removedTriangles += len(mesh.triangles) - len(triangles)
+
+		meshIndices[mesh.name] = uint32(meshIndex)
+		meshPrims[meshIndex] = [2]uint32{uint32(len(sc.VertexList) / 3), uint32(len(triangles))}
+		meshRoots[meshIndex] = sc.partitionTriangles(triangles, matIndex)
+	}
+
+	for _, inst := range b.instances {
+		meshIndex, exists := meshIndices[inst.mesh]
+		if !exists {
+			return nil, fmt.Errorf("scene: mesh instance references unknown mesh %q", inst.mesh)
+		}
+
+		// Instance transforms convert rays from world to mesh space
+		sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+			MeshIndex: meshIndex,
+			BvhRoot:   meshRoots[meshIndex],
+			Transform: inst.transform.Inv(),
+		})
+		sc.addInstanceEmissives(sc.MeshInstanceList[len(sc.MeshInstanceList)-1].Transform, meshPrims[meshIndex])
+	}
+
+	sc.RebuildTopLevel()
+
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Claim a unique item name. Returns false and records an error if the name
+// is empty or already in use.
+func (b *SceneBuilder) reserveName(kind, name string) bool {
+	if name == "" {
+		b.fail(fmt.Errorf("scene: %s name must not be empty", kind))
+		return false
+	}
+	if existing, exists := b.names[name]; exists {
+		b.fail(fmt.Errorf("scene: %s name %q is already used by a %s", kind, name, existing))
+		return false
+	}
+	b.names[name] = kind
+	return true
+}
+
+// Record the first builder error.
+func (b *SceneBuilder) fail(err error) *SceneBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// Recursively partition a list of triangles by splitting them at the median
+// center along the longest axis until each leaf contains at most
+// builderLeafPrimitives triangles. Leaf triangles are appended to the scene
+// primitive lists. Returns the index of the generated node.
+func (sc *Scene) partitionTriangles(triangles []BuilderTriangle, matIndex uint32) uint32 {
+	nodeIndex := uint32(len(sc.BvhNodeList))
+	sc.BvhNodeList = append(sc.BvhNodeList, BvhNode{})
+
+	bbox := emptyBBox()
+	centerBBox := emptyBBox()
+	centers := make([]types.Vec3, len(triangles))
+	for index, tri := range triangles {
+		for _, v := range tri.Vertices {
+			bbox[0] = types.MinVec3(bbox[0], v)
+			bbox[1] = types.MaxVec3(bbox[1], v)
+		}
+		centers[index] = tri.Vertices[0].Add(tri.Vertices[1]).Add(tri.Vertices[2]).Mul(1.0 / 3.0)
+		centerBBox[0] = types.MinVec3(centerBBox[0], centers[index])
+		centerBBox[1] = types.MaxVec3(centerBBox[1], centers[index])
+	}
+	sc.BvhNodeList[nodeIndex].SetBBox(bbox)
+
+	if len(triangles) <= builderLeafPrimitives {
+		sc.BvhNodeList[nodeIndex].SetPrimitives(uint32(len(sc.VertexList)/3), uint32(len(triangles)))
+		for _, tri := range triangles {
+			sc.appendTriangle(tri, matIndex)
+		}
+		return nodeIndex
+	}
+
+	// Split along the axis with the largest center extent
+	side := centerBBox[1].Sub(centerBBox[0])
+	axis := 0
+	if side[1] > side[axis] {
+		axis = 1
+	}
+	if side[2] > side[axis] {
+		axis = 2
+	}
+
+	order := make([]int, len(triangles))
+	for index := range order {
+		order[index] = index
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return centers[order[i]][axis] < centers[order[j]][axis]
+	})
+	sorted := make([]BuilderTriangle, len(triangles))
+	for index, triIndex := range order {
+		sorted[index] = triangles[triIndex]
+	}
+
+	mid := len(sorted) / 2
+	left := sc.partitionTriangles(sorted[:mid], matIndex)
+	right := sc.partitionTriangles(sorted[mid:], matIndex)
+	sc.BvhNodeList[nodeIndex].SetChildNodes(left, right)
+
+	return nodeIndex
+}
+
+// Append a triangle to the scene primitive lists. Missing vertex normals are
+// replaced by the triangle geometric normal.
+func (sc *Scene) appendTriangle(tri BuilderTriangle, matIndex uint32) {
+	geomNormal := tri.Vertices[1].Sub(tri.Vertices[0]).Cross(tri.Vertices[2].Sub(tri.Vertices[0]))
+	if geomNormal.Len() > 0 {
+		geomNormal = geomNormal.Normalize()
+	}
+
+	for i := 0; i < 3; i++ {
+		normal := tri.Normals[i]
+		if normal.Len() == 0 {
+			normal = geomNormal
+		}
+		sc.VertexList = append(sc.VertexList, tri.Vertices[i].Vec4(0))
+		sc.NormalList = append(sc.NormalList, normal.Vec4(0))
+		sc.UvList = append(sc.UvList, tri.UVs[i])
+	}
+	sc.MaterialIndex = append(sc.MaterialIndex, matIndex)
+}
+
+// Create an area light for each emissive primitive in the [first, first+count)
+// range for a mesh instance with the given world-to-mesh transform.
+func (sc *Scene) addInstanceEmissives(transform types.Mat4, prims [2]uint32) {
+	for primIndex := prims[0]; primIndex < prims[0]+prims[1]; primIndex++ {
+		emissiveNode := sc.findEmissiveNode(sc.MaterialIndex[primIndex])
+		if emissiveNode == -1 {
+			continue
+		}
+		sc.EmissivePrimitives = append(sc.EmissivePrimitives, EmissivePrimitive{
+			Transform:         transform,
+			Area:              TriangleArea(sc, primIndex),
+			PrimitiveIndex:    primIndex,
+			MaterialNodeIndex: uint32(emissiveNode),
+			Type:              AreaLight,
+		})
+	}
+}
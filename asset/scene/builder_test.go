@@ -0,0 +1,136 @@
+package scene
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/texure"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSceneBuilder(t *testing.T) {
+	// A 2x2 red/green checker texture mapped to a quad on the XY plane
+	checkerMeta := TextureMetadata{Format: texture.Rgba8, Width: 2, Height: 2}
+	checkerData := []byte{
+		255, 0, 0, 255, 0, 255, 0, 255,
+		0, 255, 0, 255, 255, 0, 0, 255,
+	}
+	quad := []BuilderTriangle{
+		{
+			Vertices: [3]types.Vec3{{-1, -1, 0}, {1, -1, 0}, {1, 1, 0}},
+			UVs:      [3]types.Vec2{{0, 0}, {1, 0}, {1, 1}},
+		},
+		{
+			Vertices: [3]types.Vec3{{-1, -1, 0}, {1, 1, 0}, {-1, 1, 0}},
+			UVs:      [3]types.Vec2{{0, 0}, {1, 1}, {0, 1}},
+		},
+	}
+	diffuse := MaterialNode{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{1, 1, 1, 0}}
+
+	offset := types.Vec3{0, 0, 0.5}
+	sc, err := NewSceneBuilder().
+		AddMaterial("checker", diffuse, "checker-tex").
+		AddTexture("checker-tex", checkerMeta, checkerData).
+		AddMesh("quad", "checker", quad).
+		AddInstance("quad", types.Translate4(offset)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sc.MaterialNodeList) != 1 || sc.MaterialNodeList[0].Union1[3] != 0 {
+		t.Fatalf("expected the material to reference texture 0; got %+v", sc.MaterialNodeList)
+	}
+	if len(sc.VertexList) != 6 || len(sc.MaterialIndex) != 2 || len(sc.MeshInstanceList) != 1 {
+		t.Fatalf("expected scene with 2 triangles and 1 mesh instance; got %d vertices and %d instances", len(sc.VertexList), len(sc.MeshInstanceList))
+	}
+	if normal := sc.NormalList[0].Vec3(); normal != (types.Vec3{0, 0, 1}) {
+		t.Fatalf("expected missing normals to be replaced by the geometric normal; got %v", normal)
+	}
+
+	// The uv coordinates of each vertex are copied to the scene
+	for index, uv := range sc.UvList {
+		if exp := quad[index/3].UVs[index%3]; uv != exp {
+			t.Fatalf("[vertex %d] expected uv %v; got %v", index, exp, uv)
+		}
+	}
+}
+
+func TestSceneBuilderErrors(t *testing.T) {
+	tri := []BuilderTriangle{{Vertices: [3]types.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}}}
+	diffuse := MaterialNode{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}}
+
+	type spec struct {
+		builder *SceneBuilder
+		expErr  string
+	}
+	specs := []spec{
+		spec{
+			NewSceneBuilder().AddMaterial("mat", diffuse, "").AddMesh("tri", "mat", tri),
+			"requires at least one mesh instance",
+		},
+		spec{
+			NewSceneBuilder().AddMaterial("tri", diffuse, "").AddMesh("tri", "tri", tri),
+			`mesh name "tri" is already used by a material`,
+		},
+		spec{
+			NewSceneBuilder().AddMaterial("mat", diffuse, "").AddMesh("tri", "mat", nil).AddInstance("tri", types.Ident4()),
+			`mesh "tri" does not contain any triangles`,
+		},
+		spec{
+			NewSceneBuilder().AddMaterial("mat", diffuse, "tex").AddMesh("tri", "mat", tri).AddInstance("tri", types.Ident4()),
+			`material "mat" references unknown texture "tex"`,
+		},
+		spec{
+			NewSceneBuilder().AddMesh("tri", "mat", tri).AddInstance("tri", types.Ident4()),
+			`mesh "tri" references unknown material "mat"`,
+		},
+		spec{
+			NewSceneBuilder().AddMaterial("mat", diffuse, "").AddMesh("tri", "mat", tri).AddInstance("box", types.Ident4()),
+			`references unknown mesh "box"`,
+		},
+		spec{
+			NewSceneBuilder().AddTexture("tex", TextureMetadata{Format: texture.Rgba8, Width: 2, Height: 2}, make([]byte, 8)),
+			`texture "tex" requires 16 bytes of data; got 8`,
+		},
+	}
+
+	for index, s := range specs {
+		_, err := s.builder.Build()
+		if err == nil || !strings.Contains(err.Error(), s.expErr) {
+			t.Fatalf("[spec %d] expected error containing %q; got %v", index, s.expErr, err)
+		}
+	}
+}
+
+func TestSceneBuilderDegenerateTriangles(t *testing.T) {
+	quad := []BuilderTriangle{
+		{Vertices: [3]types.Vec3{{-1, -1, 0}, {1, -1, 0}, {1, 1, 0}}},
+		// A degenerate triangle along the quad diagonal
+		{Vertices: [3]types.Vec3{{-1, -1, 0}, {0, 0, 0}, {1, 1, 0}}},
+		{Vertices: [3]types.Vec3{{-1, -1, 0}, {1, 1, 0}, {-1, 1, 0}}},
+	}
+	emissive := MaterialNode{Union1: [4]int32{int32(material.BxdfEmissive), -1, -1, -1}, Union2: types.Vec4{1, 1, 1, 0}, Union4: types.Vec3{0, 0, 1}}
+
+	b := NewSceneBuilder().
+		AddMaterial("light", emissive, "").
+		AddMesh("quad", "light", quad).
+		AddInstance("quad", types.Ident4())
+	sc, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.RemovedTriangles() != 1 {
+		t.Fatalf("expected 1 degenerate triangle to be removed; got %d", b.RemovedTriangles())
+	}
+	if len(sc.MaterialIndex) != 2 || len(sc.EmissivePrimitives) != 2 {
+		t.Fatalf("expected scene to contain 2 emissive triangles; got %d triangles and %d emissives", len(sc.MaterialIndex), len(sc.EmissivePrimitives))
+	}
+
+	degenerate := []BuilderTriangle{quad[1]}
+	if _, err = NewSceneBuilder().AddMaterial("light", emissive, "").AddMesh("line", "light", degenerate).AddInstance("line", types.Ident4()).Build(); err == nil {
+		t.Fatal("expected an error for a mesh without any non-degenerate triangles")
+	}
+}
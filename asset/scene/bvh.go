@@ -0,0 +1,144 @@
+package scene
+
+import (
+	"math"
+	"sort"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A mesh instance reference used while building the top-level BVH tree.
+type instanceVolume struct {
+	index  uint32
+	bbox   [2]types.Vec3
+	center types.Vec3
+}
+
+// Rebuild the top-level BVH tree that partitions the scene mesh instances
+// while re-using the existing bottom-level (mesh) BVH trees. This method
+// should be invoked after modifying the transformation matrices of mesh
+// instances without changing the mesh geometry. The world-space bounds of
+// instances that did not change since the last rebuild are reused.
+//
+// The top-level tree is always stored at the beginning of the BVH node list.
+// If the rebuilt tree contains a different number of nodes than the old one,
+// the bottom-level trees are shifted and their child node indices as well as
+// the instance BvhRoot indices are updated accordingly.
+//
+// Note that this method does not update the transformation matrices of any
+// emissive primitive copies associated with the mesh instances.
+func (sc *Scene) RebuildTopLevel() {
+	if len(sc.MeshInstanceList) == 0 {
+		return
+	}
+
+	// Count the nodes of the current top-level tree
+	oldTopLevelNodes := 0
+	nodeStack := []int32{0}
+	for len(nodeStack) != 0 {
+		node := &sc.BvhNodeList[nodeStack[len(nodeStack)-1]]
+		nodeStack = nodeStack[:len(nodeStack)-1]
+		oldTopLevelNodes++
+		if node.LData > 0 {
+			nodeStack = append(nodeStack, node.LData, node.RData)
+		}
+	}
+
+	// Get the world-space bbox for each mesh instance
+	volList := make([]instanceVolume, len(sc.MeshInstanceList))
+	for index := range sc.MeshInstanceList {
+		bbox := sc.instanceBBox(uint32(index))
+		volList[index] = instanceVolume{
+			index:  uint32(index),
+			bbox:   bbox,
+			center: bbox[0].Add(bbox[1]).Mul(0.5),
+		}
+	}
+
+	topLevelNodes := make([]BvhNode, 0, 2*len(volList)-1)
+	partitionInstances(&topLevelNodes, volList)
+
+	// Shift the bottom-level trees to fit the new top-level tree
+	bottomLevelNodes := sc.BvhNodeList[oldTopLevelNodes:]
+	if offset := int32(len(topLevelNodes) - oldTopLevelNodes); offset != 0 {
+		for index := range bottomLevelNodes {
+			bottomLevelNodes[index].OffsetChildNodes(offset)
+		}
+		for index := range sc.MeshInstanceList {
+			sc.MeshInstanceList[index].BvhRoot = uint32(int32(sc.MeshInstanceList[index].BvhRoot) + offset)
+		}
+	}
+
+	sc.BvhNodeList = append(topLevelNodes, bottomLevelNodes...)
+}
+
+// Recursively partition a list of mesh instances by splitting them at the
+// median center along the longest axis until each leaf contains a single
+// instance. Returns the index of the generated node.
+func partitionInstances(nodes *[]BvhNode, volList []instanceVolume) uint32 {
+	nodeIndex := uint32(len(*nodes))
+	*nodes = append(*nodes, BvhNode{})
+
+	// Calculate node bbox and the bbox of the instance centers
+	bbox := emptyBBox()
+	centerBBox := emptyBBox()
+	for _, vol := range volList {
+		bbox[0] = types.MinVec3(bbox[0], vol.bbox[0])
+		bbox[1] = types.MaxVec3(bbox[1], vol.bbox[1])
+		centerBBox[0] = types.MinVec3(centerBBox[0], vol.center)
+		centerBBox[1] = types.MaxVec3(centerBBox[1], vol.center)
+	}
+	(*nodes)[nodeIndex].SetBBox(bbox)
+
+	if len(volList) == 1 {
+		(*nodes)[nodeIndex].SetMeshIndex(volList[0].index)
+		return nodeIndex
+	}
+
+	// Split along the axis with the largest center extent
+	side := centerBBox[1].Sub(centerBBox[0])
+	axis := 0
+	if side[1] > side[axis] {
+		axis = 1
+	}
+	if side[2] > side[axis] {
+		axis = 2
+	}
+
+	sort.SliceStable(volList, func(i, j int) bool {
+		return volList[i].center[axis] < volList[j].center[axis]
+	})
+
+	mid := len(volList) / 2
+	left := partitionInstances(nodes, volList[:mid])
+	right := partitionInstances(nodes, volList[mid:])
+	(*nodes)[nodeIndex].SetChildNodes(left, right)
+
+	return nodeIndex
+}
+
+// Transform an axis-aligned bbox and return the axis-aligned bbox that
+// contains the transformed box.
+func transformBBox(transform types.Mat4, bbox [2]types.Vec3) [2]types.Vec3 {
+	out := emptyBBox()
+	for corner := 0; corner < 8; corner++ {
+		point := types.Vec3{
+			bbox[corner&1][0],
+			bbox[(corner>>1)&1][1],
+			bbox[(corner>>2)&1][2],
+		}
+		point = transform.Mul4x1(point.Vec4(1)).Vec3()
+		out[0] = types.MinVec3(out[0], point)
+		out[1] = types.MaxVec3(out[1], point)
+	}
+
+	return out
+}
+
+// Create an empty bbox that can be expanded using MinVec3/MaxVec3.
+func emptyBBox() [2]types.Vec3 {
+	return [2]types.Vec3{
+		{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32},
+		{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32},
+	}
+}
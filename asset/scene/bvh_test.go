@@ -0,0 +1,200 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRebuildTopLevel(t *testing.T) {
+	// Start with a top-level tree containing a single leaf followed by a
+	// bottom-level tree for a mesh with a unit bbox centered at the origin.
+	sc := &Scene{
+		BvhNodeList: make([]BvhNode, 4),
+	}
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox([2]types.Vec3{{-1, -1, -1}, {1, 1, 1}})
+	sc.BvhNodeList[1].SetChildNodes(2, 3)
+	sc.BvhNodeList[2].SetBBox([2]types.Vec3{{-1, -1, -1}, {0, 1, 1}})
+	sc.BvhNodeList[2].SetPrimitives(0, 1)
+	sc.BvhNodeList[3].SetBBox([2]types.Vec3{{0, -1, -1}, {1, 1, 1}})
+	sc.BvhNodeList[3].SetPrimitives(1, 1)
+
+	positions := []types.Vec3{
+		{-10, 0, 0},
+		{0, 0, 0},
+		{10, 0, 0},
+	}
+	for _, pos := range positions {
+		sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+			BvhRoot:   1,
+			Transform: types.Translate4(pos).Inv(),
+		})
+	}
+
+	sc.RebuildTopLevel()
+
+	// The top-level tree for 3 instances needs 5 nodes
+	if expLen := 5 + 3; len(sc.BvhNodeList) != expLen {
+		t.Fatalf("expected bvh node list to contain %d nodes; got %d", expLen, len(sc.BvhNodeList))
+	}
+	for index, mi := range sc.MeshInstanceList {
+		if mi.BvhRoot != 5 {
+			t.Fatalf("[instance %d] expected bvh root to be 5; got %d", index, mi.BvhRoot)
+		}
+	}
+	assertInstanceHits(t, sc, positions)
+
+	// Move instances and rebuild
+	positions = []types.Vec3{
+		{0, 20, 0},
+		{0, -20, 0},
+		{5, 5, 0},
+	}
+	for index, pos := range positions {
+		sc.MeshInstanceList[index].Transform = types.Translate4(pos).Inv()
+	}
+	sc.RebuildTopLevel()
+	assertInstanceHits(t, sc, positions)
+
+	// Rays through the old instance positions should not hit anything
+	if hits := traceInstanceHits(sc, types.Vec3{-10, 0, 10}); len(hits) != 0 {
+		t.Fatalf("expected ray through old instance position to miss; got hits %v", hits)
+	}
+}
+
+func assertInstanceHits(t *testing.T, sc *Scene, positions []types.Vec3) {
+	for index, pos := range positions {
+		// Ray parallel to the Z axis that passes through the instance center
+		hits := traceInstanceHits(sc, pos.Add(types.Vec3{0, 0, 10}))
+		if len(hits) != 1 || hits[0] != uint32(index) {
+			t.Fatalf("[instance %d] expected ray to hit instance %d; got hits %v", index, index, hits)
+		}
+	}
+}
+
+// Trace a ray parallel to the -Z axis through the two-level BVH and return
+// the indices of all instances with at least one bottom-level leaf hit.
+func traceInstanceHits(sc *Scene, origin types.Vec3) []uint32 {
+	dir := types.Vec3{0, 0, -1}
+	hits := make([]uint32, 0)
+
+	nodeStack := []int32{0}
+	for len(nodeStack) != 0 {
+		node := sc.BvhNodeList[nodeStack[len(nodeStack)-1]]
+		nodeStack = nodeStack[:len(nodeStack)-1]
+
+		if !rayHitsBBox(origin, dir, node.Min, node.Max) {
+			continue
+		}
+
+		if node.LData > 0 {
+			nodeStack = append(nodeStack, node.LData, node.RData)
+			continue
+		}
+
+		// Transform ray to mesh space and traverse bottom-level tree
+		mi := sc.MeshInstanceList[node.GetMeshIndex()]
+		localOrigin := mi.Transform.Mul4x1(origin.Vec4(1)).Vec3()
+		localDir := mi.Transform.Mul4x1(dir.Vec4(0)).Vec3()
+		meshStack := []int32{int32(mi.BvhRoot)}
+		for len(meshStack) != 0 {
+			meshNode := sc.BvhNodeList[meshStack[len(meshStack)-1]]
+			meshStack = meshStack[:len(meshStack)-1]
+
+			if !rayHitsBBox(localOrigin, localDir, meshNode.Min, meshNode.Max) {
+				continue
+			}
+
+			if meshNode.LData > 0 {
+				meshStack = append(meshStack, meshNode.LData, meshNode.RData)
+				continue
+			}
+
+			hits = append(hits, node.GetMeshIndex())
+			break
+		}
+	}
+
+	return hits
+}
+
+func rayHitsBBox(origin, dir, min, max types.Vec3) bool {
+	tMin, tMax := float32(0), float32(math.MaxFloat32)
+	for axis := 0; axis < 3; axis++ {
+		if dir[axis] == 0 {
+			if origin[axis] < min[axis] || origin[axis] > max[axis] {
+				return false
+			}
+			continue
+		}
+
+		t0 := (min[axis] - origin[axis]) / dir[axis]
+		t1 := (max[axis] - origin[axis]) / dir[axis]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+	}
+
+	return tMin <= tMax
+}
+
+func TestRebuildTopLevelInstanceBoundsCache(t *testing.T) {
+	sc := &Scene{
+		BvhNodeList: make([]BvhNode, 2),
+	}
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox([2]types.Vec3{{-1, -1, -1}, {1, 1, 1}})
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+
+	const numInstances = 16
+	for index := 0; index < numInstances; index++ {
+		sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+			BvhRoot:   1,
+			Transform: types.Translate4(types.Vec3{float32(index) * 5, 0, 0}).Inv(),
+		})
+	}
+
+	type spec struct {
+		update          func()
+		expRecalculated int
+		expBBox         [2]types.Vec3
+	}
+	specs := []spec{
+		// Initial build
+		spec{func() {}, numInstances, [2]types.Vec3{{14, -1, -1}, {16, 1, 1}}},
+		// Nothing changed
+		spec{func() {}, 0, [2]types.Vec3{{14, -1, -1}, {16, 1, 1}}},
+		// Move a single instance
+		spec{func() {
+			sc.MeshInstanceList[3].Transform = types.Translate4(types.Vec3{15, 10, 0}).Inv()
+		}, 1, [2]types.Vec3{{14, 9, -1}, {16, 11, 1}}},
+		// Force a recalculation
+		spec{func() {
+			sc.MeshInstanceList[7].MarkDirty()
+		}, 1, [2]types.Vec3{{14, 9, -1}, {16, 11, 1}}},
+		// Grow the shared mesh bounds
+		spec{func() {
+			sc.BvhNodeList[len(sc.BvhNodeList)-1].SetBBox([2]types.Vec3{{-2, -2, -2}, {2, 2, 2}})
+		}, numInstances, [2]types.Vec3{{13, 8, -2}, {17, 12, 2}}},
+	}
+
+	for index, s := range specs {
+		s.update()
+		before := sc.instanceBounds.recalculations
+		sc.RebuildTopLevel()
+		if got := sc.instanceBounds.recalculations - before; got != s.expRecalculated {
+			t.Errorf("[spec %d] expected %d instance bbox recalculations; got %d", index, s.expRecalculated, got)
+		}
+		if got := sc.instanceBBox(3); got != s.expBBox {
+			t.Errorf("[spec %d] expected bbox of instance 3 to be %v; got %v", index, s.expBBox, got)
+		}
+	}
+}
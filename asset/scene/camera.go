@@ -51,6 +51,16 @@ type Camera struct {
 
 	// Adjust the frustrum so that Y is inverted
 	InvertY bool
+
+	// Thin lens settings for depth of field.
+	Lens CameraLens
+
+	// Distances from the camera to the near and far clip planes measured
+	// along the view direction. Primary rays only hit geometry between the
+	// clip planes; secondary rays are not clipped. A zero distance disables
+	// the respective plane.
+	NearClip float32
+	FarClip  float32
 }
 
 func NewCamera(fov float32) *Camera {
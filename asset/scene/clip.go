@@ -0,0 +1,71 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Check that the camera clip distances are valid.
+func (c *Camera) ValidateClip() error {
+	if c.NearClip < 0 || c.FarClip < 0 {
+		return fmt.Errorf("scene: camera clip distances must be >= 0; got near: %f, far: %f", c.NearClip, c.FarClip)
+	}
+	if c.FarClip > 0 && c.FarClip <= c.NearClip {
+		return fmt.Errorf("scene: camera far clip distance (%f) must be greater than the near clip distance (%f)", c.FarClip, c.NearClip)
+	}
+	return nil
+}
+
+// Get the range of distances along a primary ray with the specified direction
+// that lie between the camera clip planes. As the clip planes are
+// perpendicular to the view direction, the range depends on the angle between
+// the ray and the view direction. If the far plane is disabled, tMax is set to
+// math.MaxFloat32.
+func (c *Camera) ClipRange(dir types.Vec3) (tMin, tMax float32) {
+	tMax = math.MaxFloat32
+	if c.NearClip <= 0 && c.FarClip <= 0 {
+		return 0, tMax
+	}
+
+	_, _, forward := c.LensBasis()
+	cosTheta := dir.Dot(forward)
+	if cosTheta <= 0 {
+		// The ray never crosses the clip planes
+		return 0, tMax
+	}
+
+	if c.NearClip > 0 {
+		tMin = c.NearClip / cosTheta
+	}
+	if c.FarClip > 0 {
+		tMax = c.FarClip / cosTheta
+	}
+	return tMin, tMax
+}
+
+// Clip a primary ray against the camera clip planes. The ray origin is moved
+// to the near plane so geometry in front of it is not hit and the ray max
+// distance is set so it stops at the far plane. Only primary rays should be
+// clipped; rays spawned at their hits are not affected by the clip planes.
+func (c *Camera) ClipRay(origin, dir types.Vec3) Ray {
+	tMin, tMax := c.ClipRange(dir)
+	ray := Ray{Origin: origin, Dir: dir}
+	if tMin > 0 {
+		ray.Origin = origin.Add(dir.Mul(tMin))
+	}
+	if tMax < math.MaxFloat32 {
+		ray.MaxDist = tMax - tMin
+	}
+	return ray
+}
+
+// Get the max hit distance for a ray. Rays with a zero max distance are
+// unbounded.
+func (r Ray) maxDist() float32 {
+	if r.MaxDist <= 0 {
+		return math.MaxFloat32
+	}
+	return r.MaxDist
+}
@@ -0,0 +1,61 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestCameraClipRange(t *testing.T) {
+	cam := NewCamera(45)
+	cam.LookAt = types.Vec3{0, 0, -1}
+	cam.SetupProjection(1)
+
+	diagonal := types.Vec3{1, 0, -1}.Normalize()
+	type spec struct {
+		near, far      float32
+		dir            types.Vec3
+		expMin, expMax float32
+	}
+	specs := []spec{
+		spec{0, 0, types.Vec3{0, 0, -1}, 0, math.MaxFloat32},
+		spec{2, 10, types.Vec3{0, 0, -1}, 2, 10},
+		spec{2, 0, types.Vec3{0, 0, -1}, 2, math.MaxFloat32},
+		// Clip distances are measured along the view direction
+		spec{2, 10, diagonal, 2 * math.Sqrt2, 10 * math.Sqrt2},
+		// Rays that never cross the clip planes are not clipped
+		spec{2, 10, types.Vec3{1, 0, 0}, 0, math.MaxFloat32},
+	}
+
+	for index, s := range specs {
+		cam.NearClip, cam.FarClip = s.near, s.far
+		tMin, tMax := cam.ClipRange(s.dir)
+		if math.Abs(float64(tMin-s.expMin)) > 1e-4 || math.Abs(float64(tMax-s.expMax)) > 1e-4*math.Max(1, float64(s.expMax)) {
+			t.Errorf("[spec %d] expected clip range [%f, %f]; got [%f, %f]", index, s.expMin, s.expMax, tMin, tMax)
+		}
+	}
+
+	type errSpec struct {
+		near, far float32
+		expErr    bool
+	}
+	errSpecs := []errSpec{
+		errSpec{0, 0, false},
+		errSpec{1, 0, false},
+		errSpec{1, 5, false},
+		errSpec{-1, 0, true},
+		errSpec{0, -1, true},
+		errSpec{5, 5, true},
+		errSpec{5, 1, true},
+	}
+	for index, s := range errSpecs {
+		cam.NearClip, cam.FarClip = s.near, s.far
+		err := cam.ValidateClip()
+		if s.expErr && err == nil {
+			t.Errorf("[spec %d] expected to get an error", index)
+		} else if !s.expErr && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+}
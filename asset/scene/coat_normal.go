@@ -0,0 +1,30 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/asset/material"
+)
+
+// Get the coat normal map of a layered node. Returns false if the coat is
+// shaded using the same normal as the base layer.
+func (n *MaterialNode) CoatNormalMap() (int32, bool) {
+	if n.Union1[0] != int32(material.OpLayered) || n.Union1[3] == -1 {
+		return -1, false
+	}
+	return n.Union1[3], true
+}
+
+// Set the normal map texture and convention used for shading the coat of a
+// layered node. The coat normal map only perturbs the normal of the coat
+// lobe; the base layer keeps using the surface normal. A texIndex of -1
+// clears the coat normal map.
+func (n *MaterialNode) SetCoatNormalMap(texIndex int32, convention material.NormalMapConvention) error {
+	if n.Union1[0] != int32(material.OpLayered) {
+		return fmt.Errorf("scene: coat normal maps can only be assigned to layered nodes")
+	}
+
+	n.Union1[3] = texIndex
+	n.Union5[0] = int32(convention)
+	return nil
+}
@@ -0,0 +1,44 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSetCoatNormalMap(t *testing.T) {
+	sc := &Scene{}
+	layeredIndex, err := sc.AddPlasticMaterial(types.Vec3{0.8, 0.1, 0.1}, 0, 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layered := &sc.MaterialNodeList[layeredIndex]
+
+	// Without a coat normal map, the coat uses the base normal
+	if _, hasCoatNormal := layered.CoatNormalMap(); hasCoatNormal {
+		t.Fatal("expected layered node to default to the base normal for the coat")
+	}
+
+	if err = layered.SetCoatNormalMap(0, material.NormalMapDirectX); err != nil {
+		t.Fatal(err)
+	}
+	if texIndex, hasCoatNormal := layered.CoatNormalMap(); !hasCoatNormal || texIndex != 0 {
+		t.Fatalf("expected coat normal map to use texture 0; got %d", texIndex)
+	}
+	if convention := layered.NormalMapConvention(); convention != material.NormalMapDirectX {
+		t.Fatalf("expected coat normal map convention to be %s; got %s", material.NormalMapDirectX, convention)
+	}
+
+	if err = layered.SetCoatNormalMap(-1, material.NormalMapOpenGL); err != nil {
+		t.Fatal(err)
+	}
+	if _, hasCoatNormal := layered.CoatNormalMap(); hasCoatNormal {
+		t.Fatal("expected a texture index of -1 to clear the coat normal map")
+	}
+
+	// Coat normal maps can only be assigned to layered nodes
+	if err = sc.MaterialNodeList[layered.Union1[1]].SetCoatNormalMap(0, material.NormalMapOpenGL); err == nil {
+		t.Fatal("expected an error when assigning a coat normal map to a bxdf node")
+	}
+}
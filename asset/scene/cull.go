@@ -0,0 +1,64 @@
+package scene
+
+import "github.com/achilleasa/polaris/types"
+
+// Return the indices of the mesh instances whose world-space bounding boxes
+// intersect the view frustum of the supplied camera. The returned list can
+// be used to build a reduced top-level BVH for rendering the camera view.
+//
+// The frustum planes are extracted from the camera view and projection
+// matrices so the camera projection must be set up before calling this
+// method. Culling is conservative: boxes that straddle two frustum planes
+// outside the frustum corners may be reported as visible.
+func (sc *Scene) CullToFrustum(cam *Camera) []uint32 {
+	planes := frustumPlanes(cam.ProjMat.Mul4(cam.ViewMat))
+
+	visible := make([]uint32, 0)
+	for index := range sc.MeshInstanceList {
+		if bboxInFrustum(sc.instanceBBox(uint32(index)), planes) {
+			visible = append(visible, uint32(index))
+		}
+	}
+
+	return visible
+}
+
+// Extract the left, right, bottom, top, near and far planes from a combined
+// projection/view matrix. Each plane is encoded as (a, b, c, d) where points
+// inside the frustum satisfy a*x + b*y + c*z + d >= 0.
+func frustumPlanes(projViewMat types.Mat4) [6]types.Vec4 {
+	r0, r1, r2, r3 := projViewMat.Row(0), projViewMat.Row(1), projViewMat.Row(2), projViewMat.Row(3)
+
+	var planes [6]types.Vec4
+	for i := 0; i < 4; i++ {
+		planes[0][i] = r3[i] + r0[i]
+		planes[1][i] = r3[i] - r0[i]
+		planes[2][i] = r3[i] + r1[i]
+		planes[3][i] = r3[i] - r1[i]
+		planes[4][i] = r3[i] + r2[i]
+		planes[5][i] = r3[i] - r2[i]
+	}
+
+	return planes
+}
+
+// Check whether a bbox is not fully behind any of the frustum planes.
+func bboxInFrustum(bbox [2]types.Vec3, planes [6]types.Vec4) bool {
+	for _, plane := range planes {
+		// Select the bbox corner that lies furthest along the plane normal
+		var corner types.Vec3
+		for axis := 0; axis < 3; axis++ {
+			if plane[axis] >= 0 {
+				corner[axis] = bbox[1][axis]
+			} else {
+				corner[axis] = bbox[0][axis]
+			}
+		}
+
+		if plane.Vec3().Dot(corner)+plane[3] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
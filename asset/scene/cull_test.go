@@ -0,0 +1,59 @@
+package scene
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestCullToFrustum(t *testing.T) {
+	// Bottom-level tree for a mesh with a unit bbox centered at the origin
+	sc := &Scene{
+		BvhNodeList: make([]BvhNode, 2),
+	}
+	sc.BvhNodeList[1].SetBBox([2]types.Vec3{{-1, -1, -1}, {1, 1, 1}})
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+
+	type instance struct {
+		pos   types.Vec3
+		scale float32
+	}
+	instances := []instance{
+		{types.Vec3{0, 0, -10}, 1},   // in front of the camera
+		{types.Vec3{0, 0, 10}, 1},    // behind the camera
+		{types.Vec3{50, 0, -10}, 1},  // right of the frustum
+		{types.Vec3{0, -50, -10}, 1}, // below the frustum
+		{types.Vec3{0, 0, -2000}, 1}, // beyond the far plane
+		{types.Vec3{6, 0, -10}, 1},   // just outside the right plane
+		{types.Vec3{6, 0, -10}, 5},   // scaled to overlap the right plane
+		{types.Vec3{10, 0, 0}, 1},    // right of the camera
+	}
+	for _, inst := range instances {
+		sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+			BvhRoot:   1,
+			Transform: types.Translate4(inst.pos).Mul4(types.Scale4(types.Vec3{inst.scale, inst.scale, inst.scale})).Inv(),
+		})
+	}
+
+	type spec struct {
+		lookAt     types.Vec3
+		expVisible []uint32
+	}
+	specs := []spec{
+		spec{types.Vec3{0, 0, -1}, []uint32{0, 6}},
+		spec{types.Vec3{0, 0, 1}, []uint32{1}},
+		spec{types.Vec3{1, 0, 0}, []uint32{2, 7}},
+	}
+	for index, s := range specs {
+		cam := NewCamera(math.Pi / 4)
+		cam.LookAt = s.lookAt
+		cam.SetupProjection(1)
+
+		visible := sc.CullToFrustum(cam)
+		if !reflect.DeepEqual(visible, s.expVisible) {
+			t.Fatalf("[spec %d] expected visible instances to be %v; got %v", index, s.expVisible, visible)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The default area at or below which a triangle is considered degenerate.
+const DefaultDegenerateAreaEpsilon float32 = 1e-12
+
+// Check whether a triangle is degenerate, i.e. whether its area does not
+// exceed epsilon. Degenerate triangles (e.g. triangles with collinear
+// vertices) cannot be reliably intersected and yield NaN normals. Triangles
+// with non-finite vertices are also treated as degenerate.
+func IsDegenerateTriangle(v0, v1, v2 types.Vec3, epsilon float32) bool {
+	area := 0.5 * v2.Sub(v0).Cross(v2.Sub(v1)).Len()
+	return !(area > epsilon && area <= math.MaxFloat32)
+}
@@ -0,0 +1,31 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestIsDegenerateTriangle(t *testing.T) {
+	inf := float32(math.Inf(1))
+
+	type spec struct {
+		vertices [3]types.Vec3
+		epsilon  float32
+		exp      bool
+	}
+	specs := []spec{
+		spec{[3]types.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}, DefaultDegenerateAreaEpsilon, false},
+		spec{[3]types.Vec3{{0, 0, 0}, {1, 1, 1}, {2, 2, 2}}, 0, true},
+		spec{[3]types.Vec3{{0, 0, 0}, {inf, 0, 0}, {0, 1, 0}}, 0, true},
+		// An area of 0.5 is degenerate for larger epsilons
+		spec{[3]types.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}, 0.5, true},
+	}
+
+	for index, s := range specs {
+		if got := IsDegenerateTriangle(s.vertices[0], s.vertices[1], s.vertices[2], s.epsilon); got != s.exp {
+			t.Fatalf("[spec %d] expected IsDegenerateTriangle to return %t; got %t", index, s.exp, got)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package scene
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// The default tolerance used by DiffScenes when comparing float values.
+const DefaultDiffEpsilon = 1e-5
+
+// A structural difference between two scenes.
+type Difference struct {
+	// The scene field that differs, e.g. "MeshInstanceList[1].Transform".
+	Field string
+
+	// A readable description of the difference.
+	Description string
+}
+
+// Implements Stringer.
+func (d Difference) String() string {
+	return d.Field + ": " + d.Description
+}
+
+// Compare the structure of two scenes and report their differences using
+// DefaultDiffEpsilon as the tolerance for comparing float values.
+func DiffScenes(a, b *Scene) []Difference {
+	return DiffScenesWithEpsilon(a, b, DefaultDiffEpsilon)
+}
+
+// Compare the structure of two scenes and report their differences. Float
+// values are considered equal if their absolute difference does not exceed
+// epsilon.
+//
+// Mesh instances, material nodes, emissives and texture metadata are compared
+// entry by entry. To keep the output readable, differences in the bulk data
+// lists (BVH nodes, geometry and texture data) are summarized into a single
+// entry per list that reports the number of differing entries.
+func DiffScenesWithEpsilon(a, b *Scene, epsilon float32) []Difference {
+	d := sceneDiffer{epsilon: epsilon}
+
+	// Mesh instances
+	if d.count("MeshInstanceList", len(a.MeshInstanceList), len(b.MeshInstanceList)) {
+		for index := range a.MeshInstanceList {
+			miA, miB := &a.MeshInstanceList[index], &b.MeshInstanceList[index]
+			d.uint32s(fmt.Sprintf("MeshInstanceList[%d].MeshIndex", index), miA.MeshIndex, miB.MeshIndex)
+			d.uint32s(fmt.Sprintf("MeshInstanceList[%d].BvhRoot", index), miA.BvhRoot, miB.BvhRoot)
+			d.floats(fmt.Sprintf("MeshInstanceList[%d].Transform", index), miA.Transform[:], miB.Transform[:])
+		}
+	}
+
+	// Material nodes
+	if d.count("MaterialNodeList", len(a.MaterialNodeList), len(b.MaterialNodeList)) {
+		for index := range a.MaterialNodeList {
+			nA, nB := &a.MaterialNodeList[index], &b.MaterialNodeList[index]
+			d.int32s(fmt.Sprintf("MaterialNodeList[%d].Union1", index), nA.Union1[:], nB.Union1[:])
+			d.floats(fmt.Sprintf("MaterialNodeList[%d].Union2", index), nA.Union2[:], nB.Union2[:])
+			d.floats(fmt.Sprintf("MaterialNodeList[%d].Union3", index), nA.Union3[:], nB.Union3[:])
+			d.floats(fmt.Sprintf("MaterialNodeList[%d].Union4", index), nA.Union4[:], nB.Union4[:])
+			d.int32s(fmt.Sprintf("MaterialNodeList[%d].Union5", index), nA.Union5[:], nB.Union5[:])
+			d.floats(fmt.Sprintf("MaterialNodeList[%d].Union6", index), nA.Union6[:], nB.Union6[:])
+		}
+	}
+	if a.SceneDiffuseMatIndex != b.SceneDiffuseMatIndex {
+		d.addValues("SceneDiffuseMatIndex", a.SceneDiffuseMatIndex, b.SceneDiffuseMatIndex)
+	}
+	if a.SceneEmissiveMatIndex != b.SceneEmissiveMatIndex {
+		d.addValues("SceneEmissiveMatIndex", a.SceneEmissiveMatIndex, b.SceneEmissiveMatIndex)
+	}
+	d.floats("EnvironmentYaw", []float32{a.EnvironmentYaw}, []float32{b.EnvironmentYaw})
+	groundA, groundB := a.GroundProjection.Params(), b.GroundProjection.Params()
+	d.floats("GroundProjection", groundA[:], groundB[:])
+	fogA, fogB := a.GlobalFog.Params(), b.GlobalFog.Params()
+	d.floats("GlobalFog", fogA[:], fogB[:])
+	d.floats("UnitsPerMeter", []float32{a.UnitsPerMeter}, []float32{b.UnitsPerMeter})
+
+	// Emissives
+	if d.count("EmissivePrimitives", len(a.EmissivePrimitives), len(b.EmissivePrimitives)) {
+		for index := range a.EmissivePrimitives {
+			eA, eB := &a.EmissivePrimitives[index], &b.EmissivePrimitives[index]
+			d.uint32s(fmt.Sprintf("EmissivePrimitives[%d].Type", index), uint32(eA.Type), uint32(eB.Type))
+			d.uint32s(fmt.Sprintf("EmissivePrimitives[%d].PrimitiveIndex", index), eA.PrimitiveIndex, eB.PrimitiveIndex)
+			d.uint32s(fmt.Sprintf("EmissivePrimitives[%d].MaterialNodeIndex", index), eA.MaterialNodeIndex, eB.MaterialNodeIndex)
+			d.floats(fmt.Sprintf("EmissivePrimitives[%d].Area", index), []float32{eA.Area}, []float32{eB.Area})
+			d.floats(fmt.Sprintf("EmissivePrimitives[%d].Transform", index), eA.Transform[:], eB.Transform[:])
+		}
+	}
+
+	if d.count("UVTransforms", len(a.UVTransforms), len(b.UVTransforms)) {
+		for index := range a.UVTransforms {
+			tA, tB := &a.UVTransforms[index], &b.UVTransforms[index]
+			d.floats(fmt.Sprintf("UVTransforms[%d]", index), []float32{tA.Scale[0], tA.Scale[1], tA.Offset[0], tA.Offset[1], tA.Rotation}, []float32{tB.Scale[0], tB.Scale[1], tB.Offset[0], tB.Offset[1], tB.Rotation})
+		}
+	}
+
+	if d.count("Opacities", len(a.Opacities), len(b.Opacities)) {
+		d.floats("Opacities", a.Opacities, b.Opacities)
+	}
+
+	// Light links
+	if d.count("LightLinks", len(a.LightLinks), len(b.LightLinks)) {
+		for index := range a.LightLinks {
+			lA, lB := &a.LightLinks[index], &b.LightLinks[index]
+			if fmt.Sprint(*lA) != fmt.Sprint(*lB) {
+				d.add(fmt.Sprintf("LightLinks[%d]", index), fmt.Sprintf("%+v != %+v", *lA, *lB))
+			}
+		}
+	}
+
+	// Render layers
+	if d.count("RenderLayers", len(a.RenderLayers), len(b.RenderLayers)) {
+		for index := range a.RenderLayers {
+			lA, lB := &a.RenderLayers[index], &b.RenderLayers[index]
+			if fmt.Sprint(*lA) != fmt.Sprint(*lB) {
+				d.add(fmt.Sprintf("RenderLayers[%d]", index), fmt.Sprintf("%+v != %+v", *lA, *lB))
+			}
+		}
+	}
+
+	if d.count("InstanceKeyframes", len(a.InstanceKeyframes), len(b.InstanceKeyframes)) {
+		for index := range a.InstanceKeyframes {
+			kA, kB := a.InstanceKeyframes[index], b.InstanceKeyframes[index]
+			if d.count(fmt.Sprintf("InstanceKeyframes[%d]", index), len(kA), len(kB)) {
+				for keyIndex := range kA {
+					d.floats(fmt.Sprintf("InstanceKeyframes[%d][%d].Time", index, keyIndex), []float32{kA[keyIndex].Time}, []float32{kB[keyIndex].Time})
+					d.floats(fmt.Sprintf("InstanceKeyframes[%d][%d].Transform", index, keyIndex), kA[keyIndex].Transform[:], kB[keyIndex].Transform[:])
+				}
+			}
+		}
+	}
+
+	// Textures
+	if d.count("TextureMetadata", len(a.TextureMetadata), len(b.TextureMetadata)) {
+		for index := range a.TextureMetadata {
+			tA, tB := &a.TextureMetadata[index], &b.TextureMetadata[index]
+			if *tA != *tB {
+				d.add(fmt.Sprintf("TextureMetadata[%d]", index), fmt.Sprintf("%+v != %+v", *tA, *tB))
+			}
+		}
+	}
+	if d.count("TextureData", len(a.TextureData), len(b.TextureData)) && !bytes.Equal(a.TextureData, b.TextureData) {
+		d.add("TextureData", "contents differ")
+	}
+
+	// BVH
+	if d.count("BvhNodeList", len(a.BvhNodeList), len(b.BvhNodeList)) {
+		d.summarize("BvhNodeList", len(a.BvhNodeList), func(index int) bool {
+			nA, nB := &a.BvhNodeList[index], &b.BvhNodeList[index]
+			return nA.LData == nB.LData && nA.RData == nB.RData &&
+				d.floatsEqual(nA.Min[:], nB.Min[:]) && d.floatsEqual(nA.Max[:], nB.Max[:])
+		})
+	}
+
+	// Geometry
+	if d.count("VertexList", len(a.VertexList), len(b.VertexList)) {
+		d.summarize("VertexList", len(a.VertexList), func(index int) bool {
+			return d.floatsEqual(a.VertexList[index][:], b.VertexList[index][:])
+		})
+	}
+	if d.count("NormalList", len(a.NormalList), len(b.NormalList)) {
+		d.summarize("NormalList", len(a.NormalList), func(index int) bool {
+			return d.floatsEqual(a.NormalList[index][:], b.NormalList[index][:])
+		})
+	}
+	if d.count("UvList", len(a.UvList), len(b.UvList)) {
+		d.summarize("UvList", len(a.UvList), func(index int) bool {
+			return d.floatsEqual(a.UvList[index][:], b.UvList[index][:])
+		})
+	}
+	if d.count("VertexColorList", len(a.VertexColorList), len(b.VertexColorList)) {
+		d.summarize("VertexColorList", len(a.VertexColorList), func(index int) bool {
+			return d.floatsEqual(a.VertexColorList[index][:], b.VertexColorList[index][:])
+		})
+	}
+	if d.count("MaterialIndex", len(a.MaterialIndex), len(b.MaterialIndex)) {
+		d.summarize("MaterialIndex", len(a.MaterialIndex), func(index int) bool {
+			return a.MaterialIndex[index] == b.MaterialIndex[index]
+		})
+	}
+
+	if d.count("QuadList", len(a.QuadList), len(b.QuadList)) {
+		d.summarize("QuadList", len(a.QuadList), func(index int) bool {
+			qA, qB := &a.QuadList[index], &b.QuadList[index]
+			if qA.MaterialIndex != qB.MaterialIndex {
+				return false
+			}
+			for i := 0; i < 4; i++ {
+				if !d.floatsEqual(qA.Vertices[i][:], qB.Vertices[i][:]) || !d.floatsEqual(qA.Uvs[i][:], qB.Uvs[i][:]) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	// Camera
+	switch {
+	case a.Camera == nil && b.Camera == nil:
+	case a.Camera == nil || b.Camera == nil:
+		d.add("Camera", "only defined in one of the scenes")
+	default:
+		d.floats("Camera.Position", a.Camera.Position[:], b.Camera.Position[:])
+		d.floats("Camera.LookAt", a.Camera.LookAt[:], b.Camera.LookAt[:])
+		d.floats("Camera.Up", a.Camera.Up[:], b.Camera.Up[:])
+		d.floats("Camera.FOV", []float32{a.Camera.FOV}, []float32{b.Camera.FOV})
+		if a.Camera.Lens != b.Camera.Lens {
+			d.addValues("Camera.Lens", a.Camera.Lens, b.Camera.Lens)
+		}
+	}
+
+	return d.diffs
+}
+
+// A helper for accumulating scene differences.
+type sceneDiffer struct {
+	epsilon float32
+	diffs   []Difference
+}
+
+func (d *sceneDiffer) add(field, description string) {
+	d.diffs = append(d.diffs, Difference{Field: field, Description: description})
+}
+
+func (d *sceneDiffer) addValues(field string, a, b interface{}) {
+	d.add(field, fmt.Sprintf("%v != %v", a, b))
+}
+
+// Compare list lengths. Returns true if the lengths match.
+func (d *sceneDiffer) count(field string, a, b int) bool {
+	if a == b {
+		return true
+	}
+	d.addValues(field+" count", a, b)
+	return false
+}
+
+func (d *sceneDiffer) uint32s(field string, a, b uint32) {
+	if a != b {
+		d.addValues(field, a, b)
+	}
+}
+
+func (d *sceneDiffer) int32s(field string, a, b []int32) {
+	for index := range a {
+		if a[index] != b[index] {
+			d.addValues(field, a, b)
+			return
+		}
+	}
+}
+
+func (d *sceneDiffer) floats(field string, a, b []float32) {
+	if d.floatsEqual(a, b) {
+		return
+	}
+	if len(a) == 1 {
+		d.addValues(field, a[0], b[0])
+		return
+	}
+	d.addValues(field, a, b)
+}
+
+func (d *sceneDiffer) floatsEqual(a, b []float32) bool {
+	for index := range a {
+		if float32(math.Abs(float64(a[index]-b[index]))) > d.epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// Count the list entries for which the supplied equality test fails and
+// report them as a single difference.
+func (d *sceneDiffer) summarize(field string, count int, equal func(index int) bool) {
+	var numDiffs, first int
+	for index := 0; index < count; index++ {
+		if equal(index) {
+			continue
+		}
+		if numDiffs == 0 {
+			first = index
+		}
+		numDiffs++
+	}
+
+	if numDiffs != 0 {
+		d.add(field, fmt.Sprintf("%d entries differ; first difference at index %d", numDiffs, first))
+	}
+}
@@ -0,0 +1,72 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestDiffScenes(t *testing.T) {
+	a := makeCreviceTestScene()
+	a.MaterialNodeList = make([]MaterialNode, 2)
+	a.Camera = NewCamera(45)
+
+	if diffs := DiffScenes(a, a); len(diffs) != 0 {
+		t.Fatalf("expected no differences when diffing a scene against itself; got %v", diffs)
+	}
+
+	b := makeCreviceTestScene()
+	b.MaterialNodeList = make([]MaterialNode, 3)
+	b.Camera = NewCamera(45)
+
+	// Changes below the epsilon should be ignored
+	b.VertexList[0][0] += DefaultDiffEpsilon / 10
+	b.Camera.FOV += DefaultDiffEpsilon / 10
+
+	// Changes that should be reported
+	b.MeshInstanceList[0].Transform = types.Translate4(types.Vec3{0, 1, 0})
+	b.BvhNodeList = append(b.BvhNodeList, BvhNode{})
+	b.NormalList[1][1] = -1
+	b.NormalList[4][1] = -1
+
+	diffs := DiffScenes(a, b)
+	expFields := []string{
+		"MeshInstanceList[0].Transform",
+		"MaterialNodeList count",
+		"BvhNodeList count",
+		"NormalList",
+	}
+	if len(diffs) != len(expFields) {
+		t.Fatalf("expected %d differences; got %d: %v", len(expFields), len(diffs), diffs)
+	}
+	for index, expField := range expFields {
+		if diffs[index].Field != expField {
+			t.Fatalf("[diff %d] expected field to be %q; got %q", index, expField, diffs[index].Field)
+		}
+	}
+
+	type spec struct {
+		diff   Difference
+		expStr string
+	}
+	specs := []spec{
+		spec{diffs[1], "MaterialNodeList count: 2 != 3"},
+		spec{diffs[2], "BvhNodeList count: 2 != 3"},
+		spec{diffs[3], "NormalList: 2 entries differ; first difference at index 1"},
+	}
+	for index, s := range specs {
+		if str := s.diff.String(); str != s.expStr {
+			t.Fatalf("[spec %d] expected difference to be %q; got %q", index, s.expStr, str)
+		}
+	}
+
+	// Using a larger epsilon should hide small differences
+	b = makeCreviceTestScene()
+	b.VertexList[0][0] += 0.01
+	if diffs = DiffScenesWithEpsilon(makeCreviceTestScene(), b, 0.1); len(diffs) != 0 {
+		t.Fatalf("expected no differences with a larger epsilon; got %v", diffs)
+	}
+	if diffs = DiffScenes(makeCreviceTestScene(), b); len(diffs) != 1 || diffs[0].Field != "VertexList" {
+		t.Fatalf("expected a vertex list difference; got %v", diffs)
+	}
+}
@@ -0,0 +1,207 @@
+package scene
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The set of scene properties that were modified by an Editor commit.
+type EditChanges uint8
+
+const (
+	// Mesh instance transforms were modified and the top-level BVH tree
+	// was rebuilt.
+	InstancesChanged EditChanges = 1 << iota
+
+	// Material nodes or uv transforms were modified.
+	MaterialsChanged
+)
+
+// An Editor batches scene mutations and applies them atomically between
+// render passes. Edits can be queued from any goroutine while the current
+// scene is being rendered; they are only applied when Commit is invoked.
+//
+// Commit never modifies the scene that was current before the commit.
+// Instead, it applies the queued edits to a copy of the scene that shares all
+// unmodified lists with the original. In-flight render passes therefore keep
+// working with a consistent scene while the committed scene can be passed to
+// the tracers (e.g. via UpdateState) before the next pass.
+//
+// The rebuild work performed by Commit depends on the type of the queued
+// edits:
+//
+//   - SetInstanceTransform: the top-level BVH tree is rebuilt; the mesh BVH
+//     trees are reused as the mesh geometry does not change. The transforms
+//     of the emissive primitives that belong to the instance are updated.
+//   - SetMaterial, SetUVTransform: no BVH rebuild is required.
+type Editor struct {
+	mutex sync.Mutex
+	scene *Scene
+
+	// Queued edits indexed by instance or material node index. If the
+	// same entity is edited multiple times, the last edit wins.
+	transforms   map[uint32]types.Mat4
+	materials    map[uint32]MaterialNode
+	uvTransforms map[uint32]UVTransform
+}
+
+// Create an editor for the supplied scene.
+func NewEditor(sc *Scene) *Editor {
+	e := &Editor{scene: sc}
+	e.reset()
+	return e
+}
+
+// Get the most recently committed scene.
+func (e *Editor) Scene() *Scene {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.scene
+}
+
+// Get the number of queued edits.
+func (e *Editor) Pending() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.transforms) + len(e.materials) + len(e.uvTransforms)
+}
+
+// Queue an update to the world to mesh transformation matrix of a mesh
+// instance.
+func (e *Editor) SetInstanceTransform(instanceIndex uint32, transform types.Mat4) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if int(instanceIndex) >= len(e.scene.MeshInstanceList) {
+		return fmt.Errorf("scene: invalid mesh instance index %d", instanceIndex)
+	}
+	if transform.Det() == 0 {
+		return fmt.Errorf("scene: transform for mesh instance %d is not invertible", instanceIndex)
+	}
+
+	e.transforms[instanceIndex] = transform
+	return nil
+}
+
+// Queue the replacement of a material node. The replacement node must have
+// the same type as the node it replaces; changing a node type (e.g. making a
+// surface emissive) requires recompiling the scene.
+func (e *Editor) SetMaterial(matNodeIndex uint32, node MaterialNode) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if int(matNodeIndex) >= len(e.scene.MaterialNodeList) {
+		return fmt.Errorf("scene: invalid material node index %d", matNodeIndex)
+	}
+	if oldType := e.scene.MaterialNodeList[matNodeIndex].Union1[0]; node.Union1[0] != oldType {
+		return fmt.Errorf("scene: material node %d type cannot be changed from %d to %d", matNodeIndex, oldType, node.Union1[0])
+	}
+
+	e.materials[matNodeIndex] = node
+	return nil
+}
+
+// Queue an update to the uv transform of the material with the specified root
+// node index.
+func (e *Editor) SetUVTransform(matNodeIndex uint32, t UVTransform) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if int(matNodeIndex) >= len(e.scene.MaterialNodeList) {
+		return fmt.Errorf("scene: invalid material node index %d", matNodeIndex)
+	}
+
+	e.uvTransforms[matNodeIndex] = t
+	return nil
+}
+
+// Discard all queued edits.
+func (e *Editor) Discard() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.reset()
+}
+
+// Apply all queued edits and return the updated scene together with the set
+// of modified scene properties. If no edits are queued, Commit returns the
+// current scene and a zero EditChanges value.
+func (e *Editor) Commit() (*Scene, EditChanges, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var changes EditChanges
+	if len(e.transforms) != 0 {
+		changes |= InstancesChanged
+	}
+	if len(e.materials) != 0 || len(e.uvTransforms) != 0 {
+		changes |= MaterialsChanged
+	}
+	if changes == 0 {
+		return e.scene, 0, nil
+	}
+
+	prev := e.scene
+	next := *prev
+	next.instanceBounds.entries = append([]instanceBounds(nil), prev.instanceBounds.entries...)
+
+	if changes&InstancesChanged != 0 {
+		// RebuildTopLevel updates the node list and the instance
+		// bvh roots in place so we need to work on copies.
+		next.MeshInstanceList = append([]MeshInstance(nil), prev.MeshInstanceList...)
+		next.BvhNodeList = append([]BvhNode(nil), prev.BvhNodeList...)
+		next.EmissivePrimitives = append([]EmissivePrimitive(nil), prev.EmissivePrimitives...)
+
+		for instanceIndex, transform := range e.transforms {
+			mi := &next.MeshInstanceList[instanceIndex]
+			next.updateEmissiveTransforms(mi, transform)
+			mi.Transform = transform
+		}
+		next.RebuildTopLevel()
+	}
+
+	if len(e.materials) != 0 {
+		next.MaterialNodeList = append([]MaterialNode(nil), prev.MaterialNodeList...)
+		for matNodeIndex, node := range e.materials {
+			next.MaterialNodeList[matNodeIndex] = node
+		}
+	}
+
+	if len(e.uvTransforms) != 0 {
+		next.UVTransforms = append([]UVTransform(nil), prev.UVTransforms...)
+		for matNodeIndex, t := range e.uvTransforms {
+			if err := next.SetUVTransform(matNodeIndex, t); err != nil {
+				return prev, 0, err
+			}
+		}
+	}
+
+	e.scene = &next
+	e.reset()
+	return e.scene, changes, nil
+}
+
+// Clear the queued edits.
+func (e *Editor) reset() {
+	e.transforms = make(map[uint32]types.Mat4)
+	e.materials = make(map[uint32]MaterialNode)
+	e.uvTransforms = make(map[uint32]UVTransform)
+}
+
+// Update the transform of the area light emissives that belong to a mesh
+// instance. As emissives do not reference their instance, they are matched
+// using their primitive index and their current transform.
+func (sc *Scene) updateEmissiveTransforms(mi *MeshInstance, transform types.Mat4) {
+	firstPrim, numPrims, err := meshPrimitives(sc, mi.MeshIndex)
+	if err != nil {
+		return
+	}
+
+	for index := range sc.EmissivePrimitives {
+		em := &sc.EmissivePrimitives[index]
+		if em.Type == AreaLight && em.PrimitiveIndex >= firstPrim && em.PrimitiveIndex < firstPrim+numPrims && em.Transform == mi.Transform {
+			em.Transform = transform
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package scene
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestEditorCommitBetweenPasses(t *testing.T) {
+	// Two instances of a 2x2 plane; the second one is moved by the editor
+	sc := makePlaneTestScene(4)
+	sc.MaterialNodeList = make([]MaterialNode, 1)
+	sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+		MeshIndex: 0,
+		BvhRoot:   sc.MeshInstanceList[0].BvhRoot,
+		Transform: types.Translate4(types.Vec3{10, 0, 0}).Inv(),
+	})
+	sc.RebuildTopLevel()
+	original := sc
+
+	editor := NewEditor(sc)
+
+	// Queue edits from a separate goroutine while passes are rendered
+	const numEdits = 200
+	editsDone := make(chan struct{})
+	go func() {
+		defer close(editsDone)
+		for edit := 1; edit <= numEdits; edit++ {
+			pos := types.Vec3{10 + float32(edit), float32(edit % 7), 0}
+			if err := editor.SetInstanceTransform(1, types.Translate4(pos).Inv()); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := editor.SetUVTransform(0, UVTransform{Offset: types.Vec2{float32(edit), 0}}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	// Each pass traces rays from multiple workers against a scene snapshot
+	// while the next commit is performed. Rays through the center of each
+	// instance must always hit the instance they target.
+	renderPass := func(snapshot *Scene) {
+		var wg sync.WaitGroup
+		for worker := 0; worker < 4; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				scratch := NewRayScratch()
+				for instIndex := range snapshot.MeshInstanceList {
+					center := snapshot.MeshInstanceList[instIndex].Transform.Inv().Mul4x1(types.Vec4{0, 0, 0, 1}).Vec3()
+					hit, found := snapshot.IntersectWithScratch(scratch, center.Add(types.Vec3{0, 0, 5}), types.Vec3{0, 0, -1}, 100)
+					if !found || hit.MeshInstance != uint32(instIndex) {
+						t.Errorf("expected ray through center of instance %d to hit it; got %v (found: %t)", instIndex, hit, found)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	done := false
+	for !done {
+		select {
+		case <-editsDone:
+			done = true
+		default:
+		}
+
+		snapshot := editor.Scene()
+		passDone := make(chan struct{})
+		go func() {
+			renderPass(snapshot)
+			close(passDone)
+		}()
+
+		if _, _, err := editor.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		<-passDone
+	}
+
+	// All edits should be applied once the edit goroutine completes
+	if _, _, err := editor.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if editor.Pending() != 0 {
+		t.Fatalf("expected no pending edits after commit; got %d", editor.Pending())
+	}
+
+	final := editor.Scene()
+	expPos := types.Vec3{10 + numEdits, numEdits % 7, 0}
+	if got := final.MeshInstanceList[1].Transform; got != types.Translate4(expPos).Inv() {
+		t.Fatalf("expected final instance transform to match the last edit; got %v", got)
+	}
+	if got := final.UVTransforms[0].Offset[0]; got != numEdits {
+		t.Fatalf("expected final uv offset to be %d; got %f", numEdits, got)
+	}
+	renderPass(final)
+
+	// The original scene should not be modified
+	if got := original.MeshInstanceList[1].Transform; got != types.Translate4(types.Vec3{10, 0, 0}).Inv() {
+		t.Fatalf("expected the original scene to remain unmodified; got instance transform %v", got)
+	}
+	if len(original.UVTransforms) != 0 {
+		t.Fatal("expected the original scene uv transforms to remain unmodified")
+	}
+}
+
+func TestEditorChanges(t *testing.T) {
+	sc := makePlaneTestScene(2)
+	sc.MaterialNodeList = make([]MaterialNode, 2)
+	sc.MaterialNodeList[1].Union1[0] = 1
+
+	type spec struct {
+		edit       func(*Editor) error
+		expChanges EditChanges
+		expError   bool
+	}
+	specs := []spec{
+		spec{func(e *Editor) error { return nil }, 0, false},
+		spec{func(e *Editor) error { return e.SetInstanceTransform(0, types.Translate4(types.Vec3{1, 0, 0})) }, InstancesChanged, false},
+		spec{func(e *Editor) error { return e.SetMaterial(0, MaterialNode{}) }, MaterialsChanged, false},
+		spec{func(e *Editor) error { return e.SetUVTransform(1, UVTransform{Rotation: 1}) }, MaterialsChanged, false},
+		// Invalid edits
+		spec{func(e *Editor) error { return e.SetInstanceTransform(1, types.Ident4()) }, 0, true},
+		spec{func(e *Editor) error { return e.SetInstanceTransform(0, types.Mat4{}) }, 0, true},
+		spec{func(e *Editor) error { return e.SetMaterial(2, MaterialNode{}) }, 0, true},
+		spec{func(e *Editor) error { return e.SetMaterial(1, MaterialNode{}) }, 0, true},
+		spec{func(e *Editor) error { return e.SetUVTransform(2, UVTransform{}) }, 0, true},
+	}
+
+	for index, s := range specs {
+		editor := NewEditor(sc)
+		err := s.edit(editor)
+		if s.expError {
+			if err == nil {
+				t.Errorf("[spec %d] expected edit to fail", index)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+			continue
+		}
+
+		next, changes, err := editor.Commit()
+		if err != nil {
+			t.Errorf("[spec %d] unexpected commit error: %v", index, err)
+			continue
+		}
+		if changes != s.expChanges {
+			t.Errorf("[spec %d] expected changes to be %d; got %d", index, s.expChanges, changes)
+		}
+		if (changes == 0) != (next == sc) {
+			t.Errorf("[spec %d] expected commit to return a new scene only if edits were applied", index)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package scene
+
+import (
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+// Get the faces of an emissive node that emit light.
+func (n *MaterialNode) EmissionSide() material.EmissionSide {
+	return material.EmissionSide(n.Union5[0])
+}
+
+// Set the faces of an emissive node that emit light.
+func (n *MaterialNode) SetEmissionSide(side material.EmissionSide) {
+	n.Union5[0] = int32(side)
+}
+
+// Get the falloff radius of an emissive node. A radius of 0 indicates that
+// the falloff is disabled; see material.EmissionFalloff.
+func (n *MaterialNode) EmissionFalloff() float32 {
+	return n.Union3[0]
+}
+
+// Set the falloff radius of an emissive node. Emission falloff is
+// non-physical and must be explicitly enabled by setting a non-zero radius.
+func (n *MaterialNode) SetEmissionFalloff(radius float32) {
+	n.Union3[0] = radius
+}
+
+// Get the radiance emitted by an emissive node towards dir, a direction
+// pointing away from the surface. The emitting side is selected by comparing
+// dir against the surface geometric normal; shading normals are ignored so
+// that interpolated or normal-mapped normals cannot flip the emitting side.
+func (n *MaterialNode) EmissionTowards(geomNormal, dir types.Vec3) types.Vec3 {
+	if !emitsTowards(n.EmissionSide(), geomNormal.Dot(dir)) {
+		return types.Vec3{}
+	}
+	return n.Emission()
+}
+
+// Check whether a surface with the given emission side emits light towards a
+// direction given the cosine of the angle between the direction and the
+// surface geometric normal.
+func emitsTowards(side material.EmissionSide, cosTheta float32) bool {
+	switch side {
+	case material.EmitBack:
+		return cosTheta < 0
+	case material.EmitBothSides:
+		return cosTheta != 0
+	}
+	return cosTheta > 0
+}
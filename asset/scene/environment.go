@@ -0,0 +1,70 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Set the environment map rotation around the Y axis (in radians) and update
+// the transformation matrix of any environment light emissives. A zero yaw
+// leaves the environment map unrotated.
+func (sc *Scene) SetEnvironmentYaw(yaw float32) {
+	sc.EnvironmentYaw = yaw
+	rot := EnvironmentRotation(yaw)
+	for index := range sc.EmissivePrimitives {
+		if sc.EmissivePrimitives[index].Type == EnvironmentLight {
+			sc.EmissivePrimitives[index].Transform = rot
+		}
+	}
+}
+
+// Build a matrix for converting world space directions to the space of an
+// environment map rotated by yaw radians around the Y axis.
+func EnvironmentRotation(yaw float32) types.Mat4 {
+	sin, cos := math.Sincos(float64(-yaw))
+	s, c := float32(sin), float32(cos)
+
+	// Mat4 is stored in column-major order
+	return types.Mat4{
+		c, 0, -s, 0,
+		0, 1, 0, 0,
+		s, 0, c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Ground projection settings for occluding the lower hemisphere of the scene
+// environment. When enabled, rays that escape the scene towards a direction
+// below the horizon return the ground color instead of sampling the
+// environment. This prevents environment-only lighting from leaking light
+// from below the ground.
+type GroundProjection struct {
+	Enabled bool
+
+	// The elevation of the horizon in radians. Directions whose angle with
+	// the world XZ plane is below this value are occluded. A zero value
+	// places the horizon at the XZ plane.
+	Horizon float32
+
+	// The radiance returned for occluded directions. A zero value yields
+	// a black ground.
+	Color types.Vec3
+}
+
+// Check whether the ground projection occludes the environment towards dir.
+func (g GroundProjection) Occludes(dir types.Vec3) bool {
+	return dir[1] < g.Params()[3]*dir.Len()
+}
+
+// Pack the ground projection settings into a vector for the opencl kernels.
+// The XYZ components contain the ground color and the W component contains
+// the sine of the horizon elevation; directions whose normalized Y component
+// is less than W are occluded. Disabled projections use a W value of -2 so
+// that no direction is ever occluded.
+func (g GroundProjection) Params() types.Vec4 {
+	if !g.Enabled {
+		return g.Color.Vec4(-2)
+	}
+	return g.Color.Vec4(float32(math.Sin(float64(g.Horizon))))
+}
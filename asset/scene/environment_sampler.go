@@ -0,0 +1,189 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+)
+
+// An entry in an alias table. The layout matches the AliasEntry struct used
+// by the opencl kernels.
+type AliasEntry struct {
+	// The probability of selecting this entry instead of its alias once
+	// the entry has been picked uniformly.
+	Prob float32
+
+	// The index of the alias entry.
+	Alias uint32
+
+	// The normalized probability (or density) associated with this entry.
+	Pdf float32
+
+	padding uint32
+}
+
+// Build an alias table for sampling the supplied non-negative weights in
+// O(1) time using Walker's alias method (Vose's variant). The Pdf field of
+// each entry is set to its weight divided by the total weight. If all weights
+// are zero, the table samples all entries with the same probability.
+func BuildAliasTable(weights []float32) []AliasEntry {
+	n := len(weights)
+	table := make([]AliasEntry, n)
+	if n == 0 {
+		return table
+	}
+
+	var total float64
+	for _, w := range weights {
+		total += float64(w)
+	}
+
+	// Scale probabilities so the average is 1 and split entries into the
+	// ones that need an alias and the ones that can donate probability.
+	scaled := make([]float64, n)
+	small := make([]int, 0, n)
+	large := make([]int, 0, n)
+	for index, w := range weights {
+		if total > 0 {
+			table[index].Pdf = float32(float64(w) / total)
+			scaled[index] = float64(w) * float64(n) / total
+		} else {
+			table[index].Pdf = 1.0 / float32(n)
+			scaled[index] = 1.0
+		}
+
+		if scaled[index] < 1.0 {
+			small = append(small, index)
+		} else {
+			large = append(large, index)
+		}
+	}
+
+	for len(small) != 0 && len(large) != 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+
+		table[s].Prob = float32(scaled[s])
+		table[s].Alias = uint32(l)
+
+		scaled[l] -= 1.0 - scaled[s]
+		if scaled[l] < 1.0 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+
+	// Any remaining entries (including ones left over due to rounding
+	// errors) are always selected.
+	for _, index := range append(small, large...) {
+		table[index].Prob = 1.0
+		table[index].Alias = uint32(index)
+	}
+
+	return table
+}
+
+// An importance sampler for lat-long environment maps. The sampler uses a 2D
+// alias table: a marginal table selects the map row and a per-row conditional
+// table selects the column. Each texel is weighted by its luminance times
+// sin(theta) to account for the compression of the equirectangular mapping
+// near the poles.
+type EnvironmentSampler struct {
+	Width  uint32
+	Height uint32
+
+	// The marginal row table (Height entries) followed by the conditional
+	// column table for each row (Width entries each). The Pdf field of
+	// each column entry stores the density of its texel in uv space. This
+	// layout is used by the opencl kernels.
+	Entries []AliasEntry
+}
+
+// Get the alias table entries in the layout used by the opencl kernels.
+// Returns nil if the sampler is nil.
+func (es *EnvironmentSampler) AliasTable() []AliasEntry {
+	if es == nil {
+		return nil
+	}
+	return es.Entries
+}
+
+// Create an environment sampler for a map with the specified dimensions and
+// per-texel luminance values stored in row-major order.
+func NewEnvironmentSampler(width, height uint32, luminance []float32) (*EnvironmentSampler, error) {
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("scene: environment sampler dimensions must be greater than 0")
+	}
+	if len(luminance) != int(width*height) {
+		return nil, fmt.Errorf("scene: expected %d luminance values for a %dx%d environment map; got %d", width*height, width, height, len(luminance))
+	}
+
+	es := &EnvironmentSampler{
+		Width:   width,
+		Height:  height,
+		Entries: make([]AliasEntry, height, height+width*height),
+	}
+
+	rowWeights := make([]float32, height)
+	texelWeights := make([]float32, width)
+	for y := uint32(0); y < height; y++ {
+		sinTheta := float32(math.Sin(math.Pi * (float64(y) + 0.5) / float64(height)))
+		for x := uint32(0); x < width; x++ {
+			texelWeights[x] = float32(math.Max(0, float64(luminance[y*width+x]))) * sinTheta
+			rowWeights[y] += texelWeights[x]
+		}
+		es.Entries = append(es.Entries, BuildAliasTable(texelWeights)...)
+	}
+	copy(es.Entries, BuildAliasTable(rowWeights))
+
+	// Convert conditional column pdfs into joint texel densities in uv space
+	for y := uint32(0); y < height; y++ {
+		row := es.Entries[height+y*width : height+(y+1)*width]
+		for x := range row {
+			row[x].Pdf *= es.Entries[y].Pdf * float32(width*height)
+		}
+	}
+
+	return es, nil
+}
+
+// Get an importance sampler for the radiance texture of the scene environment
+// light. Returns nil if the scene does not define an environment light or its
+// radiance is not defined by an image texture.
+//
+// The sampler is cached by the scene and only rebuilt when the scene lights
+// change or InvalidateLights is invoked. Callers must not modify it.
+func (sc *Scene) EnvironmentSampler() (*EnvironmentSampler, error) {
+	cache, err := sc.cachedLights()
+	if err != nil {
+		return nil, err
+	}
+	return cache.envSampler, nil
+}
+
+// Build an importance sampler for the radiance texture of the scene
+// environment light.
+func (sc *Scene) buildEnvironmentSampler() (*EnvironmentSampler, error) {
+	for _, em := range sc.EmissivePrimitives {
+		if em.Type != EnvironmentLight {
+			continue
+		}
+
+		texIndex := sc.MaterialNodeList[em.MaterialNodeIndex].Union1[3]
+		if texIndex < 0 || sc.TextureMetadata[texIndex].Format.IsProcedural() {
+			return nil, nil
+		}
+
+		meta := &sc.TextureMetadata[texIndex]
+		bpp := texelSize(meta.Format)
+		luminance := make([]float32, 0, meta.Width*meta.Height)
+		for y := uint32(0); y < meta.Height; y++ {
+			for x := uint32(0); x < meta.Width; x++ {
+				offset := meta.DataOffset + (y*meta.Width+x)*bpp
+				c := decodeTexel(meta.Format, sc.TextureData[offset:offset+bpp])
+				luminance = append(luminance, 0.2126*c[0]+0.7152*c[1]+0.0722*c[2])
+			}
+		}
+		return NewEnvironmentSampler(meta.Width, meta.Height, luminance)
+	}
+	return nil, nil
+}
@@ -0,0 +1,125 @@
+package scene
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBuildAliasTable(t *testing.T) {
+	type spec struct {
+		weights []float32
+	}
+	specs := []spec{
+		spec{[]float32{1}},
+		spec{[]float32{1, 1, 1, 1}},
+		spec{[]float32{0.1, 5, 0, 2.5, 1}},
+		spec{[]float32{0, 0, 0}},
+		spec{[]float32{1000, 1e-3, 1e-3, 1e-3, 1e-3, 1e-3, 1e-3}},
+	}
+
+	for index, s := range specs {
+		table := BuildAliasTable(s.weights)
+
+		var total float32
+		for _, w := range s.weights {
+			total += w
+		}
+
+		// Reconstruct the selection probability of each entry
+		n := float32(len(table))
+		probs := make([]float32, len(table))
+		for entryIndex, entry := range table {
+			probs[entryIndex] += entry.Prob / n
+			probs[entry.Alias] += (1 - entry.Prob) / n
+		}
+
+		for entryIndex, w := range s.weights {
+			expProb := 1 / n
+			if total > 0 {
+				expProb = w / total
+			}
+			if math.Abs(float64(probs[entryIndex]-expProb)) > 1e-5 {
+				t.Errorf("[spec %d] expected entry %d to be selected with probability %f; got %f", index, entryIndex, expProb, probs[entryIndex])
+			}
+			if math.Abs(float64(table[entryIndex].Pdf-expProb)) > 1e-5 {
+				t.Errorf("[spec %d] expected entry %d pdf to be %f; got %f", index, entryIndex, expProb, table[entryIndex].Pdf)
+			}
+		}
+	}
+}
+
+func TestEnvironmentSamplerDistribution(t *testing.T) {
+	// A map with a bright "sun" texel, a bright band near the pole and
+	// a dim background.
+	const width, height = 16, 8
+	luminance := make([]float32, width*height)
+	for index := range luminance {
+		luminance[index] = 0.1
+	}
+	luminance[2*width+5] = 50
+	for x := 0; x < width; x++ {
+		luminance[x] = 4
+	}
+
+	sampler, err := NewEnvironmentSampler(width, height, luminance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The expected probability of sampling each texel is proportional to
+	// its luminance times the sin(theta) term of the lat-long mapping.
+	expProbs := make([]float64, width*height)
+	var total float64
+	for y := 0; y < height; y++ {
+		sinTheta := math.Sin(math.Pi * (float64(y) + 0.5) / height)
+		for x := 0; x < width; x++ {
+			expProbs[y*width+x] = float64(luminance[y*width+x]) * sinTheta
+			total += expProbs[y*width+x]
+		}
+	}
+
+	// Reconstruct the selection probability of each texel from the
+	// marginal row table and the conditional column tables.
+	rowProbs := aliasTableProbs(sampler.Entries[:height])
+	for y := 0; y < height; y++ {
+		row := sampler.Entries[height+y*width : height+(y+1)*width]
+		colProbs := aliasTableProbs(row)
+		for x := 0; x < width; x++ {
+			expProb := expProbs[y*width+x] / total
+			if gotProb := rowProbs[y] * colProbs[x]; math.Abs(gotProb-expProb) > 1e-5 {
+				t.Errorf("expected texel (%d, %d) to be sampled with probability %f; got %f", x, y, expProb, gotProb)
+			}
+
+			// Column entries store the texel density in uv space
+			if expPdf := expProb * width * height; math.Abs(float64(row[x].Pdf)-expPdf) > 1e-3*expPdf {
+				t.Errorf("expected texel (%d, %d) uv density to be %f; got %f", x, y, expPdf, row[x].Pdf)
+			}
+		}
+	}
+}
+
+func TestEnvironmentSamplerErrors(t *testing.T) {
+	if _, err := NewEnvironmentSampler(0, 4, nil); err == nil {
+		t.Fatal("expected an error for a zero-sized environment map")
+	}
+	if _, err := NewEnvironmentSampler(2, 2, make([]float32, 3)); err == nil {
+		t.Fatal("expected an error for a luminance list with the wrong size")
+	}
+
+	// Scenes without an environment light do not need a sampler
+	sc := &Scene{}
+	if sampler, err := sc.EnvironmentSampler(); err != nil || sampler != nil {
+		t.Fatalf("expected no sampler for a scene without an environment light; got %v, %v", sampler, err)
+	}
+}
+
+// Get the selection probability of each entry in an alias table.
+func aliasTableProbs(table []AliasEntry) []float64 {
+	n := float64(len(table))
+	probs := make([]float64, len(table))
+	for index, entry := range table {
+		probs[index] += float64(entry.Prob) / n
+		probs[entry.Alias] += (1 - float64(entry.Prob)) / n
+	}
+	return probs
+}
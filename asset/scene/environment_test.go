@@ -0,0 +1,70 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSetEnvironmentYaw(t *testing.T) {
+	sc := &Scene{
+		EmissivePrimitives: []EmissivePrimitive{
+			{Type: AreaLight, Transform: types.Ident4()},
+			{Type: EnvironmentLight, Transform: types.Ident4()},
+		},
+	}
+
+	sc.SetEnvironmentYaw(math.Pi / 2)
+	if sc.EnvironmentYaw != math.Pi/2 {
+		t.Fatalf("expected environment yaw to be %f; got %f", math.Pi/2, sc.EnvironmentYaw)
+	}
+	if sc.EmissivePrimitives[0].Transform != types.Ident4() {
+		t.Fatalf("expected area light transform to be left untouched; got %v", sc.EmissivePrimitives[0].Transform)
+	}
+	if sc.EmissivePrimitives[1].Transform != EnvironmentRotation(math.Pi/2) {
+		t.Fatalf("expected env light transform to match the environment rotation; got %v", sc.EmissivePrimitives[1].Transform)
+	}
+
+	sc.SetEnvironmentYaw(0)
+	if sc.EmissivePrimitives[1].Transform != types.Ident4() {
+		t.Fatalf("expected zero yaw to reset env light transform to identity; got %v", sc.EmissivePrimitives[1].Transform)
+	}
+}
+
+func TestGroundProjection(t *testing.T) {
+	sky := types.Vec3{0.2, 0.4, 0.8}
+	ground := types.Vec3{0.3, 0.2, 0.1}
+	sc := &Scene{
+		MaterialNodeList:     []MaterialNode{{Union2: sky.Vec4(0)}},
+		SceneDiffuseMatIndex: 0,
+	}
+
+	type spec struct {
+		projection GroundProjection
+		dir        types.Vec3
+		exp        types.Vec3
+	}
+	specs := []spec{
+		// Disabled projection
+		spec{GroundProjection{Color: ground}, types.Vec3{0, -1, 0}, sky},
+		spec{GroundProjection{Color: ground}, types.Vec3{1, -0.1, 0}, sky},
+		// Horizon at the XZ plane
+		spec{GroundProjection{Enabled: true, Color: ground}, types.Vec3{0, -1, 0}, ground},
+		spec{GroundProjection{Enabled: true, Color: ground}, types.Vec3{1, -0.1, 0}, ground},
+		spec{GroundProjection{Enabled: true, Color: ground}, types.Vec3{1, 0.1, 0}, sky},
+		spec{GroundProjection{Enabled: true}, types.Vec3{0, -1, 0}, types.Vec3{}},
+		// Raised and lowered horizon (+/- 30 degrees)
+		spec{GroundProjection{Enabled: true, Horizon: math.Pi / 6, Color: ground}, types.Vec3{1, 0.5, 0}, ground},
+		spec{GroundProjection{Enabled: true, Horizon: math.Pi / 6, Color: ground}, types.Vec3{1, 0.7, 0}, sky},
+		spec{GroundProjection{Enabled: true, Horizon: -math.Pi / 6, Color: ground}, types.Vec3{1, -0.5, 0}, sky},
+		spec{GroundProjection{Enabled: true, Horizon: -math.Pi / 6, Color: ground}, types.Vec3{1, -0.7, 0}, ground},
+	}
+
+	for index, s := range specs {
+		sc.GroundProjection = s.projection
+		if got := sc.traceBackground(s.dir); got != s.exp {
+			t.Errorf("[spec %d] expected miss ray towards %v to return %v; got %v", index, s.dir, s.exp, got)
+		}
+	}
+}
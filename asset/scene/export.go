@@ -0,0 +1,96 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The winding order of front-facing triangles.
+type Winding uint8
+
+// Supported winding orders.
+const (
+	// Front faces use a counter-clockwise vertex order. This is the
+	// convention used internally by polaris.
+	WindingCCW Winding = iota
+
+	// Front faces use a clockwise vertex order.
+	WindingCW
+)
+
+// The geometry conventions of an export target. The zero value matches the
+// conventions used internally by polaris.
+type ExportConvention struct {
+	// The winding order of front-facing triangles.
+	Winding Winding
+
+	// Flip vertex normals so they point towards the back side of each
+	// triangle.
+	FlipNormals bool
+}
+
+// A world-space triangle converted to an export convention.
+type ExportTriangle struct {
+	Vertices [3]types.Vec3
+	Normals  [3]types.Vec3
+	UVs      [3]types.Vec2
+}
+
+// Get the world-space triangles of a mesh instance converted to the supplied
+// export convention. The scene geometry is not modified.
+//
+// Instances whose transform mirrors the mesh have their winding reversed so
+// that the exported triangles always face the same side as the rendered
+// ones.
+func (sc *Scene) ExportInstance(instanceIndex uint32, conv ExportConvention) ([]ExportTriangle, error) {
+	if int(instanceIndex) >= len(sc.MeshInstanceList) {
+		return nil, fmt.Errorf("scene: invalid mesh instance index %d", instanceIndex)
+	}
+	mi := &sc.MeshInstanceList[instanceIndex]
+	firstPrim, numPrims, err := meshPrimitives(sc, mi.MeshIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// The instance transform converts from world to mesh space. Normals
+	// are transformed using the inverse transpose of the mesh to world
+	// transform which is the transpose of the instance transform.
+	meshToWorld := mi.Transform.Inv()
+	normalCols := [3]types.Vec3{
+		mi.Transform.Col(0).Vec3(),
+		mi.Transform.Col(1).Vec3(),
+		mi.Transform.Col(2).Vec3(),
+	}
+	swapWinding := (conv.Winding == WindingCW) != (meshToWorld.Det() < 0)
+
+	triangles := make([]ExportTriangle, numPrims)
+	for face := uint32(0); face < numPrims; face++ {
+		tri := &triangles[face]
+		v := 3 * (firstPrim + face)
+		for i := uint32(0); i < 3; i++ {
+			tri.Vertices[i] = meshToWorld.Mul4x1(sc.VertexList[v+i].Vec3().Vec4(1)).Vec3()
+
+			n := sc.NormalList[v+i].Vec3()
+			tri.Normals[i] = types.Vec3{normalCols[0].Dot(n), normalCols[1].Dot(n), normalCols[2].Dot(n)}
+			if tri.Normals[i].Len() > 0 {
+				tri.Normals[i] = tri.Normals[i].Normalize()
+			}
+			if conv.FlipNormals {
+				tri.Normals[i] = tri.Normals[i].Mul(-1)
+			}
+
+			if len(sc.UvList) != 0 {
+				tri.UVs[i] = sc.UvList[v+i]
+			}
+		}
+
+		if swapWinding {
+			tri.Vertices[1], tri.Vertices[2] = tri.Vertices[2], tri.Vertices[1]
+			tri.Normals[1], tri.Normals[2] = tri.Normals[2], tri.Normals[1]
+			tri.UVs[1], tri.UVs[2] = tri.UVs[2], tri.UVs[1]
+		}
+	}
+
+	return triangles, nil
+}
@@ -0,0 +1,66 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A homogeneous fog that fills the entire scene. Rays that hit a surface
+// after travelling a distance d are attenuated by the fog transmittance
+// T = exp(-Density * d) while the fog scatters (1 - T) * Color of radiance
+// towards the ray origin. The fog color therefore acts as the radiance that
+// the fog in-scatters from the scene lights; surfaces fade towards it with
+// distance. Rays that escape the scene are not affected by the fog so the
+// environment remains visible.
+//
+// The fog is disabled if its density is 0.
+type GlobalFog struct {
+	// The extinction coefficient of the fog per scene unit.
+	Density float32
+
+	// The radiance in-scattered by the fog.
+	Color types.Vec3
+}
+
+// Returns true if the fog is enabled.
+func (f GlobalFog) Enabled() bool {
+	return f.Density > 0
+}
+
+// Calculate the fraction of light that is transmitted through the fog over
+// the given distance.
+func (f GlobalFog) Transmittance(dist float32) float32 {
+	if !f.Enabled() {
+		return 1
+	}
+	return float32(math.Exp(-float64(f.Density) * float64(dist)))
+}
+
+// Apply the fog to the radiance arriving from a surface at the given
+// distance.
+func (f GlobalFog) Apply(radiance types.Vec3, dist float32) types.Vec3 {
+	t := f.Transmittance(dist)
+	return radiance.Mul(t).Add(f.Color.Mul(1 - t))
+}
+
+// Pack the fog settings into a vector for the opencl kernels. The XYZ
+// components contain the fog color and the W component contains the fog
+// density.
+func (f GlobalFog) Params() types.Vec4 {
+	return f.Color.Vec4(f.Density)
+}
+
+// Ensure that the fog settings are valid.
+func (f GlobalFog) Validate() error {
+	if f.Density < 0 {
+		return fmt.Errorf("scene: fog density must be >= 0; got %f", f.Density)
+	}
+	for _, c := range f.Color {
+		if c < 0 {
+			return fmt.Errorf("scene: fog color components must be >= 0; got %v", f.Color)
+		}
+	}
+	return nil
+}
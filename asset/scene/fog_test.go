@@ -0,0 +1,36 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestGlobalFog(t *testing.T) {
+	objColor := types.Vec3{1, 0.5, 0.25}
+	fogColor := types.Vec3{0.2, 0.3, 0.4}
+
+	// Zero density is a no-op
+	for _, dist := range []float32{2, 50} {
+		if got := (GlobalFog{Color: fogColor}).Apply(objColor, dist); !types.ApproxEqual(got, objColor, 1e-5) {
+			t.Fatalf("expected radiance without fog at distance %f to be %v; got %v", dist, objColor, got)
+		}
+	}
+
+	fog := GlobalFog{Density: 0.1, Color: fogColor}
+	near, far := fog.Apply(objColor, 2), fog.Apply(objColor, 50)
+	if near.Sub(objColor).Len() >= near.Sub(fogColor).Len() {
+		t.Fatalf("expected near object (%v) to be closer to its own color than the fog color", near)
+	}
+	if far.Sub(fogColor).Len() > 0.01 {
+		t.Fatalf("expected far object to fade to the fog color %v; got %v", fogColor, far)
+	}
+
+	if exp, got := (types.Vec4{0.2, 0.3, 0.4, 0.1}), fog.Params(); got != exp {
+		t.Fatalf("expected packed fog params to be %v; got %v", exp, got)
+	}
+
+	if err := (GlobalFog{Density: -1}).Validate(); err == nil {
+		t.Fatal("expected to get an error for a negative fog density")
+	}
+}
@@ -0,0 +1,63 @@
+package scene
+
+import "github.com/achilleasa/polaris/types"
+
+// Cached world-space bounds for a mesh instance. The cached bbox is reused
+// as long as the instance is not marked as dirty and both its transform and
+// the bbox of its mesh BVH root match the values used for calculating it.
+type instanceBounds struct {
+	valid     bool
+	transform types.Mat4
+	meshBBox  [2]types.Vec3
+	bbox      [2]types.Vec3
+}
+
+// A per-scene cache of mesh instance world-space bounds.
+type instanceBoundsCache struct {
+	entries []instanceBounds
+
+	// The number of bbox calculations performed by the cache.
+	recalculations int
+}
+
+// Flag the world-space bounds of the instance for recalculation. Changes to
+// the instance transform or the geometry bounds of its mesh are detected
+// automatically; this method can be used to force a recalculation after
+// modifying the instance in any other way.
+func (mi *MeshInstance) MarkDirty() {
+	mi.dirty = 1
+}
+
+// Get the world-space bbox of a mesh instance. The bbox is only recalculated
+// if the instance is dirty or its transform or mesh bounds have changed
+// since the last call.
+func (sc *Scene) instanceBBox(index uint32) [2]types.Vec3 {
+	cache := &sc.instanceBounds
+	if len(cache.entries) != len(sc.MeshInstanceList) {
+		entries := make([]instanceBounds, len(sc.MeshInstanceList))
+		copy(entries, cache.entries)
+		cache.entries = entries
+	}
+
+	mi := &sc.MeshInstanceList[index]
+	meshRoot := &sc.BvhNodeList[mi.BvhRoot]
+	meshBBox := [2]types.Vec3{meshRoot.Min, meshRoot.Max}
+
+	entry := &cache.entries[index]
+	if entry.valid && mi.dirty == 0 && entry.transform == mi.Transform && entry.meshBBox == meshBBox {
+		return entry.bbox
+	}
+
+	cache.recalculations++
+	*entry = instanceBounds{
+		valid:     true,
+		transform: mi.Transform,
+		meshBBox:  meshBBox,
+		bbox:      transformBBox(mi.Transform.Inv(), meshBBox),
+	}
+	if int(index) < len(sc.InstanceKeyframes) && len(sc.InstanceKeyframes[index]) != 0 {
+		entry.bbox = sweptKeyframeBBox(sc.InstanceKeyframes[index], meshBBox)
+	}
+	mi.dirty = 0
+	return entry.bbox
+}
@@ -0,0 +1,515 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Intersection epsilon; matches the one used by the opencl kernels.
+const intersectionEpsilon = 1e-5
+
+// The floating point precision used for CPU ray intersection tests.
+type IntersectionPrecision uint8
+
+const (
+	// Perform intersection tests using float32 arithmetic.
+	SinglePrecision IntersectionPrecision = iota
+
+	// Perform intersection tests using float64 arithmetic. Geometry is
+	// still stored using float32 values but ray transformations and
+	// intersection tests are evaluated in double precision. This is
+	// slower but avoids artifacts for geometry far from the origin.
+	DoublePrecision
+)
+
+// Scratch space for CPU ray traversals. Reusing the same RayScratch for
+// multiple rays avoids allocating traversal stacks in hot loops. A RayScratch
+// must not be shared between goroutines.
+type RayScratch struct {
+	// The precision for intersection tests. Defaults to SinglePrecision.
+	Precision IntersectionPrecision
+
+	nodeStack []int32
+	meshStack []int32
+}
+
+// Create a new RayScratch with preallocated traversal stacks.
+func NewRayScratch() *RayScratch {
+	return &RayScratch{
+		nodeStack: make([]int32, 0, 64),
+		meshStack: make([]int32, 0, 64),
+	}
+}
+
+// A ray that is being traced through the scene BVH. Depending on the selected
+// precision either the float32 or the float64 fields are populated.
+type traversalRay struct {
+	precision IntersectionPrecision
+	maxDist   float32
+
+	origin, dir, invDir    types.Vec3
+	originD, dirD, invDirD [3]float64
+}
+
+func newTraversalRay(precision IntersectionPrecision, origin, dir types.Vec3, maxDist float32) traversalRay {
+	ray := traversalRay{precision: precision, maxDist: maxDist}
+	if precision == DoublePrecision {
+		for axis := 0; axis < 3; axis++ {
+			ray.originD[axis] = float64(origin[axis])
+			ray.dirD[axis] = float64(dir[axis])
+		}
+		ray.invDirD = invRayDirD(ray.dirD)
+	} else {
+		ray.origin, ray.dir = origin, dir
+		ray.invDir = invRayDir(dir)
+	}
+	return ray
+}
+
+// Transform the ray by a matrix.
+func (r *traversalRay) transform(m types.Mat4) traversalRay {
+	out := traversalRay{precision: r.precision, maxDist: r.maxDist}
+	if r.precision == DoublePrecision {
+		for row := 0; row < 3; row++ {
+			for col := 0; col < 3; col++ {
+				out.originD[row] += float64(m[col*4+row]) * r.originD[col]
+				out.dirD[row] += float64(m[col*4+row]) * r.dirD[col]
+			}
+			out.originD[row] += float64(m[12+row])
+		}
+		out.invDirD = invRayDirD(out.dirD)
+	} else {
+		out.origin = m.Mul4x1(r.origin.Vec4(1)).Vec3()
+		out.dir = m.Mul4x1(r.dir.Vec4(0)).Vec3()
+		out.invDir = invRayDir(out.dir)
+	}
+	return out
+}
+
+// Check if the ray intersects a bbox.
+func (r *traversalRay) intersectsBBox(min, max types.Vec3) bool {
+	if r.precision == DoublePrecision {
+		return rayIntersectsBBoxD(r.originD, r.invDirD, min, max, float64(r.maxDist))
+	}
+	return rayIntersectsBBox(r.origin, r.invDir, min, max, r.maxDist)
+}
+
+// Check if the ray intersects a scene primitive within its max distance.
+func (r *traversalRay) intersectsPrimitive(sc *Scene, primIndex uint32) bool {
+	_, hit := r.primitiveHitDist(sc, primIndex)
+	return hit
+}
+
+// Calculate the distance to a scene primitive. The returned flag indicates
+// whether the primitive is hit within the ray max distance.
+func (r *traversalRay) primitiveHitDist(sc *Scene, primIndex uint32) (float32, bool) {
+	if r.precision == DoublePrecision {
+		t := sc.rayTriangleIntersectD(primIndex, r.originD, r.dirD)
+		return float32(t), t > intersectionEpsilon && t < float64(r.maxDist)
+	}
+	t := sc.rayTriangleIntersect(primIndex, r.origin, r.dir)
+	return t, t > intersectionEpsilon && t < r.maxDist
+}
+
+// Check whether the ray travels against a normal, i.e. whether it hits the
+// front face of the surface that the normal belongs to.
+func (r *traversalRay) facesNormal(normal types.Vec3) bool {
+	if r.precision == DoublePrecision {
+		return float64(normal[0])*r.dirD[0]+float64(normal[1])*r.dirD[1]+float64(normal[2])*r.dirD[2] < 0
+	}
+	return r.dir.Dot(normal) < 0
+}
+
+// Check if the ray intersects a quad within its max distance.
+func (r *traversalRay) intersectsQuad(q *Quad) bool {
+	_, hit := r.quadHitDist(q)
+	return hit
+}
+
+// Calculate the distance to a quad. The returned flag indicates whether the
+// quad is hit within the ray max distance. Quad intersections are always
+// evaluated using single precision.
+func (r *traversalRay) quadHitDist(q *Quad) (float32, bool) {
+	origin, dir := r.origin, r.dir
+	if r.precision == DoublePrecision {
+		for axis := 0; axis < 3; axis++ {
+			origin[axis] = float32(r.originD[axis])
+			dir[axis] = float32(r.dirD[axis])
+		}
+	}
+
+	t, _, _, hit := q.intersect(origin, dir)
+	return t, hit && t < r.maxDist
+}
+
+// Check whether a ray starting at origin and travelling along dir intersects
+// any scene primitive at a distance in the (0, maxDist) range. The ray
+// direction does not need to be normalized; maxDist is expressed in units of
+// the dir vector length.
+//
+// This method performs a CPU traversal of the two-level scene BVH and is
+// meant to be used by tools that operate on the scene geometry. Callers that
+// trace many rays should use OccludedWithScratch instead.
+func (sc *Scene) Occluded(origin, dir types.Vec3, maxDist float32) bool {
+	return sc.OccludedWithScratch(NewRayScratch(), origin, dir, maxDist)
+}
+
+// Check whether a ray is occluded using the traversal stacks in the supplied
+// scratch space. This method does not allocate once the scratch stacks have
+// grown to the depth of the scene BVH.
+func (sc *Scene) OccludedWithScratch(scratch *RayScratch, origin, dir types.Vec3, maxDist float32) bool {
+	if len(sc.BvhNodeList) == 0 {
+		return false
+	}
+
+	ray := newTraversalRay(scratch.Precision, origin, dir, maxDist)
+	scratch.nodeStack = append(scratch.nodeStack[:0], 0)
+	for len(scratch.nodeStack) != 0 {
+		node := &sc.BvhNodeList[scratch.nodeStack[len(scratch.nodeStack)-1]]
+		scratch.nodeStack = scratch.nodeStack[:len(scratch.nodeStack)-1]
+
+		if !ray.intersectsBBox(node.Min, node.Max) {
+			continue
+		}
+
+		if node.LData > 0 {
+			scratch.nodeStack = append(scratch.nodeStack, node.LData, node.RData)
+			continue
+		}
+
+		// Transform ray to mesh space and check the mesh BVH
+		mi := &sc.MeshInstanceList[node.GetMeshIndex()]
+		meshRay := ray.transform(mi.Transform)
+		if sc.meshOccluded(scratch, mi.BvhRoot, &meshRay) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check whether a ray in mesh space intersects any primitive of the mesh BVH
+// tree rooted at the specified node.
+func (sc *Scene) meshOccluded(scratch *RayScratch, rootNode uint32, ray *traversalRay) bool {
+	scratch.meshStack = append(scratch.meshStack[:0], int32(rootNode))
+	for len(scratch.meshStack) != 0 {
+		node := &sc.BvhNodeList[scratch.meshStack[len(scratch.meshStack)-1]]
+		scratch.meshStack = scratch.meshStack[:len(scratch.meshStack)-1]
+
+		if !ray.intersectsBBox(node.Min, node.Max) {
+			continue
+		}
+
+		if node.LData > 0 {
+			scratch.meshStack = append(scratch.meshStack, node.LData, node.RData)
+			continue
+		}
+
+		if node.IsQuadLeaf() {
+			firstQuad, count := node.GetQuads()
+			for quadIndex := firstQuad; quadIndex < firstQuad+count; quadIndex++ {
+				if ray.intersectsQuad(&sc.QuadList[quadIndex]) {
+					return true
+				}
+			}
+			continue
+		}
+
+		firstPrim, count := node.GetPrimitives()
+		for primIndex := firstPrim; primIndex < firstPrim+count; primIndex++ {
+			if ray.intersectsPrimitive(sc, primIndex) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// The closest intersection of a ray with the scene geometry.
+type RayHit struct {
+	// The hit distance in units of the ray dir vector length.
+	Dist float32
+
+	// The index of the intersected mesh instance.
+	MeshInstance uint32
+
+	// The index of the intersected primitive. If Quad is true the index
+	// refers to QuadList; otherwise it refers to a scene triangle.
+	PrimitiveIndex uint32
+	Quad           bool
+
+	// Set to true if the ray hit the side of the primitive that its
+	// geometric normal points to.
+	FrontFace bool
+}
+
+// Find the closest intersection of a ray starting at origin and travelling
+// along dir with the scene primitives at a distance in the (0, maxDist) range.
+// The returned flag indicates whether the ray hit any primitive.
+//
+// Like Occluded, this method performs a CPU traversal of the scene BVH.
+// Callers that trace many rays should use IntersectWithScratch instead.
+func (sc *Scene) Intersect(origin, dir types.Vec3, maxDist float32) (RayHit, bool) {
+	return sc.IntersectWithScratch(NewRayScratch(), origin, dir, maxDist)
+}
+
+// Find the closest ray intersection using the traversal stacks in the
+// supplied scratch space.
+func (sc *Scene) IntersectWithScratch(scratch *RayScratch, origin, dir types.Vec3, maxDist float32) (RayHit, bool) {
+	hit := RayHit{Dist: maxDist}
+	if len(sc.BvhNodeList) == 0 {
+		return hit, false
+	}
+
+	var found bool
+	ray := newTraversalRay(scratch.Precision, origin, dir, maxDist)
+	scratch.nodeStack = append(scratch.nodeStack[:0], 0)
+	for len(scratch.nodeStack) != 0 {
+		node := &sc.BvhNodeList[scratch.nodeStack[len(scratch.nodeStack)-1]]
+		scratch.nodeStack = scratch.nodeStack[:len(scratch.nodeStack)-1]
+
+		if !ray.intersectsBBox(node.Min, node.Max) {
+			continue
+		}
+
+		if node.LData > 0 {
+			scratch.nodeStack = append(scratch.nodeStack, node.LData, node.RData)
+			continue
+		}
+
+		// Transform ray to mesh space and check the mesh BVH. As the
+		// ray dir is not normalized, hit distances are the same in
+		// both spaces.
+		instance := node.GetMeshIndex()
+		mi := &sc.MeshInstanceList[instance]
+		meshRay := ray.transform(mi.Transform)
+		if sc.meshClosestHit(scratch, mi.BvhRoot, &meshRay, &hit) {
+			hit.MeshInstance = instance
+			ray.maxDist = hit.Dist
+			found = true
+		}
+	}
+
+	return hit, found
+}
+
+// Find the closest intersection of a ray in mesh space with the primitives of
+// the mesh BVH tree rooted at the specified node. If a closer hit is found,
+// meshClosestHit updates hit and the ray max distance and returns true.
+func (sc *Scene) meshClosestHit(scratch *RayScratch, rootNode uint32, ray *traversalRay, hit *RayHit) bool {
+	var found bool
+	scratch.meshStack = append(scratch.meshStack[:0], int32(rootNode))
+	for len(scratch.meshStack) != 0 {
+		node := &sc.BvhNodeList[scratch.meshStack[len(scratch.meshStack)-1]]
+		scratch.meshStack = scratch.meshStack[:len(scratch.meshStack)-1]
+
+		if !ray.intersectsBBox(node.Min, node.Max) {
+			continue
+		}
+
+		if node.LData > 0 {
+			scratch.meshStack = append(scratch.meshStack, node.LData, node.RData)
+			continue
+		}
+
+		if sc.leafClosestHit(node, ray, hit) {
+			found = true
+		}
+	}
+
+	return found
+}
+
+// Test the ray against the primitives of a BVH leaf and update hit and the ray
+// max distance if a closer intersection is found.
+func (sc *Scene) leafClosestHit(node *BvhNode, ray *traversalRay, hit *RayHit) bool {
+	var found bool
+	if node.IsQuadLeaf() {
+		firstQuad, count := node.GetQuads()
+		for quadIndex := firstQuad; quadIndex < firstQuad+count; quadIndex++ {
+			if t, ok := ray.quadHitDist(&sc.QuadList[quadIndex]); ok {
+				ray.maxDist = t
+				hit.Dist, hit.PrimitiveIndex, hit.Quad = t, quadIndex, true
+				hit.FrontFace = ray.facesNormal(sc.QuadList[quadIndex].Normal())
+				found = true
+			}
+		}
+		return found
+	}
+
+	firstPrim, count := node.GetPrimitives()
+	for primIndex := firstPrim; primIndex < firstPrim+count; primIndex++ {
+		if t, ok := ray.primitiveHitDist(sc, primIndex); ok {
+			ray.maxDist = t
+			hit.Dist, hit.PrimitiveIndex, hit.Quad = t, primIndex, false
+			_, edge01, edge02 := sc.triangleEdges(primIndex)
+			hit.FrontFace = ray.facesNormal(edge01.Cross(edge02))
+			found = true
+		}
+	}
+	return found
+}
+
+// Calculate the intersection distance between a ray and a primitive using the
+// Moller-Trumbore algorithm. Returns -1 if the ray misses the primitive.
+func (sc *Scene) rayTriangleIntersect(primIndex uint32, origin, dir types.Vec3) float32 {
+	v0, edge01, edge02 := sc.triangleEdges(primIndex)
+	return rayTriangleEdgesIntersect(v0, edge01, edge02, origin, dir)
+}
+
+// Get the first vertex and the two edges originating from it for a primitive.
+func (sc *Scene) triangleEdges(primIndex uint32) (v0, edge01, edge02 types.Vec3) {
+	v0 = sc.VertexList[3*primIndex].Vec3()
+	edge01 = sc.VertexList[3*primIndex+1].Vec3().Sub(v0)
+	edge02 = sc.VertexList[3*primIndex+2].Vec3().Sub(v0)
+	return v0, edge01, edge02
+}
+
+// Calculate the intersection distance between a ray and a triangle defined by
+// a vertex and two edges. Returns -1 if the ray misses the triangle.
+func rayTriangleEdgesIntersect(v0, edge01, edge02, origin, dir types.Vec3) float32 {
+	// Like the opencl kernels, reject rays that are (almost) parallel to
+	// the triangle plane.
+	pVec := dir.Cross(edge02)
+	det := edge01.Dot(pVec)
+	if float32(math.Abs(float64(det))) < intersectionEpsilon {
+		return -1
+	}
+	invDet := 1.0 / det
+
+	tVec := origin.Sub(v0)
+	u := tVec.Dot(pVec) * invDet
+	if u < 0 || u > 1 {
+		return -1
+	}
+
+	qVec := tVec.Cross(edge01)
+	v := dir.Dot(qVec) * invDet
+	if v < 0 || u+v > 1 {
+		return -1
+	}
+
+	return edge02.Dot(qVec) * invDet
+}
+
+// Double precision version of rayTriangleIntersect.
+func (sc *Scene) rayTriangleIntersectD(primIndex uint32, origin, dir [3]float64) float64 {
+	v0 := vec3D(sc.VertexList[3*primIndex].Vec3())
+	edge01 := subD(vec3D(sc.VertexList[3*primIndex+1].Vec3()), v0)
+	edge02 := subD(vec3D(sc.VertexList[3*primIndex+2].Vec3()), v0)
+
+	pVec := crossD(dir, edge02)
+	det := dotD(edge01, pVec)
+	if math.Abs(det) < intersectionEpsilon {
+		return -1
+	}
+	invDet := 1.0 / det
+
+	tVec := subD(origin, v0)
+	u := dotD(tVec, pVec) * invDet
+	if u < 0 || u > 1 {
+		return -1
+	}
+
+	qVec := crossD(tVec, edge01)
+	v := dotD(dir, qVec) * invDet
+	if v < 0 || u+v > 1 {
+		return -1
+	}
+
+	return dotD(edge02, qVec) * invDet
+}
+
+// Calculate the inverse of a ray direction vector.
+func invRayDir(dir types.Vec3) types.Vec3 {
+	var invDir types.Vec3
+	for axis := 0; axis < 3; axis++ {
+		if dir[axis] == 0 {
+			invDir[axis] = math.MaxFloat32
+		} else {
+			invDir[axis] = 1.0 / dir[axis]
+		}
+	}
+	return invDir
+}
+
+// Double precision version of invRayDir.
+func invRayDirD(dir [3]float64) [3]float64 {
+	var invDir [3]float64
+	for axis := 0; axis < 3; axis++ {
+		if dir[axis] == 0 {
+			invDir[axis] = math.MaxFloat64
+		} else {
+			invDir[axis] = 1.0 / dir[axis]
+		}
+	}
+	return invDir
+}
+
+// Check if a ray intersects a bbox using the slab test.
+func rayIntersectsBBox(origin, invDir, min, max types.Vec3, maxDist float32) bool {
+	tMin, tMax := float32(0), maxDist
+	for axis := 0; axis < 3; axis++ {
+		t0 := (min[axis] - origin[axis]) * invDir[axis]
+		t1 := (max[axis] - origin[axis]) * invDir[axis]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMin > tMax {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Double precision version of rayIntersectsBBox.
+func rayIntersectsBBoxD(origin, invDir [3]float64, min, max types.Vec3, maxDist float64) bool {
+	tMin, tMax := 0.0, maxDist
+	for axis := 0; axis < 3; axis++ {
+		t0 := (float64(min[axis]) - origin[axis]) * invDir[axis]
+		t1 := (float64(max[axis]) - origin[axis]) * invDir[axis]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMin > tMax {
+			return false
+		}
+	}
+
+	return true
+}
+
+func vec3D(v types.Vec3) [3]float64 {
+	return [3]float64{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+func subD(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dotD(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func crossD(a, b [3]float64) [3]float64 {
+	return [3]float64{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
@@ -0,0 +1,148 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestOccludedPrecision(t *testing.T) {
+	// A triangle placed far away from the origin
+	center := types.Vec3{1e5, 5e4, -1e5}
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()}},
+		BvhNodeList:      make([]BvhNode, 2),
+		VertexList: []types.Vec4{
+			center.Add(types.Vec3{-1, -1, 0.3}).Vec4(1),
+			center.Add(types.Vec3{1, -0.7, -0.2}).Vec4(1),
+			center.Add(types.Vec3{0.1, 1, 0.5}).Vec4(1),
+		},
+	}
+	bbox := [2]types.Vec3{center.Sub(types.Vec3{2, 2, 2}), center.Add(types.Vec3{2, 2, 2})}
+	sc.BvhNodeList[0].SetBBox(bbox)
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox(bbox)
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+
+	// This ray passes just inside the edge between the first two vertices.
+	// Due to catastrophic cancellation the single precision intersection
+	// test misses the triangle.
+	origin := types.Vec3{0.3, 0.2, 0.1}
+	dir := types.Vec3{0.6666664, 0.3333277, -0.6666698}
+
+	type spec struct {
+		precision IntersectionPrecision
+		expHit    bool
+	}
+	specs := []spec{
+		spec{SinglePrecision, false},
+		spec{DoublePrecision, true},
+	}
+	for index, s := range specs {
+		scratch := NewRayScratch()
+		scratch.Precision = s.precision
+		if hit := sc.OccludedWithScratch(scratch, origin, dir, 4e5); hit != s.expHit {
+			t.Fatalf("[spec %d] expected OccludedWithScratch() to return %t; got %t", index, s.expHit, hit)
+		}
+	}
+
+	// Rays that clearly miss or hit the triangle should be unaffected by the
+	// selected precision
+	target := center.Add(types.Vec3{0.03, -0.23, 0.2})
+	hitDir := target.Sub(origin).Normalize()
+	missDir := center.Add(types.Vec3{0, 5, 0}).Sub(origin).Normalize()
+	for _, precision := range []IntersectionPrecision{SinglePrecision, DoublePrecision} {
+		scratch := NewRayScratch()
+		scratch.Precision = precision
+		if !sc.OccludedWithScratch(scratch, origin, hitDir, 4e5) {
+			t.Fatalf("[precision %d] expected ray towards the triangle center to hit", precision)
+		}
+		if sc.OccludedWithScratch(scratch, origin, missDir, 4e5) {
+			t.Fatalf("[precision %d] expected ray away from the triangle to miss", precision)
+		}
+	}
+}
+
+func TestIntersectFrontFace(t *testing.T) {
+	// A triangle on the XY plane with its geometric normal pointing to +Z
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()}},
+		BvhNodeList:      make([]BvhNode, 2),
+		VertexList: []types.Vec4{
+			{-1, -1, 0, 1},
+			{1, -1, 0, 1},
+			{0, 1, 0, 1},
+		},
+	}
+	bbox := [2]types.Vec3{{-1, -1, -0.1}, {1, 1, 0.1}}
+	sc.BvhNodeList[0].SetBBox(bbox)
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox(bbox)
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+
+	type spec struct {
+		origin, dir  types.Vec3
+		expFrontFace bool
+	}
+	specs := []spec{
+		spec{types.Vec3{0, 0, 5}, types.Vec3{0, 0, -1}, true},
+		spec{types.Vec3{0, 0, -5}, types.Vec3{0, 0, 1}, false},
+		spec{types.Vec3{0.5, 0, 5}, types.Vec3{-0.1, 0, -1}.Normalize(), true},
+		spec{types.Vec3{0.5, 0, -5}, types.Vec3{-0.1, 0, 1}.Normalize(), false},
+	}
+	for _, precision := range []IntersectionPrecision{SinglePrecision, DoublePrecision} {
+		scratch := NewRayScratch()
+		scratch.Precision = precision
+		for index, s := range specs {
+			hit, found := sc.IntersectWithScratch(scratch, s.origin, s.dir, 100)
+			if !found {
+				t.Fatalf("[precision %d, spec %d] expected ray to hit the triangle", precision, index)
+			}
+			if hit.FrontFace != s.expFrontFace {
+				t.Fatalf("[precision %d, spec %d] expected FrontFace to be %t; got %t", precision, index, s.expFrontFace, hit.FrontFace)
+			}
+		}
+	}
+}
+
+func TestIntersectGrazingRay(t *testing.T) {
+	// A triangle on the XY plane. For rays with a unit length direction,
+	// the ray/triangle determinant equals the z component of the direction.
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()}},
+		BvhNodeList:      make([]BvhNode, 2),
+		VertexList: []types.Vec4{
+			{0, 0, 0, 1},
+			{1, 0, 0, 1},
+			{0, 1, 0, 1},
+		},
+	}
+	bbox := [2]types.Vec3{{0, 0, -0.1}, {1, 1, 0.1}}
+	sc.BvhNodeList[0].SetBBox(bbox)
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox(bbox)
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+
+	type spec struct {
+		dirZ   float32
+		expHit bool
+	}
+	specs := []spec{
+		// Determinants below the opencl kernel epsilon are rejected
+		spec{-5e-6, false},
+		spec{-2e-5, true},
+		spec{-0.5, true},
+	}
+	target := types.Vec3{0.25, 0.25, 0}
+	for _, precision := range []IntersectionPrecision{SinglePrecision, DoublePrecision} {
+		scratch := NewRayScratch()
+		scratch.Precision = precision
+		for index, s := range specs {
+			dir := types.Vec3{1, 0, s.dirZ}.Normalize()
+			origin := target.Sub(dir.Mul(0.2))
+			if _, found := sc.IntersectWithScratch(scratch, origin, dir, 100); found != s.expHit {
+				t.Fatalf("[precision %d, spec %d] expected IntersectWithScratch() to return %t; got %t", precision, index, s.expHit, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A keyframe for the rigid motion of a mesh instance.
+type TransformKeyframe struct {
+	// The keyframe time within the shutter interval [0, 1].
+	Time float32
+
+	// The mesh to world transform at the keyframe time. The transform may
+	// only contain a rotation and a translation.
+	Transform types.Mat4
+}
+
+// Set the rigid motion keyframes for a mesh instance. The instance transform
+// is sampled by interpolating between the keyframes that enclose the ray
+// time; rotations are interpolated using slerp and translations using lerp.
+// Before the first and after the last keyframe the transform is held
+// constant. Passing an empty keyframe list makes the instance static again.
+//
+// Keyframe times must be strictly increasing. The instance Transform is set
+// to its pose at the beginning of the shutter interval and its world-space
+// bounds enclose the instance over the entire interval. As the opencl kernels
+// do not sample ray times, the renderer rejects scenes with keyframed
+// instances.
+func (sc *Scene) SetInstanceKeyframes(instanceIndex uint32, keys []TransformKeyframe) error {
+	if int(instanceIndex) >= len(sc.MeshInstanceList) {
+		return fmt.Errorf("scene: invalid mesh instance index %d", instanceIndex)
+	}
+	if err := checkKeyframes(keys); err != nil {
+		return err
+	}
+
+	for int(instanceIndex) >= len(sc.InstanceKeyframes) {
+		sc.InstanceKeyframes = append(sc.InstanceKeyframes, nil)
+	}
+	sc.InstanceKeyframes[instanceIndex] = keys
+
+	mi := &sc.MeshInstanceList[instanceIndex]
+	if len(keys) != 0 {
+		mi.Transform = SampleKeyframes(keys, 0).Inv()
+	}
+	mi.MarkDirty()
+	return nil
+}
+
+// Get the transform of a mesh instance at the specified time. Like the
+// MeshInstance Transform field, the returned matrix transforms from world
+// space to mesh space.
+func (sc *Scene) InstanceTransformAt(instanceIndex uint32, time float32) types.Mat4 {
+	if int(instanceIndex) < len(sc.InstanceKeyframes) && len(sc.InstanceKeyframes[instanceIndex]) != 0 {
+		return SampleKeyframes(sc.InstanceKeyframes[instanceIndex], time).Inv()
+	}
+	return sc.MeshInstanceList[instanceIndex].Transform
+}
+
+// Sample the mesh to world transform defined by a list of keyframes at the
+// specified time.
+func SampleKeyframes(keys []TransformKeyframe, time float32) types.Mat4 {
+	if time <= keys[0].Time {
+		return keys[0].Transform
+	}
+
+	last := len(keys) - 1
+	if time >= keys[last].Time {
+		return keys[last].Transform
+	}
+
+	next := 1
+	for keys[next].Time < time {
+		next++
+	}
+	k0, k1 := &keys[next-1], &keys[next]
+	return interpolateKeyframes(k0, k1, (time-k0.Time)/(k1.Time-k0.Time))
+}
+
+// Interpolate between two keyframes where t is in the [0, 1] range.
+func interpolateKeyframes(k0, k1 *TransformKeyframe, t float32) types.Mat4 {
+	rot := types.QuatSlerp(types.QuatFromMat4(k0.Transform), types.QuatFromMat4(k1.Transform), t)
+	t0, t1 := k0.Transform.Col(3).Vec3(), k1.Transform.Col(3).Vec3()
+	return types.Translate4(t0.Add(t1.Sub(t0).Mul(t))).Mul4(rot.Mat4())
+}
+
+// Calculate a box that encloses a mesh space bbox while it moves along a list
+// of keyframes. Each keyframe segment is bounded by the Minkowski sum of the
+// box enclosing the rotated bbox corners and the box enclosing the segment
+// translations. The rotation is sampled in steps of at most 1/16th of a turn
+// and padded by the maximum distance between a corner arc and its chords.
+func sweptKeyframeBBox(keys []TransformKeyframe, bbox [2]types.Vec3) [2]types.Vec3 {
+	var corners [8]types.Vec3
+	var maxCornerDist float32
+	for corner := range corners {
+		corners[corner] = types.Vec3{bbox[corner&1][0], bbox[(corner>>1)&1][1], bbox[(corner>>2)&1][2]}
+		maxCornerDist = maxFloat32(maxCornerDist, corners[corner].Len())
+	}
+
+	out := emptyBBox()
+	for index := range keys {
+		k0, k1 := &keys[index], &keys[index]
+		if index+1 < len(keys) {
+			k1 = &keys[index+1]
+		}
+
+		q0, q1 := types.QuatFromMat4(k0.Transform), types.QuatFromMat4(k1.Transform)
+		dot := math.Abs(float64(q0.Dot(q1)))
+		theta := 2 * math.Acos(math.Min(dot, 1))
+		steps := int(math.Ceil(theta / (math.Pi / 8)))
+		if steps < 1 {
+			steps = 1
+		}
+
+		rotBBox := emptyBBox()
+		for step := 0; step <= steps; step++ {
+			rot := types.QuatSlerp(q0, q1, float32(step)/float32(steps))
+			for _, p := range corners {
+				q := rot.Rotate(p)
+				rotBBox[0] = types.MinVec3(rotBBox[0], q)
+				rotBBox[1] = types.MaxVec3(rotBBox[1], q)
+			}
+		}
+		pad := maxCornerDist * float32(1-math.Cos(theta/float64(steps)/2))
+		padVec := types.Vec3{pad, pad, pad}
+
+		t0, t1 := k0.Transform.Col(3).Vec3(), k1.Transform.Col(3).Vec3()
+		out[0] = types.MinVec3(out[0], rotBBox[0].Sub(padVec).Add(types.MinVec3(t0, t1)))
+		out[1] = types.MaxVec3(out[1], rotBBox[1].Add(padVec).Add(types.MaxVec3(t0, t1)))
+	}
+	return out
+}
+
+// Check that keyframe times are valid and that keyframe transforms are rigid.
+func checkKeyframes(keys []TransformKeyframe) error {
+	for index := range keys {
+		key := &keys[index]
+		if !(key.Time >= 0 && key.Time <= 1) {
+			return fmt.Errorf("scene: keyframe %d time must be in the [0, 1] range; got %f", index, key.Time)
+		}
+		if index > 0 && key.Time <= keys[index-1].Time {
+			return fmt.Errorf("scene: keyframe %d time must be greater than the time of the previous keyframe", index)
+		}
+
+		m := &key.Transform
+		cols := [3]types.Vec3{m.Col(0).Vec3(), m.Col(1).Vec3(), m.Col(2).Vec3()}
+		rigid := m[3] == 0 && m[7] == 0 && m[11] == 0 && m[15] == 1 &&
+			cols[0].Cross(cols[1]).Sub(cols[2]).Len() < 1e-3
+		for _, col := range cols {
+			rigid = rigid && math.Abs(float64(col.Len()-1)) < 1e-3
+		}
+		if !rigid {
+			return fmt.Errorf("scene: keyframe %d transform must only contain a rotation and a translation", index)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSampleKeyframes(t *testing.T) {
+	yAxis := types.Vec3{0, 1, 0}
+	keyframe := func(time, angle float32, translation types.Vec3) TransformKeyframe {
+		return TransformKeyframe{
+			Time:      time,
+			Transform: types.Translate4(translation).Mul4(types.QuatFromAxisAngle(yAxis, angle).Mat4()),
+		}
+	}
+
+	// An accelerating translation combined with a rotation around the Y axis
+	keys := []TransformKeyframe{
+		keyframe(0, 0, types.Vec3{0, 0, 0}),
+		keyframe(0.5, math.Pi/2, types.Vec3{1, 0, 0}),
+		keyframe(1, math.Pi, types.Vec3{1, 3, 0}),
+	}
+
+	type spec struct {
+		time           float32
+		expAngle       float32
+		expTranslation types.Vec3
+	}
+	specs := []spec{
+		spec{0, 0, types.Vec3{0, 0, 0}},
+		spec{0.25, math.Pi / 4, types.Vec3{0.5, 0, 0}},
+		spec{0.5, math.Pi / 2, types.Vec3{1, 0, 0}},
+		spec{0.6, 0.6 * math.Pi, types.Vec3{1, 0.6, 0}},
+		spec{0.75, 3 * math.Pi / 4, types.Vec3{1, 1.5, 0}},
+		spec{1, math.Pi, types.Vec3{1, 3, 0}},
+		// Times outside the keyframe range are clamped
+		spec{-1, 0, types.Vec3{0, 0, 0}},
+		spec{2, math.Pi, types.Vec3{1, 3, 0}},
+	}
+
+	for index, s := range specs {
+		got := SampleKeyframes(keys, s.time)
+		exp := types.Translate4(s.expTranslation).Mul4(types.QuatFromAxisAngle(yAxis, s.expAngle).Mat4())
+		for _, p := range []types.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 1}} {
+			gotP := got.Mul4x1(p.Vec4(1)).Vec3()
+			expP := exp.Mul4x1(p.Vec4(1)).Vec3()
+			if !types.ApproxEqual(gotP, expP, 1e-4) {
+				t.Errorf("[spec %d] expected point %v to be transformed to %v at t=%f; got %v", index, p, expP, s.time, gotP)
+			}
+		}
+	}
+}
+
+func TestSetInstanceKeyframes(t *testing.T) {
+	sc := &Scene{
+		BvhNodeList:      make([]BvhNode, 2),
+		MeshInstanceList: []MeshInstance{{BvhRoot: 1, Transform: types.Ident4()}},
+	}
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox([2]types.Vec3{{-1, -1, -1}, {1, 1, 1}})
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+
+	type spec struct {
+		instanceIndex uint32
+		keys          []TransformKeyframe
+		expErr        bool
+	}
+	specs := []spec{
+		spec{1, []TransformKeyframe{{0, types.Ident4()}}, true},
+		spec{0, []TransformKeyframe{{-0.5, types.Ident4()}}, true},
+		spec{0, []TransformKeyframe{{0.5, types.Ident4()}, {0.5, types.Ident4()}}, true},
+		spec{0, []TransformKeyframe{{0, types.Scale4(types.Vec3{2, 2, 2})}}, true},
+		spec{0, []TransformKeyframe{{0, types.Ident4()}, {1, types.QuatFromAxisAngle(types.Vec3{0, 0, 1}, 1).Mat4()}}, false},
+	}
+	for index, s := range specs {
+		err := sc.SetInstanceKeyframes(s.instanceIndex, s.keys)
+		if s.expErr && err == nil {
+			t.Errorf("[spec %d] expected an error", index)
+		} else if !s.expErr && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+
+	// Move the unit box along a curved path while spinning it around the
+	// Y axis and ensure that the swept bounds enclose it at all times.
+	keys := []TransformKeyframe{
+		{0, types.Ident4()},
+		{0.3, types.Translate4(types.Vec3{4, 0, 0}).Mul4(types.QuatFromAxisAngle(types.Vec3{0, 1, 0}, 2).Mat4())},
+		{1, types.Translate4(types.Vec3{4, 0, 6}).Mul4(types.QuatFromAxisAngle(types.Vec3{0, 1, 0}, 3).Mat4())},
+	}
+	if err := sc.SetInstanceKeyframes(0, keys); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	sc.RebuildTopLevel()
+	bbox := sc.instanceBBox(0)
+	if bbox != [2]types.Vec3{sc.BvhNodeList[0].Min, sc.BvhNodeList[0].Max} {
+		t.Fatalf("expected top-level bounds to match the swept instance bounds %v", bbox)
+	}
+	for step := 0; step <= 100; step++ {
+		time := float32(step) / 100
+		world := sc.InstanceTransformAt(0, time).Inv()
+		for corner := 0; corner < 8; corner++ {
+			p := types.Vec3{float32(corner&1)*2 - 1, float32((corner>>1)&1)*2 - 1, float32((corner>>2)&1)*2 - 1}
+			q := world.Mul4x1(p.Vec4(1)).Vec3()
+			for axis := 0; axis < 3; axis++ {
+				if q[axis] < bbox[0][axis]-1e-4 || q[axis] > bbox[1][axis]+1e-4 {
+					t.Fatalf("expected swept bounds %v to enclose corner %v at t=%f; got %v", bbox, p, time, q)
+				}
+			}
+		}
+	}
+
+	// Clearing the keyframes makes the instance static again
+	if err := sc.SetInstanceKeyframes(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := sc.InstanceTransformAt(0, 1); got != sc.MeshInstanceList[0].Transform {
+		t.Fatalf("expected static instance transform to be %v; got %v", sc.MeshInstanceList[0].Transform, got)
+	}
+}
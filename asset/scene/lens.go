@@ -0,0 +1,39 @@
+package scene
+
+import "github.com/achilleasa/polaris/types"
+
+// The max number of aperture blades. Apertures with more blades are treated
+// as circular.
+const MaxApertureBlades = 16
+
+// Thin lens camera settings. Rays are traced from a point on the lens
+// aperture towards the point where the pinhole camera ray crosses the focal
+// plane so only geometry at the focus distance appears sharp.
+type CameraLens struct {
+	// The aperture radius in world units. A zero radius disables the thin
+	// lens model and results in a pinhole camera.
+	ApertureRadius float32
+
+	// The distance from the camera to the focal plane.
+	FocusDistance float32
+
+	// The number of aperture blades for generating polygonal bokeh and
+	// the aperture rotation in radians. Apertures with less than 3 or
+	// more than MaxApertureBlades blades are circular.
+	ApertureBlades   uint32
+	ApertureRotation float32
+}
+
+// Check whether the lens generates depth of field effects.
+func (l CameraLens) Enabled() bool {
+	return l.ApertureRadius > 0 && l.FocusDistance > 0
+}
+
+// Get the world space basis (right, up and forward axes) that is used for
+// positioning points on the camera lens.
+func (c *Camera) LensBasis() (right, up, forward types.Vec3) {
+	forward = c.LookAt.Sub(c.Position).Normalize()
+	right = forward.Cross(c.Up).Normalize()
+	up = right.Cross(forward)
+	return right, up, forward
+}
@@ -0,0 +1,26 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestCameraLensBasis(t *testing.T) {
+	cam := NewCamera(45)
+	cam.Position = types.Vec3{0, 0, 5}
+	cam.LookAt = types.Vec3{0, 0, 0}
+	cam.Up = types.Vec3{0, 1, 0}
+
+	right, up, forward := cam.LensBasis()
+	exp := [3]types.Vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, -1}}
+	for index, got := range [3]types.Vec3{right, up, forward} {
+		if got.Sub(exp[index]).Len() > 1e-5 {
+			t.Errorf("[axis %d] expected %v; got %v", index, exp[index], got)
+		}
+	}
+
+	if (CameraLens{ApertureRadius: 0.1}).Enabled() || !(CameraLens{ApertureRadius: 0.1, FocusDistance: 5}).Enabled() {
+		t.Fatal("expected lens to be enabled only if both the aperture radius and focus distance are positive")
+	}
+}
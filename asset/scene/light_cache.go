@@ -0,0 +1,89 @@
+package scene
+
+// Cached light sampling data. Building the environment light importance
+// sampler requires a full pass over the environment texture so the sampler
+// is cached and reused as long as the cache is not invalidated and the scene
+// lights match the ones used for building it. Scenes derived by copying the
+// scene (e.g. via Editor commits) share the cached data until their lights
+// change.
+type lightCache struct {
+	valid bool
+
+	// The emissive primitives and environment texture the cached data
+	// was built from.
+	emissives   []EmissivePrimitive
+	envTexIndex int32
+	envTexMeta  TextureMetadata
+	envTexBytes int
+
+	envSampler *EnvironmentSampler
+
+	// The number of times the cached data was built.
+	rebuilds int
+}
+
+// Flag the cached light sampling data for rebuilding. Changes to the emissive
+// primitives (e.g. moving an emissive instance) and to the environment light
+// texture assignment are detected automatically; this method can be used to
+// force a rebuild after modifying the light data in any other way (e.g.
+// editing the environment texture data in place).
+func (sc *Scene) InvalidateLights() {
+	sc.lightCache.valid = false
+}
+
+// Get the light sampling data, rebuilding it only if the cache was
+// invalidated or the scene lights have changed since it was last built.
+func (sc *Scene) cachedLights() (*lightCache, error) {
+	cache := &sc.lightCache
+	envTexIndex, envTexMeta := sc.environmentTexture()
+	if cache.valid && cache.envTexIndex == envTexIndex && cache.envTexMeta == envTexMeta &&
+		cache.envTexBytes == len(sc.TextureData) && sameEmissives(cache.emissives, sc.EmissivePrimitives) {
+		return cache, nil
+	}
+
+	envSampler, err := sc.buildEnvironmentSampler()
+	if err != nil {
+		return nil, err
+	}
+
+	*cache = lightCache{
+		valid:       true,
+		emissives:   append([]EmissivePrimitive(nil), sc.EmissivePrimitives...),
+		envTexIndex: envTexIndex,
+		envTexMeta:  envTexMeta,
+		envTexBytes: len(sc.TextureData),
+		envSampler:  envSampler,
+		rebuilds:    cache.rebuilds + 1,
+	}
+	return cache, nil
+}
+
+// Get the index and metadata of the texture used by the scene environment
+// light. Returns -1 if the scene does not define a textured environment
+// light.
+func (sc *Scene) environmentTexture() (int32, TextureMetadata) {
+	for _, em := range sc.EmissivePrimitives {
+		if em.Type != EnvironmentLight || int(em.MaterialNodeIndex) >= len(sc.MaterialNodeList) {
+			continue
+		}
+
+		texIndex := sc.MaterialNodeList[em.MaterialNodeIndex].Union1[3]
+		if texIndex < 0 || int(texIndex) >= len(sc.TextureMetadata) {
+			return -1, TextureMetadata{}
+		}
+		return texIndex, sc.TextureMetadata[texIndex]
+	}
+	return -1, TextureMetadata{}
+}
+
+func sameEmissives(a, b []EmissivePrimitive) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/texure"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestLightCache(t *testing.T) {
+	// A plane with an emissive primitive lit by a textured environment
+	sc := makePlaneTestScene(1)
+	sc.TextureMetadata = []TextureMetadata{{Format: texture.Luminance8, Width: 4, Height: 2}}
+	sc.TextureData = []byte{0, 64, 128, 255, 255, 128, 64, 0}
+	sc.MaterialNodeList = []MaterialNode{
+		{Union1: [4]int32{0, -1, -1, 0}},
+		{Union1: [4]int32{0, -1, -1, -1}},
+	}
+	sc.EmissivePrimitives = []EmissivePrimitive{
+		{Type: EnvironmentLight, MaterialNodeIndex: 0, Transform: types.Ident4()},
+		{Type: AreaLight, MaterialNodeIndex: 1, PrimitiveIndex: 0, Transform: types.Ident4()},
+	}
+
+	sampler, err := sc.EnvironmentSampler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sampler == nil {
+		t.Fatal("expected to get an environment sampler")
+	}
+
+	editor := NewEditor(sc)
+	type spec struct {
+		descr       string
+		edit        func() *Scene
+		expRebuilds int
+	}
+	specs := []spec{
+		spec{
+			"unchanged scene",
+			func() *Scene { return editor.Scene() },
+			1,
+		},
+		spec{
+			"camera only change",
+			func() *Scene {
+				cur := editor.Scene()
+				cur.Camera = NewCamera(45)
+				return cur
+			},
+			1,
+		},
+		spec{
+			"non-emissive material change",
+			func() *Scene {
+				if err := editor.SetUVTransform(1, UVTransform{Scale: types.Vec2{2, 2}}); err != nil {
+					t.Fatal(err)
+				}
+				next, _, err := editor.Commit()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return next
+			},
+			1,
+		},
+		spec{
+			"emissive instance moved",
+			func() *Scene {
+				if err := editor.SetInstanceTransform(0, types.Translate4(types.Vec3{1, 0, 0}).Inv()); err != nil {
+					t.Fatal(err)
+				}
+				next, _, err := editor.Commit()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return next
+			},
+			2,
+		},
+		spec{
+			"explicit invalidation",
+			func() *Scene {
+				cur := editor.Scene()
+				cur.InvalidateLights()
+				return cur
+			},
+			3,
+		},
+	}
+
+	for index, s := range specs {
+		frame := s.edit()
+
+		// Each frame uploads the scene to multiple tracers
+		for upload := 0; upload < 3; upload++ {
+			if _, err = frame.EnvironmentSampler(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := frame.lightCache.rebuilds; got != s.expRebuilds {
+			t.Errorf("[spec %d] expected light data to be built %d times after %s; got %d", index, s.expRebuilds, s.descr, got)
+		}
+	}
+
+	// The original scene keeps using its own cached data
+	if cached, _ := sc.EnvironmentSampler(); cached != sampler || sc.lightCache.rebuilds != 1 {
+		t.Fatalf("expected the original scene to reuse its cached sampler; got %d rebuilds", sc.lightCache.rebuilds)
+	}
+}
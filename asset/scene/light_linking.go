@@ -0,0 +1,206 @@
+package scene
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Light linking restricts the mesh instances that a light (emissive
+// primitive) illuminates. Lights without a link illuminate all instances.
+//
+// Links are compiled into a LightLinkTable: a bitset matrix with one row per
+// emissive and one bit per mesh instance. Checking whether a light affects a
+// shading point is a single bit test: bit (instance % 32) of word
+// Mask[emissive*Stride + instance/32]. The opencl tracer uses the same table.
+//
+// Links also apply to emissive surfaces reached by BSDF sampled rays. As the
+// tracer only knows the mesh instance and triangle of such hits, the table
+// includes a list of emitters sorted by instance and primitive index that
+// is binary searched to recover the row of the hit light.
+type LightLinkMode uint8
+
+// Supported light link modes.
+const (
+	// The light only illuminates the listed instances.
+	LightLinkInclude LightLinkMode = iota
+
+	// The light illuminates all instances except the listed ones.
+	LightLinkExclude
+)
+
+// A light link for an emissive primitive.
+type LightLink struct {
+	// The index of the linked emissive in EmissivePrimitives.
+	EmissiveIndex uint32
+
+	// Specifies whether Instances is an include or an exclude set.
+	Mode LightLinkMode
+
+	// The mesh instance indices in the link set.
+	Instances []uint32
+}
+
+// A bitset matrix for looking up whether a light illuminates a mesh instance.
+type LightLinkTable struct {
+	// One row per emissive; each row stores one bit per mesh instance.
+	Mask []uint32
+
+	// The number of uint32 words per row. If 0, the scene does not define
+	// any links and all lights illuminate all instances.
+	Stride uint32
+
+	// The area lights of the scene sorted by mesh instance and primitive
+	// index. It is only populated if Stride is not 0.
+	Emitters []LightLinkEmitter
+
+	// The index of the environment light emissive or -1 if the scene
+	// has no environment light or does not define any links.
+	EnvironmentIndex int32
+}
+
+// Maps the emissive primitive of a mesh instance to its light link table row.
+type LightLinkEmitter struct {
+	MeshInstance  uint32
+	Primitive     uint32
+	EmissiveIndex uint32
+	padding       uint32
+}
+
+// Link an emissive to a set of mesh instances, replacing any existing link
+// for the same emissive.
+func (sc *Scene) SetLightLink(link LightLink) error {
+	if err := sc.checkLightLink(&link); err != nil {
+		return err
+	}
+
+	for index := range sc.LightLinks {
+		if sc.LightLinks[index].EmissiveIndex == link.EmissiveIndex {
+			sc.LightLinks[index] = link
+			return nil
+		}
+	}
+	sc.LightLinks = append(sc.LightLinks, link)
+	return nil
+}
+
+// Remove the light link for an emissive so that it illuminates all instances.
+func (sc *Scene) ClearLightLink(emissiveIndex uint32) {
+	for index := range sc.LightLinks {
+		if sc.LightLinks[index].EmissiveIndex == emissiveIndex {
+			sc.LightLinks = append(sc.LightLinks[:index], sc.LightLinks[index+1:]...)
+			return
+		}
+	}
+}
+
+// Build the light link lookup table for the scene lights.
+func (sc *Scene) LightLinkTable() LightLinkTable {
+	if len(sc.LightLinks) == 0 {
+		return LightLinkTable{EnvironmentIndex: -1}
+	}
+
+	table := LightLinkTable{
+		Stride:           uint32(len(sc.MeshInstanceList)+31) / 32,
+		EnvironmentIndex: -1,
+	}
+	if table.Stride == 0 {
+		table.Stride = 1
+	}
+	table.Mask = make([]uint32, uint32(len(sc.EmissivePrimitives))*table.Stride)
+	for index := range table.Mask {
+		table.Mask[index] = ^uint32(0)
+	}
+
+	for _, link := range sc.LightLinks {
+		if int(link.EmissiveIndex) >= len(sc.EmissivePrimitives) {
+			continue
+		}
+
+		row := table.Mask[link.EmissiveIndex*table.Stride : (link.EmissiveIndex+1)*table.Stride]
+		if link.Mode == LightLinkInclude {
+			for word := range row {
+				row[word] = 0
+			}
+		}
+		for _, instance := range link.Instances {
+			if instance/32 >= table.Stride {
+				continue
+			}
+			if link.Mode == LightLinkInclude {
+				row[instance/32] |= 1 << (instance % 32)
+			} else {
+				row[instance/32] &^= 1 << (instance % 32)
+			}
+		}
+	}
+
+	table.Emitters = sc.lightLinkEmitters()
+	for index, em := range sc.EmissivePrimitives {
+		if em.Type == EnvironmentLight {
+			table.EnvironmentIndex = int32(index)
+		}
+	}
+	return table
+}
+
+// Find the mesh instance that owns each area light and return the emitter
+// list sorted by instance and primitive index. Area lights store the
+// transform of the instance that owns them so they are matched against
+// instances with the same transform whose mesh contains the light primitive.
+func (sc *Scene) lightLinkEmitters() []LightLinkEmitter {
+	emitters := make([]LightLinkEmitter, 0)
+	for instIndex, mi := range sc.MeshInstanceList {
+		firstPrim, numPrims, err := meshPrimitives(sc, mi.MeshIndex)
+		if err != nil {
+			continue
+		}
+
+		for emIndex, em := range sc.EmissivePrimitives {
+			if em.Type != AreaLight || em.Transform != mi.Transform || em.PrimitiveIndex < firstPrim || em.PrimitiveIndex >= firstPrim+numPrims {
+				continue
+			}
+			emitters = append(emitters, LightLinkEmitter{
+				MeshInstance:  uint32(instIndex),
+				Primitive:     em.PrimitiveIndex,
+				EmissiveIndex: uint32(emIndex),
+			})
+		}
+	}
+
+	sort.Slice(emitters, func(i, j int) bool {
+		if emitters[i].MeshInstance != emitters[j].MeshInstance {
+			return emitters[i].MeshInstance < emitters[j].MeshInstance
+		}
+		return emitters[i].Primitive < emitters[j].Primitive
+	})
+	return emitters
+}
+
+// Check whether an emissive illuminates a mesh instance.
+func (t *LightLinkTable) Affects(emissiveIndex, instanceIndex uint32) bool {
+	if t.Stride == 0 {
+		return true
+	}
+
+	word := emissiveIndex*t.Stride + instanceIndex/32
+	if instanceIndex/32 >= t.Stride || int(word) >= len(t.Mask) {
+		return true
+	}
+	return t.Mask[word]&(1<<(instanceIndex%32)) != 0
+}
+
+// Ensure that a light link references a valid emissive and valid instances.
+func (sc *Scene) checkLightLink(link *LightLink) error {
+	if int(link.EmissiveIndex) >= len(sc.EmissivePrimitives) {
+		return fmt.Errorf("scene: light link references invalid emissive index %d", link.EmissiveIndex)
+	}
+	if link.Mode != LightLinkInclude && link.Mode != LightLinkExclude {
+		return fmt.Errorf("scene: light link for emissive %d uses unsupported mode %d", link.EmissiveIndex, link.Mode)
+	}
+	for _, instance := range link.Instances {
+		if int(instance) >= len(sc.MeshInstanceList) {
+			return fmt.Errorf("scene: light link for emissive %d references invalid mesh instance %d", link.EmissiveIndex, instance)
+		}
+	}
+	return nil
+}
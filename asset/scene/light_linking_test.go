@@ -0,0 +1,121 @@
+package scene
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestLightLinking(t *testing.T) {
+	// Two instances of a diffuse plane at z=0 placed side by side and lit
+	// by an emissive ceiling at z=2.
+	sc := makePlaneTestScene(1)
+	sc.VertexList = append(sc.VertexList,
+		types.Vec4{-4, -4, 2, 1}, types.Vec4{4, 4, 2, 1}, types.Vec4{4, -4, 2, 1},
+		types.Vec4{-4, -4, 2, 1}, types.Vec4{-4, 4, 2, 1}, types.Vec4{4, 4, 2, 1},
+	)
+	sc.BvhNodeList = make([]BvhNode, 1)
+	planeRoot := buildTestMeshBvh(sc, 0, 2)
+	lightRoot := buildTestMeshBvh(sc, 2, 2)
+	sc.MeshInstanceList = []MeshInstance{
+		{MeshIndex: 0, BvhRoot: planeRoot, Transform: types.Translate4(types.Vec3{1.5, 0, 0})},
+		{MeshIndex: 0, BvhRoot: planeRoot, Transform: types.Translate4(types.Vec3{-1.5, 0, 0})},
+		{MeshIndex: 1, BvhRoot: lightRoot, Transform: types.Ident4()},
+	}
+	sc.RebuildTopLevel()
+
+	sc.MaterialNodeList = []MaterialNode{
+		{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{0.5, 0.5, 0.5, 0}},
+		{Union1: [4]int32{int32(material.BxdfEmissive), -1, -1, -1}, Union2: types.Vec4{1, 1, 1, 0}, Union4: types.Vec3{0, 0, 5}},
+	}
+	sc.MaterialIndex = []uint32{0, 0, 1, 1}
+	sc.EmissivePrimitives = []EmissivePrimitive{
+		{Type: AreaLight, PrimitiveIndex: 2, MaterialNodeIndex: 1, Transform: types.Ident4()},
+		{Type: AreaLight, PrimitiveIndex: 3, MaterialNodeIndex: 1, Transform: types.Ident4()},
+	}
+
+	type spec struct {
+		links  []LightLink
+		expLit [2]bool
+	}
+	specs := []spec{
+		// Unlinked lights illuminate all instances
+		spec{nil, [2]bool{true, true}},
+		spec{
+			[]LightLink{
+				{EmissiveIndex: 0, Mode: LightLinkInclude, Instances: []uint32{0}},
+				{EmissiveIndex: 1, Mode: LightLinkInclude, Instances: []uint32{0}},
+			},
+			[2]bool{true, false},
+		},
+		spec{
+			[]LightLink{
+				{EmissiveIndex: 0, Mode: LightLinkExclude, Instances: []uint32{0}},
+				{EmissiveIndex: 1, Mode: LightLinkExclude, Instances: []uint32{0}},
+			},
+			[2]bool{false, true},
+		},
+	}
+
+	for index, s := range specs {
+		sc.LightLinks = nil
+		for _, link := range s.links {
+			if err := sc.SetLightLink(link); err != nil {
+				t.Fatalf("[spec %d] %v", index, err)
+			}
+		}
+		if err := sc.Validate(); err != nil {
+			t.Fatalf("[spec %d] %v", index, err)
+		}
+
+		table := sc.LightLinkTable()
+		for instance := uint32(0); instance < 2; instance++ {
+			for emissive := uint32(0); emissive < 2; emissive++ {
+				if lit := table.Affects(emissive, instance); lit != s.expLit[instance] {
+					t.Errorf("[spec %d] expected emissive %d to affect instance %d: %t; got %t", index, emissive, instance, s.expLit[instance], lit)
+				}
+			}
+		}
+
+		// The light instance emitters are needed for gating emissive hits
+		if table.Stride != 0 {
+			expEmitters := []LightLinkEmitter{
+				{MeshInstance: 2, Primitive: 2, EmissiveIndex: 0},
+				{MeshInstance: 2, Primitive: 3, EmissiveIndex: 1},
+			}
+			if !reflect.DeepEqual(table.Emitters, expEmitters) {
+				t.Errorf("[spec %d] expected light link emitters to be %+v; got %+v", index, expEmitters, table.Emitters)
+			}
+		}
+	}
+
+	// Replacing and clearing links
+	sc.LightLinks = nil
+	sc.SetLightLink(LightLink{EmissiveIndex: 1, Mode: LightLinkInclude})
+	sc.SetLightLink(LightLink{EmissiveIndex: 1, Mode: LightLinkExclude, Instances: []uint32{1}})
+	if len(sc.LightLinks) != 1 || sc.LightLinks[0].Mode != LightLinkExclude {
+		t.Fatalf("expected SetLightLink to replace the existing link; got %+v", sc.LightLinks)
+	}
+	table := sc.LightLinkTable()
+	if !table.Affects(1, 0) || table.Affects(1, 1) || !table.Affects(0, 1) {
+		t.Fatalf("unexpected light link table contents: %+v", table)
+	}
+	sc.ClearLightLink(1)
+	if table = sc.LightLinkTable(); table.Stride != 0 {
+		t.Fatalf("expected light link table to be empty after clearing all links; got %+v", table)
+	}
+
+	// Invalid links
+	invalid := []LightLink{
+		{EmissiveIndex: 2},
+		{EmissiveIndex: 0, Mode: LightLinkExclude + 1},
+		{EmissiveIndex: 0, Instances: []uint32{3}},
+	}
+	for index, link := range invalid {
+		if err := sc.SetLightLink(link); err == nil {
+			t.Errorf("[invalid %d] expected SetLightLink to fail for %+v", index, link)
+		}
+	}
+}
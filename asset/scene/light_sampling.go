@@ -0,0 +1,133 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Get the probability of selecting a particular emissive when sampling the
+// scene lights. Like the opencl tracer, emissives are selected uniformly.
+func (sc *Scene) EmissiveSelectionPdf() float32 {
+	if len(sc.EmissivePrimitives) == 0 {
+		return 0
+	}
+	return 1.0 / float32(len(sc.EmissivePrimitives))
+}
+
+// Sample a direction from point towards a uniformly selected point on an area
+// light using the same warping as the opencl light sampler. SampleAreaLight
+// returns the normalized direction, the distance to the sampled point and the
+// solid angle PDF for the sample. The PDF does not include the probability of
+// selecting the emissive and is 0 if the sampled point is not visible from
+// point due to the orientation of the light.
+//
+// Area lights are treated as two-sided emitters.
+func (sc *Scene) SampleAreaLight(emissiveIndex uint32, point types.Vec3, randSample types.Vec2) (dir types.Vec3, dist, pdf float32) {
+	v := sc.areaLightVertices(emissiveIndex)
+
+	r1sqrt := float32(math.Sqrt(float64(randSample[0])))
+	ru := (1 - randSample[1]) * r1sqrt
+	rv := randSample[1] * r1sqrt
+	lightPoint := v[0].Mul(1 - ru - rv).Add(v[1].Mul(ru)).Add(v[2].Mul(rv))
+
+	toLight := lightPoint.Sub(point)
+	dist = toLight.Len()
+	if dist == 0 {
+		return dir, 0, 0
+	}
+	dir = toLight.Mul(1 / dist)
+
+	return dir, dist, areaLightSolidAnglePdf(v, dir, dist)
+}
+
+// Calculate the solid angle PDF of SampleAreaLight generating a ray from
+// point along dir. Returns 0 if the ray misses the area light.
+func (sc *Scene) AreaLightPdf(emissiveIndex uint32, point, dir types.Vec3) float32 {
+	v := sc.areaLightVertices(emissiveIndex)
+	dir = dir.Normalize()
+
+	dist := rayTriangleEdgesIntersect(v[0], v[1].Sub(v[0]), v[2].Sub(v[0]), point, dir)
+	if dist < intersectionEpsilon {
+		return 0
+	}
+
+	return areaLightSolidAnglePdf(v, dir, dist)
+}
+
+// Given a ray from point along dir (e.g. a BXDF sample) that hit the scene
+// geometry, calculate the PDF of the light sampler generating the same ray.
+// The returned PDF includes the probability of selecting the emissive that
+// was hit and can be combined with the BXDF PDF using PowerHeuristic to
+// calculate MIS weights. Returns 0 if the hit primitive is not emissive.
+func (sc *Scene) EmissiveHitPdf(hit RayHit, point, dir types.Vec3) float32 {
+	if hit.Quad {
+		return 0
+	}
+
+	mi := &sc.MeshInstanceList[hit.MeshInstance]
+	for index := range sc.EmissivePrimitives {
+		em := &sc.EmissivePrimitives[index]
+		if em.Type != AreaLight || em.PrimitiveIndex != hit.PrimitiveIndex || em.Transform != mi.Transform {
+			continue
+		}
+
+		return sc.EmissiveSelectionPdf() * sc.AreaLightPdf(uint32(index), point, dir)
+	}
+
+	return 0
+}
+
+// Calculate a multiple importance sampling weight for a sample generated by
+// a strategy with PDF pdfA when another strategy with PDF pdfB could also
+// have generated it. This is the power heuristic used by the opencl tracer.
+func PowerHeuristic(pdfA, pdfB float32) float32 {
+	a2, b2 := pdfA*pdfA, pdfB*pdfB
+	if a2+b2 == 0 {
+		return 0
+	}
+	return a2 / (a2 + b2)
+}
+
+// Get a copy of the scene emissives in the layout expected by the opencl light
+// sampler. The kernels transform area light vertices from mesh to world space
+// so the transforms of area lights are inverted and their areas are measured
+// in world space. This allows the kernels to generate the same samples as
+// SampleAreaLight for lights that belong to transformed mesh instances.
+func (sc *Scene) EmissiveSamplerList() []EmissivePrimitive {
+	list := make([]EmissivePrimitive, len(sc.EmissivePrimitives))
+	for index, em := range sc.EmissivePrimitives {
+		if em.Type == AreaLight {
+			v := sc.areaLightVertices(uint32(index))
+			em.Transform = em.Transform.Inv()
+			em.Area = 0.5 * v[1].Sub(v[0]).Cross(v[2].Sub(v[0])).Len()
+		}
+		list[index] = em
+	}
+	return list
+}
+
+// Get the world space vertices of an area light. Like the instance
+// transforms, emissive transforms convert from world to mesh space.
+func (sc *Scene) areaLightVertices(emissiveIndex uint32) [3]types.Vec3 {
+	em := &sc.EmissivePrimitives[emissiveIndex]
+	meshToWorld := em.Transform.Inv()
+
+	var v [3]types.Vec3
+	for i := uint32(0); i < 3; i++ {
+		v[i] = meshToWorld.Mul4x1(sc.VertexList[3*em.PrimitiveIndex+i].Vec3().Vec4(1)).Vec3()
+	}
+	return v
+}
+
+// Convert the uniform area PDF for sampling a point on a triangle at distance
+// dist along dir to the solid angle measure.
+func areaLightSolidAnglePdf(v [3]types.Vec3, dir types.Vec3, dist float32) float32 {
+	normal := v[1].Sub(v[0]).Cross(v[2].Sub(v[0]))
+	area := 0.5 * normal.Len()
+	denominator := area * float32(math.Abs(float64(normal.Normalize().Dot(dir))))
+	if denominator <= 0 {
+		return 0
+	}
+	return dist * dist / denominator
+}
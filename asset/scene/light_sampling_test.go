@@ -0,0 +1,177 @@
+package scene
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestEmissiveHitPdf(t *testing.T) {
+	sc := makeAreaLightTestScene()
+	origin := types.Vec3{0, 0, 0}
+	rng := rand.New(rand.NewSource(1))
+
+	// For rays generated by the light sampler, the PDF reported for the BXDF
+	// ray hitting the light must match the sampler PDF.
+	for i := 0; i < 100; i++ {
+		dir, dist, pdf := sc.SampleAreaLight(0, origin, types.Vec2{rng.Float32(), rng.Float32()})
+		hit, found := sc.Intersect(origin, dir, 1e3)
+		if !found {
+			t.Fatalf("expected ray towards sampled light point to hit the light")
+		}
+		if math.Abs(float64(hit.Dist-dist)) > 1e-4 {
+			t.Fatalf("expected hit distance to be %f; got %f", dist, hit.Dist)
+		}
+
+		hitPdf := sc.EmissiveHitPdf(hit, origin, dir)
+		if math.Abs(float64(hitPdf-pdf*sc.EmissiveSelectionPdf())) > 1e-3*float64(pdf) {
+			t.Fatalf("expected emissive hit pdf to be %f; got %f", pdf, hitPdf)
+		}
+	}
+
+	if pdf := sc.AreaLightPdf(0, origin, types.Vec3{0, -1, 0}); pdf != 0 {
+		t.Fatalf("expected pdf for ray missing the light to be 0; got %f", pdf)
+	}
+}
+
+func TestLightSamplingMIS(t *testing.T) {
+	// Estimate the irradiance (divided by PI) at the origin due to a large,
+	// nearby area light with unit radiance using light sampling, BXDF
+	// sampling and MIS combining both strategies.
+	sc := makeAreaLightTestScene()
+	origin := types.Vec3{0, 0, 0}
+	normal := types.Vec3{0, 1, 0}
+	tangent, bitangent := types.BuildOrthonormalBasis(normal)
+
+	lightEstimate := func(sample types.Vec2) (float64, float32) {
+		dir, _, pdf := sc.SampleAreaLight(0, origin, sample)
+		cos := normal.Dot(dir)
+		if pdf == 0 || cos <= 0 {
+			return 0, 0
+		}
+		bxdfPdf := cos / math.Pi
+		return float64(cos / math.Pi / (pdf * sc.EmissiveSelectionPdf())), PowerHeuristic(pdf*sc.EmissiveSelectionPdf(), bxdfPdf)
+	}
+	bxdfEstimate := func(sample types.Vec2) (float64, float32) {
+		// Cosine weighted hemisphere sample
+		r := float32(math.Sqrt(float64(sample[0])))
+		phi := 2 * math.Pi * float64(sample[1])
+		x, y := r*float32(math.Cos(phi)), r*float32(math.Sin(phi))
+		z := float32(math.Sqrt(math.Max(0, float64(1-x*x-y*y))))
+		dir := tangent.Mul(x).Add(bitangent.Mul(y)).Add(normal.Mul(z))
+
+		hit, found := sc.Intersect(origin, dir, 1e3)
+		if !found {
+			return 0, 0
+		}
+		// brdf * cos / pdf = 1 for a lambert surface
+		return 1, PowerHeuristic(z/math.Pi, sc.EmissiveHitPdf(hit, origin, dir))
+	}
+
+	const numSamples = 20000
+	rng := rand.New(rand.NewSource(42))
+	var light, bxdf, mis stats
+	for i := 0; i < numSamples; i++ {
+		lightVal, lightWeight := lightEstimate(types.Vec2{rng.Float32(), rng.Float32()})
+		bxdfVal, bxdfWeight := bxdfEstimate(types.Vec2{rng.Float32(), rng.Float32()})
+
+		light.add(lightVal)
+		bxdf.add(bxdfVal)
+		mis.add(lightVal*float64(lightWeight) + bxdfVal*float64(bxdfWeight))
+	}
+
+	type spec struct {
+		name string
+		st   stats
+	}
+	specs := []spec{
+		spec{"light", light},
+		spec{"bxdf", bxdf},
+	}
+	for index, s := range specs {
+		stdErr := math.Sqrt((s.st.variance() + mis.variance()) / numSamples)
+		if diff := math.Abs(s.st.mean() - mis.mean()); diff > 4*stdErr {
+			t.Errorf("[spec %d] expected %s sampling mean %f to match MIS mean %f", index, s.name, s.st.mean(), mis.mean())
+		}
+		if mis.variance() >= s.st.variance() {
+			t.Errorf("[spec %d] expected MIS variance %f to be lower than %s sampling variance %f", index, mis.variance(), s.name, s.st.variance())
+		}
+	}
+}
+
+func TestEmissiveSamplerList(t *testing.T) {
+	sc := makeAreaLightTestScene()
+
+	// Scale the light instance by 2 and move it above the origin
+	meshToWorld := types.Translate4(types.Vec3{0, 1, 0}).Mul4(types.Scale4(types.Vec3{2, 2, 2}))
+	sc.EmissivePrimitives[0].Transform = meshToWorld.Inv()
+	sc.EmissivePrimitives[0].Area = 6.125
+	sc.EmissivePrimitives = append(sc.EmissivePrimitives, EmissivePrimitive{Type: EnvironmentLight, Transform: EnvironmentRotation(1)})
+
+	list := sc.EmissiveSamplerList()
+	if len(list) != len(sc.EmissivePrimitives) {
+		t.Fatalf("expected list to contain %d emissives; got %d", len(sc.EmissivePrimitives), len(list))
+	}
+
+	for index := 0; index < 3; index++ {
+		meshVertex := sc.VertexList[index].Vec3()
+		exp := meshToWorld.Mul4x1(meshVertex.Vec4(1)).Vec3()
+		if got := list[0].Transform.Mul4x1(meshVertex.Vec4(1)).Vec3(); !types.ApproxEqual(got, exp, 1e-4) {
+			t.Errorf("expected vertex %d to be transformed to %v; got %v", index, exp, got)
+		}
+	}
+	if expArea := float32(4 * 6.125); math.Abs(float64(list[0].Area-expArea)) > 1e-3 {
+		t.Errorf("expected area light area to be %f; got %f", expArea, list[0].Area)
+	}
+	if list[1] != sc.EmissivePrimitives[1] {
+		t.Errorf("expected environment light to be copied as-is; got %+v", list[1])
+	}
+
+	// The scene emissives should not be modified
+	if sc.EmissivePrimitives[0].Transform != meshToWorld.Inv() {
+		t.Error("expected scene emissive transform to remain unchanged")
+	}
+}
+
+type stats struct {
+	n, sum, sumSq float64
+}
+
+func (s *stats) add(v float64) {
+	s.n++
+	s.sum += v
+	s.sumSq += v * v
+}
+
+func (s *stats) mean() float64 {
+	return s.sum / s.n
+}
+
+func (s *stats) variance() float64 {
+	mean := s.mean()
+	return s.sumSq/s.n - mean*mean
+}
+
+// Create a scene with a large triangular area light placed above the origin.
+func makeAreaLightTestScene() *Scene {
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Translate4(types.Vec3{0.5, 0, 0})}},
+		BvhNodeList:      make([]BvhNode, 2),
+		VertexList: []types.Vec4{
+			{-2, 0.5, -1.5, 0},
+			{1.5, 0.5, -1.5, 0},
+			{-0.5, 0.5, 2, 0},
+		},
+		EmissivePrimitives: []EmissivePrimitive{
+			{Type: AreaLight, PrimitiveIndex: 0, Transform: types.Translate4(types.Vec3{0.5, 0, 0})},
+		},
+	}
+	meshBBox := [2]types.Vec3{{-2, 0.4, -1.5}, {1.5, 0.6, 2}}
+	sc.BvhNodeList[1].SetBBox(meshBBox)
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+	sc.BvhNodeList[0].SetBBox(transformBBox(sc.MeshInstanceList[0].Transform.Inv(), meshBBox))
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	return sc
+}
@@ -0,0 +1,80 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The reflectance of the default material node.
+var DefaultMaterialReflectance = types.Vec4{0.5, 0.5, 0.5, 0.0}
+
+// Create a neutral gray diffuse material node for shading primitives whose
+// material index is invalid.
+func NewDefaultMaterialNode() MaterialNode {
+	return MaterialNode{
+		Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1},
+		Union2: DefaultMaterialReflectance,
+		Union4: types.Vec3{material.DefaultIntIOR, material.DefaultExtIOR, 0.0},
+		Union6: types.Vec4{0.0, 0.0, 0.0, material.DefaultNormalScale},
+	}
+}
+
+// Assign the scene default material to primitives that are missing a material
+// index or whose material index does not point to a valid material node. This
+// allows partially authored scenes to be rendered for preview purposes.
+//
+// The default material (sc.DefaultMaterial or, if not set, the node returned
+// by NewDefaultMaterialNode) is appended to the material node list the first
+// time it is needed. If strict is true, an error is returned for the first
+// invalid primitive instead and the scene is not modified.
+//
+// ApplyMaterialFallback returns the number of primitives that were assigned
+// the default material.
+func (sc *Scene) ApplyMaterialFallback(strict bool) (int, error) {
+	numTris, err := sc.triangleCount()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(sc.MaterialIndex) > numTris {
+		return 0, fmt.Errorf("scene: material index count (%d) exceeds triangle count (%d)", len(sc.MaterialIndex), numTris)
+	}
+
+	if strict {
+		if len(sc.MaterialIndex) < numTris {
+			return 0, fmt.Errorf("scene: triangle %d has no material index", len(sc.MaterialIndex))
+		}
+		for triIndex, matIndex := range sc.MaterialIndex {
+			if err = sc.checkMaterialNodeIndex(matIndex); err != nil {
+				return 0, fmt.Errorf("%s (triangle %d)", err.Error(), triIndex)
+			}
+		}
+		return 0, nil
+	}
+
+	numMaterials := uint32(len(sc.MaterialNodeList))
+	defaultMatIndex := numMaterials
+	var numFallbacks int
+	for triIndex := 0; triIndex < numTris; triIndex++ {
+		if triIndex == len(sc.MaterialIndex) {
+			sc.MaterialIndex = append(sc.MaterialIndex, numMaterials)
+		} else if sc.MaterialIndex[triIndex] < numMaterials {
+			continue
+		}
+
+		sc.MaterialIndex[triIndex] = defaultMatIndex
+		numFallbacks++
+	}
+
+	if numFallbacks != 0 {
+		defaultMat := NewDefaultMaterialNode()
+		if sc.DefaultMaterial != nil {
+			defaultMat = *sc.DefaultMaterial
+		}
+		sc.MaterialNodeList = append(sc.MaterialNodeList, defaultMat)
+	}
+
+	return numFallbacks, nil
+}
@@ -0,0 +1,80 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestApplyMaterialFallback(t *testing.T) {
+	sc := makePlaneTestScene(1)
+	sc.MaterialNodeList = []MaterialNode{
+		{Union1: [4]int32{int32(material.BxdfConductor), -1, -1, -1}},
+	}
+	sc.MaterialIndex = []uint32{0, 7}
+
+	if _, err := sc.ApplyMaterialFallback(true); err == nil {
+		t.Fatal("expected strict fallback to fail for out of range material index")
+	}
+	if len(sc.MaterialNodeList) != 1 || sc.MaterialIndex[1] != 7 {
+		t.Fatal("expected strict fallback not to modify the scene")
+	}
+
+	numFallbacks, err := sc.ApplyMaterialFallback(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numFallbacks != 1 {
+		t.Fatalf("expected 1 primitive to use the default material; got %d", numFallbacks)
+	}
+	if err = sc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The triangle with the out of range index should now render as gray diffuse
+	hit, found := sc.Intersect(types.Vec3{-0.5, 0.5, 1}, types.Vec3{0, 0, -1}, 10)
+	if !found || hit.PrimitiveIndex != 1 {
+		t.Fatalf("expected ray to hit primitive 1; got %+v (%t)", hit, found)
+	}
+	node := sc.MaterialNodeList[sc.MaterialIndex[hit.PrimitiveIndex]]
+	if node.Union1[0] != int32(material.BxdfDiffuse) || node.Union2 != DefaultMaterialReflectance {
+		t.Fatalf("expected primitive to use the gray diffuse default material; got %+v", node)
+	}
+
+	// Valid indices are left untouched
+	if sc.MaterialIndex[0] != 0 {
+		t.Fatalf("expected primitive 0 material index to be 0; got %d", sc.MaterialIndex[0])
+	}
+}
+
+func TestApplyMaterialFallbackMissingIndices(t *testing.T) {
+	customMat := MaterialNode{
+		Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1},
+		Union2: types.Vec4{1, 0, 1, 0},
+	}
+
+	sc := makePlaneTestScene(1)
+	sc.MaterialNodeList = []MaterialNode{{}}
+	sc.DefaultMaterial = &customMat
+
+	if _, err := sc.ApplyMaterialFallback(true); err == nil {
+		t.Fatal("expected strict fallback to fail for missing material indices")
+	}
+
+	numFallbacks, err := sc.ApplyMaterialFallback(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numFallbacks != 2 {
+		t.Fatalf("expected 2 primitives to use the default material; got %d", numFallbacks)
+	}
+	if len(sc.MaterialNodeList) != 2 || sc.MaterialNodeList[1] != customMat {
+		t.Fatalf("expected the custom default material to be appended to the material node list; got %+v", sc.MaterialNodeList)
+	}
+	for triIndex, matIndex := range sc.MaterialIndex {
+		if matIndex != 1 {
+			t.Fatalf("expected triangle %d material index to be 1; got %d", triIndex, matIndex)
+		}
+	}
+}
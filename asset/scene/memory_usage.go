@@ -0,0 +1,86 @@
+package scene
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A breakdown of the memory used by the scene data in bytes.
+type MemoryReport struct {
+	// BVH nodes and any miss links.
+	BVH int64
+
+	Vertices     int64
+	Normals      int64
+	UVs          int64
+	VertexColors int64
+
+	// Texture metadata and data.
+	Textures int64
+
+	// Material nodes, primitive material indices and per-material
+	// uv transforms and opacities.
+	Materials int64
+
+	// Mesh instances, emissive primitives and quads.
+	Other int64
+}
+
+// Get the total number of bytes used by the scene.
+func (r MemoryReport) Total() int64 {
+	return r.BVH + r.Vertices + r.Normals + r.UVs + r.VertexColors + r.Textures + r.Materials + r.Other
+}
+
+// Implements Stringer.
+func (r MemoryReport) String() string {
+	return fmt.Sprintf(
+		"BVH: %s, vertices: %s, normals: %s, uvs: %s, vertex colors: %s, textures: %s, materials: %s, other: %s, total: %s",
+		trimBytes(r.BVH), trimBytes(r.Vertices), trimBytes(r.Normals), trimBytes(r.UVs), trimBytes(r.VertexColors),
+		trimBytes(r.Textures), trimBytes(r.Materials), trimBytes(r.Other), trimBytes(r.Total()),
+	)
+}
+
+// Calculate the memory used by the scene. Like Summary, sizes are calculated
+// from the length of the scene data slices and their element sizes so the
+// report can be generated for partially built scenes.
+func (sc *Scene) MemoryUsage() MemoryReport {
+	return MemoryReport{
+		BVH:          sliceBytes(sc.BvhNodeList),
+		Vertices:     sliceBytes(sc.VertexList),
+		Normals:      sliceBytes(sc.NormalList),
+		UVs:          sliceBytes(sc.UvList),
+		VertexColors: sliceBytes(sc.VertexColorList),
+		Textures:     sliceBytes(sc.TextureMetadata, sc.TextureData),
+		Materials:    sliceBytes(sc.MaterialNodeList, sc.MaterialIndex, sc.UVTransforms, sc.Opacities),
+		Other:        sliceBytes(sc.MeshInstanceList, sc.EmissivePrimitives, sc.QuadList),
+	}
+}
+
+// Ensure that the total memory used by the scene does not exceed the
+// specified budget in bytes. A budget of 0 disables the check.
+func (sc *Scene) CheckMemoryBudget(budget int64) error {
+	if budget <= 0 {
+		return nil
+	}
+
+	report := sc.MemoryUsage()
+	if total := report.Total(); total > budget {
+		return fmt.Errorf("scene: memory usage of %s exceeds the budget of %s (%s)", trimBytes(total), trimBytes(budget), report)
+	}
+	return nil
+}
+
+// Sum the number of bytes used by a set of slices.
+func sliceBytes(items ...interface{}) int64 {
+	var total int64
+	for _, item := range items {
+		v := reflect.ValueOf(item)
+		total += int64(v.Type().Elem().Size()) * int64(v.Len())
+	}
+	return total
+}
+
+func trimBytes(count int64) string {
+	return strings.TrimSpace(fmtBytes(float32(count)))
+}
@@ -0,0 +1,64 @@
+package scene
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	if total := (&Scene{}).MemoryUsage().Total(); total != 0 {
+		t.Fatalf("expected empty scene to use 0 bytes; got %d", total)
+	}
+
+	sc := &Scene{
+		BvhNodeList:      make([]BvhNode, 7),
+		MeshInstanceList: make([]MeshInstance, 3),
+		MaterialNodeList: make([]MaterialNode, 4),
+		MaterialIndex:    make([]uint32, 5),
+		Opacities:        make([]float32, 4),
+		TextureMetadata:  make([]TextureMetadata, 2),
+		TextureData:      make([]byte, 2048),
+		VertexList:       make([]types.Vec4, 15),
+		NormalList:       make([]types.Vec4, 15),
+		UvList:           make([]types.Vec2, 15),
+	}
+
+	report := sc.MemoryUsage()
+	type spec struct {
+		name   string
+		value  int64
+		expVal int64
+	}
+	specs := []spec{
+		spec{"bvh", report.BVH, 7 * 32},
+		spec{"vertices", report.Vertices, 15 * 16},
+		spec{"normals", report.Normals, 15 * 16},
+		spec{"uvs", report.UVs, 15 * 8},
+		spec{"vertex colors", report.VertexColors, 0},
+		spec{"textures", report.Textures, 2*int64(unsafe.Sizeof(TextureMetadata{})) + 2048},
+		spec{"materials", report.Materials, 4*int64(unsafe.Sizeof(MaterialNode{})) + 5*4 + 4*4},
+		spec{"other", report.Other, 3 * int64(unsafe.Sizeof(MeshInstance{}))},
+	}
+	var expTotal int64
+	for index, s := range specs {
+		if s.value != s.expVal {
+			t.Errorf("[spec %d] expected %s bytes to be %d; got %d", index, s.name, s.expVal, s.value)
+		}
+		expTotal += s.expVal
+	}
+	if total := report.Total(); total != expTotal {
+		t.Fatalf("expected total bytes to be %d; got %d", expTotal, total)
+	}
+
+	if err := sc.CheckMemoryBudget(0); err != nil {
+		t.Fatalf("expected a zero budget to disable the check; got %v", err)
+	}
+	if err := sc.CheckMemoryBudget(expTotal); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sc.CheckMemoryBudget(expTotal - 1); err == nil {
+		t.Fatal("expected an error when exceeding the memory budget")
+	}
+}
@@ -0,0 +1,149 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+// Calculate the total radiant power (in watts) emitted by all instances of a
+// mesh. For each mesh primitive with an emissive material, the power of a
+// lambertian emitter (PI * radiance luminance * area) is integrated over the
+// primitive area. The mesh power is then scaled for each instance using
+// the determinant of the instance transformation matrix; the area scaling
+// is exact for uniform scaling and approximate for non-uniform scaling.
+//
+// Returns 0 if the mesh is not emissive or is not referenced by any instance.
+func (sc *Scene) MeshPower(meshIndex uint32) float32 {
+	firstPrim, numPrims, err := meshPrimitives(sc, meshIndex)
+	if err != nil {
+		return 0
+	}
+
+	var meshPower float32
+	for primIndex := firstPrim; primIndex < firstPrim+numPrims; primIndex++ {
+		nodeIndex := sc.findEmissiveNode(sc.MaterialIndex[primIndex])
+		if nodeIndex == -1 {
+			continue
+		}
+
+		emission := sc.MaterialNodeList[nodeIndex].Emission()
+		luminance := 0.2126*emission[0] + 0.7152*emission[1] + 0.0722*emission[2]
+		meshPower += math.Pi * luminance * sc.primitiveArea(primIndex)
+	}
+
+	if meshPower == 0 {
+		return 0
+	}
+
+	var power float32
+	for _, mi := range sc.MeshInstanceList {
+		if mi.MeshIndex != meshIndex {
+			continue
+		}
+
+		// The instance transform converts from world to mesh space so
+		// we need to invert its determinant. Areas scale by det^(2/3).
+		det := math.Abs(float64(mi.Transform.Det()))
+		if det == 0 {
+			continue
+		}
+		power += meshPower * float32(math.Pow(1.0/det, 2.0/3.0))
+	}
+
+	return power
+}
+
+// Calculate the area of a primitive in mesh space.
+func (sc *Scene) primitiveArea(primIndex uint32) float32 {
+	return TriangleArea(sc, primIndex)
+}
+
+// Calculate the mesh space area of a triangle primitive. Degenerate triangles
+// and out of range primitive indices yield a zero area.
+func TriangleArea(s *Scene, primIndex uint32) float32 {
+	if int(primIndex) >= len(s.VertexList)/3 {
+		return 0
+	}
+
+	return triangleArea(
+		s.VertexList[3*primIndex].Vec3(),
+		s.VertexList[3*primIndex+1].Vec3(),
+		s.VertexList[3*primIndex+2].Vec3(),
+	)
+}
+
+// Calculate the total mesh space area of the triangles that belong to a mesh.
+// Returns 0 if the mesh is not referenced by any instance.
+func MeshSurfaceArea(s *Scene, meshIndex uint32) float32 {
+	firstPrim, numPrims, err := meshPrimitives(s, meshIndex)
+	if err != nil {
+		return 0
+	}
+
+	var area float32
+	for primIndex := firstPrim; primIndex < firstPrim+numPrims; primIndex++ {
+		area += TriangleArea(s, primIndex)
+	}
+	return area
+}
+
+// Calculate the total world space area of the triangles that belong to a mesh
+// instance. Unlike the determinant-based scaling used by MeshPower, the area
+// is calculated by transforming each triangle so non-uniform scaling is
+// handled exactly. Returns 0 if the instance index is out of range.
+func InstanceSurfaceArea(s *Scene, instanceIndex uint32) float32 {
+	if int(instanceIndex) >= len(s.MeshInstanceList) {
+		return 0
+	}
+	mi := &s.MeshInstanceList[instanceIndex]
+	firstPrim, numPrims, err := meshPrimitives(s, mi.MeshIndex)
+	if err != nil {
+		return 0
+	}
+
+	// The instance transform converts from world to mesh space
+	meshToWorld := mi.Transform.Inv()
+	var area float32
+	for primIndex := firstPrim; primIndex < firstPrim+numPrims; primIndex++ {
+		area += triangleArea(
+			meshToWorld.Mul4x1(s.VertexList[3*primIndex].Vec3().Vec4(1)).Vec3(),
+			meshToWorld.Mul4x1(s.VertexList[3*primIndex+1].Vec3().Vec4(1)).Vec3(),
+			meshToWorld.Mul4x1(s.VertexList[3*primIndex+2].Vec3().Vec4(1)).Vec3(),
+		)
+	}
+	return area
+}
+
+// Calculate the area of the triangle defined by three vertices.
+func triangleArea(v0, v1, v2 types.Vec3) float32 {
+	area := 0.5 * v2.Sub(v0).Cross(v2.Sub(v1)).Len()
+
+	// Catch NaNs caused by degenerate vertex data
+	if !(area > 0) {
+		return 0
+	}
+	return area
+}
+
+// Perform a DFS in a layered material tree and return the index of the first
+// emissive node or -1 if the material is not emissive.
+func (sc *Scene) findEmissiveNode(nodeIndex uint32) int32 {
+	node := &sc.MaterialNodeList[nodeIndex]
+	nodeType := uint32(node.Union1[0])
+
+	if material.IsBxdfType(nodeType) {
+		if nodeType == uint32(material.BxdfEmissive) {
+			return int32(nodeIndex)
+		}
+		return -1
+	}
+
+	out := sc.findEmissiveNode(uint32(node.Union1[1]))
+	if out == -1 && (nodeType == uint32(material.OpMix) || nodeType == uint32(material.OpLayered)) {
+		out = sc.findEmissiveNode(uint32(node.Union1[2]))
+	}
+
+	return out
+}
@@ -0,0 +1,108 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestMeshPower(t *testing.T) {
+	// A 2x1 quad (area = 2) with radiance {1, 1, 1} and a radiance scaler of 5
+	sc := &Scene{
+		BvhNodeList: make([]BvhNode, 1),
+		VertexList: []types.Vec4{
+			{0, 0, 0, 0}, {2, 0, 0, 0}, {2, 1, 0, 0},
+			{0, 0, 0, 0}, {2, 1, 0, 0}, {0, 1, 0, 0},
+		},
+		MaterialIndex: []uint32{0, 0},
+		MaterialNodeList: []MaterialNode{
+			{
+				Union1: [4]int32{int32(material.BxdfEmissive), -1, -1, -1},
+				Union2: types.Vec4{1, 1, 1, 0},
+				Union4: types.Vec3{0, 0, 5},
+			},
+			{
+				Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1},
+				Union2: types.Vec4{1, 1, 1, 0},
+			},
+		},
+	}
+	sc.BvhNodeList[0].SetPrimitives(0, 2)
+
+	type spec struct {
+		scales   []float32
+		expPower float32
+	}
+	specs := []spec{
+		spec{[]float32{}, 0},
+		spec{[]float32{1}, 10 * math.Pi},
+		spec{[]float32{2}, 40 * math.Pi},
+		spec{[]float32{1, 2}, 50 * math.Pi},
+	}
+
+	for index, s := range specs {
+		sc.MeshInstanceList = sc.MeshInstanceList[:0]
+		for _, scale := range s.scales {
+			sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+				Transform: types.Scale4(types.Vec3{scale, scale, scale}).Inv(),
+			})
+		}
+
+		power := sc.MeshPower(0)
+		if math.Abs(float64(power-s.expPower)) > 1e-3 {
+			t.Fatalf("[spec %d] expected mesh power to be %f; got %f", index, s.expPower, power)
+		}
+	}
+
+	// Non-emissive meshes should not emit any power
+	sc.MaterialIndex = []uint32{1, 1}
+	if power := sc.MeshPower(0); power != 0 {
+		t.Fatalf("expected non-emissive mesh power to be 0; got %f", power)
+	}
+}
+
+func TestSurfaceArea(t *testing.T) {
+	// A unit right triangle (area 0.5), a 2x1 quad made of two triangles
+	// (area 2) and a degenerate triangle
+	sc := &Scene{
+		BvhNodeList: make([]BvhNode, 3),
+		VertexList: []types.Vec4{
+			{0, 0, 0, 0}, {1, 0, 0, 0}, {0, 1, 0, 0},
+			{0, 0, 0, 0}, {2, 0, 0, 0}, {2, 1, 0, 0},
+			{0, 0, 0, 0}, {2, 1, 0, 0}, {0, 1, 0, 0},
+			{0, 0, 0, 0}, {1, 1, 1, 0}, {2, 2, 2, 0},
+		},
+		MeshInstanceList: []MeshInstance{
+			{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()},
+			{MeshIndex: 1, BvhRoot: 2, Transform: types.Ident4()},
+			{MeshIndex: 1, BvhRoot: 2, Transform: types.Scale4(types.Vec3{2, 3, 1}).Inv()},
+		},
+	}
+	sc.BvhNodeList[1].SetPrimitives(0, 1)
+	sc.BvhNodeList[2].SetPrimitives(1, 3)
+
+	type spec struct {
+		area    float32
+		expArea float32
+	}
+	specs := []spec{
+		spec{TriangleArea(sc, 0), 0.5},
+		spec{TriangleArea(sc, 3), 0},
+		spec{TriangleArea(sc, 4), 0},
+		spec{MeshSurfaceArea(sc, 0), 0.5},
+		spec{MeshSurfaceArea(sc, 1), 2},
+		spec{MeshSurfaceArea(sc, 2), 0},
+		spec{InstanceSurfaceArea(sc, 0), 0.5},
+		spec{InstanceSurfaceArea(sc, 1), 2},
+		spec{InstanceSurfaceArea(sc, 2), 12},
+		spec{InstanceSurfaceArea(sc, 3), 0},
+	}
+
+	for index, s := range specs {
+		if math.Abs(float64(s.area-s.expArea)) > 1e-5 {
+			t.Fatalf("[spec %d] expected area to be %f; got %f", index, s.expArea, s.area)
+		}
+	}
+}
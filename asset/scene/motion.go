@@ -0,0 +1,309 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The type of bounding volume used by the nodes of a MotionBvh.
+type MotionBoundsType uint8
+
+const (
+	// Axis aligned boxes that enclose the node geometry over the entire
+	// motion interval.
+	SweptAABBBounds MotionBoundsType = iota
+
+	// Spheres that enclose the node geometry over the entire motion
+	// interval. Unlike boxes, spheres centered on the rotation axis are
+	// rotation invariant so they do not over-inflate for rotating parts.
+	SphereBounds
+)
+
+// Implements Stringer.
+func (t MotionBoundsType) String() string {
+	switch t {
+	case SweptAABBBounds:
+		return "swept-aabb"
+	case SphereBounds:
+		return "sphere"
+	}
+	return "unknown"
+}
+
+// A rigid rotation of a mesh about an axis. The rotation is linearly
+// interpolated over the shutter interval [0, 1].
+type RotationMotion struct {
+	// A point on the rotation axis in mesh space.
+	Origin types.Vec3
+
+	// The rotation axis.
+	Axis types.Vec3
+
+	// The rotation angle in radians at the end of the shutter interval.
+	Angle float32
+}
+
+// Get the matrix that transforms the mesh geometry from its rest pose to its
+// pose at time t.
+func (m RotationMotion) TransformAt(t float32) types.Mat4 {
+	rot := types.QuatFromAxisAngle(m.Axis.Normalize(), m.Angle*t).Mat4()
+	return types.Translate4(m.Origin).Mul4(rot).Mul4(types.Translate4(m.Origin.Mul(-1)))
+}
+
+// Get the projection of a point to the rotation axis and its distance from it.
+func (m RotationMotion) axisProjection(p types.Vec3) (types.Vec3, float32) {
+	axis := m.Axis.Normalize()
+	proj := m.Origin.Add(axis.Mul(p.Sub(m.Origin).Dot(axis)))
+	return proj, p.Sub(proj).Len()
+}
+
+// A bottom-level BVH for a mesh that moves during the shutter interval. The
+// hierarchy shares the topology of the mesh BVH but its nodes are bounded by
+// conservative volumes that enclose the node geometry at any time during the
+// motion. This allows rays with different time samples to share the same
+// hierarchy.
+//
+// The bounds type is selected per mesh when building the hierarchy; see
+// BuildMotionBvh.
+//
+// Motion BVHs are not used by the renderer as the opencl kernels do not sample
+// ray times. They are a CPU tool for comparing the traversal cost of the
+// bounds types before adopting either of them for motion blur.
+type MotionBvh struct {
+	BoundsType MotionBoundsType
+	Motion     RotationMotion
+
+	nodes []motionBvhNode
+
+	// The number of nodes visited by Intersect calls. This counter can be
+	// used to compare the traversal cost of the available bounds types.
+	NodeVisits uint64
+}
+
+type motionBvhNode struct {
+	// Swept bounds; only the fields for the hierarchy bounds type are set.
+	min, max types.Vec3
+	center   types.Vec3
+	radius   float32
+
+	// A copy of the mesh BVH node with the child node indices remapped to
+	// the motion hierarchy.
+	node BvhNode
+}
+
+// The rest pose bounds of a node.
+type restBounds struct {
+	bbox   [2]types.Vec3
+	center types.Vec3
+	radius float32
+}
+
+// Build a motion BVH for a mesh that is animated by the supplied rotation.
+func (sc *Scene) BuildMotionBvh(meshIndex uint32, motion RotationMotion, boundsType MotionBoundsType) (*MotionBvh, error) {
+	if motion.Axis.Len() == 0 {
+		return nil, fmt.Errorf("scene: motion rotation axis must not be zero")
+	}
+
+	var bvhRoot int32 = -1
+	for _, mi := range sc.MeshInstanceList {
+		if mi.MeshIndex == meshIndex {
+			bvhRoot = int32(mi.BvhRoot)
+			break
+		}
+	}
+	if bvhRoot == -1 {
+		return nil, fmt.Errorf("scene: no instance references mesh %d", meshIndex)
+	}
+
+	b := &MotionBvh{
+		BoundsType: boundsType,
+		Motion:     motion,
+	}
+	b.buildNode(sc, bvhRoot)
+	return b, nil
+}
+
+// Recursively copy a mesh BVH node and its children and calculate their swept
+// bounds. Returns the index of the copied node and its rest pose bounds.
+func (b *MotionBvh) buildNode(sc *Scene, nodeIndex int32) (int32, restBounds) {
+	outIndex := int32(len(b.nodes))
+	b.nodes = append(b.nodes, motionBvhNode{node: sc.BvhNodeList[nodeIndex]})
+
+	var rest restBounds
+	src := &sc.BvhNodeList[nodeIndex]
+	if src.LData > 0 {
+		left, leftBounds := b.buildNode(sc, src.LData)
+		right, rightBounds := b.buildNode(sc, src.RData)
+		b.nodes[outIndex].node.SetChildNodes(uint32(left), uint32(right))
+
+		rest.bbox[0] = types.MinVec3(leftBounds.bbox[0], rightBounds.bbox[0])
+		rest.bbox[1] = types.MaxVec3(leftBounds.bbox[1], rightBounds.bbox[1])
+		rest.center = leftBounds.center.Add(rightBounds.center).Mul(0.5)
+		rest.radius = maxFloat32(
+			rest.center.Sub(leftBounds.center).Len()+leftBounds.radius,
+			rest.center.Sub(rightBounds.center).Len()+rightBounds.radius,
+		)
+	} else {
+		points := leafPoints(sc, src)
+		rest.bbox = emptyBBox()
+		for _, p := range points {
+			rest.bbox[0] = types.MinVec3(rest.bbox[0], p)
+			rest.bbox[1] = types.MaxVec3(rest.bbox[1], p)
+		}
+		rest.center = rest.bbox[0].Add(rest.bbox[1]).Mul(0.5)
+		for _, p := range points {
+			rest.radius = maxFloat32(rest.radius, p.Sub(rest.center).Len())
+		}
+	}
+
+	out := &b.nodes[outIndex]
+	switch b.BoundsType {
+	case SphereBounds:
+		out.center, out.radius = b.Motion.sweptSphere(rest.center, rest.radius)
+	default:
+		out.min, out.max = b.Motion.sweptBBox(rest.bbox)
+	}
+
+	return outIndex, rest
+}
+
+// Find the closest intersection between a ray and the mesh posed at the
+// specified time. The ray origin and direction are specified in mesh space.
+func (b *MotionBvh) Intersect(sc *Scene, origin, dir types.Vec3, time, maxDist float32) (RayHit, bool) {
+	hit := RayHit{Dist: maxDist}
+	if len(b.nodes) == 0 {
+		return hit, false
+	}
+
+	// Triangles are tested using a ray transformed to the rest pose of
+	// the mesh. As the motion is rigid, hit distances are not affected.
+	ray := newTraversalRay(SinglePrecision, origin, dir, maxDist)
+	restRay := ray.transform(b.Motion.TransformAt(time).Inv())
+
+	var found bool
+	nodeStack := []int32{0}
+	for len(nodeStack) != 0 {
+		node := &b.nodes[nodeStack[len(nodeStack)-1]]
+		nodeStack = nodeStack[:len(nodeStack)-1]
+		b.NodeVisits++
+
+		if !b.intersectsNode(node, &ray) {
+			continue
+		}
+
+		if node.node.LData > 0 {
+			nodeStack = append(nodeStack, node.node.LData, node.node.RData)
+			continue
+		}
+
+		restRay.maxDist = ray.maxDist
+		if sc.leafClosestHit(&node.node, &restRay, &hit) {
+			ray.maxDist = hit.Dist
+			found = true
+		}
+	}
+
+	return hit, found
+}
+
+// Check if a ray intersects the swept bounds of a node.
+func (b *MotionBvh) intersectsNode(node *motionBvhNode, ray *traversalRay) bool {
+	if b.BoundsType != SphereBounds {
+		return ray.intersectsBBox(node.min, node.max)
+	}
+
+	// Find the point along the ray segment that is closest to the sphere center
+	toCenter := node.center.Sub(ray.origin)
+	dirLenSq := ray.dir.Dot(ray.dir)
+	if dirLenSq == 0 {
+		return false
+	}
+	t := toCenter.Dot(ray.dir) / dirLenSq
+	if t < 0 {
+		t = 0
+	} else if t > ray.maxDist {
+		t = ray.maxDist
+	}
+
+	delta := ray.origin.Add(ray.dir.Mul(t)).Sub(node.center)
+	return delta.Dot(delta) <= node.radius*node.radius
+}
+
+// Calculate a sphere that encloses a rest pose sphere over the motion
+// interval. The sphere center sweeps a circular arc around the rotation axis;
+// the returned sphere is centered at the midpoint of the arc chord. Rotations
+// of half a turn or more are bounded by a sphere centered on the axis.
+func (m RotationMotion) sweptSphere(center types.Vec3, radius float32) (types.Vec3, float32) {
+	theta := math.Abs(float64(m.Angle))
+	axisPoint, axisDist := m.axisProjection(center)
+	if theta >= math.Pi {
+		return axisPoint, axisDist + radius
+	}
+
+	arcMid := m.TransformAt(0.5).Mul4x1(center.Vec4(1)).Vec3()
+	chordMid := axisPoint.Add(arcMid.Sub(axisPoint).Mul(float32(math.Cos(theta / 2))))
+	return chordMid, axisDist*float32(math.Sin(theta/2)) + radius
+}
+
+// Calculate a box that encloses a rest pose box over the motion interval. The
+// box corners are rotated in steps of at most 1/16th of a turn and the result
+// is padded by the maximum distance between a corner arc and its chords.
+func (m RotationMotion) sweptBBox(bbox [2]types.Vec3) (types.Vec3, types.Vec3) {
+	theta := math.Abs(float64(m.Angle))
+	steps := int(math.Ceil(theta / (math.Pi / 8)))
+	if steps < 1 {
+		steps = 1
+	}
+
+	stepTransforms := make([]types.Mat4, steps+1)
+	for step := 0; step <= steps; step++ {
+		stepTransforms[step] = m.TransformAt(float32(step) / float32(steps))
+	}
+
+	out := emptyBBox()
+	var maxAxisDist float32
+	for corner := 0; corner < 8; corner++ {
+		p := types.Vec3{bbox[corner&1][0], bbox[(corner>>1)&1][1], bbox[(corner>>2)&1][2]}
+		_, axisDist := m.axisProjection(p)
+		maxAxisDist = maxFloat32(maxAxisDist, axisDist)
+
+		for _, transform := range stepTransforms {
+			q := transform.Mul4x1(p.Vec4(1)).Vec3()
+			out[0] = types.MinVec3(out[0], q)
+			out[1] = types.MaxVec3(out[1], q)
+		}
+	}
+
+	pad := maxAxisDist * float32(1-math.Cos(theta/float64(steps)/2))
+	padVec := types.Vec3{pad, pad, pad}
+	return out[0].Sub(padVec), out[1].Add(padVec)
+}
+
+// Get the rest pose vertices of the primitives referenced by a BVH leaf.
+func leafPoints(sc *Scene, node *BvhNode) []types.Vec3 {
+	var points []types.Vec3
+	if node.IsQuadLeaf() {
+		firstQuad, count := node.GetQuads()
+		for quadIndex := firstQuad; quadIndex < firstQuad+count; quadIndex++ {
+			for _, v := range sc.QuadList[quadIndex].Vertices {
+				points = append(points, v.Vec3())
+			}
+		}
+		return points
+	}
+
+	firstPrim, count := node.GetPrimitives()
+	for vertIndex := 3 * firstPrim; vertIndex < 3*(firstPrim+count); vertIndex++ {
+		points = append(points, sc.VertexList[vertIndex].Vec3())
+	}
+	return points
+}
+
+func maxFloat32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
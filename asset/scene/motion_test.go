@@ -0,0 +1,136 @@
+package scene
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestMotionBvhSpinningFan(t *testing.T) {
+	sc := makeFanTestScene(4, 8)
+	motion := RotationMotion{Axis: types.Vec3{0, 0, 1}, Angle: math.Pi / 2}
+
+	for _, boundsType := range []MotionBoundsType{SweptAABBBounds, SphereBounds} {
+		b, err := sc.BuildMotionBvh(0, motion, boundsType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A ray aimed at the middle of the first blade (primitives 0-15)
+		// should hit it at any time as long as it tracks the blade.
+		for _, time := range []float32{0, 0.25, 0.5, 1} {
+			target := motion.TransformAt(time).Mul4x1(types.Vec4{0.6, 0, 0, 1}).Vec3()
+			hit, found := b.Intersect(sc, target.Add(types.Vec3{0, 0, 1}), types.Vec3{0, 0, -1}, time, 10)
+			if !found || hit.PrimitiveIndex >= 16 || math.Abs(float64(hit.Dist-1)) > 1e-4 {
+				t.Fatalf("[%s] expected ray at t=%f to hit the first blade at distance 1; got %+v (%t)", boundsType, time, hit, found)
+			}
+		}
+
+		// At t=0.5 the blades have rotated by 45 degrees and the gap
+		// between them is located at the rest pose blade position.
+		if hit, found := b.Intersect(sc, types.Vec3{0.6, 0, 1}, types.Vec3{0, 0, -1}, 0.5, 10); found {
+			t.Fatalf("[%s] expected ray to pass between the rotated blades; got %+v", boundsType, hit)
+		}
+
+		// Compare against brute-force intersection of random rays
+		rng := rand.New(rand.NewSource(3))
+		for i := 0; i < 1000; i++ {
+			origin := types.Vec3{rng.Float32()*2.4 - 1.2, rng.Float32()*2.4 - 1.2, 1 + rng.Float32()}
+			dir := types.Vec3{rng.Float32() - 0.5, rng.Float32() - 0.5, -1}
+			time := rng.Float32()
+
+			expHit, expFound := bruteForceMotionHit(sc, motion, origin, dir, time, 10)
+			hit, found := b.Intersect(sc, origin, dir, time, 10)
+			if found != expFound || (found && (hit.PrimitiveIndex != expHit.PrimitiveIndex || hit.Dist != expHit.Dist)) {
+				t.Fatalf("[%s ray %d] expected hit %+v (%t); got %+v (%t)", boundsType, i, expHit, expFound, hit, found)
+			}
+		}
+		t.Logf("%s bounds: %d node visits", boundsType, b.NodeVisits)
+	}
+}
+
+func BenchmarkMotionBvhSweptAABB(b *testing.B) {
+	benchmarkMotionBvh(b, SweptAABBBounds)
+}
+
+func BenchmarkMotionBvhSphere(b *testing.B) {
+	benchmarkMotionBvh(b, SphereBounds)
+}
+
+func benchmarkMotionBvh(b *testing.B, boundsType MotionBoundsType) {
+	sc := makeFanTestScene(6, 32)
+	motion := RotationMotion{Axis: types.Vec3{0, 0, 1}, Angle: 2 * math.Pi}
+	bvh, err := sc.BuildMotionBvh(0, motion, boundsType)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	origins := make([]types.Vec3, 1024)
+	times := make([]float32, len(origins))
+	for index := range origins {
+		origins[index] = types.Vec3{rng.Float32()*2.4 - 1.2, rng.Float32()*2.4 - 1.2, 1}
+		times[index] = rng.Float32()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for index, origin := range origins {
+			bvh.Intersect(sc, origin, types.Vec3{0, 0, -1}, times[index], 10)
+		}
+	}
+	b.ReportMetric(float64(bvh.NodeVisits)/float64(b.N*len(origins)), "nodes/ray")
+}
+
+// Intersect a ray with all scene primitives posed at the specified time.
+func bruteForceMotionHit(sc *Scene, motion RotationMotion, origin, dir types.Vec3, time, maxDist float32) (RayHit, bool) {
+	hit := RayHit{Dist: maxDist}
+	toRest := motion.TransformAt(time).Inv()
+	restOrigin := toRest.Mul4x1(origin.Vec4(1)).Vec3()
+	restDir := toRest.Mul4x1(dir.Vec4(0)).Vec3()
+
+	var found bool
+	for primIndex := uint32(0); primIndex < uint32(len(sc.VertexList)/3); primIndex++ {
+		dist := sc.rayTriangleIntersect(primIndex, restOrigin, restDir)
+		if dist > intersectionEpsilon && dist < hit.Dist {
+			hit.Dist, hit.PrimitiveIndex = dist, primIndex
+			found = true
+		}
+	}
+	return hit, found
+}
+
+// Create a scene with a single instance of a fan that lies on the XY plane and
+// is centered at the origin. The first blade points along the X axis. Each
+// blade spans the [0.2, 1] radius range and is split into segments quads.
+func makeFanTestScene(numBlades, segments int) *Scene {
+	sc := &Scene{}
+	const halfWidth = 0.1
+	segLen := 0.8 / float32(segments)
+	for blade := 0; blade < numBlades; blade++ {
+		rot := types.QuatFromAxisAngle(types.Vec3{0, 0, 1}, 2*math.Pi*float32(blade)/float32(numBlades))
+		for seg := 0; seg < segments; seg++ {
+			r0, r1 := 0.2+float32(seg)*segLen, 0.2+float32(seg+1)*segLen
+			v := [4]types.Vec3{
+				rot.Rotate(types.Vec3{r0, -halfWidth, 0}),
+				rot.Rotate(types.Vec3{r1, -halfWidth, 0}),
+				rot.Rotate(types.Vec3{r1, halfWidth, 0}),
+				rot.Rotate(types.Vec3{r0, halfWidth, 0}),
+			}
+			sc.VertexList = append(sc.VertexList,
+				v[0].Vec4(1), v[1].Vec4(1), v[2].Vec4(1),
+				v[0].Vec4(1), v[2].Vec4(1), v[3].Vec4(1),
+			)
+		}
+	}
+
+	// Reserve the first node for the top-level BVH
+	sc.BvhNodeList = make([]BvhNode, 1)
+	root := buildTestMeshBvh(sc, 0, uint32(len(sc.VertexList)/3))
+	sc.MeshInstanceList = []MeshInstance{{MeshIndex: 0, BvhRoot: root, Transform: types.Ident4()}}
+	sc.BvhNodeList[0].SetBBox([2]types.Vec3{sc.BvhNodeList[root].Min, sc.BvhNodeList[root].Max})
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	return sc
+}
@@ -0,0 +1,30 @@
+package scene
+
+import (
+	"github.com/achilleasa/polaris/asset/material"
+)
+
+// Get the normal map scale of a bxdf node. The scale controls the strength of
+// any normal maps applied to the surface before this bxdf is selected.
+func (n *MaterialNode) NormalScale() float32 {
+	return n.Union6[3]
+}
+
+// Set the normal map scale of a bxdf node. A scale of 0 disables normal
+// mapping, 1 applies normal maps fully and values > 1 exaggerate them.
+func (n *MaterialNode) SetNormalScale(scale float32) {
+	n.Union6[3] = scale
+}
+
+// Get the green channel convention of a normal map node.
+func (n *MaterialNode) NormalMapConvention() material.NormalMapConvention {
+	if n.Union5[0] == int32(material.NormalMapDirectX) {
+		return material.NormalMapDirectX
+	}
+	return material.NormalMapOpenGL
+}
+
+// Set the green channel convention of a normal map node.
+func (n *MaterialNode) SetNormalMapConvention(convention material.NormalMapConvention) {
+	n.Union5[0] = int32(convention)
+}
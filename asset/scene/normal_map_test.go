@@ -0,0 +1,30 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+)
+
+func TestDefaultNormalScale(t *testing.T) {
+	// The default material applies normal maps fully
+	defaultMat := NewDefaultMaterialNode()
+	if defaultMat.NormalScale() != 1 {
+		t.Fatalf("expected default normal scale to be 1; got %f", defaultMat.NormalScale())
+	}
+}
+
+func TestNormalMapConvention(t *testing.T) {
+	var node MaterialNode
+	node.Union5[0] = -1
+	if got := node.NormalMapConvention(); got != material.NormalMapOpenGL {
+		t.Fatalf("expected default convention to be %s; got %s", material.NormalMapOpenGL, got)
+	}
+
+	for _, convention := range []material.NormalMapConvention{material.NormalMapOpenGL, material.NormalMapDirectX} {
+		node.SetNormalMapConvention(convention)
+		if got := node.NormalMapConvention(); got != convention {
+			t.Fatalf("expected node convention to be %s; got %s", convention, got)
+		}
+	}
+}
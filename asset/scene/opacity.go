@@ -0,0 +1,60 @@
+package scene
+
+import "fmt"
+
+// Set the opacity for the material with the specified root node index.
+//
+// Unlike a cutout, opacity values in (0, 1) blend the surface with whatever
+// lies behind it: each time a ray hits the surface it passes straight through
+// it with probability 1-opacity and is shaded otherwise. As the pass-through
+// decision is made before the material is evaluated, refractive materials
+// bend only the fraction of rays that are not passed through.
+func (sc *Scene) SetOpacity(matNodeIndex uint32, opacity float32) error {
+	if int(matNodeIndex) >= len(sc.MaterialNodeList) {
+		return fmt.Errorf("scene: invalid material node index %d", matNodeIndex)
+	}
+	if err := checkOpacity(opacity); err != nil {
+		return err
+	}
+
+	for int(matNodeIndex) >= len(sc.Opacities) {
+		sc.Opacities = append(sc.Opacities, 1)
+	}
+	sc.Opacities[matNodeIndex] = opacity
+	return nil
+}
+
+// Get the opacity of the material with the specified root node index.
+func (sc *Scene) MaterialOpacity(matNodeIndex uint32) float32 {
+	if int(matNodeIndex) >= len(sc.Opacities) {
+		return 1
+	}
+	return sc.Opacities[matNodeIndex]
+}
+
+// Get the opacities for all material nodes in the layout used by the opencl
+// kernels. Returns nil if the scene does not define any opacities.
+func (sc *Scene) OpacityList() []float32 {
+	if len(sc.Opacities) == 0 {
+		return nil
+	}
+
+	opacities := make([]float32, len(sc.MaterialNodeList))
+	for index := range opacities {
+		opacities[index] = sc.MaterialOpacity(uint32(index))
+	}
+	return opacities
+}
+
+// Check whether a ray passes through a surface with the given opacity
+// using a uniform random sample in [0, 1).
+func PassesThrough(opacity, sample float32) bool {
+	return sample >= opacity
+}
+
+func checkOpacity(opacity float32) error {
+	if !(opacity >= 0 && opacity <= 1) {
+		return fmt.Errorf("scene: opacity must be in the [0, 1] range; got %f", opacity)
+	}
+	return nil
+}
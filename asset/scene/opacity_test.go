@@ -0,0 +1,47 @@
+package scene
+
+import "testing"
+
+func TestSetOpacity(t *testing.T) {
+	sc := &Scene{MaterialNodeList: make([]MaterialNode, 4)}
+	if sc.OpacityList() != nil {
+		t.Fatal("expected opacity list to be nil for a scene without opacities")
+	}
+
+	type spec struct {
+		matNodeIndex uint32
+		opacity      float32
+		expErr       bool
+	}
+	specs := []spec{
+		spec{2, 0.25, false},
+		spec{0, 0, false},
+		spec{1, 1.5, true},
+		spec{1, -0.5, true},
+		spec{4, 0.5, true},
+	}
+	for index, s := range specs {
+		err := sc.SetOpacity(s.matNodeIndex, s.opacity)
+		if s.expErr && err == nil {
+			t.Errorf("[spec %d] expected an error", index)
+		} else if !s.expErr && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+
+	expList := []float32{0, 1, 0.25, 1}
+	list := sc.OpacityList()
+	if len(list) != len(expList) {
+		t.Fatalf("expected opacity list with %d entries; got %d", len(expList), len(list))
+	}
+	for index, exp := range expList {
+		if list[index] != exp {
+			t.Errorf("expected opacity for material node %d to be %f; got %f", index, exp, list[index])
+		}
+	}
+
+	sc.Opacities[1] = 2
+	if err := sc.Validate(); err == nil {
+		t.Fatal("expected validation to fail for an out of range opacity")
+	}
+}
@@ -3,9 +3,9 @@ package scene
 import (
 	"bytes"
 	"fmt"
-	"reflect"
 	"strings"
 
+	"github.com/achilleasa/polaris/asset/material"
 	"github.com/achilleasa/polaris/asset/texure"
 	"github.com/achilleasa/polaris/types"
 	"github.com/olekukonko/tablewriter"
@@ -20,6 +20,9 @@ import (
 // - For bottom BVH leafs:
 //   - left W is <= 0 and point to the first triangle primitive index
 //   - right W is >0 and contains the count of leaf primitives
+// - For bottom BVH quad leafs:
+//   - left W is <= 0 and points to the first quad index
+//   - right W is <0 and contains the negated count of leaf quads
 //
 //
 type BvhNode struct {
@@ -63,6 +66,22 @@ func (n *BvhNode) GetPrimitives() (firstPrimIndex, count uint32) {
 	return uint32(-n.LData), uint32(n.RData)
 }
 
+// Set quad index and count.
+func (n *BvhNode) SetQuads(firstQuadIndex, count uint32) {
+	n.LData = -int32(firstQuadIndex)
+	n.RData = -int32(count)
+}
+
+// Get quad index and count.
+func (n *BvhNode) GetQuads() (firstQuadIndex, count uint32) {
+	return uint32(-n.LData), uint32(-n.RData)
+}
+
+// Check if this is a bottom BVH leaf containing quads.
+func (n *BvhNode) IsQuadLeaf() bool {
+	return n.LData <= 0 && n.RData < 0
+}
+
 // Add offset to indices of child nodes.
 func (n *BvhNode) OffsetChildNodes(offset int32) {
 	// Ignore leafs
@@ -84,29 +103,69 @@ type MaterialNode struct {
 	// [0] type
 	// [1] left child
 	// [2] right child or transmittance texture
-	// [3] bump map, reflectance, specularity or radiance texture
+	// [3] bump map, reflectance, specularity or radiance texture or coat
+	//     normal map (layered)
 	Union1 [4]int32
 
 	// Layout:
 	// [0-3] reflectance or specularity or radiance
 	// [0-3] RGB intIORs for dispersion
 	// [0] mix weight
+	// [0-2] coat transmittance, [3] coat thickness for layered nodes
 	Union2 types.Vec4
 
 	// Layout:
 	// [0-3] transmittance
 	// [0-3] RGB extIORs for dispersion
+	// [0-3] RGB conductor eta (real part of complex IOR)
+	// [0] coat internal reflectance, [1] base albedo for layered nodes
+	// [0] emission falloff radius for emissive nodes
 	Union3 types.Vec4
 
 	// Layout:
 	// [0] internal IOR
 	// [1] external IOR
-	// [2] roughness or radiance scaler
+	// [2] roughness, radiance scaler or subsurface mean free path
 	Union4 types.Vec3
 
 	// Layout:
-	// [0] roughness texture
+	// [0] roughness texture, emission side, vertex color flag (diffuse) or
+	//     normal map convention (normal map, layered)
 	Union5 [1]int32
+
+	// Layout:
+	// [0-3] RGB conductor k (imaginary part of complex IOR)
+	// [3] normal map scale
+	Union6 types.Vec4
+}
+
+// Get the radiance emitted by an emissive material node. The emitted radiance
+// is calculated by multiplying the node's radiance color with its radiance
+// scaler. If the node uses a radiance texture, this method returns the value
+// of the radiance scaler for all channels. Non-emissive nodes do not emit any
+// radiance.
+func (n *MaterialNode) Emission() types.Vec3 {
+	if n.Union1[0] != int32(material.BxdfEmissive) {
+		return types.Vec3{}
+	}
+
+	if n.Union1[3] != -1 {
+		return types.Vec3{n.Union4[2], n.Union4[2], n.Union4[2]}
+	}
+
+	return n.Union2.Vec3().Mul(n.Union4[2])
+}
+
+// Set the internal IOR of a material node to the value of a named preset (see
+// material.IORPresets). Returns an error if the preset is not known.
+func (n *MaterialNode) SetIORPreset(name string) error {
+	ior, err := material.IORPreset(name)
+	if err != nil {
+		return err
+	}
+
+	n.Union4[0] = ior
+	return nil
 }
 
 // The type of an emissive primitive.
@@ -115,12 +174,15 @@ type EmissivePrimitiveType uint32
 const (
 	AreaLight EmissivePrimitiveType = iota
 	EnvironmentLight
+	DirectionalLight
 )
 
 // An emissive primitive.
 type EmissivePrimitive struct {
-	// A transformation matrix for converting the primitive vertices from
-	// local space to world space.
+	// A transformation matrix for converting from world space to the local
+	// space of the primitive. For area lights this is the transformation
+	// matrix of the mesh instance that owns the primitive. The opencl
+	// tracer uploads the inverse of this matrix (see EmissiveSamplerList).
 	Transform types.Mat4
 
 	// The area of the emissive primitive.
@@ -145,7 +207,10 @@ type MeshInstance struct {
 	// instances of the same mesh.
 	BvhRoot uint32
 
-	padding [2]uint32
+	// Set by MarkDirty to force the recalculation of the cached world
+	// bounds for this instance.
+	dirty   uint32
+	padding uint32
 
 	// A transformation matrix for positioning the mesh.
 	Transform types.Mat4
@@ -162,6 +227,12 @@ type TextureMetadata struct {
 
 	// Offset to the beginning of texture data
 	DataOffset uint32
+
+	// The number of mip levels stored in the texture data. Each level
+	// follows the previous one and is padded to a multiple of 4 bytes. A
+	// value of 0 or 1 indicates that only the base level is present. The
+	// opencl kernels only sample the base level.
+	MipLevels uint32
 }
 
 type Scene struct {
@@ -170,6 +241,27 @@ type Scene struct {
 	MaterialNodeList   []MaterialNode
 	EmissivePrimitives []EmissivePrimitive
 
+	// Optional per-material uv transforms indexed by the material root
+	// node index. Materials without an entry use the identity transform;
+	// see SetUVTransform.
+	UVTransforms []UVTransform
+
+	// Optional per-material opacities indexed by the material root node
+	// index. Materials without an entry are opaque; see SetOpacity.
+	Opacities []float32
+
+	// Optional light links for restricting the instances illuminated by
+	// each emissive; see SetLightLink.
+	LightLinks []LightLink
+
+	// Optional named render layers for generating per-layer coverage
+	// masks; see SetRenderLayer.
+	RenderLayers []RenderLayer
+
+	// Optional rigid motion keyframes indexed by the mesh instance index.
+	// Instances without keyframes are static; see SetInstanceKeyframes.
+	InstanceKeyframes [][]TransformKeyframe
+
 	// Texture definitions and the associated data.
 	TextureData     []byte
 	TextureMetadata []TextureMetadata
@@ -180,13 +272,49 @@ type Scene struct {
 	UvList        []types.Vec2
 	MaterialIndex []uint32
 
+	// Optional per-vertex colors using the same layout as VertexList.
+	// Diffuse nodes that enable the vertexColors parameter use the
+	// interpolated color as their reflectance. Vertex colors are ignored
+	// if the list is empty.
+	VertexColorList []types.Vec4
+
+	// Planar quad primitives referenced by quad BVH leafs.
+	QuadList []Quad
+
 	// Indices to material nodes used for storing the scene global
 	// properties such as diffuse and emissive colors.
 	SceneDiffuseMatIndex  int32
 	SceneEmissiveMatIndex int32
 
+	// An optional material node for shading primitives with an invalid
+	// material index; see ApplyMaterialFallback.
+	DefaultMaterial *MaterialNode
+
+	// The environment map rotation around the Y axis in radians. Use
+	// SetEnvironmentYaw to modify this value so that any environment
+	// emissives are kept in sync.
+	EnvironmentYaw float32
+
+	// Optional ground projection for occluding the environment below the
+	// horizon.
+	GroundProjection GroundProjection
+
+	// Optional homogeneous fog that fills the entire scene.
+	GlobalFog GlobalFog
+
+	// The number of scene units per meter (e.g. 1000 for scenes modelled
+	// in millimeters). It is used for deriving the ray epsilon; see
+	// RayEpsilon. If 0, the scene is assumed to be modelled in meters.
+	UnitsPerMeter float32
+
 	// The scene camera.
 	Camera *Camera
+
+	// Cached world-space bounds for the mesh instances.
+	instanceBounds instanceBoundsCache
+
+	// Cached light sampling data.
+	lightCache lightCache
 }
 
 // Build a tabular representation of scene statistics.
@@ -196,10 +324,11 @@ func (sc *Scene) Stats() string {
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.SetAutoFormatHeaders(false)
 	table.SetHeader([]string{"Asset Type", "Asset", "Size"})
-	table.Append([]string{"Geometry", "---", fmtSize(sc.VertexList, sc.NormalList, sc.UvList, sc.BvhNodeList)})
+	table.Append([]string{"Geometry", "---", fmtSize(sc.VertexList, sc.NormalList, sc.UvList, sc.VertexColorList, sc.BvhNodeList)})
 	table.Append([]string{"", "Vertices", fmtSize(sc.VertexList)})
 	table.Append([]string{"", "Normals", fmtSize(sc.NormalList)})
 	table.Append([]string{"", "UVs", fmtSize(sc.UvList)})
+	table.Append([]string{"", "Vertex colors", fmtSize(sc.VertexColorList)})
 	table.Append([]string{"", "BVH", fmtSize(sc.BvhNodeList)})
 	table.Append([]string{" ", " ", " "})
 	table.Append([]string{"Mesh/emissives", "---", fmtSize(sc.MeshInstanceList, sc.EmissivePrimitives)})
@@ -213,7 +342,7 @@ func (sc *Scene) Stats() string {
 	table.Append([]string{"Textures", "---", fmtSize(sc.TextureMetadata, sc.TextureData)})
 	table.Append([]string{"", "Metadata", fmtSize(sc.TextureMetadata)})
 	table.Append([]string{"", "Data", fmtSize(sc.TextureData)})
-	table.SetFooter([]string{"Total", " ", strings.TrimLeft(fmtSize(sc.VertexList, sc.NormalList, sc.UvList, sc.BvhNodeList, sc.MeshInstanceList, sc.EmissivePrimitives, sc.MaterialNodeList, sc.MaterialIndex, sc.TextureMetadata, sc.TextureData), " ")})
+	table.SetFooter([]string{"Total", " ", strings.TrimLeft(fmtSize(sc.VertexList, sc.NormalList, sc.UvList, sc.VertexColorList, sc.BvhNodeList, sc.MeshInstanceList, sc.EmissivePrimitives, sc.MaterialNodeList, sc.MaterialIndex, sc.TextureMetadata, sc.TextureData), " ")})
 
 	table.Render()
 	return buf.String()
@@ -222,17 +351,11 @@ func (sc *Scene) Stats() string {
 // Sum the total space used by a set of slices and return back a formatted
 // value with the appropriate byte/kb/mb unit.
 func fmtSize(items ...interface{}) string {
-	var totalBytes float32 = 0.0
-	for _, item := range items {
-		t := reflect.TypeOf(item)
-		v := reflect.ValueOf(item)
-		if v.Len() == 0 {
-			continue
-		}
-
-		totalBytes += float32(int(t.Elem().Size()) * v.Len())
-	}
+	return fmtBytes(float32(sliceBytes(items...)))
+}
 
+// Format a byte count using the appropriate byte/kb/mb unit.
+func fmtBytes(totalBytes float32) string {
 	if totalBytes < 1e3 {
 		return fmt.Sprintf("%3d bytes", int(totalBytes))
 	} else if totalBytes < 1e6 {
@@ -0,0 +1,77 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestEmissionIntensity(t *testing.T) {
+	color := types.Vec4{1.0, 0.5, 0.25, 0}
+	type spec struct {
+		scale  float32
+		expVal types.Vec3
+	}
+	specs := []spec{
+		spec{1, types.Vec3{1.0, 0.5, 0.25}},
+		spec{4, types.Vec3{4.0, 2.0, 1.0}},
+	}
+
+	var emissions []types.Vec3
+	for index, s := range specs {
+		node := MaterialNode{
+			Union1: [4]int32{int32(material.BxdfEmissive), -1, -1, -1},
+			Union2: color,
+			Union4: types.Vec3{0, 0, s.scale},
+		}
+
+		emission := node.Emission()
+		if !types.ApproxEqual(emission, s.expVal, 1e-5) {
+			t.Fatalf("[spec %d] expected emission to be %v; got %v", index, s.expVal, emission)
+		}
+		emissions = append(emissions, emission)
+	}
+
+	// Scaling the intensity should not affect the hue
+	for c := 0; c < 3; c++ {
+		if ratio := emissions[1][c] / emissions[0][c]; ratio != 4.0 {
+			t.Fatalf("expected radiance ratio for channel %d to be 4; got %f", c, ratio)
+		}
+	}
+
+	// Non-emissive nodes should not emit any radiance
+	node := MaterialNode{
+		Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1},
+		Union2: color,
+		Union4: types.Vec3{0, 0, 1},
+	}
+	if emission := node.Emission(); emission != (types.Vec3{}) {
+		t.Fatalf("expected diffuse node emission to be zero; got %v", emission)
+	}
+}
+
+func TestSetIORPreset(t *testing.T) {
+	node := MaterialNode{
+		Union1: [4]int32{int32(material.BxdfDielectric), -1, -1, -1},
+		Union4: types.Vec3{material.DefaultIntIOR, material.DefaultExtIOR, 0},
+	}
+
+	if err := node.SetIORPreset("Diamond"); err != nil {
+		t.Fatal(err)
+	}
+	if node.Union4[0] != 2.42 {
+		t.Fatalf("expected internal IOR to be 2.42; got %f", node.Union4[0])
+	}
+	if node.Union4[1] != material.DefaultExtIOR {
+		t.Fatalf("expected external IOR to remain %f; got %f", material.DefaultExtIOR, node.Union4[1])
+	}
+
+	expError := `unknown IOR preset "unobtainium"`
+	if err := node.SetIORPreset("unobtainium"); err == nil || err.Error() != expError {
+		t.Fatalf("expected error %q; got %v", expError, err)
+	}
+	if node.Union4[0] != 2.42 {
+		t.Fatalf("expected failed preset lookup to leave IOR unchanged; got %f", node.Union4[0])
+	}
+}
@@ -0,0 +1,304 @@
+package scene
+
+import "github.com/achilleasa/polaris/types"
+
+// The number of rays in a RayPacket.
+const RayPacketSize = 4
+
+// A packet of neighboring rays that are traced together through the scene
+// BVH. Packet traversal amortizes node fetches across coherent rays such as
+// primary rays for adjacent pixels or shadow rays towards the same light.
+type RayPacket struct {
+	Origin  [RayPacketSize]types.Vec3
+	Dir     [RayPacketSize]types.Vec3
+	MaxDist [RayPacketSize]float32
+}
+
+// Check whether the rays selected by activeMask travel towards the same
+// octant. Rays that point to different octants diverge quickly and gain
+// nothing from being traversed as a packet.
+func raysCoherent(rays *[RayPacketSize]traversalRay, activeMask uint8) bool {
+	var first *traversalRay
+	for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+		if activeMask&(1<<uint(rayIndex)) == 0 {
+			continue
+		}
+
+		ray := &rays[rayIndex]
+		if first == nil {
+			first = ray
+			continue
+		}
+		for axis := 0; axis < 3; axis++ {
+			if (ray.dir[axis] < 0) != (first.dir[axis] < 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Find the closest intersection for each ray in the packet. The returned
+// flags indicate which rays hit a primitive.
+//
+// This is an opt-in fast path for coherent rays that returns the same hits as
+// calling IntersectWithScratch for each ray. Packets with rays pointing to
+// different octants as well as double precision traversals fall back to
+// single-ray traversal. As instance transforms may rotate the rays of a
+// packet into different octants, the check is repeated in the mesh space of
+// each visited instance.
+func (sc *Scene) IntersectPacket(scratch *RayScratch, packet *RayPacket) (hits [RayPacketSize]RayHit, found [RayPacketSize]bool) {
+	var rays [RayPacketSize]traversalRay
+	for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+		rays[rayIndex] = newTraversalRay(SinglePrecision, packet.Origin[rayIndex], packet.Dir[rayIndex], packet.MaxDist[rayIndex])
+		hits[rayIndex].Dist = packet.MaxDist[rayIndex]
+	}
+
+	if scratch.Precision == DoublePrecision || !raysCoherent(&rays, allRaysMask) {
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			hits[rayIndex], found[rayIndex] = sc.IntersectWithScratch(scratch, packet.Origin[rayIndex], packet.Dir[rayIndex], packet.MaxDist[rayIndex])
+		}
+		return hits, found
+	}
+
+	if len(sc.BvhNodeList) == 0 {
+		return hits, found
+	}
+
+	var meshRays [RayPacketSize]traversalRay
+	bounds := newPacketBounds(&rays, allRaysMask)
+	scratch.nodeStack = append(scratch.nodeStack[:0], 0)
+	for len(scratch.nodeStack) != 0 {
+		node := &sc.BvhNodeList[scratch.nodeStack[len(scratch.nodeStack)-1]]
+		scratch.nodeStack = scratch.nodeStack[:len(scratch.nodeStack)-1]
+
+		if !bounds.mayIntersectBBox(node.Min, node.Max) {
+			continue
+		}
+		mask := packetBBoxMask(&rays, node, allRaysMask)
+		if mask == 0 {
+			continue
+		}
+
+		if node.LData > 0 {
+			scratch.nodeStack = append(scratch.nodeStack, node.LData, node.RData)
+			continue
+		}
+
+		// Transform the active rays to mesh space and check the mesh BVH
+		instance := node.GetMeshIndex()
+		mi := &sc.MeshInstanceList[instance]
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			if mask&(1<<uint(rayIndex)) != 0 {
+				meshRays[rayIndex] = rays[rayIndex].transform(mi.Transform)
+			}
+		}
+
+		var hitMask uint8
+		if raysCoherent(&meshRays, mask) {
+			hitMask = sc.meshPacketClosestHit(scratch, mi.BvhRoot, &meshRays, mask, &hits)
+		} else {
+			for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+				bit := uint8(1 << uint(rayIndex))
+				if mask&bit != 0 && sc.meshClosestHit(scratch, mi.BvhRoot, &meshRays[rayIndex], &hits[rayIndex]) {
+					hitMask |= bit
+				}
+			}
+		}
+		if hitMask == 0 {
+			continue
+		}
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			if hitMask&(1<<uint(rayIndex)) != 0 {
+				hits[rayIndex].MeshInstance = instance
+				rays[rayIndex].maxDist = hits[rayIndex].Dist
+				found[rayIndex] = true
+			}
+		}
+		bounds = newPacketBounds(&rays, allRaysMask)
+	}
+
+	return hits, found
+}
+
+// A bitmask with a bit set for each packet ray.
+const allRaysMask = 1<<RayPacketSize - 1
+
+// Find the closest intersections of the rays selected by mask with the
+// primitives of the mesh BVH tree rooted at the specified node. Returns a mask
+// with the rays for which a closer hit was found.
+func (sc *Scene) meshPacketClosestHit(scratch *RayScratch, rootNode uint32, rays *[RayPacketSize]traversalRay, activeMask uint8, hits *[RayPacketSize]RayHit) uint8 {
+	var hitMask uint8
+	bounds := newPacketBounds(rays, activeMask)
+	scratch.meshStack = append(scratch.meshStack[:0], int32(rootNode))
+	for len(scratch.meshStack) != 0 {
+		node := &sc.BvhNodeList[scratch.meshStack[len(scratch.meshStack)-1]]
+		scratch.meshStack = scratch.meshStack[:len(scratch.meshStack)-1]
+
+		if !bounds.mayIntersectBBox(node.Min, node.Max) {
+			continue
+		}
+		mask := packetBBoxMask(rays, node, activeMask)
+		if mask == 0 {
+			continue
+		}
+
+		if node.LData > 0 {
+			scratch.meshStack = append(scratch.meshStack, node.LData, node.RData)
+			continue
+		}
+
+		leafHitMask := sc.leafPacketClosestHit(node, rays, mask, hits)
+		if leafHitMask != 0 {
+			hitMask |= leafHitMask
+			bounds = newPacketBounds(rays, activeMask)
+		}
+	}
+
+	return hitMask
+}
+
+// Test the packet rays selected by mask against the primitives of a BVH leaf
+// and update their hits and max distances if a closer intersection is found.
+// Returns a mask with the rays for which a closer hit was found.
+func (sc *Scene) leafPacketClosestHit(node *BvhNode, rays *[RayPacketSize]traversalRay, mask uint8, hits *[RayPacketSize]RayHit) uint8 {
+	var hitMask uint8
+	if node.IsQuadLeaf() {
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			if mask&(1<<uint(rayIndex)) != 0 && sc.leafClosestHit(node, &rays[rayIndex], &hits[rayIndex]) {
+				hitMask |= 1 << uint(rayIndex)
+			}
+		}
+		return hitMask
+	}
+
+	// Fetch each triangle once and test it against all active rays
+	firstPrim, count := node.GetPrimitives()
+	for primIndex := firstPrim; primIndex < firstPrim+count; primIndex++ {
+		v0, edge01, edge02 := sc.triangleEdges(primIndex)
+		normal := edge01.Cross(edge02)
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			bit := uint8(1 << uint(rayIndex))
+			if mask&bit == 0 {
+				continue
+			}
+
+			ray := &rays[rayIndex]
+			t := rayTriangleEdgesIntersect(v0, edge01, edge02, ray.origin, ray.dir)
+			if t > intersectionEpsilon && t < ray.maxDist {
+				ray.maxDist = t
+				hits[rayIndex].Dist, hits[rayIndex].PrimitiveIndex, hits[rayIndex].Quad = t, primIndex, false
+				hits[rayIndex].FrontFace = ray.facesNormal(normal)
+				hitMask |= bit
+			}
+		}
+	}
+	return hitMask
+}
+
+// Test the rays selected by activeMask against a node bbox and return a mask
+// with the rays that intersect it.
+func packetBBoxMask(rays *[RayPacketSize]traversalRay, node *BvhNode, activeMask uint8) uint8 {
+	var mask uint8
+	for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+		bit := uint8(1 << uint(rayIndex))
+		ray := &rays[rayIndex]
+		if activeMask&bit != 0 && rayIntersectsBBox(ray.origin, ray.invDir, node.Min, node.Max, ray.maxDist) {
+			mask |= bit
+		}
+	}
+	return mask
+}
+
+// Conservative bounds for the origins and inverse directions of a set of
+// packet rays. The bounds allow rejecting a bbox for the entire packet using a
+// single interval arithmetic slab test.
+type packetBounds struct {
+	originMin, originMax types.Vec3
+	invDirMin, invDirMax types.Vec3
+	maxDist              float32
+	negDir               [3]bool
+}
+
+// Calculate the bounds for the packet rays selected by activeMask. All packet
+// rays are assumed to point to the same octant.
+func newPacketBounds(rays *[RayPacketSize]traversalRay, activeMask uint8) packetBounds {
+	var bounds packetBounds
+	first := true
+	for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+		if activeMask&(1<<uint(rayIndex)) == 0 {
+			continue
+		}
+
+		ray := &rays[rayIndex]
+		if first {
+			bounds.originMin, bounds.originMax = ray.origin, ray.origin
+			bounds.invDirMin, bounds.invDirMax = ray.invDir, ray.invDir
+			bounds.maxDist = ray.maxDist
+			for axis := 0; axis < 3; axis++ {
+				bounds.negDir[axis] = ray.dir[axis] < 0
+			}
+			first = false
+			continue
+		}
+
+		bounds.originMin = types.MinVec3(bounds.originMin, ray.origin)
+		bounds.originMax = types.MaxVec3(bounds.originMax, ray.origin)
+		bounds.invDirMin = types.MinVec3(bounds.invDirMin, ray.invDir)
+		bounds.invDirMax = types.MaxVec3(bounds.invDirMax, ray.invDir)
+		if ray.maxDist > bounds.maxDist {
+			bounds.maxDist = ray.maxDist
+		}
+	}
+	return bounds
+}
+
+// Check whether any of the packet rays may intersect a bbox. A false result
+// guarantees that all packet rays miss the bbox.
+func (b *packetBounds) mayIntersectBBox(min, max types.Vec3) bool {
+	tMin, tMax := float32(0), b.maxDist
+	for axis := 0; axis < 3; axis++ {
+		// Calculate a lower bound for the distance to the near slab
+		// plane and an upper bound for the distance to the far slab
+		// plane across all rays.
+		var t0, t1 float32
+		if b.negDir[axis] {
+			n0 := max[axis] - b.originMin[axis]
+			if n0 >= 0 {
+				t0 = n0 * b.invDirMin[axis]
+			} else {
+				t0 = n0 * b.invDirMax[axis]
+			}
+			n1 := min[axis] - b.originMax[axis]
+			if n1 >= 0 {
+				t1 = n1 * b.invDirMax[axis]
+			} else {
+				t1 = n1 * b.invDirMin[axis]
+			}
+		} else {
+			n0 := min[axis] - b.originMax[axis]
+			if n0 >= 0 {
+				t0 = n0 * b.invDirMin[axis]
+			} else {
+				t0 = n0 * b.invDirMax[axis]
+			}
+			n1 := max[axis] - b.originMin[axis]
+			if n1 >= 0 {
+				t1 = n1 * b.invDirMax[axis]
+			} else {
+				t1 = n1 * b.invDirMin[axis]
+			}
+		}
+
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMin > tMax {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestIntersectPacket(t *testing.T) {
+	sc := makePlaneTestScene(16)
+	scratch := NewRayScratch()
+	rng := rand.New(rand.NewSource(1))
+
+	type spec struct {
+		origin types.Vec3
+		spread float32
+	}
+	specs := []spec{
+		// Coherent primary rays
+		spec{types.Vec3{0, 0, 5}, 0.05},
+		// Rays that mostly miss the plane
+		spec{types.Vec3{12, 0, 5}, 0.05},
+		// Divergent rays that require single-ray traversal
+		spec{types.Vec3{0, 0, 5}, 4},
+	}
+
+	for index, s := range specs {
+		for iteration := 0; iteration < 100; iteration++ {
+			var packet RayPacket
+			for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+				packet.Origin[rayIndex] = s.origin
+				packet.Dir[rayIndex] = types.Vec3{
+					(rng.Float32() - 0.5) * s.spread,
+					(rng.Float32() - 0.5) * s.spread,
+					-1,
+				}
+				packet.MaxDist[rayIndex] = 100
+			}
+
+			hits, found := sc.IntersectPacket(scratch, &packet)
+			for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+				expHit, expFound := sc.IntersectWithScratch(scratch, packet.Origin[rayIndex], packet.Dir[rayIndex], packet.MaxDist[rayIndex])
+				if found[rayIndex] != expFound || hits[rayIndex] != expHit {
+					t.Fatalf("[spec %d] expected packet ray %d hit to be %+v (%t); got %+v (%t)", index, rayIndex, expHit, expFound, hits[rayIndex], found[rayIndex])
+				}
+			}
+		}
+	}
+}
+
+func TestIntersectPacketRotatedInstance(t *testing.T) {
+	// Rotate the plane by 45 degrees around the Z axis. Rays that travel
+	// towards the same octant in world space are rotated into different
+	// mesh space octants.
+	sc := makePlaneTestScene(16)
+	sc.MeshInstanceList[0].Transform = types.QuatFromAxisAngle(types.Vec3{0, 0, 1}, math.Pi/4).Mat4().Inv()
+	sc.RebuildTopLevel()
+	scratch := NewRayScratch()
+	rng := rand.New(rand.NewSource(1))
+
+	var hitCount int
+	for iteration := 0; iteration < 1000; iteration++ {
+		var packet RayPacket
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			packet.Origin[rayIndex] = types.Vec3{0, 0, 5}
+			packet.Dir[rayIndex] = types.Vec3{
+				0.01 + rng.Float32()*0.04,
+				0.01 + rng.Float32()*0.04,
+				-1,
+			}
+			packet.MaxDist[rayIndex] = 100
+		}
+
+		hits, found := sc.IntersectPacket(scratch, &packet)
+		for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+			expHit, expFound := sc.IntersectWithScratch(scratch, packet.Origin[rayIndex], packet.Dir[rayIndex], packet.MaxDist[rayIndex])
+			if found[rayIndex] != expFound || hits[rayIndex] != expHit {
+				t.Fatalf("[iteration %d] expected packet ray %d hit to be %+v (%t); got %+v (%t)", iteration, rayIndex, expHit, expFound, hits[rayIndex], found[rayIndex])
+			}
+			if expFound {
+				hitCount++
+			}
+		}
+	}
+
+	if exp := 1000 * RayPacketSize; hitCount != exp {
+		t.Fatalf("expected all %d rays to hit the rotated plane; got %d hits", exp, hitCount)
+	}
+}
+
+func TestIntersectClosestHit(t *testing.T) {
+	// Two stacked planes; rays from above should hit the top one
+	sc := makePlaneTestScene(2)
+	sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{MeshIndex: 0, BvhRoot: sc.MeshInstanceList[0].BvhRoot, Transform: types.Translate4(types.Vec3{0, 0, 1}).Inv()})
+	sc.RebuildTopLevel()
+
+	hit, found := sc.Intersect(types.Vec3{0.1, 0.1, 5}, types.Vec3{0, 0, -1}, 100)
+	if !found {
+		t.Fatal("expected ray to hit the plane")
+	}
+	if hit.MeshInstance != 1 || hit.Dist < 3.99 || hit.Dist > 4.01 {
+		t.Fatalf("expected ray to hit instance 1 at distance 4; got %+v", hit)
+	}
+
+	if _, found = sc.Intersect(types.Vec3{0.1, 0.1, 5}, types.Vec3{0, 0, -1}, 3); found {
+		t.Fatal("expected ray with max dist 3 to miss the planes")
+	}
+}
+
+func BenchmarkPrimaryRaysScalar(b *testing.B) {
+	benchmarkPrimaryRays(b, false)
+}
+
+func BenchmarkPrimaryRaysPacket(b *testing.B) {
+	benchmarkPrimaryRays(b, true)
+}
+
+func benchmarkPrimaryRays(b *testing.B, usePackets bool) {
+	const frameDim = 64
+	sc := makePlaneTestScene(64)
+	scratch := NewRayScratch()
+
+	// Generate primary rays for a camera looking down at the plane. Each
+	// packet covers a 2x2 pixel block.
+	var packets []RayPacket
+	for y := 0; y < frameDim; y += 2 {
+		for x := 0; x < frameDim; x += 2 {
+			var packet RayPacket
+			for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+				px := float32(x+rayIndex%2)/frameDim - 0.5
+				py := float32(y+rayIndex/2)/frameDim - 0.5
+				packet.Origin[rayIndex] = types.Vec3{0, 0, 1}
+				packet.Dir[rayIndex] = types.Vec3{px, py, -1}
+				packet.MaxDist[rayIndex] = 100
+			}
+			packets = append(packets, packet)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for packetIndex := range packets {
+			packet := &packets[packetIndex]
+			if usePackets {
+				sc.IntersectPacket(scratch, packet)
+				continue
+			}
+			for rayIndex := 0; rayIndex < RayPacketSize; rayIndex++ {
+				sc.IntersectWithScratch(scratch, packet.Origin[rayIndex], packet.Dir[rayIndex], packet.MaxDist[rayIndex])
+			}
+		}
+	}
+}
+
+// Measure primary ray throughput when rays are processed in batches of
+// varying size. Each batch generates its rays into a shared buffer before
+// intersecting them, mirroring the wavefront layout used by the tracer.
+func BenchmarkRayBatchSize(b *testing.B) {
+	const frameDim = 256
+	sc := makePlaneTestScene(64)
+	numRays := frameDim * frameDim
+
+	for batchSize := tracer.MinRayBatchSize; batchSize <= 1<<16; batchSize <<= 2 {
+		b.Run(fmt.Sprintf("batch-%d", batchSize), func(b *testing.B) {
+			scratch := NewRayScratch()
+			dirs := make([]types.Vec3, batchSize)
+			origin := types.Vec3{0, 0, 1}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tracer.ForEachRayBatch(numRays, batchSize, func(offset, count int) error {
+					for rayIndex := 0; rayIndex < count; rayIndex++ {
+						pixel := offset + rayIndex
+						dirs[rayIndex] = types.Vec3{
+							float32(pixel%frameDim)/frameDim - 0.5,
+							float32(pixel/frameDim)/frameDim - 0.5,
+							-1,
+						}
+					}
+					for rayIndex := 0; rayIndex < count; rayIndex++ {
+						sc.IntersectWithScratch(scratch, origin, dirs[rayIndex], 100)
+					}
+					return nil
+				})
+			}
+			b.ReportMetric(float64(b.N*numRays)/b.Elapsed().Seconds(), "rays/s")
+		})
+	}
+}
+
+// Create a scene with a single instance of a subdivided plane that spans the
+// [-1, 1] range on the XY plane. The plane is split into dim x dim cells with
+// two triangles each.
+func makePlaneTestScene(dim int) *Scene {
+	sc := &Scene{}
+	cellSize := 2 / float32(dim)
+	for y := 0; y < dim; y++ {
+		for x := 0; x < dim; x++ {
+			x0, y0 := -1+float32(x)*cellSize, -1+float32(y)*cellSize
+			x1, y1 := x0+cellSize, y0+cellSize
+			sc.VertexList = append(sc.VertexList,
+				types.Vec4{x0, y0, 0, 1}, types.Vec4{x1, y0, 0, 1}, types.Vec4{x1, y1, 0, 1},
+				types.Vec4{x0, y0, 0, 1}, types.Vec4{x1, y1, 0, 1}, types.Vec4{x0, y1, 0, 1},
+			)
+		}
+	}
+
+	// Reserve the first node for the top-level BVH
+	sc.BvhNodeList = make([]BvhNode, 1)
+	root := buildTestMeshBvh(sc, 0, uint32(len(sc.VertexList)/3))
+	sc.MeshInstanceList = []MeshInstance{{MeshIndex: 0, BvhRoot: root, Transform: types.Ident4()}}
+	sc.BvhNodeList[0].SetBBox([2]types.Vec3{sc.BvhNodeList[root].Min, sc.BvhNodeList[root].Max})
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	return sc
+}
+
+// Recursively build a mesh BVH by splitting the primitive range in half.
+func buildTestMeshBvh(sc *Scene, firstPrim, count uint32) uint32 {
+	bbox := emptyBBox()
+	for vertIndex := firstPrim * 3; vertIndex < (firstPrim+count)*3; vertIndex++ {
+		bbox[0] = types.MinVec3(bbox[0], sc.VertexList[vertIndex].Vec3())
+		bbox[1] = types.MaxVec3(bbox[1], sc.VertexList[vertIndex].Vec3())
+	}
+
+	nodeIndex := uint32(len(sc.BvhNodeList))
+	sc.BvhNodeList = append(sc.BvhNodeList, BvhNode{})
+	if count <= 4 {
+		sc.BvhNodeList[nodeIndex].SetPrimitives(firstPrim, count)
+	} else {
+		left := buildTestMeshBvh(sc, firstPrim, count/2)
+		right := buildTestMeshBvh(sc, firstPrim+count/2, count-count/2)
+		sc.BvhNodeList[nodeIndex].SetChildNodes(left, right)
+	}
+	sc.BvhNodeList[nodeIndex].SetBBox(bbox)
+	return nodeIndex
+}
@@ -0,0 +1,71 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+// Append a plastic material to the scene material node list and return the
+// index of its root node.
+//
+// Plastic materials are generated as a layered node with a clear dielectric
+// coat over a diffuse base. The coat uses the supplied IOR and roughness
+// (a roughDielectric bxdf for roughness > 0 or a smooth dielectric bxdf
+// otherwise) so the specular and diffuse contributions are automatically
+// weighted by the coat fresnel reflectance. The coat has no absorption and
+// zero thickness so the diffuse color is only attenuated by the fresnel
+// transmission.
+func (sc *Scene) AddPlasticMaterial(diffuse types.Vec3, roughness, ior float32) (uint32, error) {
+	for _, c := range diffuse {
+		if c < 0 || c > 1 {
+			return 0, fmt.Errorf("scene: plastic diffuse color %v must be in the [0, 1] range", diffuse)
+		}
+	}
+	if roughness < 0 || roughness > 1 {
+		return 0, fmt.Errorf("scene: plastic roughness %f must be in the [0, 1] range", roughness)
+	}
+	if ior <= material.DefaultExtIOR {
+		return 0, fmt.Errorf("scene: plastic IOR %f must be greater than the external IOR (%f)", ior, material.DefaultExtIOR)
+	}
+
+	coat := newPlasticNode(material.BxdfDielectric)
+	if roughness > 0 {
+		coat.Union1[0] = int32(material.BxdfRoughDielectric)
+		coat.Union4[2] = roughness
+	}
+	coat.Union2 = material.DefaultSpecularity
+	coat.Union3 = material.DefaultTransmittance
+	coat.Union4[0] = ior
+
+	base := newPlasticNode(material.BxdfDiffuse)
+	base.Union2 = diffuse.Vec4(0)
+
+	coatIndex := int32(len(sc.MaterialNodeList))
+	sc.MaterialNodeList = append(sc.MaterialNodeList, coat, base)
+
+	// Copy the coat interface IORs and absorption into the op node like
+	// the compiler does for layered material expressions.
+	layered := newPlasticNode(0)
+	layered.Union1[0] = int32(material.OpLayered)
+	layered.Union1[1] = coatIndex
+	layered.Union1[2] = coatIndex + 1
+	layered.Union2 = coat.Union3.Vec3().Vec4(0)
+	layered.Union3[0] = material.InternalDiffuseReflectance(coat.Union4[0] / coat.Union4[1])
+	layered.Union3[1] = diffuse.MaxComponent()
+	layered.Union4 = types.Vec3{coat.Union4[0], coat.Union4[1], 0}
+
+	sc.MaterialNodeList = append(sc.MaterialNodeList, layered)
+	return uint32(len(sc.MaterialNodeList) - 1), nil
+}
+
+// Create an untextured material node of the given type with the default IORs.
+func newPlasticNode(nodeType material.BxdfType) MaterialNode {
+	return MaterialNode{
+		Union1: [4]int32{int32(nodeType), -1, -1, -1},
+		Union4: types.Vec3{material.DefaultIntIOR, material.DefaultExtIOR, 0.0},
+		Union5: [1]int32{-1},
+		Union6: types.Vec4{0.0, 0.0, 0.0, material.DefaultNormalScale},
+	}
+}
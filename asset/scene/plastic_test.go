@@ -0,0 +1,85 @@
+package scene
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestAddPlasticMaterial(t *testing.T) {
+	type spec struct {
+		diffuse   types.Vec3
+		roughness float32
+		ior       float32
+		expErr    bool
+		expCoat   material.BxdfType
+	}
+	specs := []spec{
+		spec{types.Vec3{0.8, 0.1, 0.1}, 0.2, 1.5, false, material.BxdfRoughDielectric},
+		spec{types.Vec3{0.1, 0.8, 0.1}, 0, 1.46, false, material.BxdfDielectric},
+		spec{types.Vec3{1.2, 0.1, 0.1}, 0.2, 1.5, true, 0},
+		spec{types.Vec3{0.5, 0.5, 0.5}, -0.1, 1.5, true, 0},
+		spec{types.Vec3{0.5, 0.5, 0.5}, 0.2, 1.0, true, 0},
+	}
+
+	for index, s := range specs {
+		sc := &Scene{MaterialNodeList: []MaterialNode{NewDefaultMaterialNode()}}
+		root, err := sc.AddPlasticMaterial(s.diffuse, s.roughness, s.ior)
+		if s.expErr {
+			if err == nil {
+				t.Errorf("[spec %d] expected to get an error", index)
+			}
+			if len(sc.MaterialNodeList) != 1 {
+				t.Errorf("[spec %d] expected material node list to be left unchanged", index)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+			continue
+		}
+
+		if len(sc.MaterialNodeList) != 4 || root != 3 {
+			t.Errorf("[spec %d] expected 3 nodes to be appended with the root at index 3; got %d nodes and root %d", index, len(sc.MaterialNodeList), root)
+			continue
+		}
+
+		layered := sc.MaterialNodeList[root]
+		if material.OpType(layered.Union1[0]) != material.OpLayered {
+			t.Errorf("[spec %d] expected root to be a layered node; got type %d", index, layered.Union1[0])
+			continue
+		}
+		if layered.Union4[0] != s.ior || layered.Union2 != (types.Vec4{1, 1, 1, 0}) {
+			t.Errorf("[spec %d] expected layered node to have IOR %f and a clear coat; got IOR %f and coat %v", index, s.ior, layered.Union4[0], layered.Union2)
+		}
+
+		coat := sc.MaterialNodeList[layered.Union1[1]]
+		if material.BxdfType(coat.Union1[0]) != s.expCoat || coat.Union4[0] != s.ior || coat.Union4[2] != s.roughness {
+			t.Errorf("[spec %d] expected coat to be a %s bxdf with IOR %f and roughness %f; got %s with IOR %f and roughness %f", index, s.expCoat, s.ior, s.roughness, material.BxdfType(coat.Union1[0]), coat.Union4[0], coat.Union4[2])
+		}
+
+		base := sc.MaterialNodeList[layered.Union1[2]]
+		if material.BxdfType(base.Union1[0]) != material.BxdfDiffuse || base.Union2.Vec3() != s.diffuse {
+			t.Errorf("[spec %d] expected base to be a diffuse bxdf with reflectance %v; got %s with %v", index, s.diffuse, material.BxdfType(base.Union1[0]), base.Union2.Vec3())
+		}
+
+		// The specular coat should be selected more often at grazing angles
+		rng := rand.New(rand.NewSource(0))
+		normal := types.Vec3{0, 0, 1}
+		coatRatio := func(outDir types.Vec3) float32 {
+			var coatHits int
+			for i := 0; i < 2000; i++ {
+				if _, _, coatSelected := sc.traceSelectBxdf(root, normal, outDir, rng); coatSelected {
+					coatHits++
+				}
+			}
+			return float32(coatHits) / 2000
+		}
+		grazing := float32(math.Cos(85 * math.Pi / 180))
+		if head, graze := coatRatio(normal), coatRatio(types.Vec3{float32(math.Sqrt(float64(1 - grazing*grazing))), 0, grazing}); graze <= 2*head {
+			t.Errorf("[spec %d] expected coat to be selected more often at grazing angles; got %f at normal incidence and %f at grazing incidence", index, head, graze)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A quadrilateral primitive. Using quads for flat surfaces such as walls and
+// floors halves the number of primitives compared to using triangle pairs.
+//
+// Only planar, convex quads are supported. The quad is intersected against
+// the plane defined by its diagonals so small deviations from planarity are
+// tolerated but non-planar quads (bilinear patches) will not be rendered
+// correctly.
+//
+// Quads are only intersected by the CPU traversal code; the opencl tracer
+// renders a copy of the scene with triangulated quads. See TriangulateQuads.
+type Quad struct {
+	// Quad vertices in counter-clockwise order.
+	Vertices [4]types.Vec4
+
+	// The UV coordinates for each vertex.
+	Uvs [4]types.Vec2
+
+	// The material node index for this quad.
+	MaterialIndex uint32
+
+	padding [3]uint32
+}
+
+// Get a copy of the scene where each quad is replaced by a pair of triangles
+// that are appended to the scene primitive lists. Quad leafs are converted to
+// leafs that reference the triangles of their quads; as the triangles cover
+// the same area, the BVH node bounds are not affected. The primitive lists and
+// the BVH node list of the copy are reallocated so sc is not modified. If the
+// scene contains no quads, TriangulateQuads returns sc.
+func (sc *Scene) TriangulateQuads() *Scene {
+	if len(sc.QuadList) == 0 {
+		return sc
+	}
+
+	out := *sc
+	firstPrim := uint32(len(sc.MaterialIndex))
+	numVertices := len(sc.VertexList) + 6*len(sc.QuadList)
+	out.VertexList = append(make([]types.Vec4, 0, numVertices), sc.VertexList...)
+	out.NormalList = append(make([]types.Vec4, 0, numVertices), sc.NormalList...)
+	out.UvList = append(make([]types.Vec2, 0, numVertices), sc.UvList...)
+	out.MaterialIndex = append(make([]uint32, 0, len(sc.MaterialIndex)+2*len(sc.QuadList)), sc.MaterialIndex...)
+	if len(sc.VertexColorList) != 0 {
+		out.VertexColorList = append(make([]types.Vec4, 0, numVertices), sc.VertexColorList...)
+	}
+
+	for quadIndex := range sc.QuadList {
+		q := &sc.QuadList[quadIndex]
+		normal := q.Normal().Normalize().Vec4(0)
+		for _, tri := range [2][3]int{{0, 1, 2}, {0, 2, 3}} {
+			for _, v := range tri {
+				out.VertexList = append(out.VertexList, q.Vertices[v].Vec3().Vec4(0))
+				out.NormalList = append(out.NormalList, normal)
+				out.UvList = append(out.UvList, q.Uvs[v])
+				if len(sc.VertexColorList) != 0 {
+					out.VertexColorList = append(out.VertexColorList, types.Vec4{1, 1, 1, 1})
+				}
+			}
+			out.MaterialIndex = append(out.MaterialIndex, q.MaterialIndex)
+		}
+	}
+
+	out.BvhNodeList = append([]BvhNode(nil), sc.BvhNodeList...)
+	for nodeIndex := range out.BvhNodeList {
+		node := &out.BvhNodeList[nodeIndex]
+		if node.IsQuadLeaf() {
+			firstQuad, count := node.GetQuads()
+			node.SetPrimitives(firstPrim+2*firstQuad, 2*count)
+		}
+	}
+	out.QuadList = nil
+	return &out
+}
+
+// Get the quad normal. The normal is calculated using the cross product of
+// the quad diagonals and is not normalized.
+func (q *Quad) Normal() types.Vec3 {
+	diag02 := q.Vertices[2].Vec3().Sub(q.Vertices[0].Vec3())
+	diag13 := q.Vertices[3].Vec3().Sub(q.Vertices[1].Vec3())
+	return diag02.Cross(diag13)
+}
+
+// Get the quad bounding box.
+func (q *Quad) BBox() [2]types.Vec3 {
+	bbox := emptyBBox()
+	for _, v := range q.Vertices {
+		bbox[0] = types.MinVec3(bbox[0], v.Vec3())
+		bbox[1] = types.MaxVec3(bbox[1], v.Vec3())
+	}
+	return bbox
+}
+
+// Calculate the intersection of a ray with the quad. If the ray hits the quad,
+// Intersect returns the hit distance (in units of the dir vector length) and
+// the interpolated UV coordinates at the hit point.
+func (q *Quad) Intersect(origin, dir types.Vec3) (float32, types.Vec2, bool) {
+	t, s, r, hit := q.intersect(origin, dir)
+	if !hit {
+		return -1, types.Vec2{}, false
+	}
+
+	return t, q.interpolateUV(s, r), true
+}
+
+// Calculate the intersection of a ray with the quad plane and map the hit
+// point to the bilinear (s, r) quad coordinates. Vertex 0 maps to (0, 0),
+// vertex 1 to (1, 0), vertex 2 to (1, 1) and vertex 3 to (0, 1).
+func (q *Quad) intersect(origin, dir types.Vec3) (t, s, r float32, hit bool) {
+	v0 := q.Vertices[0].Vec3()
+	normal := q.Normal()
+	nDotD := normal.Dot(dir)
+	if float32(math.Abs(float64(nDotD))) < intersectionEpsilon*intersectionEpsilon {
+		return -1, 0, 0, false
+	}
+
+	t = v0.Sub(origin).Dot(normal) / nDotD
+	if t <= intersectionEpsilon {
+		return -1, 0, 0, false
+	}
+
+	// Project the quad and the hit point to 2D by dropping the dominant
+	// normal axis and invert the bilinear mapping.
+	dropAxis := 0
+	for axis := 1; axis < 3; axis++ {
+		if math.Abs(float64(normal[axis])) > math.Abs(float64(normal[dropAxis])) {
+			dropAxis = axis
+		}
+	}
+	project := func(v types.Vec3) types.Vec2 {
+		switch dropAxis {
+		case 0:
+			return types.Vec2{v[1], v[2]}
+		case 1:
+			return types.Vec2{v[2], v[0]}
+		}
+		return types.Vec2{v[0], v[1]}
+	}
+
+	s, r, hit = invBilinear(
+		project(origin.Add(dir.Mul(t))),
+		project(v0),
+		project(q.Vertices[1].Vec3()),
+		project(q.Vertices[2].Vec3()),
+		project(q.Vertices[3].Vec3()),
+	)
+	return t, s, r, hit
+}
+
+// Bilinearly interpolate the vertex UVs.
+func (q *Quad) interpolateUV(s, r float32) types.Vec2 {
+	var uv types.Vec2
+	weights := [4]float32{(1 - s) * (1 - r), s * (1 - r), s * r, (1 - s) * r}
+	for i, w := range weights {
+		uv[0] += q.Uvs[i][0] * w
+		uv[1] += q.Uvs[i][1] * w
+	}
+	return uv
+}
+
+// Invert the 2D bilinear mapping p = a + s*(b-a) + r*(d-a) + s*r*(a-b+c-d)
+// and return the (s, r) coordinates of point p. Returns false if p lies
+// outside the quad (a, b, c, d).
+func invBilinear(p, a, b, c, d types.Vec2) (float32, float32, bool) {
+	cross2 := func(u, v types.Vec2) float64 {
+		return float64(u[0])*float64(v[1]) - float64(u[1])*float64(v[0])
+	}
+
+	e := b.Sub(a)
+	f := d.Sub(a)
+	g := types.Vec2{a[0] - b[0] + c[0] - d[0], a[1] - b[1] + c[1] - d[1]}
+	h := p.Sub(a)
+
+	k2 := cross2(g, f)
+	k1 := cross2(e, f) + cross2(h, g)
+	k0 := cross2(h, e)
+
+	// Solve for r and then calculate s using the axis with the largest
+	// denominator for numerical stability.
+	solveS := func(r float64) float64 {
+		denomX := float64(e[0]) + float64(g[0])*r
+		denomY := float64(e[1]) + float64(g[1])*r
+		if math.Abs(denomX) > math.Abs(denomY) {
+			return (float64(h[0]) - float64(f[0])*r) / denomX
+		}
+		return (float64(h[1]) - float64(f[1])*r) / denomY
+	}
+	inRange := func(s, r float64) bool {
+		const eps = 1e-6
+		return s >= -eps && s <= 1+eps && r >= -eps && r <= 1+eps
+	}
+
+	// Parallelograms yield a linear equation
+	if math.Abs(k2) < 1e-12 {
+		if k1 == 0 {
+			return 0, 0, false
+		}
+		r := -k0 / k1
+		s := solveS(r)
+		return float32(s), float32(r), inRange(s, r)
+	}
+
+	disc := k1*k1 - 4*k0*k2
+	if disc < 0 {
+		return 0, 0, false
+	}
+	disc = math.Sqrt(disc)
+
+	r := (-k1 - disc) / (2 * k2)
+	s := solveS(r)
+	if !inRange(s, r) {
+		r = (-k1 + disc) / (2 * k2)
+		s = solveS(r)
+	}
+	return float32(s), float32(r), inRange(s, r)
+}
@@ -0,0 +1,162 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestQuadIntersect(t *testing.T) {
+	// A trapezoid lying on the XZ plane
+	quad := &Quad{
+		Vertices: [4]types.Vec4{{0, 0, 0, 1}, {2, 0, 0, 1}, {1.5, 0, 1, 1}, {0.5, 0, 1, 1}},
+		Uvs:      [4]types.Vec2{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+	}
+
+	type spec struct {
+		origin, dir types.Vec3
+		expHit      bool
+		expT        float32
+		expUV       types.Vec2
+	}
+	specs := []spec{
+		spec{types.Vec3{1, 1, 0.5}, types.Vec3{0, -1, 0}, true, 1, types.Vec2{0.5, 0.5}},
+		spec{types.Vec3{0.5, 2, 0.5}, types.Vec3{0, -1, 0}, true, 2, types.Vec2{1.0 / 6.0, 0.5}},
+		spec{types.Vec3{1, -1, 0.25}, types.Vec3{0, 1, 0}, true, 1, types.Vec2{0.5, 0.25}},
+		spec{types.Vec3{2, 1, 0}, types.Vec3{-1, -1, 0}, true, 1, types.Vec2{0.5, 0}},
+		// Outside the trapezoid but inside its bbox
+		spec{types.Vec3{0.2, 1, 0.5}, types.Vec3{0, -1, 0}, false, 0, types.Vec2{}},
+		// Pointing away from the quad
+		spec{types.Vec3{1, 1, 0.5}, types.Vec3{0, 1, 0}, false, 0, types.Vec2{}},
+		// Parallel to the quad
+		spec{types.Vec3{-1, 0, 0.5}, types.Vec3{1, 0, 0}, false, 0, types.Vec2{}},
+	}
+	for index, s := range specs {
+		hitT, uv, hit := quad.Intersect(s.origin, s.dir)
+		if hit != s.expHit {
+			t.Errorf("[spec %d] expected hit to be %t; got %t", index, s.expHit, hit)
+			continue
+		}
+		if !hit {
+			continue
+		}
+		if math.Abs(float64(hitT-s.expT)) > 1e-5 {
+			t.Errorf("[spec %d] expected hit distance to be %f; got %f", index, s.expT, hitT)
+		}
+		if math.Abs(float64(uv[0]-s.expUV[0])) > 1e-5 || math.Abs(float64(uv[1]-s.expUV[1])) > 1e-5 {
+			t.Errorf("[spec %d] expected hit uv to be %v; got %v", index, s.expUV, uv)
+		}
+	}
+}
+
+func TestOccludedQuadLeaf(t *testing.T) {
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()}},
+		BvhNodeList:      make([]BvhNode, 2),
+		QuadList: []Quad{
+			{Vertices: [4]types.Vec4{{0, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 1, 1}, {0, 0, 1, 1}}},
+		},
+	}
+	bbox := sc.QuadList[0].BBox()
+	sc.BvhNodeList[0].SetBBox(bbox)
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox(bbox)
+	sc.BvhNodeList[1].SetQuads(0, 1)
+
+	if !sc.BvhNodeList[1].IsQuadLeaf() || sc.BvhNodeList[0].IsQuadLeaf() {
+		t.Fatal("expected only the bottom BVH leaf to be a quad leaf")
+	}
+	if first, count := sc.BvhNodeList[1].GetQuads(); first != 0 || count != 1 {
+		t.Fatalf("expected quad leaf to contain quads [0, 1); got [%d, %d)", first, first+count)
+	}
+
+	type spec struct {
+		origin  types.Vec3
+		maxDist float32
+		expHit  bool
+	}
+	specs := []spec{
+		spec{types.Vec3{0.5, 1, 0.5}, 2, true},
+		spec{types.Vec3{0.5, 1, 0.5}, 0.5, false},
+		spec{types.Vec3{1.5, 1, 0.5}, 2, false},
+	}
+	for _, precision := range []IntersectionPrecision{SinglePrecision, DoublePrecision} {
+		scratch := NewRayScratch()
+		scratch.Precision = precision
+		for index, s := range specs {
+			if hit := sc.OccludedWithScratch(scratch, s.origin, types.Vec3{0, -1, 0}, s.maxDist); hit != s.expHit {
+				t.Fatalf("[precision %d, spec %d] expected OccludedWithScratch() to return %t; got %t", precision, index, s.expHit, hit)
+			}
+		}
+	}
+}
+
+func TestTriangulateQuads(t *testing.T) {
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 1, Transform: types.Ident4()}},
+		BvhNodeList:      make([]BvhNode, 2),
+		QuadList: []Quad{
+			{
+				Vertices:      [4]types.Vec4{{0, 0, 0, 1}, {1, 0, 0, 1}, {1, 0, 1, 1}, {0, 0, 1, 1}},
+				Uvs:           [4]types.Vec2{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+				MaterialIndex: 3,
+			},
+		},
+	}
+	bbox := sc.QuadList[0].BBox()
+	sc.BvhNodeList[0].SetBBox(bbox)
+	sc.BvhNodeList[0].SetMeshIndex(0)
+	sc.BvhNodeList[1].SetBBox(bbox)
+	sc.BvhNodeList[1].SetQuads(0, 1)
+
+	tri := sc.TriangulateQuads()
+	if len(sc.QuadList) != 1 || !sc.BvhNodeList[1].IsQuadLeaf() {
+		t.Fatal("expected the original scene to remain unmodified")
+	}
+	if len(tri.QuadList) != 0 || tri.BvhNodeList[1].IsQuadLeaf() {
+		t.Fatal("expected the triangulated scene to contain no quads")
+	}
+	if tri.TriangulateQuads() != tri {
+		t.Fatal("expected a scene without quads to be returned as-is")
+	}
+	if first, count := tri.BvhNodeList[1].GetPrimitives(); first != 0 || count != 2 {
+		t.Fatalf("expected leaf to contain primitives [0, 2); got [%d, %d)", first, first+count)
+	}
+	if len(tri.VertexList) != 6 || len(tri.NormalList) != 6 || len(tri.UvList) != 6 {
+		t.Fatalf("expected 6 triangle vertices; got %d", len(tri.VertexList))
+	}
+	for primIndex, matIndex := range tri.MaterialIndex {
+		if matIndex != 3 {
+			t.Errorf("expected primitive %d material index to be 3; got %d", primIndex, matIndex)
+		}
+	}
+
+	for v, quadVertex := range []int{0, 1, 2, 0, 2, 3} {
+		if tri.UvList[v] != sc.QuadList[0].Uvs[quadVertex] {
+			t.Errorf("expected vertex %d uv to be %v; got %v", v, sc.QuadList[0].Uvs[quadVertex], tri.UvList[v])
+		}
+	}
+
+	// The triangles should cover the same area as the quad
+	type spec struct {
+		origin  types.Vec3
+		expHit  bool
+		expPrim uint32
+	}
+	specs := []spec{
+		spec{types.Vec3{0.75, 1, 0.25}, true, 0},
+		spec{types.Vec3{0.25, 1, 0.75}, true, 1},
+		spec{types.Vec3{1.5, 1, 0.5}, false, 0},
+	}
+	for index, s := range specs {
+		hit, found := tri.Intersect(s.origin, types.Vec3{0, -1, 0}, 2)
+		if found != s.expHit {
+			t.Errorf("[spec %d] expected hit to be %t; got %t", index, s.expHit, found)
+			continue
+		}
+		if found && (hit.Quad || hit.PrimitiveIndex != s.expPrim || math.Abs(float64(hit.Dist-1)) > 1e-5) {
+			t.Errorf("[spec %d] expected to hit primitive %d at distance 1; got %+v", index, s.expPrim, hit)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+// Package ply implements an importer for meshes stored in the polygon file
+// format (PLY). Both the ascii and the binary little-endian encodings are
+// supported.
+package ply
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/achilleasa/polaris/asset"
+	"github.com/achilleasa/polaris/asset/compiler"
+	"github.com/achilleasa/polaris/asset/compiler/input"
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The material assigned to imported meshes. If the mesh defines vertex colors,
+// the material uses them instead of its reflectance.
+const (
+	defaultMaterialExpr            = "diffuse(reflectance: {0.7, 0.7, 0.7})"
+	defaultVertexColorMaterialExpr = "diffuse(reflectance: {0.7, 0.7, 0.7}, vertexColors: 1)"
+)
+
+// The size in bytes of each supported property type.
+var typeSizes = map[string]int{
+	"char": 1, "int8": 1,
+	"uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2,
+	"ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4,
+	"uint": 4, "uint32": 4,
+	"float": 4, "float32": 4,
+	"double": 8, "float64": 8,
+}
+
+// Alternative property names for vertex attributes. Exporters do not agree
+// on a single naming scheme for uv coordinates and colors.
+var (
+	uPropNames     = []string{"u", "s", "texture_u", "texture_s"}
+	vPropNames     = []string{"v", "t", "texture_v", "texture_t"}
+	redPropNames   = []string{"red", "diffuse_red", "r"}
+	greenPropNames = []string{"green", "diffuse_green", "g"}
+	bluePropNames  = []string{"blue", "diffuse_blue", "b"}
+	alphaPropNames = []string{"alpha", "diffuse_alpha", "a"}
+	facePropNames  = []string{"vertex_indices", "vertex_index"}
+)
+
+type property struct {
+	name      string
+	valueType string
+
+	// Set for list properties.
+	isList    bool
+	countType string
+}
+
+type element struct {
+	name       string
+	count      int
+	properties []property
+}
+
+// Get the index of the first property matching one of the supplied names or
+// -1 if no property matches.
+func (e *element) propIndex(names ...string) int {
+	for _, name := range names {
+		for index, prop := range e.properties {
+			if prop.name == name {
+				return index
+			}
+		}
+	}
+	return -1
+}
+
+type header struct {
+	binary   bool
+	elements []element
+}
+
+// A reader for property values.
+type valueReader interface {
+	read(valueType string) (float64, error)
+}
+
+// Import a PLY mesh from a file and compile it into a scene. The mesh is
+// assigned a diffuse material that uses the mesh vertex colors if present
+// and the camera is positioned so that it frames the mesh.
+func Import(path string) (*scene.Scene, error) {
+	res, err := asset.NewResource(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	parsedScene, err := Parse(res)
+	if err != nil {
+		return nil, fmt.Errorf("ply: %s: %v", path, err)
+	}
+
+	return compiler.Compile(parsedScene)
+}
+
+// Parse a PLY mesh into a scene that can be processed by the scene compiler.
+// Vertex positions, normals, uv coordinates and colors are imported; faces
+// with more than three vertices are triangulated using a triangle fan.
+func Parse(r io.Reader) (*input.Scene, error) {
+	br := bufio.NewReader(r)
+	hdr, err := parseHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var vr valueReader
+	if hdr.binary {
+		vr = &binaryReader{r: br}
+	} else {
+		scanner := bufio.NewScanner(br)
+		scanner.Split(bufio.ScanWords)
+		vr = &asciiReader{scanner: scanner}
+	}
+
+	m := &mesh{}
+	for index := range hdr.elements {
+		elem := &hdr.elements[index]
+		switch elem.name {
+		case "vertex":
+			err = m.readVertices(vr, elem)
+		case "face":
+			err = m.readFaces(vr, elem)
+		default:
+			err = skipElement(vr, elem)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.toScene()
+}
+
+// Parse the PLY header.
+func parseHeader(br *bufio.Reader) (*header, error) {
+	hdr := &header{}
+	var gotFormat bool
+	for lineNum := 1; ; lineNum++ {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("unexpected end of header")
+		}
+		tokens := strings.Fields(line)
+
+		if lineNum == 1 {
+			if len(tokens) != 1 || tokens[0] != "ply" {
+				return nil, fmt.Errorf("missing ply magic number")
+			}
+			continue
+		}
+
+		if len(tokens) == 0 {
+			continue
+		}
+
+		switch tokens[0] {
+		case "comment", "obj_info":
+		case "format":
+			if len(tokens) != 3 {
+				return nil, fmt.Errorf("header line %d: malformed format declaration", lineNum)
+			}
+			switch tokens[1] {
+			case "ascii":
+			case "binary_little_endian":
+				hdr.binary = true
+			default:
+				return nil, fmt.Errorf("header line %d: unsupported format %q; supported formats: ascii, binary_little_endian", lineNum, tokens[1])
+			}
+			gotFormat = true
+		case "element":
+			if len(tokens) != 3 {
+				return nil, fmt.Errorf("header line %d: malformed element declaration", lineNum)
+			}
+			count, err := strconv.Atoi(tokens[2])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("header line %d: invalid element count %q", lineNum, tokens[2])
+			}
+			hdr.elements = append(hdr.elements, element{name: tokens[1], count: count})
+		case "property":
+			if len(hdr.elements) == 0 {
+				return nil, fmt.Errorf("header line %d: property declared outside of an element", lineNum)
+			}
+			prop, err := parseProperty(tokens)
+			if err != nil {
+				return nil, fmt.Errorf("header line %d: %v", lineNum, err)
+			}
+			elem := &hdr.elements[len(hdr.elements)-1]
+			elem.properties = append(elem.properties, prop)
+		case "end_header":
+			if !gotFormat {
+				return nil, fmt.Errorf("missing format declaration")
+			}
+			return hdr, nil
+		default:
+			return nil, fmt.Errorf("header line %d: unexpected keyword %q", lineNum, tokens[0])
+		}
+	}
+}
+
+// Parse a scalar or list property declaration.
+func parseProperty(tokens []string) (property, error) {
+	var prop property
+	if len(tokens) == 5 && tokens[1] == "list" {
+		prop = property{isList: true, countType: tokens[2], valueType: tokens[3], name: tokens[4]}
+		if _, valid := typeSizes[prop.countType]; !valid {
+			return prop, fmt.Errorf("unsupported property type %q", prop.countType)
+		}
+	} else if len(tokens) == 3 {
+		prop = property{valueType: tokens[1], name: tokens[2]}
+	} else {
+		return prop, fmt.Errorf("malformed property declaration")
+	}
+
+	if _, valid := typeSizes[prop.valueType]; !valid {
+		return prop, fmt.Errorf("unsupported property type %q", prop.valueType)
+	}
+	return prop, nil
+}
+
+// Read all properties of an element instance. Scalar values are stored in
+// values and list values in lists; both slices are indexed by property.
+func readElement(vr valueReader, elem *element, values []float64, lists [][]float64) error {
+	for index, prop := range elem.properties {
+		if !prop.isList {
+			value, err := vr.read(prop.valueType)
+			if err != nil {
+				return err
+			}
+			values[index] = value
+			continue
+		}
+
+		count, err := vr.read(prop.countType)
+		if err != nil {
+			return err
+		}
+		if count < 0 {
+			return fmt.Errorf("invalid list length %v for property %q", count, prop.name)
+		}
+		lists[index] = lists[index][:0]
+		for item := 0; item < int(count); item++ {
+			value, err := vr.read(prop.valueType)
+			if err != nil {
+				return err
+			}
+			lists[index] = append(lists[index], value)
+		}
+	}
+	return nil
+}
+
+// Skip all instances of an element that is not used by the importer.
+func skipElement(vr valueReader, elem *element) error {
+	values := make([]float64, len(elem.properties))
+	lists := make([][]float64, len(elem.properties))
+	for index := 0; index < elem.count; index++ {
+		if err := readElement(vr, elem, values, lists); err != nil {
+			return fmt.Errorf("element %q: %v", elem.name, err)
+		}
+	}
+	return nil
+}
+
+// The mesh data parsed from the file.
+type mesh struct {
+	positions []types.Vec3
+	normals   []types.Vec3
+	uvs       []types.Vec2
+	colors    []types.Vec4
+
+	// Triangulated faces.
+	triangles [][3]int
+}
+
+// Read vertex attributes.
+func (m *mesh) readVertices(vr valueReader, elem *element) error {
+	x, y, z := elem.propIndex("x"), elem.propIndex("y"), elem.propIndex("z")
+	if x == -1 || y == -1 || z == -1 {
+		return fmt.Errorf("vertex element does not define x, y and z properties")
+	}
+	nx, ny, nz := elem.propIndex("nx"), elem.propIndex("ny"), elem.propIndex("nz")
+	hasNormals := nx != -1 && ny != -1 && nz != -1
+	u, v := elem.propIndex(uPropNames...), elem.propIndex(vPropNames...)
+	hasUVs := u != -1 && v != -1
+	red, green, blue := elem.propIndex(redPropNames...), elem.propIndex(greenPropNames...), elem.propIndex(bluePropNames...)
+	alpha := elem.propIndex(alphaPropNames...)
+	hasColors := red != -1 && green != -1 && blue != -1
+
+	values := make([]float64, len(elem.properties))
+	lists := make([][]float64, len(elem.properties))
+	for index := 0; index < elem.count; index++ {
+		if err := readElement(vr, elem, values, lists); err != nil {
+			return fmt.Errorf("vertex %d: %v", index, err)
+		}
+
+		m.positions = append(m.positions, types.Vec3{float32(values[x]), float32(values[y]), float32(values[z])})
+		if hasNormals {
+			m.normals = append(m.normals, types.Vec3{float32(values[nx]), float32(values[ny]), float32(values[nz])})
+		}
+		if hasUVs {
+			m.uvs = append(m.uvs, types.Vec2{float32(values[u]), float32(values[v])})
+		}
+		if hasColors {
+			color := types.Vec4{
+				colorValue(values[red], elem.properties[red].valueType),
+				colorValue(values[green], elem.properties[green].valueType),
+				colorValue(values[blue], elem.properties[blue].valueType),
+				1,
+			}
+			if alpha != -1 {
+				color[3] = colorValue(values[alpha], elem.properties[alpha].valueType)
+			}
+			m.colors = append(m.colors, color)
+		}
+	}
+	return nil
+}
+
+// Read faces and triangulate them.
+func (m *mesh) readFaces(vr valueReader, elem *element) error {
+	indices := elem.propIndex(facePropNames...)
+	if indices == -1 || !elem.properties[indices].isList {
+		return fmt.Errorf("face element does not define a vertex index list")
+	}
+
+	values := make([]float64, len(elem.properties))
+	lists := make([][]float64, len(elem.properties))
+	for index := 0; index < elem.count; index++ {
+		if err := readElement(vr, elem, values, lists); err != nil {
+			return fmt.Errorf("face %d: %v", index, err)
+		}
+
+		face := lists[indices]
+		if len(face) < 3 {
+			return fmt.Errorf("face %d: expected at least 3 vertices; got %d", index, len(face))
+		}
+		for _, vIndex := range face {
+			if vIndex < 0 || int(vIndex) >= len(m.positions) {
+				return fmt.Errorf("face %d: vertex index %v out of range [0, %d)", index, vIndex, len(m.positions))
+			}
+		}
+		for i := 1; i < len(face)-1; i++ {
+			m.triangles = append(m.triangles, [3]int{int(face[0]), int(face[i]), int(face[i+1])})
+		}
+	}
+	return nil
+}
+
+// Convert the parsed mesh into a scene with a single mesh instance.
+func (m *mesh) toScene() (*input.Scene, error) {
+	if len(m.triangles) == 0 {
+		return nil, fmt.Errorf("mesh does not define any faces")
+	}
+
+	hasColors := len(m.colors) != 0
+	matExpr := defaultMaterialExpr
+	if hasColors {
+		matExpr = defaultVertexColorMaterialExpr
+	}
+
+	sc := input.NewScene()
+	sc.Materials = append(sc.Materials, &input.Material{Name: "ply", Expression: matExpr, Used: true})
+
+	pm := input.NewMesh("ply")
+	pm.HasVertexColors = hasColors
+	for _, tri := range m.triangles {
+		prim := &input.Primitive{}
+		for i, vIndex := range tri {
+			prim.Vertices[i] = m.positions[vIndex]
+			if len(m.uvs) != 0 {
+				prim.UVs[i] = m.uvs[vIndex]
+			}
+			if hasColors {
+				prim.Colors[i] = m.colors[vIndex]
+			}
+		}
+
+		if len(m.normals) != 0 {
+			for i, vIndex := range tri {
+				prim.Normals[i] = m.normals[vIndex]
+			}
+		} else {
+			// Generate normals from the vertices
+			faceNormal := prim.Vertices[1].Sub(prim.Vertices[0]).Cross(prim.Vertices[2].Sub(prim.Vertices[0])).Normalize()
+			prim.Normals = [3]types.Vec3{faceNormal, faceNormal, faceNormal}
+		}
+
+		prim.SetBBox([2]types.Vec3{
+			types.MinVec3(prim.Vertices[0], types.MinVec3(prim.Vertices[1], prim.Vertices[2])),
+			types.MaxVec3(prim.Vertices[0], types.MaxVec3(prim.Vertices[1], prim.Vertices[2])),
+		})
+		prim.SetCenter(prim.Vertices[0].Add(prim.Vertices[1]).Add(prim.Vertices[2]).Mul(1.0 / 3.0))
+		pm.Primitives = append(pm.Primitives, prim)
+	}
+	sc.Meshes = append(sc.Meshes, pm)
+
+	bbox := pm.BBox()
+	mi := &input.MeshInstance{MeshIndex: 0, Transform: types.Ident4()}
+	mi.SetBBox(bbox)
+	mi.SetCenter(bbox[0].Add(bbox[1]).Mul(0.5))
+	sc.MeshInstances = append(sc.MeshInstances, mi)
+
+	// Frame the mesh bounding sphere using the default camera fov
+	center := mi.Center()
+	radius := bbox[1].Sub(center).Len()
+	sc.Camera.Look = center
+	sc.Camera.Eye = center.Add(types.Vec3{0, 0, radius / float32(math.Tan(float64(sc.Camera.FOV)*math.Pi/360))})
+
+	return sc, nil
+}
+
+// Convert a color channel to the [0, 1] range. Integer channels are
+// normalized by the max value of their type.
+func colorValue(value float64, valueType string) float32 {
+	switch typeSizes[valueType] {
+	case 1:
+		if valueType == "uchar" || valueType == "uint8" {
+			return float32(value / math.MaxUint8)
+		}
+	case 2:
+		if valueType == "ushort" || valueType == "uint16" {
+			return float32(value / math.MaxUint16)
+		}
+	}
+	return float32(value)
+}
+
+// A value reader for the ascii PLY encoding.
+type asciiReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *asciiReader) read(valueType string) (float64, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return strconv.ParseFloat(r.scanner.Text(), 64)
+}
+
+// A value reader for the binary little-endian PLY encoding.
+type binaryReader struct {
+	r   io.Reader
+	buf [8]byte
+}
+
+func (r *binaryReader) read(valueType string) (float64, error) {
+	buf := r.buf[:typeSizes[valueType]]
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+
+	switch valueType {
+	case "char", "int8":
+		return float64(int8(buf[0])), nil
+	case "uchar", "uint8":
+		return float64(buf[0]), nil
+	case "short", "int16":
+		return float64(int16(binary.LittleEndian.Uint16(buf))), nil
+	case "ushort", "uint16":
+		return float64(binary.LittleEndian.Uint16(buf)), nil
+	case "int", "int32":
+		return float64(int32(binary.LittleEndian.Uint32(buf))), nil
+	case "uint", "uint32":
+		return float64(binary.LittleEndian.Uint32(buf)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf))), nil
+	default:
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
+	}
+}
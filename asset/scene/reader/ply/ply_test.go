@@ -0,0 +1,184 @@
+package ply
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/compiler/input"
+	"github.com/achilleasa/polaris/types"
+)
+
+// A unit quad and a triangle with per-vertex colors. The quad is listed
+// first and is split into two triangles.
+const asciiMesh = `ply
+format ascii 1.0
+comment exported by a test
+element vertex 5
+property float x
+property float y
+property float z
+property uchar red
+property uchar green
+property uchar blue
+element face 2
+property list uchar int vertex_indices
+end_header
+0 0 0 255 0 0
+1 0 0 0 255 0
+1 1 0 0 0 255
+0 1 0 255 255 255
+2 0 0 0 0 0
+4 0 1 2 3
+3 1 4 2
+`
+
+func TestParseASCII(t *testing.T) {
+	sc, err := Parse(strings.NewReader(asciiMesh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertParsedMesh(t, sc.Meshes[0].Primitives, sc.Meshes[0].HasVertexColors)
+}
+
+func TestParseBinary(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(`ply
+format binary_little_endian 1.0
+element vertex 5
+property uchar red
+property uchar green
+property uchar blue
+property double x
+property double y
+property double z
+property float nx
+property float ny
+property float nz
+element face 2
+property list uchar uint vertex_index
+end_header
+`)
+	vertices := [][6]float64{
+		{255, 0, 0, 0, 0, 0},
+		{0, 255, 0, 1, 0, 0},
+		{0, 0, 255, 1, 1, 0},
+		{255, 255, 255, 0, 1, 0},
+		{0, 0, 0, 2, 0, 0},
+	}
+	for _, v := range vertices {
+		buf.Write([]byte{uint8(v[0]), uint8(v[1]), uint8(v[2])})
+		binary.Write(&buf, binary.LittleEndian, [3]float64{v[3], v[4], v[5]})
+		binary.Write(&buf, binary.LittleEndian, [3]float32{0, 0, 1})
+	}
+	buf.WriteByte(4)
+	binary.Write(&buf, binary.LittleEndian, [4]uint32{0, 1, 2, 3})
+	buf.WriteByte(3)
+	binary.Write(&buf, binary.LittleEndian, [3]uint32{1, 4, 2})
+
+	sc, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertParsedMesh(t, sc.Meshes[0].Primitives, sc.Meshes[0].HasVertexColors)
+}
+
+func TestParseErrors(t *testing.T) {
+	type spec struct {
+		input  string
+		expErr string
+	}
+	specs := []spec{
+		spec{"obj\n", "missing ply magic number"},
+		spec{"ply\nformat binary_big_endian 1.0\nend_header\n", "unsupported format"},
+		spec{"ply\nelement vertex 1\nend_header\n", "missing format declaration"},
+		spec{"ply\nformat ascii 1.0\nelement vertex 1\nproperty half x\nend_header\n", "unsupported property type"},
+		spec{"ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n3 0 1 2\n", "out of range"},
+		spec{"ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\nend_header\n0 0 0\n", "unexpected EOF"},
+	}
+
+	for index, s := range specs {
+		_, err := Parse(strings.NewReader(s.input))
+		if err == nil || !strings.Contains(err.Error(), s.expErr) {
+			t.Errorf("[spec %d] expected error containing %q; got %v", index, s.expErr, err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "polaris-ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mesh.ply")
+	if err = ioutil.WriteFile(path, []byte(asciiMesh), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err := Import(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sc.VertexList) != 9 {
+		t.Fatalf("expected compiled scene to contain 9 vertices; got %d", len(sc.VertexList))
+	}
+	if len(sc.VertexColorList) != len(sc.VertexList) {
+		t.Fatalf("expected compiled scene to contain %d vertex colors; got %d", len(sc.VertexList), len(sc.VertexColorList))
+	}
+
+	// The BVH may reorder primitives so lookup the vertex at the origin
+	var found bool
+	for index, v := range sc.VertexList {
+		if v.Vec3() != (types.Vec3{0, 0, 0}) {
+			continue
+		}
+		found = true
+		if exp := (types.Vec4{1, 0, 0, 1}); sc.VertexColorList[index] != exp {
+			t.Errorf("expected color of vertex at origin to be %v; got %v", exp, sc.VertexColorList[index])
+		}
+	}
+	if !found {
+		t.Fatal("expected compiled scene to contain a vertex at the origin")
+	}
+}
+
+func assertParsedMesh(t *testing.T, prims []*input.Primitive, hasVertexColors bool) {
+	if !hasVertexColors {
+		t.Fatal("expected mesh to define vertex colors")
+	}
+	if len(prims) != 3 {
+		t.Fatalf("expected quad and triangle to be split into 3 primitives; got %d", len(prims))
+	}
+
+	expVertices := [3][3]types.Vec3{
+		{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}},
+		{{0, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+		{{1, 0, 0}, {2, 0, 0}, {1, 1, 0}},
+	}
+	for index, prim := range prims {
+		if prim.Vertices != expVertices[index] {
+			t.Errorf("expected primitive %d vertices to be %v; got %v", index, expVertices[index], prim.Vertices)
+		}
+		for _, n := range prim.Normals {
+			if n != (types.Vec3{0, 0, 1}) {
+				t.Errorf("expected primitive %d normal to be {0, 0, 1}; got %v", index, n)
+			}
+		}
+	}
+
+	expColors := [3]types.Vec4{{1, 0, 0, 1}, {0, 0, 1, 1}, {1, 1, 1, 1}}
+	for i, exp := range expColors {
+		got := prims[1].Colors[i]
+		if math.Abs(float64(got.Sub(exp).Len())) > 1e-6 {
+			t.Errorf("expected primitive 1 vertex %d color to be %v; got %v", i, exp, got)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/achilleasa/polaris/asset"
 	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/scene/reader/ply"
+	"github.com/achilleasa/polaris/log"
 )
 
 // The Reader interface is implemented by all scene readers.
@@ -16,6 +18,11 @@ type Reader interface {
 
 // Read scene from file.
 func ReadScene(filename string) (*scene.Scene, error) {
+	// PLY files contain a single mesh and are compiled directly
+	if strings.HasSuffix(filename, ".ply") {
+		return ply.Import(filename)
+	}
+
 	res, err := asset.NewResource(filename, nil)
 	if err != nil {
 		return nil, err
@@ -33,3 +40,15 @@ func ReadScene(filename string) (*scene.Scene, error) {
 	}
 	return reader.Read(res)
 }
+
+// Read a scene from file and watch the file for changes. Each time the file
+// is modified, the scene is reloaded and onReload is invoked with the updated
+// scene. Reload errors are logged and the previous scene is retained.
+func Watch(path string, onReload func(*scene.Scene)) (*scene.Watcher, error) {
+	logger := log.New("scene watcher")
+	return scene.WatchFile(path, ReadScene, scene.WatchOptions{
+		OnError: func(err error) {
+			logger.Errorf("could not reload scene %q: %s", path, err.Error())
+		},
+	}, onReload)
+}
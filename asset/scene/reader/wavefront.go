@@ -10,6 +10,7 @@ import (
 
 	"github.com/achilleasa/polaris/asset"
 	"github.com/achilleasa/polaris/asset/compiler"
+	"github.com/achilleasa/polaris/asset/compiler/bvh"
 	"github.com/achilleasa/polaris/asset/compiler/input"
 	"github.com/achilleasa/polaris/asset/material"
 	"github.com/achilleasa/polaris/asset/scene"
@@ -36,6 +37,15 @@ type wavefrontMaterial struct {
 	// Index of refraction.
 	Ni float32
 
+	// Roughness for glossy reflections and refractions.
+	Nr float32
+
+	// Transparency (1 - dissolve).
+	Tr float32
+
+	// The green channel convention for the normal map.
+	NormalConvention material.NormalMapConvention
+
 	// Textures for modulating above parameters.
 	KdTex     string
 	KsTex     string
@@ -44,6 +54,9 @@ type wavefrontMaterial struct {
 	BumpTex   string
 	NormalTex string
 
+	// Normal map for the coat of layered material expressions.
+	CoatNormalTex string
+
 	// Layered material expression.
 	MaterialExpression string
 
@@ -68,6 +81,10 @@ func (wf *wavefrontMaterial) GetExpression() string {
 	switch {
 	case isSpecularReflection && wf.Ni == 0.0:
 		bxdf = material.BxdfConductor
+		if wf.Nr != 0.0 {
+			bxdf = material.BxdfRoughtConductor
+			exprArgs = append(exprArgs, fmt.Sprintf("%s: %v", material.ParamRoughness, wf.Nr))
+		}
 
 		if wf.KsTex != "" {
 			exprArgs = append(exprArgs, fmt.Sprintf("%s: %q", material.ParamSpecularity, wf.KsTex))
@@ -76,6 +93,10 @@ func (wf *wavefrontMaterial) GetExpression() string {
 		}
 	case isSpecularReflection && wf.Ni != 0.0:
 		bxdf = material.BxdfDielectric
+		if wf.Nr != 0.0 {
+			bxdf = material.BxdfRoughDielectric
+			exprArgs = append(exprArgs, fmt.Sprintf("%s: %v", material.ParamRoughness, wf.Nr))
+		}
 
 		if wf.KsTex != "" {
 			exprArgs = append(exprArgs, fmt.Sprintf("%s: %q", material.ParamSpecularity, wf.KsTex))
@@ -124,6 +145,22 @@ func (wf *wavefrontMaterial) GetExpression() string {
 	return materialExpr
 }
 
+const (
+	// The smoothing group for faces that precede any smoothing group
+	// statement. These faces are smooth shaded.
+	defaultSmoothingGroup = -1
+
+	// The smoothing group for flat shaded faces.
+	flatSmoothingGroup = 0
+)
+
+// A face whose normals are generated using its smoothing group.
+type smoothFace struct {
+	prim          *input.Primitive
+	vertexIndices [3]int
+	group         int
+}
+
 type wavefrontSceneReader struct {
 	logger log.Logger
 
@@ -144,6 +181,11 @@ type wavefrontSceneReader struct {
 	normalList []types.Vec3
 	uvList     []types.Vec2
 
+	// The active smoothing group and the faces whose normals need to be
+	// generated once all vertices have been parsed.
+	smoothingGroup int
+	smoothFaces    []smoothFace
+
 	// An error stack that provides additional error information when
 	// scene files include other files (models, mat libs e.t.c)
 	errStack []string
@@ -159,6 +201,7 @@ func newWavefrontReader() *wavefrontSceneReader {
 		normalList:     make([]types.Vec3, 0),
 		uvList:         make([]types.Vec2, 0),
 		errStack:       make([]string, 0),
+		smoothingGroup: defaultSmoothingGroup,
 	}
 }
 
@@ -172,6 +215,7 @@ func (r *wavefrontSceneReader) Read(sceneRes *asset.Resource) (*scene.Scene, err
 	if err != nil {
 		return nil, err
 	}
+	r.generateSmoothNormals()
 
 	// If no mesh instances are defined, create instances for each defined mesh
 	if len(r.rawScene.MeshInstances) == 0 {
@@ -195,7 +239,7 @@ func (r *wavefrontSceneReader) processMaterials() {
 	pruned := 0
 	for wfIndex, wfMat := range r.materials {
 		// Whitelist scene materials
-		if wfMat.Name == compiler.SceneDiffuseMaterialName || wfMat.Name == compiler.SceneEmissiveMaterialName {
+		if wfMat.Name == compiler.SceneDiffuseMaterialName || wfMat.Name == compiler.SceneEmissiveMaterialName || wfMat.Name == compiler.SceneSunMaterialName {
 			wfMat.Used = true
 		}
 
@@ -205,9 +249,12 @@ func (r *wavefrontSceneReader) processMaterials() {
 			prunedMaterials = append(
 				prunedMaterials,
 				&input.Material{
-					Name:         wfMat.Name,
-					Expression:   wfMat.GetExpression(),
-					AssetRelPath: wfMat.AssetRelPath,
+					Name:                wfMat.Name,
+					Expression:          wfMat.GetExpression(),
+					AssetRelPath:        wfMat.AssetRelPath,
+					Transparency:        wfMat.Tr,
+					NormalMapConvention: wfMat.NormalConvention,
+					CoatNormalTexture:   wfMat.CoatNormalTex,
 				},
 			)
 			pruned++
@@ -217,10 +264,13 @@ func (r *wavefrontSceneReader) processMaterials() {
 		r.rawScene.Materials = append(
 			r.rawScene.Materials,
 			&input.Material{
-				Name:         wfMat.Name,
-				Expression:   wfMat.GetExpression(),
-				AssetRelPath: wfMat.AssetRelPath,
-				Used:         true,
+				Name:                wfMat.Name,
+				Expression:          wfMat.GetExpression(),
+				AssetRelPath:        wfMat.AssetRelPath,
+				Transparency:        wfMat.Tr,
+				NormalMapConvention: wfMat.NormalConvention,
+				CoatNormalTexture:   wfMat.CoatNormalTex,
+				Used:                true,
 			},
 		)
 
@@ -388,6 +438,15 @@ func (r *wavefrontSceneReader) parse(res *asset.Resource) error {
 
 			r.verifyLastParsedMesh()
 			r.rawScene.Meshes = append(r.rawScene.Meshes, input.NewMesh(lineTokens[1]))
+		case "s":
+			if len(lineTokens) != 2 {
+				return r.emitError(res.Path(), lineNum, `unsupported syntax for "s"; expected 1 argument; got %d`, len(lineTokens)-1)
+			}
+
+			r.smoothingGroup, err = parseSmoothingGroup(lineTokens[1])
+			if err != nil {
+				return r.emitError(res.Path(), lineNum, err.Error())
+			}
 		case "f":
 			primList, err := r.parseFace(lineTokens, relVertexOffset, relUvOffset, relNormalOffset)
 			if err != nil {
@@ -423,6 +482,57 @@ func (r *wavefrontSceneReader) parse(res *asset.Resource) error {
 			if err != nil {
 				return r.emitError(res.Path(), lineNum, err.Error())
 			}
+		case "sun_direction", "sun_angular_diameter":
+			if r.rawScene.Sun == nil {
+				r.rawScene.Sun = &input.Sun{AngularDiameter: scene.DefaultSunAngularDiameter}
+			}
+			if lineTokens[0] == "sun_direction" {
+				r.rawScene.Sun.Direction, err = parseVec3(lineTokens)
+			} else {
+				r.rawScene.Sun.AngularDiameter, err = parseFloat32(lineTokens)
+			}
+			if err != nil {
+				return r.emitError(res.Path(), lineNum, err.Error())
+			}
+		case "bvh_builder":
+			if len(lineTokens) != 2 {
+				return r.emitError(res.Path(), lineNum, `unsupported syntax for "bvh_builder"; expected 1 argument; got %d`, len(lineTokens)-1)
+			}
+			if len(r.rawScene.Meshes) == 0 {
+				return r.emitError(res.Path(), lineNum, `"bvh_builder" must follow an object or group definition`)
+			}
+
+			builder, err := bvh.BuilderFromName(lineTokens[1])
+			if err != nil {
+				return r.emitError(res.Path(), lineNum, err.Error())
+			}
+			r.rawScene.Meshes[len(r.rawScene.Meshes)-1].BvhBuilder = builder
+		case "bvh_leaf_primitives":
+			if len(lineTokens) != 2 {
+				return r.emitError(res.Path(), lineNum, `unsupported syntax for "bvh_leaf_primitives"; expected 1 argument; got %d`, len(lineTokens)-1)
+			}
+			if len(r.rawScene.Meshes) == 0 {
+				return r.emitError(res.Path(), lineNum, `"bvh_leaf_primitives" must follow an object or group definition`)
+			}
+
+			leafPrimitives, err := strconv.Atoi(lineTokens[1])
+			if err != nil || leafPrimitives < 1 {
+				return r.emitError(res.Path(), lineNum, `invalid value %q for "bvh_leaf_primitives"; expected an integer >= 1`, lineTokens[1])
+			}
+			r.rawScene.Meshes[len(r.rawScene.Meshes)-1].BvhLeafPrimitives = leafPrimitives
+		case "bvh_split_alpha":
+			if len(r.rawScene.Meshes) == 0 {
+				return r.emitError(res.Path(), lineNum, `"bvh_split_alpha" must follow an object or group definition`)
+			}
+
+			splitAlpha, err := parseFloat32(lineTokens)
+			if err != nil {
+				return r.emitError(res.Path(), lineNum, err.Error())
+			}
+			if splitAlpha < 0 || splitAlpha > 1 {
+				return r.emitError(res.Path(), lineNum, `invalid value %q for "bvh_split_alpha"; expected a value in the [0, 1] range`, lineTokens[1])
+			}
+			r.rawScene.Meshes[len(r.rawScene.Meshes)-1].BvhSplitAlpha = &splitAlpha
 		case "instance":
 			instance, err := r.parseMeshInstance(lineTokens)
 			if err != nil {
@@ -545,6 +655,7 @@ func (r *wavefrontSceneReader) parseFace(lineTokens []string, relVertexOffset, r
 	}
 
 	var vertices [4]types.Vec3
+	var vertexIndices [4]int
 	var normals [4]types.Vec3
 	var uv [4]types.Vec2
 	var vOffset int
@@ -571,6 +682,7 @@ func (r *wavefrontSceneReader) parseFace(lineTokens []string, relVertexOffset, r
 			return nil, fmt.Errorf("could not parse vertex coord for face argument %d: %s", arg, err.Error())
 		}
 		vertices[arg] = r.vertexList[vOffset]
+		vertexIndices[arg] = vOffset
 
 		// Parse UV coords if specified
 		if expIndices > 1 && vTokens[1] != "" {
@@ -642,11 +754,62 @@ func (r *wavefrontSceneReader) parseFace(lineTokens []string, relVertexOffset, r
 		)
 		prim.SetCenter(triVerts[0].Add(triVerts[1]).Add(triVerts[2]).Mul(1.0 / 3.0))
 		primitives = append(primitives, prim)
+
+		// Generated normals are smoothed after all vertices are parsed
+		if !hasNormals && r.smoothingGroup != flatSmoothingGroup {
+			r.smoothFaces = append(r.smoothFaces, smoothFace{
+				prim:          prim,
+				vertexIndices: [3]int{vertexIndices[indices[0]], vertexIndices[indices[1]], vertexIndices[indices[2]]},
+				group:         r.smoothingGroup,
+			})
+		}
 	}
 
 	return primitives, nil
 }
 
+// Replace the generated normals of faces that belong to a smoothing group
+// with the average normal of the faces that share each vertex within the
+// same group. Face normals are weighted by the face angle at each vertex so
+// that the result does not depend on how quads are split into triangles.
+// Vertices shared by faces in different groups get a separate normal for
+// each group which yields hard edges between the groups.
+func (r *wavefrontSceneReader) generateSmoothNormals() {
+	type vertexKey struct {
+		vertexIndex int
+		group       int
+	}
+
+	vertexNormals := make(map[vertexKey]types.Vec3)
+	for _, face := range r.smoothFaces {
+		v := face.prim.Vertices
+		faceNormal := v[1].Sub(v[0]).Cross(v[2].Sub(v[0]))
+		if faceNormal.Len() == 0 {
+			continue
+		}
+		faceNormal = faceNormal.Normalize()
+
+		for corner, vertexIndex := range face.vertexIndices {
+			e0 := v[(corner+1)%3].Sub(v[corner]).Normalize()
+			e1 := v[(corner+2)%3].Sub(v[corner]).Normalize()
+			angle := float32(math.Acos(math.Max(-1, math.Min(1, float64(e0.Dot(e1))))))
+
+			key := vertexKey{vertexIndex, face.group}
+			vertexNormals[key] = vertexNormals[key].Add(faceNormal.Mul(angle))
+		}
+	}
+
+	for _, face := range r.smoothFaces {
+		for corner, vertexIndex := range face.vertexIndices {
+			// Keep the face normal if the face normals cancel out
+			if normal := vertexNormals[vertexKey{vertexIndex, face.group}]; normal.Len() > 0 {
+				face.prim.Normals[corner] = normal.Normalize()
+			}
+		}
+	}
+	r.smoothFaces = nil
+}
+
 // Parse a wavefront material library.
 func (r *wavefrontSceneReader) parseMaterials(res *asset.Resource) error {
 	var lineNum int = 0
@@ -720,7 +883,16 @@ func (r *wavefrontSceneReader) parseMaterials(res *asset.Resource) error {
 				*target, err = parseVec3(lineTokens)
 			case "Ni":
 				curMaterial.Ni, err = parseFloat32(lineTokens)
-			case "map_Kd", "map_Ks", "map_Ke", "map_Tf", "map_bump", "map_normal":
+			case "Nr":
+				curMaterial.Nr, err = parseFloat32(lineTokens)
+			case "d":
+				var dissolve float32
+				dissolve, err = parseFloat32(lineTokens)
+				if err == nil && (dissolve < 0 || dissolve > 1) {
+					err = fmt.Errorf(`"d" value must be in the [0, 1] range; got %v`, dissolve)
+				}
+				curMaterial.Tr = 1 - dissolve
+			case "map_Kd", "map_Ks", "map_Ke", "map_Tf", "map_bump", "map_normal", "map_coat_normal":
 				var target *string
 				switch lineTokens[0] {
 				case "map_Kd":
@@ -735,9 +907,16 @@ func (r *wavefrontSceneReader) parseMaterials(res *asset.Resource) error {
 					target = &curMaterial.BumpTex
 				case "map_normal":
 					target = &curMaterial.NormalTex
+				case "map_coat_normal":
+					target = &curMaterial.CoatNormalTex
 				}
 
 				*target = lineTokens[1]
+			case "map_normal_convention":
+				if len(lineTokens) != 2 {
+					return r.emitError(res.Path(), lineNum, `unsupported syntax for "%s"; expected 1 argument; got %d`, lineTokens[0], len(lineTokens)-1)
+				}
+				curMaterial.NormalConvention, err = material.NormalMapConventionFromName(lineTokens[1])
 			case "mat_expr":
 				if len(lineTokens) < 2 {
 					return r.emitError(res.Path(), lineNum, `unsupported syntax for "%s"; expected 1 argument; got %d`, lineTokens[0], len(lineTokens)-1)
@@ -781,6 +960,20 @@ func selectFaceCoordIndex(indexToken string, coordListLen int, relOffset int) (i
 	return vOffset, nil
 }
 
+// Parse the argument of a smoothing group statement. Returns
+// flatSmoothingGroup if smoothing is disabled.
+func parseSmoothingGroup(token string) (int, error) {
+	if token == "off" {
+		return flatSmoothingGroup, nil
+	}
+
+	group, err := strconv.Atoi(token)
+	if err != nil || group < 0 {
+		return 0, fmt.Errorf(`invalid smoothing group %q; expected "off" or a non-negative integer`, token)
+	}
+	return group, nil
+}
+
 // Parse a float scalar value.
 func parseFloat32(lineTokens []string) (float32, error) {
 	if len(lineTokens) < 2 {
@@ -1,14 +1,14 @@
 package reader
 
 import (
-	"image"
-	"image/png"
-	"net/http"
-	"net/http/httptest"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/achilleasa/polaris/asset"
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene"
 	"github.com/achilleasa/polaris/types"
 )
 
@@ -96,7 +96,7 @@ func TestSelectFaceCoordinate(t *testing.T) {
 	}
 
 	for idx, s := range specs {
-		v, err := selectFaceCoordIndex(s.in, s.listLen)
+		v, err := selectFaceCoordIndex(s.in, s.listLen, 0)
 		if s.expError != "" && (err == nil || err.Error() != s.expError) {
 			t.Fatalf("[spec %d] expected error %s; got %v", idx, s.expError, err)
 		} else if v != s.out {
@@ -127,10 +127,10 @@ f 1/1/1 2/2/2 -1/-1/-1
 	r.Read(res)
 
 	expMeshInstances := 1
-	if len(r.sceneGraph.MeshInstances) != expMeshInstances {
-		t.Fatalf("expected %d mesh instances to be generated; got %d", expMeshInstances, len(r.sceneGraph.MeshInstances))
+	if len(r.rawScene.MeshInstances) != expMeshInstances {
+		t.Fatalf("expected %d mesh instances to be generated; got %d", expMeshInstances, len(r.rawScene.MeshInstances))
 	}
-	inst0 := r.sceneGraph.MeshInstances[0]
+	inst0 := r.rawScene.MeshInstances[0]
 	if inst0.MeshIndex != 0 {
 		t.Fatalf("expected mesh instance to point to mesh at index 0; got %d", inst0.MeshIndex)
 	}
@@ -182,8 +182,8 @@ instance testObj 	0 1 0	90 0 0	10 10 10
 	r.Read(res)
 
 	expMeshInstances := 3
-	if len(r.sceneGraph.MeshInstances) != expMeshInstances {
-		t.Fatalf("expected %d mesh instances to be generated; got %d", expMeshInstances, len(r.sceneGraph.MeshInstances))
+	if len(r.rawScene.MeshInstances) != expMeshInstances {
+		t.Fatalf("expected %d mesh instances to be generated; got %d", expMeshInstances, len(r.rawScene.MeshInstances))
 	}
 
 	type spec struct {
@@ -198,7 +198,7 @@ instance testObj 	0 1 0	90 0 0	10 10 10
 		{2, types.Vec3{0, 1, 0}, types.Vec3{0, 0, 20}},
 	}
 	for idx, s := range specs {
-		inst := r.sceneGraph.MeshInstances[s.instance]
+		inst := r.rawScene.MeshInstances[s.instance]
 		out := inst.Transform.Mul4x1(s.in.Vec4(1.0)).Vec3()
 		if !types.ApproxEqual(out, s.expOut, 1e-3) {
 			t.Fatalf("[spec %d] expected transformed point with instance %d matrix to be %v; got %v", idx, s.instance, s.expOut, out)
@@ -209,7 +209,7 @@ instance testObj 	0 1 0	90 0 0	10 10 10
 		[2]types.Vec3{types.Vec3{1, 0, 1}, types.Vec3{2, 1, 1}},
 	}
 	for meshIndex, expBBox := range expBBoxes {
-		bbox := r.sceneGraph.MeshInstances[meshIndex].BBox()
+		bbox := r.rawScene.MeshInstances[meshIndex].BBox()
 		if !types.ApproxEqual(bbox[0], expBBox[0], 1e-3) {
 			t.Fatalf("[mesh inst. %d] expected bbox min to be %v; got %v", meshIndex, expBBox[0], bbox[0])
 		}
@@ -219,6 +219,42 @@ instance testObj 	0 1 0	90 0 0	10 10 10
 	}
 }
 
+func TestSunDirectives(t *testing.T) {
+	type spec struct {
+		payload     string
+		expDir      types.Vec3
+		expDiameter float32
+	}
+	specs := []spec{
+		spec{"sun_direction 1 2 0", types.Vec3{1, 2, 0}, scene.DefaultSunAngularDiameter},
+		spec{"sun_angular_diameter 0\nsun_direction 0 1 0", types.Vec3{0, 1, 0}, 0},
+	}
+
+	for idx, s := range specs {
+		r := newWavefrontReader()
+		err := r.parse(mockResource(s.payload))
+		if err != nil {
+			t.Fatalf("[spec %d] %v", idx, err)
+		}
+
+		sun := r.rawScene.Sun
+		if sun == nil {
+			t.Fatalf("[spec %d] expected scene to define a sun light", idx)
+		}
+		if sun.Direction != s.expDir {
+			t.Fatalf("[spec %d] expected sun direction to be %v; got %v", idx, s.expDir, sun.Direction)
+		}
+		if sun.AngularDiameter != s.expDiameter {
+			t.Fatalf("[spec %d] expected sun angular diameter to be %f; got %f", idx, s.expDiameter, sun.AngularDiameter)
+		}
+	}
+
+	r := newWavefrontReader()
+	if err := r.parse(mockResource("sun_angular_diameter")); err == nil {
+		t.Fatal("expected an error for a sun_angular_diameter directive without a value")
+	}
+}
+
 func TestParseSingleFacedObject(t *testing.T) {
 	payload := `
 o testObj
@@ -244,11 +280,11 @@ f 1/1/1 2/2/2 -1/-1/-1
 	}
 
 	expMeshes := 1
-	if len(r.sceneGraph.Meshes) != expMeshes {
-		t.Fatalf("expected %d meshes to be parsed; got %d", expMeshes, len(r.sceneGraph.Meshes))
+	if len(r.rawScene.Meshes) != expMeshes {
+		t.Fatalf("expected %d meshes to be parsed; got %d", expMeshes, len(r.rawScene.Meshes))
 	}
 
-	mesh0 := r.sceneGraph.Meshes[0]
+	mesh0 := r.rawScene.Meshes[0]
 	expName := "testObj"
 	if mesh0.Name != expName {
 		t.Fatalf("expected mesh[0] name to be '%s'; got %s", expName, mesh0.Name)
@@ -260,8 +296,8 @@ f 1/1/1 2/2/2 -1/-1/-1
 	}
 
 	expMaterials := 1
-	if len(r.sceneGraph.Materials) != expMaterials {
-		t.Fatalf("expected scene to contain %d material(s); got %d", expMaterials, len(r.sceneGraph.Materials))
+	if len(r.materials) != expMaterials {
+		t.Fatalf("expected scene to contain %d material(s); got %d", expMaterials, len(r.materials))
 	}
 
 	expPoints := []types.Vec3{
@@ -332,11 +368,11 @@ f 1 2 3 4
 	}
 
 	expMeshes := 1
-	if len(r.sceneGraph.Meshes) != expMeshes {
-		t.Fatalf("expected %d meshes to be parsed; got %d", expMeshes, len(r.sceneGraph.Meshes))
+	if len(r.rawScene.Meshes) != expMeshes {
+		t.Fatalf("expected %d meshes to be parsed; got %d", expMeshes, len(r.rawScene.Meshes))
 	}
 
-	mesh0 := r.sceneGraph.Meshes[0]
+	mesh0 := r.rawScene.Meshes[0]
 	expName := "testObj"
 	if mesh0.Name != expName {
 		t.Fatalf("expected mesh[0] name to be '%s'; got %s", expName, mesh0.Name)
@@ -348,8 +384,8 @@ f 1 2 3 4
 	}
 
 	expMaterials := 1
-	if len(r.sceneGraph.Materials) != expMaterials {
-		t.Fatalf("expected scene to contain %d material(s); got %d", expMaterials, len(r.sceneGraph.Materials))
+	if len(r.materials) != expMaterials {
+		t.Fatalf("expected scene to contain %d material(s); got %d", expMaterials, len(r.materials))
 	}
 
 	expPoints := []types.Vec3{
@@ -409,6 +445,76 @@ f 1 2 3 4
 		t.Fatalf("[prim 1] expected bbox max to be %v; got %v", expBBox[1], bbox[1])
 	}
 }
+
+func TestSmoothingGroups(t *testing.T) {
+	// Two quads that share the edge along the Y axis and form a 90 degree
+	// corner. The payload prefix selects the smoothing group of each quad.
+	geometry := `
+o testObj
+v 0 0 0
+v 0 1 0
+v 1 0 0
+v 1 1 0
+v 0 0 1
+v 0 1 1
+%s
+f 1 2 4 3
+%s
+f 5 6 2 1
+`
+	faceNormalA := types.Vec3{0, 0, -1}
+	faceNormalB := types.Vec3{-1, 0, 0}
+	smoothNormal := faceNormalA.Add(faceNormalB).Normalize()
+
+	type spec struct {
+		groupA, groupB string
+		expHardEdge    bool
+	}
+	specs := []spec{
+		spec{"s 1", "s 2", true},
+		spec{"s 1", "s 1", false},
+		spec{"s off", "s off", true},
+		spec{"s 0", "s 1", true},
+		// Undefined groups default to smooth
+		spec{"", "", false},
+	}
+
+	for index, s := range specs {
+		r := newWavefrontReader()
+		if err := r.parse(mockResource(fmt.Sprintf(geometry, s.groupA, s.groupB))); err != nil {
+			t.Fatal(err)
+		}
+		r.generateSmoothNormals()
+
+		prims := r.rawScene.Meshes[0].Primitives
+		if len(prims) != 4 {
+			t.Fatalf("[spec %d] expected 4 primitives; got %d", index, len(prims))
+		}
+
+		// Check the normals at the shared vertices (1 and 2)
+		for primIndex, prim := range prims {
+			expFaceNormal := faceNormalA
+			if primIndex >= 2 {
+				expFaceNormal = faceNormalB
+			}
+
+			for corner, vertex := range prim.Vertices {
+				expNormal := expFaceNormal
+				if vertex[0] == 0 && vertex[2] == 0 && !s.expHardEdge {
+					expNormal = smoothNormal
+				}
+				if !types.ApproxEqual(prim.Normals[corner], expNormal, 1e-5) {
+					t.Errorf("[spec %d] expected normal for vertex %v of primitive %d to be %v; got %v", index, vertex, primIndex, expNormal, prim.Normals[corner])
+				}
+			}
+		}
+	}
+
+	if err := newWavefrontReader().parse(mockResource("s foo\n")); err == nil {
+		t.Fatal("expected to get an error for an invalid smoothing group")
+	}
+}
+
 func TestMaterialLoaderMissingNewMaterialCommand(t *testing.T) {
 	payload := `Kd 1.0 1.0 1.0`
 	res := mockResource(payload)
@@ -454,7 +560,7 @@ func TestMaterialLoaderSuccess(t *testing.T) {
 	Ks 0.1 0.2 0.3
 	Ke 0.4    0.5 0.6
 	Ni 2.5
-	Nr 0`
+	Nr 0.5`
 	res := mockResource(payload)
 	r := newWavefrontReader()
 	err := r.parseMaterials(res)
@@ -462,12 +568,12 @@ func TestMaterialLoaderSuccess(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	matLen := len(r.sceneGraph.Materials)
+	matLen := len(r.materials)
 	if matLen != 1 {
 		t.Fatalf("expected to parse 1 material; got %d", matLen)
 	}
 
-	mat := r.sceneGraph.Materials[0]
+	mat := r.materials[0]
 	if mat.Name != "foo" {
 		t.Fatalf("expected material name to be 'foo'; got %s", mat.Name)
 	}
@@ -488,74 +594,187 @@ func TestMaterialLoaderSuccess(t *testing.T) {
 	if mat.Ni != expScalar {
 		t.Fatalf("expected Ni to be %f; got %f", expScalar, mat.Ni)
 	}
-	expScalar = 0
+	expScalar = 0.5
 	if mat.Nr != expScalar {
 		t.Fatalf("expected Nr to be %f; got %f", expScalar, mat.Nr)
 	}
 
+	expExpr := `roughDielectric(roughness: 0.5, specularity: {0.100000, 0.200000, 0.300000}, intIOR: 2.5)`
+	if expr := mat.GetExpression(); expr != expExpr {
+		t.Fatalf("expected material expression to be %s; got %s", expExpr, expr)
+	}
 }
 
 func TestMaterialLoaderWithTextures(t *testing.T) {
-	serverFn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		png.Encode(w, image.NewRGBA64(image.Rect(0, 0, 1, 1)))
-	})
-	server := httptest.NewServer(serverFn)
-	defer server.Close()
+	payload := `
+newmtl foo
+map_Kd kd.png
+map_Ks ks.png
+map_Ke ke.png
+map_Tf tf.png
+map_bump bump.png
+map_normal normal.png
+`
+	res := mockResource(payload)
+	r := newWavefrontReader()
+	err := r.parseMaterials(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.materials) != 1 {
+		t.Fatalf("expected to parse 1 material; got %d", len(r.materials))
+	}
 
+	mat := r.materials[0]
+	type spec struct {
+		name, tex, expTex string
+	}
+	specs := []spec{
+		{"KdTex", mat.KdTex, "kd.png"},
+		{"KsTex", mat.KsTex, "ks.png"},
+		{"KeTex", mat.KeTex, "ke.png"},
+		{"TfTex", mat.TfTex, "tf.png"},
+		{"BumpTex", mat.BumpTex, "bump.png"},
+		{"NormalTex", mat.NormalTex, "normal.png"},
+	}
+	for index, s := range specs {
+		if s.tex != s.expTex {
+			t.Errorf("[spec %d] expected %s to be %q; got %q", index, s.name, s.expTex, s.tex)
+		}
+	}
+}
+
+func TestMaterialLoaderWithMissingTextures(t *testing.T) {
 	payload := `
 newmtl foo
-map_Kd SERVER/kd.png
-map_Ks SERVER/ks.png
-map_Ke SERVER/ke.png
-map_bump SERVER/bump.png
-map_Ni SERVER/ni.png
-map_Nr SERVER/nr.png
+map_Kd invalid.png
 `
-	res := mockResource(strings.Replace(payload, "SERVER", server.URL, -1))
+	res := mockResource(payload)
 	r := newWavefrontReader()
 	err := r.parseMaterials(res)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(r.sceneGraph.Materials) != 1 {
-		t.Fatalf("expected to parse 1 material; got %d", len(r.sceneGraph.Materials))
+	geometry := `
+usemtl foo
+v 0 0 0
+v 1 0 0
+v 0 1 0
+f 1 2 3
+`
+	sc, err := r.Read(mockResource(geometry))
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	expTexCount := 6
-	if len(r.sceneGraph.Textures) != expTexCount {
-		t.Fatalf("expected to load %d textures; got %d", expTexCount, len(r.sceneGraph.Textures))
+	if len(sc.TextureMetadata) != 0 {
+		t.Fatalf("expected texture list to be empty; got %d items", len(sc.TextureMetadata))
 	}
 
-	texIndices := 0
-	mat := r.sceneGraph.Materials[0]
-	if mat.KdTex != -1 {
-		texIndices++
+	mat := sc.MaterialNodeList[sc.MaterialIndex[0]]
+	if mat.Union1[3] != -1 {
+		t.Fatalf("expected reflectance texture to be -1 for missing texture; got %d", mat.Union1[3])
 	}
-	if mat.KsTex != -1 {
-		texIndices++
+}
+
+func TestMaterialLoaderCoatNormalMap(t *testing.T) {
+	payload := `
+newmtl plain
+mat_expr layered(dielectric(intIOR: 1.5), diffuse(reflectance: {0.8, 0.1, 0.1}), 0.1)
+
+newmtl peel
+map_normal_convention directx
+mat_expr layered(dielectric(intIOR: 1.5), diffuse(reflectance: {0.8, 0.1, 0.1}), 0.2, "noise(16)")
+
+newmtl fallback
+map_coat_normal checker(8)
+mat_expr layered(dielectric(intIOR: 1.5), diffuse(reflectance: {0.8, 0.1, 0.1}), 0.3)
+
+newmtl override
+map_coat_normal checker(8)
+mat_expr layered(dielectric(intIOR: 1.5), diffuse(reflectance: {0.8, 0.1, 0.1}), 0.4, "noise(16)")
+`
+	res := mockResource(payload)
+	r := newWavefrontReader()
+	err := r.parseMaterials(res)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if mat.KeTex != -1 {
-		texIndices++
+
+	geometry := `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+usemtl plain
+f 1 2 3
+usemtl peel
+f 1 2 3
+usemtl fallback
+f 1 2 3
+usemtl override
+f 1 2 3
+`
+	sc, err := r.Read(mockResource(geometry))
+	if err != nil {
+		t.Fatal(err)
 	}
-	if mat.NiTex != -1 {
-		texIndices++
+
+	if len(sc.TextureMetadata) != 2 {
+		t.Fatalf("expected 2 textures; got %d", len(sc.TextureMetadata))
 	}
-	if mat.NrTex != -1 {
-		texIndices++
+
+	// Locate the layered node of each material using its coat thickness
+	layered := make(map[float32]*scene.MaterialNode)
+	for nodeIndex, node := range sc.MaterialNodeList {
+		if node.Union1[0] == int32(material.OpLayered) {
+			layered[node.Union2[3]] = &sc.MaterialNodeList[nodeIndex]
+		}
 	}
-	if mat.NormalTex != -1 {
-		texIndices++
+
+	type spec struct {
+		thickness     float32
+		expTex        int32
+		expConvention material.NormalMapConvention
 	}
-	if texIndices != expTexCount {
-		t.Fatalf("expected %d texture indices to be assigned to mat0; got %d", expTexCount, texIndices)
+	specs := []spec{
+		// The coat defaults to the base normal
+		spec{0.1, -1, material.NormalMapOpenGL},
+		spec{0.2, 0, material.NormalMapDirectX},
+		// map_coat_normal only applies to layered nodes without a coat normal map
+		spec{0.3, 1, material.NormalMapOpenGL},
+		spec{0.4, 0, material.NormalMapOpenGL},
+	}
+
+	for index, s := range specs {
+		node := layered[s.thickness]
+		if node == nil {
+			t.Errorf("[spec %d] could not find layered node with thickness %f", index, s.thickness)
+			continue
+		}
+
+		texIndex, hasCoatNormal := node.CoatNormalMap()
+		if texIndex != s.expTex || hasCoatNormal != (s.expTex != -1) {
+			t.Errorf("[spec %d] expected coat normal map texture to be %d; got %d", index, s.expTex, texIndex)
+			continue
+		}
+		if hasCoatNormal && node.NormalMapConvention() != s.expConvention {
+			t.Errorf("[spec %d] expected coat normal map convention to be %s; got %s", index, s.expConvention, node.NormalMapConvention())
+		}
 	}
 }
 
-func TestMaterialLoaderWithMissingTextures(t *testing.T) {
+func TestMaterialLoaderComplexIOR(t *testing.T) {
 	payload := `
-newmtl foo
-map_Kd invalid.png
+newmtl gold
+mat_expr conductor(metal: "gold")
+
+newmtl custom
+mat_expr conductor(eta: {0.2, 0.9, 1.1}, k: {3.9, 2.4, 1.6})
+
+newmtl plain
+mat_expr conductor(intIOR: 1.5)
 `
 	res := mockResource(payload)
 	r := newWavefrontReader()
@@ -564,13 +783,53 @@ map_Kd invalid.png
 		t.Fatal(err)
 	}
 
-	if len(r.sceneGraph.Textures) != 0 {
-		t.Fatalf("expected texture list to be empty; got %d items", len(r.sceneGraph.Textures))
+	geometry := `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+usemtl gold
+f 1 2 3
+usemtl custom
+f 1 2 3
+usemtl plain
+f 1 2 3
+`
+	sc, err := r.Read(mockResource(geometry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gold, err := material.ComplexIOR("gold")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type spec struct {
+		expEta types.Vec3
+		expK   types.Vec3
+	}
+	specs := []spec{
+		spec{gold.Eta, gold.K},
+		spec{types.Vec3{0.2, 0.9, 1.1}, types.Vec3{3.9, 2.4, 1.6}},
+		// Conductors without a complex IOR fall back to intIOR
+		spec{types.Vec3{}, types.Vec3{}},
 	}
 
-	mat := r.sceneGraph.Materials[0]
-	if mat.KdTex != -1 {
-		t.Fatalf("expected kdTex to be -1 for missing texture; got %d", mat.KdTex)
+	if len(sc.MaterialIndex) != len(specs) {
+		t.Fatalf("expected %d materials; got %d", len(specs), len(sc.MaterialIndex))
+	}
+	for index, s := range specs {
+		node := sc.MaterialNodeList[sc.MaterialIndex[index]]
+		if node.Union1[0] != int32(material.BxdfConductor) {
+			t.Errorf("[spec %d] expected node type to be %d; got %d", index, material.BxdfConductor, node.Union1[0])
+			continue
+		}
+		if eta := node.Union3.Vec3(); eta != s.expEta {
+			t.Errorf("[spec %d] expected eta to be %v; got %v", index, s.expEta, eta)
+		}
+		if k := node.Union6.Vec3(); k != s.expK {
+			t.Errorf("[spec %d] expected k to be %v; got %v", index, s.expK, k)
+		}
 	}
 }
 
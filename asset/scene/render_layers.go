@@ -0,0 +1,135 @@
+package scene
+
+import "fmt"
+
+// The max number of render layers supported by a scene. Layer membership is
+// stored as a per-instance bitmask so each instance can belong to any number
+// of layers.
+const MaxRenderLayers = 32
+
+// A named group of mesh instances. The renderer can output a coverage mask
+// for each layer: the fraction of each pixel's primary rays whose first hit
+// belongs to an instance in the layer.
+type RenderLayer struct {
+	Name string
+
+	// The mesh instance indices assigned to the layer.
+	Instances []uint32
+}
+
+// Assign a set of mesh instances to a named render layer, replacing any
+// existing layer with the same name.
+func (sc *Scene) SetRenderLayer(name string, instances []uint32) error {
+	layer := RenderLayer{Name: name, Instances: instances}
+	if err := sc.checkRenderLayer(&layer); err != nil {
+		return err
+	}
+
+	for index := range sc.RenderLayers {
+		if sc.RenderLayers[index].Name == name {
+			sc.RenderLayers[index] = layer
+			return nil
+		}
+	}
+	if len(sc.RenderLayers) == MaxRenderLayers {
+		return fmt.Errorf("scene: cannot define more than %d render layers", MaxRenderLayers)
+	}
+	sc.RenderLayers = append(sc.RenderLayers, layer)
+	return nil
+}
+
+// Get the index of the render layer with the specified name.
+func (sc *Scene) RenderLayerIndex(name string) (int, bool) {
+	for index := range sc.RenderLayers {
+		if sc.RenderLayers[index].Name == name {
+			return index, true
+		}
+	}
+	return -1, false
+}
+
+// Get the render layer membership for each mesh instance in the layout used
+// by the opencl kernels. Bit i of each entry is set if the instance belongs
+// to render layer i. Returns nil if the scene does not define any layers.
+func (sc *Scene) InstanceLayerMasks() []uint32 {
+	if len(sc.RenderLayers) == 0 {
+		return nil
+	}
+
+	masks := make([]uint32, len(sc.MeshInstanceList))
+	for layerIndex, layer := range sc.RenderLayers {
+		for _, instance := range layer.Instances {
+			if int(instance) < len(masks) {
+				masks[instance] |= 1 << uint(layerIndex)
+			}
+		}
+	}
+	return masks
+}
+
+// Calculate the coverage mask of each render layer on the CPU by tracing a
+// stratified grid of samplesPerPixel x samplesPerPixel primary rays for each
+// pixel. Each returned mask stores one value per pixel in row-major order.
+//
+// Like the opencl kernels, all samples are equally weighted so the mask value
+// for each pixel approximates the fraction of its area covered by the layer's
+// instances.
+func (sc *Scene) RenderLayerCoverage(cam *Camera, frameW, frameH, samplesPerPixel uint32) [][]float32 {
+	masks := make([][]float32, len(sc.RenderLayers))
+	for index := range masks {
+		masks[index] = make([]float32, frameW*frameH)
+	}
+	if len(masks) == 0 || samplesPerPixel == 0 {
+		return masks
+	}
+
+	instanceMasks := sc.InstanceLayerMasks()
+	sc.tracePrimaryCoverage(cam, frameW, frameH, samplesPerPixel, func(pixelIndex uint32, hit RayHit, sampleWeight float32) {
+		if int(hit.MeshInstance) >= len(instanceMasks) {
+			return
+		}
+
+		for layerMask, layerIndex := instanceMasks[hit.MeshInstance], 0; layerMask != 0; layerMask, layerIndex = layerMask>>1, layerIndex+1 {
+			if layerMask&1 != 0 {
+				masks[layerIndex][pixelIndex] += sampleWeight
+			}
+		}
+	})
+
+	return masks
+}
+
+func (sc *Scene) checkRenderLayer(layer *RenderLayer) error {
+	if layer.Name == "" {
+		return fmt.Errorf("scene: render layers must have a name")
+	}
+	for _, instance := range layer.Instances {
+		if int(instance) >= len(sc.MeshInstanceList) {
+			return fmt.Errorf("scene: render layer %q references invalid mesh instance %d", layer.Name, instance)
+		}
+	}
+	return nil
+}
+
+// Trace a stratified grid of samplesPerPixel x samplesPerPixel primary rays
+// for each pixel (clipped by the camera clip planes) and invoke fn with the pixel index, the hit and the sample
+// weight for each ray that hits the scene.
+func (sc *Scene) tracePrimaryCoverage(cam *Camera, frameW, frameH, samplesPerPixel uint32, fn func(pixelIndex uint32, hit RayHit, sampleWeight float32)) {
+	scratch := NewRayScratch()
+	sampleWeight := 1.0 / float32(samplesPerPixel*samplesPerPixel)
+	for y := uint32(0); y < frameH; y++ {
+		for x := uint32(0); x < frameW; x++ {
+			pixelIndex := y*frameW + x
+			for sy := uint32(0); sy < samplesPerPixel; sy++ {
+				for sx := uint32(0); sx < samplesPerPixel; sx++ {
+					px := float32(x) + (float32(sx)+0.5)/float32(samplesPerPixel)
+					py := float32(y) + (float32(sy)+0.5)/float32(samplesPerPixel)
+					ray := cam.ClipRay(cameraRayThrough(cam, px, py, frameW, frameH))
+					if hit, found := sc.IntersectWithScratch(scratch, ray.Origin, ray.Dir, ray.maxDist()); found {
+						fn(pixelIndex, hit, sampleWeight)
+					}
+				}
+			}
+		}
+	}
+}
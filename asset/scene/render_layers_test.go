@@ -0,0 +1,118 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRenderLayerCoverage(t *testing.T) {
+	// Two instances of a 2x2 plane placed side by side
+	sc := makePlaneTestScene(1)
+	sc.MeshInstanceList[0].Transform = types.Translate4(types.Vec3{-1.5, 0, 0}).Inv()
+	sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+		MeshIndex: 0,
+		BvhRoot:   sc.MeshInstanceList[0].BvhRoot,
+		Transform: types.Translate4(types.Vec3{1.5, 0, 0}).Inv(),
+	})
+	sc.RebuildTopLevel()
+
+	if err := sc.SetRenderLayer("left", []uint32{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetRenderLayer("right", []uint32{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetRenderLayer("both", []uint32{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	cam := NewCamera(math.Pi / 4)
+	cam.Position = types.Vec3{0, 0, 5}
+	cam.LookAt = types.Vec3{0, 0, 0}
+	cam.SetupProjection(1)
+
+	const frameW, frameH = 16, 16
+	masks := sc.RenderLayerCoverage(cam, frameW, frameH, 4)
+	if len(masks) != 3 {
+		t.Fatalf("expected to get 3 layer masks; got %d", len(masks))
+	}
+
+	left, right, both := masks[0], masks[1], masks[2]
+	var fractional int
+	scratch := NewRayScratch()
+	for y := 0; y < frameH; y++ {
+		for x := 0; x < frameW; x++ {
+			index := y*frameW + x
+			if left[index] > 0 && right[index] > 0 {
+				t.Fatalf("expected pixel (%d, %d) to be covered by a single layer; got left: %f, right: %f", x, y, left[index], right[index])
+			}
+			if sum := left[index] + right[index]; math.Abs(float64(both[index]-sum)) > 1e-5 {
+				t.Fatalf("expected combined layer coverage for pixel (%d, %d) to be %f; got %f", x, y, sum, both[index])
+			}
+			if left[index] > 0 && left[index] < 1 || right[index] > 0 && right[index] < 1 {
+				fractional++
+			}
+
+			// Pixels whose center hits an instance must be covered by its layer
+			origin, dir := cameraRay(cam, x, y, frameW, frameH)
+			hit, found := sc.IntersectWithScratch(scratch, origin, dir, math.MaxFloat32)
+			switch {
+			case !found:
+				continue
+			case hit.MeshInstance == 0 && (left[index] == 0 || right[index] != 0):
+				t.Errorf("expected pixel (%d, %d) to only be covered by the left layer; got left: %f, right: %f", x, y, left[index], right[index])
+			case hit.MeshInstance == 1 && (right[index] == 0 || left[index] != 0):
+				t.Errorf("expected pixel (%d, %d) to only be covered by the right layer; got left: %f, right: %f", x, y, left[index], right[index])
+			}
+		}
+	}
+
+	if fractional == 0 {
+		t.Fatal("expected pixels along the instance edges to be partially covered")
+	}
+}
+
+func TestSetRenderLayer(t *testing.T) {
+	sc := makePlaneTestScene(1)
+
+	type spec struct {
+		name      string
+		instances []uint32
+		expErr    bool
+	}
+	specs := []spec{
+		spec{"", []uint32{0}, true},
+		spec{"layer", []uint32{1}, true},
+		spec{"layer", []uint32{0}, false},
+		// Replaces the existing layer
+		spec{"layer", nil, false},
+	}
+
+	for index, s := range specs {
+		err := sc.SetRenderLayer(s.name, s.instances)
+		if s.expErr && err == nil {
+			t.Errorf("[spec %d] expected to get an error", index)
+		} else if !s.expErr && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+
+	if len(sc.RenderLayers) != 1 || len(sc.RenderLayers[0].Instances) != 0 {
+		t.Fatalf("expected the layer to be replaced; got %v", sc.RenderLayers)
+	}
+
+	for layer := 1; layer < MaxRenderLayers; layer++ {
+		if err := sc.SetRenderLayer(string(rune('a'+layer)), []uint32{0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sc.SetRenderLayer("overflow", []uint32{0}); err == nil {
+		t.Fatalf("expected an error when defining more than %d render layers", MaxRenderLayers)
+	}
+
+	if masks := sc.InstanceLayerMasks(); len(masks) != 1 || masks[0] != 0xfffffffe {
+		t.Fatalf("expected instance layer masks to be [0xfffffffe]; got %x", masks)
+	}
+}
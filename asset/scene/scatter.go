@@ -0,0 +1,96 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Append a batch of instances of a mesh and rebuild the top-level BVH tree.
+// Each transform positions a new instance in the world (mesh to world space).
+// All new instances share the bottom-level BVH tree of the existing mesh
+// instances so the mesh must already be referenced by at least one instance.
+//
+// If the mesh contains emissive primitives, an emissive copy is generated for
+// each new instance. Storage for the new instances and emissives is allocated
+// once for the entire batch.
+func ScatterInstances(s *Scene, meshIndex uint32, transforms []types.Mat4) error {
+	if len(transforms) == 0 {
+		return nil
+	}
+
+	var bvhRoot int32 = -1
+	for _, mi := range s.MeshInstanceList {
+		if mi.MeshIndex == meshIndex {
+			bvhRoot = int32(mi.BvhRoot)
+			break
+		}
+	}
+	if bvhRoot == -1 {
+		return fmt.Errorf("scene: no instance references mesh %d", meshIndex)
+	}
+
+	// Collect the unique emissive primitives that belong to the mesh
+	meshEmissives, err := meshEmissivePrimitives(s, meshIndex)
+	if err != nil {
+		return err
+	}
+
+	instances := make([]MeshInstance, len(s.MeshInstanceList), len(s.MeshInstanceList)+len(transforms))
+	copy(instances, s.MeshInstanceList)
+	emissives := s.EmissivePrimitives
+	if len(meshEmissives) != 0 {
+		emissives = make([]EmissivePrimitive, len(s.EmissivePrimitives), len(s.EmissivePrimitives)+len(transforms)*len(meshEmissives))
+		copy(emissives, s.EmissivePrimitives)
+	}
+
+	for index, transform := range transforms {
+		if transform.Det() == 0 {
+			return fmt.Errorf("scene: scatter transform %d is not invertible", index)
+		}
+
+		// Instance transforms convert from world to mesh space
+		worldToMesh := transform.Inv()
+
+		instances = append(instances, MeshInstance{
+			MeshIndex: meshIndex,
+			BvhRoot:   uint32(bvhRoot),
+			Transform: worldToMesh,
+		})
+		for _, em := range meshEmissives {
+			em.Transform = worldToMesh
+			emissives = append(emissives, em)
+		}
+	}
+
+	s.MeshInstanceList = instances
+	s.EmissivePrimitives = emissives
+	s.RebuildTopLevel()
+	return nil
+}
+
+// Get a copy of each unique area light that belongs to a mesh.
+func meshEmissivePrimitives(s *Scene, meshIndex uint32) ([]EmissivePrimitive, error) {
+	if len(s.EmissivePrimitives) == 0 {
+		return nil, nil
+	}
+
+	firstPrim, numPrims, err := meshPrimitives(s, meshIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []EmissivePrimitive
+	seen := make(map[uint32]struct{})
+	for _, em := range s.EmissivePrimitives {
+		if em.Type != AreaLight || em.PrimitiveIndex < firstPrim || em.PrimitiveIndex >= firstPrim+numPrims {
+			continue
+		}
+		if _, exists := seen[em.PrimitiveIndex]; exists {
+			continue
+		}
+		seen[em.PrimitiveIndex] = struct{}{}
+		out = append(out, em)
+	}
+	return out, nil
+}
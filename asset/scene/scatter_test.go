@@ -0,0 +1,79 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestScatterInstances(t *testing.T) {
+	sc := makePlaneTestScene(2)
+	sc.EmissivePrimitives = []EmissivePrimitive{
+		{Type: AreaLight, PrimitiveIndex: 3, Transform: sc.MeshInstanceList[0].Transform},
+		{Type: EnvironmentLight},
+	}
+	bvhRoot := sc.MeshInstanceList[0].BvhRoot
+
+	// Scatter scaled down copies of the plane on a 40x25 grid
+	const gridW, gridH = 40, 25
+	transforms := make([]types.Mat4, 0, gridW*gridH)
+	for y := 0; y < gridH; y++ {
+		for x := 0; x < gridW; x++ {
+			transforms = append(transforms, types.Translate4(types.Vec3{float32(x) + 10, float32(y), 0}).Mul4(types.Scale4(types.Vec3{0.25, 0.25, 0.25})))
+		}
+	}
+
+	if err := ScatterInstances(sc, 0, transforms); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sc.MeshInstanceList) != 1+gridW*gridH {
+		t.Fatalf("expected %d mesh instances; got %d", 1+gridW*gridH, len(sc.MeshInstanceList))
+	}
+
+	// The bottom-level tree may be shifted by the top-level rebuild but
+	// all instances should still share it
+	bvhRoot = sc.MeshInstanceList[0].BvhRoot
+	for index, mi := range sc.MeshInstanceList {
+		if mi.BvhRoot != bvhRoot {
+			t.Fatalf("expected instance %d to share BVH root %d; got %d", index, bvhRoot, mi.BvhRoot)
+		}
+	}
+
+	// Each scattered instance should get its own emissive copy
+	if len(sc.EmissivePrimitives) != 2+gridW*gridH {
+		t.Fatalf("expected %d emissives; got %d", 2+gridW*gridH, len(sc.EmissivePrimitives))
+	}
+	if em := sc.EmissivePrimitives[len(sc.EmissivePrimitives)-1]; em.PrimitiveIndex != 3 || em.Transform != sc.MeshInstanceList[len(sc.MeshInstanceList)-1].Transform {
+		t.Fatalf("expected last emissive to copy primitive 3 with the last instance transform; got %+v", em)
+	}
+
+	// A ray aimed at the center of each scattered instance should hit it
+	scratch := NewRayScratch()
+	for index := range transforms {
+		x, y := index%gridW, index/gridW
+		hit, found := sc.IntersectWithScratch(scratch, types.Vec3{float32(x) + 10.05, float32(y) + 0.05, 1}, types.Vec3{0, 0, -1}, 10)
+		if !found || hit.MeshInstance != uint32(index+1) {
+			t.Fatalf("expected ray to hit instance %d; got %+v (%t)", index+1, hit, found)
+		}
+	}
+
+	// Rays that pass between the instances should miss
+	if hit, found := sc.Intersect(types.Vec3{10.5, 0.5, 1}, types.Vec3{0, 0, -1}, 10); found {
+		t.Fatalf("expected ray to miss the scattered instances; got %+v", hit)
+	}
+}
+
+func TestScatterInstancesErrors(t *testing.T) {
+	sc := makePlaneTestScene(1)
+
+	if err := ScatterInstances(sc, 1, []types.Mat4{types.Ident4()}); err == nil {
+		t.Fatal("expected an error when scattering a mesh without instances")
+	}
+	if err := ScatterInstances(sc, 0, []types.Mat4{types.Ident4(), types.Mat4{}}); err == nil {
+		t.Fatal("expected an error for a non-invertible transform")
+	}
+	if len(sc.MeshInstanceList) != 1 {
+		t.Fatalf("expected failed scatter not to modify the scene; got %d instances", len(sc.MeshInstanceList))
+	}
+}
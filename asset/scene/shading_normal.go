@@ -0,0 +1,51 @@
+package scene
+
+import "github.com/achilleasa/polaris/types"
+
+// Adjust an interpolated or mapped shading normal so that it is consistent
+// with the geometric normal for the outgoing direction outDir (pointing away
+// from the surface).
+//
+// Shading normals that tilt away from the viewer can place outDir or its
+// mirror reflection below the geometric surface; this causes the black facets
+// and energy leaks that are visible near the shadow terminator of low-poly
+// smooth-shaded meshes. If the reflection of outDir around the shading normal
+// falls below the geometric surface, the reflection is bent so that it lies
+// just above the surface and the shading normal is replaced by the half vector
+// between outDir and the bent reflection. Normals that do not need adjusting
+// are returned unmodified. The method is described in: A. Keller et al., "The
+// Iray Light Transport Simulation and Rendering System", 2017, section A.3.
+//
+// The orientation of the returned normal matches the orientation of the input
+// shading normal so refractive bxdfs can still detect whether outDir lies
+// inside the surface.
+func ConsistentShadingNormal(normal, geomNormal, outDir types.Vec3) types.Vec3 {
+	// The triangle winding may not agree with the shading normals
+	if geomNormal.Dot(normal) < 0 {
+		geomNormal = geomNormal.Mul(-1)
+	}
+
+	// Work on the side of the surface where outDir lies
+	var side float32 = 1
+	if geomNormal.Dot(outDir) < 0 {
+		side = -1
+	}
+	ng, ns := geomNormal.Mul(side), normal.Mul(side)
+
+	reflected := ns.Mul(2 * outDir.Dot(ns)).Sub(outDir)
+	threshold := outDir.Dot(ng) * 0.9
+	if threshold > 0.01 {
+		threshold = 0.01
+	}
+	cosR := reflected.Dot(ng)
+	if cosR >= threshold {
+		return normal
+	}
+
+	bent := reflected.Add(ng.Mul(threshold - cosR)).Normalize()
+	halfway := outDir.Add(bent)
+	if halfway.Len() <= 1e-6 {
+		return geomNormal
+	}
+	return halfway.Normalize().Mul(side)
+}
@@ -0,0 +1,119 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestConsistentShadingNormal(t *testing.T) {
+	type spec struct {
+		normal, geomNormal, outDir types.Vec3
+		expUnchanged               bool
+	}
+	tilted := types.Vec3{0.6, 0, 0.8}
+	specs := []spec{
+		// Flat shading
+		spec{types.Vec3{0, 0, 1}, types.Vec3{0, 0, 1}, types.Vec3{0.6, 0, 0.8}, true},
+		// Tilted normal with a steep outgoing direction
+		spec{tilted, types.Vec3{0, 0, 1}, types.Vec3{0, 0, 1}, true},
+		// Tilted normal with reversed winding
+		spec{tilted, types.Vec3{0, 0, -1}, types.Vec3{0, 0, 1}, true},
+		// Grazing outgoing direction on the side the normal tilts away from
+		spec{tilted, types.Vec3{0, 0, 1}, types.Vec3{-0.99, 0, 0.141}.Normalize(), false},
+		// Same as above but seen from below the surface
+		spec{tilted, types.Vec3{0, 0, 1}, types.Vec3{-0.99, 0, -0.141}.Normalize(), false},
+	}
+
+	for index, s := range specs {
+		got := ConsistentShadingNormal(s.normal, s.geomNormal, s.outDir)
+		if s.expUnchanged {
+			if got != s.normal {
+				t.Errorf("[spec %d] expected normal to remain unchanged; got %v", index, got)
+			}
+			continue
+		}
+
+		if math.Abs(float64(got.Len()-1)) > 1e-4 {
+			t.Errorf("[spec %d] expected a unit normal; got %v", index, got)
+		}
+		if got.Dot(s.normal) <= 0 {
+			t.Errorf("[spec %d] expected normal %v to retain the orientation of %v", index, got, s.normal)
+		}
+		if reflected := reflectDir(s.outDir.Mul(-1), got); reflected.Dot(s.geomNormal)*s.outDir.Dot(s.geomNormal) < 0 {
+			t.Errorf("[spec %d] expected reflected direction %v to stay on the outgoing side of the surface", index, reflected)
+		}
+	}
+}
+
+func TestConsistentShadingNormalCoarseSphere(t *testing.T) {
+	const (
+		rings    = 4
+		segments = 6
+	)
+
+	// Build a coarse uv sphere with smooth normals; vertex normals on a
+	// unit sphere are equal to the vertex positions.
+	vertex := func(ring, seg int) types.Vec3 {
+		theta := math.Pi * float64(ring) / rings
+		phi := 2 * math.Pi * float64(seg) / segments
+		return types.Vec3{
+			float32(math.Sin(theta) * math.Cos(phi)),
+			float32(math.Cos(theta)),
+			float32(math.Sin(theta) * math.Sin(phi)),
+		}
+	}
+	var tris [][3]types.Vec3
+	for ring := 0; ring < rings; ring++ {
+		for seg := 0; seg < segments; seg++ {
+			v00, v01 := vertex(ring, seg), vertex(ring, seg+1)
+			v10, v11 := vertex(ring+1, seg), vertex(ring+1, seg+1)
+			if ring != 0 {
+				tris = append(tris, [3]types.Vec3{v00, v01, v11})
+			}
+			if ring != rings-1 {
+				tris = append(tris, [3]types.Vec3{v00, v11, v10})
+			}
+		}
+	}
+
+	// Light the sphere from the side and look for facets where the light
+	// direction grazes the geometry and the interpolated shading normal
+	// either faces away from the light or reflects it below the surface.
+	lightDir := types.Vec3{1, 0.1, 0.05}.Normalize()
+	var artifacts, fixedArtifacts int
+	for _, tri := range tris {
+		geomNormal := tri[1].Sub(tri[0]).Cross(tri[2].Sub(tri[0])).Normalize()
+		if geomNormal.Dot(tri[0]) < 0 {
+			geomNormal = geomNormal.Mul(-1)
+		}
+		if geomNormal.Dot(lightDir) <= 0 {
+			continue
+		}
+
+		for i := 0; i <= 8; i++ {
+			for j := 0; i+j <= 8; j++ {
+				w := [3]float32{float32(i) / 8, float32(j) / 8, float32(8-i-j) / 8}
+				normal := tri[0].Mul(w[0]).Add(tri[1].Mul(w[1])).Add(tri[2].Mul(w[2])).Normalize()
+
+				isArtifact := func(n types.Vec3) bool {
+					return n.Dot(lightDir) <= 0 || reflectDir(lightDir.Mul(-1), n).Dot(geomNormal) < 0
+				}
+				if isArtifact(normal) {
+					artifacts++
+				}
+				if isArtifact(ConsistentShadingNormal(normal, geomNormal, lightDir)) {
+					fixedArtifacts++
+				}
+			}
+		}
+	}
+
+	if artifacts == 0 {
+		t.Fatal("expected the coarse sphere to exhibit terminator artifacts without the fix")
+	}
+	if fixedArtifacts != 0 {
+		t.Fatalf("expected no terminator artifacts after adjusting the shading normals; got %d (was %d)", fixedArtifacts, artifacts)
+	}
+}
@@ -0,0 +1,23 @@
+package scene
+
+import "github.com/achilleasa/polaris/asset/material"
+
+// Check whether shadow rays can pass through any of the scene surfaces. This
+// is the case for scenes with semi-transparent or dielectric materials. The
+// opencl tracer skips its shadow transparency passes for scenes without such
+// materials as all surfaces block shadow rays.
+func (sc *Scene) HasTransmissiveMaterials() bool {
+	for _, opacity := range sc.Opacities {
+		if opacity < 1 {
+			return true
+		}
+	}
+
+	for _, node := range sc.MaterialNodeList {
+		switch material.BxdfType(node.Union1[0]) {
+		case material.BxdfDielectric, material.BxdfRoughDielectric:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestHasTransmissiveMaterials(t *testing.T) {
+	diffuse := MaterialNode{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{0.8, 0.8, 0.8, 0}}
+	glass := MaterialNode{Union1: [4]int32{int32(material.BxdfDielectric), -1, -1, -1}, Union3: types.Vec4{1, 1, 1, 0}, Union4: types.Vec3{1.5, 1, 0}}
+	roughGlass := glass
+	roughGlass.Union1[0] = int32(material.BxdfRoughDielectric)
+	mix := MaterialNode{Union1: [4]int32{int32(material.OpMix), 1, 2, -1}, Union2: types.Vec4{0.5, 0, 0, 0}}
+
+	type spec struct {
+		nodes     []MaterialNode
+		opacities []float32
+		expResult bool
+	}
+	specs := []spec{
+		spec{[]MaterialNode{diffuse}, nil, false},
+		spec{[]MaterialNode{diffuse}, []float32{1}, false},
+		spec{[]MaterialNode{diffuse}, []float32{0.5}, true},
+		spec{[]MaterialNode{glass}, nil, true},
+		spec{[]MaterialNode{roughGlass}, nil, true},
+		spec{[]MaterialNode{mix, diffuse, glass}, nil, true},
+	}
+
+	for index, s := range specs {
+		sc := &Scene{MaterialNodeList: s.nodes, Opacities: s.opacities}
+		if got := sc.HasTransmissiveMaterials(); got != s.expResult {
+			t.Errorf("[spec %d] expected HasTransmissiveMaterials to return %t; got %t", index, s.expResult, got)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package scene
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A summary of the scene contents.
+type SceneSummary struct {
+	Triangles          int
+	MeshInstances      int
+	EmissivePrimitives int
+	MaterialNodes      int
+	Textures           int
+	TextureBytes       int
+	BvhNodes           int
+
+	// The scene bounds as defined by the bbox of the top-level BVH root.
+	// The bounds are only valid if HasBounds is true.
+	Bounds    [2]types.Vec3
+	HasBounds bool
+}
+
+// Implements Stringer.
+func (s SceneSummary) String() string {
+	out := fmt.Sprintf(
+		"%d triangles, %d mesh instances, %d emissives, %d material nodes, %d textures (%s), %d BVH nodes",
+		s.Triangles, s.MeshInstances, s.EmissivePrimitives, s.MaterialNodes,
+		s.Textures, strings.TrimSpace(fmtBytes(float32(s.TextureBytes))), s.BvhNodes,
+	)
+	if s.HasBounds {
+		out += fmt.Sprintf(", bounds %v - %v", s.Bounds[0], s.Bounds[1])
+	}
+	return out
+}
+
+// Generate a summary of the scene contents. The summary is calculated using
+// the length of the scene data slices so it is cheap to generate and can be
+// safely used with partially built scenes.
+func (sc *Scene) Summary() SceneSummary {
+	summary := SceneSummary{
+		Triangles:          len(sc.VertexList) / 3,
+		MeshInstances:      len(sc.MeshInstanceList),
+		EmissivePrimitives: len(sc.EmissivePrimitives),
+		MaterialNodes:      len(sc.MaterialNodeList),
+		Textures:           len(sc.TextureMetadata),
+		TextureBytes:       len(sc.TextureData),
+		BvhNodes:           len(sc.BvhNodeList),
+	}
+
+	if len(sc.BvhNodeList) != 0 {
+		summary.Bounds = [2]types.Vec3{sc.BvhNodeList[0].Min, sc.BvhNodeList[0].Max}
+		summary.HasBounds = true
+	}
+
+	return summary
+}
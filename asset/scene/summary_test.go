@@ -0,0 +1,52 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSummary(t *testing.T) {
+	// Partially built scenes should not cause a panic
+	summary := (&Scene{}).Summary()
+	if summary.Triangles != 0 || summary.BvhNodes != 0 || summary.HasBounds {
+		t.Fatalf("expected empty summary for empty scene; got %+v", summary)
+	}
+
+	sc := &Scene{
+		BvhNodeList:        make([]BvhNode, 7),
+		MeshInstanceList:   make([]MeshInstance, 3),
+		MaterialNodeList:   make([]MaterialNode, 4),
+		EmissivePrimitives: make([]EmissivePrimitive, 2),
+		TextureMetadata:    make([]TextureMetadata, 2),
+		TextureData:        make([]byte, 2048),
+		VertexList:         make([]types.Vec4, 15),
+	}
+	sc.BvhNodeList[0].SetBBox([2]types.Vec3{{-1, -2, -3}, {1, 2, 3}})
+
+	summary = sc.Summary()
+	type spec struct {
+		name   string
+		value  int
+		expVal int
+	}
+	specs := []spec{
+		spec{"triangles", summary.Triangles, 5},
+		spec{"mesh instances", summary.MeshInstances, 3},
+		spec{"emissives", summary.EmissivePrimitives, 2},
+		spec{"material nodes", summary.MaterialNodes, 4},
+		spec{"textures", summary.Textures, 2},
+		spec{"texture bytes", summary.TextureBytes, 2048},
+		spec{"bvh nodes", summary.BvhNodes, 7},
+	}
+	for index, s := range specs {
+		if s.value != s.expVal {
+			t.Fatalf("[spec %d] expected %s count to be %d; got %d", index, s.name, s.expVal, s.value)
+		}
+	}
+
+	expBounds := [2]types.Vec3{{-1, -2, -3}, {1, 2, 3}}
+	if !summary.HasBounds || summary.Bounds != expBounds {
+		t.Fatalf("expected scene bounds to be %v; got %v", expBounds, summary.Bounds)
+	}
+}
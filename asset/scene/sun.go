@@ -0,0 +1,57 @@
+package scene
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The angular diameter of the sun as seen from earth in degrees.
+const DefaultSunAngularDiameter = 0.53
+
+// A distant light source such as the sun. Unlike an ideal directional light,
+// the sun subtends a small cone of directions which produces soft penumbrae
+// in the shadows cast by its light.
+type SunLight struct {
+	// The direction towards the light.
+	Direction types.Vec3
+
+	// The angular diameter of the light disk in degrees. A zero diameter
+	// models an ideal directional light that casts hard shadows.
+	AngularDiameter float32
+}
+
+// Get the solid angle of the cone subtended by the light.
+func (l *SunLight) SolidAngle() float32 {
+	if l.AngularDiameter <= 0 {
+		return 0
+	}
+	return float32(2 * math.Pi * (1 - math.Cos(float64(l.AngularDiameter)*math.Pi/360.0)))
+}
+
+// Create an emissive primitive for the light. The radiance of the emissive
+// material node defines the irradiance received by surfaces that face the
+// light so changing the angular diameter does not affect the brightness of
+// the light.
+//
+// The opencl kernels sample directions inside the light cone using an
+// orthonormal basis that is stored in the first three columns of the
+// emissive transform (tangent, bitangent and light direction) while the solid
+// angle of the cone is stored in the emissive area.
+func (l *SunLight) Emissive(materialNodeIndex uint32) EmissivePrimitive {
+	dir := l.Direction.Normalize()
+	tangent, bitangent := types.BuildOrthonormalBasis(dir)
+
+	// Mat4 is stored in column-major order
+	return EmissivePrimitive{
+		Transform: types.Mat4{
+			tangent[0], tangent[1], tangent[2], 0,
+			bitangent[0], bitangent[1], bitangent[2], 0,
+			dir[0], dir[1], dir[2], 0,
+			0, 0, 0, 1,
+		},
+		Area:              l.SolidAngle(),
+		MaterialNodeIndex: materialNodeIndex,
+		Type:              DirectionalLight,
+	}
+}
@@ -0,0 +1,51 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSunLightEmissive(t *testing.T) {
+	type spec struct {
+		sun           SunLight
+		expSolidAngle float32
+	}
+	specs := []spec{
+		spec{SunLight{Direction: types.Vec3{0, 2, 0}}, 0},
+		spec{SunLight{Direction: types.Vec3{1, 1, 0}, AngularDiameter: 10}, float32(2 * math.Pi * (1 - math.Cos(5*math.Pi/180)))},
+		spec{SunLight{Direction: types.Vec3{0, 0, -1}, AngularDiameter: DefaultSunAngularDiameter}, 6.72e-5},
+	}
+
+	for index, s := range specs {
+		em := s.sun.Emissive(3)
+		if em.Type != DirectionalLight || em.MaterialNodeIndex != 3 {
+			t.Errorf("[spec %d] expected a directional light emissive for material node 3; got type %d and node %d", index, em.Type, em.MaterialNodeIndex)
+		}
+		if math.Abs(float64(em.Area-s.expSolidAngle)) > 1e-6 {
+			t.Errorf("[spec %d] expected emissive area to be the cone solid angle %f; got %f", index, s.expSolidAngle, em.Area)
+		}
+
+		// The first three columns of the transform should contain an
+		// orthonormal basis whose last axis points towards the light
+		var basis [3]types.Vec3
+		for col := range basis {
+			basis[col] = types.Vec3{em.Transform[4*col], em.Transform[4*col+1], em.Transform[4*col+2]}
+		}
+		if !types.ApproxEqual(basis[2], s.sun.Direction.Normalize(), 1e-5) {
+			t.Errorf("[spec %d] expected basis to be aligned with the light direction %v; got %v", index, s.sun.Direction.Normalize(), basis[2])
+		}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				var exp float32
+				if i == j {
+					exp = 1
+				}
+				if dot := basis[i].Dot(basis[j]); math.Abs(float64(dot-exp)) > 1e-5 {
+					t.Errorf("[spec %d] expected basis[%d] . basis[%d] to be %f; got %f", index, i, j, exp, dot)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package scene
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/asset"
+	"github.com/achilleasa/polaris/asset/texure"
+	"github.com/achilleasa/polaris/types"
+)
+
+// A decoded texture image that is processed by the stages of a
+// TexturePipeline. Texel values are stored as float32 values regardless of
+// the format that is used when the image is encoded.
+type TextureImage struct {
+	// The format used when encoding the image.
+	Format texture.Format
+
+	// The base level followed by any generated mip levels.
+	Levels []TextureImageLevel
+}
+
+// A level of a TextureImage. Texels are stored in row-major order using
+// Format.Channels() values per texel.
+type TextureImageLevel struct {
+	Width  uint32
+	Height uint32
+	Texels []float32
+}
+
+// Decode a texture into a TextureImage. The image retains the texture format
+// until a conversion stage is applied to it.
+func DecodeTextureImage(tex *texture.Texture) (*TextureImage, error) {
+	channels := tex.Format.Channels()
+	if channels == 0 {
+		return nil, fmt.Errorf("scene: cannot decode texture with format %d", tex.Format)
+	}
+
+	texelCount := int(tex.Width * tex.Height)
+	bpp := int(texelSize(tex.Format))
+	if len(tex.Data) < texelCount*bpp {
+		return nil, fmt.Errorf("scene: expected %d bytes of data for a %dx%d texture; got %d", texelCount*bpp, tex.Width, tex.Height, len(tex.Data))
+	}
+
+	texels := make([]float32, texelCount*channels)
+	valueSize := bpp / channels
+	for index := range texels {
+		data := tex.Data[index*valueSize:]
+		switch tex.Format {
+		case texture.Luminance8, texture.Rgba8:
+			texels[index] = float32(data[0]) / 255.0
+		case texture.Luminance16F, texture.Rgba16F:
+			texels[index] = texture.HalfToFloat32(binary.LittleEndian.Uint16(data))
+		default:
+			texels[index] = math.Float32frombits(binary.LittleEndian.Uint32(data))
+		}
+	}
+
+	return &TextureImage{
+		Format: tex.Format,
+		Levels: []TextureImageLevel{{Width: tex.Width, Height: tex.Height, Texels: texels}},
+	}, nil
+}
+
+// Convert sRGB-encoded color values to linear values. The alpha channel of
+// rgba images is not modified. Linearizing 8-bit images reduces the precision
+// of dark colors; convert the image to half-floats to avoid banding.
+func (img *TextureImage) Linearize() {
+	channels := img.Format.Channels()
+	for levelIndex := range img.Levels {
+		texels := img.Levels[levelIndex].Texels
+		for index, v := range texels {
+			if channels == 4 && index%4 == 3 {
+				continue
+			}
+			texels[index] = srgbToLinear(v)
+		}
+	}
+}
+
+// Generate the mip chain for the image base level replacing any existing mip
+// levels. Each level is generated by box-filtering the previous level down to
+// a single texel.
+func (img *TextureImage) GenerateMips() {
+	channels := uint32(img.Format.Channels())
+	img.Levels = img.Levels[:1]
+	for prev := img.Levels[0]; prev.Width > 1 || prev.Height > 1; {
+		next := TextureImageLevel{
+			Width:  maxUint32(prev.Width/2, 1),
+			Height: maxUint32(prev.Height/2, 1),
+		}
+		next.Texels = make([]float32, next.Width*next.Height*channels)
+		for y := uint32(0); y < next.Height; y++ {
+			for x := uint32(0); x < next.Width; x++ {
+				// Average the 2x2 texel block; odd dims clamp to the last row/column
+				x0, y0 := minUint32(2*x, prev.Width-1), minUint32(2*y, prev.Height-1)
+				x1, y1 := minUint32(2*x+1, prev.Width-1), minUint32(2*y+1, prev.Height-1)
+				for c := uint32(0); c < channels; c++ {
+					sum := prev.Texels[(y0*prev.Width+x0)*channels+c] +
+						prev.Texels[(y0*prev.Width+x1)*channels+c] +
+						prev.Texels[(y1*prev.Width+x0)*channels+c] +
+						prev.Texels[(y1*prev.Width+x1)*channels+c]
+					next.Texels[(y*next.Width+x)*channels+c] = 0.25 * sum
+				}
+			}
+		}
+		img.Levels = append(img.Levels, next)
+		prev = next
+	}
+}
+
+// Select a half-float format for encoding the image.
+func (img *TextureImage) ToHalf() {
+	if img.Format.Channels() == 1 {
+		img.Format = texture.Luminance16F
+	} else {
+		img.Format = texture.Rgba16F
+	}
+}
+
+// Encode the image using its format. The DataOffset of the returned metadata
+// is relative to the start of the returned data; callers must adjust it when
+// appending the data to the scene texture data.
+func (img *TextureImage) Encode() (TextureMetadata, []byte) {
+	var data []byte
+	for _, level := range img.Levels {
+		for _, v := range level.Texels {
+			switch img.Format {
+			case texture.Luminance8, texture.Rgba8:
+				data = append(data, byte(clamp01(v)*255.0+0.5))
+			case texture.Luminance16F, texture.Rgba16F:
+				data = append(data, 0, 0)
+				binary.LittleEndian.PutUint16(data[len(data)-2:], texture.Float32ToHalf(v))
+			default:
+				data = append(data, 0, 0, 0, 0)
+				binary.LittleEndian.PutUint32(data[len(data)-4:], math.Float32bits(v))
+			}
+		}
+
+		// Pad each level to a multiple of 4 bytes
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	return TextureMetadata{
+		Format:    img.Format,
+		Width:     img.Levels[0].Width,
+		Height:    img.Levels[0].Height,
+		MipLevels: uint32(len(img.Levels)),
+	}, data
+}
+
+// A TexturePipeline chains the stages that prepare a texture for rendering.
+// Stages are executed in the order they are added when Run is invoked and
+// the first stage must always be Decode:
+//
+//	meta, data, err := NewTexturePipeline(res).Decode().Linearize().GenerateMips().ToHalf().Run()
+type TexturePipeline struct {
+	res    *asset.Resource
+	stages []textureStage
+}
+
+type textureStage struct {
+	name string
+	run  func(*TextureImage) (*TextureImage, error)
+}
+
+// Create a new texture pipeline for a texture resource.
+func NewTexturePipeline(res *asset.Resource) *TexturePipeline {
+	return &TexturePipeline{res: res}
+}
+
+// Load and decode the texture resource.
+func (p *TexturePipeline) Decode() *TexturePipeline {
+	return p.add("decode", func(*TextureImage) (*TextureImage, error) {
+		tex, err := texture.New(p.res)
+		if err != nil {
+			return nil, err
+		}
+		return DecodeTextureImage(tex)
+	})
+}
+
+// Convert sRGB-encoded color values to linear values.
+func (p *TexturePipeline) Linearize() *TexturePipeline {
+	return p.add("linearize", func(img *TextureImage) (*TextureImage, error) {
+		img.Linearize()
+		return img, nil
+	})
+}
+
+// Generate the texture mip chain.
+func (p *TexturePipeline) GenerateMips() *TexturePipeline {
+	return p.add("generate mips", func(img *TextureImage) (*TextureImage, error) {
+		img.GenerateMips()
+		return img, nil
+	})
+}
+
+// Convert the texture to a half-float format.
+func (p *TexturePipeline) ToHalf() *TexturePipeline {
+	return p.add("convert to half", func(img *TextureImage) (*TextureImage, error) {
+		img.ToHalf()
+		return img, nil
+	})
+}
+
+// Run the pipeline stages and encode the processed texture.
+func (p *TexturePipeline) Run() (TextureMetadata, []byte, error) {
+	if len(p.stages) == 0 || p.stages[0].name != "decode" {
+		return TextureMetadata{}, nil, fmt.Errorf("scene: texture pipeline must start with a decode stage")
+	}
+
+	var img *TextureImage
+	var err error
+	for _, stage := range p.stages {
+		if img, err = stage.run(img); err != nil {
+			return TextureMetadata{}, nil, fmt.Errorf("scene: texture pipeline stage %q failed: %v", stage.name, err)
+		}
+	}
+
+	meta, data := img.Encode()
+	return meta, data, nil
+}
+
+func (p *TexturePipeline) add(name string, run func(*TextureImage) (*TextureImage, error)) *TexturePipeline {
+	p.stages = append(p.stages, textureStage{name: name, run: run})
+	return p
+}
+
+// Convert a sRGB-encoded value to a linear value.
+func srgbToLinear(v float32) float32 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return float32(math.Pow((float64(v)+0.055)/1.055, 2.4))
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Get the size in bytes of a texel for the given format.
+func texelSize(format texture.Format) uint32 {
+	switch format {
+	case texture.Luminance8:
+		return 1
+	case texture.Luminance16F:
+		return 2
+	case texture.Luminance32F, texture.Rgba8:
+		return 4
+	case texture.Rgba16F:
+		return 8
+	case texture.Rgba32F:
+		return 16
+	}
+	return 0
+}
+
+// Convert raw texel data to a color value.
+func decodeTexel(format texture.Format, data []byte) types.Vec3 {
+	switch format {
+	case texture.Luminance8:
+		v := float32(data[0]) / 255.0
+		return types.Vec3{v, v, v}
+	case texture.Luminance32F:
+		v := math.Float32frombits(binary.LittleEndian.Uint32(data))
+		return types.Vec3{v, v, v}
+	case texture.Rgba8:
+		return types.Vec3{float32(data[0]) / 255.0, float32(data[1]) / 255.0, float32(data[2]) / 255.0}
+	case texture.Rgba32F:
+		return types.Vec3{
+			math.Float32frombits(binary.LittleEndian.Uint32(data[0:])),
+			math.Float32frombits(binary.LittleEndian.Uint32(data[4:])),
+			math.Float32frombits(binary.LittleEndian.Uint32(data[8:])),
+		}
+	case texture.Luminance16F:
+		v := texture.HalfToFloat32(binary.LittleEndian.Uint16(data))
+		return types.Vec3{v, v, v}
+	case texture.Rgba16F:
+		return types.Vec3{
+			texture.HalfToFloat32(binary.LittleEndian.Uint16(data[0:])),
+			texture.HalfToFloat32(binary.LittleEndian.Uint16(data[2:])),
+			texture.HalfToFloat32(binary.LittleEndian.Uint16(data[4:])),
+		}
+	}
+	return types.Vec3{}
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,143 @@
+package scene
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset"
+	"github.com/achilleasa/polaris/asset/texure"
+)
+
+func TestTexturePipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "polaris-tex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{188, 188, 188, 255})
+		}
+	}
+	imgFile := filepath.Join(dir, "test.png")
+	f, err := os.Create(imgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(f, img)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := asset.NewResource(imgFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+
+	meta, data, err := NewTexturePipeline(res).Decode().Linearize().GenerateMips().ToHalf().Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Format != texture.Rgba16F {
+		t.Fatalf("expected format to be %d; got %d", texture.Rgba16F, meta.Format)
+	}
+	if meta.Width != 8 || meta.Height != 4 {
+		t.Fatalf("expected dims to be 8x4; got %dx%d", meta.Width, meta.Height)
+	}
+	if meta.MipLevels != 4 {
+		t.Fatalf("expected 4 mip levels; got %d", meta.MipLevels)
+	}
+
+	// 8x4 + 4x2 + 2x1 + 1x1 texels, 8 bytes each
+	if expLen := (32 + 8 + 2 + 1) * 8; len(data) != expLen {
+		t.Fatalf("expected data len to be %d; got %d", expLen, len(data))
+	}
+
+	// sRGB 188/255 is roughly 0.5 in linear space
+	got := decodeTexel(meta.Format, data[(2*8+4)*8:])
+	if math.Abs(float64(got[0]-0.5)) > 0.01 {
+		t.Fatalf("expected linearized texel value to be close to 0.5; got %v", got)
+	}
+}
+
+func TestTexturePipelineWithoutDecode(t *testing.T) {
+	if _, _, err := NewTexturePipeline(nil).GenerateMips().Run(); err == nil {
+		t.Fatal("expected an error when running a pipeline without a decode stage")
+	}
+}
+
+func TestTextureImageStages(t *testing.T) {
+	tex := &texture.Texture{
+		Format: texture.Luminance8,
+		Width:  3,
+		Height: 2,
+		Data:   []byte{0, 255, 51, 255, 0, 255},
+	}
+
+	img, err := DecodeTextureImage(tex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != texture.Luminance8 || len(img.Levels) != 1 {
+		t.Fatalf("expected a single Luminance8 level; got format %d with %d levels", img.Format, len(img.Levels))
+	}
+
+	img.Linearize()
+	if got := img.Levels[0].Texels[2]; math.Abs(float64(got-0.0331)) > 1e-3 {
+		t.Fatalf("expected linearized value to be 0.0331; got %f", got)
+	}
+
+	img.GenerateMips()
+	expDims := [][2]uint32{{3, 2}, {1, 1}}
+	if len(img.Levels) != len(expDims) {
+		t.Fatalf("expected %d mip levels; got %d", len(expDims), len(img.Levels))
+	}
+	for index, exp := range expDims {
+		if level := img.Levels[index]; level.Width != exp[0] || level.Height != exp[1] {
+			t.Errorf("expected mip level %d dims to be %dx%d; got %dx%d", index, exp[0], exp[1], level.Width, level.Height)
+		}
+	}
+	if got := img.Levels[1].Texels[0]; got != 0.5 {
+		t.Errorf("expected top mip level to be 0.5; got %f", got)
+	}
+
+	meta, data := img.Encode()
+	if meta.Format != texture.Luminance8 || meta.MipLevels != 2 {
+		t.Fatalf("expected Luminance8 texture with 2 mip levels; got format %d with %d levels", meta.Format, meta.MipLevels)
+	}
+	// Each level is padded to 4 bytes
+	if len(data) != 12 {
+		t.Fatalf("expected encoded data len to be 12; got %d", len(data))
+	}
+
+	img.ToHalf()
+	meta, data = img.Encode()
+	if meta.Format != texture.Luminance16F {
+		t.Fatalf("expected format to be %d; got %d", texture.Luminance16F, meta.Format)
+	}
+	if len(data) != 16 {
+		t.Fatalf("expected encoded data len to be 16; got %d", len(data))
+	}
+
+	// Decoding the encoded half texture should recover the base level
+	decoded, err := DecodeTextureImage(&texture.Texture{Format: meta.Format, Width: meta.Width, Height: meta.Height, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, exp := range img.Levels[0].Texels {
+		if got := decoded.Levels[0].Texels[index]; math.Abs(float64(got-exp)) > 1e-3 {
+			t.Errorf("expected decoded texel %d to be %f; got %f", index, exp, got)
+		}
+	}
+}
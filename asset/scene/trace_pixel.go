@@ -0,0 +1,544 @@
+package scene
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The default number of bounces for TracePixel.
+const DefaultTraceMaxBounces = 5
+
+// Options for TracePixel.
+type TraceOptions struct {
+	// The frame dimensions used to map the pixel coordinates to a camera ray.
+	FrameW, FrameH uint32
+
+	// The max number of bounces to trace. If 0, DefaultTraceMaxBounces is used.
+	MaxBounces uint32
+
+	// The seed for the random number generator used for sampling the path.
+	// Tracing the same pixel with the same seed always yields the same path.
+	Seed int64
+
+	// The ray epsilon used for offsetting bounce ray origins. If 0, the
+	// epsilon is derived from the scene units; see Scene.RayEpsilon.
+	RayEpsilon float32
+
+	// The min roughness of conductors encountered after the first diffuse
+	// bounce; see material.RegularizeRoughness. Regularized conductors are
+	// sampled using the GGX distribution. If 0, regularization is disabled.
+	RoughnessClamp float32
+
+	// The max number of glossy bounces along the path and the action taken
+	// for glossy bounces past it; see material.ApplyGlossyBudget. If
+	// MaxGlossyBounces is 0, the glossy bounce budget is disabled.
+	MaxGlossyBounces   uint32
+	GlossyBudgetPolicy material.GlossyBudgetPolicy
+}
+
+// A source of uniformly distributed random samples in the [0, 1) range. A
+// *rand.Rand instance satisfies this interface.
+type Sampler interface {
+	Float32() float32
+	Float64() float64
+}
+
+// A ray with a world space origin and a normalized direction.
+type Ray struct {
+	Origin, Dir types.Vec3
+
+	// The max hit distance along the ray. If 0, the ray is unbounded.
+	MaxDist float32
+}
+
+// A single vertex of a traced path.
+type PathVertex struct {
+	// The ray that reached this vertex.
+	Origin, Dir types.Vec3
+
+	// Set to false if the ray escaped the scene. For escaped rays, only
+	// the ray and the Emission fields are populated.
+	Hit bool
+
+	// The intersection details and the world space hit point, shading
+	// normal, geometric normal and uv coordinates. Unlike the shading
+	// normal, the geometric normal is never flipped to face the ray.
+	RayHit
+	Point           types.Vec3
+	Normal          types.Vec3
+	GeometricNormal types.Vec3
+	UV              types.Vec2
+
+	// The interpolated vertex color for triangle hits. HasVertexColor is
+	// false if the scene does not define vertex colors.
+	VertexColor    types.Vec4
+	HasVertexColor bool
+
+	// The index of the bxdf node that was selected after evaluating the
+	// material tree for the hit primitive and its type. Layered material
+	// coats only reflect light and are reported as conductors.
+	MaterialNodeIndex uint32
+	BxdfType          material.BxdfType
+
+	// The direction that was sampled for the next bounce.
+	SampledDir types.Vec3
+
+	// Set if the ray passed through a semi-transparent surface without
+	// being shaded; see SetOpacity.
+	PassedThrough bool
+
+	// The path throughput before this vertex was shaded.
+	Throughput types.Vec3
+
+	// The radiance emitted by the vertex (or the background for escaped
+	// rays) and its contribution to the pixel after applying throughput.
+	Emission     types.Vec3
+	Contribution types.Vec3
+
+	// The radiance in-scattered by the global fog along the ray that
+	// reached this vertex after applying throughput. Emission and
+	// surface contributions at this vertex are attenuated by the fog.
+	Fog types.Vec3
+}
+
+// The result of TracePixel.
+type PathTrace struct {
+	Vertices []PathVertex
+
+	// The total radiance arriving at the pixel via the traced path.
+	Radiance types.Vec3
+}
+
+// Trace a single primary ray through pixel (x, y) and record each vertex of
+// the resulting path. This is a debugging aid for inspecting why a pixel
+// looks the way it does; it runs on the CPU and is independent of the opencl
+// render loop.
+//
+// TracePixel is a separate, simplified implementation of the integrator and
+// does not track changes to the opencl kernels. Its output may diverge from
+// the rendered image and it must not be used to validate renderer features;
+// tests for those belong to the opencl tracer.
+//
+// The primary ray passes through the pixel center and is generated from the
+// camera frustrum and clipped by the camera clip planes. Paths are shaded
+// using a simplified model of the opencl material sampler: textures are not
+// sampled (the constant node values are used instead), rough bxdfs are
+// treated as their smooth counterparts and paths are only terminated when
+// they escape, hit an emissive surface or exceed the bounce or glossy bounce
+// limits.
+func TracePixel(sc *Scene, cam *Camera, x, y int, opts TraceOptions) PathTrace {
+	return tracePath(sc, cam.ClipRay(cameraRay(cam, x, y, opts.FrameW, opts.FrameH)), opts, rand.New(rand.NewSource(opts.Seed)))
+}
+
+// Scratch space for tracing paths. Reusing the same pathScratch for multiple
+// paths avoids allocating traversal stacks and vertex lists in hot loops. A
+// pathScratch must not be shared between goroutines.
+type pathScratch struct {
+	ray      *RayScratch
+	vertices []PathVertex
+}
+
+// Create a new pathScratch with preallocated traversal stacks and room for
+// the vertices of a path with the default number of bounces.
+func newPathScratch() *pathScratch {
+	return &pathScratch{
+		ray:      NewRayScratch(),
+		vertices: make([]PathVertex, 0, DefaultTraceMaxBounces+1),
+	}
+}
+
+// Trace a path starting with the supplied ray and record each vertex of the
+// path. The max distance of the ray only applies to the first bounce. Random
+// samples for shading the path are drawn from rng.
+func tracePath(sc *Scene, ray Ray, opts TraceOptions, rng Sampler) PathTrace {
+	return tracePathWithScratch(newPathScratch(), sc, ray, opts, rng)
+}
+
+// Trace a path using the traversal stacks and vertex list in the supplied
+// scratch space. The vertices of the returned trace are only valid until
+// the scratch space is reused.
+func tracePathWithScratch(scratch *pathScratch, sc *Scene, ray Ray, opts TraceOptions, rng Sampler) PathTrace {
+	maxBounces := opts.MaxBounces
+	if maxBounces == 0 {
+		maxBounces = DefaultTraceMaxBounces
+	}
+
+	rayEpsilon := opts.RayEpsilon
+	if rayEpsilon == 0 {
+		rayEpsilon = sc.RayEpsilon()
+	}
+
+	throughput := types.Vec3{1, 1, 1}
+	diffuseBounce := false
+	var glossyBounces uint32
+
+	origin, dir, maxDist := ray.Origin, ray.Dir, ray.maxDist()
+	trace := PathTrace{Vertices: scratch.vertices[:0]}
+	for bounce := uint32(0); bounce <= maxBounces; bounce++ {
+		vertex := PathVertex{Origin: origin, Dir: dir, Throughput: throughput}
+
+		hit, found := sc.IntersectWithScratch(scratch.ray, origin, dir, maxDist)
+		maxDist = math.MaxFloat32
+		if !found {
+			vertex.Emission = sc.traceBackground(dir)
+			vertex.Contribution = mulComponents(vertex.Emission, throughput)
+			trace.Radiance = trace.Radiance.Add(vertex.Contribution)
+			trace.Vertices = append(trace.Vertices, vertex)
+			break
+		}
+
+		// Attenuate the path by the fog along the ray and add the
+		// radiance that the fog scatters towards it
+		if sc.GlobalFog.Enabled() {
+			fogTransmittance := sc.GlobalFog.Transmittance(hit.Dist)
+			vertex.Fog = mulComponents(sc.GlobalFog.Color.Mul(1-fogTransmittance), throughput)
+			trace.Radiance = trace.Radiance.Add(vertex.Fog)
+			throughput = throughput.Mul(fogTransmittance)
+			vertex.Throughput = throughput
+		}
+
+		vertex.Hit = true
+		vertex.RayHit = hit
+		vertex.Point = origin.Add(dir.Mul(hit.Dist))
+		matIndex := sc.traceSurface(&vertex)
+		vertex.Normal = ConsistentShadingNormal(vertex.Normal, vertex.GeometricNormal, dir.Mul(-1))
+		if vertex.Normal.Dot(dir) > 0 && !isTransmissive(sc, matIndex) {
+			vertex.Normal = vertex.Normal.Mul(-1)
+		}
+
+		if matIndex < 0 || int(matIndex) >= len(sc.MaterialNodeList) {
+			trace.Vertices = append(trace.Vertices, vertex)
+			break
+		}
+
+		if opacity := sc.MaterialOpacity(uint32(matIndex)); opacity < 1 && PassesThrough(opacity, rng.Float32()) {
+			vertex.PassedThrough = true
+			vertex.SampledDir = dir
+			trace.Vertices = append(trace.Vertices, vertex)
+			origin = vertex.Point.Add(dir.Mul(rayEpsilon * 10))
+			continue
+		}
+
+		var tint types.Vec3
+		var coatSelected bool
+		vertex.MaterialNodeIndex, tint, coatSelected = sc.traceSelectBxdf(uint32(matIndex), vertex.Normal, dir.Mul(-1), rng)
+		node := &sc.MaterialNodeList[vertex.MaterialNodeIndex]
+		vertex.BxdfType = material.BxdfType(node.Union1[0])
+		if coatSelected {
+			vertex.BxdfType = material.BxdfConductor
+		}
+		if vertex.HasVertexColor && node.UsesVertexColors() {
+			colored := *node
+			colored.Union2 = vertex.VertexColor
+			node = &colored
+		}
+
+		if vertex.BxdfType == material.BxdfEmissive {
+			vertex.Emission = node.EmissionTowards(vertex.GeometricNormal, dir.Mul(-1))
+			if bounce > 0 {
+				// The falloff only affects the light cast by the emitter
+				// and not its visibility to the camera
+				vertex.Emission = vertex.Emission.Mul(material.EmissionFalloff(hit.Dist, node.EmissionFalloff()))
+			}
+			vertex.Contribution = mulComponents(vertex.Emission, throughput)
+			trace.Radiance = trace.Radiance.Add(vertex.Contribution)
+			trace.Vertices = append(trace.Vertices, vertex)
+			break
+		}
+
+		var withinBudget bool
+		vertex.BxdfType, withinBudget = material.ApplyGlossyBudget(vertex.BxdfType, glossyBounces, opts.MaxGlossyBounces, opts.GlossyBudgetPolicy)
+		if !withinBudget {
+			trace.Vertices = append(trace.Vertices, vertex)
+			break
+		}
+		if material.IsGlossyBxdf(vertex.BxdfType) {
+			glossyBounces++
+		}
+
+		var weight types.Vec3
+		if roughness, regularize := traceRegularizedRoughness(node, vertex.BxdfType, diffuseBounce, opts.RoughnessClamp); regularize {
+			vertex.BxdfType = material.BxdfRoughtConductor
+			vertex.SampledDir, weight = traceSampleGGX(node, roughness, vertex.Normal, dir, rng)
+		} else {
+			vertex.SampledDir, weight = traceSampleBxdf(node, vertex.BxdfType, vertex.Normal, dir, hit.Dist, rng)
+		}
+		diffuseBounce = diffuseBounce || vertex.BxdfType == material.BxdfDiffuse || vertex.BxdfType == material.BxdfSubsurface
+		trace.Vertices = append(trace.Vertices, vertex)
+		if weight.Len() == 0 {
+			break
+		}
+
+		throughput = mulComponents(throughput, mulComponents(weight, tint))
+		origin = vertex.Point.Add(vertex.SampledDir.Mul(rayEpsilon * 10))
+		dir = vertex.SampledDir
+	}
+
+	scratch.vertices = trace.Vertices
+	return trace
+}
+
+// Generate a ray through the center of pixel (x, y) by interpolating the
+// camera frustrum corner rays.
+func cameraRay(cam *Camera, x, y int, frameW, frameH uint32) (types.Vec3, types.Vec3) {
+	return cameraRayThrough(cam, float32(x)+0.5, float32(y)+0.5, frameW, frameH)
+}
+
+// Generate a ray through the point (px, py) expressed in frame pixel
+// coordinates.
+func cameraRayThrough(cam *Camera, px, py float32, frameW, frameH uint32) (types.Vec3, types.Vec3) {
+	tx := px / float32(frameW)
+	ty := py / float32(frameH)
+
+	lerp := func(a, b types.Vec3, t float32) types.Vec3 {
+		return a.Mul(1 - t).Add(b.Mul(t))
+	}
+	left := lerp(cam.Frustrum[0].Vec3(), cam.Frustrum[2].Vec3(), ty)
+	right := lerp(cam.Frustrum[1].Vec3(), cam.Frustrum[3].Vec3(), ty)
+	return cam.Position, lerp(left, right, tx).Normalize()
+}
+
+// Get the radiance of the scene background towards dir. Directions below the
+// horizon return the ground color if ground projection is enabled.
+func (sc *Scene) traceBackground(dir types.Vec3) types.Vec3 {
+	if sc.GroundProjection.Occludes(dir) {
+		return sc.GroundProjection.Color
+	}
+	if sc.SceneDiffuseMatIndex < 0 || int(sc.SceneDiffuseMatIndex) >= len(sc.MaterialNodeList) {
+		return types.Vec3{}
+	}
+	return sc.MaterialNodeList[sc.SceneDiffuseMatIndex].Union2.Vec3()
+}
+
+// Populate the world space shading normal and uv coordinates of a path
+// vertex and return the material index for the hit primitive.
+func (sc *Scene) traceSurface(vertex *PathVertex) int32 {
+	mi := &sc.MeshInstanceList[vertex.MeshInstance]
+	meshPoint := mi.Transform.Mul4x1(vertex.Point.Vec4(1)).Vec3()
+
+	var normal, geomNormal types.Vec3
+	var matIndex int32
+	if vertex.Quad {
+		q := &sc.QuadList[vertex.PrimitiveIndex]
+		normal = q.Normal()
+		geomNormal = normal
+		if _, s, r, hit := q.intersect(meshPoint.Add(normal), normal.Mul(-1)); hit {
+			vertex.UV = q.interpolateUV(s, r)
+		}
+		matIndex = int32(q.MaterialIndex)
+	} else {
+		offset := 3 * vertex.PrimitiveIndex
+		v0 := sc.VertexList[offset].Vec3()
+		edge01 := sc.VertexList[offset+1].Vec3().Sub(v0)
+		edge02 := sc.VertexList[offset+2].Vec3().Sub(v0)
+		normal = edge01.Cross(edge02)
+		geomNormal = normal
+
+		bary := barycentric(meshPoint.Sub(v0), edge01, edge02)
+		if int(offset+2) < len(sc.NormalList) {
+			var interpolated types.Vec3
+			for i := uint32(0); i < 3; i++ {
+				interpolated = interpolated.Add(sc.NormalList[offset+i].Vec3().Mul(bary[i]))
+			}
+			if interpolated.Len() > 0 {
+				normal = interpolated
+			}
+		}
+		if int(offset+2) < len(sc.UvList) {
+			for i := uint32(0); i < 3; i++ {
+				vertex.UV[0] += sc.UvList[offset+i][0] * bary[i]
+				vertex.UV[1] += sc.UvList[offset+i][1] * bary[i]
+			}
+		}
+		vertex.VertexColor, vertex.HasVertexColor = sc.VertexColor(vertex.PrimitiveIndex, bary)
+
+		matIndex = -1
+		if int(vertex.PrimitiveIndex) < len(sc.MaterialIndex) {
+			matIndex = int32(sc.MaterialIndex[vertex.PrimitiveIndex])
+		}
+	}
+
+	// Normals are transformed by the transpose of the world-to-mesh matrix
+	for axis := 0; axis < 3; axis++ {
+		vertex.Normal[axis] = mi.Transform.Col(axis).Vec3().Dot(normal)
+		vertex.GeometricNormal[axis] = mi.Transform.Col(axis).Vec3().Dot(geomNormal)
+	}
+	vertex.Normal = vertex.Normal.Normalize()
+	vertex.GeometricNormal = vertex.GeometricNormal.Normalize()
+	return matIndex
+}
+
+// Calculate the barycentric coordinates of a point p (relative to v0) inside
+// the triangle (v0, v0+edge01, v0+edge02).
+func barycentric(p, edge01, edge02 types.Vec3) [3]float32 {
+	d00, d01, d11 := edge01.Dot(edge01), edge01.Dot(edge02), edge02.Dot(edge02)
+	d20, d21 := p.Dot(edge01), p.Dot(edge02)
+	denom := d00*d11 - d01*d01
+	if denom == 0 {
+		return [3]float32{1, 0, 0}
+	}
+	v := (d11*d20 - d01*d21) / denom
+	w := (d00*d21 - d01*d20) / denom
+	return [3]float32{1 - v - w, v, w}
+}
+
+// Check whether any bxdf in a material tree can transmit light. The normals
+// of transmissive surfaces are not flipped so that the sampler can detect
+// whether the ray enters or exits the surface.
+func isTransmissive(sc *Scene, matIndex int32) bool {
+	if matIndex < 0 || int(matIndex) >= len(sc.MaterialNodeList) {
+		return false
+	}
+	node := &sc.MaterialNodeList[matIndex]
+	nodeType := uint32(node.Union1[0])
+	if material.IsBxdfType(nodeType) {
+		return nodeType == uint32(material.BxdfDielectric) || nodeType == uint32(material.BxdfRoughDielectric) || nodeType == uint32(material.BxdfSubsurface)
+	}
+	if nodeType == uint32(material.OpLayered) {
+		// The coat of layered materials only reflects light
+		return isTransmissive(sc, node.Union1[2])
+	}
+	if isTransmissive(sc, node.Union1[1]) {
+		return true
+	}
+	return (nodeType == uint32(material.OpMix) || nodeType == uint32(material.OpMixMap)) && isTransmissive(sc, node.Union1[2])
+}
+
+// Walk the material tree starting at nodeIndex and return the index of the
+// selected bxdf node. Like the opencl material sampler, mix nodes select a
+// child by comparing a random sample against the mix weight. As textures are
+// not sampled, mix map nodes assume a weight of 0.5 and bump, normal map and
+// dispersion nodes simply follow their left child.
+//
+// Layered nodes select their coat with a probability equal to the coat fresnel
+// reflectance for outDir. The returned tint accumulates the coat transmission
+// for paths that select the base layer while the returned flag indicates
+// whether a coat was selected and should be shaded as a mirror.
+func (sc *Scene) traceSelectBxdf(nodeIndex uint32, normal, outDir types.Vec3, rng Sampler) (uint32, types.Vec3, bool) {
+	tint := types.Vec3{1, 1, 1}
+	coatSelected := false
+	for {
+		node := &sc.MaterialNodeList[nodeIndex]
+		switch material.OpType(node.Union1[0]) {
+		case material.OpMix:
+			if rng.Float32() < node.Union2[0] {
+				nodeIndex = uint32(node.Union1[1])
+			} else {
+				nodeIndex = uint32(node.Union1[2])
+			}
+		case material.OpMixMap:
+			if rng.Float32() < 0.5 {
+				nodeIndex = uint32(node.Union1[1])
+			} else {
+				nodeIndex = uint32(node.Union1[2])
+			}
+		case material.OpBumpMap, material.OpNormalMap, material.OpDisperse:
+			nodeIndex = uint32(node.Union1[1])
+		case material.OpLayered:
+			cosI := float32(math.Abs(float64(outDir.Dot(normal))))
+			if rng.Float32() < material.FresnelDielectric(node.Union4[1], node.Union4[0], cosI) {
+				coatSelected = true
+				nodeIndex = uint32(node.Union1[1])
+			} else {
+				transmission := material.LayerTransmission(node.Union2.Vec3(), node.Union2[3], node.Union4[0], node.Union4[1], node.Union3[0], node.Union3[1], cosI)
+				tint = mulComponents(tint, transmission)
+				nodeIndex = uint32(node.Union1[2])
+			}
+		default:
+			return nodeIndex, tint, coatSelected
+		}
+	}
+}
+
+// Sample an outgoing direction for a bxdf node and return it together with
+// the throughput weight (bxdf * cos / pdf) for the sampled direction. The
+// distance travelled by the incoming ray is used by subsurface bxdfs.
+func traceSampleBxdf(node *MaterialNode, bxdfType material.BxdfType, normal, inDir types.Vec3, hitDist float32, rng Sampler) (types.Vec3, types.Vec3) {
+	switch bxdfType {
+	case material.BxdfDiffuse:
+		// Cosine weighted hemisphere sample; the cos/pdf terms cancel out
+		return cosWeightedSample(normal, rng), node.Union2.Vec3()
+	case material.BxdfSubsurface:
+		// Rays exiting the medium are attenuated by the diffusion profile
+		// while entering rays are reflected or transmitted with equal
+		// probability; see subsurface.cl
+		if inDir.Dot(normal) > 0 {
+			return cosWeightedSample(normal, rng), material.SubsurfaceTransmission(node.Union2.Vec3(), node.Union4[2], hitDist)
+		}
+		if rng.Float32() < 0.5 {
+			return cosWeightedSample(normal, rng), node.Union2.Vec3()
+		}
+		return cosWeightedSample(normal.Mul(-1), rng), node.Union2.Vec3()
+	case material.BxdfConductor, material.BxdfRoughtConductor:
+		return reflectDir(inDir, normal), node.Union2.Vec3()
+	case material.BxdfDielectric, material.BxdfRoughDielectric:
+		outDir := inDir.Mul(-1)
+		intIOR, extIOR := node.Union4[0], node.Union4[1]
+		etaI, etaT := extIOR, intIOR
+		if outDir.Dot(normal) < 0 {
+			etaI, etaT = etaT, etaI
+		}
+		if rng.Float32() < material.FresnelDielectric(etaI, etaT, outDir.Dot(normal)) {
+			return reflectDir(inDir, normal), node.Union2.Vec3()
+		}
+		refracted, ok := material.Refract(outDir, normal, intIOR, extIOR)
+		if !ok {
+			return reflectDir(inDir, normal), node.Union2.Vec3()
+		}
+		return refracted.Normalize(), node.Union3.Vec3()
+	}
+	return types.Vec3{}, types.Vec3{}
+}
+
+// Get the regularized roughness for a conductor that is encountered after a
+// diffuse bounce. The returned flag is false if the bxdf is not regularized.
+func traceRegularizedRoughness(node *MaterialNode, bxdfType material.BxdfType, diffuseBounce bool, minRoughness float32) (float32, bool) {
+	if !diffuseBounce || minRoughness <= 0 {
+		return 0, false
+	}
+	if bxdfType != material.BxdfConductor && bxdfType != material.BxdfRoughtConductor {
+		return 0, false
+	}
+	_, roughness := material.RegularizeRoughness(bxdfType, node.Union4[2], minRoughness)
+	return roughness, true
+}
+
+// Sample a reflection off a rough conductor by sampling a GGX microfacet
+// normal and return the reflected direction and its throughput weight. The
+// weight is 0 if the reflected direction falls below the surface.
+func traceSampleGGX(node *MaterialNode, roughness float32, normal, inDir types.Vec3, rng Sampler) (types.Vec3, types.Vec3) {
+	alpha := material.GGXAlpha(roughness)
+	m := material.GGXSample(alpha, normal, types.Vec2{rng.Float32(), rng.Float32()})
+	outDir := reflectDir(inDir, m)
+	iDotN, oDotN := -inDir.Dot(normal), outDir.Dot(normal)
+	if iDotN <= 0 || oDotN <= 0 {
+		return outDir, types.Vec3{}
+	}
+
+	// weight = bxdf * cos / pdf = G * |o.m| / (|i.n| * |m.n|)
+	g := material.GGXG(alpha, inDir.Mul(-1), outDir, normal, m)
+	return outDir, node.Union2.Vec3().Mul(g * outDir.Dot(m) / (iDotN * m.Dot(normal)))
+}
+
+// Generate a cosine weighted direction in the hemisphere around normal.
+func cosWeightedSample(normal types.Vec3, rng Sampler) types.Vec3 {
+	tangent, bitangent := types.BuildOrthonormalBasis(normal)
+	r := float32(math.Sqrt(rng.Float64()))
+	phi := 2 * math.Pi * rng.Float64()
+	x, y := r*float32(math.Cos(phi)), r*float32(math.Sin(phi))
+	z := float32(math.Sqrt(math.Max(0, float64(1-x*x-y*y))))
+	return tangent.Mul(x).Add(bitangent.Mul(y)).Add(normal.Mul(z))
+}
+
+// Reflect an incoming ray direction around a normal.
+func reflectDir(inDir, normal types.Vec3) types.Vec3 {
+	return inDir.Sub(normal.Mul(2 * inDir.Dot(normal)))
+}
+
+// Multiply two vectors component-wise.
+func mulComponents(a, b types.Vec3) types.Vec3 {
+	return types.Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
@@ -0,0 +1,112 @@
+package scene
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestTracePixel(t *testing.T) {
+	sc := makePlaneTestScene(4)
+	sc.MaterialNodeList = []MaterialNode{
+		{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{0.8, 0.8, 0.8, 0}},
+		{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{0.5, 0.6, 0.7, 0}},
+	}
+	sc.MaterialIndex = make([]uint32, len(sc.VertexList)/3)
+	sc.SceneDiffuseMatIndex = 1
+
+	cam := NewCamera(45)
+	cam.Position = types.Vec3{0, 0, 5}
+	cam.LookAt = types.Vec3{0, 0, 0}
+	cam.SetupProjection(1)
+
+	opts := TraceOptions{FrameW: 64, FrameH: 64, Seed: 42}
+
+	// The center pixel hits the plane and bounces off to the background
+	trace := TracePixel(sc, cam, 32, 32, opts)
+	if len(trace.Vertices) != 2 {
+		t.Fatalf("expected path with 2 vertices; got %d", len(trace.Vertices))
+	}
+	first := trace.Vertices[0]
+	if !first.Hit || first.MaterialNodeIndex != 0 || first.BxdfType != material.BxdfDiffuse {
+		t.Fatalf("expected first vertex to hit the diffuse plane; got %+v", first)
+	}
+	if first.Point[2] < -1e-4 || first.Point[2] > 1e-4 || first.Point.Sub(types.Vec3{}).Len() > 0.1 {
+		t.Fatalf("expected first hit point to be close to the origin; got %v", first.Point)
+	}
+	if first.Normal.Dot(types.Vec3{0, 0, 1}) < 0.999 {
+		t.Fatalf("expected first hit normal to face the camera; got %v", first.Normal)
+	}
+	if first.SampledDir[2] <= 0 {
+		t.Fatalf("expected sampled direction to point away from the plane; got %v", first.SampledDir)
+	}
+	expRadiance := types.Vec3{0.5 * 0.8, 0.6 * 0.8, 0.7 * 0.8}
+	if trace.Vertices[1].Hit || trace.Radiance.Sub(expRadiance).Len() > 1e-4 {
+		t.Fatalf("expected bounce to escape with radiance %v; got %v", expRadiance, trace.Radiance)
+	}
+
+	// Tracing with the same seed yields the same path
+	if again := TracePixel(sc, cam, 32, 32, opts); !reflect.DeepEqual(trace, again) {
+		t.Fatal("expected tracing the same pixel with the same seed to yield the same path")
+	}
+
+	// The corner pixel misses the plane
+	trace = TracePixel(sc, cam, 0, 0, opts)
+	if len(trace.Vertices) != 1 || trace.Vertices[0].Hit {
+		t.Fatalf("expected corner pixel ray to miss the plane; got %+v", trace.Vertices)
+	}
+}
+
+// Create a scene with two large mirrors facing each other across the z = 0 and
+// z = 2 planes and a camera looking down between them at a slight angle.
+func makeMirrorHallTestScene() (*Scene, *Camera) {
+	sc := &Scene{SceneDiffuseMatIndex: 1, SceneEmissiveMatIndex: -1}
+	for _, z := range []float32{0, 2} {
+		v := [4]types.Vec4{{-100, -100, z, 1}, {100, -100, z, 1}, {100, 100, z, 1}, {-100, 100, z, 1}}
+		if z > 0 {
+			v[1], v[3] = v[3], v[1]
+		}
+		sc.VertexList = append(sc.VertexList, v[0], v[1], v[2], v[0], v[2], v[3])
+	}
+	sc.MaterialIndex = make([]uint32, len(sc.VertexList)/3)
+	sc.MaterialNodeList = []MaterialNode{
+		{Union1: [4]int32{int32(material.BxdfConductor), -1, -1, -1}, Union2: types.Vec4{0.9, 0.9, 0.9, 0}},
+		{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{1, 1, 1, 0}},
+	}
+	sc.BvhNodeList = make([]BvhNode, 1)
+	root := buildTestMeshBvh(sc, 0, uint32(len(sc.VertexList)/3))
+	sc.MeshInstanceList = []MeshInstance{{MeshIndex: 0, BvhRoot: root, Transform: types.Ident4()}}
+	sc.BvhNodeList[0].SetBBox([2]types.Vec3{sc.BvhNodeList[root].Min, sc.BvhNodeList[root].Max})
+	sc.BvhNodeList[0].SetMeshIndex(0)
+
+	cam := NewCamera(45)
+	cam.Position = types.Vec3{0, 0, 1}
+	cam.LookAt = types.Vec3{0.2, 0, 0}
+	cam.SetupProjection(1)
+	return sc, cam
+}
+
+func TestTracePathAllocations(t *testing.T) {
+	sc, cam := makeMirrorHallTestScene()
+	opts := TraceOptions{FrameW: 8, FrameH: 8, MaxBounces: 16, Seed: 1}
+	scratch := newPathScratch()
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	traceFrame := func() {
+		for y := 0; y < int(opts.FrameH); y++ {
+			for x := 0; x < int(opts.FrameW); x++ {
+				tracePathWithScratch(scratch, sc, cam.ClipRay(cameraRay(cam, x, y, opts.FrameW, opts.FrameH)), opts, rng)
+			}
+		}
+	}
+
+	// Warm up the scratch buffers
+	traceFrame()
+
+	if allocs := testing.AllocsPerRun(10, traceFrame); allocs != 0 {
+		t.Fatalf("expected tracing a path to perform 0 allocations; got %f allocations per frame", allocs)
+	}
+}
@@ -0,0 +1,26 @@
+package scene
+
+// The ray epsilon for scenes modelled in meters. It matches the
+// INTERSECTION_EPSILON constant used by the opencl kernels.
+const DefaultRayEpsilon float32 = 1e-5
+
+// Get the number of scene units per meter. Scenes that do not specify a
+// unit scale are assumed to be modelled in meters.
+func (sc *Scene) unitScale() float32 {
+	if sc.UnitsPerMeter <= 0 {
+		return 1
+	}
+	return sc.UnitsPerMeter
+}
+
+// Get the distance (in scene units) by which secondary ray origins are
+// offset from the surface they leave to avoid self-intersections. The
+// epsilon is derived by scaling DefaultRayEpsilon by the scene unit scale so
+// that scenes modelled in e.g. millimeters do not suffer from shadow acne.
+//
+// The opencl tracer also uses this value to derive the distance by which
+// occlusion rays are clipped before reaching a light sample. Renderers may
+// override it via the BlockRequest RayEpsilon field.
+func (sc *Scene) RayEpsilon() float32 {
+	return DefaultRayEpsilon * sc.unitScale()
+}
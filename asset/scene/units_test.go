@@ -0,0 +1,29 @@
+package scene
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRayEpsilon(t *testing.T) {
+	type spec struct {
+		unitsPerMeter float32
+		expEpsilon    float32
+	}
+	specs := []spec{
+		// Scenes default to meters
+		spec{0, DefaultRayEpsilon},
+		spec{1, DefaultRayEpsilon},
+		// Millimeters
+		spec{1000, DefaultRayEpsilon * 1000},
+		// Kilometers
+		spec{0.001, DefaultRayEpsilon * 0.001},
+	}
+
+	for index, s := range specs {
+		sc := &Scene{UnitsPerMeter: s.unitsPerMeter}
+		if got := sc.RayEpsilon(); math.Abs(float64(got-s.expEpsilon)) > 1e-12 {
+			t.Errorf("[spec %d] expected ray epsilon for %f units per meter to be %g; got %g", index, s.unitsPerMeter, s.expEpsilon, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A texture coordinate transform for a material. Scaling and rotation are
+// applied around the texture center (0.5, 0.5) followed by the offset. A
+// zero scale component is treated as 1 so the zero value is the identity
+// transform.
+type UVTransform struct {
+	Scale  types.Vec2
+	Offset types.Vec2
+
+	// Counter-clockwise rotation in radians.
+	Rotation float32
+}
+
+// Get the transform as a 2x3 affine matrix stored as two rows. The w
+// component of each row is unused.
+func (t UVTransform) Matrix() [2]types.Vec4 {
+	scale := t.Scale
+	for axis := 0; axis < 2; axis++ {
+		if scale[axis] == 0 {
+			scale[axis] = 1
+		}
+	}
+
+	sin, cos := math.Sincos(float64(t.Rotation))
+	a, b := float32(cos)*scale[0], -float32(sin)*scale[1]
+	c, d := float32(sin)*scale[0], float32(cos)*scale[1]
+
+	// uv' = M * (uv - center) + center + offset
+	return [2]types.Vec4{
+		{a, b, 0.5 - 0.5*(a+b) + t.Offset[0], 0},
+		{c, d, 0.5 - 0.5*(c+d) + t.Offset[1], 0},
+	}
+}
+
+// Apply the transform to a set of uv coordinates.
+func (t UVTransform) Apply(uv types.Vec2) types.Vec2 {
+	return applyUVMatrix(t.Matrix(), uv)
+}
+
+// Set the uv transform for the material with the specified root node index.
+func (sc *Scene) SetUVTransform(matNodeIndex uint32, t UVTransform) error {
+	if int(matNodeIndex) >= len(sc.MaterialNodeList) {
+		return fmt.Errorf("scene: invalid material node index %d", matNodeIndex)
+	}
+
+	if int(matNodeIndex) >= len(sc.UVTransforms) {
+		sc.UVTransforms = append(sc.UVTransforms, make([]UVTransform, int(matNodeIndex)+1-len(sc.UVTransforms))...)
+	}
+	sc.UVTransforms[matNodeIndex] = t
+	return nil
+}
+
+// Transform uv coordinates using the uv transform of the material with the
+// specified root node index.
+func (sc *Scene) MaterialUV(matNodeIndex uint32, uv types.Vec2) types.Vec2 {
+	if int(matNodeIndex) >= len(sc.UVTransforms) {
+		return uv
+	}
+	return sc.UVTransforms[matNodeIndex].Apply(uv)
+}
+
+// Get the uv transform matrices for all material nodes in the layout used
+// by the opencl kernels (two rows per node). Returns nil if the scene does
+// not define any uv transforms.
+func (sc *Scene) UVTransformMatrices() []types.Vec4 {
+	if len(sc.UVTransforms) == 0 {
+		return nil
+	}
+
+	matrices := make([]types.Vec4, 0, 2*len(sc.MaterialNodeList))
+	for index := range sc.MaterialNodeList {
+		var t UVTransform
+		if index < len(sc.UVTransforms) {
+			t = sc.UVTransforms[index]
+		}
+		m := t.Matrix()
+		matrices = append(matrices, m[0], m[1])
+	}
+	return matrices
+}
+
+func applyUVMatrix(m [2]types.Vec4, uv types.Vec2) types.Vec2 {
+	return types.Vec2{
+		m[0][0]*uv[0] + m[0][1]*uv[1] + m[0][2],
+		m[1][0]*uv[0] + m[1][1]*uv[1] + m[1][2],
+	}
+}
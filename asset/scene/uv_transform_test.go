@@ -0,0 +1,80 @@
+package scene
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestUVTransformTiling(t *testing.T) {
+	type spec struct {
+		transform    UVTransform
+		expCrossings int
+	}
+	specs := []spec{
+		// Default (identity) transform
+		spec{UVTransform{}, 1},
+		spec{UVTransform{Scale: types.Vec2{2, 2}}, 2},
+		spec{UVTransform{Scale: types.Vec2{3, 1}, Offset: types.Vec2{0.1, 0}}, 3},
+		// The texture varies along v after a 90 degree rotation
+		spec{UVTransform{Scale: types.Vec2{2, 2}, Rotation: 0.5 * math.Pi}, 0},
+	}
+
+	for index, s := range specs {
+		sc := &Scene{MaterialNodeList: make([]MaterialNode, 1)}
+		if err := sc.SetUVTransform(0, s.transform); err != nil {
+			t.Fatal(err)
+		}
+
+		// Walk across the quad along u and count dark to bright transitions
+		const steps = 64
+		var crossings int
+		var prevBright bool
+		for step := 0; step <= steps; step++ {
+			uv := sc.MaterialUV(0, types.Vec2{float32(step) / steps, 0.5})
+
+			// Sample a wrapping texture with a dark left half and a
+			// bright right half
+			bright := uv[0]-float32(math.Floor(float64(uv[0]))) >= 0.5
+			if step > 0 && bright && !prevBright {
+				crossings++
+			}
+			prevBright = bright
+		}
+
+		if crossings != s.expCrossings {
+			t.Errorf("[spec %d] expected texture to tile %d times across the quad; got %d", index, s.expCrossings, crossings)
+		}
+	}
+}
+
+func TestUVTransformDefaults(t *testing.T) {
+	sc := &Scene{MaterialNodeList: make([]MaterialNode, 3)}
+
+	uv := types.Vec2{0.25, 0.75}
+	if got := sc.MaterialUV(1, uv); got != uv {
+		t.Fatalf("expected materials without a uv transform to use the identity transform; got %v", got)
+	}
+	if sc.UVTransformMatrices() != nil {
+		t.Fatal("expected no uv transform matrices for a scene without uv transforms")
+	}
+
+	if err := sc.SetUVTransform(3, UVTransform{}); err == nil {
+		t.Fatal("expected an error when setting a uv transform for an invalid material node")
+	}
+
+	if err := sc.SetUVTransform(1, UVTransform{Offset: types.Vec2{0.5, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	matrices := sc.UVTransformMatrices()
+	if len(matrices) != 2*len(sc.MaterialNodeList) {
+		t.Fatalf("expected %d uv transform matrix rows; got %d", 2*len(sc.MaterialNodeList), len(matrices))
+	}
+	if got := applyUVMatrix([2]types.Vec4{matrices[0], matrices[1]}, uv); got != uv {
+		t.Fatalf("expected material 0 to use the identity transform; got %v", got)
+	}
+	if got := applyUVMatrix([2]types.Vec4{matrices[2], matrices[3]}, uv); got != (types.Vec2{0.75, 0.75}) {
+		t.Fatalf("expected material 1 uv to be offset; got %v", got)
+	}
+}
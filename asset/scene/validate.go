@@ -0,0 +1,117 @@
+package scene
+
+import "fmt"
+
+// Check the scene for inconsistencies between the geometry lists and the
+// per-triangle material indices. Mismatches between these lists are usually
+// caused by importer bugs and would otherwise silently corrupt shading.
+func (sc *Scene) Validate() error {
+	numTris, err := sc.triangleCount()
+	if err != nil {
+		return err
+	}
+
+	if len(sc.MaterialIndex) != numTris {
+		return fmt.Errorf("scene: material index count (%d) does not match triangle count (%d)", len(sc.MaterialIndex), numTris)
+	}
+
+	if len(sc.VertexColorList) != 0 && len(sc.VertexColorList) != len(sc.VertexList) {
+		return fmt.Errorf("scene: vertex color count (%d) does not match vertex count (%d)", len(sc.VertexColorList), len(sc.VertexList))
+	}
+
+	if len(sc.UVTransforms) > len(sc.MaterialNodeList) {
+		return fmt.Errorf("scene: uv transform count (%d) exceeds material node count (%d)", len(sc.UVTransforms), len(sc.MaterialNodeList))
+	}
+
+	if len(sc.Opacities) > len(sc.MaterialNodeList) {
+		return fmt.Errorf("scene: opacity count (%d) exceeds material node count (%d)", len(sc.Opacities), len(sc.MaterialNodeList))
+	}
+	for matNodeIndex, opacity := range sc.Opacities {
+		if err := checkOpacity(opacity); err != nil {
+			return fmt.Errorf("%s (material node %d)", err.Error(), matNodeIndex)
+		}
+	}
+
+	for index := range sc.LightLinks {
+		if err := sc.checkLightLink(&sc.LightLinks[index]); err != nil {
+			return err
+		}
+	}
+
+	if len(sc.RenderLayers) > MaxRenderLayers {
+		return fmt.Errorf("scene: render layer count (%d) exceeds the max supported count (%d)", len(sc.RenderLayers), MaxRenderLayers)
+	}
+	for index := range sc.RenderLayers {
+		if err := sc.checkRenderLayer(&sc.RenderLayers[index]); err != nil {
+			return err
+		}
+	}
+
+	if err := sc.GlobalFog.Validate(); err != nil {
+		return err
+	}
+
+	if sc.Camera != nil {
+		if err := sc.Camera.ValidateClip(); err != nil {
+			return err
+		}
+	}
+
+	if len(sc.InstanceKeyframes) > len(sc.MeshInstanceList) {
+		return fmt.Errorf("scene: keyframe list count (%d) exceeds mesh instance count (%d)", len(sc.InstanceKeyframes), len(sc.MeshInstanceList))
+	}
+	for instanceIndex, keys := range sc.InstanceKeyframes {
+		if err := checkKeyframes(keys); err != nil {
+			return fmt.Errorf("%s (mesh instance %d)", err.Error(), instanceIndex)
+		}
+	}
+
+	for triIndex, matIndex := range sc.MaterialIndex {
+		if err := sc.checkMaterialNodeIndex(matIndex); err != nil {
+			return fmt.Errorf("%s (triangle %d)", err.Error(), triIndex)
+		}
+	}
+
+	return nil
+}
+
+// Ensure that each triangle has an associated material index by assigning
+// defaultMat to any triangles that lack one. An error is returned if the scene
+// defines more material indices than triangles as there is no way to tell
+// which of the indices are stale.
+func (sc *Scene) EnsureMaterialIndices(defaultMat uint32) error {
+	numTris, err := sc.triangleCount()
+	if err != nil {
+		return err
+	}
+
+	if len(sc.MaterialIndex) > numTris {
+		return fmt.Errorf("scene: material index count (%d) exceeds triangle count (%d)", len(sc.MaterialIndex), numTris)
+	}
+
+	if err = sc.checkMaterialNodeIndex(defaultMat); err != nil {
+		return err
+	}
+
+	for len(sc.MaterialIndex) < numTris {
+		sc.MaterialIndex = append(sc.MaterialIndex, defaultMat)
+	}
+
+	return nil
+}
+
+// Get the number of triangles defined by the scene vertex list.
+func (sc *Scene) triangleCount() (int, error) {
+	if len(sc.VertexList)%3 != 0 {
+		return 0, fmt.Errorf("scene: vertex count (%d) is not a multiple of 3", len(sc.VertexList))
+	}
+	return len(sc.VertexList) / 3, nil
+}
+
+// Check that a material index points to a valid material node.
+func (sc *Scene) checkMaterialNodeIndex(matIndex uint32) error {
+	if int(matIndex) >= len(sc.MaterialNodeList) {
+		return fmt.Errorf("scene: material index %d out of range [0, %d)", matIndex, len(sc.MaterialNodeList))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestEnsureMaterialIndices(t *testing.T) {
+	sc := &Scene{
+		VertexList:       make([]types.Vec4, 12),
+		MaterialNodeList: make([]MaterialNode, 3),
+		MaterialIndex:    []uint32{1, 2},
+	}
+
+	if err := sc.Validate(); err == nil {
+		t.Fatal("expected Validate to fail for scene with missing material indices")
+	}
+
+	if err := sc.EnsureMaterialIndices(0); err != nil {
+		t.Fatal(err)
+	}
+
+	expIndices := []uint32{1, 2, 0, 0}
+	if len(sc.MaterialIndex) != len(expIndices) {
+		t.Fatalf("expected material index count to be %d; got %d", len(expIndices), len(sc.MaterialIndex))
+	}
+	for index, exp := range expIndices {
+		if sc.MaterialIndex[index] != exp {
+			t.Errorf("expected material index %d to be %d; got %d", index, exp, sc.MaterialIndex[index])
+		}
+	}
+
+	if err := sc.Validate(); err != nil {
+		t.Fatalf("expected Validate to succeed after auto-fill; got %v", err)
+	}
+}
+
+func TestMaterialIndexErrors(t *testing.T) {
+	type spec struct {
+		sc         *Scene
+		defaultMat uint32
+		expError   string
+	}
+	specs := []spec{
+		spec{
+			&Scene{VertexList: make([]types.Vec4, 4), MaterialNodeList: make([]MaterialNode, 1)},
+			0,
+			"scene: vertex count (4) is not a multiple of 3",
+		},
+		spec{
+			&Scene{VertexList: make([]types.Vec4, 3), MaterialNodeList: make([]MaterialNode, 1), MaterialIndex: []uint32{0, 0}},
+			0,
+			"scene: material index count (2) exceeds triangle count (1)",
+		},
+		spec{
+			&Scene{VertexList: make([]types.Vec4, 3), MaterialNodeList: make([]MaterialNode, 1)},
+			1,
+			"scene: material index 1 out of range [0, 1)",
+		},
+	}
+
+	for index, s := range specs {
+		err := s.sc.EnsureMaterialIndices(s.defaultMat)
+		if err == nil || err.Error() != s.expError {
+			t.Errorf("[spec %d] expected error %q; got %v", index, s.expError, err)
+		}
+	}
+
+	sc := &Scene{VertexList: make([]types.Vec4, 3), MaterialNodeList: make([]MaterialNode, 1), MaterialIndex: []uint32{4}}
+	expError := "scene: material index 4 out of range [0, 1) (triangle 0)"
+	if err := sc.Validate(); err == nil || err.Error() != expError {
+		t.Fatalf("expected error %q; got %v", expError, err)
+	}
+}
@@ -0,0 +1,30 @@
+package scene
+
+import (
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+// Interpolate the vertex colors of a triangle at the point with the supplied
+// barycentric coordinates. The returned flag is false if the scene does not
+// define vertex colors for the triangle.
+func (sc *Scene) VertexColor(primIndex uint32, bary [3]float32) (types.Vec4, bool) {
+	offset := 3 * int(primIndex)
+	if offset+2 >= len(sc.VertexColorList) {
+		return types.Vec4{}, false
+	}
+
+	var color types.Vec4
+	for i := 0; i < 3; i++ {
+		for c := 0; c < 4; c++ {
+			color[c] += sc.VertexColorList[offset+i][c] * bary[i]
+		}
+	}
+	return color, true
+}
+
+// Check whether the node is a diffuse bxdf that uses the interpolated vertex
+// color as its reflectance.
+func (n *MaterialNode) UsesVertexColors() bool {
+	return n.Union1[0] == int32(material.BxdfDiffuse) && n.Union5[0] == 1
+}
@@ -0,0 +1,55 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestVertexColor(t *testing.T) {
+	sc := &Scene{
+		VertexList: []types.Vec4{
+			{0, 0, 0, 1},
+			{1, 0, 0, 1},
+			{0, 1, 0, 1},
+		},
+		VertexColorList: []types.Vec4{
+			{1, 0, 0, 1},
+			{0, 1, 0, 1},
+			{0, 0, 1, 1},
+		},
+	}
+
+	third := float32(1.0 / 3.0)
+	type spec struct {
+		bary     [3]float32
+		expColor types.Vec4
+	}
+	specs := []spec{
+		// Corners
+		spec{[3]float32{1, 0, 0}, types.Vec4{1, 0, 0, 1}},
+		spec{[3]float32{0, 1, 0}, types.Vec4{0, 1, 0, 1}},
+		spec{[3]float32{0, 0, 1}, types.Vec4{0, 0, 1, 1}},
+		// Edge midpoint
+		spec{[3]float32{0.5, 0.5, 0}, types.Vec4{0.5, 0.5, 0, 1}},
+		// Triangle center
+		spec{[3]float32{third, third, third}, types.Vec4{third, third, third, 1}},
+	}
+
+	for index, s := range specs {
+		color, ok := sc.VertexColor(0, s.bary)
+		if !ok {
+			t.Errorf("[spec %d] expected triangle to have vertex colors", index)
+			continue
+		}
+		if color.Sub(s.expColor).Len() > 1e-5 {
+			t.Errorf("[spec %d] expected interpolated color to be %v; got %v", index, s.expColor, color)
+		}
+	}
+
+	// Vertex colors are ignored if the list is empty
+	sc.VertexColorList = nil
+	if _, ok := sc.VertexColor(0, [3]float32{third, third, third}); ok {
+		t.Fatal("expected scene without vertex colors to report no vertex color")
+	}
+}
@@ -0,0 +1,20 @@
+package scene
+
+import "github.com/achilleasa/polaris/types"
+
+// Visit each scene mesh instance in MeshInstanceList order together with its
+// mesh-to-world transformation. If fn returns false the traversal stops and
+// no further instances are visited.
+//
+// Instance transforms convert from world to mesh space so the world
+// transform passed to fn is their inverse. Instances are not nested; if
+// instance hierarchies are introduced, the world transform will be the
+// concatenation of the transforms along the instance path.
+func (sc *Scene) WalkInstances(fn func(inst *MeshInstance, worldTransform types.Mat4) bool) {
+	for index := range sc.MeshInstanceList {
+		mi := &sc.MeshInstanceList[index]
+		if !fn(mi, mi.Transform.Inv()) {
+			return
+		}
+	}
+}
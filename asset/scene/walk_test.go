@@ -0,0 +1,50 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestWalkInstances(t *testing.T) {
+	sc := &Scene{}
+	for index := 0; index < 4; index++ {
+		offset := types.Vec3{float32(index), 0, 0}
+		sc.MeshInstanceList = append(sc.MeshInstanceList, MeshInstance{
+			MeshIndex: uint32(index),
+			Transform: types.Translate4(offset).Inv(),
+		})
+	}
+
+	type spec struct {
+		stopAfter  int
+		expVisited int
+	}
+	specs := []spec{
+		spec{-1, 4},
+		spec{1, 1},
+		spec{3, 3},
+	}
+
+	for index, s := range specs {
+		var visited int
+		sc.WalkInstances(func(inst *MeshInstance, worldTransform types.Mat4) bool {
+			if inst != &sc.MeshInstanceList[visited] {
+				t.Errorf("[spec %d] expected instance %d to be visited in list order", index, visited)
+			}
+
+			// The world transform should move the mesh origin to the instance offset
+			expPos := types.Vec3{float32(inst.MeshIndex), 0, 0}
+			if pos := worldTransform.Mul4x1(types.Vec4{0, 0, 0, 1}).Vec3(); pos.Sub(expPos).Len() > 1e-5 {
+				t.Errorf("[spec %d] expected world transform of instance %d to map the origin to %v; got %v", index, inst.MeshIndex, expPos, pos)
+			}
+
+			visited++
+			return visited != s.stopAfter
+		})
+
+		if visited != s.expVisited {
+			t.Errorf("[spec %d] expected to visit %d instances; got %d", index, s.expVisited, visited)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package scene
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The default interval between scene file modification checks.
+const DefaultWatchPollInterval = 250 * time.Millisecond
+
+// The default quiet period that must elapse after the last scene file
+// modification before the scene is reloaded.
+const DefaultWatchDebounce = 500 * time.Millisecond
+
+// A function that loads a scene from a file.
+type SceneLoader func(path string) (*Scene, error)
+
+// Options for tuning a scene Watcher.
+type WatchOptions struct {
+	// The interval between file modification checks. Defaults to
+	// DefaultWatchPollInterval.
+	PollInterval time.Duration
+
+	// Rapid successive writes are coalesced into a single reload that is
+	// triggered once the file has not been modified for this period.
+	// Defaults to DefaultWatchDebounce.
+	Debounce time.Duration
+
+	// An optional callback for errors that occur while reloading the scene.
+	OnError func(error)
+}
+
+// A Watcher monitors a scene file and reloads the scene when the file changes.
+// The file is polled for changes so the watcher works with any filesystem.
+type Watcher struct {
+	path     string
+	loader   SceneLoader
+	options  WatchOptions
+	onReload func(*Scene)
+
+	mutex sync.Mutex
+	scene *Scene
+
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// The modification stamp of a watched file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Load a scene using the supplied loader and start watching its source file for
+// changes. Each time the file changes, the scene is reloaded and onReload is
+// invoked with the updated scene from the watcher goroutine.
+//
+// Bulk scene assets (geometry, BVH and texture data) that are unchanged between
+// reloads are shared with the previous scene instance so consumers can skip
+// processing them (e.g. re-uploading them to the GPU) by comparing slices.
+func WatchFile(path string, loader SceneLoader, options WatchOptions, onReload func(*Scene)) (*Watcher, error) {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultWatchPollInterval
+	}
+	if options.Debounce < 0 {
+		return nil, fmt.Errorf("scene: watch debounce period must not be negative")
+	} else if options.Debounce == 0 {
+		options.Debounce = DefaultWatchDebounce
+	}
+
+	stamp, err := statFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := loader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:     path,
+		loader:   loader,
+		options:  options,
+		onReload: onReload,
+		scene:    sc,
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	go w.watch(stamp)
+	return w, nil
+}
+
+// Get the most recently loaded scene.
+func (w *Watcher) Scene() *Scene {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.scene
+}
+
+// Stop watching the scene file. Close blocks until any in-progress reload
+// completes.
+func (w *Watcher) Close() {
+	close(w.stopChan)
+	<-w.doneChan
+}
+
+// Poll the watched file for changes and reload the scene once the file has
+// not been modified for the debounce period.
+func (w *Watcher) watch(lastStamp fileStamp) {
+	defer close(w.doneChan)
+
+	ticker := time.NewTicker(w.options.PollInterval)
+	defer ticker.Stop()
+
+	var changedAt time.Time
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case now := <-ticker.C:
+			// The file may be temporarily missing while an editor
+			// replaces it; try again on the next tick.
+			stamp, err := statFile(w.path)
+			if err != nil {
+				continue
+			}
+
+			if stamp != lastStamp {
+				lastStamp = stamp
+				changedAt = now
+				continue
+			}
+
+			if !changedAt.IsZero() && now.Sub(changedAt) >= w.options.Debounce {
+				changedAt = time.Time{}
+				w.reload()
+			}
+		}
+	}
+}
+
+// Reload the scene and notify the reload callback.
+func (w *Watcher) reload() {
+	sc, err := w.loader(w.path)
+	if err != nil {
+		if w.options.OnError != nil {
+			w.options.OnError(err)
+		}
+		return
+	}
+
+	w.mutex.Lock()
+	reuseUnchangedAssets(w.scene, sc)
+	w.scene = sc
+	w.mutex.Unlock()
+
+	if w.onReload != nil {
+		w.onReload(sc)
+	}
+}
+
+// Replace the bulk asset lists of the next scene with the ones from the
+// previous scene if their contents are identical.
+func reuseUnchangedAssets(prev, next *Scene) {
+	if prev == nil {
+		return
+	}
+
+	reuse := func(prevList, nextList interface{}) {
+		prevVal := reflect.ValueOf(prevList).Elem()
+		nextVal := reflect.ValueOf(nextList).Elem()
+		if prevVal.Len() == nextVal.Len() && reflect.DeepEqual(prevVal.Interface(), nextVal.Interface()) {
+			nextVal.Set(prevVal)
+		}
+	}
+	reuse(&prev.VertexList, &next.VertexList)
+	reuse(&prev.NormalList, &next.NormalList)
+	reuse(&prev.UvList, &next.UvList)
+	reuse(&prev.VertexColorList, &next.VertexColorList)
+	reuse(&prev.MaterialIndex, &next.MaterialIndex)
+	reuse(&prev.BvhNodeList, &next.BvhNodeList)
+	reuse(&prev.TextureMetadata, &next.TextureMetadata)
+
+	if bytes.Equal(prev.TextureData, next.TextureData) {
+		next.TextureData = prev.TextureData
+	}
+}
+
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,97 @@
+package scene
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "polaris-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The test scene file contains the number of triangles to generate
+	path := filepath.Join(dir, "scene.txt")
+	writeCount := func(count int) {
+		if err := ioutil.WriteFile(path, []byte(strconv.Itoa(count)+strings.Repeat(" ", count)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader := func(path string) (*Scene, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		return &Scene{
+			VertexList:  make([]types.Vec4, 3*count),
+			TextureData: []byte{1, 2, 3, 4},
+		}, nil
+	}
+
+	writeCount(1)
+	reloadChan := make(chan *Scene, 10)
+	w, err := WatchFile(path, loader, WatchOptions{PollInterval: 5 * time.Millisecond, Debounce: 100 * time.Millisecond}, func(sc *Scene) {
+		reloadChan <- sc
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	initial := w.Scene()
+	if len(initial.VertexList) != 3 {
+		t.Fatalf("expected initial scene to contain 3 vertices; got %d", len(initial.VertexList))
+	}
+
+	// Rapid successive writes should trigger a single reload
+	for count := 2; count <= 5; count++ {
+		writeCount(count)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var reloaded *Scene
+	select {
+	case reloaded = <-reloadChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for scene reload")
+	}
+
+	if len(reloaded.VertexList) != 15 {
+		t.Fatalf("expected reloaded scene to contain 15 vertices; got %d", len(reloaded.VertexList))
+	}
+	if w.Scene() != reloaded {
+		t.Fatal("expected watcher to return the reloaded scene")
+	}
+	if &reloaded.TextureData[0] != &initial.TextureData[0] {
+		t.Fatal("expected unchanged texture data to be reused")
+	}
+
+	select {
+	case <-reloadChan:
+		t.Fatal("expected successive writes to be debounced into a single reload")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatchFileErrors(t *testing.T) {
+	loader := func(path string) (*Scene, error) {
+		return &Scene{}, nil
+	}
+
+	if _, err := WatchFile(filepath.Join(os.TempDir(), "polaris-missing-scene.obj"), loader, WatchOptions{}, nil); err == nil {
+		t.Fatal("expected an error when watching a missing file")
+	}
+}
@@ -0,0 +1,229 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// A report with the results of a winding repair pass.
+type WindingReport struct {
+	// The number of faces whose winding was flipped.
+	FlippedFaces int
+
+	// The number of connected face components. A value greater than 1
+	// indicates that the mesh contains disconnected parts whose orientation
+	// was repaired independently of each other.
+	Components int
+
+	// The number of edges shared by more than two faces. Orientation is
+	// not propagated across non-manifold edges.
+	NonManifoldEdges int
+
+	// The number of manifold edges whose faces could not be consistently
+	// oriented (e.g. because the surface is non-orientable).
+	InconsistentEdges int
+}
+
+// Returns true if the repaired mesh is manifold, orientable and connected.
+func (r *WindingReport) IsClean() bool {
+	return r.Components <= 1 && r.NonManifoldEdges == 0 && r.InconsistentEdges == 0
+}
+
+// A reference to a face that shares an edge. The forward flag is set when the
+// face traverses the edge from its lower to its higher vertex id.
+type edgeFace struct {
+	face    uint32
+	forward bool
+}
+
+// Ensure that all connected faces of a mesh use a consistent winding order.
+//
+// Faces are considered to be connected if they share an edge. Orientation
+// is propagated across shared edges (flood fill) so that connected faces
+// traverse their shared edges in opposite directions. For each connected
+// component, the orientation shared by the majority of its faces is preserved
+// and the remaining faces are flipped by swapping the order of their last
+// two vertices. The normals of flipped faces that point away from the new
+// face orientation are also flipped.
+//
+// Running this function on an already repaired mesh has no effect.
+func FixWinding(s *Scene, meshIndex uint32) (*WindingReport, error) {
+	firstPrim, numPrims, err := meshPrimitives(s, meshIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Since primitives are stored as a triangle soup we need to weld
+	// vertices by position before we can detect shared edges.
+	vertexIds := make(map[types.Vec3]uint32)
+	faceVertexIds := make([][3]uint32, numPrims)
+	for face := uint32(0); face < numPrims; face++ {
+		for i := uint32(0); i < 3; i++ {
+			pos := s.VertexList[3*(firstPrim+face)+i].Vec3()
+			id, exists := vertexIds[pos]
+			if !exists {
+				id = uint32(len(vertexIds))
+				vertexIds[pos] = id
+			}
+			faceVertexIds[face][i] = id
+		}
+	}
+
+	edges := make(map[[2]uint32][]edgeFace)
+	for face, ids := range faceVertexIds {
+		for i := 0; i < 3; i++ {
+			from, to := ids[i], ids[(i+1)%3]
+			if from == to {
+				// Skip degenerate edge
+				continue
+			}
+
+			key := [2]uint32{from, to}
+			if from > to {
+				key = [2]uint32{to, from}
+			}
+			edges[key] = append(edges[key], edgeFace{uint32(face), from < to})
+		}
+	}
+
+	report := &WindingReport{}
+	adjacency := make([][]edgeFace, numPrims)
+	for _, faces := range edges {
+		switch {
+		case len(faces) > 2:
+			report.NonManifoldEdges++
+		case len(faces) == 2:
+			// Faces are consistently oriented if they traverse the shared
+			// edge in opposite directions. We store the relative flip that
+			// needs to be applied to the neighbor in the forward flag.
+			sameDir := faces[0].forward == faces[1].forward
+			adjacency[faces[0].face] = append(adjacency[faces[0].face], edgeFace{faces[1].face, sameDir})
+			adjacency[faces[1].face] = append(adjacency[faces[1].face], edgeFace{faces[0].face, sameDir})
+		}
+	}
+
+	// Flood-fill each connected component
+	visited := make([]bool, numPrims)
+	flip := make([]bool, numPrims)
+	for seed := uint32(0); seed < numPrims; seed++ {
+		if visited[seed] {
+			continue
+		}
+		report.Components++
+
+		component := []uint32{seed}
+		visited[seed] = true
+		numFlipped := 0
+		for next := 0; next < len(component); next++ {
+			face := component[next]
+			for _, neighbor := range adjacency[face] {
+				wantFlip := flip[face] != neighbor.forward
+				if visited[neighbor.face] {
+					if flip[neighbor.face] != wantFlip {
+						report.InconsistentEdges++
+					}
+					continue
+				}
+
+				visited[neighbor.face] = true
+				flip[neighbor.face] = wantFlip
+				if wantFlip {
+					numFlipped++
+				}
+				component = append(component, neighbor.face)
+			}
+		}
+
+		// Preserve the orientation of the majority of the component faces.
+		if 2*numFlipped > len(component) {
+			for _, face := range component {
+				flip[face] = !flip[face]
+			}
+		}
+	}
+
+	// Each inconsistent edge is detected from both of its faces.
+	report.InconsistentEdges /= 2
+
+	for face := uint32(0); face < numPrims; face++ {
+		if !flip[face] {
+			continue
+		}
+
+		flipFace(s, firstPrim+face)
+		report.FlippedFaces++
+	}
+
+	return report, nil
+}
+
+// Reverse the winding of a primitive by swapping its last two vertices and
+// ensure that its normals point towards the same side as the primitive.
+func flipFace(s *Scene, primIndex uint32) {
+	v := 3 * primIndex
+	s.VertexList[v+1], s.VertexList[v+2] = s.VertexList[v+2], s.VertexList[v+1]
+	s.NormalList[v+1], s.NormalList[v+2] = s.NormalList[v+2], s.NormalList[v+1]
+	if len(s.UvList) != 0 {
+		s.UvList[v+1], s.UvList[v+2] = s.UvList[v+2], s.UvList[v+1]
+	}
+	if len(s.VertexColorList) != 0 {
+		s.VertexColorList[v+1], s.VertexColorList[v+2] = s.VertexColorList[v+2], s.VertexColorList[v+1]
+	}
+
+	v0 := s.VertexList[v].Vec3()
+	faceNormal := s.VertexList[v+1].Vec3().Sub(v0).Cross(s.VertexList[v+2].Vec3().Sub(v0))
+	for i := v; i < v+3; i++ {
+		if s.NormalList[i].Vec3().Dot(faceNormal) < 0 {
+			s.NormalList[i] = s.NormalList[i].Mul(-1)
+		}
+	}
+}
+
+// Get the range of primitives that belong to a mesh by scanning the leafs of
+// the mesh BVH tree.
+func meshPrimitives(s *Scene, meshIndex uint32) (firstPrim, numPrims uint32, err error) {
+	var bvhRoot int32 = -1
+	for _, mi := range s.MeshInstanceList {
+		if mi.MeshIndex == meshIndex {
+			bvhRoot = int32(mi.BvhRoot)
+			break
+		}
+	}
+
+	if bvhRoot == -1 {
+		return 0, 0, fmt.Errorf("scene: no instance references mesh %d", meshIndex)
+	}
+
+	var lastPrim uint32
+	firstPrim = ^uint32(0)
+	nodeStack := []int32{bvhRoot}
+	for len(nodeStack) != 0 {
+		node := &s.BvhNodeList[nodeStack[len(nodeStack)-1]]
+		nodeStack = nodeStack[:len(nodeStack)-1]
+
+		if node.LData > 0 {
+			nodeStack = append(nodeStack, node.LData, node.RData)
+			continue
+		}
+
+		// Quad leafs do not reference any triangle primitives
+		if node.IsQuadLeaf() {
+			continue
+		}
+
+		first, count := node.GetPrimitives()
+		if first < firstPrim {
+			firstPrim = first
+		}
+		if first+count > lastPrim {
+			lastPrim = first + count
+		}
+	}
+
+	if lastPrim <= firstPrim {
+		return 0, 0, nil
+	}
+
+	return firstPrim, lastPrim - firstPrim, nil
+}
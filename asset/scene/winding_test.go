@@ -0,0 +1,99 @@
+package scene
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestFixWinding(t *testing.T) {
+	// Build an octahedron centered at the origin with outward facing faces
+	// and flip the winding and normals of one of its faces.
+	px, nx := types.Vec3{1, 0, 0}, types.Vec3{-1, 0, 0}
+	py, ny := types.Vec3{0, 1, 0}, types.Vec3{0, -1, 0}
+	pz, nz := types.Vec3{0, 0, 1}, types.Vec3{0, 0, -1}
+	faces := [][3]types.Vec3{
+		{px, py, pz},
+		{py, nx, pz},
+		{nx, ny, pz},
+		{ny, px, pz},
+		{py, px, nz},
+		{nx, py, nz},
+		{ny, nx, nz},
+		{px, ny, nz},
+	}
+	faces[5][1], faces[5][2] = faces[5][2], faces[5][1]
+
+	sc := makeWindingTestScene(faces)
+	report, err := FixWinding(sc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.FlippedFaces != 1 {
+		t.Fatalf("expected 1 flipped face; got %d", report.FlippedFaces)
+	}
+	if !report.IsClean() {
+		t.Fatalf("expected report to be clean; got %+v", report)
+	}
+
+	for face := 0; face < len(faces); face++ {
+		v0 := sc.VertexList[3*face].Vec3()
+		v1 := sc.VertexList[3*face+1].Vec3()
+		v2 := sc.VertexList[3*face+2].Vec3()
+		center := v0.Add(v1).Add(v2)
+		faceNormal := v1.Sub(v0).Cross(v2.Sub(v0))
+		if faceNormal.Dot(center) <= 0 {
+			t.Fatalf("[face %d] expected face to point outwards", face)
+		}
+
+		for i := 0; i < 3; i++ {
+			if sc.NormalList[3*face+i].Vec3().Dot(center) <= 0 {
+				t.Fatalf("[face %d] expected normal %d to point outwards", face, i)
+			}
+		}
+	}
+
+	// Running a second pass should not modify the mesh
+	report, err = FixWinding(sc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FlippedFaces != 0 {
+		t.Fatalf("expected second pass to flip 0 faces; got %d", report.FlippedFaces)
+	}
+
+	// Add a disconnected triangle
+	sc = makeWindingTestScene(append(faces, [3]types.Vec3{{5, 0, 0}, {6, 0, 0}, {5, 1, 0}}))
+	report, err = FixWinding(sc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Components != 2 {
+		t.Fatalf("expected 2 components; got %d", report.Components)
+	}
+
+	// Unknown meshes should be reported as errors
+	if _, err = FixWinding(sc, 1); err == nil {
+		t.Fatal("expected to get an error for an unknown mesh")
+	}
+}
+
+func makeWindingTestScene(faces [][3]types.Vec3) *Scene {
+	sc := &Scene{
+		MeshInstanceList: []MeshInstance{{MeshIndex: 0, BvhRoot: 0}},
+		BvhNodeList:      make([]BvhNode, 1),
+	}
+	sc.BvhNodeList[0].SetPrimitives(0, uint32(len(faces)))
+
+	for _, face := range faces {
+		normal := face[1].Sub(face[0]).Cross(face[2].Sub(face[0])).Normalize()
+		for _, v := range face {
+			sc.VertexList = append(sc.VertexList, v.Vec4(0))
+			sc.NormalList = append(sc.NormalList, normal.Vec4(0))
+			sc.UvList = append(sc.UvList, types.Vec2{})
+		}
+	}
+
+	return sc
+}
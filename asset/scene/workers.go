@@ -0,0 +1,47 @@
+package scene
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Resolve the number of workers for a CPU task. A count of 0 selects one
+// worker per CPU; negative counts are rejected.
+func resolveWorkers(workers int) (int, error) {
+	if workers == 0 {
+		return runtime.NumCPU(), nil
+	} else if workers < 1 {
+		return 0, fmt.Errorf("scene: worker count must be >= 1; got %d", workers)
+	}
+	return workers, nil
+}
+
+// Process rows [0, numRows) in parallel using at most workers goroutines.
+// Workers claim rows one at a time so the load stays balanced even if the
+// row cost varies. The callback receives the index of the worker processing
+// the row so workers can own scratch buffers. Blocks until all rows are
+// processed.
+func parallelRows(numRows, workers int, fn func(workerIndex, row int)) {
+	if workers > numRows {
+		workers = numRows
+	}
+
+	var nextRow int32 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for workerIndex := 0; workerIndex < workers; workerIndex++ {
+		go func(workerIndex int) {
+			defer wg.Done()
+			for {
+				row := int(atomic.AddInt32(&nextRow, 1))
+				if row >= numRows {
+					return
+				}
+				fn(workerIndex, row)
+			}
+		}(workerIndex)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,74 @@
+package scene
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelRows(t *testing.T) {
+	type spec struct {
+		numRows, workers int
+	}
+	specs := []spec{
+		spec{16, 1},
+		spec{16, 4},
+		// Workers are capped by the number of rows
+		spec{2, 8},
+	}
+
+	for index, s := range specs {
+		var active, maxActive int32
+		processed := make([]int32, s.numRows)
+		parallelRows(s.numRows, s.workers, func(workerIndex, row int) {
+			if workerIndex < 0 || workerIndex >= s.workers {
+				t.Errorf("[spec %d] unexpected worker index %d", index, workerIndex)
+			}
+
+			cur := atomic.AddInt32(&active, 1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if cur <= max || atomic.CompareAndSwapInt32(&maxActive, max, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			atomic.AddInt32(&processed[row], 1)
+		})
+
+		for row, count := range processed {
+			if count != 1 {
+				t.Fatalf("[spec %d] expected row %d to be processed once; got %d", index, row, count)
+			}
+		}
+		if int(maxActive) > s.workers || int(maxActive) > s.numRows {
+			t.Fatalf("[spec %d] expected at most %d concurrent workers; got %d", index, s.workers, maxActive)
+		}
+	}
+
+	if _, err := resolveWorkers(-1); err == nil {
+		t.Fatal("expected an error for a negative worker count")
+	}
+	if workers, err := resolveWorkers(0); err != nil || workers < 1 {
+		t.Fatalf("expected the default worker count to be >= 1; got %d (%v)", workers, err)
+	}
+}
+
+func TestWorkerCountDeterminism(t *testing.T) {
+	sc := makeCreviceTestScene()
+	bakeOpts := AOBakeOptions{Width: 16, Height: 8, Samples: 16, MaxDistance: 0.5, Workers: 1}
+	serialIm, err := sc.BakeAO(0, bakeOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bakeOpts.Workers = 4
+	parallelIm, err := sc.BakeAO(0, bakeOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(serialIm.Pix, parallelIm.Pix) {
+		t.Fatal("expected baked AO to match between 1 and 4 workers")
+	}
+}
@@ -0,0 +1,80 @@
+package writer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/log"
+)
+
+type objSceneWriter struct {
+	logger     log.Logger
+	sceneFile  string
+	convention scene.ExportConvention
+}
+
+// Create a new wavefront obj scene writer.
+func newObjSceneWriter(sceneFile string, convention scene.ExportConvention) *objSceneWriter {
+	return &objSceneWriter{
+		logger:     log.New("obj scene writer"),
+		sceneFile:  sceneFile,
+		convention: convention,
+	}
+}
+
+// Write the scene geometry to a wavefront obj file. Each mesh instance is
+// written as a separate object with its triangles converted to world space
+// and to the writer's export convention.
+func (w *objSceneWriter) Write(sc *scene.Scene) error {
+	w.logger.Noticef(`writing scene geometry to "%s"`, w.sceneFile)
+	start := time.Now()
+
+	f, err := os.Create(w.sceneFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	hasUVs := len(sc.UvList) != 0
+	var nextIndex uint32 = 1
+	for instanceIndex := range sc.MeshInstanceList {
+		triangles, err := sc.ExportInstance(uint32(instanceIndex), w.convention)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(bw, "o instance_%d\n", instanceIndex)
+		for _, tri := range triangles {
+			for i := 0; i < 3; i++ {
+				fmt.Fprintf(bw, "v %g %g %g\n", tri.Vertices[i][0], tri.Vertices[i][1], tri.Vertices[i][2])
+				fmt.Fprintf(bw, "vn %g %g %g\n", tri.Normals[i][0], tri.Normals[i][1], tri.Normals[i][2])
+				if hasUVs {
+					fmt.Fprintf(bw, "vt %g %g\n", tri.UVs[i][0], tri.UVs[i][1])
+				}
+			}
+		}
+		for range triangles {
+			bw.WriteString("f")
+			for i := uint32(0); i < 3; i++ {
+				if hasUVs {
+					fmt.Fprintf(bw, " %d/%d/%d", nextIndex+i, nextIndex+i, nextIndex+i)
+				} else {
+					fmt.Fprintf(bw, " %d//%d", nextIndex+i, nextIndex+i)
+				}
+			}
+			bw.WriteString("\n")
+			nextIndex += 3
+		}
+	}
+
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+
+	w.logger.Noticef("wrote scene geometry in %d ms", time.Since(start).Nanoseconds()/1e6)
+	return nil
+}
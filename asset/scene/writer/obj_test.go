@@ -0,0 +1,88 @@
+package writer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+)
+
+// A unit quad at z=0 whose front faces point towards +z.
+const quadObj = `o quad
+v 0 0 0
+v 1 0 0
+v 0 1 0
+v 1 1 0
+vn 0 0 1
+f 1//1 2//1 3//1
+f 2//1 4//1 3//1
+`
+
+func TestWriteOBJWinding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "polaris-obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inFile := filepath.Join(dir, "in.obj")
+	if err = ioutil.WriteFile(inFile, []byte(quadObj), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := reader.ReadScene(inFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origVertices := append(sc.VertexList[:0:0], sc.VertexList...)
+	origNormals := append(sc.NormalList[:0:0], sc.NormalList...)
+
+	type spec struct {
+		conv scene.ExportConvention
+		// The expected sign of the z component of the geometric normal
+		// (using CCW winding) and the vertex normals after re-importing.
+		expFaceZ   float32
+		expNormalZ float32
+	}
+	specs := []spec{
+		spec{scene.ExportConvention{}, 1, 1},
+		spec{scene.ExportConvention{Winding: scene.WindingCW}, -1, 1},
+		spec{scene.ExportConvention{FlipNormals: true}, 1, -1},
+		spec{scene.ExportConvention{Winding: scene.WindingCW, FlipNormals: true}, -1, -1},
+	}
+
+	for index, s := range specs {
+		outFile := filepath.Join(dir, "out.obj")
+		if err = WriteOBJ(sc, outFile, s.conv); err != nil {
+			t.Fatalf("[spec %d] %v", index, err)
+		}
+
+		// Conversion must only be applied on write
+		if !reflect.DeepEqual(sc.VertexList, origVertices) || !reflect.DeepEqual(sc.NormalList, origNormals) {
+			t.Fatalf("[spec %d] expected export to leave the scene geometry untouched", index)
+		}
+
+		exported, err := reader.ReadScene(outFile)
+		if err != nil {
+			t.Fatalf("[spec %d] %v", index, err)
+		}
+		if len(exported.VertexList) != len(origVertices) {
+			t.Fatalf("[spec %d] expected re-imported scene to contain %d vertices; got %d", index, len(origVertices), len(exported.VertexList))
+		}
+		for v := 0; v < len(exported.VertexList); v += 3 {
+			v0 := exported.VertexList[v].Vec3()
+			faceNormal := exported.VertexList[v+1].Vec3().Sub(v0).Cross(exported.VertexList[v+2].Vec3().Sub(v0))
+			if faceNormal[2]*s.expFaceZ <= 0 {
+				t.Errorf("[spec %d] expected face %d geometric normal z to have sign %v; got %v", index, v/3, s.expFaceZ, faceNormal)
+			}
+			for i := v; i < v+3; i++ {
+				if exported.NormalList[i][2] != s.expNormalZ {
+					t.Errorf("[spec %d] expected vertex %d normal z to be %v; got %v", index, i, s.expNormalZ, exported.NormalList[i])
+				}
+			}
+		}
+	}
+}
@@ -13,3 +13,10 @@ func WriteScene(sc *scene.Scene, filename string) error {
 	writer := newZipSceneWriter(filename)
 	return writer.Write(sc)
 }
+
+// Write the scene geometry to a wavefront obj file using the supplied winding
+// and normal conventions. The scene itself is not modified.
+func WriteOBJ(sc *scene.Scene, filename string, convention scene.ExportConvention) error {
+	writer := newObjSceneWriter(filename, convention)
+	return writer.Write(sc)
+}
@@ -0,0 +1,171 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/achilleasa/polaris/asset"
+)
+
+// The width and height of a compressed texel block.
+const blockDim = 4
+
+// Size of a DDS file header including the magic word.
+const ddsHeaderSize = 128
+
+// Returns the size in bytes of a single compressed block.
+func blockSize(format Format) int {
+	if format == Bc1 {
+		return 8
+	}
+	return 16
+}
+
+// Create a new Rgba8 texture by decoding block-compressed texture data. The
+// texture dimensions must be a multiple of the block size (4).
+func NewBlockCompressed(format Format, width, height uint32, data []byte) (*Texture, error) {
+	if !format.IsBlockCompressed() {
+		return nil, fmt.Errorf("texture: format %d is not block-compressed", format)
+	}
+	if width == 0 || height == 0 || width%blockDim != 0 || height%blockDim != 0 {
+		return nil, fmt.Errorf("texture: block-compressed texture dimensions must be a non-zero multiple of %d; got %dx%d", blockDim, width, height)
+	}
+
+	blocksX, blocksY := int(width/blockDim), int(height/blockDim)
+	bSize := blockSize(format)
+	if expLen := blocksX * blocksY * bSize; len(data) < expLen {
+		return nil, fmt.Errorf("texture: expected %d bytes of block-compressed data for a %dx%d texture; got %d", expLen, width, height, len(data))
+	}
+
+	tex := &Texture{
+		Format: Rgba8,
+		Width:  width,
+		Height: height,
+		Data:   make([]byte, width*height*4),
+	}
+
+	var texels [blockDim * blockDim][4]byte
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			block := data[(by*blocksX+bx)*bSize:]
+			if format == Bc1 {
+				decodeColorBlock(block[0:8], &texels, true)
+			} else {
+				decodeColorBlock(block[8:16], &texels, false)
+				decodeAlphaBlock(block[0:8], &texels)
+			}
+
+			// Copy decoded texels to their place in the texture
+			for ty := 0; ty < blockDim; ty++ {
+				rowOffset := ((by*blockDim+ty)*int(width) + bx*blockDim) * 4
+				for tx := 0; tx < blockDim; tx++ {
+					copy(tex.Data[rowOffset+tx*4:], texels[ty*blockDim+tx][:])
+				}
+			}
+		}
+	}
+
+	return tex, nil
+}
+
+// Decode a BC1 color block. If allowAlpha is true and the first endpoint is
+// less than or equal to the second endpoint, the block uses 3 colors and
+// maps index 3 to transparent black.
+func decodeColorBlock(block []byte, texels *[blockDim * blockDim][4]byte, allowAlpha bool) {
+	c0 := binary.LittleEndian.Uint16(block[0:])
+	c1 := binary.LittleEndian.Uint16(block[2:])
+	indices := binary.LittleEndian.Uint32(block[4:])
+
+	var palette [4][4]byte
+	palette[0] = expandRgb565(c0)
+	palette[1] = expandRgb565(c1)
+	if c0 > c1 || !allowAlpha {
+		for ch := 0; ch < 3; ch++ {
+			palette[2][ch] = byte((2*uint32(palette[0][ch]) + uint32(palette[1][ch]) + 1) / 3)
+			palette[3][ch] = byte((uint32(palette[0][ch]) + 2*uint32(palette[1][ch]) + 1) / 3)
+		}
+		palette[2][3], palette[3][3] = 255, 255
+	} else {
+		for ch := 0; ch < 3; ch++ {
+			palette[2][ch] = byte((uint32(palette[0][ch]) + uint32(palette[1][ch])) / 2)
+		}
+		palette[2][3], palette[3] = 255, [4]byte{0, 0, 0, 0}
+	}
+
+	for index := range texels {
+		texels[index] = palette[(indices>>(2*uint(index)))&0x3]
+	}
+}
+
+// Decode a BC3 alpha block and update the alpha channel of the block texels.
+func decodeAlphaBlock(block []byte, texels *[blockDim * blockDim][4]byte) {
+	var palette [8]byte
+	palette[0], palette[1] = block[0], block[1]
+	a0, a1 := uint32(block[0]), uint32(block[1])
+	if a0 > a1 {
+		for i := uint32(1); i < 7; i++ {
+			palette[i+1] = byte(((7-i)*a0 + i*a1 + 3) / 7)
+		}
+	} else {
+		for i := uint32(1); i < 5; i++ {
+			palette[i+1] = byte(((5-i)*a0 + i*a1 + 2) / 5)
+		}
+		palette[6], palette[7] = 0, 255
+	}
+
+	// 48-bit little-endian index field with 3 bits per texel
+	var indices uint64
+	for i := 7; i >= 2; i-- {
+		indices = indices<<8 | uint64(block[i])
+	}
+	for index := range texels {
+		texels[index][3] = palette[(indices>>(3*uint(index)))&0x7]
+	}
+}
+
+// Expand a 16-bit RGB565 color into an opaque RGBA8 color.
+func expandRgb565(c uint16) [4]byte {
+	r := byte(c>>11) & 0x1f
+	g := byte(c>>5) & 0x3f
+	b := byte(c) & 0x1f
+	return [4]byte{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+// Returns true if the resource points to a DDS file.
+func isDDS(res *asset.Resource) bool {
+	return strings.HasSuffix(strings.ToLower(res.Path()), ".dds")
+}
+
+// Load the top mip level of a DXT1 (BC1) or DXT5 (BC3) compressed DDS file
+// and decode it into an Rgba8 texture.
+func newFromDDS(res *asset.Resource) (*Texture, error) {
+	data, err := ioutil.ReadAll(res)
+	if err != nil {
+		return nil, fmt.Errorf("texture: could not read data from %s: %s", res.Path(), err.Error())
+	}
+
+	if len(data) < ddsHeaderSize || string(data[0:4]) != "DDS " {
+		return nil, fmt.Errorf("texture: invalid DDS header while loading %s", res.Path())
+	}
+
+	height := binary.LittleEndian.Uint32(data[12:])
+	width := binary.LittleEndian.Uint32(data[16:])
+
+	var format Format
+	switch fourCC := string(data[84:88]); fourCC {
+	case "DXT1":
+		format = Bc1
+	case "DXT5":
+		format = Bc3
+	default:
+		return nil, fmt.Errorf("texture: unsupported DDS compression %q while loading %s; supported compressions: DXT1, DXT5", fourCC, res.Path())
+	}
+
+	tex, err := NewBlockCompressed(format, width, height, data[ddsHeaderSize:])
+	if err != nil {
+		return nil, fmt.Errorf("%s while loading %s", err.Error(), res.Path())
+	}
+	return tex, nil
+}
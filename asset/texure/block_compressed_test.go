@@ -0,0 +1,139 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset"
+)
+
+func TestBc1Decode(t *testing.T) {
+	type spec struct {
+		c0, c1    uint16
+		expColors [4][4]byte
+	}
+	specs := []spec{
+		// 4-color mode (c0 > c1)
+		spec{0xF800, 0x001F, [4][4]byte{{255, 0, 0, 255}, {0, 0, 255, 255}, {170, 0, 85, 255}, {85, 0, 170, 255}}},
+		// 3-color mode with transparent black (c0 <= c1)
+		spec{0x001F, 0xF800, [4][4]byte{{0, 0, 255, 255}, {255, 0, 0, 255}, {127, 0, 127, 255}, {0, 0, 0, 0}}},
+	}
+
+	for index, s := range specs {
+		// Texels in the first row select palette entries 0 to 3; the
+		// remaining texels select palette entry 0.
+		block := make([]byte, 8)
+		binary.LittleEndian.PutUint16(block[0:], s.c0)
+		binary.LittleEndian.PutUint16(block[2:], s.c1)
+		binary.LittleEndian.PutUint32(block[4:], 0<<0|1<<2|2<<4|3<<6)
+
+		tex, err := NewBlockCompressed(Bc1, 4, 4, block)
+		if err != nil {
+			t.Fatalf("[spec %d] %v", index, err)
+		}
+		if tex.Format != Rgba8 || len(tex.Data) != 4*4*4 {
+			t.Fatalf("[spec %d] expected a 4x4 Rgba8 texture; got format %d with %d bytes", index, tex.Format, len(tex.Data))
+		}
+
+		for texel := 0; texel < 16; texel++ {
+			expColor := s.expColors[0]
+			if texel < 4 {
+				expColor = s.expColors[texel]
+			}
+			if got := tex.Data[texel*4 : texel*4+4]; !bytes.Equal(got, expColor[:]) {
+				t.Errorf("[spec %d] expected texel %d to be %v; got %v", index, texel, expColor, got)
+			}
+		}
+	}
+}
+
+func TestBc3Decode(t *testing.T) {
+	// 8x4 texture with a red block followed by a green block. The alpha
+	// block of the second texel block interpolates between 255 and 0.
+	data := make([]byte, 32)
+	for blockIndex, color := range []uint16{0xF800, 0x07E0} {
+		block := data[blockIndex*16:]
+		block[0], block[1] = 255, 0
+		if blockIndex == 1 {
+			// Texels 0 to 7 select alpha palette entries 0 to 7
+			var indices uint64
+			for texel := uint64(0); texel < 8; texel++ {
+				indices |= texel << (3 * texel)
+			}
+			for i := 0; i < 6; i++ {
+				block[2+i] = byte(indices >> (8 * uint(i)))
+			}
+		}
+		binary.LittleEndian.PutUint16(block[8:], color)
+		binary.LittleEndian.PutUint16(block[10:], color)
+	}
+
+	tex, err := NewBlockCompressed(Bc3, 8, 4, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expAlpha := []byte{255, 0, 219, 182, 146, 109, 73, 36}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			got := tex.Data[(y*8+x)*4 : (y*8+x)*4+4]
+			expColor := []byte{255, 0, 0, 255}
+			if x >= 4 {
+				texel := y*4 + x - 4
+				expColor = []byte{0, 255, 0, 255}
+				if texel < len(expAlpha) {
+					expColor[3] = expAlpha[texel]
+				}
+			}
+			if !bytes.Equal(got, expColor) {
+				t.Errorf("expected texel (%d, %d) to be %v; got %v", x, y, expColor, got)
+			}
+		}
+	}
+}
+
+func TestBlockCompressedValidation(t *testing.T) {
+	type spec struct {
+		format        Format
+		width, height uint32
+		dataLen       int
+	}
+	specs := []spec{
+		spec{Bc1, 6, 4, 16},
+		spec{Bc1, 4, 0, 8},
+		spec{Bc3, 8, 8, 48},
+		spec{Rgba8, 4, 4, 64},
+	}
+
+	for index, s := range specs {
+		if _, err := NewBlockCompressed(s.format, s.width, s.height, make([]byte, s.dataLen)); err == nil {
+			t.Errorf("[spec %d] expected to get an error", index)
+		}
+	}
+}
+
+func TestDDSTexture(t *testing.T) {
+	data := make([]byte, ddsHeaderSize+8)
+	copy(data, "DDS ")
+	binary.LittleEndian.PutUint32(data[12:], 4)
+	binary.LittleEndian.PutUint32(data[16:], 4)
+	copy(data[84:], "DXT1")
+	binary.LittleEndian.PutUint16(data[ddsHeaderSize:], 0x07E0)
+
+	tex, err := New(asset.NewResourceFromStream("tex.dds", bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tex.Format != Rgba8 || tex.Width != 4 || tex.Height != 4 {
+		t.Fatalf("expected a 4x4 Rgba8 texture; got format %d with dims %dx%d", tex.Format, tex.Width, tex.Height)
+	}
+	if got := tex.Data[0:4]; !bytes.Equal(got, []byte{0, 255, 0, 255}) {
+		t.Fatalf("expected first texel to be green; got %v", got)
+	}
+
+	copy(data[84:], "DXT3")
+	if _, err = New(asset.NewResourceFromStream("tex.dds", bytes.NewReader(data))); err == nil {
+		t.Fatal("expected to get an error for an unsupported DDS compression")
+	}
+}
@@ -0,0 +1,68 @@
+package texture
+
+import "math"
+
+// Convert a float32 value to an IEEE 754 half-precision float. Values outside
+// the half-float range are clamped to infinity and the result is rounded to
+// the nearest representable value.
+func Float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xff) - 127 + 15
+	mantissa := bits & 0x7fffff
+
+	switch {
+	case bits&0x7fffffff == 0:
+		return sign
+	case exp >= 0x1f:
+		// NaN or overflow to infinity
+		if bits&0x7f800000 == 0x7f800000 && mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp <= 0:
+		// Denormal or underflow to zero
+		if exp < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift := uint32(14 - exp)
+		half := uint16(mantissa >> shift)
+		if mantissa>>(shift-1)&1 != 0 {
+			half++
+		}
+		return sign | half
+	}
+
+	half := sign | uint16(exp)<<10 | uint16(mantissa>>13)
+	if mantissa&0x1000 != 0 {
+		// Round up; a carry into the exponent is still correct
+		half++
+	}
+	return half
+}
+
+// Convert an IEEE 754 half-precision float to a float32 value.
+func HalfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mantissa := uint32(h & 0x3ff)
+
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	case exp == 0:
+		if mantissa == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Normalize denormal value
+		exp = 1
+		for mantissa&0x400 == 0 {
+			mantissa <<= 1
+			exp--
+		}
+		mantissa &= 0x3ff
+	}
+
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mantissa<<13)
+}
@@ -0,0 +1,38 @@
+package texture
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHalfConversion(t *testing.T) {
+	type spec struct {
+		in      float32
+		expHalf uint16
+		expOut  float32
+	}
+	specs := []spec{
+		spec{0, 0x0000, 0},
+		spec{1, 0x3c00, 1},
+		spec{-2, 0xc000, -2},
+		spec{0.5, 0x3800, 0.5},
+		spec{65504, 0x7bff, 65504},
+		spec{1e6, 0x7c00, float32(math.Inf(1))},
+		// Smallest positive denormal
+		spec{5.9604645e-8, 0x0001, 5.9604645e-8},
+		spec{1e-9, 0x0000, 0},
+		// Rounds to the nearest representable value
+		spec{0.1, 0x2e66, 0.099975586},
+	}
+
+	for index, s := range specs {
+		h := Float32ToHalf(s.in)
+		if h != s.expHalf {
+			t.Errorf("[spec %d] expected %f to be encoded as 0x%04x; got 0x%04x", index, s.in, s.expHalf, h)
+			continue
+		}
+		if out := HalfToFloat32(h); out != s.expOut {
+			t.Errorf("[spec %d] expected 0x%04x to be decoded as %g; got %g", index, h, s.expOut, out)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package texture
+
+import (
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// Convert a height map into a tangent-space normal map. The height map is
+// stored in row-major order and strength scales the height gradients.
+//
+// The generated normal map uses the same convention as the normal map
+// samplers: the X (red) axis points towards increasing columns (+U), the Y
+// (green) axis points towards increasing rows (+V) and the Z (blue) axis
+// points away from the surface. Each component is mapped from [-1, 1] to
+// [0, 255]. The returned data uses the Rgba8 texture layout with the alpha
+// channel set to 255.
+func HeightToNormal(height []float32, w, h int, strength float32) []byte {
+	data := make([]byte, 4*w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := heightGradient(height, w, h, x, y)
+			n := types.Vec3{-dx * strength, -dy * strength, 1}.Normalize()
+
+			offset := 4 * (y*w + x)
+			data[offset] = encodeNormalComponent(n[0])
+			data[offset+1] = encodeNormalComponent(n[1])
+			data[offset+2] = encodeNormalComponent(n[2])
+			data[offset+3] = 255
+		}
+	}
+
+	return data
+}
+
+// Reconstruct a height map from a tangent-space normal map generated using the
+// convention described in HeightToNormal. The normal map data must use the
+// Rgba8 layout and strength must match the value used to generate it.
+//
+// The height map is recovered by solving the Poisson equation whose source
+// term is the divergence of the gradient field encoded by the normals. As
+// heights can only be recovered up to a constant offset, the returned height
+// map is shifted so that its mean value is zero.
+func NormalToHeight(normals []byte, w, h int, strength float32) []float32 {
+	if strength == 0 {
+		strength = 1
+	}
+
+	// Decode gradients
+	gradX := make([]float32, w*h)
+	gradY := make([]float32, w*h)
+	for index := 0; index < w*h; index++ {
+		nx := decodeNormalComponent(normals[4*index])
+		ny := decodeNormalComponent(normals[4*index+1])
+		nz := decodeNormalComponent(normals[4*index+2])
+		if nz < 1e-3 {
+			nz = 1e-3
+		}
+		gradX[index] = -nx / (nz * strength)
+		gradY[index] = -ny / (nz * strength)
+	}
+
+	// Solve the Poisson equation using successive over-relaxation. Each
+	// texel height is related to its neighbors via the average gradient
+	// between them; neighbors outside the map are ignored which is
+	// equivalent to imposing Neumann boundary conditions.
+	size := w
+	if h > size {
+		size = h
+	}
+	omega := float32(2 / (1 + math.Sin(math.Pi/float64(size))))
+	height := make([]float32, w*h)
+	for iter := 0; iter < 4*size; iter++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				index := y*w + x
+				var sum, count float32
+				if x > 0 {
+					sum += height[index-1] + 0.5*(gradX[index-1]+gradX[index])
+					count++
+				}
+				if x < w-1 {
+					sum += height[index+1] - 0.5*(gradX[index]+gradX[index+1])
+					count++
+				}
+				if y > 0 {
+					sum += height[index-w] + 0.5*(gradY[index-w]+gradY[index])
+					count++
+				}
+				if y < h-1 {
+					sum += height[index+w] - 0.5*(gradY[index]+gradY[index+w])
+					count++
+				}
+				if count == 0 {
+					continue
+				}
+
+				height[index] += omega * (sum/count - height[index])
+			}
+		}
+	}
+
+	var mean float32
+	for _, v := range height {
+		mean += v
+	}
+	mean /= float32(len(height))
+	for index := range height {
+		height[index] -= mean
+	}
+
+	return height
+}
+
+// Calculate the gradient of a scalar field at (x, y) using central differences.
+// One-sided differences are used at the field borders.
+func heightGradient(field []float32, w, h, x, y int) (float32, float32) {
+	x0, x1 := x-1, x+1
+	if x0 < 0 {
+		x0 = 0
+	}
+	if x1 > w-1 {
+		x1 = w - 1
+	}
+	y0, y1 := y-1, y+1
+	if y0 < 0 {
+		y0 = 0
+	}
+	if y1 > h-1 {
+		y1 = h - 1
+	}
+
+	var dx, dy float32
+	if x1 != x0 {
+		dx = (field[y*w+x1] - field[y*w+x0]) / float32(x1-x0)
+	}
+	if y1 != y0 {
+		dy = (field[y1*w+x] - field[y0*w+x]) / float32(y1-y0)
+	}
+
+	return dx, dy
+}
+
+// Map a normal component from [-1, 1] to [0, 255].
+func encodeNormalComponent(v float32) byte {
+	c := (v*0.5 + 0.5) * 255
+	if c < 0 {
+		c = 0
+	} else if c > 255 {
+		c = 255
+	}
+	return byte(c + 0.5)
+}
+
+// Map a normal component from [0, 255] to [-1, 1].
+func decodeNormalComponent(c byte) float32 {
+	return float32(c)/255*2 - 1
+}
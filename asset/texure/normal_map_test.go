@@ -0,0 +1,86 @@
+package texture
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHeightToNormal(t *testing.T) {
+	const w, h = 8, 8
+
+	type spec struct {
+		height  func(x, y int) float32
+		expRGBA [4]byte
+	}
+	specs := []spec{
+		// flat surface
+		spec{func(x, y int) float32 { return 0 }, [4]byte{128, 128, 255, 255}},
+		// height increasing along +U tilts the normal towards -X
+		spec{func(x, y int) float32 { return float32(x) }, [4]byte{37, 128, 218, 255}},
+		// height increasing along +V tilts the normal towards -Y
+		spec{func(x, y int) float32 { return float32(y) }, [4]byte{128, 37, 218, 255}},
+	}
+	for index, s := range specs {
+		height := make([]float32, w*h)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				height[y*w+x] = s.height(x, y)
+			}
+		}
+
+		data := HeightToNormal(height, w, h, 1)
+		if len(data) != 4*w*h {
+			t.Fatalf("[spec %d] expected normal map data len to be %d; got %d", index, 4*w*h, len(data))
+		}
+		for offset := 0; offset < len(data); offset += 4 {
+			for c := 0; c < 4; c++ {
+				if diff := int(data[offset+c]) - int(s.expRGBA[c]); diff < -1 || diff > 1 {
+					t.Fatalf("[spec %d] expected texel %d to be %v; got %v", index, offset/4, s.expRGBA, data[offset:offset+4])
+				}
+			}
+		}
+	}
+}
+
+func TestNormalToHeightRoundTrip(t *testing.T) {
+	const w, h = 64, 64
+	const strength = 2
+
+	// A bump pattern composed of a few smooth hills and valleys
+	height := make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/w, float64(y)/h
+			height[y*w+x] = float32(4 * math.Sin(2*math.Pi*fx) * math.Cos(3*math.Pi*fy))
+		}
+	}
+
+	normals := HeightToNormal(height, w, h, strength)
+	recovered := NormalToHeight(normals, w, h, strength)
+	if len(recovered) != w*h {
+		t.Fatalf("expected recovered height map len to be %d; got %d", w*h, len(recovered))
+	}
+
+	if corr := correlation(height, recovered); corr < 0.95 {
+		t.Fatalf("expected recovered height map to correlate with the original; got correlation %f", corr)
+	}
+}
+
+func correlation(a, b []float32) float64 {
+	var meanA, meanB float64
+	for index := range a {
+		meanA += float64(a[index])
+		meanB += float64(b[index])
+	}
+	meanA /= float64(len(a))
+	meanB /= float64(len(b))
+
+	var cov, varA, varB float64
+	for index := range a {
+		da, db := float64(a[index])-meanA, float64(b[index])-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	return cov / math.Sqrt(varA*varB)
+}
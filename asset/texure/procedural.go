@@ -0,0 +1,132 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+var (
+	// Procedural texture specs use a function-like syntax:
+	// checker(scale, {r,g,b}, {r,g,b}) or noise(scale, {r,g,b}, {r,g,b}).
+	// All arguments are optional.
+	proceduralSpecRegex = regexp.MustCompile(`^\s*(checker|noise)\s*\((.*)\)\s*$`)
+	proceduralArgRegex  = regexp.MustCompile(`\{[^}]*\}|[^,\s][^,]*`)
+)
+
+// Size of the encoded procedural texture parameters in bytes.
+const sizeofProceduralParams = 32
+
+// A texture that is evaluated analytically at sample UVs.
+type Procedural struct {
+	Format Format
+
+	// The number of pattern repetitions per UV unit.
+	Scale float32
+
+	// The colors to blend.
+	Color1 types.Vec3
+	Color2 types.Vec3
+}
+
+// Returns true if spec describes a procedural texture.
+func IsProcedural(spec string) bool {
+	return proceduralSpecRegex.MatchString(spec)
+}
+
+// Parse a procedural texture spec.
+func ParseProcedural(spec string) (*Procedural, error) {
+	matches := proceduralSpecRegex.FindStringSubmatch(spec)
+	if matches == nil {
+		return nil, fmt.Errorf("texture: invalid procedural texture spec %q", spec)
+	}
+
+	proc := &Procedural{
+		Format: ProceduralChecker,
+		Scale:  1.0,
+		Color1: types.Vec3{1, 1, 1},
+		Color2: types.Vec3{0, 0, 0},
+	}
+	if matches[1] == "noise" {
+		proc.Format = ProceduralNoise
+	}
+
+	args := proceduralArgRegex.FindAllString(matches[2], -1)
+	if len(args) > 3 {
+		return nil, fmt.Errorf("texture: procedural texture %q expects at most 3 arguments; got %d", matches[1], len(args))
+	}
+
+	var err error
+	for index, arg := range args {
+		arg = strings.TrimSpace(arg)
+		switch index {
+		case 0:
+			var scale float64
+			scale, err = strconv.ParseFloat(arg, 32)
+			if err == nil && scale <= 0 {
+				err = fmt.Errorf("scale must be > 0")
+			}
+			proc.Scale = float32(scale)
+		case 1:
+			proc.Color1, err = parseColor(arg)
+		case 2:
+			proc.Color2, err = parseColor(arg)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("texture: invalid argument %d for procedural texture %q: %v", index, matches[1], err)
+		}
+	}
+
+	return proc, nil
+}
+
+// Parse a color in {r,g,b} format.
+func parseColor(arg string) (types.Vec3, error) {
+	var color types.Vec3
+	if !strings.HasPrefix(arg, "{") || !strings.HasSuffix(arg, "}") {
+		return color, fmt.Errorf("expected color in {r,g,b} format")
+	}
+
+	components := strings.Split(arg[1:len(arg)-1], ",")
+	if len(components) != 3 {
+		return color, fmt.Errorf("expected color in {r,g,b} format")
+	}
+
+	for index, component := range components {
+		val, err := strconv.ParseFloat(strings.TrimSpace(component), 32)
+		if err != nil {
+			return color, err
+		}
+		color[index] = float32(val)
+	}
+
+	return color, nil
+}
+
+// Convert the procedural texture into a texture whose data contains the
+// encoded procedural parameters with the following layout:
+// [0-2] color1, [3] scale, [4-6] color2, [7] unused.
+func (p *Procedural) Texture() *Texture {
+	params := []float32{
+		p.Color1[0], p.Color1[1], p.Color1[2], p.Scale,
+		p.Color2[0], p.Color2[1], p.Color2[2], 0,
+	}
+
+	data := make([]byte, sizeofProceduralParams)
+	for index, param := range params {
+		binary.LittleEndian.PutUint32(data[index<<2:], math.Float32bits(param))
+	}
+
+	return &Texture{
+		Format: p.Format,
+		Width:  1,
+		Height: 1,
+		Data:   data,
+	}
+}
@@ -0,0 +1,40 @@
+package texture
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestProceduralChecker(t *testing.T) {
+	proc, err := ParseProcedural("checker(4, {1,0,0}, {0, 0, 1})")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proc.Scale != 4 || proc.Color1 != (types.Vec3{1, 0, 0}) || proc.Color2 != (types.Vec3{0, 0, 1}) {
+		t.Fatalf("expected parsed params to be 4, {1,0,0}, {0,0,1}; got %f, %v, %v", proc.Scale, proc.Color1, proc.Color2)
+	}
+
+	tex := proc.Texture()
+	if tex.Format != ProceduralChecker || !tex.Format.IsProcedural() {
+		t.Fatalf("expected texture format to be %d; got %d", ProceduralChecker, tex.Format)
+	}
+	if len(tex.Data) != sizeofProceduralParams {
+		t.Fatalf("expected texture data len to be %d; got %d", sizeofProceduralParams, len(tex.Data))
+	}
+}
+
+func TestProceduralParseErrors(t *testing.T) {
+	specs := []string{
+		"stripes(4)",
+		"checker(-1)",
+		"checker(4, {1,0})",
+		"checker(4, {1,0,0}, {0,0,1}, 5)",
+	}
+	for index, spec := range specs {
+		if _, err := ParseProcedural(spec); err == nil {
+			t.Fatalf("[spec %d] expected parsing %q to fail", index, spec)
+		}
+	}
+}
@@ -23,6 +23,10 @@ type Texture struct {
 
 // Create a new texture from a Resource.
 func New(res *asset.Resource) (*Texture, error) {
+	if isDDS(res) {
+		return newFromDDS(res)
+	}
+
 	var pathToFile string
 
 	// If this is a remote Resource save it to a temp file so that oiio can load it
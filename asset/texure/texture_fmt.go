@@ -7,4 +7,47 @@ const (
 	Luminance32F
 	Rgba8
 	Rgba32F
+	Luminance16F
+	Rgba16F
 )
+
+// Block-compressed texture formats. Block-compressed textures are expanded to
+// Rgba8 when they are loaded so they never reach the opencl kernels.
+const (
+	Bc1 Format = 50 + iota
+	Bc3
+)
+
+// Procedural texture formats. Procedural textures are evaluated analytically
+// and only store their parameters in the texture data.
+const (
+	ProceduralChecker Format = 100 + iota
+	ProceduralNoise
+)
+
+// Returns true if this is a procedural texture format.
+func (f Format) IsProcedural() bool {
+	return f >= ProceduralChecker
+}
+
+// Returns true if this is a half-float texture format.
+func (f Format) IsHalf() bool {
+	return f == Luminance16F || f == Rgba16F
+}
+
+// Get the number of channels stored for each texel. Returns 0 for procedural
+// and block-compressed formats.
+func (f Format) Channels() int {
+	switch f {
+	case Luminance8, Luminance32F, Luminance16F:
+		return 1
+	case Rgba8, Rgba32F, Rgba16F:
+		return 4
+	}
+	return 0
+}
+
+// Returns true if this is a block-compressed texture format.
+func (f Format) IsBlockCompressed() bool {
+	return f == Bc1 || f == Bc3
+}
@@ -2,14 +2,21 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"runtime"
+	"strconv"
+	"strings"
 
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene"
 	"github.com/achilleasa/polaris/asset/scene/reader"
 	"github.com/achilleasa/polaris/renderer"
 	"github.com/achilleasa/polaris/tracer"
 	"github.com/achilleasa/polaris/tracer/opencl"
+	"github.com/achilleasa/polaris/types"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
@@ -28,12 +35,31 @@ func RenderFrame(ctx *cli.Context) error {
 	setupLogging(ctx)
 
 	opts := renderer.Options{
-		FrameW:          uint32(ctx.Int("width")),
-		FrameH:          uint32(ctx.Int("height")),
-		SamplesPerPixel: uint32(ctx.Int("spp")),
-		Exposure:        float32(ctx.Float64("exposure")),
-		NumBounces:      uint32(ctx.Int("num-bounces")),
-		MinBouncesForRR: uint32(ctx.Int("rr-bounces")),
+		FrameW:                  uint32(ctx.Int("width")),
+		FrameH:                  uint32(ctx.Int("height")),
+		SamplesPerPixel:         uint32(ctx.Int("spp")),
+		Exposure:                float32(ctx.Float64("exposure")),
+		NumBounces:              uint32(ctx.Int("num-bounces")),
+		MinBouncesForRR:         uint32(ctx.Int("rr-bounces")),
+		Seed:                    uint64(ctx.Int("seed")),
+		FrameIndex:              uint32(ctx.Int("frame-index")),
+		CausticPhotons:          uint32(ctx.Int("caustic-photons")),
+		CausticRadius:           float32(ctx.Float64("caustic-radius")),
+		BounceAOVDepth:          uint32(ctx.Int("bounce-aov-depth")),
+		DirectClamp:             float32(ctx.Float64("direct-clamp")),
+		IndirectClamp:           float32(ctx.Float64("indirect-clamp")),
+		RoughnessClamp:          float32(ctx.Float64("roughness-clamp")),
+		MaxGlossyBounces:        uint32(ctx.Int("max-glossy-bounces")),
+		SobolDimensions:         uint32(ctx.Int("sobol-dimensions")),
+		ShadowTransparencyDepth: uint32(ctx.Int("shadow-transparency-depth")),
+		FireflyThreshold:        float32(ctx.Float64("firefly-threshold")),
+		FireflyRadius:           uint32(ctx.Int("firefly-radius")),
+		RayBatchSize:            uint32(ctx.Int("ray-batch-size")),
+		RayEpsilon:              float32(ctx.Float64("ray-epsilon")),
+		MaxSamples:              uint32(ctx.Int("max-spp")),
+		TargetError:             float32(ctx.Float64("target-error")),
+		PreviewScale:            float32(ctx.Float64("preview-scale")),
+		Workers:                 ctx.Int("workers"),
 		//
 		BlackListedDevices: ctx.StringSlice("blacklist"),
 		ForcePrimaryDevice: ctx.String("force-primary"),
@@ -44,6 +70,52 @@ func RenderFrame(ctx *cli.Context) error {
 		opts.MinBouncesForRR = opts.NumBounces + 1
 	}
 
+	var err error
+	opts.RNG, err = tracer.RNGTypeFromName(ctx.String("rng"))
+	if err != nil {
+		return err
+	}
+
+	opts.Sampler, err = tracer.SamplerTypeFromName(ctx.String("sampler"))
+	if err != nil {
+		return err
+	}
+
+	opts.SubpixelPattern, err = tracer.SubpixelPatternFromName(ctx.String("aa-pattern"))
+	if err != nil {
+		return err
+	}
+
+	opts.ColorSpace, err = tracer.ColorSpaceFromName(ctx.String("color-space"))
+	if err != nil {
+		return err
+	}
+	opts.WhiteBalance = tracer.WhiteBalance{
+		Temperature: float32(ctx.Float64("white-balance")),
+		Tint:        float32(ctx.Float64("white-balance-tint")),
+	}
+
+	opts.NonFiniteGuard, err = tracer.NonFiniteGuardFromName(ctx.String("nan-guard"))
+	if err != nil {
+		return err
+	}
+	opts.BackgroundMode, err = tracer.BackgroundModeFromName(ctx.String("background"))
+	if err != nil {
+		return err
+	}
+	opts.GlossyBudgetPolicy, err = material.GlossyBudgetPolicyFromName(ctx.String("glossy-budget-policy"))
+	if err != nil {
+		return err
+	}
+	opts.CollectStats = ctx.Bool("stats")
+
+	if crop := ctx.String("crop"); crop != "" {
+		_, err = fmt.Sscanf(crop, "%d,%d,%d,%d", &opts.CropX, &opts.CropY, &opts.CropW, &opts.CropH)
+		if err != nil {
+			return fmt.Errorf("invalid crop window %q; expected x,y,w,h", crop)
+		}
+	}
+
 	// Load scene
 	if ctx.NArg() != 1 {
 		return errors.New("missing scene file argument")
@@ -53,13 +125,51 @@ func RenderFrame(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	logger.Noticef("scene summary: %s", sc.Summary())
+	sc.SetEnvironmentYaw(float32(ctx.Float64("env-rotation") * math.Pi / 180.0))
+	if err = setupGroundProjection(ctx, sc); err != nil {
+		return err
+	}
+	if err = setupGlobalFog(ctx, sc); err != nil {
+		return err
+	}
+	if err = setupRenderLayers(ctx, sc); err != nil {
+		return err
+	}
+	setupCameraLens(ctx, sc.Camera)
+	if err = setupCameraClip(ctx, sc.Camera); err != nil {
+		return err
+	}
+	if unitsPerMeter := float32(ctx.Float64("units-per-meter")); unitsPerMeter > 0 {
+		sc.UnitsPerMeter = unitsPerMeter
+	}
 
 	// Update projection matrix
 	sc.Camera.SetupProjection(float32(opts.FrameW) / float32(opts.FrameH))
 
 	// Setup tracing pipeline
 	pipeline := opencl.DefaultPipeline(opencl.NoDebug)
-	pipeline.PostProcess = append(pipeline.PostProcess, opencl.SaveFrameBuffer(ctx.String("out")))
+	if err = setupIntegrator(ctx, pipeline); err != nil {
+		return err
+	}
+	if lutFile := ctx.String("lut"); lutFile != "" {
+		lut, err := tracer.LoadCubeLUT(lutFile)
+		if err != nil {
+			return err
+		}
+		pipeline.PostProcess = append(pipeline.PostProcess, opencl.ApplyFrameBufferLUT(lut))
+	}
+	if ctx.Bool("crop-output") {
+		pipeline.PostProcess = append(pipeline.PostProcess, opencl.SaveFrameBufferRegion(ctx.String("out"), opts.CropWindow()))
+	} else {
+		pipeline.PostProcess = append(pipeline.PostProcess, opencl.SaveFrameBuffer(ctx.String("out")))
+	}
+	if opts.BounceAOVDepth > 0 {
+		pipeline.PostProcess = append(pipeline.PostProcess, opencl.SaveBounceAOVs("aov-bounce-%d.png"))
+	}
+	if len(sc.RenderLayers) > 0 {
+		pipeline.PostProcess = append(pipeline.PostProcess, opencl.SaveRenderLayerMasks("layer-%s.png"))
+	}
 
 	// Create renderer
 	r, err := renderer.NewDefault(sc, tracer.NaiveScheduler(), pipeline, opts)
@@ -68,13 +178,16 @@ func RenderFrame(ctx *cli.Context) error {
 	}
 	defer r.Close()
 
-	err = r.Render()
+	err = r.Render(context.Background())
 	if err != nil {
 		return err
 	}
 
 	// Display stats
 	displayFrameStats(r.Stats())
+	if opts.CollectStats {
+		displayRenderStats(r.Stats().RenderStats)
+	}
 
 	return err
 }
@@ -97,7 +210,29 @@ func displayFrameStats(stats renderer.FrameStats) {
 	table.SetFooter([]string{"", "", "", "TOTAL", fmt.Sprintf("%s", stats.RenderTime)})
 
 	table.Render()
-	logger.Noticef("frame statistics\n%s", buf.String())
+	logger.Noticef("frame statistics (%d spp)\n%s", stats.Samples, buf.String())
+
+	if stats.PaddedSamplerDimensions > 0 {
+		logger.Noticef("paths use %d sampler dimensions; %d dimensions past the sobol dimension budget were padded", stats.SamplerDimensions, stats.PaddedSamplerDimensions)
+	}
+}
+
+func displayRenderStats(stats renderer.RenderStats) {
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Counter", "Value"})
+	table.Append([]string{"Primary rays", fmt.Sprintf("%d", stats.PrimaryRays)})
+	table.Append([]string{"Shadow rays", fmt.Sprintf("%d", stats.ShadowRays)})
+	table.Append([]string{"Bounce rays", fmt.Sprintf("%d", stats.BounceRays)})
+	table.Append([]string{"BVH node visits", fmt.Sprintf("%d", stats.BvhNodeVisits)})
+	table.Append([]string{"Triangle tests", fmt.Sprintf("%d", stats.TriangleTests)})
+	table.Append([]string{"Elapsed time", fmt.Sprintf("%s", stats.ElapsedTime)})
+	table.SetFooter([]string{"Rays/sec", fmt.Sprintf("%.0f", stats.RaysPerSecond())})
+
+	table.Render()
+	logger.Noticef("ray statistics\n%s", buf.String())
 }
 
 // Render scene using an interactive opengl view.
@@ -106,12 +241,26 @@ func RenderInteractive(ctx *cli.Context) error {
 	setupLogging(ctx)
 
 	opts := renderer.Options{
-		FrameW:          uint32(ctx.Int("width")),
-		FrameH:          uint32(ctx.Int("height")),
-		SamplesPerPixel: uint32(ctx.Int("spp")),
-		Exposure:        float32(ctx.Float64("exposure")),
-		NumBounces:      uint32(ctx.Int("num-bounces")),
-		MinBouncesForRR: uint32(ctx.Int("rr-bounces")),
+		FrameW:                  uint32(ctx.Int("width")),
+		FrameH:                  uint32(ctx.Int("height")),
+		SamplesPerPixel:         uint32(ctx.Int("spp")),
+		Exposure:                float32(ctx.Float64("exposure")),
+		NumBounces:              uint32(ctx.Int("num-bounces")),
+		MinBouncesForRR:         uint32(ctx.Int("rr-bounces")),
+		Seed:                    uint64(ctx.Int("seed")),
+		CausticPhotons:          uint32(ctx.Int("caustic-photons")),
+		CausticRadius:           float32(ctx.Float64("caustic-radius")),
+		DirectClamp:             float32(ctx.Float64("direct-clamp")),
+		IndirectClamp:           float32(ctx.Float64("indirect-clamp")),
+		RoughnessClamp:          float32(ctx.Float64("roughness-clamp")),
+		MaxGlossyBounces:        uint32(ctx.Int("max-glossy-bounces")),
+		SobolDimensions:         uint32(ctx.Int("sobol-dimensions")),
+		ShadowTransparencyDepth: uint32(ctx.Int("shadow-transparency-depth")),
+		FireflyThreshold:        float32(ctx.Float64("firefly-threshold")),
+		FireflyRadius:           uint32(ctx.Int("firefly-radius")),
+		RayBatchSize:            uint32(ctx.Int("ray-batch-size")),
+		RayEpsilon:              float32(ctx.Float64("ray-epsilon")),
+		Workers:                 ctx.Int("workers"),
 		//
 		BlackListedDevices: ctx.StringSlice("blacklist"),
 		ForcePrimaryDevice: ctx.String("force-primary"),
@@ -122,6 +271,44 @@ func RenderInteractive(ctx *cli.Context) error {
 		opts.MinBouncesForRR = opts.NumBounces + 1
 	}
 
+	var err error
+	opts.RNG, err = tracer.RNGTypeFromName(ctx.String("rng"))
+	if err != nil {
+		return err
+	}
+
+	opts.Sampler, err = tracer.SamplerTypeFromName(ctx.String("sampler"))
+	if err != nil {
+		return err
+	}
+
+	opts.SubpixelPattern, err = tracer.SubpixelPatternFromName(ctx.String("aa-pattern"))
+	if err != nil {
+		return err
+	}
+
+	opts.ColorSpace, err = tracer.ColorSpaceFromName(ctx.String("color-space"))
+	if err != nil {
+		return err
+	}
+	opts.WhiteBalance = tracer.WhiteBalance{
+		Temperature: float32(ctx.Float64("white-balance")),
+		Tint:        float32(ctx.Float64("white-balance-tint")),
+	}
+
+	opts.NonFiniteGuard, err = tracer.NonFiniteGuardFromName(ctx.String("nan-guard"))
+	if err != nil {
+		return err
+	}
+	opts.BackgroundMode, err = tracer.BackgroundModeFromName(ctx.String("background"))
+	if err != nil {
+		return err
+	}
+	opts.GlossyBudgetPolicy, err = material.GlossyBudgetPolicyFromName(ctx.String("glossy-budget-policy"))
+	if err != nil {
+		return err
+	}
+
 	// Setup block scheduler
 	schedulerType := ctx.String("scheduler")
 	var scheduler tracer.BlockScheduler
@@ -144,6 +331,21 @@ func RenderInteractive(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	logger.Noticef("scene summary: %s", sc.Summary())
+	sc.SetEnvironmentYaw(float32(ctx.Float64("env-rotation") * math.Pi / 180.0))
+	if err = setupGroundProjection(ctx, sc); err != nil {
+		return err
+	}
+	if err = setupGlobalFog(ctx, sc); err != nil {
+		return err
+	}
+	setupCameraLens(ctx, sc.Camera)
+	if err = setupCameraClip(ctx, sc.Camera); err != nil {
+		return err
+	}
+	if unitsPerMeter := float32(ctx.Float64("units-per-meter")); unitsPerMeter > 0 {
+		sc.UnitsPerMeter = unitsPerMeter
+	}
 
 	// Due to the way that gl.TexSubImage2D works we need to
 	// generate a mirrored image of the frame buffer.
@@ -152,6 +354,9 @@ func RenderInteractive(ctx *cli.Context) error {
 
 	// Setup tracing pipeline
 	pipeline := opencl.DefaultPipeline(opencl.NoDebug)
+	if err = setupIntegrator(ctx, pipeline); err != nil {
+		return err
+	}
 
 	// Create renderer
 	r, err := renderer.NewInteractive(sc, scheduler, pipeline, opts)
@@ -160,5 +365,116 @@ func RenderInteractive(ctx *cli.Context) error {
 	}
 
 	// enter main loop
-	return r.Render()
+	return r.Render(context.Background())
+}
+
+// Replace the pipeline integrator with the one selected via the command line.
+func setupIntegrator(ctx *cli.Context, pipeline *opencl.Pipeline) error {
+	switch name := ctx.String("integrator"); name {
+	case "path":
+	case "normals":
+		pipeline.Integrator = opencl.NormalsIntegrator()
+	case "ao":
+		pipeline.Integrator = opencl.AmbientOcclusionIntegrator(uint32(ctx.Int("ao-samples")), float32(ctx.Float64("ao-distance")))
+	default:
+		return fmt.Errorf("unsupported integrator %q; supported integrators: path, normals, ao", name)
+	}
+	return nil
+}
+
+// Setup the scene ground projection using the supplied cli flags.
+func setupGroundProjection(ctx *cli.Context, sc *scene.Scene) error {
+	if !ctx.Bool("ground-projection") {
+		return nil
+	}
+
+	color, err := parseColorFlag(ctx, "ground-color")
+	if err != nil {
+		return err
+	}
+
+	sc.GroundProjection = scene.GroundProjection{
+		Enabled: true,
+		Horizon: float32(ctx.Float64("ground-horizon") * math.Pi / 180.0),
+		Color:   color,
+	}
+	return nil
+}
+
+// Setup the scene global fog using the supplied cli flags.
+func setupGlobalFog(ctx *cli.Context, sc *scene.Scene) error {
+	if ctx.Float64("fog-density") == 0 {
+		return nil
+	}
+
+	color, err := parseColorFlag(ctx, "fog-color")
+	if err != nil {
+		return err
+	}
+
+	sc.GlobalFog = scene.GlobalFog{
+		Density: float32(ctx.Float64("fog-density")),
+		Color:   color,
+	}
+	return sc.GlobalFog.Validate()
+}
+
+// Assign mesh instances to the render layers specified via the command line.
+// Each layer is specified as name=index,index,...
+func setupRenderLayers(ctx *cli.Context, sc *scene.Scene) error {
+	for _, spec := range ctx.StringSlice("render-layer") {
+		tokens := strings.SplitN(spec, "=", 2)
+		if len(tokens) != 2 || tokens[0] == "" {
+			return fmt.Errorf("invalid render layer %q; expected name=index,index,...", spec)
+		}
+
+		var instances []uint32
+		for _, index := range strings.Split(tokens[1], ",") {
+			instance, err := strconv.ParseUint(strings.TrimSpace(index), 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid render layer %q; expected name=index,index,...", spec)
+			}
+			instances = append(instances, uint32(instance))
+		}
+
+		if err := sc.SetRenderLayer(tokens[0], instances); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply the near and far clip plane settings specified via the command line to the camera.
+func setupCameraClip(ctx *cli.Context, camera *scene.Camera) error {
+	camera.NearClip = float32(ctx.Float64("near-clip"))
+	camera.FarClip = float32(ctx.Float64("far-clip"))
+	return camera.ValidateClip()
+}
+
+// Parse a color flag specified as comma-separated RGB values.
+func parseColorFlag(ctx *cli.Context, name string) (types.Vec3, error) {
+	var color types.Vec3
+	if _, err := fmt.Sscanf(ctx.String(name), "%f,%f,%f", &color[0], &color[1], &color[2]); err != nil {
+		return color, fmt.Errorf("invalid %s %q; expected comma-separated RGB values", name, ctx.String(name))
+	}
+	return color, nil
+}
+
+// Apply the thin lens settings specified via the command line to the camera.
+func setupCameraLens(ctx *cli.Context, camera *scene.Camera) {
+	if ctx.Float64("aperture") <= 0 {
+		return
+	}
+
+	camera.Lens = scene.CameraLens{
+		ApertureRadius:   float32(ctx.Float64("aperture")),
+		FocusDistance:    float32(ctx.Float64("focus-distance")),
+		ApertureBlades:   uint32(ctx.Int("aperture-blades")),
+		ApertureRotation: float32(ctx.Float64("aperture-rotation") * math.Pi / 180.0),
+	}
+
+	// Focus on the camera look at point if no focus distance is specified
+	if camera.Lens.FocusDistance <= 0 {
+		camera.Lens.FocusDistance = camera.LookAt.Sub(camera.Position).Len()
+	}
 }
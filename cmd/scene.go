@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"errors"
+	"image/png"
+	"os"
 	"strings"
 
+	"github.com/achilleasa/polaris/asset/scene"
 	"github.com/achilleasa/polaris/asset/scene/reader"
 	"github.com/achilleasa/polaris/asset/scene/writer"
 	"github.com/urfave/cli"
@@ -62,3 +65,40 @@ func ShowSceneInfo(ctx *cli.Context) error {
 
 	return nil
 }
+
+// Bake ambient occlusion for a scene mesh instance into a texture.
+func BakeAO(ctx *cli.Context) error {
+	setupLogging(ctx)
+
+	if ctx.NArg() != 1 {
+		return errors.New("missing scene file argument")
+	}
+
+	sc, err := reader.ReadScene(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	opts := scene.AOBakeOptions{
+		Width:       uint32(ctx.Int("width")),
+		Height:      uint32(ctx.Int("height")),
+		Samples:     uint32(ctx.Int("spp")),
+		MaxDistance: float32(ctx.Float64("max-distance")),
+		Padding:     uint32(ctx.Int("padding")),
+		Seed:        int64(ctx.Int("seed")),
+	}
+
+	logger.Noticef("baking AO for mesh instance %d", ctx.Int("instance"))
+	im, err := sc.BakeAO(uint32(ctx.Int("instance")), opts)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(ctx.String("out"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, im)
+}
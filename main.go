@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/achilleasa/polaris/cmd"
+	"github.com/achilleasa/polaris/tracer"
 	"github.com/urfave/cli"
 )
 
@@ -55,6 +56,54 @@ func main() {
 					ArgsUsage: "scene_file.zip",
 					Action:    cmd.ShowSceneInfo,
 				},
+				{
+					Name:      "bake-ao",
+					Usage:     "bake ambient occlusion for a mesh instance into a texture",
+					ArgsUsage: "scene_file.zip or scene_file.obj",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "instance",
+							Value: 0,
+							Usage: "the index of the mesh instance to bake",
+						},
+						cli.IntFlag{
+							Name:  "width",
+							Value: 1024,
+							Usage: "texture width",
+						},
+						cli.IntFlag{
+							Name:  "height",
+							Value: 1024,
+							Usage: "texture height",
+						},
+						cli.IntFlag{
+							Name:  "spp",
+							Value: 64,
+							Usage: "occlusion rays per texel",
+						},
+						cli.Float64Flag{
+							Name:  "max-distance",
+							Value: 0,
+							Usage: "max occluder distance (unlimited if 0)",
+						},
+						cli.IntFlag{
+							Name:  "padding",
+							Value: 4,
+							Usage: "number of texels to pad around UV islands",
+						},
+						cli.IntFlag{
+							Name:  "seed",
+							Value: 0,
+							Usage: "seed for the random number generator",
+						},
+						cli.StringFlag{
+							Name:  "out, o",
+							Value: "ao.png",
+							Usage: "image filename for the baked texture",
+						},
+					},
+					Action: cmd.BakeAO,
+				},
 			},
 		},
 		{
@@ -103,6 +152,230 @@ func main() {
 							Value: 1.2,
 							Usage: "camera exposure for tone-mapping",
 						},
+						cli.StringFlag{
+							Name:  "color-space",
+							Value: "srgb",
+							Usage: "select output color space; supported color spaces: srgb, rec709, acescg",
+						},
+						cli.Float64Flag{
+							Name:  "white-balance",
+							Value: 0,
+							Usage: "color temperature in Kelvin of the illuminant to neutralize (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "white-balance-tint",
+							Value: 0,
+							Usage: "white balance tint (Duv); positive values shift the image towards magenta",
+						},
+						cli.Float64Flag{
+							Name:  "env-rotation",
+							Value: 0,
+							Usage: "rotate the environment map around the Y axis by the specified angle in degrees",
+						},
+						cli.BoolFlag{
+							Name:  "ground-projection",
+							Usage: "occlude the environment below the horizon with the ground color",
+						},
+						cli.Float64Flag{
+							Name:  "ground-horizon",
+							Value: 0,
+							Usage: "the elevation of the ground projection horizon in degrees",
+						},
+						cli.StringFlag{
+							Name:  "ground-color",
+							Value: "0,0,0",
+							Usage: "the ground projection color as comma-separated RGB values",
+						},
+						cli.Float64Flag{
+							Name:  "fog-density",
+							Value: 0,
+							Usage: "the density of a global exponential fog per scene unit (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "fog-color",
+							Value: "0.5,0.5,0.5",
+							Usage: "the radiance in-scattered by the global fog as comma-separated RGB values",
+						},
+						cli.Float64Flag{
+							Name:  "aperture",
+							Value: 0,
+							Usage: "the camera aperture radius for depth of field effects; set to 0 to use a pinhole camera",
+						},
+						cli.Float64Flag{
+							Name:  "focus-distance",
+							Value: 0,
+							Usage: "the distance to the camera focal plane; if set to 0 the camera focuses on its look at point",
+						},
+						cli.IntFlag{
+							Name:  "aperture-blades",
+							Value: 0,
+							Usage: "the number of aperture blades for polygonal bokeh; values below 3 or above 16 produce a circular aperture",
+						},
+						cli.Float64Flag{
+							Name:  "aperture-rotation",
+							Value: 0,
+							Usage: "the aperture rotation in degrees",
+						},
+						cli.Float64Flag{
+							Name:  "near-clip",
+							Value: 0,
+							Usage: "the distance to the camera near clip plane; geometry in front of it is not rendered (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "far-clip",
+							Value: 0,
+							Usage: "the distance to the camera far clip plane; geometry behind it is not rendered (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "rng",
+							Value: "pcg32",
+							Usage: "select random number generator algorithm; supported algorithms: pcg32, xorshift128+",
+						},
+						cli.IntFlag{
+							Name:  "seed",
+							Value: 0,
+							Usage: "seed for the random number generator",
+						},
+						cli.IntFlag{
+							Name:  "frame-index",
+							Value: 0,
+							Usage: "animation frame index; combined with the seed to decorrelate noise across frames",
+						},
+						cli.StringFlag{
+							Name:  "sampler",
+							Value: "random",
+							Usage: "select sampler for camera and bounce samples; supported samplers: random, sobol",
+						},
+						cli.IntFlag{
+							Name:  "sobol-dimensions",
+							Value: 0,
+							Usage: "max number of sobol dimensions per path; deeper bounces reuse shuffled dimensions (16 if 0)",
+						},
+						cli.StringFlag{
+							Name:  "aa-pattern",
+							Value: "sampler",
+							Usage: "select sub-pixel sample placement for anti-aliasing; supported patterns: sampler, grid, halton, poisson",
+						},
+						cli.StringFlag{
+							Name:  "nan-guard",
+							Value: "count",
+							Usage: "select how samples with NaN or Inf radiance are handled; supported policies: count (drop and report), drop, off",
+						},
+						cli.StringFlag{
+							Name:  "background",
+							Value: "opaque",
+							Usage: "select how the background is written to the frame; supported modes: opaque, transparent (alpha from primary ray coverage), transparent-black (like transparent but misses are black)",
+						},
+						cli.IntFlag{
+							Name:  "caustic-photons",
+							Value: 0,
+							Usage: "number of photons to emit for rendering caustics (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "caustic-radius",
+							Value: 0.05,
+							Usage: "photon gather radius for rendering caustics",
+						},
+						cli.Float64Flag{
+							Name:  "direct-clamp",
+							Value: 0,
+							Usage: "clamp direct lighting contributions to this max value (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "indirect-clamp",
+							Value: 0,
+							Usage: "clamp indirect lighting contributions to this max value (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "roughness-clamp",
+							Value: 0,
+							Usage: "min roughness of glossy materials after the first diffuse bounce; suppresses caustic fireflies at the cost of some bias (disabled if 0)",
+						},
+						cli.IntFlag{
+							Name:  "max-glossy-bounces",
+							Value: 0,
+							Usage: "max number of glossy bounces per path; skips deep glossy interreflections to reduce render time (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "glossy-budget-policy",
+							Value: "terminate",
+							Usage: "select how glossy bounces past the max glossy bounce count are handled; supported policies: terminate, diffuse",
+						},
+						cli.IntFlag{
+							Name:  "shadow-transparency-depth",
+							Value: 4,
+							Usage: "max number of transparent surfaces that shadow rays can pass through (transparent surfaces cast opaque shadows if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "firefly-threshold",
+							Value: 0,
+							Usage: "before tone-mapping, scale down pixels brighter than this multiple of their neighborhood median (disabled if 0)",
+						},
+						cli.IntFlag{
+							Name:  "firefly-radius",
+							Value: int(tracer.DefaultFireflyRadius),
+							Usage: "the neighborhood radius in pixels for the firefly filter",
+						},
+						cli.IntFlag{
+							Name:  "ray-batch-size",
+							Value: int(tracer.DefaultRayBatchSize),
+							Usage: "max number of rays processed by each intersection kernel dispatch; must be a power of two",
+						},
+						cli.Float64Flag{
+							Name:  "units-per-meter",
+							Value: 0,
+							Usage: "number of scene units per meter (e.g. 1000 for millimeters); used to derive the ray epsilon (meters if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "ray-epsilon",
+							Value: 0,
+							Usage: "override the ray epsilon derived from the scene units (disabled if 0)",
+						},
+						cli.IntFlag{
+							Name:  "max-spp",
+							Value: 1024,
+							Usage: "max samples per pixel when rendering with a target error",
+						},
+						cli.Float64Flag{
+							Name:  "target-error",
+							Value: 0,
+							Usage: "stop rendering once the estimated relative image error drops below this value; the image is rendered in passes of spp samples (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "preview-scale",
+							Value: 0,
+							Usage: "render a preview at this fraction of the frame resolution before rendering the full resolution frame (disabled if 0)",
+						},
+						cli.IntFlag{
+							Name:  "bounce-aov-depth",
+							Value: 0,
+							Usage: "capture the radiance of this many bounce depths into separate aov-bounce-N.png images (disabled if 0)",
+						},
+						cli.StringSliceFlag{
+							Name:  "render-layer",
+							Value: &cli.StringSlice{},
+							Usage: "assign mesh instances to a render layer specified as name=index,index,... and save its coverage mask into layer-NAME.png",
+						},
+						cli.StringFlag{
+							Name:  "integrator",
+							Value: "path",
+							Usage: "the integrator for rendering the scene: path, normals, ao",
+						},
+						cli.IntFlag{
+							Name:  "ao-samples",
+							Value: 16,
+							Usage: "number of occlusion rays per sample for the ao integrator",
+						},
+						cli.Float64Flag{
+							Name:  "ao-distance",
+							Value: 0,
+							Usage: "max occluder distance for the ao integrator (0 = unbounded)",
+						},
+						cli.IntFlag{
+							Name:  "workers",
+							Value: 0,
+							Usage: "max number of CPU cores used by opencl CPU devices (0 = one per CPU)",
+						},
 						cli.StringSliceFlag{
 							Name:  "blacklist, b",
 							Value: &cli.StringSlice{},
@@ -113,6 +386,24 @@ func main() {
 							Value: "",
 							Usage: "force a particular device name as the primary device",
 						},
+						cli.StringFlag{
+							Name:  "crop",
+							Value: "",
+							Usage: "only render the pixels inside a crop window specified as x,y,w,h",
+						},
+						cli.BoolFlag{
+							Name:  "crop-output",
+							Usage: "save an image with the crop window dimensions instead of a full-size frame",
+						},
+						cli.StringFlag{
+							Name:  "lut",
+							Value: "",
+							Usage: "apply a 3D color LUT in .cube format to the tone-mapped output",
+						},
+						cli.BoolFlag{
+							Name:  "stats",
+							Usage: "collect and display ray and BVH traversal statistics",
+						},
 						cli.StringFlag{
 							Name:  "out, o",
 							Value: "frame.png",
@@ -156,6 +447,200 @@ func main() {
 							Value: 1.2,
 							Usage: "camera exposure for tone-mapping",
 						},
+						cli.StringFlag{
+							Name:  "color-space",
+							Value: "srgb",
+							Usage: "select output color space; supported color spaces: srgb, rec709, acescg",
+						},
+						cli.Float64Flag{
+							Name:  "white-balance",
+							Value: 0,
+							Usage: "color temperature in Kelvin of the illuminant to neutralize (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "white-balance-tint",
+							Value: 0,
+							Usage: "white balance tint (Duv); positive values shift the image towards magenta",
+						},
+						cli.Float64Flag{
+							Name:  "env-rotation",
+							Value: 0,
+							Usage: "rotate the environment map around the Y axis by the specified angle in degrees",
+						},
+						cli.BoolFlag{
+							Name:  "ground-projection",
+							Usage: "occlude the environment below the horizon with the ground color",
+						},
+						cli.Float64Flag{
+							Name:  "ground-horizon",
+							Value: 0,
+							Usage: "the elevation of the ground projection horizon in degrees",
+						},
+						cli.StringFlag{
+							Name:  "ground-color",
+							Value: "0,0,0",
+							Usage: "the ground projection color as comma-separated RGB values",
+						},
+						cli.Float64Flag{
+							Name:  "fog-density",
+							Value: 0,
+							Usage: "the density of a global exponential fog per scene unit (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "fog-color",
+							Value: "0.5,0.5,0.5",
+							Usage: "the radiance in-scattered by the global fog as comma-separated RGB values",
+						},
+						cli.Float64Flag{
+							Name:  "aperture",
+							Value: 0,
+							Usage: "the camera aperture radius for depth of field effects; set to 0 to use a pinhole camera",
+						},
+						cli.Float64Flag{
+							Name:  "focus-distance",
+							Value: 0,
+							Usage: "the distance to the camera focal plane; if set to 0 the camera focuses on its look at point",
+						},
+						cli.IntFlag{
+							Name:  "aperture-blades",
+							Value: 0,
+							Usage: "the number of aperture blades for polygonal bokeh; values below 3 or above 16 produce a circular aperture",
+						},
+						cli.Float64Flag{
+							Name:  "aperture-rotation",
+							Value: 0,
+							Usage: "the aperture rotation in degrees",
+						},
+						cli.Float64Flag{
+							Name:  "near-clip",
+							Value: 0,
+							Usage: "the distance to the camera near clip plane; geometry in front of it is not rendered (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "far-clip",
+							Value: 0,
+							Usage: "the distance to the camera far clip plane; geometry behind it is not rendered (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "rng",
+							Value: "pcg32",
+							Usage: "select random number generator algorithm; supported algorithms: pcg32, xorshift128+",
+						},
+						cli.IntFlag{
+							Name:  "seed",
+							Value: 0,
+							Usage: "seed for the random number generator",
+						},
+						cli.StringFlag{
+							Name:  "sampler",
+							Value: "random",
+							Usage: "select sampler for camera and bounce samples; supported samplers: random, sobol",
+						},
+						cli.IntFlag{
+							Name:  "sobol-dimensions",
+							Value: 0,
+							Usage: "max number of sobol dimensions per path; deeper bounces reuse shuffled dimensions (16 if 0)",
+						},
+						cli.StringFlag{
+							Name:  "aa-pattern",
+							Value: "sampler",
+							Usage: "select sub-pixel sample placement for anti-aliasing; supported patterns: sampler, grid, halton, poisson",
+						},
+						cli.StringFlag{
+							Name:  "nan-guard",
+							Value: "count",
+							Usage: "select how samples with NaN or Inf radiance are handled; supported policies: count (drop and report), drop, off",
+						},
+						cli.StringFlag{
+							Name:  "background",
+							Value: "opaque",
+							Usage: "select how the background is written to the frame; supported modes: opaque, transparent (alpha from primary ray coverage), transparent-black (like transparent but misses are black)",
+						},
+						cli.IntFlag{
+							Name:  "caustic-photons",
+							Value: 0,
+							Usage: "number of photons to emit for rendering caustics (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "caustic-radius",
+							Value: 0.05,
+							Usage: "photon gather radius for rendering caustics",
+						},
+						cli.Float64Flag{
+							Name:  "direct-clamp",
+							Value: 0,
+							Usage: "clamp direct lighting contributions to this max value (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "indirect-clamp",
+							Value: 0,
+							Usage: "clamp indirect lighting contributions to this max value (disabled if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "roughness-clamp",
+							Value: 0,
+							Usage: "min roughness of glossy materials after the first diffuse bounce; suppresses caustic fireflies at the cost of some bias (disabled if 0)",
+						},
+						cli.IntFlag{
+							Name:  "max-glossy-bounces",
+							Value: 0,
+							Usage: "max number of glossy bounces per path; skips deep glossy interreflections to reduce render time (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "glossy-budget-policy",
+							Value: "terminate",
+							Usage: "select how glossy bounces past the max glossy bounce count are handled; supported policies: terminate, diffuse",
+						},
+						cli.IntFlag{
+							Name:  "shadow-transparency-depth",
+							Value: 4,
+							Usage: "max number of transparent surfaces that shadow rays can pass through (transparent surfaces cast opaque shadows if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "firefly-threshold",
+							Value: 0,
+							Usage: "before tone-mapping, scale down pixels brighter than this multiple of their neighborhood median (disabled if 0)",
+						},
+						cli.IntFlag{
+							Name:  "firefly-radius",
+							Value: int(tracer.DefaultFireflyRadius),
+							Usage: "the neighborhood radius in pixels for the firefly filter",
+						},
+						cli.IntFlag{
+							Name:  "ray-batch-size",
+							Value: int(tracer.DefaultRayBatchSize),
+							Usage: "max number of rays processed by each intersection kernel dispatch; must be a power of two",
+						},
+						cli.Float64Flag{
+							Name:  "units-per-meter",
+							Value: 0,
+							Usage: "number of scene units per meter (e.g. 1000 for millimeters); used to derive the ray epsilon (meters if 0)",
+						},
+						cli.Float64Flag{
+							Name:  "ray-epsilon",
+							Value: 0,
+							Usage: "override the ray epsilon derived from the scene units (disabled if 0)",
+						},
+						cli.StringFlag{
+							Name:  "integrator",
+							Value: "path",
+							Usage: "the integrator for rendering the scene: path, normals, ao",
+						},
+						cli.IntFlag{
+							Name:  "ao-samples",
+							Value: 16,
+							Usage: "number of occlusion rays per sample for the ao integrator",
+						},
+						cli.Float64Flag{
+							Name:  "ao-distance",
+							Value: 0,
+							Usage: "max occluder distance for the ao integrator (0 = unbounded)",
+						},
+						cli.IntFlag{
+							Name:  "workers",
+							Value: 0,
+							Usage: "max number of CPU cores used by opencl CPU devices (0 = one per CPU)",
+						},
 						cli.StringSliceFlag{
 							Name:  "blacklist, b",
 							Value: &cli.StringSlice{},
@@ -0,0 +1,170 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The default min interval between progress callbacks.
+const DefaultProgressInterval = 250 * time.Millisecond
+
+// The max number of passes used by async renders. Each pass takes an equal
+// share of the requested samples per pixel.
+const asyncRenderPasses = 16
+
+// A callback for reporting the progress of an async render. It receives the
+// completed fraction of the render in the [0, 1] range and the mean radiance
+// of each frame pixel accumulated so far.
+type ProgressFunc func(fraction float32, preview []types.Vec3)
+
+// A handle for an async render.
+type RenderHandle struct {
+	done chan struct{}
+
+	// The render result; only valid after done is closed.
+	result []types.Vec3
+	stats  FrameStats
+	err    error
+}
+
+// Render a frame of sc as seen by cam in the background. If cam is nil, the
+// scene camera is used instead. The samples per pixel are split into
+// multiple passes and onProgress (if defined) is invoked after each pass
+// with the fraction of completed samples and the current image. Callbacks
+// are throttled to at most one per opts.ProgressInterval except for the
+// final callback which is always invoked once the render completes.
+//
+// Async renders do not support previews or early convergence.
+func RenderAsync(ctx context.Context, sc *scene.Scene, cam *scene.Camera, opts Options, onProgress ProgressFunc) (*RenderHandle, error) {
+	if opts.TargetError > 0 || opts.PreviewScale > 0 {
+		return nil, fmt.Errorf("renderer: previews and early convergence are not supported by async renders")
+	}
+
+	if sc != nil && cam != nil {
+		scCopy := *sc
+		scCopy.Camera = cam
+		sc = &scCopy
+	}
+
+	r, err := NewDefault(sc, tracer.NaiveScheduler(), opencl.DefaultPipeline(opencl.NoDebug), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return startAsyncRender(ctx, r.(*defaultRenderer), onProgress, r.Close), nil
+}
+
+// Block until the render completes and return the mean radiance of each frame
+// pixel. If ctx is cancelled before the render completes, Wait returns
+// ctx.Err().
+func (h *RenderHandle) Wait() ([]types.Vec3, error) {
+	<-h.done
+	return h.result, h.err
+}
+
+// Get a channel which is closed when the render completes.
+func (h *RenderHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Get the stats for the completed render. Stats blocks until the render
+// completes.
+func (h *RenderHandle) Stats() FrameStats {
+	<-h.done
+	return h.stats
+}
+
+// Start rendering passes in a separate goroutine and invoke cleanup once the
+// render completes.
+func startAsyncRender(ctx context.Context, r *defaultRenderer, onProgress ProgressFunc, cleanup func()) *RenderHandle {
+	h := &RenderHandle{done: make(chan struct{})}
+	go func() {
+		defer func() {
+			if cleanup != nil {
+				cleanup()
+			}
+			close(h.done)
+		}()
+
+		h.result, h.err = r.renderProgressive(ctx, onProgress)
+		h.stats = r.Stats()
+	}()
+	return h
+}
+
+// Render the frame in passes reporting the progress after each pass. The
+// primary tracer must be able to read back its frame accumulator.
+func (r *defaultRenderer) renderProgressive(ctx context.Context, onProgress ProgressFunc) ([]types.Vec3, error) {
+	reader, ok := r.tracers[r.primary].(tracer.AccumulatorReader)
+	if !ok {
+		return nil, fmt.Errorf("renderer: primary tracer %q does not support progress reporting", r.tracers[r.primary].Id())
+	}
+
+	interval := r.options.ProgressInterval
+	if interval == 0 {
+		interval = DefaultProgressInterval
+	}
+
+	totalSamples := r.passSamples()
+	passOpts := r.options
+	passOpts.SamplesPerPixel = (totalSamples + asyncRenderPasses - 1) / asyncRenderPasses
+
+	start := time.Now()
+	r.stats.RenderStats = RenderStats{}
+	var lastProgress time.Time
+	var samples uint32
+	for samples < totalSamples {
+		if passOpts.SamplesPerPixel > totalSamples-samples {
+			passOpts.SamplesPerPixel = totalSamples - samples
+		}
+		if err := r.renderPass(ctx, &passOpts, r.rng.Uint32(), samples); err != nil {
+			return nil, err
+		}
+		samples += passOpts.SamplesPerPixel
+
+		// Skip the accumulator read-back if the callback is throttled
+		if samples < totalSamples && (onProgress == nil || time.Since(lastProgress) < interval) {
+			continue
+		}
+
+		accumulator, err := reader.ReadAccumulator(&tracer.BlockRequest{FrameW: r.options.FrameW, FrameH: r.options.FrameH})
+		if err != nil {
+			return nil, err
+		}
+		image := meanRadiance(accumulator, samples)
+		if onProgress != nil {
+			onProgress(float32(samples)/float32(totalSamples), image)
+		}
+		lastProgress = time.Now()
+
+		if samples == totalSamples {
+			r.stats.RenderTime = time.Since(start)
+			r.stats.RenderStats.ElapsedTime = r.stats.RenderTime
+			r.stats.Samples = samples
+			return image, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Convert a frame accumulator with RGBA radiance sums into a list of mean
+// radiance values.
+func meanRadiance(accumulator []float32, samples uint32) []types.Vec3 {
+	out := make([]types.Vec3, len(accumulator)/4)
+	scale := 1.0 / float32(samples)
+	for index := range out {
+		out[index] = types.Vec3{
+			accumulator[4*index] * scale,
+			accumulator[4*index+1] * scale,
+			accumulator[4*index+2] * scale,
+		}
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package renderer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/achilleasa/polaris/log"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRenderAsync(t *testing.T) {
+	render := func(ctx context.Context, interval time.Duration, onProgress ProgressFunc) *RenderHandle {
+		mt := &constMockTracer{mockTracer: makeMockTracer("mock"), frame: make([]float32, 4*16*16)}
+		r := &defaultRenderer{
+			logger:    log.New("renderer"),
+			scheduler: tracer.NaiveScheduler(),
+			options: Options{
+				FrameW:           16,
+				FrameH:           16,
+				SamplesPerPixel:  64,
+				ProgressInterval: interval,
+			},
+			rng:     tracer.NewRNG(tracer.PCG32, 0),
+			tracers: []tracer.Tracer{mt},
+			stats: FrameStats{
+				Tracers: make([]TracerStat, 1),
+			},
+		}
+		r.startWorkers()
+		return startAsyncRender(ctx, r, onProgress, r.Close)
+	}
+
+	var fractions []float32
+	h := render(context.Background(), time.Nanosecond, func(fraction float32, preview []types.Vec3) {
+		fractions = append(fractions, fraction)
+		if len(preview) != 16*16 {
+			t.Fatalf("expected preview to contain %d pixels; got %d", 16*16, len(preview))
+		}
+		if exp := (types.Vec3{0.25, 0.25, 0.25}); preview[0] != exp {
+			t.Fatalf("expected preview pixel to be %v; got %v", exp, preview[0])
+		}
+	})
+	img, err := h.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img) != 16*16 {
+		t.Fatalf("expected output to contain %d pixels; got %d", 16*16, len(img))
+	}
+	if samples := h.Stats().Samples; samples != 64 {
+		t.Fatalf("expected 64 samples; got %d", samples)
+	}
+	if len(fractions) != asyncRenderPasses {
+		t.Fatalf("expected %d progress callbacks; got %d", asyncRenderPasses, len(fractions))
+	}
+	for index := 1; index < len(fractions); index++ {
+		if fractions[index] <= fractions[index-1] {
+			t.Fatalf("expected progress to increase monotonically; got %v", fractions)
+		}
+	}
+	if last := fractions[len(fractions)-1]; last != 1 {
+		t.Fatalf("expected final progress to be 1.0; got %f", last)
+	}
+
+	// Throttled callbacks should still report completion
+	fractions = nil
+	h = render(context.Background(), time.Hour, func(fraction float32, _ []types.Vec3) {
+		fractions = append(fractions, fraction)
+	})
+	if _, err = h.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fractions) != 2 || fractions[1] != 1 {
+		t.Fatalf("expected the first and final progress callbacks to be invoked; got %v", fractions)
+	}
+
+	// Cancelled renders should report the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = render(ctx, 0, nil).Wait(); err != context.Canceled {
+		t.Fatalf("expected error %v; got %v", context.Canceled, err)
+	}
+}
@@ -1,8 +1,9 @@
 package renderer
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,13 @@ import (
 	"github.com/achilleasa/polaris/tracer/opencl/device"
 )
 
+// A block request that is dispatched to a tracer worker together with the
+// context of the render call that generated it.
+type renderJob struct {
+	ctx      context.Context
+	blockReq tracer.BlockRequest
+}
+
 type defaultRenderer struct {
 	logger log.Logger
 
@@ -25,7 +33,7 @@ type defaultRenderer struct {
 
 	// The list of registered tracers.
 	tracers         []tracer.Tracer
-	jobChans        []chan tracer.BlockRequest
+	jobChans        []chan renderJob
 	jobCompleteChan chan error
 
 	// The selected primary tracer.
@@ -37,6 +45,9 @@ type defaultRenderer struct {
 	// The block assignments generated by the scheduler
 	blockAssignments []uint32
 
+	// The random number generator for generating frame seeds.
+	rng tracer.RNG
+
 	// Renderer statistics.
 	stats FrameStats
 }
@@ -49,37 +60,108 @@ func NewDefault(sc *scene.Scene, scheduler tracer.BlockScheduler, pipeline *open
 		return nil, ErrCameraNotDefined
 	}
 
+	if err := checkSceneFeatures(sc); err != nil {
+		return nil, err
+	}
+
+	if err := opts.validateCropWindow(); err != nil {
+		return nil, err
+	}
+
+	if opts.RayEpsilon < 0 {
+		return nil, fmt.Errorf("renderer: ray epsilon must be >= 0; got %f", opts.RayEpsilon)
+	}
+
+	if opts.TargetError < 0 {
+		return nil, fmt.Errorf("renderer: target error must be >= 0; got %f", opts.TargetError)
+	} else if opts.TargetError > 0 && opts.MaxSamples == 0 {
+		return nil, fmt.Errorf("renderer: a max sample count is required when specifying a target error")
+	}
+
+	if err := tracer.ValidateSobolDimensionBudget(opts.SobolDimensions); err != nil {
+		return nil, err
+	}
+
+	if opts.RoughnessClamp < 0 || opts.RoughnessClamp > 1 {
+		return nil, fmt.Errorf("renderer: roughness clamp must be in the [0, 1] range; got %f", opts.RoughnessClamp)
+	}
+
+	if opts.FireflyThreshold > 0 && opts.FireflyRadius == 0 {
+		opts.FireflyRadius = tracer.DefaultFireflyRadius
+	}
+	if err := tracer.ValidateFireflyFilter(opts.FireflyThreshold, opts.FireflyRadius); err != nil {
+		return nil, err
+	}
+
+	if err := opts.WhiteBalance.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.PreviewScale < 0 || opts.PreviewScale >= 1 {
+		return nil, fmt.Errorf("renderer: preview scale must be in the [0, 1) range; got %f", opts.PreviewScale)
+	}
+
+	if opts.Workers == 0 {
+		opts.Workers = runtime.NumCPU()
+	} else if opts.Workers < 0 {
+		return nil, fmt.Errorf("renderer: worker count must be >= 1; got %d", opts.Workers)
+	}
+
+	if opts.RayBatchSize != 0 {
+		if err := tracer.ValidateRayBatchSize(opts.RayBatchSize); err != nil {
+			return nil, err
+		}
+	}
+
 	r := &defaultRenderer{
 		logger:    log.New("renderer"),
 		scheduler: scheduler,
 		options:   opts,
+		rng:       tracer.NewRNG(opts.RNG, tracer.FrameSeed(opts.Seed, opts.FrameIndex)),
 	}
 
 	err := r.initTracers(pipeline)
 	if err != nil {
 		return nil, err
 	}
-	r.jobChans = make([]chan tracer.BlockRequest, len(r.tracers))
+
+	// Queue state changes
+	for _, tr := range r.tracers {
+		tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{opts.FrameW, opts.FrameH})
+		tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+		tr.UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
+	}
+
+	r.startWorkers()
+
+	return r, nil
+}
+
+// Check that the scene does not use any features that the opencl tracer
+// cannot render.
+func checkSceneFeatures(sc *scene.Scene) error {
+	for instanceIndex, keys := range sc.InstanceKeyframes {
+		if len(keys) != 0 {
+			return fmt.Errorf("renderer: keyframed motion is not supported by the opencl tracer (mesh instance %d)", instanceIndex)
+		}
+	}
+	return nil
+}
+
+// Start a job worker for each attached tracer and wait for all of them to start.
+func (r *defaultRenderer) startWorkers() {
+	r.jobChans = make([]chan renderJob, len(r.tracers))
 	r.jobCompleteChan = make(chan error, 0)
 
-	// Start workers
 	r.workerInitGroup.Add(len(r.tracers))
 	r.workerCloseGroup.Add(len(r.tracers))
 	for trIndex := 0; trIndex < len(r.tracers); trIndex++ {
-		// Queue state changes
-		r.tracers[trIndex].UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{opts.FrameW, opts.FrameH})
-		r.tracers[trIndex].UpdateState(tracer.Synchronous, tracer.SceneData, sc)
-		r.tracers[trIndex].UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
-
-		// Start worker
-		r.jobChans[trIndex] = make(chan tracer.BlockRequest, 0)
+		r.jobChans[trIndex] = make(chan renderJob, 0)
 		go r.jobWorker(trIndex)
 	}
 
 	// wait for all workers to start
 	r.workerInitGroup.Wait()
-
-	return r, nil
 }
 
 // Get last frame stats.
@@ -96,24 +178,184 @@ func (r *defaultRenderer) Close() {
 	r.workerCloseGroup.Wait()
 }
 
-// Render next frame.
-func (r *defaultRenderer) Render() error {
-	return r.renderFrame(0)
+// Render next frame. If ctx is cancelled while the frame is being rendered,
+// Render waits for the tracers to abort their current block and returns
+// ctx.Err(). In that case the samples that each tracer completed before the
+// cancellation are merged into the frame accumulator of the primary tracer
+// and the per-tracer sample counts are reported by Stats().
+func (r *defaultRenderer) Render(ctx context.Context) error {
+	start := time.Now()
+	r.stats.RenderStats = RenderStats{}
+	defer func() {
+		r.stats.RenderStats.ElapsedTime = time.Since(start)
+	}()
+
+	if r.options.PreviewScale > 0 {
+		if err := r.renderPreview(ctx); err != nil {
+			return err
+		}
+	}
+
+	if r.options.TargetError > 0 {
+		return r.renderConverged(ctx)
+	}
+
+	err := r.renderFrame(ctx, 0)
+	if err == nil {
+		r.stats.Samples = r.passSamples()
+	}
+	return err
+}
+
+// Render the frame in passes until its estimated error drops to the target
+// error or the max sample count is reached. The primary tracer must be able
+// to read back its frame accumulator.
+func (r *defaultRenderer) renderConverged(ctx context.Context) error {
+	reader, ok := r.tracers[r.primary].(tracer.AccumulatorReader)
+	if !ok {
+		return fmt.Errorf("renderer: primary tracer %q does not support convergence checks", r.tracers[r.primary].Id())
+	}
+
+	start := time.Now()
+	var estimator tracer.ConvergenceEstimator
+	var samples uint32
+	for samples < r.options.MaxSamples && estimator.EstimatedError() > r.options.TargetError {
+		if err := r.renderFrame(ctx, samples); err != nil {
+			return err
+		}
+		samples += r.passSamples()
+
+		accumulator, err := reader.ReadAccumulator(&tracer.BlockRequest{FrameW: r.options.FrameW, FrameH: r.options.FrameH})
+		if err != nil {
+			return err
+		}
+		estimator.Update(accumulator, samples)
+	}
+
+	r.stats.RenderTime = time.Since(start)
+	r.stats.Samples = samples
+	r.stats.EstimatedError = estimator.EstimatedError()
+	r.logger.Noticef("rendered %d samples per pixel (max %d); estimated error %.4f", samples, r.options.MaxSamples, r.stats.EstimatedError)
+	return nil
+}
+
+// Render the frame at a reduced resolution and pass it to the preview handler.
+// The preview uses its own seed so it does not affect the random sequence of
+// the full resolution render; the latter is identical to a render without a
+// preview.
+func (r *defaultRenderer) renderPreview(ctx context.Context) error {
+	previewOpts := r.options.previewOptions()
+	if err := r.updateFrameDimensions(previewOpts.FrameW, previewOpts.FrameH); err != nil {
+		return err
+	}
+
+	previewSeed := tracer.NewRNG(r.options.RNG, ^tracer.FrameSeed(r.options.Seed, r.options.FrameIndex)).Uint32()
+	err := r.renderPass(ctx, &previewOpts, previewSeed, 0)
+	if err == nil {
+		r.logger.Noticef("rendered %dx%d preview in %s", previewOpts.FrameW, previewOpts.FrameH, r.stats.RenderTime)
+		err = r.emitPreview(&previewOpts)
+	}
+
+	// Always restore the frame dimensions
+	if resizeErr := r.updateFrameDimensions(r.options.FrameW, r.options.FrameH); err == nil {
+		err = resizeErr
+	}
+	return err
+}
+
+// Pass the rendered preview to the preview handler.
+func (r *defaultRenderer) emitPreview(previewOpts *Options) error {
+	if r.options.PreviewHandler == nil {
+		return nil
+	}
+
+	preview := Preview{
+		FrameW:  previewOpts.FrameW,
+		FrameH:  previewOpts.FrameH,
+		Samples: r.passSamples(),
+	}
+	if reader, ok := r.tracers[r.primary].(tracer.AccumulatorReader); ok {
+		var err error
+		preview.Accumulator, err = reader.ReadAccumulator(&tracer.BlockRequest{FrameW: preview.FrameW, FrameH: preview.FrameH})
+		if err != nil {
+			return err
+		}
+	}
+
+	r.options.PreviewHandler(preview)
+	return nil
+}
+
+// Update the frame dimensions for all tracers.
+func (r *defaultRenderer) updateFrameDimensions(frameW, frameH uint32) error {
+	for _, tr := range r.tracers {
+		if _, err := tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{frameW, frameH}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the number of samples per pixel that are taken by each render pass.
+func (r *defaultRenderer) passSamples() uint32 {
+	if r.options.SamplesPerPixel == 0 {
+		return 1
+	}
+	return r.options.SamplesPerPixel
 }
 
 // The actual frame implementation. This is intentionally split so it can be
 // used by the opengl renderer.
-func (r *defaultRenderer) renderFrame(accumulatedSamples uint32) error {
+func (r *defaultRenderer) renderFrame(ctx context.Context, accumulatedSamples uint32) error {
+	return r.renderPass(ctx, &r.options, r.rng.Uint32(), accumulatedSamples)
+}
+
+// Render a pass using the frame dimensions, crop window and tracing settings
+// from the supplied options.
+func (r *defaultRenderer) renderPass(ctx context.Context, opts *Options, seed, accumulatedSamples uint32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if opts.Sampler == tracer.SobolSampler {
+		r.stats.SamplerDimensions, r.stats.PaddedSamplerDimensions = tracer.SobolDimensionUsage(opts.NumBounces, opts.SobolDimensions)
+	}
+
+	crop := opts.CropWindow()
 	var blockReq = tracer.BlockRequest{
-		FrameW:             r.options.FrameW,
-		FrameH:             r.options.FrameH,
-		BlockW:             r.options.FrameW,
-		SamplesPerPixel:    r.options.SamplesPerPixel,
-		Exposure:           r.options.Exposure,
-		NumBounces:         r.options.NumBounces,
-		MinBouncesForRR:    r.options.MinBouncesForRR,
-		AccumulatedSamples: accumulatedSamples,
-		Seed:               rand.Uint32(),
+		FrameW:                  opts.FrameW,
+		FrameH:                  opts.FrameH,
+		BlockX:                  uint32(crop.Min.X),
+		BlockY:                  uint32(crop.Min.Y),
+		BlockW:                  uint32(crop.Dx()),
+		SamplesPerPixel:         opts.SamplesPerPixel,
+		Exposure:                opts.Exposure,
+		ColorSpace:              opts.ColorSpace,
+		WhiteBalance:            opts.WhiteBalance,
+		NumBounces:              opts.NumBounces,
+		MinBouncesForRR:         opts.MinBouncesForRR,
+		AccumulatedSamples:      accumulatedSamples,
+		Seed:                    seed,
+		RNG:                     opts.RNG,
+		Sampler:                 opts.Sampler,
+		SobolDimensions:         opts.SobolDimensions,
+		SubpixelPattern:         opts.SubpixelPattern,
+		CausticPhotons:          opts.CausticPhotons,
+		CausticRadius:           opts.CausticRadius,
+		BounceAOVDepth:          opts.BounceAOVDepth,
+		DirectClamp:             opts.DirectClamp,
+		IndirectClamp:           opts.IndirectClamp,
+		RoughnessClamp:          opts.RoughnessClamp,
+		MaxGlossyBounces:        opts.MaxGlossyBounces,
+		GlossyBudgetPolicy:      opts.GlossyBudgetPolicy,
+		ShadowTransparencyDepth: opts.ShadowTransparencyDepth,
+		FireflyThreshold:        opts.FireflyThreshold,
+		FireflyRadius:           opts.FireflyRadius,
+		RayBatchSize:            opts.RayBatchSize,
+		RayEpsilon:              opts.RayEpsilon,
+		NonFiniteGuard:          opts.NonFiniteGuard,
+		BackgroundMode:          opts.BackgroundMode,
+		CollectStats:            opts.CollectStats,
 	}
 
 	// If running in progressive mode we need to capture a single sample
@@ -123,14 +365,14 @@ func (r *defaultRenderer) renderFrame(accumulatedSamples uint32) error {
 
 	start := time.Now()
 
-	// Schedule blocks and process them in parallel
-	r.blockAssignments = r.scheduler.Schedule(r.tracers, blockReq.FrameH)
+	// Schedule blocks covering the crop window rows and process them in parallel
+	r.blockAssignments = r.scheduler.Schedule(r.tracers, uint32(crop.Dy()))
 	for trIndex, blockH := range r.blockAssignments {
 		blockReq.BlockH = blockH
-		r.jobChans[trIndex] <- blockReq
+		r.jobChans[trIndex] <- renderJob{ctx: ctx, blockReq: blockReq}
 
 		r.stats.Tracers[trIndex].BlockH = blockH
-		r.stats.Tracers[trIndex].FramePercent = 100.0 * float32(blockH) / float32(blockReq.FrameH)
+		r.stats.Tracers[trIndex].FramePercent = 100.0 * float32(blockH) / float32(crop.Dy())
 
 		blockReq.BlockY += blockH
 	}
@@ -140,7 +382,9 @@ func (r *defaultRenderer) renderFrame(accumulatedSamples uint32) error {
 		tot += bh
 	}
 
-	// Wait for all tracers to finish
+	// Wait for all tracers to finish. We always need to drain the completion
+	// channel, even if a tracer fails, so workers do not block forever.
+	var firstErr error
 	pending := len(r.tracers)
 	for pending != 0 {
 		err, ok := <-r.jobCompleteChan
@@ -148,24 +392,41 @@ func (r *defaultRenderer) renderFrame(accumulatedSamples uint32) error {
 			err = ErrInterrupted
 		}
 
-		if err != nil {
-			return err
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 
 		pending--
 	}
 
+	for trIndex, tr := range r.tracers {
+		r.stats.Tracers[trIndex].Samples = tr.Stats().Samples
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+
 	// Run post-process filters on the primary tracer
+	blockReq.BlockX = 0
 	blockReq.BlockY = 0
+	blockReq.BlockW = blockReq.FrameW
 	blockReq.BlockH = blockReq.FrameH
 	r.tracers[r.primary].SyncFramebuffer(&blockReq)
 
 	r.stats.RenderTime = time.Since(start)
 
 	// Collect stats
+	var nonFiniteSamples uint32
 	for trIndex, tr := range r.tracers {
 		r.stats.Tracers[trIndex].RenderTime = tr.Stats().RenderTime
+		nonFiniteSamples += tr.Stats().NonFiniteSamples
+		r.stats.RenderStats.Add(tr.Stats().Rays)
 	}
+	if nonFiniteSamples != 0 {
+		r.logger.Warningf("dropped %d non-finite samples", nonFiniteSamples)
+	}
+	r.stats.NonFiniteSamples += nonFiniteSamples
 
 	return nil
 }
@@ -180,15 +441,22 @@ func (r *defaultRenderer) jobWorker(trIndex int) {
 
 	for {
 		select {
-		case blockReq, ok := <-r.jobChans[trIndex]:
+		case job, ok := <-r.jobChans[trIndex]:
 			if !ok {
 				return
 			}
 
-			_, err := r.tracers[trIndex].Trace(&blockReq)
-			if err == nil {
-				// Merge trace accumulator output for this pass with primary tracer's frame accumulator
-				_, err = r.tracers[r.primary].MergeOutput(r.tracers[trIndex], &blockReq)
+			_, err := r.tracers[trIndex].Trace(job.ctx, &job.blockReq)
+
+			// Merge trace accumulator output for this pass with primary
+			// tracer's frame accumulator. If the pass was cancelled we
+			// still merge any samples that were completed before the
+			// tracer aborted so they can be retrieved by the caller.
+			cancelled := err != nil && err == job.ctx.Err()
+			if err == nil || (cancelled && r.tracers[trIndex].Stats().Samples > 0) {
+				if _, mergeErr := r.tracers[r.primary].MergeOutput(r.tracers[trIndex], &job.blockReq); mergeErr != nil {
+					err = mergeErr
+				}
 			}
 			r.jobCompleteChan <- err
 		}
@@ -208,18 +476,30 @@ func (r *defaultRenderer) initTracers(pipeline *opencl.Pipeline) error {
 
 	selectedDevices := make([]*device.Device, 0)
 	for _, platformInfo := range platforms {
-		for _, device := range platformInfo.Devices {
+		for _, dev := range platformInfo.Devices {
 			keep := true
 			for _, text := range r.options.BlackListedDevices {
-				if text != "" && strings.Contains(device.Name, text) {
+				if text != "" && strings.Contains(dev.Name, text) {
 					keep = false
 					break
 				}
 			}
 
-			if keep {
-				selectedDevices = append(selectedDevices, device)
+			if !keep {
+				continue
 			}
+
+			// Limit the number of cores used by CPU devices
+			if dev.Type == device.CpuDevice {
+				partition, err := dev.Partition(uint32(r.options.Workers))
+				if err != nil {
+					r.logger.Warningf("could not limit device %q to %d workers: %v", dev.Name, r.options.Workers, err)
+					continue
+				}
+				dev = partition
+			}
+
+			selectedDevices = append(selectedDevices, dev)
 		}
 	}
 
@@ -0,0 +1,740 @@
+package renderer
+
+import (
+	"context"
+	"image"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/log"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRenderCancellation(t *testing.T) {
+	r := &defaultRenderer{
+		logger:    log.New("renderer"),
+		scheduler: tracer.NaiveScheduler(),
+		options: Options{
+			FrameW:          16,
+			FrameH:          16,
+			SamplesPerPixel: 1000,
+		},
+		rng: tracer.NewRNG(tracer.PCG32, 0),
+		tracers: []tracer.Tracer{
+			makeMockTracer("mock-1"),
+			makeMockTracer("mock-2"),
+		},
+		stats: FrameStats{
+			Tracers: make([]TracerStat, 2),
+		},
+	}
+	r.startWorkers()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := r.Render(ctx)
+	if err != context.Canceled {
+		t.Fatalf("expected to get error %v; got %v", context.Canceled, err)
+	}
+
+	// Each mock sample takes 1ms so a full render would take at least 1s
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected Render to return promptly after cancellation; took %s", elapsed)
+	}
+
+	// Ensure that all workers exit
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for workers to exit")
+	}
+
+	// Rendering with an already cancelled context should fail immediately
+	err = r.Render(ctx)
+	if err != context.Canceled {
+		t.Fatalf("expected to get error %v; got %v", context.Canceled, err)
+	}
+}
+
+func TestRenderCancellationKeepsPartialSamples(t *testing.T) {
+	var frameW, frameH uint32 = 8, 8
+	r := &defaultRenderer{
+		logger:    log.New("renderer"),
+		scheduler: tracer.NaiveScheduler(),
+		options: Options{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			SamplesPerPixel: 1000,
+		},
+		rng: tracer.NewRNG(tracer.PCG32, 0),
+		tracers: []tracer.Tracer{
+			makeSlowAccumMockTracer("mock-1", frameW, frameH),
+			makeSlowAccumMockTracer("mock-2", frameW, frameH),
+		},
+		stats: FrameStats{
+			Tracers: make([]TracerStat, 2),
+		},
+	}
+	r.startWorkers()
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := r.Render(ctx)
+	if err != context.Canceled {
+		t.Fatalf("expected to get error %v; got %v", context.Canceled, err)
+	}
+
+	accumulator, err := r.tracers[r.primary].(*slowAccumMockTracer).ReadAccumulator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each pixel should contain the samples that were completed by the
+	// tracer that rendered its block before the render was cancelled.
+	var blockY uint32
+	for trIndex, blockH := range r.blockAssignments {
+		samples := r.Stats().Tracers[trIndex].Samples
+		if samples == 0 || samples >= r.options.SamplesPerPixel {
+			t.Fatalf("[tracer %d] expected tracer to complete a partial pass; got %d samples", trIndex, samples)
+		}
+
+		for index := blockY * frameW; index < (blockY+blockH)*frameW; index++ {
+			if accumulator[index] != float32(samples) {
+				t.Fatalf("[tracer %d] expected accumulated value at pixel %d to be %d; got %f", trIndex, index, samples, accumulator[index])
+			}
+		}
+		blockY += blockH
+	}
+}
+
+func TestFrameIndexSeed(t *testing.T) {
+	renderSeed := func(frameIndex uint32) uint32 {
+		mt := makeMockTracer("mock")
+		r := &defaultRenderer{
+			logger:    log.New("renderer"),
+			scheduler: tracer.NaiveScheduler(),
+			options: Options{
+				FrameW:          16,
+				FrameH:          16,
+				SamplesPerPixel: 1,
+				Seed:            42,
+				FrameIndex:      frameIndex,
+			},
+			rng:     tracer.NewRNG(tracer.PCG32, tracer.FrameSeed(42, frameIndex)),
+			tracers: []tracer.Tracer{mt},
+			stats: FrameStats{
+				Tracers: make([]TracerStat, 1),
+			},
+		}
+		r.startWorkers()
+		defer r.Close()
+
+		if err := r.Render(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return mt.lastSeed
+	}
+
+	// The tracer kernels derive per-pixel random sequences from the block
+	// request seed so distinct seeds yield distinct noise patterns.
+	seed0, seed1 := renderSeed(0), renderSeed(1)
+	if seed0 == seed1 {
+		t.Fatalf("expected frames 0 and 1 to use different seeds; got %d for both", seed0)
+	}
+
+	if seed := renderSeed(1); seed != seed1 {
+		t.Fatalf("expected frame seed to be reproducible; got %d and %d", seed1, seed)
+	}
+
+	// Frame 0 should match the seed used when no frame index is specified
+	if exp := tracer.NewRNG(tracer.PCG32, 42).Uint32(); seed0 != exp {
+		t.Fatalf("expected frame 0 to use seed %d; got %d", exp, seed0)
+	}
+}
+
+func TestCropWindow(t *testing.T) {
+	frame := make([]float32, 16*16)
+	var mu sync.Mutex
+	newTracer := func(id string) tracer.Tracer {
+		return &cropMockTracer{
+			mockTracer: makeMockTracer(id),
+			frame:      frame,
+			mu:         &mu,
+		}
+	}
+
+	r := &defaultRenderer{
+		logger:    log.New("renderer"),
+		scheduler: tracer.NaiveScheduler(),
+		options: Options{
+			FrameW:          16,
+			FrameH:          16,
+			SamplesPerPixel: 1,
+			CropX:           3,
+			CropY:           5,
+			CropW:           6,
+			CropH:           7,
+		},
+		rng:     tracer.NewRNG(tracer.PCG32, 0),
+		tracers: []tracer.Tracer{newTracer("mock-1"), newTracer("mock-2")},
+		stats: FrameStats{
+			Tracers: make([]TracerStat, 2),
+		},
+	}
+	r.startWorkers()
+	defer r.Close()
+
+	if err := r.Render(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	crop := r.options.CropWindow()
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			inside := image.Pt(x, y).In(crop)
+			value := frame[y*16+x]
+			if inside && value != 1 {
+				t.Fatalf("expected pixel (%d, %d) inside the crop window to be traced once; got %f", x, y, value)
+			} else if !inside && value != 0 {
+				t.Fatalf("expected pixel (%d, %d) outside the crop window to be zero; got %f", x, y, value)
+			}
+		}
+	}
+}
+
+func TestValidateCropWindow(t *testing.T) {
+	type spec struct {
+		opts     Options
+		expError bool
+	}
+	specs := []spec{
+		spec{Options{FrameW: 16, FrameH: 16}, false},
+		spec{Options{FrameW: 16, FrameH: 16, CropX: 4, CropY: 4, CropW: 12, CropH: 12}, false},
+		spec{Options{FrameW: 16, FrameH: 16, CropX: 5, CropY: 4, CropW: 12, CropH: 12}, true},
+		spec{Options{FrameW: 16, FrameH: 16, CropX: 0, CropY: 1, CropW: 16, CropH: 16}, true},
+	}
+
+	for index, s := range specs {
+		err := s.opts.validateCropWindow()
+		if s.expError && err == nil {
+			t.Errorf("[spec %d] expected crop window %v to be rejected", index, s.opts.CropWindow())
+		} else if !s.expError && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+}
+
+func TestCheckSceneFeatures(t *testing.T) {
+	keys := []scene.TransformKeyframe{
+		{Time: 0, Transform: types.Ident4()},
+		{Time: 1, Transform: types.Ident4()},
+	}
+
+	type spec struct {
+		sc       *scene.Scene
+		expError bool
+	}
+	specs := []spec{
+		spec{&scene.Scene{}, false},
+		// Instances without keyframes are static
+		spec{&scene.Scene{InstanceKeyframes: [][]scene.TransformKeyframe{nil, nil}}, false},
+		spec{&scene.Scene{InstanceKeyframes: [][]scene.TransformKeyframe{nil, keys}}, true},
+	}
+
+	for index, s := range specs {
+		err := checkSceneFeatures(s.sc)
+		if s.expError && err == nil {
+			t.Errorf("[spec %d] expected scene to be rejected", index)
+		} else if !s.expError && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+}
+
+func TestEarlyConvergence(t *testing.T) {
+	newRenderer := func(tr tracer.Tracer) *defaultRenderer {
+		r := &defaultRenderer{
+			logger:    log.New("renderer"),
+			scheduler: tracer.NaiveScheduler(),
+			options: Options{
+				FrameW:          16,
+				FrameH:          16,
+				SamplesPerPixel: 4,
+				MaxSamples:      1024,
+				TargetError:     0.01,
+			},
+			rng:     tracer.NewRNG(tracer.PCG32, 0),
+			tracers: []tracer.Tracer{tr},
+			stats: FrameStats{
+				Tracers: make([]TracerStat, 1),
+			},
+		}
+		r.startWorkers()
+		return r
+	}
+
+	// A constant image converges after the second pass
+	mt := &constMockTracer{mockTracer: makeMockTracer("mock"), frame: make([]float32, 4*16*16)}
+	r := newRenderer(mt)
+	defer r.Close()
+	if err := r.Render(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := r.Stats(); stats.Samples != 8 || stats.EstimatedError != 0 {
+		t.Fatalf("expected render to stop after 8 samples with 0 error; got %d samples and error %f", stats.Samples, stats.EstimatedError)
+	}
+
+	// The primary tracer must be able to read back its accumulator
+	r = newRenderer(makeMockTracer("mock"))
+	defer r.Close()
+	if err := r.Render(context.Background()); err == nil {
+		t.Fatal("expected render to fail when the primary tracer cannot read back its accumulator")
+	}
+}
+
+func TestPreviewRender(t *testing.T) {
+	render := func(previewScale float32, handler func(Preview)) []*previewMockTracer {
+		tracers := []*previewMockTracer{
+			makePreviewMockTracer("mock-1", 16, 16),
+			makePreviewMockTracer("mock-2", 16, 16),
+		}
+		r := &defaultRenderer{
+			logger:    log.New("renderer"),
+			scheduler: tracer.NaiveScheduler(),
+			options: Options{
+				FrameW:          16,
+				FrameH:          16,
+				SamplesPerPixel: 4,
+				Seed:            42,
+				PreviewScale:    previewScale,
+				PreviewHandler:  handler,
+			},
+			rng:     tracer.NewRNG(tracer.PCG32, 42),
+			tracers: []tracer.Tracer{tracers[0], tracers[1]},
+			stats: FrameStats{
+				Tracers: make([]TracerStat, 2),
+			},
+		}
+		r.startWorkers()
+		defer r.Close()
+
+		if err := r.Render(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return tracers
+	}
+
+	var previews []Preview
+	got := render(0.25, func(p Preview) { previews = append(previews, p) })
+	if len(previews) != 1 {
+		t.Fatalf("expected preview handler to be invoked once; got %d", len(previews))
+	}
+	if p := previews[0]; p.FrameW != 4 || p.FrameH != 4 || len(p.Accumulator) != 4*4 || p.Samples != 4 {
+		t.Fatalf("expected a 4x4 preview with 4 samples; got %dx%d with %d samples and %d accumulator entries", p.FrameW, p.FrameH, p.Samples, len(p.Accumulator))
+	}
+	for _, tr := range got {
+		if tr.frameW != 16 || tr.frameH != 16 {
+			t.Fatalf("expected tracer %q frame dimensions to be restored to 16x16; got %dx%d", tr.id, tr.frameW, tr.frameH)
+		}
+	}
+
+	// The full resolution output should match a render without a preview
+	exp := render(0, nil)
+	for index := range exp[0].frameAccumulator {
+		if math.Float32bits(got[0].frameAccumulator[index]) != math.Float32bits(exp[0].frameAccumulator[index]) {
+			t.Fatalf("expected pixel %d to be %v; got %v", index, exp[0].frameAccumulator[index], got[0].frameAccumulator[index])
+		}
+	}
+}
+
+func TestPreviewOptions(t *testing.T) {
+	type spec struct {
+		opts    Options
+		expW    uint32
+		expH    uint32
+		expCrop image.Rectangle
+	}
+	specs := []spec{
+		spec{Options{FrameW: 1024, FrameH: 512, PreviewScale: 0.25}, 256, 128, image.Rect(0, 0, 256, 128)},
+		spec{Options{FrameW: 15, FrameH: 2, PreviewScale: 0.1}, 2, 1, image.Rect(0, 0, 2, 1)},
+		spec{Options{FrameW: 16, FrameH: 16, PreviewScale: 0.5, CropX: 3, CropY: 5, CropW: 6, CropH: 7}, 8, 8, image.Rect(1, 2, 4, 6)},
+		spec{Options{FrameW: 16, FrameH: 16, PreviewScale: 0.25, CropX: 15, CropY: 15, CropW: 1, CropH: 1}, 4, 4, image.Rect(3, 3, 4, 4)},
+	}
+
+	for index, s := range specs {
+		previewOpts := s.opts.previewOptions()
+		if previewOpts.FrameW != s.expW || previewOpts.FrameH != s.expH {
+			t.Errorf("[spec %d] expected preview dimensions to be %dx%d; got %dx%d", index, s.expW, s.expH, previewOpts.FrameW, previewOpts.FrameH)
+		}
+		if crop := previewOpts.CropWindow(); crop != s.expCrop {
+			t.Errorf("[spec %d] expected preview crop window to be %v; got %v", index, s.expCrop, crop)
+		}
+		if err := previewOpts.validateCropWindow(); err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+}
+
+func TestNonFiniteSampleStats(t *testing.T) {
+	tracers := []*mockTracer{makeMockTracer("mock-1"), makeMockTracer("mock-2")}
+	r := &defaultRenderer{
+		logger:    log.New("renderer"),
+		scheduler: tracer.NaiveScheduler(),
+		options: Options{
+			FrameW:          16,
+			FrameH:          16,
+			SamplesPerPixel: 1,
+		},
+		rng:     tracer.NewRNG(tracer.PCG32, 0),
+		tracers: []tracer.Tracer{tracers[0], tracers[1]},
+		stats: FrameStats{
+			Tracers: make([]TracerStat, 2),
+		},
+	}
+	r.startWorkers()
+	defer r.Close()
+
+	// The tracers report the number of dropped samples for each block
+	tracers[0].stats.NonFiniteSamples = 2
+	tracers[1].stats.NonFiniteSamples = 3
+	for frame := 0; frame < 2; frame++ {
+		if err := r.Render(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := r.Stats().NonFiniteSamples; got != 10 {
+		t.Fatalf("expected 10 dropped non-finite samples to be reported; got %d", got)
+	}
+}
+
+func TestRenderStats(t *testing.T) {
+	type spec struct {
+		collect bool
+		spp     uint32
+	}
+	specs := []spec{
+		spec{false, 4},
+		spec{true, 1},
+		spec{true, 4},
+	}
+
+	for index, s := range specs {
+		tracers := []*statsMockTracer{
+			&statsMockTracer{mockTracer: makeMockTracer("mock-1")},
+			&statsMockTracer{mockTracer: makeMockTracer("mock-2")},
+		}
+		r := &defaultRenderer{
+			logger:    log.New("renderer"),
+			scheduler: tracer.NaiveScheduler(),
+			options: Options{
+				FrameW:          16,
+				FrameH:          8,
+				SamplesPerPixel: s.spp,
+				NumBounces:      3,
+				CollectStats:    s.collect,
+			},
+			rng:     tracer.NewRNG(tracer.PCG32, 0),
+			tracers: []tracer.Tracer{tracers[0], tracers[1]},
+			stats: FrameStats{
+				Tracers: make([]TracerStat, 2),
+			},
+		}
+		r.startWorkers()
+
+		// Stats should be reset between frames
+		for frame := 0; frame < 2; frame++ {
+			if err := r.Render(context.Background()); err != nil {
+				t.Fatalf("[spec %d] unexpected error: %v", index, err)
+			}
+		}
+		r.Close()
+
+		stats := r.Stats().RenderStats
+		if stats.ElapsedTime <= 0 {
+			t.Errorf("[spec %d] expected elapsed time to be > 0", index)
+		}
+
+		if !s.collect {
+			if stats.RayStats != (tracer.RayStats{}) {
+				t.Errorf("[spec %d] expected ray stats to be zero when collection is disabled; got %+v", index, stats.RayStats)
+			}
+			continue
+		}
+
+		paths := uint64(16 * 8 * s.spp)
+		exp := tracer.RayStats{
+			PrimaryRays:   paths,
+			ShadowRays:    3 * paths,
+			BounceRays:    2 * paths,
+			BvhNodeVisits: 6 * 10 * paths,
+			TriangleTests: 6 * 4 * paths,
+		}
+		if stats.RayStats != exp {
+			t.Errorf("[spec %d] expected ray stats to be %+v; got %+v", index, exp, stats.RayStats)
+		}
+		if stats.TotalRays() != 6*paths {
+			t.Errorf("[spec %d] expected %d total rays; got %d", index, 6*paths, stats.TotalRays())
+		}
+		if stats.RaysPerSecond() <= 0 {
+			t.Errorf("[spec %d] expected rays per second to be > 0", index)
+		}
+	}
+}
+
+// A mock tracer that reports ray stats for a path tracer that traces a shadow
+// ray per bounce and a bounce ray for all but the last bounce.
+type statsMockTracer struct {
+	*mockTracer
+}
+
+func (mt *statsMockTracer) Trace(ctx context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	mt.stats.Rays = tracer.RayStats{}
+	if blockReq.CollectStats {
+		paths := uint64(blockReq.BlockW * blockReq.BlockH * blockReq.SamplesPerPixel)
+		mt.stats.Rays.PrimaryRays = paths
+		mt.stats.Rays.ShadowRays = paths * uint64(blockReq.NumBounces)
+		mt.stats.Rays.BounceRays = paths * uint64(blockReq.NumBounces-1)
+		mt.stats.Rays.BvhNodeVisits = 10 * mt.stats.Rays.TotalRays()
+		mt.stats.Rays.TriangleTests = 4 * mt.stats.Rays.TotalRays()
+	}
+	return mt.mockTracer.Trace(ctx, blockReq)
+}
+
+// A mock tracer that accumulates a constant radiance value for each sample.
+type constMockTracer struct {
+	*mockTracer
+	frame []float32
+}
+
+func (mt *constMockTracer) Trace(_ context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	if blockReq.AccumulatedSamples == 0 {
+		for index := range mt.frame {
+			mt.frame[index] = 0
+		}
+	}
+	for y := blockReq.BlockY; y < blockReq.BlockY+blockReq.BlockH; y++ {
+		for x := blockReq.BlockX; x < blockReq.BlockX+blockReq.BlockW; x++ {
+			for c := uint32(0); c < 3; c++ {
+				mt.frame[4*(y*blockReq.FrameW+x)+c] += 0.25 * float32(blockReq.SamplesPerPixel)
+			}
+		}
+	}
+	return 0, nil
+}
+
+func (mt *constMockTracer) ReadAccumulator(_ *tracer.BlockRequest) ([]float32, error) {
+	return append([]float32(nil), mt.frame...), nil
+}
+
+// A mock tracer that follows the same sampling contract as the opencl tracer:
+// per-sample seeds are derived from the block seed and random samples are
+// keyed on the frame pixel index.
+type accumMockTracer struct {
+	*mockTracer
+	traceAccumulator []float32
+	frameAccumulator []float32
+}
+
+func makeAccumMockTracer(id string, frameW, frameH uint32) *accumMockTracer {
+	return &accumMockTracer{
+		mockTracer:       makeMockTracer(id),
+		traceAccumulator: make([]float32, frameW*frameH),
+		frameAccumulator: make([]float32, frameW*frameH),
+	}
+}
+
+func (mt *accumMockTracer) Trace(_ context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	rng := tracer.NewRNG(blockReq.RNG, uint64(blockReq.Seed))
+	for y := blockReq.BlockY; y < blockReq.BlockY+blockReq.BlockH; y++ {
+		for x := blockReq.BlockX; x < blockReq.BlockX+blockReq.BlockW; x++ {
+			mt.traceAccumulator[y*blockReq.FrameW+x] = 0
+		}
+	}
+	for sample := uint32(0); sample < blockReq.SamplesPerPixel; sample++ {
+		sampleSeed := rng.Uint32()
+		for y := blockReq.BlockY; y < blockReq.BlockY+blockReq.BlockH; y++ {
+			for x := blockReq.BlockX; x < blockReq.BlockX+blockReq.BlockW; x++ {
+				pixelIndex := y*blockReq.FrameW + x
+				pixelRng := tracer.NewRNG(blockReq.RNG, uint64(sampleSeed)<<32|uint64(pixelIndex))
+				mt.traceAccumulator[pixelIndex] += pixelRng.Float32() * 0.1
+			}
+		}
+	}
+	return 0, nil
+}
+
+func (mt *accumMockTracer) MergeOutput(other tracer.Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	src := other.(*accumMockTracer)
+	from := blockReq.FrameW * blockReq.BlockY
+	to := from + blockReq.FrameW*blockReq.BlockH
+	for index := from; index < to; index++ {
+		mt.frameAccumulator[index] += src.traceAccumulator[index]
+	}
+	return 0, nil
+}
+
+// A mock tracer that reallocates its accumulators when the frame dimensions
+// change and can read back its frame accumulator.
+type previewMockTracer struct {
+	*accumMockTracer
+	frameW, frameH uint32
+}
+
+func makePreviewMockTracer(id string, frameW, frameH uint32) *previewMockTracer {
+	return &previewMockTracer{
+		accumMockTracer: makeAccumMockTracer(id, frameW, frameH),
+		frameW:          frameW,
+		frameH:          frameH,
+	}
+}
+
+func (mt *previewMockTracer) UpdateState(_ tracer.UpdateMode, changeType tracer.ChangeType, data interface{}) (time.Duration, error) {
+	if dims, ok := data.([2]uint32); ok && changeType == tracer.FrameDimensions && (dims[0] != mt.frameW || dims[1] != mt.frameH) {
+		mt.frameW, mt.frameH = dims[0], dims[1]
+		mt.traceAccumulator = make([]float32, mt.frameW*mt.frameH)
+		mt.frameAccumulator = make([]float32, mt.frameW*mt.frameH)
+	}
+	return 0, nil
+}
+
+func (mt *previewMockTracer) MergeOutput(other tracer.Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	return mt.accumMockTracer.MergeOutput(other.(*previewMockTracer).accumMockTracer, blockReq)
+}
+
+func (mt *previewMockTracer) ReadAccumulator(_ *tracer.BlockRequest) ([]float32, error) {
+	return append([]float32(nil), mt.frameAccumulator...), nil
+}
+
+// A mock tracer that accumulates a unit value per sample and aborts between
+// samples if its context is cancelled.
+type slowAccumMockTracer struct {
+	*accumMockTracer
+}
+
+func makeSlowAccumMockTracer(id string, frameW, frameH uint32) *slowAccumMockTracer {
+	return &slowAccumMockTracer{
+		accumMockTracer: makeAccumMockTracer(id, frameW, frameH),
+	}
+}
+
+func (mt *slowAccumMockTracer) Trace(ctx context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	from := blockReq.FrameW * blockReq.BlockY
+	to := from + blockReq.FrameW*blockReq.BlockH
+	for index := from; index < to; index++ {
+		mt.traceAccumulator[index] = 0
+	}
+
+	mt.stats.Samples = 0
+	for sample := uint32(0); sample < blockReq.SamplesPerPixel; sample++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		for index := from; index < to; index++ {
+			mt.traceAccumulator[index]++
+		}
+		mt.stats.Samples++
+		time.Sleep(time.Millisecond)
+	}
+	return 0, nil
+}
+
+func (mt *slowAccumMockTracer) MergeOutput(other tracer.Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	return mt.accumMockTracer.MergeOutput(other.(*slowAccumMockTracer).accumMockTracer, blockReq)
+}
+
+func (mt *slowAccumMockTracer) ReadAccumulator(_ *tracer.BlockRequest) ([]float32, error) {
+	return append([]float32(nil), mt.frameAccumulator...), nil
+}
+
+// A mock tracer that marks the pixels covered by its block requests.
+type cropMockTracer struct {
+	*mockTracer
+	frame []float32
+	mu    *sync.Mutex
+}
+
+func (mt *cropMockTracer) Trace(_ context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	for y := blockReq.BlockY; y < blockReq.BlockY+blockReq.BlockH; y++ {
+		for x := blockReq.BlockX; x < blockReq.BlockX+blockReq.BlockW; x++ {
+			mt.frame[y*blockReq.FrameW+x]++
+		}
+	}
+	return 0, nil
+}
+
+type mockTracer struct {
+	id       string
+	stats    *tracer.Stats
+	lastSeed uint32
+}
+
+func makeMockTracer(id string) *mockTracer {
+	return &mockTracer{
+		id:    id,
+		stats: &tracer.Stats{},
+	}
+}
+
+func (mt *mockTracer) Id() string {
+	return mt.id
+}
+
+func (mt *mockTracer) Flags() tracer.Flag {
+	return tracer.Local
+}
+
+func (mt *mockTracer) Speed() uint32 {
+	return 1
+}
+
+func (mt *mockTracer) Init() error {
+	return nil
+}
+
+func (mt *mockTracer) Close() {
+}
+
+func (mt *mockTracer) Stats() *tracer.Stats {
+	return mt.stats
+}
+
+func (mt *mockTracer) UpdateState(_ tracer.UpdateMode, _ tracer.ChangeType, _ interface{}) (time.Duration, error) {
+	return 0, nil
+}
+
+func (mt *mockTracer) Trace(ctx context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	mt.lastSeed = blockReq.Seed
+	start := time.Now()
+	for sample := uint32(0); sample < blockReq.SamplesPerPixel; sample++ {
+		if err := ctx.Err(); err != nil {
+			return time.Since(start), err
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return time.Since(start), nil
+}
+
+func (mt *mockTracer) MergeOutput(_ tracer.Tracer, _ *tracer.BlockRequest) (time.Duration, error) {
+	return 0, nil
+}
+
+func (mt *mockTracer) SyncFramebuffer(_ *tracer.BlockRequest) (time.Duration, error) {
+	return 0, nil
+}
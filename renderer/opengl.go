@@ -1,6 +1,7 @@
 package renderer
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -133,8 +134,12 @@ func (r *interactiveGLRenderer) initGL(opts Options) error {
 	return nil
 }
 
-func (r *interactiveGLRenderer) Render() error {
+func (r *interactiveGLRenderer) Render(ctx context.Context) error {
 	for !r.window.ShouldClose() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		glfw.PollEvents()
 
 		// Render next frame
@@ -142,7 +147,7 @@ func (r *interactiveGLRenderer) Render() error {
 
 		// Render frame unless we have reached our target SPP
 		if r.options.SamplesPerPixel == 0 || (r.options.SamplesPerPixel != 0 && r.accumulatedSamples < r.defaultRenderer.options.SamplesPerPixel) {
-			err := r.renderFrame(r.accumulatedSamples)
+			err := r.renderFrame(ctx, r.accumulatedSamples)
 			if r.options.SamplesPerPixel == 0 {
 				r.accumulatedSamples++
 			} else {
@@ -1,5 +1,28 @@
 package renderer
 
+import (
+	"fmt"
+	"image"
+	"math"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+// A low resolution preview of a frame.
+type Preview struct {
+	// The preview dimensions.
+	FrameW uint32
+	FrameH uint32
+
+	// The number of samples per pixel and the accumulated radiance sums
+	// stored as RGBA quadruplets. The accumulator is nil if the primary
+	// tracer cannot read back its frame accumulator.
+	Samples     uint32
+	Accumulator []float32
+}
+
 type Options struct {
 	// Frame dims.
 	FrameW uint32
@@ -17,7 +40,171 @@ type Options struct {
 	// Exposure for tonemapping.
 	Exposure float32
 
+	// The color space of the rendered output.
+	ColorSpace tracer.ColorSpace
+
+	// White balance applied before tone-mapping; see tracer.WhiteBalance.
+	WhiteBalance tracer.WhiteBalance
+
+	// The random number generator algorithm and its seed.
+	RNG  tracer.RNGType
+	Seed uint64
+
+	// The index of the rendered frame when rendering animations. The frame
+	// index is combined with the seed so that noise patterns are
+	// decorrelated across frames.
+	FrameIndex uint32
+
+	// The sampler for generating camera and bounce samples.
+	Sampler tracer.SamplerType
+
+	// The max number of sobol dimensions used by each path; see
+	// tracer.BlockRequest. If set to 0, tracer.SobolMaxDimensions is used.
+	SobolDimensions uint32
+
+	// The placement of primary ray samples inside each pixel.
+	SubpixelPattern tracer.SubpixelPattern
+
+	// Caustic photon map settings. The caustics pass is disabled if
+	// CausticPhotons is 0.
+	CausticPhotons uint32
+	CausticRadius  float32
+
+	// The number of bounce depths to capture into separate radiance
+	// buffers. Bounce capturing is disabled if set to 0.
+	BounceAOVDepth uint32
+
+	// Clamp thresholds for direct and indirect radiance contributions.
+	// Each clamp is disabled if set to 0.
+	DirectClamp   float32
+	IndirectClamp float32
+
+	// The min roughness of glossy bxdfs after the first diffuse bounce;
+	// see tracer.BlockRequest. Regularization is disabled if set to 0.
+	RoughnessClamp float32
+
+	// The glossy bounce budget for each path; see tracer.BlockRequest.
+	// The budget is disabled if MaxGlossyBounces is 0.
+	MaxGlossyBounces   uint32
+	GlossyBudgetPolicy material.GlossyBudgetPolicy
+
+	// The max number of transparent surfaces that shadow rays can pass
+	// through; see tracer.BlockRequest. If set to 0, transparent surfaces
+	// cast opaque shadows.
+	ShadowTransparencyDepth uint32
+
+	// Firefly filter settings; see tracer.BlockRequest. The filter is
+	// disabled if FireflyThreshold is 0. If FireflyRadius is 0,
+	// tracer.DefaultFireflyRadius is used.
+	FireflyThreshold float32
+	FireflyRadius    uint32
+
+	// The max number of rays processed by each intersection kernel
+	// dispatch. Must be a power of two; if set to 0 the tracer uses
+	// tracer.DefaultRayBatchSize.
+	RayBatchSize uint32
+
+	// The ray epsilon for offsetting secondary ray origins. If set to 0,
+	// the epsilon is derived from the scene UnitsPerMeter value.
+	RayEpsilon float32
+
+	// The policy for handling samples with NaN or Inf components. The
+	// default policy drops such samples and reports their count in the
+	// frame stats.
+	NonFiniteGuard tracer.NonFiniteGuard
+
+	// Controls how the scene background is written to the frame. With a
+	// transparent background, the alpha of each pixel is the fraction of
+	// its primary rays that hit the scene so the frame can be composited
+	// over a plate.
+	BackgroundMode tracer.BackgroundMode
+
+	// Collect ray and BVH traversal counters while rendering and report
+	// them in the frame stats. Collection requires additional device
+	// synchronization so it is disabled by default.
+	CollectStats bool
+
+	// Early convergence settings. If TargetError is greater than 0, Render
+	// accumulates passes of SamplesPerPixel samples until the estimated
+	// relative image error drops to TargetError or at least MaxSamples
+	// samples per pixel have been taken.
+	MaxSamples  uint32
+	TargetError float32
+
+	// Progressive preview settings. If PreviewScale is greater than 0,
+	// Render first renders the frame at PreviewScale times the frame
+	// resolution and passes the result to PreviewHandler (if defined)
+	// before rendering the frame at full resolution.
+	PreviewScale   float32
+	PreviewHandler func(Preview)
+
+	// The min interval between progress callbacks for renders started via
+	// RenderAsync. If set to 0, DefaultProgressInterval is used.
+	ProgressInterval time.Duration
+
+	// Restrict rendering to a crop window with its top-left corner at
+	// (CropX, CropY). Pixels outside the window are not traced and remain
+	// black. Cropping is disabled if either CropW or CropH is 0.
+	CropX, CropY uint32
+	CropW, CropH uint32
+
+	// The max number of CPU cores used by opencl CPU devices. CPU devices
+	// with more compute units are partitioned into a sub-device with
+	// Workers compute units. If set to 0, runtime.NumCPU() is used.
+	Workers int
+
 	// Device selection.
 	BlackListedDevices []string
 	ForcePrimaryDevice string
 }
+
+// Get the crop window as a rectangle in frame coordinates. If cropping is
+// disabled, the returned rectangle covers the entire frame.
+func (opts *Options) CropWindow() image.Rectangle {
+	if opts.CropW == 0 || opts.CropH == 0 {
+		return image.Rect(0, 0, int(opts.FrameW), int(opts.FrameH))
+	}
+	return image.Rect(int(opts.CropX), int(opts.CropY), int(opts.CropX+opts.CropW), int(opts.CropY+opts.CropH))
+}
+
+// Get the options for rendering a preview of the frame at PreviewScale times
+// the frame resolution. The crop window, if defined, is scaled so that it
+// covers the same frame region.
+func (opts *Options) previewOptions() Options {
+	scaleDim := func(v uint32) uint32 {
+		scaled := uint32(math.Ceil(float64(v) * float64(opts.PreviewScale)))
+		if scaled == 0 {
+			scaled = 1
+		}
+		return scaled
+	}
+
+	previewOpts := *opts
+	previewOpts.FrameW = scaleDim(opts.FrameW)
+	previewOpts.FrameH = scaleDim(opts.FrameH)
+	if opts.CropW != 0 && opts.CropH != 0 {
+		previewOpts.CropX = uint32(float64(opts.CropX) * float64(opts.PreviewScale))
+		previewOpts.CropY = uint32(float64(opts.CropY) * float64(opts.PreviewScale))
+		previewOpts.CropW = minUint32(scaleDim(opts.CropW), previewOpts.FrameW-previewOpts.CropX)
+		previewOpts.CropH = minUint32(scaleDim(opts.CropH), previewOpts.FrameH-previewOpts.CropY)
+	}
+	return previewOpts
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Ensure that the crop window lies within the frame.
+func (opts *Options) validateCropWindow() error {
+	if opts.CropW == 0 || opts.CropH == 0 {
+		return nil
+	}
+	if opts.CropX+opts.CropW > opts.FrameW || opts.CropY+opts.CropH > opts.FrameH {
+		return fmt.Errorf("renderer: crop window %v exceeds the frame dimensions (%dx%d)", opts.CropWindow(), opts.FrameW, opts.FrameH)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl"
+)
+
+// The number of channels per pixel written by RenderInto. Pixels are stored
+// in row-major order starting from the top-left corner of the frame; each
+// pixel occupies 3 consecutive floats with its mean R, G and B radiance.
+const RenderIntoChannels = 3
+
+// Render a frame of sc as seen by cam into dst, blocking until the render
+// completes. If cam is nil, the scene camera is used instead. The length of
+// dst must be exactly opts.FrameW * opts.FrameH * RenderIntoChannels; see
+// RenderIntoChannels for the pixel layout.
+//
+// Unlike RenderAsync, RenderInto writes the mean radiance into the caller
+// supplied dst instead of returning a new image and supports both previews
+// and early convergence. Each call still sets up a new renderer and reads
+// back a copy of the RGBA frame accumulator.
+func RenderInto(dst []float32, sc *scene.Scene, cam *scene.Camera, opts Options) error {
+	if err := checkRenderIntoBuffer(dst, opts.FrameW, opts.FrameH); err != nil {
+		return err
+	}
+
+	if sc != nil && cam != nil {
+		scCopy := *sc
+		scCopy.Camera = cam
+		sc = &scCopy
+	}
+
+	r, err := NewDefault(sc, tracer.NaiveScheduler(), opencl.DefaultPipeline(opencl.NoDebug), opts)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.(*defaultRenderer).renderInto(context.Background(), dst)
+}
+
+// Render the frame and write the mean radiance of each pixel into dst. The
+// primary tracer must be able to read back its frame accumulator.
+func (r *defaultRenderer) renderInto(ctx context.Context, dst []float32) error {
+	if err := checkRenderIntoBuffer(dst, r.options.FrameW, r.options.FrameH); err != nil {
+		return err
+	}
+
+	reader, ok := r.tracers[r.primary].(tracer.AccumulatorReader)
+	if !ok {
+		return fmt.Errorf("renderer: primary tracer %q does not support reading back the rendered frame", r.tracers[r.primary].Id())
+	}
+
+	if err := r.Render(ctx); err != nil {
+		return err
+	}
+
+	accumulator, err := reader.ReadAccumulator(&tracer.BlockRequest{FrameW: r.options.FrameW, FrameH: r.options.FrameH})
+	if err != nil {
+		return err
+	}
+	writeMeanRadiance(dst, accumulator, r.stats.Samples)
+	return nil
+}
+
+// Ensure that dst can hold exactly one frame with the specified dimensions.
+func checkRenderIntoBuffer(dst []float32, frameW, frameH uint32) error {
+	if exp := int(frameW) * int(frameH) * RenderIntoChannels; len(dst) != exp {
+		return fmt.Errorf("renderer: output buffer length %d does not match frame dimensions %dx%d with %d channels (expected %d)", len(dst), frameW, frameH, RenderIntoChannels, exp)
+	}
+	return nil
+}
+
+// Write the mean RGB radiance of each pixel in a frame accumulator with RGBA
+// radiance sums into dst.
+func writeMeanRadiance(dst, accumulator []float32, samples uint32) {
+	scale := 1.0 / float32(samples)
+	for index := 0; index < len(dst)/RenderIntoChannels; index++ {
+		dst[RenderIntoChannels*index] = accumulator[4*index] * scale
+		dst[RenderIntoChannels*index+1] = accumulator[4*index+1] * scale
+		dst[RenderIntoChannels*index+2] = accumulator[4*index+2] * scale
+	}
+}
@@ -0,0 +1,81 @@
+package renderer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/achilleasa/polaris/log"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+func TestRenderInto(t *testing.T) {
+	newRenderer := func() *defaultRenderer {
+		r := &defaultRenderer{
+			logger:    log.New("renderer"),
+			scheduler: tracer.NaiveScheduler(),
+			options: Options{
+				FrameW:          16,
+				FrameH:          16,
+				SamplesPerPixel: 8,
+			},
+			rng:     tracer.NewRNG(tracer.PCG32, 0),
+			tracers: []tracer.Tracer{&constMockTracer{mockTracer: makeMockTracer("mock"), frame: make([]float32, 4*16*16)}},
+			stats: FrameStats{
+				Tracers: make([]TracerStat, 1),
+			},
+		}
+		r.startWorkers()
+		return r
+	}
+
+	// Render using the allocating API
+	r := newRenderer()
+	expImg, err := startAsyncRender(context.Background(), r, nil, r.Close).Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render into a preallocated buffer
+	r = newRenderer()
+	defer r.Close()
+	dst := make([]float32, 16*16*RenderIntoChannels)
+	if err = r.renderInto(context.Background(), dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for index, exp := range expImg {
+		for c := 0; c < 3; c++ {
+			if got := dst[RenderIntoChannels*index+c]; got != exp[c] {
+				t.Fatalf("expected channel %d of pixel %d to be %f; got %f", c, index, exp[c], got)
+			}
+		}
+	}
+
+	type spec struct {
+		bufLen int
+		expErr bool
+	}
+	specs := []spec{
+		spec{16 * 16 * RenderIntoChannels, false},
+		spec{16 * 16 * 4, true},
+		spec{16*16*RenderIntoChannels - 1, true},
+		spec{0, true},
+	}
+
+	for index, s := range specs {
+		err = r.renderInto(context.Background(), make([]float32, s.bufLen))
+		if s.expErr && err == nil {
+			t.Errorf("[spec %d] expected to get an error for buffer length %d", index, s.bufLen)
+		} else if !s.expErr && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+
+	// The primary tracer must be able to read back its accumulator
+	r = newRenderer()
+	defer r.Close()
+	r.tracers = []tracer.Tracer{makeMockTracer("mock")}
+	if err = r.renderInto(context.Background(), dst); err == nil {
+		t.Fatal("expected render to fail when the primary tracer cannot read back its accumulator")
+	}
+}
@@ -1,8 +1,11 @@
 package renderer
 
+import "context"
+
 type Renderer interface {
-	// Render frame.
-	Render() error
+	// Render frame. Rendering is aborted and ctx.Err() is returned if
+	// the supplied context is cancelled.
+	Render(context.Context) error
 
 	// Shutdown renderer and any attached tracer.
 	Close()
@@ -1,6 +1,10 @@
 package renderer
 
-import "time"
+import (
+	"time"
+
+	"github.com/achilleasa/polaris/tracer"
+)
 
 type TracerStat struct {
 	// The tracer id.
@@ -15,6 +19,11 @@ type TracerStat struct {
 
 	// Render time for assigned block
 	RenderTime time.Duration
+
+	// The number of samples per pixel that were completed for the assigned
+	// block. This is less than the requested samples if the frame was
+	// cancelled while rendering.
+	Samples uint32
 }
 
 type FrameStats struct {
@@ -23,4 +32,41 @@ type FrameStats struct {
 
 	// Total render time for entire frame.
 	RenderTime time.Duration
+
+	// The number of samples per pixel that were taken and the estimated
+	// relative error of the image. The error is only estimated when
+	// rendering with a convergence target.
+	Samples        uint32
+	EstimatedError float32
+
+	// The number of non-finite samples that were dropped since the
+	// renderer was created.
+	NonFiniteSamples uint32
+
+	// The number of sampler dimensions consumed by each path and the
+	// number of those dimensions that exceed the sobol dimension budget
+	// and are padded. Only reported when using the sobol sampler.
+	SamplerDimensions       uint32
+	PaddedSamplerDimensions uint32
+
+	// Ray and traversal counters for the last rendered frame. Counters
+	// are only collected if the CollectStats option is enabled.
+	RenderStats RenderStats
+}
+
+// Ray tracing statistics for a rendered frame.
+type RenderStats struct {
+	// The ray counters summed over all tracers.
+	tracer.RayStats
+
+	// The time elapsed while rendering the frame.
+	ElapsedTime time.Duration
+}
+
+// Get the number of traced rays per second.
+func (s RenderStats) RaysPerSecond() float64 {
+	if s.ElapsedTime <= 0 {
+		return 0
+	}
+	return float64(s.TotalRays()) / s.ElapsedTime.Seconds()
 }
@@ -0,0 +1,58 @@
+package tracer
+
+import "fmt"
+
+// Controls how the scene background is written to the frame buffer. When
+// rendering for compositing, a transparent background allows the render to
+// be placed over a plate.
+type BackgroundMode uint8
+
+// Supported background modes. The values must match the ones defined by the
+// opencl kernels.
+const (
+	// Primary ray misses are shaded using the scene background and all
+	// pixels are opaque.
+	OpaqueBackground BackgroundMode = iota
+
+	// Primary ray misses are shaded using the scene background but the
+	// alpha of each pixel is set to the fraction of its primary rays that
+	// hit the scene.
+	TransparentBackground
+
+	// Like TransparentBackground but primary ray misses do not contribute
+	// any radiance so the frame buffer contains premultiplied colors.
+	TransparentBlackBackground
+)
+
+// Implements Stringer.
+func (m BackgroundMode) String() string {
+	switch m {
+	case OpaqueBackground:
+		return "opaque"
+	case TransparentBackground:
+		return "transparent"
+	case TransparentBlackBackground:
+		return "transparent-black"
+	}
+
+	return "invalid"
+}
+
+// Returns true if the background mode requires per-pixel coverage.
+func (m BackgroundMode) Transparent() bool {
+	return m == TransparentBackground || m == TransparentBlackBackground
+}
+
+// Lookup a background mode by its name.
+func BackgroundModeFromName(name string) (BackgroundMode, error) {
+	switch name {
+	case "opaque":
+		return OpaqueBackground, nil
+	case "transparent":
+		return TransparentBackground, nil
+	case "transparent-black":
+		return TransparentBlackBackground, nil
+	}
+
+	return 0, fmt.Errorf("unsupported background mode %q; supported modes: opaque, transparent, transparent-black", name)
+}
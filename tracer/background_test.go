@@ -0,0 +1,19 @@
+package tracer
+
+import "testing"
+
+func TestBackgroundModeFromName(t *testing.T) {
+	for _, mode := range []BackgroundMode{OpaqueBackground, TransparentBackground, TransparentBlackBackground} {
+		got, err := BackgroundModeFromName(mode.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != mode {
+			t.Fatalf("expected to get mode %s; got %s", mode, got)
+		}
+	}
+
+	if _, err := BackgroundModeFromName("checkerboard"); err == nil {
+		t.Fatal("expected an error for an unsupported background mode")
+	}
+}
@@ -0,0 +1,43 @@
+package tracer
+
+import "fmt"
+
+const (
+	// The default number of rays processed by each intersection kernel
+	// dispatch. A 1024x256 block fits in a single batch.
+	DefaultRayBatchSize uint32 = 1 << 18
+
+	// The supported range for ray batch sizes.
+	MinRayBatchSize uint32 = 1 << 10
+	MaxRayBatchSize uint32 = 1 << 24
+)
+
+// Ensure that a ray batch size is a power of two in the supported range.
+func ValidateRayBatchSize(batchSize uint32) error {
+	if batchSize < MinRayBatchSize || batchSize > MaxRayBatchSize {
+		return fmt.Errorf("tracer: ray batch size %d is outside the supported range [%d, %d]", batchSize, MinRayBatchSize, MaxRayBatchSize)
+	}
+	if batchSize&(batchSize-1) != 0 {
+		return fmt.Errorf("tracer: ray batch size %d is not a power of two", batchSize)
+	}
+	return nil
+}
+
+// Split numRays into consecutive batches of at most batchSize rays and invoke
+// fn with the offset and length of each batch. Processing stops at the first
+// error returned by fn. If batchSize is 0, DefaultRayBatchSize is used.
+func ForEachRayBatch(numRays int, batchSize uint32, fn func(offset, count int) error) error {
+	if batchSize == 0 {
+		batchSize = DefaultRayBatchSize
+	}
+	for offset := 0; offset < numRays; offset += int(batchSize) {
+		count := numRays - offset
+		if count > int(batchSize) {
+			count = int(batchSize)
+		}
+		if err := fn(offset, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
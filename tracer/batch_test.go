@@ -0,0 +1,73 @@
+package tracer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateRayBatchSize(t *testing.T) {
+	type spec struct {
+		batchSize uint32
+		expError  bool
+	}
+	specs := []spec{
+		spec{MinRayBatchSize, false},
+		spec{DefaultRayBatchSize, false},
+		spec{MaxRayBatchSize, false},
+		spec{0, true},
+		spec{MinRayBatchSize >> 1, true},
+		spec{MaxRayBatchSize << 1, true},
+		spec{3000, true},
+	}
+
+	for index, s := range specs {
+		err := ValidateRayBatchSize(s.batchSize)
+		if s.expError && err == nil {
+			t.Errorf("[spec %d] expected batch size %d to be rejected", index, s.batchSize)
+		} else if !s.expError && err != nil {
+			t.Errorf("[spec %d] expected batch size %d to be accepted; got %v", index, s.batchSize, err)
+		}
+	}
+}
+
+func TestForEachRayBatch(t *testing.T) {
+	type spec struct {
+		numRays    int
+		batchSize  uint32
+		expBatches [][2]int
+	}
+	specs := []spec{
+		spec{0, 1024, nil},
+		spec{1000, 1024, [][2]int{{0, 1000}}},
+		spec{2500, 1024, [][2]int{{0, 1024}, {1024, 1024}, {2048, 452}}},
+		spec{2048, 1024, [][2]int{{0, 1024}, {1024, 1024}}},
+		spec{1000, 0, [][2]int{{0, 1000}}},
+	}
+
+	for index, s := range specs {
+		var batches [][2]int
+		err := ForEachRayBatch(s.numRays, s.batchSize, func(offset, count int) error {
+			batches = append(batches, [2]int{offset, count})
+			return nil
+		})
+		if err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+			continue
+		}
+		if !reflect.DeepEqual(batches, s.expBatches) {
+			t.Errorf("[spec %d] expected batches %v; got %v", index, s.expBatches, batches)
+		}
+	}
+
+	// Errors abort processing
+	expErr := errors.New("kernel failed")
+	var calls int
+	err := ForEachRayBatch(4096, 1024, func(_, _ int) error {
+		calls++
+		return expErr
+	})
+	if err != expErr || calls != 1 {
+		t.Fatalf("expected processing to stop after the first error; got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,13 @@
+package tracer
+
+// Get the clamp threshold for a radiance contribution that reaches the camera
+// after numSegments path segments. Contributions with at most two segments
+// (camera -> surface -> light) are treated as direct lighting and use
+// DirectClamp; all other contributions use IndirectClamp. A threshold of 0
+// disables clamping.
+func (req *BlockRequest) RadianceClamp(numSegments uint32) float32 {
+	if numSegments <= 2 {
+		return req.DirectClamp
+	}
+	return req.IndirectClamp
+}
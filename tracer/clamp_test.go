@@ -0,0 +1,26 @@
+package tracer
+
+import "testing"
+
+func TestRadianceClamp(t *testing.T) {
+	type spec struct {
+		req         BlockRequest
+		numSegments uint32
+		expClamp    float32
+	}
+	specs := []spec{
+		// Both clamps are off by default
+		spec{BlockRequest{}, 2, 0},
+		spec{BlockRequest{}, 3, 0},
+		// Camera rays hitting lights count as direct lighting
+		spec{BlockRequest{DirectClamp: 1, IndirectClamp: 2}, 1, 1},
+		spec{BlockRequest{DirectClamp: 1, IndirectClamp: 2}, 2, 1},
+		spec{BlockRequest{DirectClamp: 1, IndirectClamp: 2}, 3, 2},
+	}
+
+	for index, s := range specs {
+		if got := s.req.RadianceClamp(s.numSegments); got != s.expClamp {
+			t.Errorf("[spec %d] expected clamp for %d segments to be %f; got %f", index, s.numSegments, s.expClamp, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package tracer
+
+import (
+	"fmt"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The color space of the rendered output.
+//
+// The renderer works in linear RGB using the sRGB/Rec.709 primaries and a D65
+// white point. Before tone-mapping, the accumulated radiance is converted
+// from the working color space to the selected output color space.
+type ColorSpace uint8
+
+// Supported output color spaces.
+const (
+	SRGB ColorSpace = iota
+	Rec709
+	ACEScg
+)
+
+// Implements Stringer.
+func (cs ColorSpace) String() string {
+	switch cs {
+	case SRGB:
+		return "srgb"
+	case Rec709:
+		return "rec709"
+	case ACEScg:
+		return "acescg"
+	}
+
+	return "invalid"
+}
+
+// Lookup a color space by its name.
+func ColorSpaceFromName(name string) (ColorSpace, error) {
+	switch name {
+	case "srgb":
+		return SRGB, nil
+	case "rec709":
+		return Rec709, nil
+	case "acescg":
+		return ACEScg, nil
+	}
+
+	return 0, fmt.Errorf("unsupported color space %q; supported color spaces: srgb, rec709, acescg", name)
+}
+
+// Get the matrix for converting linear radiance from the renderer's working
+// color space to this color space. sRGB and Rec.709 share the same primaries
+// so their conversion matrix is the identity matrix. The ACEScg matrix
+// includes a Bradford chromatic adaptation from D65 to the ACES white point.
+func (cs ColorSpace) Matrix() types.Mat3 {
+	switch cs {
+	case ACEScg:
+		// Column-major order
+		return types.Mat3{
+			0.6130974, 0.0701937, 0.0206156,
+			0.3395231, 0.9163539, 0.1095698,
+			0.0473795, 0.0134524, 0.8698146,
+		}
+	}
+
+	return types.Ident3()
+}
+
+// Convert a linear color from the renderer's working color space to this
+// color space.
+func (cs ColorSpace) Convert(rgb types.Vec3) types.Vec3 {
+	return cs.Matrix().Mul3x1(rgb)
+}
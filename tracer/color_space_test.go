@@ -0,0 +1,51 @@
+package tracer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestColorSpaceConvert(t *testing.T) {
+	type spec struct {
+		cs     ColorSpace
+		in     types.Vec3
+		expOut types.Vec3
+	}
+	specs := []spec{
+		spec{SRGB, types.Vec3{1, 0, 0}, types.Vec3{1, 0, 0}},
+		spec{Rec709, types.Vec3{0, 0.5, 0}, types.Vec3{0, 0.5, 0}},
+		spec{ACEScg, types.Vec3{1, 0, 0}, types.Vec3{0.6130974, 0.0701937, 0.0206156}},
+		spec{ACEScg, types.Vec3{0, 1, 0}, types.Vec3{0.3395231, 0.9163539, 0.1095698}},
+		spec{ACEScg, types.Vec3{0, 0, 1}, types.Vec3{0.0473795, 0.0134524, 0.8698146}},
+		// White should be preserved
+		spec{ACEScg, types.Vec3{1, 1, 1}, types.Vec3{1, 1, 1}},
+	}
+
+	for index, s := range specs {
+		out := s.cs.Convert(s.in)
+		for c := 0; c < 3; c++ {
+			if math.Abs(float64(out[c]-s.expOut[c])) > 1e-4 {
+				t.Errorf("[spec %d] expected %s conversion of %v to be %v; got %v", index, s.cs, s.in, s.expOut, out)
+				break
+			}
+		}
+	}
+}
+
+func TestColorSpaceFromName(t *testing.T) {
+	for _, cs := range []ColorSpace{SRGB, Rec709, ACEScg} {
+		got, err := ColorSpaceFromName(cs.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != cs {
+			t.Fatalf("expected lookup of %q to return %d; got %d", cs.String(), cs, got)
+		}
+	}
+
+	if _, err := ColorSpaceFromName("p3"); err == nil {
+		t.Fatal("expected lookup of unsupported color space to fail")
+	}
+}
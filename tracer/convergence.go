@@ -0,0 +1,79 @@
+package tracer
+
+import "math"
+
+// The AccumulatorReader interface is implemented by tracers that can read
+// back the contents of their frame accumulator.
+type AccumulatorReader interface {
+	// Read the accumulated radiance sums for the frame described by the
+	// block request. Each pixel is stored as an RGBA quadruplet.
+	ReadAccumulator(*BlockRequest) ([]float32, error)
+}
+
+// A ConvergenceEstimator tracks the convergence of a progressively rendered
+// image by comparing the image estimates obtained after consecutive render
+// passes.
+//
+// Assuming independent samples with per-pixel variance s^2, the difference
+// between the estimate after n samples and the estimate after a previous
+// pass with m samples has variance s^2 * (k/n)^2 * (1/k + 1/m) where
+// k = n - m. Inverting this relation yields an estimate for s^2 and the
+// standard error s/sqrt(n) of the current estimate. The reported error is
+// the root mean square of the per-pixel standard error relative to the pixel
+// luminance.
+type ConvergenceEstimator struct {
+	prev        []float32
+	prevSamples uint32
+	err         float32
+}
+
+// The luminance offset used for calculating relative errors. It prevents
+// dark pixels from dominating the error estimate.
+const convergenceLuminanceBias = 1e-2
+
+// Update the estimator with the accumulated radiance sums (RGBA per pixel)
+// after the specified number of samples per pixel.
+func (ce *ConvergenceEstimator) Update(accumulator []float32, samples uint32) {
+	defer func() {
+		ce.prev = append(ce.prev[:0], accumulator...)
+		ce.prevSamples = samples
+	}()
+
+	if ce.prevSamples == 0 || samples <= ce.prevSamples || len(ce.prev) != len(accumulator) {
+		ce.err = float32(math.Inf(1))
+		return
+	}
+
+	n, m := float64(samples), float64(ce.prevSamples)
+	k := n - m
+	varianceScale := (n * n / (k * k)) / (1/k + 1/m)
+
+	var sumSq float64
+	numPixels := len(accumulator) / 4
+	for pixel := 0; pixel < numPixels; pixel++ {
+		cur := pixelLuminance(accumulator[4*pixel:]) / n
+		prev := pixelLuminance(ce.prev[4*pixel:]) / m
+		delta := cur - prev
+		stdErr := math.Sqrt(delta*delta*varianceScale/n) / (math.Abs(cur) + convergenceLuminanceBias)
+		sumSq += stdErr * stdErr
+	}
+
+	ce.err = 0
+	if numPixels > 0 {
+		ce.err = float32(math.Sqrt(sumSq / float64(numPixels)))
+	}
+}
+
+// Get the estimated relative error of the image. The error is infinite
+// until the estimator has been updated with at least two render passes.
+func (ce *ConvergenceEstimator) EstimatedError() float32 {
+	if ce.prevSamples == 0 {
+		return float32(math.Inf(1))
+	}
+	return ce.err
+}
+
+// Calculate the luminance of an RGB pixel.
+func pixelLuminance(rgb []float32) float64 {
+	return 0.2126*float64(rgb[0]) + 0.7152*float64(rgb[1]) + 0.0722*float64(rgb[2])
+}
@@ -0,0 +1,66 @@
+package tracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvergenceEstimator(t *testing.T) {
+	const numPixels = 256
+
+	// Accumulate samples with a constant mean of 0.5 per pixel and add
+	// uniform noise with the specified amplitude.
+	estimate := func(noise float32, passes int) []float32 {
+		rng := NewRNG(PCG32, 1)
+		accumulator := make([]float32, 4*numPixels)
+		var ce ConvergenceEstimator
+		var errors []float32
+		for pass := 1; pass <= passes; pass++ {
+			for sample := 0; sample < 16; sample++ {
+				for pixel := 0; pixel < numPixels; pixel++ {
+					v := 0.5 + noise*(rng.Float32()-0.5)
+					for c := 0; c < 3; c++ {
+						accumulator[4*pixel+c] += v
+					}
+				}
+			}
+			ce.Update(accumulator, uint32(16*pass))
+			errors = append(errors, ce.EstimatedError())
+		}
+		return errors
+	}
+
+	var ce ConvergenceEstimator
+	if !math.IsInf(float64(ce.EstimatedError()), 1) {
+		t.Fatalf("expected error to be infinite before any updates; got %f", ce.EstimatedError())
+	}
+
+	// A noise-free image converges after two passes
+	errors := estimate(0, 2)
+	if !math.IsInf(float64(errors[0]), 1) {
+		t.Fatalf("expected error to be infinite after a single pass; got %f", errors[0])
+	}
+	if errors[1] != 0 {
+		t.Fatalf("expected error for a noise-free image to be 0; got %f", errors[1])
+	}
+
+	// For noisy images the error should drop roughly with 1/sqrt(samples).
+	// The std deviation of uniform noise with amplitude 1 is 1/sqrt(12) so
+	// the expected relative error after n samples is 1/(0.51*sqrt(12*n)).
+	errors = estimate(1, 64)
+	type spec struct {
+		pass int
+	}
+	specs := []spec{
+		spec{4},
+		spec{16},
+		spec{64},
+	}
+	for index, s := range specs {
+		samples := float64(16 * s.pass)
+		exp := 1 / (0.51 * math.Sqrt(12*samples))
+		if got := float64(errors[s.pass-1]); math.Abs(got-exp) > 0.25*exp {
+			t.Errorf("[spec %d] expected error after %d samples to be approx %f; got %f", index, int(samples), exp, got)
+		}
+	}
+}
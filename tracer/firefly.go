@@ -0,0 +1,26 @@
+package tracer
+
+import "fmt"
+
+const (
+	// The default window radius for the firefly filter. A radius of r
+	// examines a (2r+1) x (2r+1) pixel neighborhood.
+	DefaultFireflyRadius uint32 = 1
+
+	// The max supported firefly filter window radius.
+	MaxFireflyRadius uint32 = 3
+)
+
+// Ensure that the firefly filter settings are valid. A zero threshold
+// disables the filter.
+func ValidateFireflyFilter(threshold float32, radius uint32) error {
+	switch {
+	case threshold == 0:
+		return nil
+	case threshold < 1:
+		return fmt.Errorf("tracer: firefly threshold must be >= 1; got %f", threshold)
+	case radius == 0 || radius > MaxFireflyRadius:
+		return fmt.Errorf("tracer: firefly radius must be in the [1, %d] range; got %d", MaxFireflyRadius, radius)
+	}
+	return nil
+}
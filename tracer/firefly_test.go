@@ -0,0 +1,28 @@
+package tracer
+
+import "testing"
+
+func TestValidateFireflyFilter(t *testing.T) {
+	type spec struct {
+		threshold float32
+		radius    uint32
+		expError  bool
+	}
+	specs := []spec{
+		spec{0, 0, false},
+		spec{4, 1, false},
+		spec{4, MaxFireflyRadius, false},
+		spec{0.5, 1, true},
+		spec{4, 0, true},
+		spec{4, MaxFireflyRadius + 1, true},
+	}
+
+	for index, s := range specs {
+		err := ValidateFireflyFilter(s.threshold, s.radius)
+		if s.expError && err == nil {
+			t.Errorf("[spec %d] expected an error", index)
+		} else if !s.expError && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The range of supported 3D LUT sizes.
+const (
+	MinLUTSize = 2
+	MaxLUTSize = 256
+)
+
+// A 3D color lookup table for applying a look to the final image. The table
+// contains Size^3 entries ordered with the red coordinate varying fastest,
+// followed by green and then blue, as in the Adobe .cube format. Input
+// colors are mapped from the [DomainMin, DomainMax] range to the table
+// coordinates and the output color is trilinearly interpolated.
+type LUT3D struct {
+	Title     string
+	Size      int
+	DomainMin types.Vec3
+	DomainMax types.Vec3
+	Data      []types.Vec3
+}
+
+// Create an identity LUT with the given size.
+func NewIdentityLUT(size int) *LUT3D {
+	lut := &LUT3D{
+		Size:      size,
+		DomainMax: types.Vec3{1, 1, 1},
+		Data:      make([]types.Vec3, 0, size*size*size),
+	}
+
+	scale := 1.0 / float32(size-1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				lut.Data = append(lut.Data, types.Vec3{float32(r) * scale, float32(g) * scale, float32(b) * scale})
+			}
+		}
+	}
+	return lut
+}
+
+// Load a 3D LUT from a .cube file.
+func LoadCubeLUT(path string) (*LUT3D, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lut, err := ParseCubeLUT(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s (%s)", err.Error(), path)
+	}
+	return lut, nil
+}
+
+// Parse a 3D LUT in the .cube format. Only 3D LUTs are supported.
+func ParseCubeLUT(r io.Reader) (*LUT3D, error) {
+	lut := &LUT3D{DomainMax: types.Vec3{1, 1, 1}}
+
+	var lineNum int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		lineTokens := strings.Fields(scanner.Text())
+		if len(lineTokens) == 0 || strings.HasPrefix(lineTokens[0], "#") {
+			continue
+		}
+
+		// Keywords must precede the table data
+		keyword := lineTokens[0]
+		if len(lut.Data) > 0 && !isCubeNumber(keyword) {
+			return nil, fmt.Errorf("tracer: unexpected keyword %q after LUT data at line %d", keyword, lineNum)
+		}
+
+		var err error
+		switch keyword {
+		case "TITLE":
+			lut.Title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "TITLE")), `"`)
+		case "LUT_3D_SIZE":
+			if len(lineTokens) != 2 {
+				return nil, fmt.Errorf("tracer: expected 1 argument for LUT_3D_SIZE at line %d; got %d", lineNum, len(lineTokens)-1)
+			}
+			lut.Size, err = strconv.Atoi(lineTokens[1])
+			if err != nil || lut.Size < MinLUTSize || lut.Size > MaxLUTSize {
+				return nil, fmt.Errorf("tracer: LUT_3D_SIZE at line %d must be an integer in the [%d, %d] range; got %q", lineNum, MinLUTSize, MaxLUTSize, lineTokens[1])
+			}
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("tracer: 1D LUTs are not supported (line %d)", lineNum)
+		case "DOMAIN_MIN":
+			lut.DomainMin, err = parseCubeVec3(lineTokens[1:])
+		case "DOMAIN_MAX":
+			lut.DomainMax, err = parseCubeVec3(lineTokens[1:])
+		default:
+			if !isCubeNumber(keyword) {
+				return nil, fmt.Errorf("tracer: unsupported keyword %q at line %d", keyword, lineNum)
+			}
+			if lut.Size == 0 {
+				return nil, fmt.Errorf("tracer: LUT data at line %d precedes the LUT_3D_SIZE keyword", lineNum)
+			}
+
+			var entry types.Vec3
+			entry, err = parseCubeVec3(lineTokens)
+			lut.Data = append(lut.Data, entry)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("tracer: %s at line %d", err.Error(), lineNum)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := lut.Validate(); err != nil {
+		return nil, err
+	}
+	return lut, nil
+}
+
+// Ensure that the LUT dimensions and domain are consistent.
+func (lut *LUT3D) Validate() error {
+	if lut.Size < MinLUTSize || lut.Size > MaxLUTSize {
+		return fmt.Errorf("tracer: LUT size must be in the [%d, %d] range; got %d", MinLUTSize, MaxLUTSize, lut.Size)
+	}
+	if expEntries := lut.Size * lut.Size * lut.Size; len(lut.Data) != expEntries {
+		return fmt.Errorf("tracer: expected a LUT with size %d to contain %d entries; got %d", lut.Size, expEntries, len(lut.Data))
+	}
+	for c := 0; c < 3; c++ {
+		if lut.DomainMin[c] >= lut.DomainMax[c] {
+			return fmt.Errorf("tracer: LUT domain min %v must be less than domain max %v", lut.DomainMin, lut.DomainMax)
+		}
+	}
+	return nil
+}
+
+// Map a color through the LUT using trilinear interpolation. Colors outside
+// the LUT domain are clamped to the domain bounds.
+func (lut *LUT3D) Lookup(rgb types.Vec3) types.Vec3 {
+	maxIndex := float32(lut.Size - 1)
+
+	var base [3]int
+	var frac types.Vec3
+	for c := 0; c < 3; c++ {
+		v := (rgb[c] - lut.DomainMin[c]) / (lut.DomainMax[c] - lut.DomainMin[c]) * maxIndex
+		switch {
+		case !(v > 0):
+			v = 0
+		case v > maxIndex:
+			v = maxIndex
+		}
+
+		base[c] = int(v)
+		if base[c] == lut.Size-1 {
+			base[c]--
+		}
+		frac[c] = v - float32(base[c])
+	}
+
+	entry := func(r, g, b int) types.Vec3 {
+		return lut.Data[(b*lut.Size+g)*lut.Size+r]
+	}
+	lerp := func(a, b types.Vec3, t float32) types.Vec3 {
+		return a.Add(b.Sub(a).Mul(t))
+	}
+
+	r, g, b := base[0], base[1], base[2]
+	c00 := lerp(entry(r, g, b), entry(r+1, g, b), frac[0])
+	c10 := lerp(entry(r, g+1, b), entry(r+1, g+1, b), frac[0])
+	c01 := lerp(entry(r, g, b+1), entry(r+1, g, b+1), frac[0])
+	c11 := lerp(entry(r, g+1, b+1), entry(r+1, g+1, b+1), frac[0])
+	return lerp(lerp(c00, c10, frac[1]), lerp(c01, c11, frac[1]), frac[2])
+}
+
+// Apply a LUT to a list of pixels in place. LUTs are designed for display
+// referred colors so they should be applied after tone-mapping.
+func ApplyLUT(pixels []types.Vec3, lut *LUT3D) {
+	for index, pixel := range pixels {
+		pixels[index] = lut.Lookup(pixel)
+	}
+}
+
+// Check whether a .cube token starts a numeric table entry.
+func isCubeNumber(token string) bool {
+	_, err := strconv.ParseFloat(token, 32)
+	return err == nil
+}
+
+// Parse a triplet of floats.
+func parseCubeVec3(tokens []string) (types.Vec3, error) {
+	var out types.Vec3
+	if len(tokens) != 3 {
+		return out, fmt.Errorf("expected 3 values; got %d", len(tokens))
+	}
+
+	for c, token := range tokens {
+		v, err := strconv.ParseFloat(token, 32)
+		if err != nil {
+			return out, fmt.Errorf("could not parse value %q: %s", token, err.Error())
+		}
+		out[c] = float32(v)
+	}
+	return out, nil
+}
@@ -0,0 +1,85 @@
+package tracer
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestApplyLUT(t *testing.T) {
+	// Serialize an identity LUT in the .cube format
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, `# identity`)
+	fmt.Fprintln(&buf, `TITLE "identity look"`)
+	fmt.Fprintln(&buf, `LUT_3D_SIZE 5`)
+	for _, entry := range NewIdentityLUT(5).Data {
+		fmt.Fprintf(&buf, "%f %f %f\n", entry[0], entry[1], entry[2])
+	}
+
+	lut, err := ParseCubeLUT(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.Title != "identity look" || lut.Size != 5 {
+		t.Fatalf("expected LUT title and size to be %q and 5; got %q and %d", "identity look", lut.Title, lut.Size)
+	}
+
+	rng := rand.New(rand.NewSource(0))
+	pixels := make([]types.Vec3, 256)
+	for index := range pixels {
+		pixels[index] = types.Vec3{rng.Float32(), rng.Float32(), rng.Float32()}
+	}
+	pixels[0] = types.Vec3{0, 0, 0}
+	pixels[1] = types.Vec3{1, 1, 1}
+	orig := append([]types.Vec3(nil), pixels...)
+
+	ApplyLUT(pixels, lut)
+	for index, pixel := range pixels {
+		if !types.ApproxEqual(pixel, orig[index], 1e-5) {
+			t.Fatalf("expected identity LUT to leave pixel %d unchanged (%v); got %v", index, orig[index], pixel)
+		}
+	}
+
+	// Out of domain colors are clamped
+	if got := lut.Lookup(types.Vec3{-1, 2, 0.5}); !types.ApproxEqual(got, types.Vec3{0, 1, 0.5}, 1e-5) {
+		t.Fatalf("expected out of domain color to be clamped to %v; got %v", types.Vec3{0, 1, 0.5}, got)
+	}
+
+	// Invert the colors using a custom domain
+	invert := NewIdentityLUT(2)
+	invert.DomainMax = types.Vec3{2, 2, 2}
+	for index, entry := range invert.Data {
+		invert.Data[index] = types.Vec3{1, 1, 1}.Sub(entry)
+	}
+	if got := invert.Lookup(types.Vec3{0.5, 1, 1.5}); !types.ApproxEqual(got, types.Vec3{0.75, 0.5, 0.25}, 1e-5) {
+		t.Fatalf("expected inverted color to be %v; got %v", types.Vec3{0.75, 0.5, 0.25}, got)
+	}
+}
+
+func TestParseCubeLUTErrors(t *testing.T) {
+	type spec struct {
+		descr   string
+		payload string
+	}
+	specs := []spec{
+		spec{"missing size", "0 0 0\n1 1 1\n"},
+		spec{"size too small", "LUT_3D_SIZE 1\n0 0 0\n"},
+		spec{"too few entries", "LUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 7)},
+		spec{"too many entries", "LUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 9)},
+		spec{"malformed entry", "LUT_3D_SIZE 2\n" + strings.Repeat("0 0\n", 8)},
+		spec{"keyword after data", "LUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 8) + "DOMAIN_MIN 0 0 0\n"},
+		spec{"invalid domain", "LUT_3D_SIZE 2\nDOMAIN_MIN 1 0 0\nDOMAIN_MAX 1 1 1\n" + strings.Repeat("0 0 0\n", 8)},
+		spec{"1D LUT", "LUT_1D_SIZE 16\n"},
+		spec{"unknown keyword", "LUT_3D_SIZE 2\nFOO 1\n"},
+	}
+
+	for index, s := range specs {
+		if _, err := ParseCubeLUT(strings.NewReader(s.payload)); err == nil {
+			t.Errorf("[spec %d] expected to get an error for a LUT with %s", index, s.descr)
+		}
+	}
+}
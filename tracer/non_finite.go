@@ -0,0 +1,49 @@
+package tracer
+
+import "fmt"
+
+// The policy for handling radiance samples with NaN or Inf components. A
+// single non-finite sample permanently poisons the average of the pixel it is
+// accumulated to so by default such samples are dropped.
+type NonFiniteGuard uint8
+
+// Supported non-finite sample guard policies. The values must match the ones
+// defined by the opencl kernels.
+const (
+	// Drop non-finite samples and count them in the tracer stats.
+	CountNonFinite NonFiniteGuard = iota
+
+	// Drop non-finite samples without counting them.
+	DropNonFinite
+
+	// Accumulate non-finite samples as-is.
+	KeepNonFinite
+)
+
+// Implements Stringer.
+func (g NonFiniteGuard) String() string {
+	switch g {
+	case CountNonFinite:
+		return "count"
+	case DropNonFinite:
+		return "drop"
+	case KeepNonFinite:
+		return "off"
+	}
+
+	return "invalid"
+}
+
+// Lookup a non-finite sample guard policy by its name.
+func NonFiniteGuardFromName(name string) (NonFiniteGuard, error) {
+	switch name {
+	case "count":
+		return CountNonFinite, nil
+	case "drop":
+		return DropNonFinite, nil
+	case "off":
+		return KeepNonFinite, nil
+	}
+
+	return 0, fmt.Errorf("unsupported non-finite sample guard %q; supported guards: count, drop, off", name)
+}
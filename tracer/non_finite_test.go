@@ -0,0 +1,19 @@
+package tracer
+
+import "testing"
+
+func TestNonFiniteGuardFromName(t *testing.T) {
+	for _, guard := range []NonFiniteGuard{CountNonFinite, DropNonFinite, KeepNonFinite} {
+		got, err := NonFiniteGuardFromName(guard.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != guard {
+			t.Fatalf("expected to get guard %s; got %s", guard, got)
+		}
+	}
+
+	if _, err := NonFiniteGuardFromName("foo"); err == nil {
+		t.Fatal("expected to get an error for an unsupported guard")
+	}
+}
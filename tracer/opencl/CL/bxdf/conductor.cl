@@ -4,6 +4,21 @@
 float3 conductorSample(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 inRayDir, float3 *outRayDir, float *pdf);
 float conductorPdf(Surface *surface, float3 inRayDir, float3 outRayDir);
 float3 conductorEval(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float3 inRayDir, float3 outRayDir);
+float3 conductorFresnel(MaterialNode *matNode, float iDotN);
+
+// Calculate fresnel for a conductor. If the material defines a complex IOR
+// then the conductor fresnel equations are evaluated for each channel. Otherwise
+// the dielectric approximation is used unless no IOR is specified.
+inline float3 conductorFresnel(MaterialNode *matNode, float iDotN){
+	if( any(matNode->conductorEta != 0.0f) || any(matNode->conductorK != 0.0f) ){
+		float extIOR = matNode->extIOR != 0.0f ? matNode->extIOR : 1.0f;
+		return fresnelForConductorRGB(matNode->conductorEta / extIOR, matNode->conductorK / extIOR, iDotN);
+	}
+
+	return matNode->intIOR != 0.0f 
+		? (float3)(fresnelForDielectric(matNode->extIOR, matNode->intIOR, iDotN))
+		: (float3)(1.0f, 1.0f, 1.0f);
+}
 
 // Sample conductor bxdf
 //
@@ -19,10 +34,7 @@ float3 conductorSample(Surface *surface, MaterialNode *matNode, __global Texture
 
 	*pdf = 1.0f;
 
-	// Calculate fresnel unless no IOR is specified
-	float f = matNode->intIOR != 0.0f 
-		? fresnelForDielectric(matNode->extIOR, matNode->intIOR, iDotN)
-		: 1.0f;
+	float3 f = conductorFresnel(matNode, iDotN);
 
 	float3 ks = matGetSample3f(surface->uv, matNode->specularity, matNode->specularityTex, texMeta, texData);
 	return iDotN != 0.0f ? f * ks / iDotN : 0.0f;
@@ -52,10 +64,7 @@ float3 conductorEval(Surface *surface, MaterialNode *matNode, __global TextureMe
 		return (float3)(0.0f, 0.0f, 0.0f);
 	}
 
-	// Calculate fresnel unless no IOR is specified
-	float f = matNode->intIOR != 0.0f 
-		? fresnelForDielectric(matNode->extIOR, matNode->intIOR, iDotN)
-		: 1.0f;
+	float3 f = conductorFresnel(matNode, iDotN);
 
 	float3 ks = matGetSample3f(surface->uv, matNode->specularity, matNode->specularityTex, texMeta, texData);
 	return iDotN != 0.0f ? f * ks / iDotN : 0.0f;
@@ -10,39 +10,48 @@ float3 dielectricEval(Surface *surface, MaterialNode *matNode, __global TextureM
 // BXDF = 1 / cos(theta)
 // PDF = 1
 float3 dielecticSample(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 inRayDir, float3 *outRayDir, float *pdf){
-	float iDotN = dot(inRayDir, surface->normal);
+	float3 n = surface->normal;
+	float iDotN = dot(inRayDir, n);
 	float etaI = matNode->extIOR;
 	float etaT = matNode->intIOR;
 
-	// If hitting from the inside we need to swap the eta 
+	// If hitting from the inside we need to swap the eta and flip the
+	// normal so it points towards the same side as the incoming ray.
 	if( iDotN < 0.0f ){
 		float tmp = etaI;
 		etaI = etaT;
 		etaT = tmp;
+		n = -n;
+		iDotN = -iDotN;
+	}
+
+	if( iDotN == 0.0f ){
+		*pdf = 0.0f;
+		return (float3)(0.0f, 0.0f, 0.0f);
 	}
 
 	float eta = etaI / etaT;
 
-	// Calculate fresnel 
-	float f = fresnelForDielectric(etaI, etaT, iDotN);
+	// Calculate fresnel; this is equal to 1 if the ray undergoes total
+	// internal reflection (sinT >= 1) in which case we always pick the
+	// reflection ray.
+	float f = fresnelForDielectricExact(etaI, etaT, iDotN);
 	
 	float3 kVal;
-	float cosTSq = 1.0f + eta * (iDotN * iDotN - 1.0f);
 
 	// Based on the fresnel value randomly sample the reflection ray.
-	// In the case where the ray undergoes total internal reflection we 
-	// always pick the reflection ray
-	if( cosTSq <= 0.0f || randSample.x <= f ){
-		*outRayDir = -sign(iDotN) * 2.0f * iDotN * surface->normal - inRayDir;
+	if( randSample.x < f ){
+		*outRayDir = 2.0f * iDotN * n - inRayDir;
 		kVal = matGetSample3f(surface->uv, matNode->specularity, matNode->specularityTex, texMeta, texData);
-		*pdf = cosTSq <= 0.0f ? 1.0f : f;
+		*pdf = f;
 	} else {
-		*outRayDir = (eta * iDotN - sign(iDotN)*sqrt(cosTSq))*surface->normal - eta * inRayDir;
+		float cosT = sqrt(1.0f - eta * eta * (1.0f - iDotN * iDotN));
+		*outRayDir = (eta * iDotN - cosT) * n - eta * inRayDir;
 		kVal = eta * eta * matGetSample3f(surface->uv, matNode->transmittance, matNode->transmittanceTex, texMeta, texData);
 		*pdf = 1.0f - f;
 	}
 	
-	return iDotN != 0.0f ? *pdf * kVal / fabs(iDotN): 0.0f;
+	return *pdf * kVal / iDotN;
 }
 
 // Get PDF for dielectic surface given a pre-calculated bounce ray.
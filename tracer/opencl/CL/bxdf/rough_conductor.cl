@@ -29,10 +29,7 @@ float3 roughConductorSample( Surface *surface, MaterialNode *matNode, __global T
 	float d = ggxGetD(roughness, surface->normal, h);
 	float g = ggxGetG(roughness, inRayDir, *outRayDir, surface->normal, h);
 
-	// Calculate fresnel unless no IOR is specified
-	float f = matNode->intIOR != 0.0f
-		? fresnelForDielectric(matNode->extIOR, matNode->intIOR, iDotN)
-		: 1.0f;
+	float3 f = conductorFresnel(matNode, iDotN);
 
 	// Eval sample (equation 20)
 	float denom = 4.0f * iDotN * oDotN;
@@ -61,10 +58,7 @@ float3 roughConductorEval( Surface *surface, MaterialNode *matNode, __global Tex
 	float iDotN = dot(inRayDir, surface->normal);
 	float oDotN = dot(outRayDir, surface->normal);
 
-	// Calculate fresnel unless no IOR is specified
-	float f = matNode->intIOR != 0.0f
-		? fresnelForDielectric(matNode->extIOR, matNode->intIOR, iDotN)
-		: 1.0f;
+	float3 f = conductorFresnel(matNode, iDotN);
 
 	float3 h = normalize(inRayDir + outRayDir);
 
@@ -30,7 +30,7 @@ float3 roughDielectricSample( Surface *surface, MaterialNode *matNode, __global
 	// Calculate fresnel 
 	float f = fresnelForDielectric(etaI, etaT, iDotN);
 	
-	float cosTSq = 1.0f + eta * (iDotN * iDotN - 1.0f);
+	float cosTSq = 1.0f + eta * eta * (iDotN * iDotN - 1.0f);
 
 	// Based on the fresnel value randomly sample the reflection ray.
 	// In the case where the ray undergoes total internal reflection we 
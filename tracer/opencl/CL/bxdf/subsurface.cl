@@ -0,0 +1,73 @@
+#ifndef BXDF_SUBSURFACE_CL
+#define BXDF_SUBSURFACE_CL
+
+float3 subsurfaceSample(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 inRayDir, float3 *outRayDir, float *pdf);
+float subsurfacePdf(Surface *surface, MaterialNode *matNode, float3 inRayDir, float3 outRayDir);
+float3 subsurfaceEval(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float3 inRayDir, float3 outRayDir);
+float3 subsurfaceTransmission(float3 albedo, float meanFreePath, float dist);
+
+// Approximate subsurface scattering using the normalized Burley diffusion
+// profile. Rays hitting the surface from the outside are either diffusely
+// reflected or diffusely transmitted into the medium with equal probability:
+//
+// BXDF = albedo / 2PI
+// PDF = |cos(theta)| / 2PI
+//
+// Rays hitting the surface from the inside have travelled through the medium
+// for surface->hitDist units. They exit the medium through a cosine weighted
+// lobe and are attenuated by the fraction of the diffusion profile that
+// reaches that far:
+//
+// BXDF = transmission(albedo, meanFreePath, hitDist) / PI
+// PDF = cos(theta) / PI
+float3 subsurfaceSample(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 inRayDir, float3 *outRayDir, float *pdf){
+	float3 albedo = matGetSample3f(surface->uv, matNode->reflectance, matNode->reflectanceTex, texMeta, texData);
+
+	// Exiting the medium
+	if( dot(inRayDir, surface->normal) < 0.0f ){
+		*outRayDir = cosWeightedHemisphereGetSample(surface->normal, randSample);
+		*pdf = dot(surface->normal, *outRayDir) * C_1_PI;
+		return subsurfaceTransmission(albedo, matNode->meanFreePath, surface->hitDist) * C_1_PI;
+	}
+
+	// Entering the medium or reflecting off its surface
+	if( randSample.x < 0.5f ){
+		*outRayDir = cosWeightedHemisphereGetSample(surface->normal, (float2)(2.0f * randSample.x, randSample.y));
+	} else {
+		*outRayDir = cosWeightedHemisphereGetSample(-surface->normal, (float2)(2.0f * randSample.x - 1.0f, randSample.y));
+	}
+	*pdf = 0.5f * fabs(dot(surface->normal, *outRayDir)) * C_1_PI;
+	return albedo * 0.5f * C_1_PI;
+}
+
+// Get PDF for subsurface surface given a pre-calculated bounce ray.
+float subsurfacePdf(Surface *surface, MaterialNode *matNode, float3 inRayDir, float3 outRayDir){
+	float oDotN = dot(surface->normal, outRayDir);
+	if( dot(inRayDir, surface->normal) < 0.0f ){
+		return max(0.0f, oDotN) * C_1_PI;
+	}
+	return 0.5f * fabs(oDotN) * C_1_PI;
+}
+
+// Evaluate BXDF for subsurface surface given a pre-calculated bounce ray.
+float3 subsurfaceEval(Surface *surface, MaterialNode *matNode, __global TextureMetadata *texMeta, __global uchar *texData, float3 inRayDir, float3 outRayDir){
+	float3 albedo = matGetSample3f(surface->uv, matNode->reflectance, matNode->reflectanceTex, texMeta, texData);
+	if( dot(inRayDir, surface->normal) < 0.0f ){
+		return dot(surface->normal, outRayDir) > 0.0f
+			? subsurfaceTransmission(albedo, matNode->meanFreePath, surface->hitDist) * C_1_PI
+			: (float3)(0.0f, 0.0f, 0.0f);
+	}
+	return albedo * 0.5f * C_1_PI;
+}
+
+// Calculate the fraction of light that diffuses further than dist inside the
+// medium using the CDF of the normalized Burley diffusion profile. The profile
+// shape for each channel is derived from the albedo using the searchlight
+// configuration fit from Christensen and Burley, 2015.
+float3 subsurfaceTransmission(float3 albedo, float meanFreePath, float dist){
+	float3 a = albedo - 0.8f;
+	float3 s = 1.9f - albedo + 3.5f * a * a;
+	float3 r = dist * s / meanFreePath;
+	return 0.25f * exp(-r) + 0.75f * exp(-r / 3.0f);
+}
+#endif
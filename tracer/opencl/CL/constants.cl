@@ -21,7 +21,10 @@
 
 // Intersection constants
 #define INTERSECTION_EPSILON 0.00001f
-#define INTERSECTION_WITH_LIGHT_EPSILON (INTERSECTION_EPSILON * 1e3f)
+
+// Occlusion rays towards light samples are clipped by this multiple of the
+// ray epsilon which is derived from the scene units
+#define LIGHT_CLIP_EPSILON_SCALE 1e3f
 
 // GGX distribution explodes if roughness is set to 0 (microfacet bxdf)
 #define MIN_ROUGHNESS 0.1f
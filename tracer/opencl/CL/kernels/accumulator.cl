@@ -18,4 +18,62 @@ __kernel void aggregateAccumulator(
 	dstAccumulator[globalId] += srcAccumulator[globalId];
 }
 
+// Add the accumulator contents that were added since the last invocation of
+// this kernel to the bounce accumulator layer that starts at bounceOffset.
+__kernel void captureBounceContribution(
+		__global float3 *accumulator,
+		__global float3 *snapshot,
+		__global float3 *bounceAccumulator,
+		const uint bounceOffset
+		){
+	int globalId = get_global_id(0);
+	float3 value = accumulator[globalId];
+	bounceAccumulator[bounceOffset + globalId] += value - snapshot[globalId];
+	snapshot[globalId] = value;
+}
+
+// Add a unit sample to the coverage layer of each render layer that contains
+// the mesh instance hit by a primary ray. Layer coverage is stored in the x
+// component of the layer accumulator which holds layerStride pixels per layer.
+__kernel void accumulateLayerCoverage(
+		__global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		__global uint *instanceLayers,
+		__global float3 *layerAccumulator,
+		const uint numLayers,
+		const uint layerStride
+		){
+	int globalId = get_global_id(0);
+	if(globalId >= *numRays || !hitFlags[globalId] || intersections[globalId].wuvt.w == FLT_MAX){
+		return;
+	}
+
+	uint pixelIndex = paths[globalId].pixelIndex;
+	uint layerMask = instanceLayers[intersections[globalId].meshInstance];
+	for(uint layer = 0; layerMask != 0 && layer < numLayers; layer++, layerMask >>= 1){
+		if(layerMask & 1){
+			layerAccumulator[layer * layerStride + pixelIndex].x += 1.0f;
+		}
+	}
+}
+
+// Add a unit sample to the coverage accumulator of each pixel whose primary
+// ray hit the scene. Coverage is stored in the x component of the accumulator.
+__kernel void accumulateCoverage(
+		__global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		__global float3 *coverageAccumulator
+		){
+	int globalId = get_global_id(0);
+	if(globalId >= *numRays || !hitFlags[globalId] || intersections[globalId].wuvt.w == FLT_MAX){
+		return;
+	}
+
+	coverageAccumulator[paths[globalId].pixelIndex].x += 1.0f;
+}
+
 #endif
@@ -0,0 +1,107 @@
+#ifndef AOV_INTEGRATOR_KERNEL_CL
+#define AOV_INTEGRATOR_KERNEL_CL
+
+// Accumulate the shading normal at the first hit of each ray encoded into the
+// [0, 1] range. Any bump or normal maps defined by the surface material are
+// applied to the normal. Rays that miss the scene do not contribute.
+__kernel void shadeNormals(
+		__global Ray *rays,
+		__global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		// scene data
+		__global float4 *vertices,
+		__global float4 *normals,
+		__global float2 *uv,
+		__global float4 *uvTransforms,
+		const uint hasUVTransforms,
+		__global uint *materialIndices,
+		__global MaterialNode *materialNodes,
+		// texture data
+		__global TextureMetadata *texMeta,
+		__global uchar *texData,
+		// state
+		const uint randSeed,
+		// output accumulator
+		__global float3 *accumulator
+		){
+
+	int globalId = get_global_id(0);
+	if( globalId >= *numRays || !hitFlags[globalId] || intersections[globalId].wuvt.w == FLT_MAX ){
+		return;
+	}
+
+	uint rayPathIndex;
+	float3 inRayDir = -rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
+	uint pixelIndex = paths[rayPathIndex].pixelIndex;
+
+	Surface surface;
+	surfaceInit(&surface, intersections + globalId, vertices, normals, uv, materialIndices);
+	surfaceApplyUVTransform(&surface, uvTransforms, hasUVTransforms);
+
+	// Traverse the material tree so that any normal maps get applied
+	MaterialNode materialNode;
+	float3 bxdfTint;
+	uint2 rndState = (uint2)(randSeed, pixelIndex);
+	matSelectNode(paths + rayPathIndex, &surface, inRayDir, &materialNode, &bxdfTint, materialNodes, &rndState, texMeta, texData);
+
+	accumulator[pixelIndex] += (surface.normal + 1.0f) * 0.5f;
+}
+
+// Emit a cosine weighted occlusion ray over the hemisphere that faces the
+// incoming ray at the first hit of each ray. The emissive sample of each
+// occlusion ray is set to sampleWeight so that accumulateEmissiveSamples adds
+// the unoccluded fraction of the hemisphere to the accumulator. Rays that miss
+// the scene see an unoccluded hemisphere.
+__kernel void shadeAmbientOcclusion(
+		__global Ray *rays,
+		__global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		// scene data
+		__global float4 *vertices,
+		__global float4 *normals,
+		__global float2 *uv,
+		__global uint *materialIndices,
+		// state
+		const uint randSeed,
+		const float maxDist,
+		const float sampleWeight,
+		const float rayEpsilon,
+		// occlusion rays and samples
+		__global Ray *occlusionRays,
+		volatile __global int *numOcclusionRays,
+		__global float3 *emissiveSamples,
+		// output accumulator
+		__global float3 *accumulator
+		){
+
+	int globalId = get_global_id(0);
+	if( globalId >= *numRays ){
+		return;
+	}
+
+	uint rayPathIndex;
+	float3 rayDir = rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
+	uint pixelIndex = paths[rayPathIndex].pixelIndex;
+	if( !hitFlags[globalId] || intersections[globalId].wuvt.w == FLT_MAX ){
+		accumulator[pixelIndex] += sampleWeight;
+		return;
+	}
+
+	Surface surface;
+	surfaceInit(&surface, intersections + globalId, vertices, normals, uv, materialIndices);
+	float3 normal = dot(surface.geomNormal, rayDir) > 0.0f ? -surface.geomNormal : surface.geomNormal;
+
+	// As with shadeHits, the PRNG is keyed on the path pixel index
+	uint2 rndState = (uint2)(randSeed, pixelIndex);
+	float3 outRayDir = cosWeightedHemisphereGetSample(normal, randomGetSample2f(&rndState));
+
+	int occlusionRayIndex = atomic_inc(numOcclusionRays);
+	emissiveSamples[occlusionRayIndex] = (float3)(sampleWeight, sampleWeight, sampleWeight);
+	rayNew(occlusionRays + occlusionRayIndex, DISPLACE_BY_EPSILON(surface.point, normal, rayEpsilon), outRayDir, maxDist, rayPathIndex);
+}
+
+#endif
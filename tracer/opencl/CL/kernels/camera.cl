@@ -1,6 +1,38 @@
 #ifndef CAMERA_KERNEL_CL
 #define CAMERA_KERNEL_CL
 
+// This value must match the one defined in asset/scene/lens.go
+#define MAX_APERTURE_BLADES 16
+
+float2 apertureGetSample(const uint blades, const float rotation, float2 randSample);
+
+// Map a uniform random sample to a point on an aperture with unit circumradius.
+// Polygonal apertures are sampled uniformly by selecting one of the equal-area
+// triangles that fan out from the aperture center and sampling a point on it.
+float2 apertureGetSample(const uint blades, const float rotation, float2 randSample){
+	float c;
+	float s;
+	if( blades < 3 || blades > MAX_APERTURE_BLADES ){
+		s = sincos(C_TWO_TIMES_PI * randSample.y + rotation, &c);
+		return native_sqrt(randSample.x) * (float2)(c, s);
+	}
+
+	// Select the blade triangle and rescale the remaining sample fraction
+	float scaled = randSample.x * blades;
+	uint blade = min((uint)scaled, blades - 1);
+	float u = scaled - blade;
+
+	float bladeAngle = C_TWO_TIMES_PI / blades;
+	s = sincos(blade * bladeAngle + rotation, &c);
+	float2 v0 = (float2)(c, s);
+	s = sincos((blade + 1) * bladeAngle + rotation, &c);
+	float2 v1 = (float2)(c, s);
+
+	// Uniformly sample the triangle (center, v0, v1)
+	float sqrtU = native_sqrt(u);
+	return sqrtU * (1.0f - randSample.y) * v0 + sqrtU * randSample.y * v1;
+}
+
 // Generate primary rays.
 __kernel void generatePrimaryRays(
 		__global Ray *rays, 
@@ -11,12 +43,31 @@ __kernel void generatePrimaryRays(
 		const float4 frustrumBL,
 		const float4 frustrumBR,
 		const float3 eyePos,
+		// thin lens settings; a zero aperture radius disables depth of field
+		const float apertureRadius,
+		const float focusDistance,
+		const uint apertureBlades,
+		const float apertureRotation,
+		const float3 lensRight,
+		const float3 lensUp,
+		const float3 lensForward,
+		// near (x) and far (y) clip plane distances; a zero distance
+		// disables the respective plane
+		const float2 clipPlanes,
 		const float2 texelDims,
+		const uint blockX,
 		const uint blockY,
+		const uint blockW,
 		const uint blockH,
 		const uint frameW,
 		const uint frameH,
-		const uint randSeed
+		const uint randSeed,
+		const uint samplerType,
+		const uint sampleIndex,
+		__global const uint *sobolDirections,
+		// sub-pixel sample placement; see tracer/subpixel.go
+		const uint subpixelPattern,
+		__global const float2 *poissonPattern
 		){
 
 	uint2 globalId;
@@ -24,24 +75,27 @@ __kernel void generatePrimaryRays(
 	globalId.y = get_global_id(1);
 
 	if(globalId.x == 0 && globalId.y == 0){
-		*numRays = frameW * blockH;
+		*numRays = blockW * blockH;
 	}
 
-	if( globalId.x < frameW && globalId.y < blockH ){
-		uint index = (globalId.y * frameW) + globalId.x;
-		uint pixelIndex = ((globalId.y + blockY) * frameW) + globalId.x;
+	if( globalId.x < blockW && globalId.y < blockH ){
+		uint index = (globalId.y * blockW) + globalId.x;
+		uint pixelIndex = ((globalId.y + blockY) * frameW) + globalId.x + blockX;
 
 		// Apply stratified sampling using a tent filter. This will wrap our
-		// random numbers in the [-1, 1] range. X and Y point to the top corner
+		// sub-pixel samples in the [-1, 1] range. X and Y point to the top corner
 		// of the current texel so we need to add a bit of offset to get the coords
-		// into the [-0.5, 1.5] range.
-		uint2 rndState = globalId + randSeed;
-		float2 sample0 = randomGetSample2f(&rndState);
+		// into the [-0.5, 1.5] range. The PRNG is keyed on the frame pixel
+		// index so the generated samples do not depend on the block layout.
+		uint2 rndState = (uint2)(randSeed, pixelIndex);
+		float2 sample0 = subpixelPattern == SUBPIXEL_PATTERN_SAMPLER
+			? qmcGetSample2f(samplerType, sobolDirections, sampleIndex, SOBOL_CAMERA_DIM, SOBOL_MAX_DIMENSIONS, pixelIndex, &rndState)
+			: subpixelGetSample(subpixelPattern, sampleIndex, pixelIndex, poissonPattern);
 		float2 offset = (float2)(
 				sample0.x < 0.5f ? native_sqrt(2.0f * sample0.x) - 0.5f : 1.5f - native_sqrt(2.0f - 2.0f * sample0.x),
 				sample0.y < 0.5f ? native_sqrt(2.0f * sample0.y) - 0.5f : 1.5f - native_sqrt(2.0f - 2.0f * sample0.y)
 		);
-		float2 texel = ((float2)(globalId.x, globalId.y + blockY) + offset) * texelDims;
+		float2 texel = ((float2)(globalId.x + blockX, globalId.y + blockY) + offset) * texelDims;
 
 		// Get ray direction using trilinear interpolation
 		float4 dir = normalize(
@@ -52,7 +106,30 @@ __kernel void generatePrimaryRays(
 			)
 		);
 
-		rayNew(rays + index,  eyePos, dir.xyz, FLT_MAX, index);
+		// Trace thin lens rays from a point on the aperture towards the
+		// point where the pinhole ray crosses the focal plane
+		float3 rayOrigin = eyePos;
+		if( apertureRadius > 0.0f && focusDistance > 0.0f ){
+			float3 focusPoint = eyePos + dir.xyz * (focusDistance / dot(dir.xyz, lensForward));
+			float2 lensSample = apertureRadius * apertureGetSample(apertureBlades, apertureRotation, randomGetSample2f(&rndState));
+			rayOrigin = eyePos + lensSample.x * lensRight + lensSample.y * lensUp;
+			dir.xyz = normalize(focusPoint - rayOrigin);
+		}
+
+		// Move the ray origin to the near clip plane and stop the ray at
+		// the far clip plane. The planes are perpendicular to the view
+		// direction so the clip distances are scaled by the ray angle.
+		float maxDist = FLT_MAX;
+		float cosTheta = dot(dir.xyz, lensForward);
+		if( cosTheta > 0.0f ){
+			float tMin = clipPlanes.x > 0.0f ? clipPlanes.x / cosTheta : 0.0f;
+			if( clipPlanes.y > 0.0f ){
+				maxDist = clipPlanes.y / cosTheta - tMin;
+			}
+			rayOrigin += dir.xyz * tMin;
+		}
+
+		rayNew(rays + index, rayOrigin, dir.xyz, maxDist, index);
 		pathNew(paths + index, pixelIndex);
 	}
 }
@@ -95,6 +95,35 @@ __kernel void debugRayIntersectionNormals(
 	output[pixelIndex] = (uchar4)((uchar)val.x, (uchar)val.y, (uchar)val.z, 255);
 }
 
+// Map the per-ray traversal costs recorded by the intersection query kernels
+// to a blue -> green -> yellow -> red color ramp.
+__kernel void debugBvhHeatmap(
+		__global const int *numRays,
+		__global Path *paths,
+		__global uint *traversalCost,
+		const float invMaxCost,
+		__global uchar4 *output
+		){
+
+	int globalId = get_global_id(0);
+	if(globalId >= *numRays){
+		return;
+	}
+
+	float t = clamp((float)traversalCost[globalId] * invMaxCost, 0.0f, 1.0f);
+	float3 val;
+	if( t < 0.33f ){
+		val = mix((float3)(0.0f, 0.0f, 1.0f), (float3)(0.0f, 1.0f, 0.0f), t / 0.33f);
+	} else if( t < 0.66f ){
+		val = mix((float3)(0.0f, 1.0f, 0.0f), (float3)(1.0f, 1.0f, 0.0f), (t - 0.33f) / 0.33f);
+	} else {
+		val = mix((float3)(1.0f, 1.0f, 0.0f), (float3)(1.0f, 0.0f, 0.0f), (t - 0.66f) / 0.34f);
+	}
+
+	val *= 255.0f;
+	output[paths[globalId].pixelIndex] = (uchar4)((uchar)val.x, (uchar)val.y, (uchar)val.z, 255);
+}
+
 // Render emissive samples with optional masking for occluded/not-occluded rays.
 __kernel void debugEmissiveSamples(
 		__global Ray *rays,
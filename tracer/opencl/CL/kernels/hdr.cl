@@ -1,29 +1,103 @@
 #ifndef HDR_KERNEL_CL
 #define HDR_KERNEL_CL
 
+#define MAX_FIREFLY_RADIUS 3
+#define MAX_FIREFLY_WINDOW ((2 * MAX_FIREFLY_RADIUS + 1) * (2 * MAX_FIREFLY_RADIUS + 1))
+
+// Background modes; see tracer.BackgroundMode
+#define BACKGROUND_OPAQUE            0
+#define BACKGROUND_TRANSPARENT       1
+#define BACKGROUND_TRANSPARENT_BLACK 2
+
+float3 filterFirefly(__global float3 *accumulator, int x, int y, const uint frameW, const uint frameH, const float threshold, const uint radius);
+
+// Scale down a pixel whose luminance exceeds threshold times the median
+// luminance of its (2*radius+1)^2 neighborhood (clipped to the frame).
+// Pixels whose neighborhood median is zero are left untouched.
+float3 filterFirefly(__global float3 *accumulator, int x, int y, const uint frameW, const uint frameH, const float threshold, const uint radius){
+	float3 pixel = accumulator[y * frameW + x];
+	float lum = dot(pixel, (float3)(0.2126f, 0.7152f, 0.0722f));
+	if( threshold <= 0.0f || lum <= 0.0f ){
+		return pixel;
+	}
+
+	// Collect window luminances using insertion sort
+	float window[MAX_FIREFLY_WINDOW];
+	int count = 0;
+	int r = min((int)radius, MAX_FIREFLY_RADIUS);
+	for(int wy = y - r; wy <= y + r; wy++){
+		for(int wx = x - r; wx <= x + r; wx++){
+			if( wx < 0 || wy < 0 || wx >= (int)frameW || wy >= (int)frameH ){
+				continue;
+			}
+
+			float wLum = dot(accumulator[wy * frameW + wx], (float3)(0.2126f, 0.7152f, 0.0722f));
+			int i = count++;
+			for(; i > 0 && window[i-1] > wLum; i--){
+				window[i] = window[i-1];
+			}
+			window[i] = wLum;
+		}
+	}
+
+	// Without a lit neighborhood there is no reference luminance to clamp to
+	float median = window[count / 2];
+	if( median <= 0.0f ){
+		return pixel;
+	}
+
+	float maxLum = threshold * median;
+	return lum <= maxLum ? pixel : pixel * (maxLum / lum);
+}
+
 // Simple Reinhard tone-mapping
 __kernel void tonemapSimpleReinhard(
 	__global float3 *accumulator,
 	__global Path *paths,
 	__global uchar4 *frameBuffer,
 	const float sampleWeight,
-	const float exposure
+	const float exposure,
+	// Firefly filter settings; a zero threshold disables the filter
+	const uint frameW,
+	const uint frameH,
+	const float fireflyThreshold,
+	const uint fireflyRadius,
+	// Rows of the matrix for converting to the output color space
+	const float4 colorSpaceRow0,
+	const float4 colorSpaceRow1,
+	const float4 colorSpaceRow2,
+	// Background mode and per-pixel primary ray coverage; coverage is only
+	// read if the background is transparent
+	const uint backgroundMode,
+	__global float3 *coverageAccumulator
 		){
 
 			int globalId = get_global_id(0);
 
-			// Apply tone-mapping
-			float3 hdrColor = accumulator[globalId] * sampleWeight * exposure;
+			// Suppress fireflies, convert to output color space and apply tone-mapping
+			float3 hdrSample = filterFirefly(accumulator, globalId % frameW, globalId / frameW, frameW, frameH, fireflyThreshold, fireflyRadius);
+			float3 linearColor = hdrSample * sampleWeight * exposure;
+			float3 hdrColor = max((float3)(
+					dot(colorSpaceRow0.xyz, linearColor),
+					dot(colorSpaceRow1.xyz, linearColor),
+					dot(colorSpaceRow2.xyz, linearColor)
+					), 0.0f);
 			float3 mapped = hdrColor / (hdrColor + 1.0f);
 
 			// Apply gamma correction and scale
 			float3 normalizedOutput = clamp(pow(mapped, 1.0f / 2.2f), 0.0f, 1.0f) * 255.0f;
 
+			// Pixel alpha is the fraction of primary rays that hit the scene
+			uchar alpha = 255;
+			if( backgroundMode != BACKGROUND_OPAQUE ){
+				alpha = (uchar)(clamp(coverageAccumulator[globalId].x * sampleWeight, 0.0f, 1.0f) * 255.0f);
+			}
+
 			frameBuffer[globalId] = (uchar4)(
 					(uchar)normalizedOutput.r,
 					(uchar)normalizedOutput.g,
 					(uchar)normalizedOutput.b,
-					255 // alpha
+					alpha
 					);
 		}
 
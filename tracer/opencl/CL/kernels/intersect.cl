@@ -18,6 +18,11 @@
 #define RAY_VISIT_RIGHT_NODE 2
 #define RAY_VISIT_BOTH_NODES 3
 
+// If BVH_TRAVERSAL_COST is defined, the intersection query kernels record the
+// number of visited nodes and triangle tests for each ray into the
+// traversalCost buffer. This is used for rendering BVH heatmaps and is
+// disabled by default to avoid the extra memory writes.
+
 void printIntersection(Intersection *intersection);
 
 // Test for ray intersections with scene geometry and set an ouput flag to indicate
@@ -29,7 +34,10 @@ __kernel void rayIntersectionTest(
 		__global BvhNode* bvhNodes,
 		__global MeshInstance* meshInstances,
 		__global float4* vertexList,
-		__global int* hitFlag
+		__global int* hitFlag,
+		// Traversal stats; only updated if collectStats is set
+		const uint collectStats,
+		volatile __global uint *traversalStats
 		){
 
 	int globalId = get_global_id(0);
@@ -67,7 +75,11 @@ __kernel void rayIntersectionTest(
 	int wantRight;
 	int gotHit = 0;
 
+	uint nodeVisits = 0;
+	uint triangleTests = 0;
 	while(stackIndex > -1){
+		nodeVisits++;
+
 		if(BVH_IS_LEAF(curNode)){
 			numTriangles = BVH_TRIANGLE_COUNT(curNode);
 
@@ -90,6 +102,8 @@ __kernel void rayIntersectionTest(
 				// Intersect with all triangles using the Moller-Trumbore algorithm
 				triStartIndex = BVH_TRIANGLE_INDEX(curNode);
 				for(int vIndex = triStartIndex * 3; vIndex < (triStartIndex + numTriangles)*3;vIndex+=3){
+					triangleTests++;
+
 					v0 = vertexList[vIndex].xyz;
 					edge01 = vertexList[vIndex+1].xyz - v0;
 					edge02 = vertexList[vIndex+2].xyz - v0;
@@ -175,6 +189,11 @@ __kernel void rayIntersectionTest(
 		}
 	}
 	
+	if(collectStats){
+		atomic_add(&traversalStats[0], nodeVisits);
+		atomic_add(&traversalStats[1], triangleTests);
+	}
+
 	// Update hit flag
 	hitFlag[globalId] = gotHit;
 }
@@ -188,7 +207,12 @@ __kernel void rayIntersectionQuery(
 		__global MeshInstance* meshInstances,
 		__global float4* vertexList,
 		__global int* hitFlag,
-		__global Intersection* intersections
+		__global Intersection* intersections,
+		// Traversal stats; only updated if collectStats is set
+		const uint collectStats,
+		volatile __global uint *traversalStats,
+		// Per-ray traversal cost; only updated if BVH_TRAVERSAL_COST is defined
+		__global uint *traversalCost
 		){
 
 	int globalId = get_global_id(0);
@@ -228,7 +252,11 @@ __kernel void rayIntersectionQuery(
 
 	int wantLeft;
 	int wantRight;
+	uint nodeVisits = 0;
+	uint triangleTests = 0;
 	while(stackIndex > -1){
+		nodeVisits++;
+
 		if(BVH_IS_LEAF(curNode)){
 			numTriangles = BVH_TRIANGLE_COUNT(curNode);
 
@@ -251,6 +279,8 @@ __kernel void rayIntersectionQuery(
 				// Intersect with all triangles using the Moller-Trumbore algorithm
 				triStartIndex = BVH_TRIANGLE_INDEX(curNode);
 				for(int vIndex = triStartIndex * 3; vIndex < (triStartIndex + numTriangles)*3;vIndex+=3){
+					triangleTests++;
+
 					v0 = vertexList[vIndex].xyz;
 					edge01 = vertexList[vIndex+1].xyz - v0;
 					edge02 = vertexList[vIndex+2].xyz - v0;
@@ -286,6 +316,7 @@ __kernel void rayIntersectionQuery(
 								t
 						);
 						intersection.triIndex = vIndex / 3;
+						intersection.frontFace = det > 0.0f;
 						intersection.meshInstance = meshInstanceId;
 					}
 				}
@@ -341,6 +372,15 @@ __kernel void rayIntersectionQuery(
 		}
 	}
 			
+	if(collectStats){
+		atomic_add(&traversalStats[0], nodeVisits);
+		atomic_add(&traversalStats[1], triangleTests);
+	}
+
+#ifdef BVH_TRAVERSAL_COST
+	traversalCost[globalId] = nodeVisits + triangleTests;
+#endif
+
 	// Update hit flag
 	hitFlag[globalId] = intersection.wuvt.w < ray.origin.w ? 1 : 0;
 	intersections[globalId] = intersection;
@@ -357,7 +397,12 @@ __kernel void rayPacketIntersectionQuery(
 		__global MeshInstance* meshInstances,
 		__global float4* vertexList,
 		__global int* hitFlag,
-		__global Intersection* intersections
+		__global Intersection* intersections,
+		// Traversal stats; only updated if collectStats is set
+		const uint collectStats,
+		volatile __global uint *traversalStats,
+		// Per-ray traversal cost; only updated if BVH_TRAVERSAL_COST is defined
+		__global uint *traversalCost
 		){
 
 	int globalId = get_global_id(0);
@@ -405,7 +450,11 @@ __kernel void rayPacketIntersectionQuery(
 	
 	barrier(CLK_LOCAL_MEM_FENCE);
 
+	uint nodeVisits = 0;
+	uint triangleTests = 0;
 	while(stackIndex > -1){
+		nodeVisits++;
+
 		if(BVH_IS_LEAF(curNode)){
 			numTriangles = BVH_TRIANGLE_COUNT(curNode);
 
@@ -432,6 +481,8 @@ __kernel void rayPacketIntersectionQuery(
 				// Intersect with all triangles using the Moller-Trumbore algorithm
 				triStartIndex = BVH_TRIANGLE_INDEX(curNode);
 				for(int vIndex = triStartIndex * 3; vIndex < (triStartIndex + numTriangles)*3;vIndex+=3){
+					triangleTests++;
+
 					// Fetch vertex data in parallel
 					if(localId < 3 ){
 						vert[localId] = vertexList[vIndex + localId].xyz;
@@ -466,6 +517,7 @@ __kernel void rayPacketIntersectionQuery(
 									t
 							);
 							intersection.triIndex = vIndex / 3;
+							intersection.frontFace = det > 0.0f;
 							intersection.meshInstance = meshInstanceId;
 						}
 					}
@@ -569,6 +621,15 @@ __kernel void rayPacketIntersectionQuery(
 		barrier(CLK_LOCAL_MEM_FENCE);
 	}
 			
+	if(collectStats){
+		atomic_add(&traversalStats[0], nodeVisits);
+		atomic_add(&traversalStats[1], triangleTests);
+	}
+
+#ifdef BVH_TRAVERSAL_COST
+	traversalCost[globalId] = nodeVisits + triangleTests;
+#endif
+
 	// Update hit flag
 	hitFlag[globalId] = intersection.wuvt.w < ray.origin.w ? 1 : 0;
 	intersections[globalId] = intersection;
@@ -5,6 +5,8 @@
 #include "hdr.cl"
 #include "intersect.cl"
 #include "pt_integrator.cl"
+#include "aov_integrator.cl"
+#include "photon.cl"
 #include "accumulator.cl"
 #include "debug.cl"
 
@@ -0,0 +1,272 @@
+#ifndef PHOTON_KERNEL_CL
+#define PHOTON_KERNEL_CL
+
+float3 photonMapGather(__global Photon *photons, __global uint *cellStart, float3 gridMin, float cellSize, int3 gridDims, float radius, float3 point);
+
+// Emit photons from the scene area lights. Each photon ray is associated with a
+// path whose throughput stores the photon power. The power of all emitted
+// photons is normalized by the total number of photons emitted for the
+// photon map.
+__kernel void emitPhotons(
+		__global Ray *rays,
+		__global Path *paths,
+		// scene data
+		__global float4 *vertices,
+		__global float4 *normals,
+		__global float2 *uv,
+		__global MaterialNode *materialNodes,
+		__global Emissive *emissives,
+		const uint numEmissives,
+		// texture data
+		__global TextureMetadata *texMeta,
+		__global uchar *texData,
+		// state
+		const uint randSeed,
+		const uint numPhotons,
+		const uint totalPhotons,
+		const float rayEpsilon
+		){
+
+	int globalId = get_global_id(0);
+	if( globalId >= numPhotons ){
+		return;
+	}
+
+	uint2 rndState = (uint2)(randSeed, globalId);
+	float2 sample0 = randomGetSample2f(&rndState);
+	float2 sample1 = randomGetSample2f(&rndState);
+	float2 sample2 = randomGetSample2f(&rndState);
+
+	float selectionPdf;
+	__global Emissive *emissive = emissives + emissiveSelect(numEmissives, sample0.x, &selectionPdf);
+
+	pathNew(paths + globalId, 0);
+
+	// Only area lights can emit photons
+	if( emissive->type != EMISSIVE_TYPE_AREA_LIGHT ){
+		pathSetThroughput(paths + globalId, (float3)(0.0f, 0.0f, 0.0f));
+		rayNew(rays + globalId, (float3)(0.0f, 0.0f, 0.0f), (float3)(0.0f, 1.0f, 0.0f), 0.0f, globalId);
+		return;
+	}
+
+	// Select a random point on the emissive with PDF=1/area and get its *world* xyz/normal coordinates
+	float r1sqrt = native_sqrt(sample1.x);
+	float ru = (1.0f - sample1.y) * r1sqrt;
+	float rv = sample1.y * r1sqrt;
+	float3 wuv = (float3)(1.0f - ru - rv, ru, rv);
+	int offset = emissive->triIndex * 3;
+
+	float3 emissivePoint = mul4x1(
+			(wuv.x * vertices[offset] + wuv.y * vertices[offset+1] + wuv.z * vertices[offset+2]).xyz,
+			emissive->transformMat0,
+			emissive->transformMat1,
+			emissive->transformMat2,
+			emissive->transformMat3
+			);
+
+	float3 emissiveNormal = normalize(mul3x1(
+			(wuv.x * normals[offset] + wuv.y * normals[offset+1] + wuv.z * normals[offset+2]).xyz,
+			emissive->transformMat0.xyz,
+			emissive->transformMat1.xyz,
+			emissive->transformMat2.xyz
+			));
+
+	float2 emissiveUV = wuv.x * uv[offset] +
+		wuv.y * uv[offset+1] +
+		wuv.z * uv[offset+2];
+
+	// Emit photon using a cosine weighted direction. The photon power is:
+	// Le * PI * area / (selectionPdf * totalPhotons)
+	MaterialNode matNode = materialNodes[emissive->matNodeIndex];
+	float3 ke = matNode.scale * matGetSample3f(emissiveUV, matNode.radiance, matNode.radianceTex, texMeta, texData);
+	float3 power = ke * C_PI * emissive->area / (selectionPdf * (float)totalPhotons);
+
+	// Flip the normal for back-facing emission. Two-sided emissives pick a
+	// side at random and the photon power is doubled to account for the
+	// side selection probability.
+	if( matNode.emissionSide == EMISSION_SIDE_BACK ){
+		emissiveNormal = -emissiveNormal;
+	} else if( matNode.emissionSide == EMISSION_SIDE_BOTH ){
+		emissiveNormal = sample0.y < 0.5f ? emissiveNormal : -emissiveNormal;
+		power *= 2.0f;
+	}
+
+	pathSetThroughput(paths + globalId, power);
+	rayNew(rays + globalId, DISPLACE_BY_EPSILON(emissivePoint, emissiveNormal, rayEpsilon), cosWeightedHemisphereGetSample(emissiveNormal, sample2), FLT_MAX, globalId);
+}
+
+// Shade photon ray hits. Photons bouncing off singular surfaces generate an
+// indirect ray and are flagged as caustic photons. Caustic photons hitting
+// a diffuse surface are stored in the photon buffer. All other photon paths
+// are terminated.
+__kernel void shadePhotonHits(
+		__global Ray *rays,
+		global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		// scene data
+		__global float4 *vertices,
+		__global float4 *normals,
+		__global float2 *uv,
+		__global float4 *uvTransforms,
+		const uint hasUVTransforms,
+		__global uint *materialIndices,
+		__global MaterialNode *materialNodes,
+		// texture data
+		__global TextureMetadata *texMeta,
+		__global uchar *texData,
+		// state
+		const uint randSeed,
+		const float rayEpsilon,
+		// indirect rays
+		__global Ray *indirectRays,
+		volatile __global int *numIndirectRays,
+		// stored photons
+		__global Photon *photons,
+		volatile __global int *numPhotons,
+		const uint maxPhotons
+		){
+
+	int globalId = get_global_id(0);
+	if( globalId >= *numRays || !hitFlags[globalId] ){
+		return;
+	}
+
+	uint rayPathIndex;
+	float3 inRayDir = -rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
+	float3 power = paths[rayPathIndex].throughput;
+	if( MAX_VEC3_COMPONENT(power) <= 0.0f ){
+		return;
+	}
+
+	uint2 rndState = (uint2)(randSeed, globalId);
+
+	Surface surface;
+	surfaceInit(&surface, intersections + globalId, vertices, normals, uv, materialIndices);
+	surfaceApplyUVTransform(&surface, uvTransforms, hasUVTransforms);
+
+	MaterialNode materialNode;
+	float3 bxdfTint = (float3)(1.0f, 1.0f, 1.0f);
+	matSelectNode(paths + rayPathIndex, &surface, inRayDir, &materialNode, &bxdfTint, materialNodes, &rndState, texMeta, texData);
+
+	if( BXDF_IS_SINGULAR(materialNode.type) ){
+		float3 bxdfOutRayDir;
+		float bxdfPdf;
+		float3 bxdfSample = bxdfGetSample(&surface, &materialNode, texMeta, texData, randomGetSample2f(&rndState), inRayDir, &bxdfOutRayDir, &bxdfPdf);
+		float3 throughput = bxdfSample * bxdfTint * fabs(dot(surface.normal, bxdfOutRayDir));
+		if( MAX_VEC3_COMPONENT(throughput) <= 0.0f || bxdfPdf <= 0.0f ){
+			return;
+		}
+
+		pathSetThroughput(paths + rayPathIndex, power * throughput / bxdfPdf);
+		paths[rayPathIndex].flags |= PATH_FLAG_CAUSTIC;
+
+		float displaceDir = sign(dot(surface.normal, bxdfOutRayDir));
+		int rayIndex = atomic_inc(numIndirectRays);
+		rayNew(indirectRays + rayIndex, DISPLACE_BY_EPSILON(surface.point, surface.normal * displaceDir, rayEpsilon), bxdfOutRayDir, FLT_MAX, rayPathIndex);
+		return;
+	}
+
+	if( materialNode.type == BXDF_TYPE_DIFFUSE && (paths[rayPathIndex].flags & PATH_FLAG_CAUSTIC) ){
+		int photonIndex = atomic_inc(numPhotons);
+		if( (uint)photonIndex < maxPhotons ){
+			photons[photonIndex].position = surface.point;
+			photons[photonIndex].power = power;
+		}
+	}
+}
+
+// Estimate caustic radiance at the first non-singular path vertex using the
+// caustic photon map. This kernel only processes paths whose current hit is
+// reached via zero or more singular bounces and lands on a diffuse surface.
+__kernel void gatherCausticPhotons(
+		__global Ray *rays,
+		global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		// scene data
+		__global float4 *vertices,
+		__global float4 *normals,
+		__global float2 *uv,
+		__global float4 *uvTransforms,
+		const uint hasUVTransforms,
+		__global uint *materialIndices,
+		__global MaterialNode *materialNodes,
+		// texture data
+		__global TextureMetadata *texMeta,
+		__global uchar *texData,
+		// state
+		const uint randSeed,
+		// photon map
+		__global Photon *photons,
+		__global uint *photonCellStart,
+		const float3 gridMin,
+		const float cellSize,
+		const uint gridDimX,
+		const uint gridDimY,
+		const uint gridDimZ,
+		const float radius,
+		// output accumulator
+		__global float3 *accumulator,
+		// non-finite sample guard policy and drop counter
+		const uint nonFiniteGuard,
+		volatile __global uint *nonFiniteCounter
+		){
+
+	int globalId = get_global_id(0);
+	if( globalId >= *numRays || !hitFlags[globalId] ){
+		return;
+	}
+
+	uint rayPathIndex;
+	float3 inRayDir = -rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
+	if( paths[rayPathIndex].flags & PATH_FLAG_NON_SINGULAR_BOUNCE ){
+		return;
+	}
+
+	uint2 rndState = (uint2)(randSeed, globalId);
+
+	Surface surface;
+	surfaceInit(&surface, intersections + globalId, vertices, normals, uv, materialIndices);
+	surfaceApplyUVTransform(&surface, uvTransforms, hasUVTransforms);
+
+	MaterialNode materialNode;
+	float3 bxdfTint = (float3)(1.0f, 1.0f, 1.0f);
+	matSelectNode(paths + rayPathIndex, &surface, inRayDir, &materialNode, &bxdfTint, materialNodes, &rndState, texMeta, texData);
+	if( materialNode.type != BXDF_TYPE_DIFFUSE ){
+		return;
+	}
+
+	float3 irradiance = photonMapGather(photons, photonCellStart, gridMin, cellSize, (int3)(gridDimX, gridDimY, gridDimZ), radius, surface.point);
+	float3 kd = matGetSample3f(surface.uv, materialNode.reflectance, materialNode.reflectanceTex, texMeta, texData);
+	accumulateRadiance(accumulator, paths[rayPathIndex].pixelIndex, paths[rayPathIndex].throughput * bxdfTint * kd * C_1_PI * irradiance, nonFiniteGuard, nonFiniteCounter);
+}
+
+// Estimate the irradiance at a point by summing the power of all photons
+// within the gather radius and dividing by the gather disc area.
+float3 photonMapGather(__global Photon *photons, __global uint *cellStart, float3 gridMin, float cellSize, int3 gridDims, float radius, float3 point){
+	float3 power = (float3)(0.0f, 0.0f, 0.0f);
+	float radiusSq = radius * radius;
+
+	int3 minCell = clamp(convert_int3(floor((point - radius - gridMin) / cellSize)), (int3)(0, 0, 0), gridDims - 1);
+	int3 maxCell = clamp(convert_int3(floor((point + radius - gridMin) / cellSize)), (int3)(0, 0, 0), gridDims - 1);
+	for(int z = minCell.z; z <= maxCell.z; z++){
+		for(int y = minCell.y; y <= maxCell.y; y++){
+			for(int x = minCell.x; x <= maxCell.x; x++){
+				uint cell = x + gridDims.x * (y + gridDims.y * z);
+				for(uint index = cellStart[cell]; index < cellStart[cell+1]; index++){
+					float3 delta = photons[index].position - point;
+					if( dot(delta, delta) <= radiusSq ){
+						power += photons[index].power;
+					}
+				}
+			}
+		}
+	}
+
+	return power / (C_PI * radiusSq);
+}
+
+#endif
@@ -3,11 +3,50 @@
 
 #define MAX_VEC3_COMPONENT(v) (max(v.x,max(v.y,v.z)))
 #define MIN_VEC3_COMPONENT(v) (min(v.x,min(v.y,v.z)))
-#define DISPLACE_BY_EPSILON(v,n) (v + n * INTERSECTION_EPSILON)
+#define DISPLACE_BY_EPSILON(v,n,eps) (v + n * (eps))
 
 #define BALANCE_HEURISTIC(a,b) a/(a+b)
 #define POWER_HEURISTIC(a,b) (a*a)/(a*a+b*b)
 
+// Scale radiance so that none of its components exceeds maxValue while
+// preserving its hue. Clamping is disabled if maxValue is 0.
+float3 clampRadiance(float3 radiance, float maxValue){
+	float maxComponent = MAX_VEC3_COMPONENT(radiance);
+	return maxValue > 0.0f && maxComponent > maxValue ? radiance * (maxValue / maxComponent) : radiance;
+}
+
+// Check whether an emissive illuminates a mesh instance by looking up the
+// instance bit in the emissive's row of the light link table.
+bool lightLinkAffects(__global uint *lightLinks, const uint lightLinkStride, uint emissiveIndex, uint meshInstance){
+	if( lightLinkStride == 0 || meshInstance / 32 >= lightLinkStride ){
+		return true;
+	}
+	return (lightLinks[emissiveIndex * lightLinkStride + meshInstance / 32] & (1u << (meshInstance % 32))) != 0;
+}
+
+// Find the emissive index of a mesh instance triangle by binary searching the
+// light link emitter list (instance, primitive, emissive index) which is
+// sorted by instance and primitive index. Returns -1 if the triangle is not
+// an area light.
+int lightLinkFindEmitter(__global uint4 *emitters, const uint numEmitters, uint meshInstance, uint triIndex){
+	int lo = 0;
+	int hi = (int)numEmitters - 1;
+	while( lo <= hi ){
+		int mid = (lo + hi) / 2;
+		uint4 emitter = emitters[mid];
+		if( emitter.x == meshInstance && emitter.y == triIndex ){
+			return (int)emitter.z;
+		}
+
+		if( emitter.x < meshInstance || (emitter.x == meshInstance && emitter.y < triIndex) ){
+			lo = mid + 1;
+		} else {
+			hi = mid - 1;
+		}
+	}
+	return -1;
+}
+
 // For each intersection, calculate an outgoing indirect ray based on the 
 // surface PDF and also perform direct light sampling emitting occlusion
 // rays and light samples. 
@@ -24,10 +63,32 @@ __kernel void shadeHits(
 		__global float4 *vertices,
 		__global float4 *normals,
 		__global float2 *uv,
+		__global float4 *uvTransforms,
+		const uint hasUVTransforms,
+		__global float4 *vertexColors,
+		const uint hasVertexColors,
 		__global uint *materialIndices,
 		__global MaterialNode *materialNodes,
+		__global float *materialOpacities,
+		const uint hasMaterialOpacities,
 		__global Emissive *emissives,
 		const uint numEmissives,
+		// light link table and the sorted area light emitter list; a
+		// zero stride disables light linking
+		__global uint *lightLinks,
+		const uint lightLinkStride,
+		__global uint4 *lightLinkEmitters,
+		const uint numLightLinkEmitters,
+		// environment light importance sampling table; a zero width
+		// disables importance sampling
+		__global AliasEntry *envAliasTable,
+		const uint envAliasW,
+		const uint envAliasH,
+		// ground color (xyz) and sine of the horizon elevation (w)
+		const float4 groundProjection,
+		// global fog color (xyz) and density (w); a zero density
+		// disables the fog
+		const float4 globalFog,
 		// texture data
 		__global TextureMetadata *texMeta,
 		__global uchar *texData,
@@ -35,6 +96,25 @@ __kernel void shadeHits(
 		const uint bounce,
 		const uint minBouncesForRR,
 		const uint randSeed,
+		const uint samplerType,
+		const uint sampleIndex,
+		__global const uint *sobolDirections,
+		// max number of sobol dimensions per path; see tracer/sobol.go
+		const uint sobolDimensionBudget,
+		const uint skipCausticPaths,
+		// clamp thresholds for emissive hits and light samples
+		const float emissiveHitClamp,
+		const float lightSampleClamp,
+		// min roughness for glossy bxdfs after the first diffuse bounce;
+		// regularization is disabled if set to 0
+		const float roughnessClamp,
+		// glossy bounce budget and the policy for glossy bounces past it;
+		// the budget is disabled if set to 0
+		const uint maxGlossyBounces,
+		const uint glossyBudgetPolicy,
+		// offset for secondary ray origins; occlusion rays are clipped by
+		// a multiple of this value before reaching the light sample
+		const float rayEpsilon,
 		// occlusion rays and samples
 		__global Ray *occlusionRays,
 		volatile __global int *numOcclusionRays,
@@ -43,7 +123,10 @@ __kernel void shadeHits(
 		__global Ray *indirectRays,
 		volatile __global int *numIndirectRays,
 		// output accumulator
-		__global float3 *accumulator
+		__global float3 *accumulator,
+		// non-finite sample guard policy and drop counter
+		const uint nonFiniteGuard,
+		volatile __global uint *nonFiniteCounter
 		){
 
 	// Local counters used to perform atomics inside this WG
@@ -77,39 +160,98 @@ __kernel void shadeHits(
 			bxdfPdf = 1.0f;
 			bxdfWeight = 1.0f;
 
-			// Init PRNG and generate required samples
-			uint2 rndState = (uint2)(randSeed, globalId);
-			float2 sample0 = randomGetSample2f(&rndState);
-			float2 sample1 = randomGetSample2f(&rndState);
-			float2 sample2 = randomGetSample2f(&rndState);
-
 			// Load incoming ray direction and invert it so it points away
 			// from the surface. All BxDF formulas use in/out rays that 
 			// are going outwards from the surface.
 			float3 inRayDir = -rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
 			curPathThroughput = paths[rayPathIndex].throughput;
 
-			// Fill surface data and calculate cos(n, inRay)
+			// Attenuate the path by the global fog along the incoming ray
+			// and add the radiance that the fog scatters towards it
+			if( globalFog.w > 0.0f ){
+				float fogTransmittance = exp(-globalFog.w * intersections[globalId].wuvt.w);
+				accumulateRadiance(accumulator, paths[rayPathIndex].pixelIndex, clampRadiance(curPathThroughput * globalFog.xyz * (1.0f - fogTransmittance), emissiveHitClamp), nonFiniteGuard, nonFiniteCounter);
+				curPathThroughput *= fogTransmittance;
+				pathSetThroughput(paths + rayPathIndex, curPathThroughput);
+			}
+
+			// Init PRNG and generate required samples. Each bounce consumes
+			// a fixed set of sample dimensions so that dimension allocation
+			// remains consistent across paths. The PRNG is keyed on the
+			// path pixel index rather than the ray index which depends on
+			// the block layout and the order of ray compaction.
+			uint pixelIndex = paths[rayPathIndex].pixelIndex;
+			uint2 rndState = (uint2)(randSeed, pixelIndex);
+			uint bounceDim = SOBOL_BOUNCE_DIM_OFFSET + bounce * SOBOL_DIMS_PER_BOUNCE;
+			float2 sample0 = qmcGetSample2f(samplerType, sobolDirections, sampleIndex, bounceDim, sobolDimensionBudget, pixelIndex, &rndState);
+			float2 sample1 = qmcGetSample2f(samplerType, sobolDirections, sampleIndex, bounceDim + 2, sobolDimensionBudget, pixelIndex, &rndState);
+			float2 sample2 = qmcGetSample2f(samplerType, sobolDirections, sampleIndex, bounceDim + 4, sobolDimensionBudget, pixelIndex, &rndState);
+
+			// Fill surface data
 			surfaceInit(&surface, intersections + globalId, vertices, normals, uv, materialIndices);
+			surfaceApplyUVTransform(&surface, uvTransforms, hasUVTransforms);
+
+			// Rays pass straight through semi-transparent surfaces with
+			// a probability of 1-opacity. Such paths are not shaded and
+			// retain their throughput so the pixel converges to a blend
+			// of the surface and whatever lies behind it.
+			float opacity = hasMaterialOpacities ? materialOpacities[surface.matNodeIndex] : 1.0f;
+			bool passThrough = opacity < 1.0f && sample2.y >= opacity;
 
 			// Select material
 			MaterialNode materialNode;
 			matSelectNode(paths + rayPathIndex, &surface, inRayDir, &materialNode, &bxdfTint, materialNodes, &rndState, texMeta, texData);
 
-			float inRayDotNormal = dot(inRayDir, surface.normal);
-
-			// Check if we hit an emissive node. If so, we need to accumulate implicit
-			// light and terminate the path.
-			if( BXDF_IS_EMISSIVE(materialNode.type) ){
-				// Make sure that the incoming ray is facing the emissive.
-				if( inRayDotNormal > 0.0f ){
-					accumulator[rayPathIndex] += curPathThroughput * materialNode.scale * matGetSample3f(surface.uv, materialNode.radiance, materialNode.radianceTex, texMeta, texData);
+			// Diffuse nodes may replace their reflectance with the
+			// interpolated vertex color
+			if( hasVertexColors && materialNode.type == BXDF_TYPE_DIFFUSE && materialNode.useVertexColors == 1 ){
+				materialNode.reflectance = surfaceGetVertexColor(intersections + globalId, vertexColors);
+				materialNode.reflectanceTex = -1;
+			}
+
+			// Skip deep glossy interreflections once the path exhausts its
+			// glossy bounce budget
+			bool withinGlossyBudget = passThrough || matApplyGlossyBudget(&materialNode, paths + rayPathIndex, maxGlossyBounces, glossyBudgetPolicy);
+
+			// Regularize glossy bxdfs once the path has scattered off a
+			// diffuse surface so that caustic paths can be resolved by
+			// light sampling instead of producing fireflies
+			if( roughnessClamp > 0.0f && (paths[rayPathIndex].flags & PATH_FLAG_DIFFUSE_BOUNCE) ){
+				matRegularizeRoughness(&materialNode, surface.uv, roughnessClamp, texMeta, texData);
+			}
+
+			// Keep the (possibly mapped) shading normal consistent with
+			// the geometric normal for the incoming direction
+			surface.normal = surfaceConsistentNormal(surface.normal, surface.geomNormal, inRayDir);
+
+			if( passThrough ){
+				bxdfOutRayDir = -inRayDir;
+				outBxdfRayOrigin = DISPLACE_BY_EPSILON(surface.point, surface.normal * sign(dot(surface.normal, bxdfOutRayDir)), rayEpsilon);
+				wgIndirectRayIndex = atomic_inc(&wgNumIndirectRays);
+			} else if( BXDF_IS_EMISSIVE(materialNode.type) ){
+				// Check if we hit an emissive node. If so, we need to accumulate implicit
+				// light and terminate the path.
+				// Make sure that the emissive emits towards the incoming ray. If
+				// the caustics pass is enabled, skip caustic paths as their
+				// contribution is provided by the photon map.
+				// Emissives reached by bxdf sampling only contribute if
+				// they are linked to the instance that scattered the path.
+				bool linked = bounce == 0 || lightLinkStride == 0;
+				if( !linked ){
+					int hitEmissiveIndex = lightLinkFindEmitter(lightLinkEmitters, numLightLinkEmitters, intersections[globalId].meshInstance, intersections[globalId].triIndex);
+					linked = hitEmissiveIndex == -1 || lightLinkAffects(lightLinks, lightLinkStride, hitEmissiveIndex, paths[rayPathIndex].lastInstance);
+				}
+				if( linked && emissiveEmitsTowards(materialNode.emissionSide, dot(inRayDir, surface.geomNormal)) && !(skipCausticPaths && pathIsGatheredCaustic(paths + rayPathIndex)) ){
+					// The emission falloff only attenuates the light cast by
+					// the emissive and not its visibility to the camera
+					float falloff = bounce > 0 ? emissiveFalloff(materialNode.emissionFalloff, intersections[globalId].wuvt.w) : 1.0f;
+					accumulateRadiance(accumulator, pixelIndex, clampRadiance(curPathThroughput * materialNode.scale * falloff * matGetSample3f(surface.uv, materialNode.radiance, materialNode.radianceTex, texMeta, texData), emissiveHitClamp), nonFiniteGuard, nonFiniteCounter);
 				}
 			} else {
 				// Implement RR to terminate paths with no significant contribution
 				// killing paths with a probability less than sample2.x while also
 				// boosting surving paths by the same probablility.
-				bool rejectSample = materialNode.type == BXDF_INVALID;
+				bool rejectSample = materialNode.type == BXDF_INVALID || !withinGlossyBudget;
 				if(bounce >= minBouncesForRR) {
 					float rrProbability = max(
 							// convert throughput to luminance
@@ -133,14 +275,28 @@ __kernel void shadeHits(
 					// material is refractive and we are hitting it from the outside we 
 					// need to ensure that the outgoing ray starts inside the surface.
 					float displaceDir = sign(dot(surface.normal, bxdfOutRayDir));
-					outBxdfRayOrigin = DISPLACE_BY_EPSILON(surface.point, surface.normal * displaceDir);
+					outBxdfRayOrigin = DISPLACE_BY_EPSILON(surface.point, surface.normal * displaceDir, rayEpsilon);
 					// The emissive ray always starts away from the surface. This allows us to shade BTDFs
-					outEmissiveRayOrigin = DISPLACE_BY_EPSILON(surface.point, surface.normal);
+					outEmissiveRayOrigin = DISPLACE_BY_EPSILON(surface.point, surface.normal, rayEpsilon);
 
 					// Select and sample emissive source
 					int emissiveIndex = numEmissives > 0 ? emissiveSelect(numEmissives, sample1.x, &emissiveSelectionPdf) : -1;
+					// Skip lights that are not linked to the shaded instance
+					if( emissiveIndex > -1 && !lightLinkAffects(lightLinks, lightLinkStride, emissiveIndex, intersections[globalId].meshInstance) ){
+						emissiveIndex = -1;
+						emissivePdf = 0.0f;
+					}
 					if( emissiveIndex > -1 ){
-						emissiveSample = emissiveGetSample(&surface, emissives + emissiveIndex, vertices, normals, uv, materialNodes, texMeta, texData, sample1, &emissiveOutRayDir, &emissivePdf, &distToEmissive);
+						emissiveSample = emissiveGetSample(&surface, emissives + emissiveIndex, vertices, normals, uv, envAliasTable, envAliasW, envAliasH, materialNodes, texMeta, texData, sample1, &emissiveOutRayDir, &emissivePdf, &distToEmissive);
+						// Environment samples below the horizon see the ground instead
+						if( emissives[emissiveIndex].type == EMISSIVE_TYPE_ENVIRONMENT_LIGHT && groundOccludes(emissiveOutRayDir, groundProjection) ){
+							emissiveSample = groundProjection.xyz * C_1_PI;
+						}
+
+						// Light samples from area lights are attenuated by the fog
+						if( globalFog.w > 0.0f && distToEmissive < FLT_MAX ){
+							emissiveSample *= exp(-globalFog.w * distToEmissive);
+						}
 
 						// MIS: we already have a PDF for generating emissiveOutRayDir.
 						// Calculate a PDF for the BXDF sampler generating the same ray 
@@ -148,9 +304,15 @@ __kernel void shadeHits(
 						bxdfEmissivePdf = bxdfGetPdf(&surface, &materialNode, texMeta, texData, inRayDir, emissiveOutRayDir);
 						emissiveWeight = POWER_HEURISTIC(emissivePdf, bxdfEmissivePdf);
 
+						// Directional lights are never hit by BXDF rays so their
+						// samples are the only strategy that can reach them
+						if( emissives[emissiveIndex].type == EMISSIVE_TYPE_DIRECTIONAL_LIGHT ){
+							emissiveWeight = 1.0f;
+						}
+
 						// We use the same approach to calculate a weight for the BXDF sample by 
 						// calculating the PDF for the emissive sampler generating bxdfOutRayDir
-						emissiveBxdfPdf = emissiveGetPdf(&surface, emissives + emissiveIndex, vertices, normals, uv, materialNodes, texMeta, texData, bxdfOutRayDir);
+						emissiveBxdfPdf = emissiveGetPdf(&surface, emissives + emissiveIndex, vertices, normals, uv, envAliasTable, envAliasW, envAliasH, materialNodes, texMeta, texData, bxdfOutRayDir);
 						bxdfWeight = POWER_HEURISTIC(bxdfPdf, emissiveBxdfPdf);
 					}
 
@@ -158,7 +320,8 @@ __kernel void shadeHits(
 					float nDotEmissiveOutRay = max(0.0f, dot(surface.normal, emissiveOutRayDir));
 					if( MAX_VEC3_COMPONENT(emissiveSample) > 0.0f && emissivePdf > 0.0f && nDotEmissiveOutRay > 0.0f){
 						bxdfEmissiveSample = bxdfEval(&surface, &materialNode, texMeta, texData, inRayDir, emissiveOutRayDir);
-						emissiveSample *= emissiveWeight * bxdfEmissiveSample * curPathThroughput * nDotEmissiveOutRay / (emissivePdf * emissiveSelectionPdf);
+						emissiveSample *= emissiveWeight * bxdfEmissiveSample * bxdfTint * curPathThroughput * nDotEmissiveOutRay / (emissivePdf * emissiveSelectionPdf);
+						emissiveSample = clampRadiance(emissiveSample, lightSampleClamp);
 						wgOcclusionRayIndex = MAX_VEC3_COMPONENT(emissiveSample) > 0.0f ? atomic_inc(&wgNumOcclusionRays) : -1;
 					}
 
@@ -173,6 +336,13 @@ __kernel void shadeHits(
 					float3 throughput = bxdfWeight * bxdfSample * bxdfTint * fabs(dot(surface.normal, bxdfOutRayDir));
 					if (MAX_VEC3_COMPONENT(throughput) > 0.0f && bxdfPdf > 0.0f){
 						pathSetThroughput(paths + rayPathIndex, curPathThroughput * throughput / bxdfPdf);
+						paths[rayPathIndex].lastInstance = intersections[globalId].meshInstance;
+						if( materialNode.type == BXDF_TYPE_DIFFUSE || materialNode.type == BXDF_TYPE_SUBSURFACE ){
+							paths[rayPathIndex].flags |= PATH_FLAG_DIFFUSE_BOUNCE;
+						}
+						if( skipCausticPaths ){
+							pathUpdateCausticFlags(paths + rayPathIndex, BXDF_IS_SINGULAR(materialNode.type), materialNode.type == BXDF_TYPE_DIFFUSE);
+						}
 						wgIndirectRayIndex = atomic_inc(&wgNumIndirectRays);
 					} 
 				} // if(!rejectSample)
@@ -200,7 +370,7 @@ __kernel void shadeHits(
 	if( wgOcclusionRayIndex != -1 ){
 		wgOcclusionRayIndex += wgNumOcclusionRays;
 		emissiveSamples[wgOcclusionRayIndex] = emissiveSample;
-		rayNew(occlusionRays + wgOcclusionRayIndex, outEmissiveRayOrigin, emissiveOutRayDir, distToEmissive - INTERSECTION_WITH_LIGHT_EPSILON, rayPathIndex);
+		rayNew(occlusionRays + wgOcclusionRayIndex, outEmissiveRayOrigin, emissiveOutRayDir, distToEmissive - rayEpsilon * LIGHT_CLIP_EPSILON_SCALE, rayPathIndex);
 	}
 
 	// Emit indirect ray
@@ -218,11 +388,18 @@ __kernel void shadePrimaryRayMisses(
 		__global uint *hitFlags,
 		__global MaterialNode *materialNodes,
 		const uint sceneDiffuseMatNodeIndex,
+		const float envYaw,
+		// ground color (xyz) and sine of the horizon elevation (w)
+		const float4 groundProjection,
 		// Texture data
 		__global TextureMetadata *texMeta,
 		__global uchar *texData,
-		// Output
-		__global float3 *accumulator
+		const float maxRadiance,
+		// output accumulator
+		__global float3 *accumulator,
+		// non-finite sample guard policy and drop counter
+		const uint nonFiniteGuard,
+		volatile __global uint *nonFiniteCounter
 		){
 
 	int globalId = get_global_id(0);
@@ -235,10 +412,11 @@ __kernel void shadePrimaryRayMisses(
 	// Just sample global env map or use scene bg color
 	MaterialNode matNode = materialNodes[sceneDiffuseMatNodeIndex];
 	uint rayPathIndex;
-	float2 uv = rayToLatLongUV(rayGetDirAndPathIndex(rays + globalId, &rayPathIndex));
+	float3 rayDir = rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
+	float2 uv = rayToRotatedLatLongUV(rayDir, envYaw);
 
-	float3 kd = matGetSample3f(uv, matNode.reflectance, matNode.reflectanceTex, texMeta, texData);
-	accumulator[paths[rayPathIndex].pixelIndex] += kd;
+	float3 kd = groundOccludes(rayDir, groundProjection) ? groundProjection.xyz : matGetSample3f(uv, matNode.reflectance, matNode.reflectanceTex, texMeta, texData);
+	accumulateRadiance(accumulator, paths[rayPathIndex].pixelIndex, clampRadiance(kd, maxRadiance), nonFiniteGuard, nonFiniteCounter);
 }
 
 // Shade indirect ray misses by sampling the scene background.
@@ -249,11 +427,23 @@ __kernel void shadeIndirectRayMisses(
 		__global uint *hitFlags,
 		__global MaterialNode *materialNodes,
 		const uint sceneDiffuseMatNodeIndex,
+		const float envYaw,
+		// ground color (xyz) and sine of the horizon elevation (w)
+		const float4 groundProjection,
 		// Texture data
 		__global TextureMetadata *texMeta,
 		__global uchar *texData,
-		// Output
-		__global float3 *accumulator
+		const float maxRadiance,
+		// light link table and the emissive index of the environment
+		// light; a zero stride or a negative index disables light linking
+		__global uint *lightLinks,
+		const uint lightLinkStride,
+		const int envEmissiveIndex,
+		// output accumulator
+		__global float3 *accumulator,
+		// non-finite sample guard policy and drop counter
+		const uint nonFiniteGuard,
+		volatile __global uint *nonFiniteCounter
 		){
 
 	int globalId = get_global_id(0);
@@ -263,15 +453,22 @@ __kernel void shadeIndirectRayMisses(
 		return;
 	}
 
+	// Skip the environment light if it is not linked to the instance that
+	// scattered the path
+	uint rayPathIndex;
+	float3 rayDir = rayGetDirAndPathIndex(rays + globalId, &rayPathIndex);
+	if( envEmissiveIndex >= 0 && !lightLinkAffects(lightLinks, lightLinkStride, envEmissiveIndex, paths[rayPathIndex].lastInstance) ){
+		return;
+	}
+
 	// Just sample global env map or use scene bg color
 	MaterialNode matNode = materialNodes[sceneDiffuseMatNodeIndex];
-	uint rayPathIndex;
-	float2 uv = rayToLatLongUV(rayGetDirAndPathIndex(rays + globalId, &rayPathIndex));
+	float2 uv = rayToRotatedLatLongUV(rayDir, envYaw);
 
 	// As this is an indirect ray we need to multiply the path throughput with the diffuse sample
 	// and accumulate that.
-	float3 kd = matGetSample3f(uv, matNode.reflectance, matNode.reflectanceTex, texMeta, texData);
-	accumulator[paths[rayPathIndex].pixelIndex] += paths[rayPathIndex].throughput * kd;
+	float3 kd = groundOccludes(rayDir, groundProjection) ? groundProjection.xyz : matGetSample3f(uv, matNode.reflectance, matNode.reflectanceTex, texMeta, texData);
+	accumulateRadiance(accumulator, paths[rayPathIndex].pixelIndex, clampRadiance(paths[rayPathIndex].throughput * kd, maxRadiance), nonFiniteGuard, nonFiniteCounter);
 }
 
 // Accumulate emissive samples for emissive surfaces that are not occluded.
@@ -281,7 +478,11 @@ __kernel void accumulateEmissiveSamples(
 		__global Path *paths,
 		__global uint *hitFlags,
 		__global float3 *emissiveSamples,
-		__global float3 *accumulator
+		// output accumulator
+		__global float3 *accumulator,
+		// non-finite sample guard policy and drop counter
+		const uint nonFiniteGuard,
+		volatile __global uint *nonFiniteCounter
 		){
 
 	int globalId = get_global_id(0);
@@ -292,7 +493,65 @@ __kernel void accumulateEmissiveSamples(
 	}
 
 	uint pathIndex = rayGetPathIndex(rays + globalId);
-	accumulator[paths[pathIndex].pixelIndex] += emissiveSamples[globalId];
+	accumulateRadiance(accumulator, paths[pathIndex].pixelIndex, emissiveSamples[globalId], nonFiniteGuard, nonFiniteCounter);
+}
+
+
+// Attenuate the emissive samples of occlusion rays that hit a semi-transparent
+// or dielectric surface by the surface transmittance and advance the rays past
+// the hit point so that the next intersection pass can detect any surfaces
+// behind it. Rays that hit an opaque surface have their sample zeroed and
+// their max distance collapsed so that they register no further hits.
+__kernel void shadeOcclusionHits(
+		__global Ray *rays,
+		__global const int *numRays,
+		__global Path *paths,
+		__global uint *hitFlags,
+		__global Intersection *intersections,
+		// scene data
+		__global float4 *vertices,
+		__global float4 *normals,
+		__global float2 *uv,
+		__global float4 *uvTransforms,
+		const uint hasUVTransforms,
+		__global uint *materialIndices,
+		__global MaterialNode *materialNodes,
+		__global float *materialOpacities,
+		const uint hasMaterialOpacities,
+		// texture data
+		__global TextureMetadata *texMeta,
+		__global uchar *texData,
+		// state
+		const uint randSeed,
+		const float rayEpsilon,
+		__global float3 *emissiveSamples
+		){
+
+	int globalId = get_global_id(0);
+
+	// If this thread is inactive or the ray reached the emissive there is nothing to do
+	if( globalId >= *numRays || !hitFlags[globalId] ){
+		return;
+	}
+
+	Surface surface;
+	surfaceInit(&surface, intersections + globalId, vertices, normals, uv, materialIndices);
+	surfaceApplyUVTransform(&surface, uvTransforms, hasUVTransforms);
+
+	// Semi-transparent surfaces let through a 1-opacity fraction of the
+	// light before it reaches the surface material. As with shadeHits, the
+	// PRNG is keyed on the path pixel index rather than the ray index.
+	uint2 rndState = (uint2)(randSeed, paths[rayGetPathIndex(rays + globalId)].pixelIndex);
+	float3 rayDir = rays[globalId].dir.xyz;
+	float opacity = hasMaterialOpacities ? materialOpacities[surface.matNodeIndex] : 1.0f;
+	float3 transmittance = (1.0f - opacity) + opacity * matShadowTransmittance(&surface, fabs(dot(surface.normal, rayDir)), materialNodes, &rndState, texMeta, texData);
+	emissiveSamples[globalId] *= transmittance;
+
+	float remainingDist = rays[globalId].origin.w - intersections[globalId].wuvt.w - rayEpsilon;
+	if( MAX_VEC3_COMPONENT(transmittance) <= 0.0f || remainingDist <= 0.0f ){
+		remainingDist = 0.0f;
+	}
+	rays[globalId].origin = (float4)(surface.point + rayDir * rayEpsilon, remainingDist);
 }
 
 #endif
@@ -55,7 +55,7 @@ float ggxGetD(float roughness, float3 n, float3 m){
 float3 ggxGetSample(float roughness, float3 inRayDir, float3 n, float2 randSample){
 	// Generate tangent, bi-tangent vectors
 	float3 u,v;
-	TANGENT_VECTORS(n, u, v);
+	orthonormalBasis(n, &u, &v);
 
 	// According to equations (35, 36) for sampling GGX:
 	// theta = atan( a * sqrt(randSample.x / 1 - randSample.x) )
@@ -105,7 +105,7 @@ float3 cosWeightedHemisphereGetSample(float3 normal, float2 randSample) {
 
 	// Generate tangent, bi-tangent vectors
 	float3 u,v;
-	TANGENT_VECTORS(normal, u, v);
+	orthonormalBasis(normal, &u, &v);
 
 	// Project disk point to unit hemisphere and rotate so that the normal points up
 	return normalize(u * rd * native_cos(phi) + v * rd * native_sin(phi) + normal * native_sqrt(1 - randSample.x));
@@ -3,19 +3,83 @@
 
 #define EMISSIVE_TYPE_AREA_LIGHT 0
 #define EMISSIVE_TYPE_ENVIRONMENT_LIGHT 1
+#define EMISSIVE_TYPE_DIRECTIONAL_LIGHT 2
 
-float3 environmentLightGetSample( Surface *surface, __global Emissive *emissive, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 *outRayDir, float *pdf, float *distToEmissive); 
-float environmentLightGetPdf( Surface *surface, __global Emissive *emissive, float3 outRayDir);
+#define EMISSION_SIDE_FRONT 0
+#define EMISSION_SIDE_BACK 1
+#define EMISSION_SIDE_BOTH 2
+
+bool emissiveEmitsTowards(int emissionSide, float cosTheta);
+float emissiveFalloff(float radius, float dist);
+
+uint aliasTableSample(__global AliasEntry *table, const uint numEntries, float *randSample);
+float envUVDensityToSolidAngle(float uvPdf, float sinTheta);
+float3 environmentLightGetSample( Surface *surface, __global Emissive *emissive, __global AliasEntry *envAliasTable, const uint envAliasW, const uint envAliasH, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 *outRayDir, float *pdf, float *distToEmissive); 
+float environmentLightGetPdf( Surface *surface, __global Emissive *emissive, __global AliasEntry *envAliasTable, const uint envAliasW, const uint envAliasH, float3 outRayDir);
+float3 directionalLightGetSample( __global Emissive *emissive, __global MaterialNode *materialNodes, float2 randSample, float3 *outRayDir, float *pdf, float *distToEmissive);
 float3 areaLightGetSample( Surface *surface, __global Emissive *emissive, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 *outRayDir, float *pdf, float *distToEmissive);
 float areaLightGetPdf( Surface *surface, __global Emissive *emissive, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float3 outRayDir);
 
-float3 emissiveGetSample( Surface *surface, __global Emissive *emissive, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 *outRayDir, float *pdf, float *distToEmissive);
-float emissiveGetPdf( Surface *surface, __global Emissive *emissive, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float3 outRayDir);
+float3 emissiveGetSample( Surface *surface, __global Emissive *emissive, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global AliasEntry *envAliasTable, const uint envAliasW, const uint envAliasH, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float2 randSample, float3 *outRayDir, float *pdf, float *distToEmissive);
+float emissiveGetPdf( Surface *surface, __global Emissive *emissive, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global AliasEntry *envAliasTable, const uint envAliasW, const uint envAliasH, __global MaterialNode *materialNodes, __global TextureMetadata *texMeta, __global uchar *texData, float3 outRayDir);
 uint emissiveSelect( const int numLights, float randSample, float *pdf);
 
+// Check whether an emissive surface emits light towards a direction given the
+// cosine of the angle between the direction and the surface geometric normal.
+bool emissiveEmitsTowards(int emissionSide, float cosTheta){
+	switch( emissionSide ){
+		case EMISSION_SIDE_BACK:
+			return cosTheta < 0.0f;
+		case EMISSION_SIDE_BOTH:
+			return cosTheta != 0.0f;
+	}
+	return cosTheta > 0.0f;
+}
+
+// Attenuate the emission towards a point at distance dist from an emissive
+// surface. This is a non-physical effect that is disabled if radius is 0.
+float emissiveFalloff(float radius, float dist){
+	if( radius <= 0.0f ){
+		return 1.0f;
+	}
+
+	float t = min(dist / radius, 1.0f);
+	float w = 1.0f - t * t;
+	return w * w;
+}
+
+// Select an entry from an alias table using a uniform random sample. The
+// sample fraction that remains after selecting the entry is rescaled to [0, 1)
+// and written back to randSample so it can be reused.
+uint aliasTableSample(__global AliasEntry *table, const uint numEntries, float *randSample){
+	float scaled = *randSample * numEntries;
+	uint index = min((uint)scaled, numEntries - 1);
+	float frac = scaled - index;
+
+	AliasEntry entry = table[index];
+	if( frac < entry.prob ){
+		*randSample = frac / entry.prob;
+		return index;
+	}
+	*randSample = (frac - entry.prob) / (1.0f - entry.prob);
+	return entry.alias;
+}
+
+// Convert a density in the uv space of a lat-long env map to a density with
+// respect to solid angle using the mapping jacobian 2 * PI^2 * sin(theta).
+float envUVDensityToSolidAngle(float uvPdf, float sinTheta){
+	return sinTheta > 0.0f ? uvPdf / (C_TWO_TIMES_PI * C_PI * sinTheta) : 0.0f;
+}
+
+// Sample the environment light. If an alias table is available (envAliasW > 0),
+// ray directions are importance sampled based on the env map luminance; 
+// otherwise, a cosine weighted hemisphere sample is used.
 float3 environmentLightGetSample(
 		Surface *surface,
 		__global Emissive *emissive,
+		__global AliasEntry *envAliasTable,
+		const uint envAliasW,
+		const uint envAliasH,
 		__global MaterialNode *materialNodes,
 		__global TextureMetadata *texMeta,
 		__global uchar *texData,
@@ -25,12 +89,40 @@ float3 environmentLightGetSample(
 		float *distToEmissive
 		){
 
-	*outRayDir = cosWeightedHemisphereGetSample(surface->normal, randSample);
-	*pdf = max(0.0f, dot(surface->normal, *outRayDir)) * C_1_PI;
+	float2 uv;
 	*distToEmissive = FLT_MAX;
 
-	// Convert ray direction vector into spherical UV and use that to sample the env map
-	float2 uv = rayToLatLongUV(*outRayDir);
+	if( envAliasW > 0 ){
+		// Select a row using the marginal table and a column using the row's
+		// conditional table and then pick a uniform point inside the texel.
+		uint y = aliasTableSample(envAliasTable, envAliasH, &randSample.y);
+		__global AliasEntry *row = envAliasTable + envAliasH + y * envAliasW;
+		uint x = aliasTableSample(row, envAliasW, &randSample.x);
+		uv = (float2)(((float)x + randSample.x) / envAliasW, ((float)y + randSample.y) / envAliasH);
+
+		float cosPhi;
+		float sinPhi = sincos(uv.x * C_TWO_TIMES_PI, &cosPhi);
+		float cosTheta;
+		float sinTheta = sincos(uv.y * C_PI, &cosTheta);
+		float3 envDir = (float3)(sinTheta * sinPhi, cosTheta, sinTheta * cosPhi);
+
+		// The env transform is a rotation so we can use its transpose to
+		// convert the direction back to world space.
+		*outRayDir = (float3)(
+			dot(emissive->transformMat0.xyz, envDir),
+			dot(emissive->transformMat1.xyz, envDir),
+			dot(emissive->transformMat2.xyz, envDir)
+		);
+		*pdf = envUVDensityToSolidAngle(row[x].pdf, sinTheta);
+	} else {
+		*outRayDir = cosWeightedHemisphereGetSample(surface->normal, randSample);
+		*pdf = max(0.0f, dot(surface->normal, *outRayDir)) * C_1_PI;
+
+		// Rotate ray direction into env map space, convert it into spherical UV
+		// and use that to sample the env map
+		uv = rayToLatLongUV(mul3x1(*outRayDir, emissive->transformMat0.xyz, emissive->transformMat1.xyz, emissive->transformMat2.xyz));
+	}
+
 	MaterialNode matNode = materialNodes[emissive->matNodeIndex];
 
 	return matNode.scale * matGetSample3f(uv, matNode.radiance, matNode.radianceTex, texMeta, texData) * C_1_PI;
@@ -39,13 +131,64 @@ float3 environmentLightGetSample(
 float environmentLightGetPdf(
 		Surface *surface,
 		__global Emissive *emissive,
+		__global AliasEntry *envAliasTable,
+		const uint envAliasW,
+		const uint envAliasH,
 		float3 outRayDir
 		){
 
+	if( envAliasW > 0 ){
+		float2 uv = rayToLatLongUV(mul3x1(outRayDir, emissive->transformMat0.xyz, emissive->transformMat1.xyz, emissive->transformMat2.xyz));
+		uint x = min((uint)(uv.x * envAliasW), envAliasW - 1);
+		uint y = min((uint)(uv.y * envAliasH), envAliasH - 1);
+		return envUVDensityToSolidAngle(envAliasTable[envAliasH + y * envAliasW + x].pdf, sin(uv.y * C_PI));
+	}
+
 	// We use the same formula as for lambert shading: cos(theta) / PI
 	return max(0.0f, dot(surface->normal, outRayDir) * C_1_PI);
 }
 
+// Sample a direction inside the cone subtended by a directional (sun) light.
+// The emissive stores an orthonormal basis around the light direction in the
+// first three columns of its transform and the solid angle of the cone in its
+// area. The light radiance describes the irradiance delivered to surfaces
+// facing the light so the returned sample is the irradiance divided by the
+// cone solid angle. Lights with a zero solid angle always return the light
+// direction with a pdf of 1.
+float3 directionalLightGetSample(
+		__global Emissive *emissive,
+		__global MaterialNode *materialNodes,
+		float2 randSample,
+		float3 *outRayDir,
+		float *pdf,
+		float *distToEmissive
+		){
+
+	MaterialNode matNode = materialNodes[emissive->matNodeIndex];
+	float3 irradiance = matNode.scale * matNode.radiance;
+	*distToEmissive = FLT_MAX;
+
+	if( emissive->area <= 0.0f ){
+		*outRayDir = emissive->transformMat2.xyz;
+		*pdf = 1.0f;
+		return irradiance;
+	}
+
+	// Uniformly sample the cone solid angle
+	float cosTheta = 1.0f - randSample.x * emissive->area * C_1_TWO_TIMES_PI;
+	float sinTheta = native_sqrt(max(0.0f, 1.0f - cosTheta * cosTheta));
+	float cosPhi;
+	float sinPhi = sincos(randSample.y * C_TWO_TIMES_PI, &cosPhi);
+
+	*outRayDir = normalize(
+			emissive->transformMat0.xyz * sinTheta * cosPhi +
+			emissive->transformMat1.xyz * sinTheta * sinPhi +
+			emissive->transformMat2.xyz * cosTheta
+			);
+	*pdf = native_recip(emissive->area);
+	return irradiance * *pdf;
+}
+
 // Generate a out ray direction towards a random point on the emissive primitive
 // and return a emission material sample from that point.
 float3 areaLightGetSample(
@@ -78,18 +221,23 @@ float3 areaLightGetSample(
 			emissive->transformMat3
 			);
 
-	float3 emissiveNormal = mul4x1(
+	float3 emissiveNormal = normalize(mul3x1(
 			(wuv.x * normals[offset] + wuv.y * normals[offset+1] + wuv.z * normals[offset+2]).xyz,
-			emissive->transformMat0,
-			emissive->transformMat1,
-			emissive->transformMat2,
-			emissive->transformMat3
-			);
+			emissive->transformMat0.xyz,
+			emissive->transformMat1.xyz,
+			emissive->transformMat2.xyz
+			));
 
 	float2 emissiveUV = wuv.x * uv[offset] + 
 		wuv.y * uv[offset+1] + 
 		wuv.z * uv[offset+2];
 
+	// The emission side is selected using the geometric normal
+	float3 v0 = mul4x1(vertices[offset].xyz, emissive->transformMat0, emissive->transformMat1, emissive->transformMat2, emissive->transformMat3);
+	float3 geomNormal = cross(
+			mul4x1(vertices[offset+1].xyz, emissive->transformMat0, emissive->transformMat1, emissive->transformMat2, emissive->transformMat3) - v0,
+			mul4x1(vertices[offset+2].xyz, emissive->transformMat0, emissive->transformMat1, emissive->transformMat2, emissive->transformMat3) - v0
+			);
 
 	MaterialNode matNode = materialNodes[emissive->matNodeIndex];
 
@@ -98,14 +246,14 @@ float3 areaLightGetSample(
 	*outRayDir = normalize(emissiveRay);
 	*distToEmissive = native_sqrt(squaredDistToLight);
 
-	float nDotOutRay = dot(emissiveNormal, -*outRayDir);
-	if( nDotOutRay > 0.0f ){
+	float nDotOutRay = fabs(dot(emissiveNormal, -*outRayDir));
+	if( nDotOutRay > 0.0f && emissiveEmitsTowards(matNode.emissionSide, dot(geomNormal, -*outRayDir)) ){
 		*pdf = 1.0f / emissive->area;
 
 		// convert from area to solid angle using formula (25) from total compedium:
 		// ω = cos(θy) / dist^2
 		float3 ke = matGetSample3f(emissiveUV, matNode.radiance, matNode.radianceTex, texMeta, texData);
-		return matNode.scale * ke * nDotOutRay / squaredDistToLight;
+		return matNode.scale * ke * nDotOutRay * emissiveFalloff(matNode.emissionFalloff, *distToEmissive) / squaredDistToLight;
 	}
 
 	*pdf = 0.0f;
@@ -134,8 +282,8 @@ float areaLightGetPdf(
 	float3 edge02 = vertices[offset+2].xyz - v0;
 
 	v0 = mul4x1(v0, emissive->transformMat0, emissive->transformMat1, emissive->transformMat2, emissive->transformMat3);
-	edge01 = mul4x1(edge01, emissive->transformMat0, emissive->transformMat1, emissive->transformMat2, emissive->transformMat3);
-	edge02 = mul4x1(edge02, emissive->transformMat0, emissive->transformMat1, emissive->transformMat2, emissive->transformMat3);
+	edge01 = mul3x1(edge01, emissive->transformMat0.xyz, emissive->transformMat1.xyz, emissive->transformMat2.xyz);
+	edge02 = mul3x1(edge02, emissive->transformMat0.xyz, emissive->transformMat1.xyz, emissive->transformMat2.xyz);
 
 	float3 pVec = cross(outRayDir, edge02);
 	float det = dot(edge01, pVec);
@@ -181,6 +329,9 @@ float3 emissiveGetSample(
 		__global float4 *vertices, 
 		__global float4 *normals,
 		__global float2 *uv,
+		__global AliasEntry *envAliasTable,
+		const uint envAliasW,
+		const uint envAliasH,
 		__global MaterialNode *materialNodes,
 		__global TextureMetadata *texMeta,
 		__global uchar *texData,
@@ -194,19 +345,25 @@ float3 emissiveGetSample(
 		case EMISSIVE_TYPE_AREA_LIGHT:
 			return areaLightGetSample(surface, emissive, vertices, normals, uv, materialNodes, texMeta, texData, randSample, outRayDir, pdf, distToEmissive);
 		case EMISSIVE_TYPE_ENVIRONMENT_LIGHT:
-			return environmentLightGetSample(surface, emissive, materialNodes, texMeta, texData, randSample, outRayDir, pdf, distToEmissive);
+			return environmentLightGetSample(surface, emissive, envAliasTable, envAliasW, envAliasH, materialNodes, texMeta, texData, randSample, outRayDir, pdf, distToEmissive);
+		case EMISSIVE_TYPE_DIRECTIONAL_LIGHT:
+			return directionalLightGetSample(emissive, materialNodes, randSample, outRayDir, pdf, distToEmissive);
 	}
 	return (float3)(0.0f, 0.0f, 0.0f);
 }
 
 // Given a pre-calculated bounce ray, calculate a PDF for hitting this 
-// emissive primitive.
+// emissive primitive. Directional lights are not visible to bounce rays so
+// their PDF is always 0.
 float emissiveGetPdf(
 		Surface *surface,
 		__global Emissive *emissive,
 		__global float4 *vertices, 
 		__global float4 *normals,
 		__global float2 *uv,
+		__global AliasEntry *envAliasTable,
+		const uint envAliasW,
+		const uint envAliasH,
 		__global MaterialNode *materialNodes,
 		__global TextureMetadata *texMeta,
 		__global uchar *texData,
@@ -217,7 +374,7 @@ float emissiveGetPdf(
 		case EMISSIVE_TYPE_AREA_LIGHT:
 			return areaLightGetPdf(surface, emissive, vertices, normals, uv, materialNodes, texMeta, texData, outRayDir);
 		case EMISSIVE_TYPE_ENVIRONMENT_LIGHT:
-			return environmentLightGetPdf(surface, emissive, outRayDir);
+			return environmentLightGetPdf(surface, emissive, envAliasTable, envAliasW, envAliasH, outRayDir);
 	}
 
 	return 0.0f;
@@ -6,16 +6,31 @@
 #define MAT_OP_BUMP_MAP   10003
 #define MAT_OP_NORMAL_MAP 10004
 #define MAT_OP_DISPERSE   10005
+#define MAT_OP_LAYERED    10006
 #define MAT_NODE_IS_OP(node) (node->type >= MAT_OP_MIX)
 #ifndef BXDF_INVALID
 	#define BXDF_INVALID 0
+	#define BXDF_TYPE_DIFFUSE          1 << 2
+	#define BXDF_TYPE_CONDUCTOR        1 << 3
+	#define BXDF_TYPE_ROUGHT_CONDUCTOR 1 << 4
+	#define BXDF_TYPE_DIELECTRIC       1 << 5
+	#define BXDF_TYPE_ROUGH_DIELECTRIC 1 << 6
 #endif
 
+// Glossy budget policies
+#define GLOSSY_BUDGET_TERMINATE 0
+#define GLOSSY_BUDGET_DIFFUSE   1
+
 void matSelectNode(__global Path *path, Surface *surface, float3 inRayDir, MaterialNode *selectedMaterial, float3 *tint, __global MaterialNode* materialNodes, uint2 *rndState, __global TextureMetadata *texMeta, __global uchar *texData );
 float3 matGetSample3f(float2 uv, float3 defaultValue, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData);
 float matGetSample1f(float2 uv, float defaultValue, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData);
 float3 matGetBumpSample3f(float3 normal, float2 uv, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData);
-float3 matGetNormalSample3f(float3 normal, float2 uv, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData);
+float3 matGetNormalSample3f(float3 normal, float2 uv, int texIndex, int flipY, __global TextureMetadata *texMeta, __global uchar* texData);
+float3 matScaleNormal(float3 normal, float3 mappedNormal, float scale);
+float3 matLayerTransmission(__global MaterialNode *node, float cosI);
+void matRegularizeRoughness(MaterialNode *node, float2 uv, float minRoughness, __global TextureMetadata *texMeta, __global uchar* texData);
+bool matApplyGlossyBudget(MaterialNode *node, __global Path *path, uint maxGlossyBounces, uint policy);
+float3 matShadowTransmittance(Surface *surface, float cosI, __global MaterialNode* materialNodes, uint2 *rndState, __global TextureMetadata *texMeta, __global uchar *texData);
 
 // Traverse the layered material tree for this surface and select a leaf node
 void matSelectNode(__global Path *path, Surface *surface, float3 inRayDir, MaterialNode *selectedMaterial, float3 *tint, __global MaterialNode* materialNodes, uint2 *rndState, __global TextureMetadata *texMeta, __global uchar *texData ){
@@ -23,6 +38,12 @@ void matSelectNode(__global Path *path, Surface *surface, float3 inRayDir, Mater
 	float2 sample;
 	float2 forceIOR = (float2)(0.0f, 0.0f);
 	uint flags;
+
+	// The surface normal before applying any normal maps
+	float3 unmappedNormal = surface->normal;
+	bool normalMapped = false;
+	bool coatSelected = false;
+	float cosI;
 	while(MAT_NODE_IS_OP(node)) {
 		switch(node->type){
 			case MAT_OP_MIX: 
@@ -41,7 +62,11 @@ void matSelectNode(__global Path *path, Surface *surface, float3 inRayDir, Mater
 				node = materialNodes + node->leftChild;
 				break;
 			case MAT_OP_NORMAL_MAP:
-				surface->normal = matGetNormalSample3f(surface->normal, surface->uv, node->bumpTex, texMeta, texData);
+				if (!normalMapped) {
+					unmappedNormal = surface->normal;
+					normalMapped = true;
+				}
+				surface->normal = matGetNormalSample3f(surface->normal, surface->uv, node->bumpTex, node->normalMapFlipY, texMeta, texData);
 				node = materialNodes + node->leftChild;
 				break;
 			case MAT_OP_DISPERSE:
@@ -77,16 +102,67 @@ void matSelectNode(__global Path *path, Surface *surface, float3 inRayDir, Mater
 				}
 				node = materialNodes + node->leftChild;
 				break;
+			case MAT_OP_LAYERED:
+				// Statistical layering: reflect off the coat with a probability
+				// equal to its fresnel reflectance; otherwise refract into the
+				// coat and tint the base sample by the coat transmission.
+				cosI = fabs(dot(inRayDir, surface->normal));
+				sample = randomGetSample2f(rndState);
+				if( sample.x < fresnelForDielectricExact(node->extIOR, node->intIOR, cosI) ){
+					coatSelected = true;
+
+					// The coat may use its own normal map which replaces
+					// any normal maps applied to the base layer.
+					if (node->coatNormalTex != -1) {
+						if (!normalMapped) {
+							unmappedNormal = surface->normal;
+							normalMapped = true;
+						}
+						surface->normal = matGetNormalSample3f(unmappedNormal, surface->uv, node->coatNormalTex, node->normalMapFlipY, texMeta, texData);
+					}
+					node = materialNodes + node->leftChild;
+				} else {
+					*tint *= matLayerTransmission(node, cosI);
+					node = materialNodes + node->rightChild;
+				}
+				break;
 		}
 	}
 
 	*selectedMaterial = *node;
 
+	// The coat of a layered material only reflects light. As the fresnel term
+	// is already accounted for by the coat selection probability, the coat
+	// is shaded as a perfect (or rough) mirror.
+	if (coatSelected) {
+		selectedMaterial->type = selectedMaterial->type == BXDF_TYPE_ROUGH_DIELECTRIC ? BXDF_TYPE_ROUGHT_CONDUCTOR : BXDF_TYPE_CONDUCTOR;
+		selectedMaterial->intIOR = 0.0f;
+		selectedMaterial->conductorEta = (float3)(0.0f, 0.0f, 0.0f);
+		selectedMaterial->conductorK = (float3)(0.0f, 0.0f, 0.0f);
+	}
+
+	// Apply the normal map scale of the selected bxdf
+	if (normalMapped) {
+		surface->normal = matScaleNormal(unmappedNormal, surface->normal, node->conductorKAndNormalScale.w);
+	}
+
 	// Apply dispersion IORs
 	selectedMaterial->intIOR = max(selectedMaterial->intIOR, forceIOR.x);
 	selectedMaterial->extIOR = max(selectedMaterial->extIOR, forceIOR.y);
 }
 
+// Calculate the tint applied to the base layer of a layered material using the
+// statistical model documented in asset/material/layered.go.
+float3 matLayerTransmission(__global MaterialNode *node, float cosI){
+	float eta = node->extIOR / node->intIOR;
+	float cosT = sqrt(max(1e-4f, 1.0f - eta * eta * (1.0f - cosI * cosI)));
+	float fdr = node->layerReflectances.x;
+	float albedo = node->layerReflectances.y;
+
+	float3 a = pow(node->layerTransmittanceAndThickness.xyz, 2.0f * node->layerTransmittanceAndThickness.w / cosT);
+	return (1.0f - fdr) * a / (1.0f - fdr * albedo * a);
+}
+
 // Sample texture using the supplied uv coordinates and return a float3 vector. 
 // If texIndex is -1 then fall-back to the supplied default value.
 float3 matGetSample3f(float2 uv, float3 defaultValue, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData){
@@ -107,8 +183,9 @@ float matGetSample1f(float2 uv, float defaultValue, int texIndex, __global Textu
 	return texGetSample1f( uv, texIndex, texMeta, texData );
 }
 
-// Apply normal map to intersection normal.
-float3 matGetNormalSample3f(float3 normal, float2 uv, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData){
+// Apply normal map to intersection normal. If flipY is 1, the green channel of
+// the normal map is assumed to point down (DirectX convention).
+float3 matGetNormalSample3f(float3 normal, float2 uv, int texIndex, int flipY, __global TextureMetadata *texMeta, __global uchar* texData){
 	// Generate tangent, bi-tangent vectors
 	float3 u,v;
 	TANGENT_VECTORS(normal, u, v);
@@ -117,9 +194,20 @@ float3 matGetNormalSample3f(float3 normal, float2 uv, int texIndex, __global Tex
 	// R, G components encode the range [-1, 1] into a value [0, 255]
 	// B component encodes the range [0, 1] into [128, 255]
 	float3 sample = (texGetSample3f( uv, texIndex, texMeta, texData ) * 2.0f) - 1.0f;
+	if( flipY == 1 ){
+		sample.y = -sample.y;
+	}
 	return normalize(u * sample.x + v * sample.y + 0.5f * normal * sample.z);
 }
 
+// Blend between the unmapped and the mapped normal using the normal map scale.
+// A scale of 0 disables the normal map while values > 1 exaggerate it.
+float3 matScaleNormal(float3 normal, float3 mappedNormal, float scale){
+	float3 scaled = normal + (mappedNormal - normal) * scale;
+	float len = length(scaled);
+	return len > 1e-6f ? scaled / len : normal;
+}
+
 // Apply bump map to intersection normal.
 float3 matGetBumpSample3f(float3 normal, float2 uv, int texIndex, __global TextureMetadata *texMeta, __global uchar* texData){
 	// Generate tangent, bi-tangent vectors
@@ -129,4 +217,86 @@ float3 matGetBumpSample3f(float3 normal, float2 uv, int texIndex, __global Textu
 	float3 sample = (texGetBumpSample3f( uv, texIndex, texMeta, texData ) * 2.0f) - 1.0f;
 	return normalize(u * sample.x + v * sample.y + normal * sample.z);
 }
+
+// Increase the roughness of glossy bxdfs to at least minRoughness. Smooth
+// conductors and dielectrics are converted to their rough counterparts and
+// roughness textures are resolved so the clamped value can be stored in the
+// node.
+void matRegularizeRoughness(MaterialNode *node, float2 uv, float minRoughness, __global TextureMetadata *texMeta, __global uchar* texData){
+	switch(node->type){
+		case BXDF_TYPE_CONDUCTOR:
+			node->type = BXDF_TYPE_ROUGHT_CONDUCTOR;
+			node->roughness = minRoughness;
+			break;
+		case BXDF_TYPE_DIELECTRIC:
+			node->type = BXDF_TYPE_ROUGH_DIELECTRIC;
+			node->roughness = minRoughness;
+			break;
+		case BXDF_TYPE_ROUGHT_CONDUCTOR:
+		case BXDF_TYPE_ROUGH_DIELECTRIC:
+			node->roughness = max(matGetSample1f(uv, node->roughness, node->roughnessTex, texMeta, texData), minRoughness);
+			break;
+		default:
+			return;
+	}
+	node->roughnessTex = -1;
+}
+
+// Apply the glossy bounce budget to the selected material node. Once the path
+// exhausts its budget, glossy nodes are either converted to diffuse nodes with
+// the same color or the function returns false to indicate that the path
+// should be terminated.
+bool matApplyGlossyBudget(MaterialNode *node, __global Path *path, uint maxGlossyBounces, uint policy){
+	if( maxGlossyBounces == 0 || (node->type != BXDF_TYPE_CONDUCTOR && node->type != BXDF_TYPE_ROUGHT_CONDUCTOR) ){
+		return true;
+	}
+
+	if( path->glossyBounces < maxGlossyBounces ){
+		path->glossyBounces++;
+		return true;
+	}
+
+	if( policy == GLOSSY_BUDGET_DIFFUSE ){
+		// The conductor specularity aliases the diffuse reflectance
+		node->type = BXDF_TYPE_DIFFUSE;
+		node->useVertexColors = 0;
+		return true;
+	}
+	return false;
+}
+
+// Calculate the fraction of light that passes straight through the material
+// tree for this surface. Refraction is ignored so that dielectrics cast tinted
+// shadows; coats of layered materials attenuate light by their transmission
+// and all other bxdfs are opaque.
+float3 matShadowTransmittance(Surface *surface, float cosI, __global MaterialNode* materialNodes, uint2 *rndState, __global TextureMetadata *texMeta, __global uchar *texData){
+	__global MaterialNode* node = materialNodes + surface->matNodeIndex;
+	float3 tint = (float3)(1.0f, 1.0f, 1.0f);
+	float2 sample;
+	while(MAT_NODE_IS_OP(node)) {
+		switch(node->type){
+			case MAT_OP_MIX:
+				sample = randomGetSample2f(rndState);
+				node = materialNodes + (sample.x < node->mixWeight ? node->leftChild : node->rightChild);
+				break;
+			case MAT_OP_MIX_MAP:
+				sample = randomGetSample2f(rndState);
+				sample.y = texGetSample1f(surface->uv, node->mixWeightsTex, texMeta, texData);
+				node = materialNodes + (sample.x < sample.y ? node->leftChild : node->rightChild);
+				break;
+			case MAT_OP_LAYERED:
+				tint *= matLayerTransmission(node, cosI);
+				node = materialNodes + node->rightChild;
+				break;
+			default:
+				node = materialNodes + node->leftChild;
+				break;
+		}
+	}
+
+	if( node->type != BXDF_TYPE_DIELECTRIC && node->type != BXDF_TYPE_ROUGH_DIELECTRIC ){
+		return (float3)(0.0f, 0.0f, 0.0f);
+	}
+	return tint * matGetSample3f(surface->uv, node->transmittance, node->transmittanceTex, texMeta, texData);
+}
 #endif
@@ -2,6 +2,8 @@
 #define SAMPLERS_CL
 
 #include "random_sampler.cl"
+#include "sobol_sampler.cl"
+#include "subpixel_sampler.cl"
 #include "texture_sampler.cl"
 #include "material_sampler.cl"
 #include "distribution_sampler.cl"
@@ -0,0 +1,92 @@
+#ifndef SOBOL_SAMPLER_CL
+#define SOBOL_SAMPLER_CL
+
+// These values must match the ones defined in tracer/sobol.go
+#define SAMPLER_RANDOM 0
+#define SAMPLER_SOBOL 1
+#define SOBOL_MAX_DIMENSIONS 16
+#define SOBOL_BITS 32
+
+// Sample dimensions allocated to the camera and each path bounce.
+#define SOBOL_CAMERA_DIM 0
+#define SOBOL_BOUNCE_DIM_OFFSET 2
+#define SOBOL_DIMS_PER_BOUNCE 6
+
+uint _sobolReverseBits(uint x);
+uint _sobolHash(uint x);
+uint _sobolOwenScramble(uint x, uint seed);
+float sobolGetSample1f(__global const uint *sobolDirections, uint sampleIndex, uint dim, uint pixelIndex);
+float2 qmcGetSample2f(uint samplerType, __global const uint *sobolDirections, uint sampleIndex, uint dim, uint maxDims, uint pixelIndex, uint2 *rndState);
+
+// Reverse the bits of a uint value.
+uint _sobolReverseBits(uint x){
+	x = (x << 16) | (x >> 16);
+	x = ((x & 0x00ff00ff) << 8) | ((x & 0xff00ff00) >> 8);
+	x = ((x & 0x0f0f0f0f) << 4) | ((x & 0xf0f0f0f0) >> 4);
+	x = ((x & 0x33333333) << 2) | ((x & 0xcccccccc) >> 2);
+	x = ((x & 0x55555555) << 1) | ((x & 0xaaaaaaaa) >> 1);
+	return x;
+}
+
+// Hash a uint value (lowbias32)
+uint _sobolHash(uint x){
+	x ^= x >> 16;
+	x *= 0x7feb352d;
+	x ^= x >> 15;
+	x *= 0x846ca68b;
+	x ^= x >> 16;
+	return x;
+}
+
+// Apply hash-based Owen scrambling to a sobol sample.
+uint _sobolOwenScramble(uint x, uint seed){
+	x = _sobolReverseBits(x);
+	x += seed;
+	x ^= x * 0x6c50b47c;
+	x ^= x * 0xb82f1e52;
+	x ^= x * 0xc7afe638;
+	x ^= x * 0x8d22f6e6;
+	return _sobolReverseBits(x);
+}
+
+// Generate an Owen-scrambled sobol sample in the [0, 1) range for the given
+// sample index and dimension using a per-pixel scramble seed.
+float sobolGetSample1f(__global const uint *sobolDirections, uint sampleIndex, uint dim, uint pixelIndex){
+	__global const uint *v = sobolDirections + dim * SOBOL_BITS;
+
+	uint x = 0;
+	for(uint bit = 0; sampleIndex != 0; sampleIndex >>= 1, bit++){
+		if(sampleIndex & 1){
+			x ^= v[bit];
+		}
+	}
+
+	x = _sobolOwenScramble(x, _sobolHash(pixelIndex * SOBOL_MAX_DIMENSIONS + dim));
+	return (float)(x >> 8) * (1.0f / 16777216.0f);
+}
+
+// Generate a 2D sample using dimensions dim and dim+1. Dimensions past the
+// maxDims budget wrap around the bounce dimensions and use a rehashed scramble
+// seed and a shuffled sample index so that wrapped bounces are not correlated
+// with earlier ones. If the
+// random sampler is selected, this function falls back to the random sampler.
+float2 qmcGetSample2f(uint samplerType, __global const uint *sobolDirections, uint sampleIndex, uint dim, uint maxDims, uint pixelIndex, uint2 *rndState){
+	if(samplerType != SAMPLER_SOBOL){
+		return randomGetSample2f(rndState);
+	}
+
+	if(dim >= maxDims){
+		uint period = maxDims - SOBOL_BOUNCE_DIM_OFFSET;
+		uint wraps = (dim - SOBOL_BOUNCE_DIM_OFFSET) / period;
+		dim = SOBOL_BOUNCE_DIM_OFFSET + (dim - SOBOL_BOUNCE_DIM_OFFSET) % period;
+		pixelIndex ^= _sobolHash(wraps);
+		sampleIndex = _sobolOwenScramble(sampleIndex, _sobolHash(pixelIndex));
+	}
+
+	return (float2)(
+		sobolGetSample1f(sobolDirections, sampleIndex, dim, pixelIndex),
+		sobolGetSample1f(sobolDirections, sampleIndex, dim + 1, pixelIndex)
+	);
+}
+
+#endif
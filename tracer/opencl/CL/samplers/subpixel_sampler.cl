@@ -0,0 +1,65 @@
+#ifndef SUBPIXEL_SAMPLER_CL
+#define SUBPIXEL_SAMPLER_CL
+
+// These values must match the ones defined in tracer/subpixel.go
+#define SUBPIXEL_PATTERN_SAMPLER 0
+#define SUBPIXEL_PATTERN_GRID 1
+#define SUBPIXEL_PATTERN_HALTON 2
+#define SUBPIXEL_PATTERN_POISSON 3
+#define SUBPIXEL_PATTERN_SIZE 64
+#define SUBPIXEL_GRID_DIM 8
+
+float _subpixelRadicalInverse3(uint index);
+float2 subpixelGetSample(uint pattern, uint sampleIndex, uint pixelIndex, __global const float2 *poissonPattern);
+
+// Calculate the base 3 radical inverse of index.
+float _subpixelRadicalInverse3(uint index){
+	float inv = 0.0f;
+	float scale = 1.0f / 3.0f;
+	for(; index != 0; index /= 3){
+		inv += (index % 3) * scale;
+		scale /= 3.0f;
+	}
+	return inv;
+}
+
+// Generate the sub-pixel sample in the [0, 1) range for the given sample and
+// frame pixel index using one of the grid, Halton or Poisson-disk patterns.
+// The pattern is shifted by a per-pixel offset so that neighboring pixels do
+// not share the same sample positions.
+float2 subpixelGetSample(uint pattern, uint sampleIndex, uint pixelIndex, __global const float2 *poissonPattern){
+	float2 p;
+	uint cell, x, y;
+	switch(pattern){
+		case SUBPIXEL_PATTERN_GRID:
+			// Interleave the cell index bits so that every 4^k consecutive
+			// samples form a regular 2^k x 2^k grid
+			cell = sampleIndex % SUBPIXEL_PATTERN_SIZE;
+			x = 0;
+			y = 0;
+			for(uint bit = 0; bit < 3; bit++){
+				x |= ((cell >> (2 * bit)) & 1) << (2 - bit);
+				y |= ((cell >> (2 * bit + 1)) & 1) << (2 - bit);
+			}
+			p = ((float2)(x, y) + 0.5f) / SUBPIXEL_GRID_DIM;
+			break;
+		case SUBPIXEL_PATTERN_HALTON:
+			p = (float2)(
+				(float)(_sobolReverseBits(sampleIndex) >> 8) * (1.0f / 16777216.0f),
+				_subpixelRadicalInverse3(sampleIndex)
+			);
+			break;
+		default:
+			p = poissonPattern[sampleIndex % SUBPIXEL_PATTERN_SIZE];
+			break;
+	}
+
+	float2 shift = (float2)(
+		(float)(_sobolHash(2 * pixelIndex) >> 8),
+		(float)(_sobolHash(2 * pixelIndex + 1) >> 8)
+	) * (1.0f / 16777216.0f);
+	p += shift;
+	return p - (float2)(p.x >= 1.0f ? 1.0f : 0.0f, p.y >= 1.0f ? 1.0f : 0.0f);
+}
+
+#endif
@@ -5,13 +5,77 @@
 #define TEX_FMT_LUMINANCE32F 1
 #define TEX_FMT_RGBA8 2
 #define TEX_FMT_RGBA32F 3
+#define TEX_FMT_LUMINANCE16F 4
+#define TEX_FMT_RGBA16F 5
+#define TEX_FMT_PROCEDURAL_CHECKER 100
+#define TEX_FMT_PROCEDURAL_NOISE 101
 
 float3 texGetSample3f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data);
 float texGetSample1f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data);
 float3 texGetBumpSample3f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data);
+float _texGetLatticeValue(int x, int y);
+float _texGetValueNoise(float2 p);
+float3 texGetProceduralSample3f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data);
+
+// Get a random value in the [0, 1) range for a lattice point.
+float _texGetLatticeValue(int x, int y){
+	uint h = ((uint)x * 0x8da6b343) ^ ((uint)y * 0xd8163841);
+	h ^= h >> 16;
+	h *= 0x7feb352d;
+	h ^= h >> 15;
+	h *= 0x846ca68b;
+	h ^= h >> 16;
+	return (float)(h >> 8) * (1.0f / 16777216.0f);
+}
+
+// Evaluate value noise by smoothly interpolating the random values
+// assigned to the integer lattice points.
+float _texGetValueNoise(float2 p){
+	float2 f = floor(p);
+	int ix = (int)f.x;
+	int iy = (int)f.y;
+
+	float2 t = p - f;
+	t = t * t * (3.0f - 2.0f * t);
+
+	return mix(
+		mix(_texGetLatticeValue(ix, iy), _texGetLatticeValue(ix + 1, iy), t.x),
+		mix(_texGetLatticeValue(ix, iy + 1), _texGetLatticeValue(ix + 1, iy + 1), t.x),
+		t.y
+	);
+}
+
+// Evaluate a procedural texture at the given uv coordinates. Procedural
+// texture parameters are stored in the texture data using the layout:
+// [0-2] color1, [3] scale, [4-6] color2.
+float3 texGetProceduralSample3f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data) {
+	__global const float* params = (__global const float*)(data + metadata[texIndex].dataOffset);
+	float3 color1 = vload3(0, params);
+	float3 color2 = vload3(0, params + 4);
+	float2 p = uv * params[3];
+
+	float t = 0.0f;
+	switch(metadata[texIndex].format){
+		case TEX_FMT_PROCEDURAL_CHECKER:
+		{
+			float2 cell = floor(p);
+			t = (float)(((int)cell.x + (int)cell.y) & 1);
+			break;
+		}
+		case TEX_FMT_PROCEDURAL_NOISE:
+			t = _texGetValueNoise(p);
+			break;
+	}
+
+	return mix(color1, color2, t);
+}
 
 // Sample texture at given uv coordinates returning back a float3 vector
 float3 texGetSample3f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data) {
+	if( metadata[texIndex].format >= TEX_FMT_PROCEDURAL_CHECKER ){
+		return texGetProceduralSample3f(uv, texIndex, metadata, data);
+	}
+
 	uint2 texDims = (uint2)(
 			metadata[texIndex].width,
 			metadata[texIndex].height
@@ -93,6 +157,36 @@ float3 texGetSample3f(float2 uv, int texIndex, __global TextureMetadata *metadat
 					coeffX
 			);
 			
+			return (float3)(r,r,r);
+		}
+		case TEX_FMT_RGBA16F:
+		{
+			const __global half* halfPtr = (__global const half*)basePtr;
+
+			float4 rgbTL = vload_half4((ty * texDims.x) + tx, halfPtr);
+			float4 rgbTR = vload_half4((ty * texDims.x) + bx, halfPtr);
+			float4 rgbBL = vload_half4((by * texDims.x) + tx, halfPtr);
+			float4 rgbBR = vload_half4((by * texDims.x) + bx, halfPtr);
+
+			return mix(
+					mix(rgbTL, rgbBL, coeffY),
+					mix(rgbTR, rgbBR, coeffY),
+					coeffX
+			).xyz;
+		}
+		case TEX_FMT_LUMINANCE16F:
+		{
+			const __global half* halfPtr = (__global const half*)basePtr;
+			float rTL = vload_half((ty * texDims.x) + tx, halfPtr);
+			float rTR = vload_half((ty * texDims.x) + bx, halfPtr);
+			float rBL = vload_half((by * texDims.x) + tx, halfPtr);
+			float rBR = vload_half((by * texDims.x) + bx, halfPtr);
+			float r = mix(
+					mix(rTL, rBL, coeffY),
+					mix(rTR, rBR, coeffY),
+					coeffX
+			);
+
 			return (float3)(r,r,r);
 		}
 	}
@@ -103,6 +197,10 @@ float3 texGetSample3f(float2 uv, int texIndex, __global TextureMetadata *metadat
 // Sample texture at given uv coordinates returning back a float. For multi-channel
 // textures we only read from the red channel.
 float texGetSample1f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data) {
+	if( metadata[texIndex].format >= TEX_FMT_PROCEDURAL_CHECKER ){
+		return texGetProceduralSample3f(uv, texIndex, metadata, data).x;
+	}
+
 	uint2 texDims = (uint2)(
 			metadata[texIndex].width,
 			metadata[texIndex].height
@@ -178,6 +276,33 @@ float texGetSample1f(float2 uv, int texIndex, __global TextureMetadata *metadata
 					coeffX
 			);
 		}
+		case TEX_FMT_RGBA16F:
+		{
+			const __global half* halfPtr = (__global const half*)basePtr;
+
+			float rTL = vload_half((ty * texDims.x << 2) + (tx << 2), halfPtr);
+			float rTR = vload_half((ty * texDims.x << 2) + (bx << 2), halfPtr);
+			float rBL = vload_half((by * texDims.x << 2) + (tx << 2), halfPtr);
+			float rBR = vload_half((by * texDims.x << 2) + (bx << 2), halfPtr);
+			return mix(
+					mix(rTL, rBL, coeffY),
+					mix(rTR, rBR, coeffY),
+					coeffX
+			);
+		}
+		case TEX_FMT_LUMINANCE16F:
+		{
+			const __global half* halfPtr = (__global const half*)basePtr;
+			float rTL = vload_half((ty * texDims.x) + tx, halfPtr);
+			float rTR = vload_half((ty * texDims.x) + bx, halfPtr);
+			float rBL = vload_half((by * texDims.x) + tx, halfPtr);
+			float rBR = vload_half((by * texDims.x) + bx, halfPtr);
+			return mix(
+					mix(rTL, rBL, coeffY),
+					mix(rTR, rBR, coeffY),
+					coeffX
+			);
+		}
 	}
 
 	return 0.0f;
@@ -185,6 +310,18 @@ float texGetSample1f(float2 uv, int texIndex, __global TextureMetadata *metadata
 
 // Sample bump map texture at given uv coordinates returning back a float3 vector
 float3 texGetBumpSample3f(float2 uv, int texIndex, __global TextureMetadata *metadata, __global uchar* data) {
+	float3 halfVec = (float3)(0.5f, 0.5f, 0.5f);
+
+	// For procedural textures use finite differences to recreate the normal
+	if( metadata[texIndex].format >= TEX_FMT_PROCEDURAL_CHECKER ){
+		float delta = 1.0f / 1024.0f;
+		float s0 = texGetProceduralSample3f(uv, texIndex, metadata, data).x;
+		float s1 = texGetProceduralSample3f(uv + (float2)(delta, 0.0f), texIndex, metadata, data).x;
+		float s2 = texGetProceduralSample3f(uv + (float2)(0.0f, delta), texIndex, metadata, data).x;
+
+		return halfVec + 0.5f * normalize((float3)(s1 - s0, s2 - s0, 1.0f));
+	}
+
 	uint2 texDims = (uint2)(
 			metadata[texIndex].width,
 			metadata[texIndex].height
@@ -205,8 +342,6 @@ float3 texGetBumpSample3f(float2 uv, int texIndex, __global TextureMetadata *met
 
 	__global uchar* basePtr = data + metadata[texIndex].dataOffset;
 
-	float3 halfVec = (float3)(0.5f, 0.5f, 0.5f);
-
 	switch(metadata[texIndex].format){
 		case TEX_FMT_RGBA8:
 		{
@@ -244,6 +379,26 @@ float3 texGetBumpSample3f(float2 uv, int texIndex, __global TextureMetadata *met
 			float s1 = floatPtr[(ty * texDims.x) + bx];
 			float s2 = floatPtr[(by * texDims.x) + tx];
 
+			return halfVec + 0.5f * normalize((float3)(s1 - s0, s2 - s0, 1.0f));
+		}
+		case TEX_FMT_RGBA16F:
+		{
+			const __global half* halfPtr = (__global const half*)basePtr;
+
+			float s0 = vload_half(((ty * texDims.x) + tx) << 2, halfPtr);
+			float s1 = vload_half(((ty * texDims.x) + bx) << 2, halfPtr);
+			float s2 = vload_half(((by * texDims.x) + tx) << 2, halfPtr);
+
+			return halfVec + 0.5f * normalize((float3)(s1 - s0, s2 - s0, 1.0f));
+		}
+		case TEX_FMT_LUMINANCE16F:
+		{
+			const __global half* halfPtr = (__global const half*)basePtr;
+
+			float s0 = vload_half((ty * texDims.x) + tx, halfPtr);
+			float s1 = vload_half((ty * texDims.x) + bx, halfPtr);
+			float s2 = vload_half((by * texDims.x) + tx, halfPtr);
+
 			return halfVec + 0.5f * normalize((float3)(s1 - s0, s2 - s0, 1.0f));
 		}
 	}
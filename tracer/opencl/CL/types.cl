@@ -19,9 +19,12 @@ typedef struct {
 	// Path flags
 	uint flags;
 
-	// Padding; reseved for future use
-	uint _reserved1;
-	uint _reserved2;
+	// Number of glossy bounces along this path
+	uint glossyBounces;
+
+	// The mesh instance that scattered the path's current ray; used for
+	// light linking emissive surfaces reached via bxdf sampling
+	uint lastInstance;
 } Path;
 
 typedef struct {
@@ -76,9 +79,12 @@ typedef struct {
 
 	// Index to triangle that was intersected
 	uint triIndex;
-	
+
+	// Set to 1 if the ray hit the side of the triangle that its geometric
+	// normal points to
+	uint frontFace;
+
 	// padding
-	uint _reserved1;
 	uint _reserved2;
 } Intersection;
 
@@ -89,11 +95,20 @@ typedef struct {
 	// normal at intersection point
 	float3 normal;
 
+	// geometric (non-interpolated) normal at intersection point
+	float3 geomNormal;
+
+	// set to 1 if the intersection ray hit the front face of the surface
+	uint frontFace;
+
 	// texture uv coords at intersection point
 	float2 uv;
 
 	// material node index
 	uint matNodeIndex;
+
+	// distance from the ray origin to the intersection point
+	float hitDist;
 } Surface;
 
 typedef struct {
@@ -105,6 +120,9 @@ typedef struct {
 
 	// start offset in texture data
 	uint dataOffset;
+
+	// number of stored mip levels; only the base level is sampled
+	uint mipLevels;
 } TextureMetadata;
 
 typedef struct {
@@ -127,6 +145,9 @@ typedef struct {
 		int reflectanceTex;
 		int specularityTex;
 		int radianceTex;
+
+		// Coat normal map for layered nodes
+		int coatNormalTex;
 	};
 
 	union {
@@ -137,11 +158,23 @@ typedef struct {
 
 		// mix node
 		float mixWeight;
+
+		// Coat transmittance (xyz) and thickness (w) for layered nodes
+		float4 layerTransmittanceAndThickness;
 	};
 	
 	union {
 		float3 transmittance;
 		float3 extDispersionIORs;
+
+		// Real part of the complex IOR for conductors
+		float3 conductorEta;
+
+		// Coat internal reflectance (x) and base albedo (y) for layered nodes
+		float2 layerReflectances;
+
+		// Falloff radius for emissive nodes
+		float emissionFalloff;
 	};
 
 	union {
@@ -157,10 +190,33 @@ typedef struct {
 		float scale;
 
 		float roughness;
+
+		// Mean free path for subsurface nodes
+		float meanFreePath;
 	};
 
 	union {
 		int roughnessTex;
+
+		// The faces that emit light for emissive nodes
+		int emissionSide;
+
+		// Set to 1 for diffuse nodes that use the interpolated vertex
+		// color as their reflectance
+		int useVertexColors;
+
+		// Set to 1 for normal map nodes whose green channel points down
+		// (DirectX convention). Also used by the coat normal map of
+		// layered nodes
+		int normalMapFlipY;
+	};
+
+	union {
+		// Imaginary part of the complex IOR for conductors
+		float3 conductorK;
+
+		// The w component stores the normal map scale for bxdf nodes
+		float4 conductorKAndNormalScale;
 	};
 } MaterialNode;
 
@@ -186,4 +242,26 @@ typedef struct {
 	uint type;
 } Emissive;
 
+typedef struct {
+	// The probability of selecting this entry instead of its alias
+	float prob;
+
+	// The alias entry index
+	uint alias;
+
+	// The normalized probability (or density) for this entry
+	float pdf;
+
+	// Padding; reserved for future use
+	uint _reserved;
+} AliasEntry;
+
+typedef struct {
+	// photon position. This uses the same space as a float4
+	float3 position;
+
+	// photon power. This uses the same space as a float4
+	float3 power;
+} Photon;
+
 #endif
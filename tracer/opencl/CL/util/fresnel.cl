@@ -2,7 +2,9 @@
 #define FRESNEL_CL
 
 float fresnelForDielectric(float etaI, float etaT, float iDotN);
+float fresnelForDielectricExact(float etaI, float etaT, float iDotN);
 float fresnelForConductor(float eta, float etaK, float iDotN);
+float3 fresnelForConductorRGB(float3 eta, float3 etaK, float iDotN);
 
 // Calculate fresnel given the eta and cosTheta using Schlick's approximation.
 inline float fresnelForDielectric(float etaI, float etaT, float iDotN){
@@ -15,6 +17,22 @@ inline float fresnelForDielectric(float etaI, float etaT, float iDotN){
 	return r0 + (1.0f - r0) * c1 * c1 *c;
 }
 
+// Calculate the exact fresnel reflectance for a dielectric interface. If the
+// ray undergoes total internal reflection this function returns 1.
+inline float fresnelForDielectricExact(float etaI, float etaT, float iDotN){
+	float cosI = fabs(iDotN);
+	float eta = etaI / etaT;
+	float sinTSq = eta * eta * (1.0f - cosI * cosI);
+	if( sinTSq >= 1.0f ){
+		return 1.0f;
+	}
+
+	float cosT = sqrt(1.0f - sinTSq);
+	float rs = (etaI * cosI - etaT * cosT) / (etaI * cosI + etaT * cosT);
+	float rp = (etaT * cosI - etaI * cosT) / (etaT * cosI + etaI * cosT);
+	return 0.5f * (rs * rs + rp * rp);
+}
+
 // Calculate fresnel for a conductor using etaK as the imaginary part of the eta
 inline float fresnelForConductor(float eta, float etaK, float iDotN){
     float  iDotNSq = iDotN * iDotN;
@@ -27,4 +45,18 @@ inline float fresnelForConductor(float eta, float etaK, float iDotN){
 
     return 0.5f * (Rp + Rs);
 }
+
+// Calculate per-channel fresnel for a conductor with a complex IOR (eta + i*etaK)
+inline float3 fresnelForConductorRGB(float3 eta, float3 etaK, float iDotN){
+	float cosI = fabs(iDotN);
+	float cosISq = cosI * cosI;
+	float3 twoEtaCosI = 2.0f * eta * cosI;
+
+	float3 t0 = eta * eta + etaK * etaK;
+	float3 t1 = t0 * cosISq;
+	float3 Rs = (t0 - twoEtaCosI + cosISq) / (t0 + twoEtaCosI + cosISq);
+	float3 Rp = (t1 - twoEtaCosI + 1.0f) / (t1 + twoEtaCosI + 1.0f);
+
+	return 0.5f * (Rp + Rs);
+}
 #endif
@@ -5,15 +5,27 @@
 #define PATH_FLAG_DISPERSE_G 1 << 1
 #define PATH_FLAG_DISPERSE_B 1 << 2
 
+// Caustic tracking flags
+#define PATH_FLAG_CAUSTIC              (1 << 3)
+#define PATH_FLAG_NON_SINGULAR_BOUNCE  (1 << 4)
+#define PATH_FLAG_PHOTON_GATHER        (1 << 5)
+
+// Set once the path scatters off a diffuse surface
+#define PATH_FLAG_DIFFUSE_BOUNCE       (1 << 6)
+
 void pathNew(__global Path *path, uint pixelIndex);
 void pathMulThroughput(__global Path *path, float3 fragColor);
 void pathSetThroughput(__global Path *path, float3 throughput);
+void pathUpdateCausticFlags(__global Path *path, bool isSingular, bool isDiffuse);
+inline bool pathIsGatheredCaustic(__global Path *path);
 
 // Initialize path.
 inline void pathNew(__global Path *path, uint pixelIndex){
 	path->throughput = (float3)(1.0f, 1.0f, 1.0f);
 	path->pixelIndex = pixelIndex;
 	path->flags = 0;
+	path->glossyBounces = 0;
+	path->lastInstance = UINT_MAX;
 }
 
 // Multiply a fragment color with the current path throughput.
@@ -26,4 +38,32 @@ void pathSetThroughput(__global Path *path, float3 throughput){
 	path->throughput = throughput;
 }
 
+// Update the caustic tracking flags after a bounce. A path reaching an emissive
+// via one or more singular bounces right after its first non-singular bounce
+// on a diffuse surface is a caustic path whose contribution is already
+// provided by the caustic photon map.
+void pathUpdateCausticFlags(__global Path *path, bool isSingular, bool isDiffuse){
+	uint flags = path->flags;
+	if( isSingular ){
+		if( flags & PATH_FLAG_PHOTON_GATHER ){
+			flags |= PATH_FLAG_CAUSTIC;
+		}
+	} else if( flags & PATH_FLAG_NON_SINGULAR_BOUNCE ){
+		flags &= ~(PATH_FLAG_PHOTON_GATHER | PATH_FLAG_CAUSTIC);
+	} else {
+		flags |= PATH_FLAG_NON_SINGULAR_BOUNCE;
+		if( isDiffuse ){
+			flags |= PATH_FLAG_PHOTON_GATHER;
+		}
+	}
+	path->flags = flags;
+}
+
+// Check if the path is a caustic path whose contribution is provided by the
+// caustic photon map.
+inline bool pathIsGatheredCaustic(__global Path *path){
+	uint mask = PATH_FLAG_PHOTON_GATHER | PATH_FLAG_CAUSTIC;
+	return (path->flags & mask) == mask;
+}
+
 #endif
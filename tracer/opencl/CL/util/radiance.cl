@@ -0,0 +1,27 @@
+#ifndef RADIANCE_CL
+#define RADIANCE_CL
+
+// Non-finite sample guard policies. These values must match the ones
+// defined in tracer/non_finite.go
+#define NON_FINITE_COUNT 0
+#define NON_FINITE_DROP  1
+#define NON_FINITE_KEEP  2
+
+bool accumulateRadiance(__global float3 *accumulator, uint pixelIndex, float3 radiance, const uint nonFiniteGuard, volatile __global uint *nonFiniteCounter);
+
+// Add a radiance sample to a pixel accumulator. Unless the guard is disabled,
+// samples with NaN or Inf components are dropped so they do not poison the
+// pixel average. Returns false if the sample was dropped.
+bool accumulateRadiance(__global float3 *accumulator, uint pixelIndex, float3 radiance, const uint nonFiniteGuard, volatile __global uint *nonFiniteCounter){
+	if( nonFiniteGuard != NON_FINITE_KEEP && !(isfinite(radiance.x) && isfinite(radiance.y) && isfinite(radiance.z)) ){
+		if( nonFiniteGuard == NON_FINITE_COUNT ){
+			atomic_inc(nonFiniteCounter);
+		}
+		return false;
+	}
+
+	accumulator[pixelIndex] += radiance;
+	return true;
+}
+
+#endif
@@ -6,6 +6,9 @@
 	v = cross(normal, u);
 
 void surfaceInit(Surface *surface, __global Intersection *intersection, __global float4 *vertices, __global float4 *normals, __global float2 *uv, __global uint *matIndices);
+void surfaceApplyUVTransform(Surface *surface, __global float4 *uvTransforms, const uint hasUVTransforms);
+float3 surfaceGetVertexColor(__global Intersection *intersection, __global float4 *vertexColors);
+float3 surfaceConsistentNormal(float3 normal, float3 geomNormal, float3 outDir);
 void printSurface(Surface *surface);
 
 // Initialize surface parameters
@@ -24,12 +27,73 @@ void surfaceInit(Surface *surface, __global Intersection *intersection, __global
 					   wuv.z * normals[offset+2]).xyz
 			);
 
+	surface->geomNormal = normalize(cross(
+					  (vertices[offset+1] - vertices[offset]).xyz,
+					  (vertices[offset+2] - vertices[offset]).xyz
+			));
+
+	surface->frontFace = intersection->frontFace;
+
 	surface->uv = wuv.x * uv[offset] + 
 		          wuv.y * uv[offset+1] + 
 				  wuv.z * uv[offset+2];
 
 	// Fetch material root node index
 	surface->matNodeIndex = matIndices[intersection->triIndex];
+
+	surface->hitDist = intersection->wuvt.w;
+}
+
+// Transform the surface uv coordinates using the 2x3 affine uv transform of
+// the surface material. Each material root node uses two rows.
+void surfaceApplyUVTransform(Surface *surface, __global float4 *uvTransforms, const uint hasUVTransforms){
+	if( !hasUVTransforms ){
+		return;
+	}
+
+	float3 uvw = (float3)(surface->uv, 1.0f);
+	surface->uv = (float2)(
+		dot(uvTransforms[2 * surface->matNodeIndex].xyz, uvw),
+		dot(uvTransforms[2 * surface->matNodeIndex + 1].xyz, uvw)
+	);
+}
+
+// Interpolate the vertex colors of the intersected triangle
+float3 surfaceGetVertexColor(__global Intersection *intersection, __global float4 *vertexColors){
+	float3 wuv = intersection->wuvt.xyz;
+	int offset = intersection->triIndex * 3;
+
+	return (wuv.x * vertexColors[offset] + 
+		    wuv.y * vertexColors[offset+1] + 
+			wuv.z * vertexColors[offset+2]).xyz;
+}
+
+// Bend the shading normal so that the mirror reflection of outDir does not
+// fall below the geometric surface. This avoids black facets and energy leaks
+// near the shadow terminator of low-poly smooth-shaded meshes. The returned
+// normal retains the orientation of the input normal. See: A. Keller et al.,
+// "The Iray Light Transport Simulation and Rendering System", 2017, A.3.
+float3 surfaceConsistentNormal(float3 normal, float3 geomNormal, float3 outDir){
+	// The triangle winding may not agree with the shading normals
+	if( dot(geomNormal, normal) < 0.0f ){
+		geomNormal = -geomNormal;
+	}
+
+	// Work on the side of the surface where outDir lies
+	float side = dot(geomNormal, outDir) < 0.0f ? -1.0f : 1.0f;
+	float3 ng = geomNormal * side;
+	float3 ns = normal * side;
+
+	float3 reflected = 2.0f * dot(outDir, ns) * ns - outDir;
+	float threshold = min(0.9f * dot(outDir, ng), 0.01f);
+	float cosR = dot(reflected, ng);
+	if( cosR >= threshold ){
+		return normal;
+	}
+
+	float3 halfway = outDir + normalize(reflected + ng * (threshold - cosR));
+	float len = length(halfway);
+	return len > 1e-6f ? side * halfway / len : geomNormal;
 }
 
 void printSurface(Surface *surface){
@@ -4,6 +4,9 @@
 float3 mul4x1(float3 vec, float4 mat0, float4 mat1, float4 mat2, float4 mat3);
 float3 mul3x1(float3 vec, float3 mat0, float3 mat1, float3 mat2);
 float2 rayToLatLongUV(float3 vec);
+float2 rayToRotatedLatLongUV(float3 vec, float yaw);
+bool groundOccludes(float3 vec, float4 groundProjection);
+void orthonormalBasis(float3 n, float3 *t, float3 *b);
 
 // Transform vector with a 4x4 matrix.
 float3 mul4x1(float3 vec, float4 mat0, float4 mat1, float4 mat2, float4 mat3){
@@ -36,4 +39,34 @@ float2 rayToLatLongUV(float3 vec){
 		);
 }
 
+// Convert ray direction vector to normalized spherical UV coords for an
+// environment map rotated by yaw radians around the Y axis.
+float2 rayToRotatedLatLongUV(float3 vec, float yaw){
+	float c;
+	float s = sincos(-yaw, &c);
+
+	return rayToLatLongUV((float3)(
+		vec.x * c + vec.z * s,
+		vec.y,
+		vec.z * c - vec.x * s
+	));
+}
+
+// Check whether a ground projection occludes the environment towards the ray
+// direction vec. The w component of groundProjection contains the sine of the
+// horizon elevation; disabled projections set it to a value below -1.
+bool groundOccludes(float3 vec, float4 groundProjection){
+	return vec.y < groundProjection.w * length(vec);
+}
+
+// Build a right-handed orthonormal basis (t, b, n) around the unit vector n
+// using the branchless method by Duff et al. which is stable for any n.
+void orthonormalBasis(float3 n, float3 *t, float3 *b){
+	float sign = copysign(1.0f, n.z);
+	float a = -1.0f / (sign + n.z);
+	float c = n.x * n.y * a;
+	*t = (float3)(1.0f + sign * n.x * n.x * a, sign * c, -sign * n.x);
+	*b = (float3)(c, sign + n.y * n.y * a, -n.y);
+}
+
 #endif
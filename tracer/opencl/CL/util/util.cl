@@ -6,5 +6,6 @@
 #include "transform.cl"
 #include "surface.cl"
 #include "fresnel.cl"
+#include "radiance.cl"
 
 #endif
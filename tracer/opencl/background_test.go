@@ -0,0 +1,141 @@
+package opencl
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+func TestTransparentBackground(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	dir, err := ioutil.TempDir("", "polaris-background")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	imgFile := filepath.Join(dir, "fb.png")
+
+	pipeline := DefaultPipeline(NoDebug)
+	pipeline.PostProcess = append(pipeline.PostProcess, SaveFrameBuffer(imgFile))
+	tr := createTestTracer(t, pipeline, frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/coverage.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type spec struct {
+		mode          tracer.BackgroundMode
+		expNRGBA      bool
+		expCornerA    uint8
+		expCoverEdges bool
+	}
+	specs := []spec{
+		spec{tracer.OpaqueBackground, false, 255, false},
+		// Colors are only premultiplied by alpha if misses do not
+		// contribute any radiance
+		spec{tracer.TransparentBackground, true, 0, true},
+		spec{tracer.TransparentBlackBackground, false, 0, true},
+	}
+
+	for index, s := range specs {
+		blockReq := tracer.BlockRequest{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			BlockW:          frameW,
+			BlockH:          frameH,
+			SamplesPerPixel: 16,
+			NumBounces:      1,
+			Exposure:        1,
+			Seed:            1,
+			BackgroundMode:  s.mode,
+		}
+		traceTestScene(t, tr, sc, blockReq)
+		if _, err = tr.SyncFramebuffer(&blockReq); err != nil {
+			t.Fatal(err)
+		}
+
+		fb := make([]uint8, 4*frameW*frameH)
+		err = tr.resources.buffers.FrameBuffer.ReadData(0, 0, len(fb), fb)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The saved image should interpret the frame buffer colors as
+		// either straight or premultiplied by alpha
+		im := readTestImage(t, imgFile)
+		for y := 0; y < frameH; y++ {
+			for x := 0; x < frameW; x++ {
+				pix := fb[4*(y*frameW+x) : 4*(y*frameW+x)+4]
+				var exp color.Color = color.RGBA{pix[0], pix[1], pix[2], pix[3]}
+				if s.expNRGBA {
+					exp = color.NRGBA{pix[0], pix[1], pix[2], pix[3]}
+				}
+				if !colorsMatch(im.At(x, y), exp) {
+					t.Fatalf("[spec %d] expected pixel (%d, %d) to be %v; got %v", index, x, y, exp, im.At(x, y))
+				}
+			}
+		}
+
+		alpha := func(x, y int) uint8 {
+			_, _, _, a := im.At(x, y).RGBA()
+			return uint8(a >> 8)
+		}
+
+		// The quad covers the center of the frame and its edges project
+		// between columns/rows 4 and 11.
+		if got := alpha(0, 0); got != s.expCornerA {
+			t.Errorf("[spec %d] expected corner alpha to be %d; got %d", index, s.expCornerA, got)
+		}
+		if got := alpha(frameW/2, frameH/2); got != 255 {
+			t.Errorf("[spec %d] expected center alpha to be 255; got %d", index, got)
+		}
+
+		var partial int
+		for y := 0; y < frameH; y++ {
+			if a := alpha(4, y); a > 0 && a < 255 {
+				partial++
+			}
+		}
+		if hasPartial := partial > 0; hasPartial != s.expCoverEdges {
+			t.Errorf("[spec %d] expected partially covered edge pixels: %t; got %d", index, s.expCoverEdges, partial)
+		}
+	}
+}
+
+// Check whether two colors match allowing for the rounding errors introduced
+// by converting between premultiplied and straight alpha.
+func colorsMatch(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	for _, c := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		if c[0] > c[1]+2*0x101 || c[1] > c[0]+2*0x101 {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode a png image.
+func readTestImage(t *testing.T, imgFile string) image.Image {
+	f, err := os.Open(imgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	im, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return im
+}
@@ -0,0 +1,90 @@
+package opencl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl/device"
+)
+
+func TestBounceAOV(t *testing.T) {
+	devList, err := device.SelectDevices(device.CpuDevice, "CPU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devList) != 1 {
+		t.Fatalf("expected to get 1 CPU opencl device; got %d; check that openCL drivers are installed", len(devList))
+	}
+
+	sc, err := reader.ReadScene("fixtures/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Camera.SetupProjection(1)
+
+	tr, err := NewTracer("test", devList[0], nil, DefaultPipeline(NoDebug))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	const frameW, frameH = 32, 32
+	tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{frameW, frameH})
+	tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+	tr.UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 16,
+		NumBounces:      4,
+		MinBouncesForRR: 5,
+		Exposure:        1,
+		BounceAOVDepth:  2,
+	}
+
+	// Trace modifies the block request so we need to pass a copy
+	traceReq := blockReq
+	_, err = tr.Trace(context.Background(), &traceReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.MergeOutput(tr, &blockReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var energy [2]float32
+	for depth := uint32(0); depth < blockReq.BounceAOVDepth; depth++ {
+		radiance, err := tr.(*Tracer).BounceAOV(depth, &blockReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(radiance) != frameW*frameH {
+			t.Fatalf("[depth %d] expected to get %d radiance samples; got %d", depth, frameW*frameH, len(radiance))
+		}
+		for _, sample := range radiance {
+			energy[depth] += sample[0] + sample[1] + sample[2]
+		}
+	}
+
+	if energy[1] <= 0 {
+		t.Fatalf("expected indirect lighting to contribute energy to depth 1; got %f", energy[1])
+	}
+	if energy[0] <= energy[1] {
+		t.Fatalf("expected direct lighting energy (%f) to dominate indirect lighting energy (%f)", energy[0], energy[1])
+	}
+
+	// Requesting a depth that was not captured should fail
+	if _, err = tr.(*Tracer).BounceAOV(blockReq.BounceAOVDepth, &blockReq); err == nil {
+		t.Fatal("expected to get an error when requesting an uncaptured bounce depth")
+	}
+}
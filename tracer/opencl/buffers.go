@@ -4,7 +4,9 @@ import (
 	"reflect"
 
 	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/tracer"
 	"github.com/achilleasa/polaris/tracer/opencl/device"
+	"github.com/achilleasa/polaris/types"
 	"github.com/achilleasa/gopencl/v1.2/cl"
 )
 
@@ -16,6 +18,7 @@ const (
 	sizeofIntersection      = 32
 	sizeofEmissiveSample    = 16 // float3 but takes same space as float4
 	sizeofAccumulatorSample = 16 // float3
+	sizeofPhoton            = 32
 )
 
 type bufferSet struct {
@@ -28,8 +31,9 @@ type bufferSet struct {
 	// Mesh instances.
 	MeshInstances *device.Buffer
 
-	// Surface materials.
-	MaterialNodes *device.Buffer
+	// Surface materials and their optional opacities.
+	MaterialNodes     *device.Buffer
+	MaterialOpacities *device.Buffer
 
 	// Texture data
 	Textures        *device.Buffer
@@ -39,11 +43,56 @@ type bufferSet struct {
 	Vertices        *device.Buffer
 	Normals         *device.Buffer
 	UV              *device.Buffer
+	UVTransforms    *device.Buffer
+	VertexColors    *device.Buffer
 	MaterialIndices *device.Buffer
 
 	// Emissive primitives
 	EmissivePrimitives *device.Buffer
 
+	// The light link table mask and its row stride in words. A zero
+	// stride indicates that the scene does not define any light links.
+	LightLinks      *device.Buffer
+	LightLinkStride uint32
+
+	// The area light emitters of the light link table and the index of
+	// the environment light emissive or -1 if the scene has none.
+	LightLinkEmitters    *device.Buffer
+	NumLightLinkEmitters uint32
+	EnvEmissiveIndex     int32
+
+	// The render layer membership bitmask of each mesh instance and the
+	// number of layers defined by the scene.
+	InstanceLayers  *device.Buffer
+	NumRenderLayers uint32
+
+	// The alias table for importance sampling the environment light and
+	// the dimensions of the env map it was built for. Zero dimensions
+	// indicate that env importance sampling is not available.
+	EnvAliasTable *device.Buffer
+	EnvAliasDims  [2]uint32
+
+	// The packed ground projection settings; see
+	// scene.GroundProjection.Params.
+	GroundProjection types.Vec4
+
+	// The packed global fog settings; see scene.GlobalFog.Params.
+	GlobalFog types.Vec4
+
+	// Set if the scene contains any materials that shadow rays can pass
+	// through; see scene.HasTransmissiveMaterials.
+	HasTransmissiveMaterials bool
+
+	// Buffers use their data as host memory so we need to retain any
+	// data that is generated while uploading the scene.
+	envSampler     *scene.EnvironmentSampler
+	lightLinkMask  []uint32
+	lightEmitters  []scene.LightLinkEmitter
+	instanceLayers []uint32
+	uvTransforms   []types.Vec4
+	opacities      []float32
+	emissives      []scene.EmissivePrimitive
+
 	// Primary/occlusion/indirect rays and paths
 	Rays  [3]*device.Buffer
 	Paths *device.Buffer
@@ -65,8 +114,48 @@ type bufferSet struct {
 	EmissiveSamples *device.Buffer
 	DebugOutput     *device.Buffer
 
+	// Sobol sampler direction numbers.
+	SobolDirections *device.Buffer
+
+	// The Poisson-disk sub-pixel sample pattern.
+	PoissonPattern *device.Buffer
+
+	// Caustic photon map data.
+	Photons         *device.Buffer
+	PhotonCounter   *device.Buffer
+	PhotonCellStart *device.Buffer
+
+	// Per-bounce radiance buffers. Each buffer stores one layer of
+	// pixels for each captured bounce depth. The snapshot buffer tracks
+	// the trace accumulator contents at the end of the last bounce.
+	BounceSnapshot         *device.Buffer
+	TraceBounceAccumulator *device.Buffer
+	FrameBounceAccumulator *device.Buffer
+
+	// Per-layer coverage buffers. Each buffer stores one layer of pixels
+	// for each render layer defined by the scene.
+	TraceLayerAccumulator *device.Buffer
+	FrameLayerAccumulator *device.Buffer
+
+	// Primary ray coverage buffers for rendering with a transparent
+	// background. Each buffer stores the number of primary ray hits
+	// for each pixel.
+	TraceCoverageAccumulator *device.Buffer
+	FrameCoverageAccumulator *device.Buffer
+
 	// Counters
 	RayCounters [3]*device.Buffer
+
+	// The number of non-finite samples dropped by the current trace.
+	NonFiniteCounter *device.Buffer
+
+	// BVH node visit and triangle test counters for the current sample.
+	TraversalStats *device.Buffer
+
+	// The traversal cost of each ray processed by the last intersection
+	// query. Only populated if the kernels are built with the
+	// BVH_TRAVERSAL_COST symbol defined.
+	TraversalCost *device.Buffer
 }
 
 // Allocate new buffer set.
@@ -78,13 +167,20 @@ func newBufferSet(dev *device.Device) *bufferSet {
 		BvhNodes:           dev.Buffer("bvhNodes"),
 		MeshInstances:      dev.Buffer("meshInstances"),
 		MaterialNodes:      dev.Buffer("materialNodes"),
+		MaterialOpacities:  dev.Buffer("materialOpacities"),
 		Textures:           dev.Buffer("textures"),
 		TextureMetadata:    dev.Buffer("textureMetadata"),
 		Vertices:           dev.Buffer("vertices"),
 		Normals:            dev.Buffer("normals"),
 		UV:                 dev.Buffer("uv"),
+		UVTransforms:       dev.Buffer("uvTransforms"),
+		VertexColors:       dev.Buffer("vertexColors"),
 		MaterialIndices:    dev.Buffer("materialIndices"),
 		EmissivePrimitives: dev.Buffer("emissivePrimitives"),
+		LightLinks:         dev.Buffer("lightLinks"),
+		LightLinkEmitters:  dev.Buffer("lightLinkEmitters"),
+		InstanceLayers:     dev.Buffer("instanceLayers"),
+		EnvAliasTable:      dev.Buffer("envAliasTable"),
 		// Tracer data
 		Rays: [3]*device.Buffer{
 			dev.Buffer("rays0"),
@@ -98,11 +194,27 @@ func newBufferSet(dev *device.Device) *bufferSet {
 		TraceAccumulator: dev.Buffer("traceAccumulator"),
 		FrameAccumulator: dev.Buffer("frameAccumulator"),
 		DebugOutput:      dev.Buffer("debugOutput"),
+		SobolDirections:  dev.Buffer("sobolDirections"),
+		PoissonPattern:   dev.Buffer("poissonPattern"),
+		Photons:          dev.Buffer("photons"),
+		PhotonCounter:    dev.Buffer("photonCounter"),
+		PhotonCellStart:  dev.Buffer("photonCellStart"),
+
+		BounceSnapshot:           dev.Buffer("bounceSnapshot"),
+		TraceBounceAccumulator:   dev.Buffer("traceBounceAccumulator"),
+		FrameBounceAccumulator:   dev.Buffer("frameBounceAccumulator"),
+		TraceLayerAccumulator:    dev.Buffer("traceLayerAccumulator"),
+		FrameLayerAccumulator:    dev.Buffer("frameLayerAccumulator"),
+		TraceCoverageAccumulator: dev.Buffer("traceCoverageAccumulator"),
+		FrameCoverageAccumulator: dev.Buffer("frameCoverageAccumulator"),
 		RayCounters: [3]*device.Buffer{
 			dev.Buffer("numRays0"),
 			dev.Buffer("numRays1"),
 			dev.Buffer("numRays2"),
 		},
+		NonFiniteCounter: dev.Buffer("nonFiniteCounter"),
+		TraversalStats:   dev.Buffer("traversalStats"),
+		TraversalCost:    dev.Buffer("traversalCost"),
 	}
 }
 
@@ -142,6 +254,18 @@ func (bs *bufferSet) Resize(frameW, frameH uint32) error {
 			return err
 		}
 	}
+	err = bs.NonFiniteCounter.Allocate(4, cl.MEM_READ_WRITE)
+	if err != nil {
+		return err
+	}
+	err = bs.TraversalStats.Allocate(8, cl.MEM_READ_WRITE)
+	if err != nil {
+		return err
+	}
+	err = bs.TraversalCost.Allocate(int(pixels*4), cl.MEM_READ_WRITE)
+	if err != nil {
+		return err
+	}
 	err = bs.Paths.Allocate(int(pixels*sizeofPath), cl.MEM_READ_WRITE)
 	if err != nil {
 		return err
@@ -177,17 +301,48 @@ func (bs *bufferSet) Resize(frameW, frameH uint32) error {
 func (bs *bufferSet) UploadSceneData(scene *scene.Scene) error {
 	var err error
 
+	lightLinks := scene.LightLinkTable()
+	bs.LightLinkStride = lightLinks.Stride
+	bs.lightLinkMask = lightLinks.Mask
+	bs.lightEmitters = lightLinks.Emitters
+	bs.NumLightLinkEmitters = uint32(len(lightLinks.Emitters))
+	bs.EnvEmissiveIndex = lightLinks.EnvironmentIndex
+	bs.instanceLayers = scene.InstanceLayerMasks()
+	bs.NumRenderLayers = uint32(len(scene.RenderLayers))
+	bs.uvTransforms = scene.UVTransformMatrices()
+	bs.opacities = scene.OpacityList()
+	bs.emissives = scene.EmissiveSamplerList()
+	bs.GroundProjection = scene.GroundProjection.Params()
+	bs.GlobalFog = scene.GlobalFog.Params()
+	bs.HasTransmissiveMaterials = scene.HasTransmissiveMaterials()
+
+	bs.envSampler, err = scene.EnvironmentSampler()
+	if err != nil {
+		return err
+	}
+	bs.EnvAliasDims = [2]uint32{}
+	if bs.envSampler != nil {
+		bs.EnvAliasDims = [2]uint32{bs.envSampler.Width, bs.envSampler.Height}
+	}
+
 	targets := map[*device.Buffer]interface{}{
 		bs.BvhNodes:           scene.BvhNodeList,
 		bs.MeshInstances:      scene.MeshInstanceList,
 		bs.MaterialNodes:      scene.MaterialNodeList,
+		bs.MaterialOpacities:  bs.opacities,
 		bs.Textures:           scene.TextureData,
 		bs.TextureMetadata:    scene.TextureMetadata,
 		bs.Vertices:           scene.VertexList,
 		bs.Normals:            scene.NormalList,
 		bs.UV:                 scene.UvList,
+		bs.UVTransforms:       bs.uvTransforms,
+		bs.VertexColors:       scene.VertexColorList,
 		bs.MaterialIndices:    scene.MaterialIndex,
-		bs.EmissivePrimitives: scene.EmissivePrimitives,
+		bs.EmissivePrimitives: bs.emissives,
+		bs.LightLinks:         bs.lightLinkMask,
+		bs.LightLinkEmitters:  bs.lightEmitters,
+		bs.InstanceLayers:     bs.instanceLayers,
+		bs.EnvAliasTable:      bs.envSampler.AliasTable(),
 	}
 
 	for buf, data := range targets {
@@ -199,3 +354,89 @@ func (bs *bufferSet) UploadSceneData(scene *scene.Scene) error {
 
 	return nil
 }
+
+// Allocate the buffers for storing up to maxPhotons traced photons and reset
+// the photon counter.
+func (bs *bufferSet) AllocatePhotonBuffers(maxPhotons uint32) error {
+	err := bs.Photons.Allocate(int(maxPhotons*sizeofPhoton), cl.MEM_READ_WRITE)
+	if err != nil {
+		return err
+	}
+
+	return bs.PhotonCounter.AllocateAndWriteData([]int32{0}, cl.MEM_READ_WRITE)
+}
+
+// Upload photon map data to the device buffers.
+func (bs *bufferSet) UploadPhotonMap(photonMap *tracer.PhotonMap) error {
+	err := bs.Photons.AllocateAndWriteData(photonMap.Photons, cl.MEM_READ_ONLY)
+	if err != nil {
+		return err
+	}
+
+	return bs.PhotonCellStart.AllocateAndWriteData(photonMap.CellStart, cl.MEM_READ_ONLY)
+}
+
+// Allocate the buffers for capturing per-bounce radiance for the requested
+// number of bounce depths. Returns true if the buffers were reallocated.
+func (bs *bufferSet) AllocateBounceBuffers(frameW, frameH, depth uint32) (bool, error) {
+	pixels := frameW * frameH
+	size := int(depth * pixels * sizeofAccumulatorSample)
+	if bs.FrameBounceAccumulator.Size() == size {
+		return false, nil
+	}
+
+	err := bs.BounceSnapshot.Allocate(int(pixels*sizeofAccumulatorSample), cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+	err = bs.TraceBounceAccumulator.Allocate(size, cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+	err = bs.FrameBounceAccumulator.Allocate(size, cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Allocate the buffers for capturing the coverage of each render layer.
+// Returns true if the buffers were reallocated.
+func (bs *bufferSet) AllocateLayerBuffers(frameW, frameH, numLayers uint32) (bool, error) {
+	size := int(numLayers * frameW * frameH * sizeofAccumulatorSample)
+	if bs.FrameLayerAccumulator.Size() == size {
+		return false, nil
+	}
+
+	err := bs.TraceLayerAccumulator.Allocate(size, cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+	err = bs.FrameLayerAccumulator.Allocate(size, cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Allocate the buffers for capturing the primary ray coverage of each pixel.
+// Returns true if the buffers were reallocated.
+func (bs *bufferSet) AllocateCoverageBuffers(frameW, frameH uint32) (bool, error) {
+	size := int(frameW * frameH * sizeofAccumulatorSample)
+	if bs.FrameCoverageAccumulator.Size() == size {
+		return false, nil
+	}
+
+	err := bs.TraceCoverageAccumulator.Allocate(size, cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+	err = bs.FrameCoverageAccumulator.Allocate(size, cl.MEM_READ_WRITE)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
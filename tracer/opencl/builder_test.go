@@ -0,0 +1,88 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/texure"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSceneBuilderRender(t *testing.T) {
+	const frameW, frameH = 8, 8
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A 2x2 red/green checker texture mapped to a quad on the XY plane that
+	// is lit by a large emissive quad behind the camera
+	checkerMeta := scene.TextureMetadata{Format: texture.Rgba8, Width: 2, Height: 2}
+	checkerData := []byte{
+		255, 0, 0, 255, 0, 255, 0, 255,
+		0, 255, 0, 255, 255, 0, 0, 255,
+	}
+	quad := []scene.BuilderTriangle{
+		{
+			Vertices: [3]types.Vec3{{-1, -1, 0}, {1, -1, 0}, {1, 1, 0}},
+			UVs:      [3]types.Vec2{{0, 0}, {1, 0}, {1, 1}},
+		},
+		{
+			Vertices: [3]types.Vec3{{-1, -1, 0}, {1, 1, 0}, {-1, 1, 0}},
+			UVs:      [3]types.Vec2{{0, 0}, {1, 1}, {0, 1}},
+		},
+	}
+	light := []scene.BuilderTriangle{
+		{Vertices: [3]types.Vec3{{-10, -10, 4}, {-10, 10, 4}, {10, 10, 4}}},
+		{Vertices: [3]types.Vec3{{-10, -10, 4}, {10, 10, 4}, {10, -10, 4}}},
+	}
+	diffuse := scene.MaterialNode{Union1: [4]int32{int32(material.BxdfDiffuse), -1, -1, -1}, Union2: types.Vec4{1, 1, 1, 0}}
+	emissive := scene.MaterialNode{Union1: [4]int32{int32(material.BxdfEmissive), -1, -1, -1}, Union2: types.Vec4{1, 1, 1, 0}, Union4: types.Vec3{0, 0, 1}}
+
+	offset := types.Vec3{0, 0, 0.5}
+	sc, err := scene.NewSceneBuilder().
+		AddMaterial("checker", diffuse, "checker-tex").
+		AddMaterial("light", emissive, "").
+		AddTexture("checker-tex", checkerMeta, checkerData).
+		AddMesh("quad", "checker", quad).
+		AddMesh("light", "light", light).
+		AddInstance("quad", types.Translate4(offset)).
+		AddInstance("light", types.Ident4()).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc.Camera = scene.NewCamera(45)
+	sc.Camera.Position = types.Vec3{0, 0, 2.5}
+	sc.Camera.LookAt = offset
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 16,
+		NumBounces:      1,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	// The quad fills the frame so each pixel should show one of the checker
+	// colors
+	var redPixels, greenPixels int
+	for pixel, value := range traceTestScene(t, tr, sc, blockReq) {
+		if !isFinite(value) {
+			t.Fatalf("expected pixel %d radiance to be finite; got %v", pixel, value)
+		}
+		if value[0] > value[1] {
+			redPixels++
+		} else if value[1] > value[0] {
+			greenPixels++
+		}
+	}
+	if redPixels == 0 || greenPixels == 0 {
+		t.Fatalf("expected rendered quad to show the checker pattern; got %d red and %d green pixels", redPixels, greenPixels)
+	}
+}
@@ -0,0 +1,107 @@
+package opencl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl/device"
+)
+
+func TestBvhTraversalCost(t *testing.T) {
+	devList, err := device.SelectDevices(device.CpuDevice, "CPU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devList) != 1 {
+		t.Fatalf("expected to get 1 CPU opencl device; got %d; check that openCL drivers are installed", len(devList))
+	}
+
+	sc, err := reader.ReadScene("fixtures/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Camera.SetupProjection(1)
+
+	const frameW, frameH = 32, 32
+	var costs, hitFlags []uint32
+
+	// Capture the traversal cost and hit flag of each primary ray
+	pipeline := &Pipeline{
+		PrimaryRayGenerator: PerspectiveCamera(),
+		Integrator: func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+			numPixels := int(blockReq.BlockW * blockReq.BlockH)
+			elapsed, err := tr.resources.RayIntersectionQuery(0, blockReq.RayBatchSize, numPixels, false)
+			if err != nil {
+				return elapsed, err
+			}
+
+			costs = make([]uint32, numPixels)
+			err = tr.resources.buffers.TraversalCost.ReadData(0, 0, numPixels*4, costs)
+			if err != nil {
+				return elapsed, err
+			}
+			hitFlags = make([]uint32, numPixels)
+			return elapsed, tr.resources.buffers.HitFlags.ReadData(0, 0, numPixels*4, hitFlags)
+		},
+		Defines: []string{"BVH_TRAVERSAL_COST"},
+	}
+
+	tr, err := NewTracer("test", devList[0], nil, pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{frameW, frameH})
+	tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+	tr.UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 1,
+		NumBounces:      1,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+	}
+	_, err = tr.Trace(context.Background(), &blockReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rays that hit the box descend into the mesh BVH and test triangles
+	// while rays escaping to the sky are culled by the top-level BVH.
+	var hitCost, missCost, hits, misses uint32
+	for index, cost := range costs {
+		if cost == 0 {
+			t.Fatalf("expected traversal cost for ray %d to be recorded", index)
+		}
+
+		if hitFlags[index] != 0 {
+			hitCost += cost
+			hits++
+		} else {
+			missCost += cost
+			misses++
+		}
+	}
+
+	if hits == 0 || misses == 0 {
+		t.Fatalf("expected primary rays to both hit and miss the box; got %d hits and %d misses", hits, misses)
+	}
+
+	avgHitCost := float32(hitCost) / float32(hits)
+	avgMissCost := float32(missCost) / float32(misses)
+	if avgHitCost <= avgMissCost {
+		t.Fatalf("expected the average traversal cost for rays hitting the box (%f) to exceed the one for sky rays (%f)", avgHitCost, avgMissCost)
+	}
+}
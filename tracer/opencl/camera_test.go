@@ -0,0 +1,384 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSubpixelPatterns(t *testing.T) {
+	const frameW, frameH = 2, 2
+	const numSamples = tracer.SubpixelPatternSize
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	type spec struct {
+		pattern  tracer.SubpixelPattern
+		minCount int
+		maxCount int
+	}
+
+	// Split each pixel into 4x4 strata and count the samples falling into
+	// each stratum; a perfectly uniform pattern places 4 out of 64
+	// samples into every stratum.
+	specs := []spec{
+		spec{tracer.GridPattern, 4, 4},
+		spec{tracer.HaltonPattern, 2, 6},
+		spec{tracer.PoissonPattern, 2, 6},
+		spec{tracer.SamplerPattern, 4, 4},
+	}
+
+	for index, s := range specs {
+		blockReq := &tracer.BlockRequest{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			BlockW:          frameW,
+			BlockH:          frameH,
+			Seed:            1,
+			Sampler:         tracer.SobolSampler,
+			SubpixelPattern: s.pattern,
+		}
+
+		var counts [frameW * frameH][4][4]int
+		var pixel0Samples [numSamples]types.Vec2
+		for sampleIndex := uint32(0); sampleIndex < numSamples; sampleIndex++ {
+			blockReq.AccumulatedSamples = sampleIndex
+			rays := generateTestPrimaryRays(t, tr, blockReq, scene.CameraLens{}, types.Vec2{})
+			for pixelIndex, ray := range rays {
+				p := subpixelSampleFromRay(ray, pixelIndex%frameW, pixelIndex/frameW, frameW, frameH)
+				if p[0] < 0 || p[0] >= 1 || p[1] < 0 || p[1] >= 1 {
+					t.Fatalf("[spec %d] expected sample %d of pixel %d to be in the [0, 1) range; got %v", index, sampleIndex, pixelIndex, p)
+				}
+				counts[pixelIndex][int(p[0]*4)][int(p[1]*4)]++
+				if pixelIndex == 0 {
+					pixel0Samples[sampleIndex] = p
+				}
+			}
+		}
+
+		for pixelIndex := range counts {
+			for x := 0; x < 4; x++ {
+				for y := 0; y < 4; y++ {
+					if c := counts[pixelIndex][x][y]; c < s.minCount || c > s.maxCount {
+						t.Errorf("[spec %d] expected stratum (%d, %d) of pixel %d to contain [%d, %d] samples; got %d", index, x, y, pixelIndex, s.minCount, s.maxCount, c)
+					}
+				}
+			}
+		}
+
+		if s.pattern != tracer.GridPattern {
+			continue
+		}
+
+		// Every 4 consecutive grid samples should form a regular 2x2 grid.
+		// Sample positions are measured relative to the cell corner of the
+		// first sample.
+		const halfCell = 0.5 / 8
+		shift := pixel0Samples[0]
+		for first := 0; first < numSamples; first += 4 {
+			var seen [2][2]bool
+			for _, p := range pixel0Samples[first : first+4] {
+				x := int(math.Mod(float64(p[0]-shift[0]+1+halfCell), 1) * 2)
+				y := int(math.Mod(float64(p[1]-shift[1]+1+halfCell), 1) * 2)
+				if seen[x][y] {
+					t.Fatalf("[spec %d] expected grid samples %d-%d to occupy different quadrants", index, first, first+3)
+				}
+				seen[x][y] = true
+			}
+		}
+	}
+}
+
+func TestThinLensFocus(t *testing.T) {
+	const frameW, frameH = 8, 8
+	const focusDistance = 4
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	blockReq := &tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		Seed:            1,
+		SubpixelPattern: tracer.GridPattern,
+	}
+	lens := scene.CameraLens{ApertureRadius: 0.5, FocusDistance: focusDistance, ApertureBlades: 6}
+
+	// Thin lens rays start on the aperture and cross the pinhole rays for
+	// the same sub-pixel samples at the focal plane.
+	pinholeRays := generateTestPrimaryRays(t, tr, blockReq, scene.CameraLens{}, types.Vec2{})
+	lensRays := generateTestPrimaryRays(t, tr, blockReq, lens, types.Vec2{})
+	for index, ray := range lensRays {
+		origin, dir := ray.Origin.Vec3(), ray.Dir.Vec3()
+		if origin[2] != 0 || origin.Len() > lens.ApertureRadius+1e-5 {
+			t.Fatalf("[ray %d] expected ray origin to lie on the aperture; got %v", index, origin)
+		}
+
+		pinholeDir := pinholeRays[index].Dir.Vec3()
+		expFocusPoint := pinholeDir.Mul(focusDistance / -pinholeDir[2])
+		focusPoint := origin.Add(dir.Mul((focusDistance + origin[2]) / -dir[2]))
+		if focusPoint.Sub(expFocusPoint).Len() > 1e-4 {
+			t.Fatalf("[ray %d] expected ray to cross the focal plane at %v; got %v", index, expFocusPoint, focusPoint)
+		}
+	}
+}
+
+func TestClipPlanes(t *testing.T) {
+	const frameW, frameH = 8, 8
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	blockReq := &tracer.BlockRequest{
+		FrameW: frameW,
+		FrameH: frameH,
+		BlockW: frameW,
+		BlockH: frameH,
+		Seed:   1,
+	}
+
+	type spec struct {
+		near float32
+		far  float32
+	}
+	specs := []spec{
+		spec{0, 0},
+		spec{0.5, 0},
+		spec{0, 10},
+		spec{0.5, 10},
+	}
+
+	for specIndex, s := range specs {
+		rays := generateTestPrimaryRays(t, tr, blockReq, scene.CameraLens{}, types.Vec2{s.near, s.far})
+		for index, ray := range rays {
+			// The clip planes are perpendicular to the view direction so
+			// the ray origin should lie on the near plane and the max
+			// distance should span the gap between the two planes.
+			cosTheta := -ray.Dir[2]
+			if depth := -ray.Origin[2]; math.Abs(float64(depth-s.near)) > 1e-4 {
+				t.Fatalf("[spec %d] expected ray %d origin to lie at depth %f; got %f", specIndex, index, s.near, depth)
+			}
+
+			expMaxDist := float32(math.MaxFloat32)
+			if s.far > 0 {
+				expMaxDist = (s.far - s.near) / cosTheta
+			}
+			if maxDist := ray.Origin[3]; math.Abs(float64(maxDist-expMaxDist)) > 1e-4*float64(expMaxDist) {
+				t.Fatalf("[spec %d] expected ray %d max distance to be %f; got %f", specIndex, index, expMaxDist, maxDist)
+			}
+		}
+	}
+}
+
+func TestClipPlanesCulling(t *testing.T) {
+	const frameW, frameH = 8, 8
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// An emissive quad 3 units in front of the camera
+	sc, err := reader.ReadScene("fixtures/emitter.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 1,
+		NumBounces:      1,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	type spec struct {
+		near, far  float32
+		expVisible bool
+	}
+	specs := []spec{
+		spec{0, 0, true},
+		spec{1, 10, true},
+		// The quad lies behind the far plane
+		spec{0, 2, false},
+		// The quad lies in front of the near plane
+		spec{4, 0, false},
+	}
+
+	for index, s := range specs {
+		sc.Camera.NearClip, sc.Camera.FarClip = s.near, s.far
+		for pixel, value := range traceTestScene(t, tr, sc, blockReq) {
+			if visible := value.MaxComponent() > 0; visible != s.expVisible {
+				t.Fatalf("[spec %d] expected quad visibility at pixel %d to be %t; got radiance %v", index, pixel, s.expVisible, value)
+			}
+		}
+	}
+}
+
+func TestPolygonalAperture(t *testing.T) {
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), apertureTestFrameW, apertureTestFrameH)
+	defer tr.Close()
+
+	const blades = 5
+	const rotation = 0.3
+
+	vertices := make([]types.Vec2, blades)
+	for i := range vertices {
+		sin, cos := math.Sincos(float64(i)*2*math.Pi/blades + rotation)
+		vertices[i] = types.Vec2{float32(cos), float32(sin)}
+	}
+
+	// For a convex polygon with counter-clockwise vertices, a point
+	// is inside if it lies on the left of every edge.
+	insidePolygon := func(p types.Vec2, scale float32) bool {
+		for i := range vertices {
+			v0, v1 := vertices[i], vertices[(i+1)%blades]
+			v0, v1 = types.Vec2{v0[0] * scale, v0[1] * scale}, types.Vec2{v1[0] * scale, v1[1] * scale}
+			if (v1[0]-v0[0])*(p[1]-v0[1])-(v1[1]-v0[1])*(p[0]-v0[0]) < -1e-5 {
+				return false
+			}
+		}
+		return true
+	}
+
+	points := sampleTestAperture(t, tr, blades, rotation)
+	sectorCounts := make([]int, blades)
+	var halfScaleCount int
+	for index, p := range points {
+		if !insidePolygon(p, 1) {
+			t.Fatalf("[sample %d] expected point %v to lie inside the aperture pentagon", index, p)
+		}
+		if insidePolygon(p, 0.5) {
+			halfScaleCount++
+		}
+
+		angle := math.Atan2(float64(p[1]), float64(p[0])) - rotation
+		for angle < 0 {
+			angle += 2 * math.Pi
+		}
+		sectorCounts[int(angle/(2*math.Pi/blades))%blades]++
+	}
+
+	// Uniform samples should be evenly split across the blade
+	// triangles and a pentagon at half scale should receive a
+	// quarter of them.
+	numSamples := float64(len(points))
+	for sector, count := range sectorCounts {
+		if got := float64(count) / numSamples; math.Abs(got-1.0/blades) > 0.01 {
+			t.Errorf("expected blade sector %d to receive %f of the samples; got %f", sector, 1.0/blades, got)
+		}
+	}
+	if got := float64(halfScaleCount) / numSamples; math.Abs(got-0.25) > 0.01 {
+		t.Errorf("expected half-scale pentagon to receive 0.25 of the samples; got %f", got)
+	}
+}
+
+func TestCircularAperture(t *testing.T) {
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), apertureTestFrameW, apertureTestFrameH)
+	defer tr.Close()
+
+	// Apertures with too few or too many blades are circular
+	for _, blades := range []uint32{0, 2, scene.MaxApertureBlades + 1} {
+		points := sampleTestAperture(t, tr, blades, 1)
+		var halfRadiusCount int
+		for index, p := range points {
+			r := math.Sqrt(float64(p.Dot(p)))
+			if r > 1+1e-5 {
+				t.Fatalf("[blades %d] expected sample %d to lie inside the unit disk; got radius %f", blades, index, r)
+			}
+			if r < 0.5 {
+				halfRadiusCount++
+			}
+		}
+
+		if got := float64(halfRadiusCount) / float64(len(points)); math.Abs(got-0.25) > 0.02 {
+			t.Errorf("[blades %d] expected disk of radius 0.5 to receive 0.25 of the samples; got %f", blades, got)
+		}
+	}
+}
+
+// Generate a primary ray for each pixel in the block request using a camera
+// at the origin that looks towards -Z. The camera frustrum corners lie on the
+// z = -1 plane and span the [-1, 1] range along the X and Y axes.
+func generateTestPrimaryRays(t *testing.T, tr *Tracer, blockReq *tracer.BlockRequest, lens scene.CameraLens, clipPlanes types.Vec2) []testRay {
+	frustrum := [4]types.Vec4{
+		{-1, 1, -1, 0},
+		{1, 1, -1, 0},
+		{-1, -1, -1, 0},
+		{1, -1, -1, 0},
+	}
+	lensBasis := [3]types.Vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, -1}}
+
+	_, err := tr.resources.GeneratePrimaryRays(blockReq, types.Vec3{}, frustrum, lens, lensBasis, clipPlanes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rays := make([]testRay, blockReq.BlockW*blockReq.BlockH)
+	err = tr.resources.buffers.Rays[0].ReadData(0, 0, len(rays)*sizeofRay, rays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rays
+}
+
+// Recover the sub-pixel sample that was used for generating the pinhole
+// camera ray for pixel (x, y) by undoing the frustrum interpolation and the
+// tent filter warp that are applied by the camera kernel.
+func subpixelSampleFromRay(ray testRay, x, y int, frameW, frameH uint32) types.Vec2 {
+	dir := ray.Dir.Vec3()
+	offset := [2]float32{
+		(dir[0]/-dir[2]+1)*0.5*float32(frameW) - float32(x),
+		(1-dir[1]/-dir[2])*0.5*float32(frameH) - float32(y),
+	}
+
+	var sample types.Vec2
+	for axis, o := range offset {
+		u := o + 0.5
+		if u < 1 {
+			sample[axis] = 0.5 * u * u
+		} else {
+			sample[axis] = 1 - 0.5*(2-u)*(2-u)
+		}
+	}
+	return sample
+}
+
+// The frame dimensions used by aperture tests.
+const apertureTestFrameW, apertureTestFrameH = 32, 32
+
+// Collect the aperture points of the thin lens rays generated for a lens with
+// unit circumradius using a different seed for each frame.
+func sampleTestAperture(t *testing.T, tr *Tracer, blades uint32, rotation float32) []types.Vec2 {
+	const numSeeds = 20
+
+	lens := scene.CameraLens{
+		ApertureRadius:   1,
+		FocusDistance:    1,
+		ApertureBlades:   blades,
+		ApertureRotation: rotation,
+	}
+
+	points := make([]types.Vec2, 0, numSeeds*apertureTestFrameW*apertureTestFrameH)
+	for seed := uint32(1); seed <= numSeeds; seed++ {
+		blockReq := &tracer.BlockRequest{
+			FrameW: apertureTestFrameW,
+			FrameH: apertureTestFrameH,
+			BlockW: apertureTestFrameW,
+			BlockH: apertureTestFrameH,
+			Seed:   seed,
+		}
+		for _, ray := range generateTestPrimaryRays(t, tr, blockReq, lens, types.Vec2{}) {
+			points = append(points, types.Vec2{ray.Origin[0], ray.Origin[1]})
+		}
+	}
+	return points
+}
@@ -0,0 +1,59 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRadianceClamp(t *testing.T) {
+	const frameW, frameH = 4, 4
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/emitter.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The camera sees the emitter directly so its radiance is clamped by
+	// the direct clamp.
+	radiance := types.Vec3{50, 40, 30}
+	type spec struct {
+		directClamp   float32
+		indirectClamp float32
+		expRadiance   types.Vec3
+	}
+	specs := []spec{
+		// Both clamps are off by default
+		spec{0, 0, radiance},
+		spec{0, 10, radiance},
+		// Clamping preserves the hue of the clamped radiance
+		spec{25, 10, types.Vec3{25, 20, 15}},
+	}
+
+	for index, s := range specs {
+		blockReq := tracer.BlockRequest{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			BlockW:          frameW,
+			BlockH:          frameH,
+			SamplesPerPixel: 1,
+			NumBounces:      1,
+			Exposure:        1,
+			DirectClamp:     s.directClamp,
+			IndirectClamp:   s.indirectClamp,
+		}
+		got := traceTestScene(t, tr, sc, blockReq)[(frameH/2)*frameW+frameW/2]
+		for c := 0; c < 3; c++ {
+			if math.Abs(float64(got[c]-s.expRadiance[c])) > 1e-3 {
+				t.Errorf("[spec %d] expected radiance to be %v; got %v", index, s.expRadiance, got)
+				break
+			}
+		}
+	}
+}
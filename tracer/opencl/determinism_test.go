@@ -0,0 +1,106 @@
+package opencl
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl/device"
+)
+
+func TestDeterministicAccumulation(t *testing.T) {
+	const frameW, frameH = 16, 16
+	const samplesPerPixel = 4
+
+	sc, err := reader.ReadScene("fixtures/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Camera.SetupProjection(float32(frameW) / float32(frameH))
+
+	devList, err := device.SelectDevices(device.CpuDevice, "CPU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devList) != 1 {
+		t.Fatalf("expected to get 1 CPU opencl device; got %d; check that openCL drivers are installed", len(devList))
+	}
+	sharedCtx, err := device.NewSharedContext(devList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render a few seeded frames by splitting the frame rows between
+	// numTracers tracers in the same way as the renderer and merging their
+	// output into the frame accumulator of the first tracer.
+	render := func(numTracers int) []float32 {
+		tracers := make([]tracer.Tracer, numTracers)
+		for index := range tracers {
+			tr, err := NewTracer(fmt.Sprintf("test-%d", index), devList[0], sharedCtx, DefaultPipeline(NoDebug))
+			if err == nil {
+				err = tr.Init()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tr.Close()
+
+			tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{frameW, frameH})
+			tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+			tr.UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
+			tracers[index] = tr
+		}
+		primary := tracers[0].(*Tracer)
+
+		scheduler := tracer.NaiveScheduler()
+		rng := tracer.NewRNG(tracer.PCG32, 42)
+		blockReq := tracer.BlockRequest{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			BlockW:          frameW,
+			SamplesPerPixel: samplesPerPixel,
+			NumBounces:      3,
+			MinBouncesForRR: 1,
+			Exposure:        1,
+		}
+		for frame := uint32(0); frame < 3; frame++ {
+			blockReq.Seed = rng.Uint32()
+			blockReq.AccumulatedSamples = frame * samplesPerPixel
+			blockReq.BlockY = 0
+			for trIndex, blockH := range scheduler.Schedule(tracers, frameH) {
+				blockReq.BlockH = blockH
+
+				// Trace modifies the block request so we need to pass a copy
+				traceReq := blockReq
+				if _, err := tracers[trIndex].Trace(context.Background(), &traceReq); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := primary.MergeOutput(tracers[trIndex], &traceReq); err != nil {
+					t.Fatal(err)
+				}
+
+				blockReq.BlockY += blockH
+			}
+		}
+
+		blockReq.BlockY, blockReq.BlockH = 0, frameH
+		data, err := primary.ReadAccumulator(&blockReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	exp := render(1)
+	for _, numTracers := range []int{2, 4} {
+		got := render(numTracers)
+		for index := range exp {
+			if math.Float32bits(got[index]) != math.Float32bits(exp[index]) {
+				t.Fatalf("[%d tracers] expected accumulator value %d to be %v; got %v", numTracers, index, exp[index], got[index])
+			}
+		}
+	}
+}
@@ -49,6 +49,9 @@ type Device struct {
 	// Speed estimate in GFlops.
 	Speed uint32
 
+	// Set if the device is a partition of a parent device.
+	subDevice bool
+
 	// Opencl handles; allocated when device is initialized.
 	ctx      *cl.Context
 	cmdQueue cl.CommandQueue
@@ -70,8 +73,9 @@ func (d Device) String() string {
 	)
 }
 
-// Initialize device.
-func (d *Device) Init(programFile string, ctx *cl.Context) error {
+// Initialize device. The program is built with the supplied preprocessor
+// symbols defined.
+func (d *Device) Init(programFile string, ctx *cl.Context, defines ...string) error {
 	var errCode cl.ErrorCode
 
 	// Already initialized
@@ -130,11 +134,15 @@ func (d *Device) Init(programFile string, ctx *cl.Context) error {
 		return fmt.Errorf("opencl device (%s): could not create program (error: %s; code %d)", d.Name, ErrorName(errCode), errCode)
 	}
 
+	buildOpts := fmt.Sprintf("-I %s", filepath.Dir(absProgramPath))
+	for _, define := range defines {
+		buildOpts += " -D " + define
+	}
 	errCode = cl.BuildProgram(
 		d.program,
 		1,
 		&d.Id,
-		cl.Str(fmt.Sprintf("-I %s\x00", filepath.Dir(absProgramPath))),
+		cl.Str(buildOpts+"\x00"),
 		nil,
 		nil,
 	)
@@ -166,6 +174,49 @@ func (d *Device) Close() {
 		cl.ReleaseContext(d.ctx)
 		d.ctx = nil
 	}
+
+	if d.subDevice && d.Id != nil {
+		cl.ReleaseDevice(d.Id)
+		d.Id = nil
+	}
+}
+
+// Partition the device into a sub-device that uses at most computeUnits of
+// its compute units. If the device does not have more than computeUnits
+// compute units, the device itself is returned. Partitioning must be performed
+// before the device is initialized.
+func (d *Device) Partition(computeUnits uint32) (*Device, error) {
+	if computeUnits == 0 {
+		return nil, fmt.Errorf("opencl device (%s): sub-device compute unit count must be >= 1", d.Name)
+	} else if computeUnits >= d.compUnits {
+		return d, nil
+	}
+
+	// Partition properties are intptr_t values terminated by a 0 entry
+	props := []uintptr{
+		uintptr(cl.DEVICE_PARTITION_BY_COUNTS),
+		uintptr(computeUnits),
+		uintptr(cl.DEVICE_PARTITION_BY_COUNTS_LIST_END),
+		0,
+	}
+
+	var subId cl.DeviceId
+	var numDevices uint32
+	errCode := cl.CreateSubDevices(d.Id, (*cl.DevicePartitionProperty)(unsafe.Pointer(&props[0])), 1, &subId, &numDevices)
+	if errCode != cl.SUCCESS {
+		return nil, fmt.Errorf("opencl device (%s): could not partition device into %d compute units (error: %s; code %d)", d.Name, computeUnits, ErrorName(errCode), errCode)
+	}
+
+	sub := &Device{
+		Name:       d.Name,
+		Id:         subId,
+		Type:       d.Type,
+		compUnits:  computeUnits,
+		clockSpeed: d.clockSpeed,
+		subDevice:  true,
+	}
+	sub.Speed = sub.compUnits * sub.clockSpeed / 1000
+	return sub, nil
 }
 
 // Load kernel by name.
@@ -3,6 +3,9 @@ package device
 import (
 	"strings"
 	"testing"
+	"unsafe"
+
+	"github.com/achilleasa/gopencl/v1.2/cl"
 )
 
 func TestSelectDevices(t *testing.T) {
@@ -61,3 +64,46 @@ func createCpuTestDevice() (*Device, error) {
 	}
 	return devList[0], devList[0].Init("test.cl")
 }
+
+func TestDevicePartition(t *testing.T) {
+	devList, err := SelectDevices(CpuDevice, "CPU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devList) != 1 {
+		t.Fatalf("expected to get 1 CPU opencl device; got %d; check that openCL drivers are installed", len(devList))
+	}
+
+	dev := devList[0]
+	if _, err = dev.Partition(0); err == nil {
+		t.Fatal("expected to get an error when partitioning a device into 0 compute units")
+	}
+
+	if sub, err := dev.Partition(dev.compUnits); err != nil || sub != dev {
+		t.Fatalf("expected partitioning into all compute units to return the device itself; got %v (%v)", sub, err)
+	}
+
+	if dev.compUnits < 2 {
+		t.Skip("CPU device has a single compute unit")
+	}
+
+	sub, err := dev.Partition(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	var compUnits uint32
+	errCode := cl.GetDeviceInfo(sub.Id, cl.DEVICE_MAX_COMPUTE_UNITS, 4, unsafe.Pointer(&compUnits), nil)
+	if errCode != cl.SUCCESS {
+		t.Fatalf("could not query sub-device compute units (error: %s)", ErrorName(errCode))
+	}
+	if compUnits != 1 {
+		t.Fatalf("expected sub-device to have 1 compute unit; got %d", compUnits)
+	}
+
+	err = sub.Init("test.cl")
+	if err != nil {
+		t.Fatalf("error initializing sub-device '%s': %v", sub.Name, err)
+	}
+}
@@ -0,0 +1,136 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestEmissionSide(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// The emissive quad lies on the z=-1 plane and its front face points
+	// towards +Z
+	sc, err := reader.ReadScene("fixtures/emitter.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type spec struct {
+		side    material.EmissionSide
+		camZ    float32
+		expEmit bool
+	}
+	specs := []spec{
+		spec{material.EmitFront, 2, true},
+		spec{material.EmitFront, -4, false},
+		spec{material.EmitBack, 2, false},
+		spec{material.EmitBack, -4, true},
+		spec{material.EmitBothSides, 2, true},
+		spec{material.EmitBothSides, -4, true},
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 4,
+		NumBounces:      1,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	for index, s := range specs {
+		for nodeIndex := range sc.MaterialNodeList {
+			if node := &sc.MaterialNodeList[nodeIndex]; node.Union1[0] == int32(material.BxdfEmissive) {
+				node.SetEmissionSide(s.side)
+			}
+		}
+		sc.Camera.Position = types.Vec3{0, 0, s.camZ}
+		sc.Camera.LookAt = types.Vec3{0, 0, -1}
+
+		expRadiance := types.Vec3{}
+		if s.expEmit {
+			expRadiance = types.Vec3{50, 40, 30}
+		}
+		radiance := traceTestScene(t, tr, sc, blockReq)
+		for pixel, value := range radiance {
+			if value.Sub(expRadiance).Len() > 1e-2 {
+				t.Fatalf("[spec %d] expected %s emission seen from z=%.0f to be %v at pixel %d; got %v", index, s.side, s.camZ, expRadiance, pixel, value)
+			}
+		}
+	}
+}
+
+func TestEmissionFalloff(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A diffuse floor lit by an emissive ceiling 2 units above it
+	sc, err := reader.ReadScene("fixtures/falloff.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setFalloff := func(radius float32) {
+		for nodeIndex := range sc.MaterialNodeList {
+			if node := &sc.MaterialNodeList[nodeIndex]; node.Union1[0] == int32(material.BxdfEmissive) {
+				node.SetEmissionFalloff(radius)
+			}
+		}
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 16,
+		NumBounces:      2,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	type spec struct {
+		radius float32
+		expLit bool
+	}
+	specs := []spec{
+		// Falloff is disabled by default
+		spec{0, true},
+		// The floor lies beyond the falloff radius
+		spec{1, false},
+		spec{100, true},
+	}
+
+	for index, s := range specs {
+		setFalloff(s.radius)
+
+		var sum float32
+		for _, value := range traceTestScene(t, tr, sc, blockReq) {
+			sum += value.MaxComponent()
+		}
+		if lit := sum > 0; lit != s.expLit {
+			t.Errorf("[spec %d] expected floor lit state with falloff radius %.0f to be %t; got total radiance %f", index, s.radius, s.expLit, sum)
+		}
+	}
+
+	// The falloff does not affect the visibility of the emitter to the camera
+	setFalloff(0.1)
+	sc.Camera.LookAt = types.Vec3{0, 2, 0}
+	for pixel, value := range traceTestScene(t, tr, sc, blockReq) {
+		if value.Sub(types.Vec3{5, 5, 5}).Len() > 1e-3 {
+			t.Fatalf("expected emitter seen by the camera to have radiance {5, 5, 5} at pixel %d; got %v", pixel, value)
+		}
+	}
+}
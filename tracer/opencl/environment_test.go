@@ -0,0 +1,66 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestEnvironmentYaw(t *testing.T) {
+	const frameW, frameH = 32, 32
+	const leftX, rightX = 4, frameW - 5
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/environment.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 4,
+		NumBounces:      1,
+		Exposure:        1,
+	}
+
+	white, black := types.Vec3{1, 1, 1}, types.Vec3{}
+	type spec struct {
+		yaw      float32
+		expLeft  types.Vec3
+		expRight types.Vec3
+	}
+	specs := []spec{
+		spec{0, black, black},
+		// An eighth of a turn moves a checker cell boundary to the frame
+		// center; the sign of the yaw selects the side that changes color.
+		spec{math.Pi / 4, black, white},
+		spec{-math.Pi / 4, white, black},
+		spec{math.Pi / 2, white, white},
+	}
+
+	for index, s := range specs {
+		sc.SetEnvironmentYaw(s.yaw)
+		radiance := traceTestScene(t, tr, sc, blockReq)
+
+		for _, px := range []struct {
+			x   int
+			exp types.Vec3
+		}{{leftX, s.expLeft}, {rightX, s.expRight}} {
+			got := radiance[(frameH/2)*frameW+px.x]
+			for c := 0; c < 3; c++ {
+				if math.Abs(float64(got[c]-px.exp[c])) > 1e-3 {
+					t.Errorf("[spec %d] expected pixel (%d, %d) to be %v; got %v", index, px.x, frameH/2, px.exp, got)
+					break
+				}
+			}
+		}
+	}
+}
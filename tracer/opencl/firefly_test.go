@@ -0,0 +1,135 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestFireflyFilter(t *testing.T) {
+	const frameW, frameH = 16, 12
+	const fireflyX, fireflyY = 7, 5
+	const exposure = 0.05
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A gradient with a fine checker pattern as surface detail and a single
+	// firefly pixel.
+	gradient := make([]types.Vec4, frameW*frameH)
+	for y := 0; y < frameH; y++ {
+		for x := 0; x < frameW; x++ {
+			v := 0.5 + float32(x)/frameW
+			if (x+y)%2 == 0 {
+				v *= 3
+			}
+			gradient[y*frameW+x] = types.Vec4{v, 0.8 * v, 0.5 * v, 0}
+		}
+	}
+	var maxNeighborLum float32
+	for y := fireflyY - 1; y <= fireflyY+1; y++ {
+		for x := fireflyX - 1; x <= fireflyX+1; x++ {
+			if lum := luminance(gradient[y*frameW+x]); lum > maxNeighborLum {
+				maxNeighborLum = lum
+			}
+		}
+	}
+	gradient[fireflyY*frameW+fireflyX] = types.Vec4{2000, 1500, 1000, 0}
+
+	// A firefly in an unlit neighborhood
+	black := make([]types.Vec4, frameW*frameH)
+	black[fireflyY*frameW+fireflyX] = types.Vec4{2000, 1500, 1000, 0}
+
+	type spec struct {
+		pixels         []types.Vec4
+		threshold      float32
+		radius         uint32
+		expSuppression bool
+	}
+	specs := []spec{
+		spec{gradient, 10, 1, true},
+		spec{gradient, 10, 3, true},
+		// The firefly is below the threshold
+		spec{gradient, 5000, 1, false},
+		// The neighborhood median is zero
+		spec{black, 10, 1, false},
+	}
+
+	tonemap := func(pixels []types.Vec4, threshold float32, radius uint32) []byte {
+		err := tr.resources.buffers.FrameAccumulator.WriteData(pixels, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		blockReq := &tracer.BlockRequest{
+			FrameW:           frameW,
+			FrameH:           frameH,
+			BlockW:           frameW,
+			BlockH:           frameH,
+			SamplesPerPixel:  1,
+			Exposure:         exposure,
+			FireflyThreshold: threshold,
+			FireflyRadius:    radius,
+		}
+		_, err = tr.resources.TonemapSimpleReinhard(blockReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fb := make([]byte, frameW*frameH*4)
+		err = tr.resources.buffers.FrameBuffer.ReadData(0, 0, len(fb), fb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fb
+	}
+
+	for index, s := range specs {
+		unfiltered := tonemap(s.pixels, 0, 0)
+		out := tonemap(s.pixels, s.threshold, s.radius)
+
+		fireflyOffset := 4 * (fireflyY*frameW + fireflyX)
+		for offset := 0; offset < len(out); offset += 4 {
+			if offset == fireflyOffset && s.expSuppression {
+				continue
+			}
+			for c := 0; c < 4; c++ {
+				if out[offset+c] != unfiltered[offset+c] {
+					t.Errorf("[spec %d] expected pixel (%d, %d) to be preserved; got %v, want %v", index, (offset/4)%frameW, (offset/4)/frameW, out[offset:offset+4], unfiltered[offset:offset+4])
+					break
+				}
+			}
+		}
+
+		if !s.expSuppression {
+			continue
+		}
+
+		// The suppressed pixel should keep its hue and be within the
+		// threshold of the brightest neighbor of the clean image.
+		var firefly types.Vec4
+		for c := 0; c < 3; c++ {
+			firefly[c] = inverseTonemap(out[fireflyOffset+c]) / exposure
+		}
+		if lum := luminance(firefly); lum > 1.05*s.threshold*maxNeighborLum {
+			t.Errorf("[spec %d] expected firefly luminance to be at most %f; got %f", index, s.threshold*maxNeighborLum, lum)
+		}
+		if ratio := firefly[0] / firefly[2]; ratio < 1.9 || ratio > 2.1 {
+			t.Errorf("[spec %d] expected firefly hue to be preserved; got %v", index, firefly)
+		}
+	}
+}
+
+// Calculate the luminance of an RGB color.
+func luminance(rgb types.Vec4) float32 {
+	return 0.2126*rgb[0] + 0.7152*rgb[1] + 0.0722*rgb[2]
+}
+
+// Map a tone-mapped and gamma corrected framebuffer channel value back to the
+// linear radiance that produced it.
+func inverseTonemap(v byte) float32 {
+	mapped := math.Pow(float64(v)/255.0, 2.2)
+	return float32(mapped / (1 - mapped))
+}
@@ -0,0 +1,75 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestGlobalFog(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	objColor := types.Vec3{0.8, 0.5, 0.25}
+	fogColor := types.Vec3{0.2, 0.3, 0.4}
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// An emissive plane on the z=0 plane that covers the whole frame and a
+	// background with the same color as the plane
+	sc, err := reader.ReadScene("fixtures/fog.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 4,
+		NumBounces:      1,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	// Render the center pixel with the camera looking at the plane from
+	// the given distance
+	render := func(fog scene.GlobalFog, dist float32) types.Vec3 {
+		sc.GlobalFog = fog
+		sc.Camera.Position = types.Vec3{0, 0, dist}
+		sc.Camera.LookAt = types.Vec3{0, 0, 0}
+		return traceTestScene(t, tr, sc, blockReq)[(frameH/2)*frameW+frameW/2]
+	}
+
+	// Zero density is a no-op
+	for _, dist := range []float32{2, 50} {
+		if got := render(scene.GlobalFog{Color: fogColor}, dist); !types.ApproxEqual(got, objColor, 1e-4) {
+			t.Fatalf("expected radiance without fog at distance %f to be %v; got %v", dist, objColor, got)
+		}
+	}
+
+	// The center pixel ray is almost perpendicular to the plane so its
+	// length closely matches the camera distance
+	fog := scene.GlobalFog{Density: 0.1, Color: fogColor}
+	near, far := render(fog, 2), render(fog, 50)
+	if exp := fog.Apply(objColor, 2); !types.ApproxEqual(near, exp, 1e-3) {
+		t.Fatalf("expected fogged radiance for near object to be %v; got %v", exp, near)
+	}
+	if near.Sub(objColor).Len() >= near.Sub(fogColor).Len() {
+		t.Fatalf("expected near object (%v) to be closer to its own color than the fog color", near)
+	}
+	if far.Sub(fogColor).Len() > 0.01 {
+		t.Fatalf("expected far object to fade to the fog color %v; got %v", fogColor, far)
+	}
+
+	// Rays that escape the scene are not fogged
+	sc.Camera.Position = types.Vec3{0, 0, 5}
+	sc.Camera.LookAt = types.Vec3{0, 0, 10}
+	if got := traceTestScene(t, tr, sc, blockReq)[(frameH/2)*frameW+frameW/2]; !types.ApproxEqual(got, objColor, 1e-4) {
+		t.Fatalf("expected escaped ray to return the unfogged background %v; got %v", objColor, got)
+	}
+}
@@ -0,0 +1,63 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+func TestGlossyBudget(t *testing.T) {
+	const frameW, frameH = 8, 8
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/glossy_budget.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The light is only reachable after bouncing off both mirrors
+	type spec struct {
+		maxGlossyBounces uint32
+		policy           material.GlossyBudgetPolicy
+		minRadiance      float32
+		maxRadiance      float32
+	}
+	specs := []spec{
+		// The budget is disabled by default
+		spec{0, material.GlossyBudgetTerminate, 1, math.MaxFloat32},
+		spec{2, material.GlossyBudgetTerminate, 1, math.MaxFloat32},
+		// Paths are terminated at the second mirror
+		spec{1, material.GlossyBudgetTerminate, 0, 1e-3},
+		// The second mirror is shaded as a diffuse surface that is lit by
+		// the light
+		spec{1, material.GlossyBudgetDiffuse, 1e-3, math.MaxFloat32},
+	}
+
+	for index, s := range specs {
+		blockReq := tracer.BlockRequest{
+			FrameW:             frameW,
+			FrameH:             frameH,
+			BlockW:             frameW,
+			BlockH:             frameH,
+			SamplesPerPixel:    16,
+			NumBounces:         4,
+			Exposure:           1,
+			Seed:               1,
+			MaxGlossyBounces:   s.maxGlossyBounces,
+			GlossyBudgetPolicy: s.policy,
+		}
+
+		// Camera rays through the bottom rows of the frame are reflected
+		// past the second mirror so the sampled pixel lies above the
+		// frame center.
+		got := traceTestScene(t, tr, sc, blockReq)[(frameH/2-1)*frameW+frameW/2]
+		if got[0] < s.minRadiance || got[0] > s.maxRadiance {
+			t.Errorf("[spec %d] expected radiance to be in [%f, %f]; got %v", index, s.minRadiance, s.maxRadiance, got)
+		}
+	}
+}
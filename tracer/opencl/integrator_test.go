@@ -0,0 +1,117 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestNormalsIntegrator(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	pipeline := DefaultPipeline(NoDebug)
+	pipeline.Integrator = NormalsIntegrator()
+	tr := createTestTracer(t, pipeline, frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 4,
+		NumBounces:      3,
+		Exposure:        1,
+	}
+	normals := traceTestScene(t, tr, sc, blockReq)
+
+	// The box walls face inwards and the camera looks through its open
+	// front side so the frame corners see past the box.
+	encode := func(n types.Vec3) types.Vec3 {
+		return n.Mul(0.5).Add(types.Vec3{0.5, 0.5, 0.5})
+	}
+	type spec struct {
+		x, y      int
+		expNormal types.Vec3
+	}
+	specs := []spec{
+		// back
+		spec{frameW / 2, frameH / 2, encode(types.Vec3{0, 0, 1})},
+		// left and right
+		spec{6, frameH / 2, encode(types.Vec3{1, 0, 0})},
+		spec{frameW - 7, frameH / 2, encode(types.Vec3{-1, 0, 0})},
+		// ceiling and floor
+		spec{frameW / 2, 6, encode(types.Vec3{0, -1, 0})},
+		spec{frameW / 2, frameH - 7, encode(types.Vec3{0, 1, 0})},
+		// miss
+		spec{0, 0, types.Vec3{}},
+	}
+
+	for index, s := range specs {
+		got := normals[s.y*frameW+s.x]
+		for c := 0; c < 3; c++ {
+			if math.Abs(float64(got[c]-s.expNormal[c])) > 1e-4 {
+				t.Errorf("[spec %d] expected pixel (%d, %d) to be %v; got %v", index, s.x, s.y, s.expNormal, got)
+				break
+			}
+		}
+	}
+}
+
+func TestAmbientOcclusionIntegrator(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	sc, err := reader.ReadScene("fixtures/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 4,
+		NumBounces:      3,
+		Exposure:        1,
+	}
+	backPixel := (frameH/2)*frameW + frameW/2
+
+	type spec struct {
+		maxDist    float32
+		expBackMin float32
+		expBackMax float32
+	}
+	specs := []spec{
+		// The box walls occlude most of the hemisphere of the back wall
+		// but some rays escape through the open front side
+		spec{0, 0.01, 0.99},
+		// Occluders further than maxDist are ignored
+		spec{0.01, 1, 1},
+	}
+
+	for index, s := range specs {
+		pipeline := DefaultPipeline(NoDebug)
+		pipeline.Integrator = AmbientOcclusionIntegrator(16, s.maxDist)
+		tr := createTestTracer(t, pipeline, frameW, frameH)
+		ao := traceTestScene(t, tr, sc, blockReq)
+		tr.Close()
+
+		if got := ao[backPixel]; got[0] < s.expBackMin || got[0] > s.expBackMax || got[0] != got[1] || got[0] != got[2] {
+			t.Errorf("[spec %d] expected back wall ambient occlusion to be in [%f, %f]; got %v", index, s.expBackMin, s.expBackMax, got)
+		}
+
+		// Rays that miss the scene are unoccluded
+		if got := ao[0]; math.Abs(float64(got[0]-1)) > 1e-4 {
+			t.Errorf("[spec %d] expected ambient occlusion for missed rays to be 1; got %v", index, got)
+		}
+	}
+}
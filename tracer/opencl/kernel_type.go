@@ -17,15 +17,27 @@ const (
 	shadePrimaryRayMisses
 	shadeIndirectRayMisses
 	accumulateEmissiveSamples
+	shadeOcclusionHits
+	// aov integrator kernels
+	shadeNormals
+	shadeAmbientOcclusion
+	// photon kernels
+	emitPhotons
+	shadePhotonHits
+	gatherCausticPhotons
 	// hdr kernels
 	tonemapSimpleReinhard
 	// accumulator
 	clearAccumulator
 	aggregateAccumulator
+	captureBounceContribution
+	accumulateLayerCoverage
+	accumulateCoverage
 	// debugging
 	debugClearBuffer
 	debugRayIntersectionDepth
 	debugRayIntersectionNormals
+	debugBvhHeatmap
 	debugEmissiveSamples
 	debugThroughput
 	debugAccumulator
@@ -52,18 +64,38 @@ func (kt kernelType) String() string {
 		return "shadeIndirectRayMisses"
 	case accumulateEmissiveSamples:
 		return "accumulateEmissiveSamples"
+	case shadeOcclusionHits:
+		return "shadeOcclusionHits"
+	case shadeNormals:
+		return "shadeNormals"
+	case shadeAmbientOcclusion:
+		return "shadeAmbientOcclusion"
+	case emitPhotons:
+		return "emitPhotons"
+	case shadePhotonHits:
+		return "shadePhotonHits"
+	case gatherCausticPhotons:
+		return "gatherCausticPhotons"
 	case tonemapSimpleReinhard:
 		return "tonemapSimpleReinhard"
 	case clearAccumulator:
 		return "clearAccumulator"
 	case aggregateAccumulator:
 		return "aggregateAccumulator"
+	case captureBounceContribution:
+		return "captureBounceContribution"
+	case accumulateLayerCoverage:
+		return "accumulateLayerCoverage"
+	case accumulateCoverage:
+		return "accumulateCoverage"
 	case debugClearBuffer:
 		return "debugClearBuffer"
 	case debugRayIntersectionDepth:
 		return "debugRayIntersectionDepth"
 	case debugRayIntersectionNormals:
 		return "debugRayIntersectionNormals"
+	case debugBvhHeatmap:
+		return "debugBvhHeatmap"
 	case debugEmissiveSamples:
 		return "debugEmissiveSamples"
 	case debugThroughput:
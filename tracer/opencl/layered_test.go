@@ -0,0 +1,77 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestLayeredFurnace(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// Two layered quads inside a furnace whose background radiance is 0.5.
+	// The left quad uses a clear coat and the right quad an absorbing coat.
+	sc, err := reader.ReadScene("fixtures/layered.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bgRadiance := float32(0.5)
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 256,
+		NumBounces:      2,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+		Seed:            1,
+	}
+	radiance := traceTestScene(t, tr, sc, blockReq)
+
+	// Average the pixels close to the center of each quad where rays hit
+	// the quad at near normal incidence
+	meanRadiance := func(startX int) types.Vec3 {
+		var sum types.Vec3
+		for y := frameH/2 - 1; y <= frameH/2; y++ {
+			for x := startX; x < startX+2; x++ {
+				sum = sum.Add(radiance[y*frameW+x])
+			}
+		}
+		return sum.Mul(0.25)
+	}
+
+	var layeredNodes []int
+	for index, node := range sc.MaterialNodeList {
+		if node.Union1[0] == int32(material.OpLayered) {
+			layeredNodes = append(layeredNodes, index)
+		}
+	}
+	if len(layeredNodes) != 2 {
+		t.Fatalf("expected compiled scene to contain 2 layered nodes; got %d", len(layeredNodes))
+	}
+
+	// The coat reflects a fraction F of the light and the base reflects the
+	// remaining light tinted by the coat transmission
+	for index, startX := range []int{frameW/4 - 1, 3*frameW/4 - 1} {
+		node := sc.MaterialNodeList[layeredNodes[index]]
+		transmittance, thickness := node.Union2.Vec3(), node.Union2[3]
+		internalReflectance, baseAlbedo := node.Union3[0], node.Union3[1]
+		intIOR, extIOR := node.Union4[0], node.Union4[1]
+
+		f := material.FresnelDielectric(extIOR, intIOR, 1)
+		transmission := material.LayerTransmission(transmittance, thickness, intIOR, extIOR, internalReflectance, baseAlbedo, 1)
+		expRadiance := types.Vec3{f, f, f}.Add(transmission.Mul((1 - f) * baseAlbedo)).Mul(bgRadiance)
+
+		if got := meanRadiance(startX); got.Sub(expRadiance).Len() > 0.02 {
+			t.Errorf("[quad %d] expected mean furnace radiance %v; got %v", index, expRadiance, got)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+func TestInstancedAreaLight(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// Both scenes place the light at the same world space location; the
+	// first one uses a scaled and translated mesh instance.
+	meanRadiance := func(sceneFile string) float32 {
+		sc, err := reader.ReadScene(sceneFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		blockReq := tracer.BlockRequest{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			BlockW:          frameW,
+			BlockH:          frameH,
+			SamplesPerPixel: 64,
+			NumBounces:      1,
+			Exposure:        1,
+			Seed:            1,
+		}
+
+		var mean float32
+		radiance := traceTestScene(t, tr, sc, blockReq)
+		for _, value := range radiance {
+			mean += value[0]
+		}
+		return mean / float32(len(radiance))
+	}
+
+	instanced := meanRadiance("fixtures/light_instance.obj")
+	reference := meanRadiance("fixtures/light_instance_ref.obj")
+	if reference <= 0 {
+		t.Fatal("expected the reference scene floor to be lit")
+	}
+	if ratio := instanced / reference; ratio < 0.95 || ratio > 1.05 {
+		t.Fatalf("expected the instanced light to match the reference lighting %f; got %f", reference, instanced)
+	}
+}
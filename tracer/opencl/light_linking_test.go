@@ -0,0 +1,77 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+func TestLightLinking(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// The fixture defines the red wall, green wall and light instances
+	// in this order. Each wall only reflects its own color channel so the
+	// energy of each channel measures the light reaching each instance
+	// either via light sampling or via bxdf sampled rays hitting the light.
+	sc, err := reader.ReadScene("fixtures/light_link.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.MeshInstanceList) != 3 || len(sc.EmissivePrimitives) != 2 {
+		t.Fatalf("expected fixture to define 3 mesh instances and 2 emissives; got %d and %d", len(sc.MeshInstanceList), len(sc.EmissivePrimitives))
+	}
+
+	// A nil instance list disables light linking
+	type spec struct {
+		mode      scene.LightLinkMode
+		instances []uint32
+		expLit    [2]bool
+	}
+	specs := []spec{
+		spec{scene.LightLinkInclude, nil, [2]bool{true, true}},
+		spec{scene.LightLinkInclude, []uint32{0}, [2]bool{true, false}},
+		spec{scene.LightLinkExclude, []uint32{0}, [2]bool{false, true}},
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 8,
+		NumBounces:      3,
+		MinBouncesForRR: 4,
+		Exposure:        1,
+	}
+
+	for index, s := range specs {
+		sc.LightLinks = nil
+		if s.instances != nil {
+			for emissiveIndex := range sc.EmissivePrimitives {
+				err = sc.SetLightLink(scene.LightLink{EmissiveIndex: uint32(emissiveIndex), Mode: s.mode, Instances: s.instances})
+				if err != nil {
+					t.Fatalf("[spec %d] %v", index, err)
+				}
+			}
+		}
+
+		var energy [2]float32
+		for _, sample := range traceTestScene(t, tr, sc, blockReq) {
+			energy[0] += sample[0]
+			energy[1] += sample[1]
+		}
+
+		for instance, lit := range s.expLit {
+			if lit && energy[instance] <= 0 {
+				t.Errorf("[spec %d] expected instance %d to be lit", index, instance)
+			} else if !lit && energy[instance] != 0 {
+				t.Errorf("[spec %d] expected instance %d to receive no light; got energy %f", index, instance, energy[instance])
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestNonFiniteGuard(t *testing.T) {
+	const frameW, frameH = 8, 1
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// Each occlusion ray carries an emissive sample for a separate pixel
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(1))
+	samples := []types.Vec4{
+		{1, 1, 1, 0},
+		{nan, 0.5, 0.5, 0},
+		{0.5, 0.5, 0.5, 0},
+		{0, -inf, 0, 0},
+		{1.5, 1.5, 1.5, 0},
+	}
+	rays := make([]testRay, len(samples))
+	paths := make([]testPath, len(samples))
+	for index := range samples {
+		rays[index] = testRay{Origin: types.Vec4{0, 0, 0, 1}, Dir: types.Vec4{0, 0, -1, float32(index)}}
+		paths[index] = testPath{Throughput: types.Vec4{1, 1, 1, 0}, PixelIndex: uint32(index), LastInstance: math.MaxUint32}
+	}
+
+	type spec struct {
+		guard      tracer.NonFiniteGuard
+		expCounter uint32
+		expDropped bool
+	}
+	specs := []spec{
+		spec{tracer.CountNonFinite, 2, true},
+		spec{tracer.DropNonFinite, 0, true},
+		spec{tracer.KeepNonFinite, 0, false},
+	}
+
+	for index, s := range specs {
+		blockReq := &tracer.BlockRequest{
+			FrameW:         frameW,
+			FrameH:         frameH,
+			BlockW:         frameW,
+			BlockH:         frameH,
+			NonFiniteGuard: s.guard,
+		}
+
+		_, err := tr.resources.ClearTraceAccumulator(blockReq)
+		if err == nil {
+			err = tr.resources.ResetNonFiniteCounter()
+		}
+		if err == nil {
+			err = tr.resources.buffers.Rays[2].WriteData(rays, 0)
+		}
+		if err == nil {
+			err = tr.resources.buffers.RayCounters[2].WriteData([]int32{int32(len(rays))}, 0)
+		}
+		if err == nil {
+			err = tr.resources.buffers.Paths.WriteData(paths, 0)
+		}
+		if err == nil {
+			err = tr.resources.buffers.HitFlags.WriteData(make([]uint32, len(rays)), 0)
+		}
+		if err == nil {
+			err = tr.resources.buffers.EmissiveSamples.WriteData(samples, 0)
+		}
+		if err == nil {
+			_, err = tr.resources.AccumulateEmissiveSamples(blockReq, 2, len(rays))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counter, err := tr.resources.ReadNonFiniteCounter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter != s.expCounter {
+			t.Errorf("[spec %d] expected non-finite counter to be %d; got %d", index, s.expCounter, counter)
+		}
+
+		accumulator := make([]types.Vec4, len(samples))
+		err = tr.resources.buffers.TraceAccumulator.ReadData(0, 0, len(samples)*sizeofAccumulatorSample, accumulator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pixel, sample := range samples {
+			exp := sample.Vec3()
+			if s.expDropped && !isFinite(exp) {
+				exp = types.Vec3{}
+			}
+
+			got := accumulator[pixel].Vec3()
+			if isFinite(got) != isFinite(exp) || (isFinite(exp) && got != exp) {
+				t.Errorf("[spec %d] expected pixel %d to be %v; got %v", index, pixel, exp, got)
+			}
+		}
+	}
+}
+
+// Check whether all components of a vector are finite.
+func isFinite(v types.Vec3) bool {
+	for _, c := range v {
+		if math.IsNaN(float64(c)) || math.IsInf(float64(c), 0) {
+			return false
+		}
+	}
+	return true
+}
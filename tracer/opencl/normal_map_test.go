@@ -0,0 +1,115 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestNormalMapScale(t *testing.T) {
+	tr, traceNormal := createNormalMapTestTracer(t)
+	defer tr.Close()
+
+	unmapped := types.Vec3{0, 0, 1}
+	type spec struct {
+		scale    float32
+		minAngle float64
+		maxAngle float64
+	}
+	specs := []spec{
+		// Scale 0 disables the normal map
+		spec{0, 0, 1e-2},
+		// Scale 1 applies the mapped normal
+		spec{1, 0.5, math.Pi / 2},
+	}
+
+	angles := make([]float64, len(specs))
+	for index, s := range specs {
+		normal := traceNormal(s.scale, material.NormalMapOpenGL)
+		if math.Abs(float64(normal.Len())-1) > 1e-3 {
+			t.Fatalf("[spec %d] expected output normal to be normalized; got length %f", index, normal.Len())
+		}
+
+		angles[index] = math.Acos(math.Min(1, float64(normal.Dot(unmapped))))
+		if angles[index] < s.minAngle || angles[index] > s.maxAngle {
+			t.Fatalf("[spec %d] expected angle to unmapped normal to be in [%f, %f]; got %f", index, s.minAngle, s.maxAngle, angles[index])
+		}
+	}
+
+	// Scales > 1 should exaggerate the normal map
+	if angle := math.Acos(math.Min(1, float64(traceNormal(2, material.NormalMapOpenGL).Dot(unmapped)))); angle <= angles[1] {
+		t.Fatalf("expected scale 2 to tilt the normal more than scale 1; got %f <= %f", angle, angles[1])
+	}
+}
+
+func TestNormalMapConvention(t *testing.T) {
+	tr, traceNormal := createNormalMapTestTracer(t)
+	defer tr.Close()
+
+	gl := traceNormal(1, material.NormalMapOpenGL)
+	dx := traceNormal(1, material.NormalMapDirectX)
+
+	// Flipping the green channel inverts the perturbation along the
+	// bitangent. As both tangent vectors lie on the XY plane, the normal
+	// component and the length of the tangent plane projection should not
+	// change.
+	if gl.Sub(dx).Len() < 0.1 {
+		t.Fatalf("expected the directx convention to change the mapped normal; got %v (opengl) and %v (directx)", gl, dx)
+	}
+	if math.Abs(float64(gl[2]-dx[2])) > 1e-3 {
+		t.Fatalf("expected normal components to match; got %f (opengl) and %f (directx)", gl[2], dx[2])
+	}
+	glLen := math.Hypot(float64(gl[0]), float64(gl[1]))
+	dxLen := math.Hypot(float64(dx[0]), float64(dx[1]))
+	if math.Abs(glLen-dxLen) > 1e-3 {
+		t.Fatalf("expected tangent plane components to have the same length; got %f (opengl) and %f (directx)", glLen, dxLen)
+	}
+}
+
+// Create a tracer for rendering the normal map fixture with the normals
+// integrator. The returned function sets the normal map scale and convention
+// of the fixture material, renders the scene and returns the decoded shading
+// normal at the frame center.
+func createNormalMapTestTracer(t *testing.T) (*Tracer, func(float32, material.NormalMapConvention) types.Vec3) {
+	const frameW, frameH = 8, 8
+
+	pipeline := DefaultPipeline(NoDebug)
+	pipeline.Integrator = NormalsIntegrator()
+	tr := createTestTracer(t, pipeline, frameW, frameH)
+
+	sc, err := reader.ReadScene("fixtures/normal_map.obj")
+	if err != nil {
+		tr.Close()
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 1,
+		NumBounces:      1,
+		Exposure:        1,
+	}
+
+	traceNormal := func(scale float32, convention material.NormalMapConvention) types.Vec3 {
+		for index := range sc.MaterialNodeList {
+			node := &sc.MaterialNodeList[index]
+			if node.Union1[0] == int32(material.OpNormalMap) {
+				node.SetNormalMapConvention(convention)
+			} else {
+				node.SetNormalScale(scale)
+			}
+		}
+
+		encoded := traceTestScene(t, tr, sc, blockReq)[(frameH/2)*frameW+frameW/2]
+		return encoded.Mul(2).Sub(types.Vec3{1, 1, 1})
+	}
+
+	return tr, traceNormal
+}
@@ -0,0 +1,67 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestOpacity(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A red emissive quad that fills the camera view in front of a blue
+	// background
+	sc, err := reader.ReadScene("fixtures/opacity.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bgColor := types.Vec3{0, 0, 0.5}
+
+	type spec struct {
+		opacity float32
+	}
+	specs := []spec{
+		spec{1},
+		spec{0.5},
+		spec{0.2},
+		spec{0},
+	}
+
+	// Rays passing through the quad are traced as a second bounce
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 64,
+		NumBounces:      2,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	for index, s := range specs {
+		if err = sc.SetOpacity(sc.MaterialIndex[0], s.opacity); err != nil {
+			t.Fatalf("[spec %d] unexpected error: %v", index, err)
+		}
+
+		// The frame should converge to a blend of the quad and the
+		// background weighted by the quad opacity
+		var mean types.Vec3
+		radiance := traceTestScene(t, tr, sc, blockReq)
+		for _, value := range radiance {
+			mean = mean.Add(value)
+		}
+		mean = mean.Mul(1.0 / float32(len(radiance)))
+
+		expRadiance := types.Vec3{s.opacity, 0, 0}.Add(bgColor.Mul(1 - s.opacity))
+		if mean.Sub(expRadiance).Len() > 0.02 {
+			t.Errorf("[spec %d] expected mean radiance %v; got %v", index, expRadiance, mean)
+		}
+	}
+}
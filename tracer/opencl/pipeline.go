@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"image"
 	"image/png"
-	"math/rand"
+	"math"
 	"os"
 	"time"
 	"unsafe"
 
 	"github.com/achilleasa/polaris/tracer"
 	"github.com/achilleasa/polaris/tracer/opencl/device"
+	"github.com/achilleasa/polaris/types"
 	"github.com/go-gl/gl/v2.1/gl"
 )
 
@@ -27,6 +28,7 @@ const (
 	Throughput
 	Accumulator
 	FrameBuffer
+	PrimaryRayBvhHeatmap
 )
 
 // An alias for functions that can be used as part of the rendering pipeline.
@@ -50,6 +52,11 @@ type Pipeline struct {
 	// A set of post-processing stages that are executed prior to
 	// rendering the final frame.
 	PostProcess []PipelineStage
+
+	// A list of preprocessor symbols that are defined when building the
+	// opencl kernels. Debug stages use them to enable instrumentation
+	// that would otherwise slow down the regular kernels.
+	Defines []string
 }
 
 func DefaultPipeline(debugFlags DebugFlag) *Pipeline {
@@ -66,6 +73,10 @@ func DefaultPipeline(debugFlags DebugFlag) *Pipeline {
 		pipeline.PostProcess = append(pipeline.PostProcess, SaveFrameBuffer("debug-fb.png"))
 	}
 
+	if debugFlags&PrimaryRayBvhHeatmap == PrimaryRayBvhHeatmap {
+		pipeline.Defines = append(pipeline.Defines, "BVH_TRAVERSAL_COST")
+	}
+
 	return pipeline
 }
 
@@ -79,7 +90,7 @@ func ClearAccumulator() PipelineStage {
 // Use a perspective camera for the primary ray generation stage.
 func PerspectiveCamera() PipelineStage {
 	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
-		return tr.resources.GeneratePrimaryRays(blockReq, tr.cameraPosition, tr.cameraFrustrum)
+		return tr.resources.GeneratePrimaryRays(blockReq, tr.cameraPosition, tr.cameraFrustrum, tr.cameraLens, tr.cameraLensBasis, tr.cameraClip)
 	}
 }
 
@@ -90,25 +101,151 @@ func TonemapSimpleReinhard() PipelineStage {
 	}
 }
 
+// Apply a 3D color LUT to the tone-mapped RGBA framebuffer. This stage
+// must be placed after the tone-mapping stage and before any stage that
+// saves or displays the framebuffer.
+func ApplyFrameBufferLUT(lut *tracer.LUT3D) PipelineStage {
+	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+		start := time.Now()
+
+		fb := make([]byte, tr.resources.buffers.FrameBuffer.Size())
+		err := tr.resources.buffers.FrameBuffer.ReadData(0, 0, len(fb), fb)
+		if err != nil {
+			return 0, err
+		}
+
+		pixels := make([]types.Vec3, len(fb)/4)
+		for index := range pixels {
+			pixels[index] = types.Vec3{float32(fb[4*index]) / 255, float32(fb[4*index+1]) / 255, float32(fb[4*index+2]) / 255}
+		}
+		tracer.ApplyLUT(pixels, lut)
+		for index, pixel := range pixels {
+			for c := 0; c < 3; c++ {
+				fb[4*index+c] = uint8(math.Min(math.Max(float64(pixel[c]), 0), 1)*255 + 0.5)
+			}
+		}
+
+		return time.Since(start), tr.resources.buffers.FrameBuffer.WriteData(fb, 0)
+	}
+}
+
+// Render the shading normal at the first hit of each primary ray encoded into
+// the [0, 1] range instead of the scene radiance. Primary rays that miss the
+// scene are black.
+func NormalsIntegrator() PipelineStage {
+	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+		start := time.Now()
+		numPixels := int(blockReq.BlockW * blockReq.BlockH)
+
+		err := intersectPrimaryRays(tr, blockReq, numPixels)
+		if err == nil {
+			_, err = tr.resources.ShadeNormals(blockReq.Seed, 0, numPixels)
+		}
+		return time.Since(start), err
+	}
+}
+
+// Render the ambient occlusion at the first hit of each primary ray instead of
+// the scene radiance. The occlusion is estimated by tracing numSamples cosine
+// weighted rays over the hemisphere that faces the camera; occluders further
+// than maxDist are ignored. If maxDist is 0, all occluders contribute. Primary
+// rays that miss the scene are white.
+func AmbientOcclusionIntegrator(numSamples uint32, maxDist float32) PipelineStage {
+	if numSamples == 0 {
+		numSamples = 1
+	}
+	if maxDist <= 0 {
+		maxDist = math.MaxFloat32
+	}
+	sampleWeight := 1.0 / float32(numSamples)
+
+	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+		start := time.Now()
+		numPixels := int(blockReq.BlockW * blockReq.BlockH)
+		rng := tracer.NewRNG(blockReq.RNG, uint64(blockReq.Seed))
+
+		err := intersectPrimaryRays(tr, blockReq, numPixels)
+		if err != nil {
+			return time.Since(start), err
+		}
+
+		for sample := uint32(0); sample < numSamples; sample++ {
+			_, err = tr.resources.ShadeAmbientOcclusion(rng.Uint32(), maxDist, sampleWeight, blockReq.RayEpsilon, 0, numPixels)
+			if err == nil {
+				_, err = tr.resources.RayIntersectionTest(2, blockReq.RayBatchSize, numPixels, blockReq.CollectStats)
+			}
+			if err == nil && blockReq.CollectStats {
+				tr.stats.Rays.ShadowRays += uint64(readCounter(tr.resources, 2))
+			}
+			if err == nil {
+				_, err = tr.resources.AccumulateEmissiveSamples(blockReq, 2, numPixels)
+			}
+			if err != nil {
+				return time.Since(start), err
+			}
+		}
+		return time.Since(start), nil
+	}
+}
+
+// Intersect the primary rays in the first ray buffer and accumulate the
+// coverage of any render layers and of the transparent background.
+func intersectPrimaryRays(tr *Tracer, blockReq *tracer.BlockRequest, numPixels int) error {
+	var err error
+
+	// Use packet query intersector for GPUs as opencl forces CPU
+	// to use a local workgroup size equal to 1
+	if tr.device.Type == device.GpuDevice {
+		_, err = tr.resources.RayPacketIntersectionQuery(0, blockReq.RayBatchSize, numPixels, blockReq.CollectStats)
+	} else {
+		_, err = tr.resources.RayIntersectionQuery(0, blockReq.RayBatchSize, numPixels, blockReq.CollectStats)
+	}
+	if err != nil {
+		return err
+	}
+	if blockReq.CollectStats {
+		tr.stats.Rays.PrimaryRays += uint64(readCounter(tr.resources, 0))
+	}
+
+	if tr.resources.buffers.NumRenderLayers > 0 {
+		_, err = tr.resources.AccumulateLayerCoverage(blockReq, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if blockReq.BackgroundMode.Transparent() {
+		_, err = tr.resources.AccumulateCoverage(blockReq, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Use a montecarlo pathtracer implementation.
 func MonteCarloIntegrator(debugFlags DebugFlag) PipelineStage {
 	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
 		var err error
 
 		start := time.Now()
-		numPixels := int(blockReq.FrameW * blockReq.BlockH)
+		numPixels := int(blockReq.BlockW * blockReq.BlockH)
 		numEmissives := uint32(len(tr.sceneData.EmissivePrimitives))
+		rng := tracer.NewRNG(blockReq.RNG, uint64(blockReq.Seed))
+
+		// Gather caustics from the photon map and skip caustic paths
+		// while shading hits to avoid counting them twice.
+		var skipCausticPaths uint32 = 0
+		gatherCaustics := blockReq.CausticPhotons > 0 && tr.photonMap != nil && len(tr.photonMap.Photons) > 0
+		if gatherCaustics {
+			skipCausticPaths = 1
+		}
 
 		var activeRayBuf uint32 = 0
 
 		// Intersect primary rays outside of the loop
-		// Use packet query intersector for GPUs as opencl forces CPU
-		// to use a local workgroup size equal to 1
-		if tr.device.Type == device.GpuDevice {
-			_, err = tr.resources.RayPacketIntersectionQuery(activeRayBuf, numPixels)
-		} else {
-			_, err = tr.resources.RayIntersectionQuery(activeRayBuf, numPixels)
-		}
+		err = intersectPrimaryRays(tr, blockReq, numPixels)
 		if err != nil {
 			return time.Since(start), err
 		}
@@ -128,22 +265,49 @@ func MonteCarloIntegrator(debugFlags DebugFlag) PipelineStage {
 			}
 		}
 
+		if debugFlags&PrimaryRayBvhHeatmap == PrimaryRayBvhHeatmap {
+			_, err = tr.resources.DebugBvhHeatmap(blockReq, activeRayBuf)
+			err = dumpDebugBuffer(err, tr.resources, blockReq.FrameW, blockReq.FrameH, "debug-primary-bvh-heatmap.png")
+			if err != nil {
+				return time.Since(start), err
+			}
+		}
+
+		// Occlusion rays can only pass through transmissive surfaces so
+		// we can skip the shadow transparency passes if the scene has none
+		shadowTransparencyDepth := blockReq.ShadowTransparencyDepth
+		if !tr.resources.buffers.HasTransmissiveMaterials {
+			shadowTransparencyDepth = 0
+		}
+
 		var bounce uint32
 		for bounce = 0; bounce < blockReq.NumBounces; bounce++ {
 			// Shade misses
 			if tr.sceneData.SceneDiffuseMatIndex != -1 {
 				if bounce == 0 {
-					_, err = tr.resources.ShadePrimaryRayMisses(uint32(tr.sceneData.SceneDiffuseMatIndex), activeRayBuf, numPixels)
+					// Primary ray misses do not contribute any
+					// radiance with a transparent black background
+					if blockReq.BackgroundMode != tracer.TransparentBlackBackground {
+						_, err = tr.resources.ShadePrimaryRayMisses(blockReq, uint32(tr.sceneData.SceneDiffuseMatIndex), tr.sceneData.EnvironmentYaw, activeRayBuf, numPixels)
+					}
 				} else {
-					_, err = tr.resources.ShadeIndirectRayMisses(uint32(tr.sceneData.SceneDiffuseMatIndex), activeRayBuf, numPixels)
+					_, err = tr.resources.ShadeIndirectRayMisses(blockReq, bounce, uint32(tr.sceneData.SceneDiffuseMatIndex), tr.sceneData.EnvironmentYaw, activeRayBuf, numPixels)
 				}
 				if err != nil {
 					return time.Since(start), err
 				}
 			}
 
+			// Add caustic photon map contribution
+			if gatherCaustics {
+				_, err = tr.resources.GatherCausticPhotons(blockReq, tr.photonMap, rng.Uint32(), activeRayBuf, numPixels)
+				if err != nil {
+					return time.Since(start), err
+				}
+			}
+
 			// Shade hits
-			_, err = tr.resources.ShadeHits(bounce, blockReq.MinBouncesForRR, rand.Uint32(), numEmissives, activeRayBuf, numPixels)
+			_, err = tr.resources.ShadeHits(blockReq, bounce, rng.Uint32(), numEmissives, skipCausticPaths, activeRayBuf, numPixels)
 			if err != nil {
 				return time.Since(start), err
 			}
@@ -156,17 +320,39 @@ func MonteCarloIntegrator(debugFlags DebugFlag) PipelineStage {
 				}
 			}
 
+			// Let occlusion rays pass through transparent surfaces
+			for depth := uint32(0); depth < shadowTransparencyDepth; depth++ {
+				_, err = tr.resources.RayIntersectionQuery(2, blockReq.RayBatchSize, numPixels, blockReq.CollectStats)
+				if err == nil {
+					_, err = tr.resources.ShadeOcclusionHits(rng.Uint32(), 2, numPixels, blockReq.RayEpsilon)
+				}
+				if err != nil {
+					return time.Since(start), err
+				}
+			}
+
 			// Process intersections for occlusion rays and accumulate emissive samples for non occluded paths
-			_, err := tr.resources.RayIntersectionTest(2, numPixels)
+			_, err := tr.resources.RayIntersectionTest(2, blockReq.RayBatchSize, numPixels, blockReq.CollectStats)
 			if err != nil {
 				return time.Since(start), err
 			}
+			if blockReq.CollectStats {
+				tr.stats.Rays.ShadowRays += uint64(readCounter(tr.resources, 2))
+			}
 
-			_, err = tr.resources.AccumulateEmissiveSamples(2, numPixels)
+			_, err = tr.resources.AccumulateEmissiveSamples(blockReq, 2, numPixels)
 			if err != nil {
 				return time.Since(start), err
 			}
 
+			// Capture the radiance added by this bounce
+			if blockReq.BounceAOVDepth > 0 {
+				_, err = tr.resources.CaptureBounceContribution(blockReq, bounce)
+				if err != nil {
+					return time.Since(start), err
+				}
+			}
+
 			if debugFlags&AllEmissiveSamples == AllEmissiveSamples {
 				_, err = tr.resources.DebugEmissiveSamples(blockReq, 0, 0)
 				err = dumpDebugBuffer(err, tr.resources, blockReq.FrameW, blockReq.FrameH, fmt.Sprintf("debug-emissive-all-%03d.png", bounce))
@@ -202,10 +388,13 @@ func MonteCarloIntegrator(debugFlags DebugFlag) PipelineStage {
 			// Process intersections for indirect rays
 			if bounce+1 < blockReq.NumBounces {
 				activeRayBuf = 1 - activeRayBuf
-				_, err = tr.resources.RayIntersectionQuery(activeRayBuf, numPixels)
+				_, err = tr.resources.RayIntersectionQuery(activeRayBuf, blockReq.RayBatchSize, numPixels, blockReq.CollectStats)
 				if err != nil {
 					return time.Since(start), err
 				}
+				if blockReq.CollectStats {
+					tr.stats.Rays.BounceRays += uint64(readCounter(tr.resources, activeRayBuf))
+				}
 			}
 		}
 		return time.Since(start), nil
@@ -214,6 +403,12 @@ func MonteCarloIntegrator(debugFlags DebugFlag) PipelineStage {
 
 // Save a copy of the RGBA framebuffer.
 func SaveFrameBuffer(imgFile string) PipelineStage {
+	return SaveFrameBufferRegion(imgFile, image.Rectangle{})
+}
+
+// Save the part of the frame buffer inside region to a png file. If region
+// is empty, the entire frame buffer is saved.
+func SaveFrameBufferRegion(imgFile string, region image.Rectangle) PipelineStage {
 	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
 		start := time.Now()
 
@@ -223,13 +418,103 @@ func SaveFrameBuffer(imgFile string) PipelineStage {
 		}
 		defer f.Close()
 
-		im := image.NewRGBA(image.Rect(0, 0, int(blockReq.FrameW), int(blockReq.FrameH)))
-		err = tr.resources.buffers.FrameBuffer.ReadData(0, 0, tr.resources.buffers.FrameBuffer.Size(), im.Pix)
+		// With a transparent background, the frame buffer colors are only
+		// premultiplied by alpha if misses do not contribute any radiance
+		bounds := image.Rect(0, 0, int(blockReq.FrameW), int(blockReq.FrameH))
+		var im interface {
+			image.Image
+			SubImage(image.Rectangle) image.Image
+		}
+		var pix []uint8
+		if blockReq.BackgroundMode == tracer.TransparentBackground {
+			nrgba := image.NewNRGBA(bounds)
+			im, pix = nrgba, nrgba.Pix
+		} else {
+			rgba := image.NewRGBA(bounds)
+			im, pix = rgba, rgba.Pix
+		}
+
+		err = tr.resources.buffers.FrameBuffer.ReadData(0, 0, tr.resources.buffers.FrameBuffer.Size(), pix)
 		if err != nil {
 			return 0, err
 		}
 
-		return time.Since(start), png.Encode(f, im)
+		if region.Empty() {
+			return time.Since(start), png.Encode(f, im)
+		}
+		return time.Since(start), png.Encode(f, im.SubImage(region))
+	}
+}
+
+// Save the per-bounce radiance AOVs as tone-mapped images. The file pattern
+// should contain a %d verb that is replaced by the bounce depth.
+func SaveBounceAOVs(filePattern string) PipelineStage {
+	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+		start := time.Now()
+
+		for depth := uint32(0); depth < blockReq.BounceAOVDepth; depth++ {
+			radiance, err := tr.BounceAOV(depth, blockReq)
+			if err != nil {
+				return time.Since(start), err
+			}
+
+			im := image.NewRGBA(image.Rect(0, 0, int(blockReq.FrameW), int(blockReq.FrameH)))
+			csMat := blockReq.ColorSpace.Matrix().Mul3(blockReq.WhiteBalance.Matrix())
+			for index, sample := range radiance {
+				sample = csMat.Mul3x1(sample)
+				for c := 0; c < 3; c++ {
+					// Apply simple Reinhard tone-mapping and gamma correction
+					hdr := float64(sample[c] * blockReq.Exposure)
+					im.Pix[4*index+c] = uint8(math.Min(math.Pow(hdr/(hdr+1), 1.0/2.2), 1.0) * 255)
+				}
+				im.Pix[4*index+3] = 255
+			}
+
+			f, err := os.Create(fmt.Sprintf(filePattern, depth))
+			if err != nil {
+				return time.Since(start), err
+			}
+			err = png.Encode(f, im)
+			f.Close()
+			if err != nil {
+				return time.Since(start), err
+			}
+		}
+
+		return time.Since(start), nil
+	}
+}
+
+// Save the coverage mask of each render layer defined by the scene as a
+// grayscale image. The file pattern should contain a %s verb that is replaced
+// by the layer name.
+func SaveRenderLayerMasks(filePattern string) PipelineStage {
+	return func(tr *Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+		start := time.Now()
+
+		for layer := range tr.sceneData.RenderLayers {
+			coverage, err := tr.RenderLayerMask(uint32(layer), blockReq)
+			if err != nil {
+				return time.Since(start), err
+			}
+
+			im := image.NewGray(image.Rect(0, 0, int(blockReq.FrameW), int(blockReq.FrameH)))
+			for index, value := range coverage {
+				im.Pix[index] = uint8(math.Min(float64(value), 1.0) * 255)
+			}
+
+			f, err := os.Create(fmt.Sprintf(filePattern, tr.sceneData.RenderLayers[layer].Name))
+			if err != nil {
+				return time.Since(start), err
+			}
+			err = png.Encode(f, im)
+			f.Close()
+			if err != nil {
+				return time.Since(start), err
+			}
+		}
+
+		return time.Since(start), nil
 	}
 }
 
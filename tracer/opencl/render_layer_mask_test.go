@@ -0,0 +1,92 @@
+package opencl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl/device"
+)
+
+func TestRenderLayerMask(t *testing.T) {
+	devList, err := device.SelectDevices(device.CpuDevice, "CPU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devList) != 1 {
+		t.Fatalf("expected to get 1 CPU opencl device; got %d; check that openCL drivers are installed", len(devList))
+	}
+
+	sc, err := reader.ReadScene("fixtures/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Camera.SetupProjection(1)
+	if err = sc.SetRenderLayer("box", []uint32{0}); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := NewTracer("test", devList[0], nil, DefaultPipeline(NoDebug))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	const frameW, frameH = 32, 32
+	tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{frameW, frameH})
+	tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+	tr.UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 16,
+		NumBounces:      1,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+	}
+
+	// Trace modifies the block request so we need to pass a copy
+	traceReq := blockReq
+	_, err = tr.Trace(context.Background(), &traceReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.MergeOutput(tr, &blockReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coverage, err := tr.(*Tracer).RenderLayerMask(0, &blockReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage) != frameW*frameH {
+		t.Fatalf("expected to get %d coverage samples; got %d", frameW*frameH, len(coverage))
+	}
+
+	var covered int
+	for index, value := range coverage {
+		if value < 0 || value > 1 {
+			t.Fatalf("expected coverage for pixel %d to be in [0, 1]; got %f", index, value)
+		}
+		if value > 0 {
+			covered++
+		}
+	}
+	if covered == 0 {
+		t.Fatal("expected the box instance to cover some pixels")
+	}
+
+	// Requesting a layer that is not defined by the scene should fail
+	if _, err = tr.(*Tracer).RenderLayerMask(1, &blockReq); err == nil {
+		t.Fatal("expected to get an error when requesting an undefined render layer")
+	}
+}
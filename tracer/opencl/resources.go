@@ -5,6 +5,8 @@ import (
 	"math"
 	"time"
 
+	"github.com/achilleasa/gopencl/v1.2/cl"
+	"github.com/achilleasa/polaris/asset/scene"
 	"github.com/achilleasa/polaris/tracer"
 	"github.com/achilleasa/polaris/tracer/opencl/device"
 	"github.com/achilleasa/polaris/types"
@@ -40,6 +42,20 @@ func newDeviceResources(dev *device.Device) (*deviceResources, error) {
 		buffers: newBufferSet(dev),
 	}
 
+	// Upload sobol direction numbers
+	err = dr.buffers.SobolDirections.AllocateAndWriteData(tracer.SobolDirections(), cl.MEM_READ_ONLY)
+	if err != nil {
+		dr.Close()
+		return nil, err
+	}
+
+	// Upload the Poisson-disk sub-pixel pattern
+	err = dr.buffers.PoissonPattern.AllocateAndWriteData(tracer.PoissonDiskPattern(tracer.PoissonPatternSeed), cl.MEM_READ_ONLY)
+	if err != nil {
+		dr.Close()
+		return nil, err
+	}
+
 	// Load all kernels
 	dr.kernels = make([]*device.Kernel, numKernels)
 
@@ -103,6 +119,231 @@ func (dr *deviceResources) ClearTraceAccumulator(blockReq *tracer.BlockRequest)
 	return kernel.Exec1D(0, int(blockReq.FrameW*blockReq.FrameH), 0)
 }
 
+// Clear the per-bounce trace accumulator and the accumulator snapshot.
+func (dr *deviceResources) ClearTraceBounceAccumulator(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[clearAccumulator]
+	numPixels := int(blockReq.FrameW * blockReq.FrameH)
+
+	err := kernel.SetArgs(
+		dr.buffers.BounceSnapshot,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	elapsed, err := kernel.Exec1D(0, numPixels, 0)
+	if err != nil {
+		return elapsed, err
+	}
+
+	err = kernel.SetArgs(
+		dr.buffers.TraceBounceAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels*int(blockReq.BounceAOVDepth), 0)
+}
+
+// Clear the per-bounce frame accumulator.
+func (dr *deviceResources) ClearFrameBounceAccumulator(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[clearAccumulator]
+	err := kernel.SetArgs(
+		dr.buffers.FrameBounceAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, int(blockReq.FrameW*blockReq.FrameH*blockReq.BounceAOVDepth), 0)
+}
+
+// Add the trace accumulator contributions of the current bounce to the
+// per-bounce trace accumulator. Contributions of bounces that exceed the
+// requested AOV depth are added to the last layer.
+func (dr *deviceResources) CaptureBounceContribution(blockReq *tracer.BlockRequest, bounce uint32) (time.Duration, error) {
+	kernel := dr.kernels[captureBounceContribution]
+	numPixels := blockReq.FrameW * blockReq.FrameH
+	if bounce >= blockReq.BounceAOVDepth {
+		bounce = blockReq.BounceAOVDepth - 1
+	}
+
+	err := kernel.SetArgs(
+		dr.buffers.TraceAccumulator,
+		dr.buffers.BounceSnapshot,
+		dr.buffers.TraceBounceAccumulator,
+		bounce*numPixels,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, int(numPixels), 0)
+}
+
+// Aggregate the per-bounce trace accumulator contents from another tracer
+// into this tracer's per-bounce frame accumulator.
+func (dr *deviceResources) AggregateBounceAccumulator(srcAccumulator *device.Buffer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[aggregateAccumulator]
+	err := kernel.SetArgs(
+		srcAccumulator,
+		dr.buffers.FrameBounceAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Add the rows of the block specified by blockReq for each layer
+	var total time.Duration
+	for depth := uint32(0); depth < blockReq.BounceAOVDepth; depth++ {
+		elapsed, err := kernel.Exec1DNoWait(
+			int(blockReq.FrameW*(depth*blockReq.FrameH+blockReq.BlockY)),
+			int(blockReq.FrameW*blockReq.BlockH),
+			0,
+		)
+		total += elapsed
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Clear the per-layer trace coverage accumulator.
+func (dr *deviceResources) ClearTraceLayerAccumulator(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	return dr.clearLayerAccumulator(dr.buffers.TraceLayerAccumulator, blockReq)
+}
+
+// Clear the per-layer frame coverage accumulator.
+func (dr *deviceResources) ClearFrameLayerAccumulator(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	return dr.clearLayerAccumulator(dr.buffers.FrameLayerAccumulator, blockReq)
+}
+
+func (dr *deviceResources) clearLayerAccumulator(accumulator *device.Buffer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[clearAccumulator]
+	err := kernel.SetArgs(
+		accumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, int(blockReq.FrameW*blockReq.FrameH*dr.buffers.NumRenderLayers), 0)
+}
+
+// Add the primary ray hits to the per-layer trace coverage accumulator.
+func (dr *deviceResources) AccumulateLayerCoverage(blockReq *tracer.BlockRequest, activeRayBuf uint32) (time.Duration, error) {
+	kernel := dr.kernels[accumulateLayerCoverage]
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
+
+	err := kernel.SetArgs(
+		dr.buffers.RayCounters[activeRayBuf],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.InstanceLayers,
+		dr.buffers.TraceLayerAccumulator,
+		dr.buffers.NumRenderLayers,
+		blockReq.FrameW*blockReq.FrameH,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
+// Aggregate the per-layer trace coverage accumulator contents from another
+// tracer into this tracer's per-layer frame coverage accumulator.
+func (dr *deviceResources) AggregateLayerAccumulator(srcAccumulator *device.Buffer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[aggregateAccumulator]
+	err := kernel.SetArgs(
+		srcAccumulator,
+		dr.buffers.FrameLayerAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Add the rows of the block specified by blockReq for each layer
+	var total time.Duration
+	for layer := uint32(0); layer < dr.buffers.NumRenderLayers; layer++ {
+		elapsed, err := kernel.Exec1DNoWait(
+			int(blockReq.FrameW*(layer*blockReq.FrameH+blockReq.BlockY)),
+			int(blockReq.FrameW*blockReq.BlockH),
+			0,
+		)
+		total += elapsed
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Clear the primary ray coverage trace accumulator.
+func (dr *deviceResources) ClearTraceCoverageAccumulator(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	return dr.clearCoverageAccumulator(dr.buffers.TraceCoverageAccumulator, blockReq)
+}
+
+// Clear the primary ray coverage frame accumulator.
+func (dr *deviceResources) ClearFrameCoverageAccumulator(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	return dr.clearCoverageAccumulator(dr.buffers.FrameCoverageAccumulator, blockReq)
+}
+
+func (dr *deviceResources) clearCoverageAccumulator(accumulator *device.Buffer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[clearAccumulator]
+	err := kernel.SetArgs(
+		accumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, int(blockReq.FrameW*blockReq.FrameH), 0)
+}
+
+// Add the primary ray hits to the coverage trace accumulator.
+func (dr *deviceResources) AccumulateCoverage(blockReq *tracer.BlockRequest, activeRayBuf uint32) (time.Duration, error) {
+	kernel := dr.kernels[accumulateCoverage]
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
+
+	err := kernel.SetArgs(
+		dr.buffers.RayCounters[activeRayBuf],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.TraceCoverageAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
+// Aggregate the coverage trace accumulator contents from another tracer into
+// this tracer's coverage frame accumulator.
+func (dr *deviceResources) AggregateCoverageAccumulator(srcAccumulator *device.Buffer, blockReq *tracer.BlockRequest) (time.Duration, error) {
+	kernel := dr.kernels[aggregateAccumulator]
+	err := kernel.SetArgs(
+		srcAccumulator,
+		dr.buffers.FrameCoverageAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1DNoWait(
+		int(blockReq.FrameW*blockReq.BlockY),
+		int(blockReq.FrameW*blockReq.BlockH),
+		0,
+	)
+}
+
 // Aggregate the trace accumulator contents from another tracer into
 // this tracer's frame accumulator.
 func (dr *deviceResources) AggregateAccumulator(srcAccumulator *device.Buffer, blockReq *tracer.BlockRequest) (time.Duration, error) {
@@ -115,16 +356,43 @@ func (dr *deviceResources) AggregateAccumulator(srcAccumulator *device.Buffer, b
 		return 0, err
 	}
 
-	// Add the contents of block specified by blockReq
+	// Add the rows of the block specified by blockReq. Pixels outside
+	// the block columns are zero in the trace accumulator.
 	return kernel.Exec1DNoWait(
 		int(blockReq.FrameW*blockReq.BlockY),
-		int(blockReq.BlockW*blockReq.BlockH),
+		int(blockReq.FrameW*blockReq.BlockH),
 		0,
 	)
 }
 
+// Reset the counter of dropped non-finite samples.
+func (dr *deviceResources) ResetNonFiniteCounter() error {
+	return dr.buffers.NonFiniteCounter.WriteData(counterResetPattern, 0)
+}
+
+// Read the number of non-finite samples that were dropped since the last
+// counter reset.
+func (dr *deviceResources) ReadNonFiniteCounter() (uint32, error) {
+	out := make([]uint32, 1)
+	err := dr.buffers.NonFiniteCounter.ReadData(0, 0, 4, out)
+	return out[0], err
+}
+
+// Reset the BVH traversal counters.
+func (dr *deviceResources) ResetTraversalStats() error {
+	return dr.buffers.TraversalStats.WriteData([]uint32{0, 0}, 0)
+}
+
+// Read the number of visited BVH nodes and triangle tests since the last
+// traversal counter reset.
+func (dr *deviceResources) ReadTraversalStats() (nodeVisits, triangleTests uint32, err error) {
+	out := make([]uint32, 2)
+	err = dr.buffers.TraversalStats.ReadData(0, 0, 8, out)
+	return out[0], out[1], err
+}
+
 // Generate primary rays.
-func (dr *deviceResources) GeneratePrimaryRays(blockReq *tracer.BlockRequest, cameraEyePos types.Vec3, cameraFrustrum [4]types.Vec4) (time.Duration, error) {
+func (dr *deviceResources) GeneratePrimaryRays(blockReq *tracer.BlockRequest, cameraEyePos types.Vec3, cameraFrustrum [4]types.Vec4, lens scene.CameraLens, lensBasis [3]types.Vec3, clipPlanes types.Vec2) (time.Duration, error) {
 	kernel := dr.kernels[generatePrimaryRays]
 
 	texelDims := types.Vec2{
@@ -141,25 +409,40 @@ func (dr *deviceResources) GeneratePrimaryRays(blockReq *tracer.BlockRequest, ca
 		cameraFrustrum[2],
 		cameraFrustrum[3],
 		cameraEyePos,
+		lens.ApertureRadius,
+		lens.FocusDistance,
+		lens.ApertureBlades,
+		lens.ApertureRotation,
+		lensBasis[0],
+		lensBasis[1],
+		lensBasis[2],
+		clipPlanes,
 		texelDims,
+		blockReq.BlockX,
 		blockReq.BlockY,
+		blockReq.BlockW,
 		blockReq.BlockH,
 		blockReq.FrameW,
 		blockReq.FrameH,
 		blockReq.Seed,
+		uint32(blockReq.Sampler),
+		blockReq.AccumulatedSamples,
+		dr.buffers.SobolDirections,
+		uint32(blockReq.SubpixelPattern),
+		dr.buffers.PoissonPattern,
 	)
 	if err != nil {
 		return 0, err
 	}
 
-	return kernel.Exec2D(0, 0, int(blockReq.FrameW), int(blockReq.BlockH), 0, 0)
+	return kernel.Exec2D(0, 0, int(blockReq.BlockW), int(blockReq.BlockH), 0, 0)
 }
 
 // Test for ray intersection. This method will update the hit buffer to indicate
 // whether each ray intersects with the scene geometry or not. This method is
 // much faster than an intersection query as it terminates on the first found
 // intersection and does not evaulate intersection data.
-func (dr *deviceResources) RayIntersectionTest(rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) RayIntersectionTest(rayBufferIndex, batchSize uint32, numPixels int, collectStats bool) (time.Duration, error) {
 	kernel := dr.kernels[rayIntersectionTest]
 
 	err := kernel.SetArgs(
@@ -169,17 +452,19 @@ func (dr *deviceResources) RayIntersectionTest(rayBufferIndex uint32, numPixels
 		dr.buffers.MeshInstances,
 		dr.buffers.Vertices,
 		dr.buffers.HitFlags,
+		boolFlag(collectStats),
+		dr.buffers.TraversalStats,
 	)
 	if err != nil {
 		return 0, err
 	}
 
-	return kernel.Exec1D(0, numPixels, 0)
+	return execBatched(kernel, batchSize, numPixels, 0)
 }
 
 // Calculate ray intersections and fill out the hit buffer and the intersection
 // buffer with intersection data for the closest ray/triangle intersection.
-func (dr *deviceResources) RayIntersectionQuery(rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) RayIntersectionQuery(rayBufferIndex, batchSize uint32, numPixels int, collectStats bool) (time.Duration, error) {
 	kernel := dr.kernels[rayIntersectionQuery]
 
 	err := kernel.SetArgs(
@@ -190,18 +475,21 @@ func (dr *deviceResources) RayIntersectionQuery(rayBufferIndex uint32, numPixels
 		dr.buffers.Vertices,
 		dr.buffers.HitFlags,
 		dr.buffers.Intersections,
+		boolFlag(collectStats),
+		dr.buffers.TraversalStats,
+		dr.buffers.TraversalCost,
 	)
 	if err != nil {
 		return 0, err
 	}
 
-	return kernel.Exec1D(0, numPixels, 0)
+	return execBatched(kernel, batchSize, numPixels, 0)
 }
 
 // Calculate ray intersections and fill out the hit buffer and the intersection
 // buffer with intersection data for the closest ray/triangle intersection.
 // This kernel works with ray packets and should only be used for primary rays.
-func (dr *deviceResources) RayPacketIntersectionQuery(rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) RayPacketIntersectionQuery(rayBufferIndex, batchSize uint32, numPixels int, collectStats bool) (time.Duration, error) {
 	kernel := dr.kernels[rayPacketIntersectionQuery]
 
 	err := kernel.SetArgs(
@@ -212,18 +500,21 @@ func (dr *deviceResources) RayPacketIntersectionQuery(rayBufferIndex uint32, num
 		dr.buffers.Vertices,
 		dr.buffers.HitFlags,
 		dr.buffers.Intersections,
+		boolFlag(collectStats),
+		dr.buffers.TraversalStats,
+		dr.buffers.TraversalCost,
 	)
 	if err != nil {
 		return 0, err
 	}
 
-	return kernel.Exec1D(0, numPixels, 32)
+	return execBatched(kernel, batchSize, numPixels, 32)
 }
 
 // Evaluate shading for intersections. For each intersection, this kernel may
 // generate an occlusion ray and a emissive sample as well as an indirect
 // ray to be used for future bounces.
-func (dr *deviceResources) ShadeHits(bounce, minBouncesForRR, randSeed, numEmissives, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) ShadeHits(blockReq *tracer.BlockRequest, bounce, randSeed, numEmissives, skipCausticPaths, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
 	kernel := dr.kernels[shadeHits]
 
 	// Clear indirect ray counters
@@ -246,15 +537,43 @@ func (dr *deviceResources) ShadeHits(bounce, minBouncesForRR, randSeed, numEmiss
 		dr.buffers.Vertices,
 		dr.buffers.Normals,
 		dr.buffers.UV,
+		dr.buffers.UVTransforms,
+		optionalBufferFlag(dr.buffers.UVTransforms),
+		dr.buffers.VertexColors,
+		optionalBufferFlag(dr.buffers.VertexColors),
 		dr.buffers.MaterialIndices,
 		dr.buffers.MaterialNodes,
+		dr.buffers.MaterialOpacities,
+		optionalBufferFlag(dr.buffers.MaterialOpacities),
 		dr.buffers.EmissivePrimitives,
 		numEmissives,
+		dr.buffers.LightLinks,
+		dr.buffers.LightLinkStride,
+		dr.buffers.LightLinkEmitters,
+		dr.buffers.NumLightLinkEmitters,
+		dr.buffers.EnvAliasTable,
+		dr.buffers.EnvAliasDims[0],
+		dr.buffers.EnvAliasDims[1],
+		dr.buffers.GroundProjection,
+		dr.buffers.GlobalFog,
 		dr.buffers.TextureMetadata,
 		dr.buffers.Textures,
 		bounce,
-		minBouncesForRR,
+		blockReq.MinBouncesForRR,
 		randSeed,
+		uint32(blockReq.Sampler),
+		blockReq.AccumulatedSamples,
+		dr.buffers.SobolDirections,
+		blockReq.SobolDimensionBudget(),
+		skipCausticPaths,
+		// Emissive hits are reached after bounce+1 path segments and
+		// light samples after bounce+2 segments
+		blockReq.RadianceClamp(bounce+1),
+		blockReq.RadianceClamp(bounce+2),
+		blockReq.RoughnessClamp,
+		blockReq.MaxGlossyBounces,
+		uint32(blockReq.GlossyBudgetPolicy),
+		blockReq.RayEpsilon,
 		// Occlusion rays and emissive samples
 		dr.buffers.Rays[2], // occlusion rays always go to last ray buf
 		dr.buffers.RayCounters[2],
@@ -264,6 +583,8 @@ func (dr *deviceResources) ShadeHits(bounce, minBouncesForRR, randSeed, numEmiss
 		dr.buffers.RayCounters[1-rayBufferIndex],
 		//
 		dr.buffers.TraceAccumulator,
+		uint32(blockReq.NonFiniteGuard),
+		dr.buffers.NonFiniteCounter,
 	)
 	if err != nil {
 		return 0, err
@@ -275,7 +596,7 @@ func (dr *deviceResources) ShadeHits(bounce, minBouncesForRR, randSeed, numEmiss
 // Shade primary ray misses by sampling the scene background. This kernel samples
 // the background color or envmap using the ray direction and sets the
 // accumulator to the sampled value.
-func (dr *deviceResources) ShadePrimaryRayMisses(diffuseMatNodeIndex, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) ShadePrimaryRayMisses(blockReq *tracer.BlockRequest, diffuseMatNodeIndex uint32, envYaw float32, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
 	kernel := dr.kernels[shadePrimaryRayMisses]
 
 	err := kernel.SetArgs(
@@ -285,9 +606,14 @@ func (dr *deviceResources) ShadePrimaryRayMisses(diffuseMatNodeIndex, rayBufferI
 		dr.buffers.HitFlags,
 		dr.buffers.MaterialNodes,
 		diffuseMatNodeIndex,
+		envYaw,
+		dr.buffers.GroundProjection,
 		dr.buffers.TextureMetadata,
 		dr.buffers.Textures,
+		blockReq.RadianceClamp(1),
 		dr.buffers.TraceAccumulator,
+		uint32(blockReq.NonFiniteGuard),
+		dr.buffers.NonFiniteCounter,
 	)
 	if err != nil {
 		return 0, err
@@ -299,7 +625,7 @@ func (dr *deviceResources) ShadePrimaryRayMisses(diffuseMatNodeIndex, rayBufferI
 // Shade indirect ray misses by sampling the scene background. The main difference
 // with ShadePrimaryRayMisses is that this kernel multiplies the path throughput
 // with the bg sample and adds that to the accumulator.
-func (dr *deviceResources) ShadeIndirectRayMisses(diffuseMatNodeIndex, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) ShadeIndirectRayMisses(blockReq *tracer.BlockRequest, bounce, diffuseMatNodeIndex uint32, envYaw float32, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
 	kernel := dr.kernels[shadeIndirectRayMisses]
 
 	err := kernel.SetArgs(
@@ -309,9 +635,17 @@ func (dr *deviceResources) ShadeIndirectRayMisses(diffuseMatNodeIndex, rayBuffer
 		dr.buffers.HitFlags,
 		dr.buffers.MaterialNodes,
 		diffuseMatNodeIndex,
+		envYaw,
+		dr.buffers.GroundProjection,
 		dr.buffers.TextureMetadata,
 		dr.buffers.Textures,
+		blockReq.RadianceClamp(bounce+1),
+		dr.buffers.LightLinks,
+		dr.buffers.LightLinkStride,
+		dr.buffers.EnvEmissiveIndex,
 		dr.buffers.TraceAccumulator,
+		uint32(blockReq.NonFiniteGuard),
+		dr.buffers.NonFiniteCounter,
 	)
 	if err != nil {
 		return 0, err
@@ -322,7 +656,7 @@ func (dr *deviceResources) ShadeIndirectRayMisses(diffuseMatNodeIndex, rayBuffer
 
 // Accumulate emissive samples for which no occlusion has been detected
 // between the surface and the emissive primitive.
-func (dr *deviceResources) AccumulateEmissiveSamples(rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+func (dr *deviceResources) AccumulateEmissiveSamples(blockReq *tracer.BlockRequest, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
 	kernel := dr.kernels[accumulateEmissiveSamples]
 
 	err := kernel.SetArgs(
@@ -332,6 +666,233 @@ func (dr *deviceResources) AccumulateEmissiveSamples(rayBufferIndex uint32, numP
 		dr.buffers.HitFlags,
 		dr.buffers.EmissiveSamples,
 		dr.buffers.TraceAccumulator,
+		uint32(blockReq.NonFiniteGuard),
+		dr.buffers.NonFiniteCounter,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
+// Attenuate the emissive samples of occlusion rays that hit a transparent
+// surface by the surface transmittance and advance the rays past the hit
+// point. Occlusion rays that hit an opaque surface are collapsed so they
+// register no further hits. The intersection buffer must contain the
+// intersections for the occlusion rays.
+func (dr *deviceResources) ShadeOcclusionHits(randSeed, rayBufferIndex uint32, numPixels int, rayEpsilon float32) (time.Duration, error) {
+	kernel := dr.kernels[shadeOcclusionHits]
+
+	err := kernel.SetArgs(
+		dr.buffers.Rays[rayBufferIndex],
+		dr.buffers.RayCounters[rayBufferIndex],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.Vertices,
+		dr.buffers.Normals,
+		dr.buffers.UV,
+		dr.buffers.UVTransforms,
+		optionalBufferFlag(dr.buffers.UVTransforms),
+		dr.buffers.MaterialIndices,
+		dr.buffers.MaterialNodes,
+		dr.buffers.MaterialOpacities,
+		optionalBufferFlag(dr.buffers.MaterialOpacities),
+		dr.buffers.TextureMetadata,
+		dr.buffers.Textures,
+		randSeed,
+		rayEpsilon,
+		dr.buffers.EmissiveSamples,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
+// Accumulate the shading normal at the first hit of each ray encoded into the
+// [0, 1] range.
+func (dr *deviceResources) ShadeNormals(randSeed, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+	kernel := dr.kernels[shadeNormals]
+
+	err := kernel.SetArgs(
+		dr.buffers.Rays[rayBufferIndex],
+		dr.buffers.RayCounters[rayBufferIndex],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.Vertices,
+		dr.buffers.Normals,
+		dr.buffers.UV,
+		dr.buffers.UVTransforms,
+		optionalBufferFlag(dr.buffers.UVTransforms),
+		dr.buffers.MaterialIndices,
+		dr.buffers.MaterialNodes,
+		dr.buffers.TextureMetadata,
+		dr.buffers.Textures,
+		randSeed,
+		dr.buffers.TraceAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
+// Emit a cosine weighted ambient occlusion ray for the first hit of each ray
+// into the occlusion ray buffer. Rays that miss the scene add sampleWeight to
+// the accumulator while unoccluded ambient occlusion rays add sampleWeight via
+// AccumulateEmissiveSamples.
+func (dr *deviceResources) ShadeAmbientOcclusion(randSeed uint32, maxDist, sampleWeight, rayEpsilon float32, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+	kernel := dr.kernels[shadeAmbientOcclusion]
+
+	// Clear occlusion ray counter
+	err := dr.buffers.RayCounters[2].WriteData(counterResetPattern, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	err = kernel.SetArgs(
+		dr.buffers.Rays[rayBufferIndex],
+		dr.buffers.RayCounters[rayBufferIndex],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.Vertices,
+		dr.buffers.Normals,
+		dr.buffers.UV,
+		dr.buffers.MaterialIndices,
+		randSeed,
+		maxDist,
+		sampleWeight,
+		rayEpsilon,
+		dr.buffers.Rays[2],
+		dr.buffers.RayCounters[2],
+		dr.buffers.EmissiveSamples,
+		dr.buffers.TraceAccumulator,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
+// Emit a batch of photons from the scene area lights. The photon rays are
+// stored in the first ray buffer. The power of each photon is normalized
+// using the total number of photons that will be emitted for the photon map.
+func (dr *deviceResources) EmitPhotons(randSeed, numEmissives, numPhotons, totalPhotons uint32, rayEpsilon float32) (time.Duration, error) {
+	kernel := dr.kernels[emitPhotons]
+
+	err := dr.buffers.RayCounters[0].WriteData([]int32{int32(numPhotons)}, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	err = kernel.SetArgs(
+		dr.buffers.Rays[0],
+		dr.buffers.Paths,
+		dr.buffers.Vertices,
+		dr.buffers.Normals,
+		dr.buffers.UV,
+		dr.buffers.MaterialNodes,
+		dr.buffers.EmissivePrimitives,
+		numEmissives,
+		dr.buffers.TextureMetadata,
+		dr.buffers.Textures,
+		randSeed,
+		numPhotons,
+		totalPhotons,
+		rayEpsilon,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, int(numPhotons), 0)
+}
+
+// Shade photon ray hits. Photons that bounce off singular surfaces generate
+// indirect rays while caustic photons that hit a diffuse surface are stored
+// in the photon buffer.
+func (dr *deviceResources) ShadePhotonHits(randSeed, maxPhotons, rayBufferIndex uint32, numRays int, rayEpsilon float32) (time.Duration, error) {
+	kernel := dr.kernels[shadePhotonHits]
+
+	// Clear indirect ray counter
+	err := dr.buffers.RayCounters[1-rayBufferIndex].WriteData(counterResetPattern, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	err = kernel.SetArgs(
+		dr.buffers.Rays[rayBufferIndex],
+		dr.buffers.RayCounters[rayBufferIndex],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.Vertices,
+		dr.buffers.Normals,
+		dr.buffers.UV,
+		dr.buffers.UVTransforms,
+		optionalBufferFlag(dr.buffers.UVTransforms),
+		dr.buffers.MaterialIndices,
+		dr.buffers.MaterialNodes,
+		dr.buffers.TextureMetadata,
+		dr.buffers.Textures,
+		randSeed,
+		rayEpsilon,
+		// Indirect rays
+		dr.buffers.Rays[1-rayBufferIndex],
+		dr.buffers.RayCounters[1-rayBufferIndex],
+		// Stored photons
+		dr.buffers.Photons,
+		dr.buffers.PhotonCounter,
+		maxPhotons,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numRays, 0)
+}
+
+// Add the caustic photon map contribution for paths whose first non-singular
+// hit lands on a diffuse surface.
+func (dr *deviceResources) GatherCausticPhotons(blockReq *tracer.BlockRequest, photonMap *tracer.PhotonMap, randSeed, rayBufferIndex uint32, numPixels int) (time.Duration, error) {
+	kernel := dr.kernels[gatherCausticPhotons]
+
+	err := kernel.SetArgs(
+		dr.buffers.Rays[rayBufferIndex],
+		dr.buffers.RayCounters[rayBufferIndex],
+		dr.buffers.Paths,
+		dr.buffers.HitFlags,
+		dr.buffers.Intersections,
+		dr.buffers.Vertices,
+		dr.buffers.Normals,
+		dr.buffers.UV,
+		dr.buffers.UVTransforms,
+		optionalBufferFlag(dr.buffers.UVTransforms),
+		dr.buffers.MaterialIndices,
+		dr.buffers.MaterialNodes,
+		dr.buffers.TextureMetadata,
+		dr.buffers.Textures,
+		randSeed,
+		// Photon map
+		dr.buffers.Photons,
+		dr.buffers.PhotonCellStart,
+		photonMap.GridMin,
+		photonMap.CellSize,
+		photonMap.GridDims[0],
+		photonMap.GridDims[1],
+		photonMap.GridDims[2],
+		photonMap.Radius,
+		//
+		dr.buffers.TraceAccumulator,
+		uint32(blockReq.NonFiniteGuard),
+		dr.buffers.NonFiniteCounter,
 	)
 	if err != nil {
 		return 0, err
@@ -345,12 +906,22 @@ func (dr *deviceResources) TonemapSimpleReinhard(blockReq *tracer.BlockRequest)
 	kernel := dr.kernels[tonemapSimpleReinhard]
 	numPixels := int(blockReq.FrameW * blockReq.BlockH)
 	sampleWeight := float32(1.0 / float32(blockReq.AccumulatedSamples+blockReq.SamplesPerPixel))
+	csMat := blockReq.ColorSpace.Matrix().Mul3(blockReq.WhiteBalance.Matrix())
 	err := kernel.SetArgs(
 		dr.buffers.FrameAccumulator,
 		dr.buffers.Paths,
 		dr.buffers.FrameBuffer,
 		sampleWeight,
 		blockReq.Exposure,
+		blockReq.FrameW,
+		blockReq.FrameH,
+		blockReq.FireflyThreshold,
+		blockReq.FireflyRadius,
+		types.Vec4{csMat[0], csMat[3], csMat[6], 0},
+		types.Vec4{csMat[1], csMat[4], csMat[7], 0},
+		types.Vec4{csMat[2], csMat[5], csMat[8], 0},
+		uint32(blockReq.BackgroundMode),
+		dr.buffers.FrameCoverageAccumulator,
 	)
 	if err != nil {
 		return 0, err
@@ -401,7 +972,7 @@ func (dr *deviceResources) DebugRayIntersectionDepth(blockReq *tracer.BlockReque
 	}
 
 	kernel := dr.kernels[debugRayIntersectionDepth]
-	numPixels := int(blockReq.FrameW * blockReq.BlockH)
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
 
 	err = kernel.SetArgs(
 		dr.buffers.RayCounters[activeRayBuf],
@@ -426,7 +997,7 @@ func (dr *deviceResources) DebugRayIntersectionNormals(blockReq *tracer.BlockReq
 	}
 
 	kernel := dr.kernels[debugRayIntersectionNormals]
-	numPixels := int(blockReq.FrameW * blockReq.BlockH)
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
 
 	err = kernel.SetArgs(
 		dr.buffers.Rays[activeRayBuf],
@@ -450,6 +1021,44 @@ func (dr *deviceResources) DebugRayIntersectionNormals(blockReq *tracer.BlockReq
 	return kernel.Exec1D(0, numPixels, 0)
 }
 
+// Generate a heatmap of the BVH traversal cost for the primary rays. The
+// costs are recorded by the intersection query kernels and are only
+// available if the kernels are built with the BVH_TRAVERSAL_COST symbol defined.
+func (dr *deviceResources) DebugBvhHeatmap(blockReq *tracer.BlockRequest, activeRayBuf uint32) (time.Duration, error) {
+	_, err := dr.DebugClearBuffer(blockReq)
+	if err != nil {
+		return 0, err
+	}
+
+	// Find max cost for the rays in this block so we can normalize the heatmap
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
+	costs := make([]uint32, numPixels)
+	err = dr.buffers.TraversalCost.ReadData(0, 0, numPixels*4, costs)
+	if err != nil {
+		return 0, err
+	}
+	var maxCost uint32 = 1
+	for _, cost := range costs {
+		if cost > maxCost {
+			maxCost = cost
+		}
+	}
+
+	kernel := dr.kernels[debugBvhHeatmap]
+	err = kernel.SetArgs(
+		dr.buffers.RayCounters[activeRayBuf],
+		dr.buffers.Paths,
+		dr.buffers.TraversalCost,
+		1.0/float32(maxCost),
+		dr.buffers.DebugOutput,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return kernel.Exec1D(0, numPixels, 0)
+}
+
 // Render emissiveSamples optionally masking occluded/not-occluded rays.
 func (dr *deviceResources) DebugEmissiveSamples(blockReq *tracer.BlockRequest, maskOccluded, maskNotOccluded uint32) (time.Duration, error) {
 	_, err := dr.DebugClearBuffer(blockReq)
@@ -458,7 +1067,7 @@ func (dr *deviceResources) DebugEmissiveSamples(blockReq *tracer.BlockRequest, m
 	}
 
 	kernel := dr.kernels[debugEmissiveSamples]
-	numPixels := int(blockReq.FrameW * blockReq.BlockH)
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
 
 	err = kernel.SetArgs(
 		dr.buffers.Rays[2],
@@ -485,7 +1094,7 @@ func (dr *deviceResources) DebugThroughput(blockReq *tracer.BlockRequest) (time.
 	}
 
 	kernel := dr.kernels[debugThroughput]
-	numPixels := int(blockReq.FrameW * blockReq.BlockH)
+	numPixels := int(blockReq.BlockW * blockReq.BlockH)
 
 	err = kernel.SetArgs(
 		dr.buffers.Paths,
@@ -521,3 +1130,33 @@ func (dr *deviceResources) DebugAccumulator(blockReq *tracer.BlockRequest) (time
 
 	return kernel.Exec1D(0, numPixels, 0)
 }
+
+// Execute a 1D kernel over numPixels work items split into batches of at most
+// batchSize items and return the total execution time.
+func execBatched(kernel *device.Kernel, batchSize uint32, numPixels, localWorkSize int) (time.Duration, error) {
+	var total time.Duration
+	err := tracer.ForEachRayBatch(numPixels, batchSize, func(offset, count int) error {
+		elapsed, err := kernel.Exec1D(offset, count, localWorkSize)
+		total += elapsed
+		return err
+	})
+	return total, err
+}
+
+// Convert a boolean to a kernel flag.
+func boolFlag(flag bool) uint32 {
+	if flag {
+		return 1
+	}
+	return 0
+}
+
+// Get a kernel flag indicating whether an optional scene buffer (e.g. vertex
+// colors) has been allocated. Optional buffers are not allocated for scenes
+// that do not define the associated data.
+func optionalBufferFlag(buf *device.Buffer) uint32 {
+	if buf.Handle() != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+)
+
+func TestRoughnessClamp(t *testing.T) {
+	const (
+		frameW, frameH   = 32, 32
+		floorRowStart    = 20
+		numSeeds         = 16
+		minRoughness     = 0.5
+		fireflyThreshold = 50
+	)
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/roughness_clamp.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Render the floor with a single sample per pixel so that each pixel
+	// holds a single path estimate. A smooth mirror can only be resolved
+	// by paths that happen to reflect towards the light while a
+	// regularized mirror can also be shaded using light sampling.
+	estimate := func(roughnessClamp float32) (mean float32, fireflies int) {
+		var numSamples int
+		for seed := uint32(1); seed <= numSeeds; seed++ {
+			blockReq := tracer.BlockRequest{
+				FrameW:          frameW,
+				FrameH:          frameH,
+				BlockW:          frameW,
+				BlockH:          frameH,
+				SamplesPerPixel: 1,
+				NumBounces:      3,
+				Exposure:        1,
+				Seed:            seed,
+				RoughnessClamp:  roughnessClamp,
+			}
+			radiance := traceTestScene(t, tr, sc, blockReq)
+			for _, value := range radiance[floorRowStart*frameW:] {
+				mean += value[0]
+				numSamples++
+				if value[0] > fireflyThreshold {
+					fireflies++
+				}
+			}
+		}
+		return mean / float32(numSamples), fireflies
+	}
+
+	smoothMean, smoothFireflies := estimate(0)
+	clampedMean, clampedFireflies := estimate(minRoughness)
+	if smoothFireflies == 0 || clampedFireflies*4 > smoothFireflies {
+		t.Fatalf("expected roughness clamping to reduce the firefly count; got %d (smooth) and %d (clamped)", smoothFireflies, clampedFireflies)
+	}
+	if ratio := clampedMean / smoothMean; ratio < 0.5 || ratio > 2 {
+		t.Fatalf("expected the clamped estimate %f to approximate the smooth estimate %f", clampedMean, smoothMean)
+	}
+}
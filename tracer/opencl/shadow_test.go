@@ -0,0 +1,138 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+// The device layout of a ray and a path.
+type testRay struct {
+	Origin types.Vec4
+	Dir    types.Vec4
+}
+
+type testPath struct {
+	Throughput    types.Vec4
+	PixelIndex    uint32
+	Flags         uint32
+	GlossyBounces uint32
+	LastInstance  uint32
+}
+
+func TestShadowTransparency(t *testing.T) {
+	const frameW, frameH = 4, 4
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/shadow_panes.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.HasTransmissiveMaterials() {
+		t.Fatal("expected fixture to contain transmissive materials")
+	}
+	_, err = tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rayEpsilon := sc.RayEpsilon()
+
+	// Trace a shadow ray from (paneX, 0, 2) towards -Z using the same
+	// kernel sequence as the path tracing pipeline and return the emissive
+	// sample that reaches its end.
+	traceShadowRay := func(paneX, maxDist float32, depth uint32) types.Vec3 {
+		rays := []testRay{{Origin: types.Vec4{paneX, 0, 2, maxDist}, Dir: types.Vec4{0, 0, -1, 0}}}
+		paths := []testPath{{Throughput: types.Vec4{1, 1, 1, 0}, LastInstance: math.MaxUint32}}
+		err := tr.resources.buffers.Rays[2].WriteData(rays, 0)
+		if err == nil {
+			err = tr.resources.buffers.RayCounters[2].WriteData([]int32{1}, 0)
+		}
+		if err == nil {
+			err = tr.resources.buffers.Paths.WriteData(paths, 0)
+		}
+		if err == nil {
+			err = tr.resources.buffers.EmissiveSamples.WriteData([]types.Vec4{{1, 1, 1, 0}}, 0)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for pass := uint32(0); pass < depth; pass++ {
+			_, err = tr.resources.RayIntersectionQuery(2, 0, 1, false)
+			if err == nil {
+				_, err = tr.resources.ShadeOcclusionHits(pass, 2, 1, rayEpsilon)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = tr.resources.RayIntersectionTest(2, 0, 1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hitFlags := make([]uint32, 1)
+		err = tr.resources.buffers.HitFlags.ReadData(0, 0, 4, hitFlags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hitFlags[0] != 0 {
+			return types.Vec3{}
+		}
+
+		samples := make([]types.Vec4, 1)
+		err = tr.resources.buffers.EmissiveSamples.ReadData(0, 0, sizeofEmissiveSample, samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return samples[0].Vec3()
+	}
+
+	red := types.Vec3{0.9, 0.2, 0.1}
+	const (
+		glassPane      = 0
+		roughGlassPane = 3
+		glassSlab      = 6
+		diffusePane    = 9
+		hazePane       = 12
+		tintedHazePane = 15
+	)
+
+	type spec struct {
+		paneX    float32
+		depth    uint32
+		maxDist  float32
+		expValue types.Vec3
+	}
+	specs := []spec{
+		spec{glassPane, 4, 4, red},
+		spec{roughGlassPane, 4, 4, red},
+		spec{glassSlab, 4, 4, types.Vec3{red[0] * red[0], red[1] * red[1], red[2] * red[2]}},
+		// Transparent surfaces block shadow rays once the depth is reached
+		spec{glassPane, 0, 4, types.Vec3{}},
+		spec{glassSlab, 1, 4, types.Vec3{}},
+		// Opaque surfaces always block shadow rays
+		spec{diffusePane, 4, 4, types.Vec3{}},
+		// Semi-transparent surfaces let through a 1-opacity fraction
+		spec{hazePane, 4, 4, types.Vec3{0.75, 0.75, 0.75}},
+		spec{tintedHazePane, 4, 4, types.Vec3{0.95, 0.6, 0.55}},
+		// Surfaces beyond the light do not cast shadows
+		spec{diffusePane, 4, 1, types.Vec3{1, 1, 1}},
+	}
+
+	for index, s := range specs {
+		got := traceShadowRay(s.paneX, s.maxDist, s.depth)
+		for c := 0; c < 3; c++ {
+			if math.Abs(float64(got[c]-s.expValue[c])) > 1e-4 {
+				t.Errorf("[spec %d] expected transmittance %v; got %v", index, s.expValue, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSubsurfaceBacklitSlab(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A thin slab in front of an emissive wall
+	sc, err := reader.ReadScene("fixtures/subsurface.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slab := &sc.MaterialNodeList[sc.MaterialIndex[0]]
+	if slab.Union1[0] != int32(material.BxdfSubsurface) {
+		t.Fatalf("expected slab to use a subsurface material; got bxdf type %d", slab.Union1[0])
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 256,
+		NumBounces:      4,
+		MinBouncesForRR: 4,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	// Average the pixels at the center of the frame which only see the
+	// front face of the slab
+	meanRadiance := func() types.Vec3 {
+		radiance := traceTestScene(t, tr, sc, blockReq)
+
+		var sum types.Vec3
+		for y := frameH/2 - 2; y < frameH/2+2; y++ {
+			for x := frameW/2 - 2; x < frameW/2+2; x++ {
+				sum = sum.Add(radiance[y*frameW+x])
+			}
+		}
+		return sum.Mul(1.0 / 16)
+	}
+
+	thin := meanRadiance()
+
+	// Shrinking the mean free path has the same effect as making the slab
+	// thicker
+	meanFreePath := slab.Union4[2]
+	slab.Union4[2] = meanFreePath / 20
+	thick := meanRadiance()
+
+	for c := 0; c < 3; c++ {
+		if thin[c] <= 0 {
+			t.Fatalf("expected light to bleed through a thin subsurface slab; got radiance %v", thin)
+		}
+		if thick[c] >= thin[c] {
+			t.Fatalf("expected a thick slab to transmit less light than a thin slab; got %v (thick) and %v (thin)", thick, thin)
+		}
+	}
+
+	// Channels with a higher albedo scatter further through the medium
+	if !(thin[0] > thin[1] && thin[1] > thin[2]) {
+		t.Fatalf("expected transmitted radiance to follow the albedo; got %v", thin)
+	}
+
+	// A diffuse slab blocks the backlight
+	slab.Union1[0] = int32(material.BxdfDiffuse)
+	if got := meanRadiance(); got.MaxComponent() != 0 {
+		t.Fatalf("expected a diffuse slab to block the backlight; got radiance %v", got)
+	}
+}
@@ -0,0 +1,77 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestSunPenumbra(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/sun.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sunIndex := -1
+	for index, em := range sc.EmissivePrimitives {
+		if em.Type == scene.DirectionalLight {
+			sunIndex = index
+		}
+	}
+	if sunIndex == -1 {
+		t.Fatal("expected compiled scene to contain a directional light emissive")
+	}
+
+	// Count the pixels along the center row that are partially lit. The
+	// shadow of the occluder is centered in the frame and the columns at
+	// the frame edges are skipped as they may see the occluder itself.
+	penumbraPixels := func(angularDiameter float32) int {
+		sun := &scene.SunLight{Direction: types.Vec3{1, 1, 0}, AngularDiameter: angularDiameter}
+		sc.EmissivePrimitives[sunIndex] = sun.Emissive(sc.EmissivePrimitives[sunIndex].MaterialNodeIndex)
+
+		blockReq := tracer.BlockRequest{
+			FrameW:          frameW,
+			FrameH:          frameH,
+			BlockW:          frameW,
+			BlockH:          frameH,
+			SamplesPerPixel: 64,
+			NumBounces:      1,
+			Exposure:        1,
+			Seed:            1,
+		}
+		row := traceTestScene(t, tr, sc, blockReq)[(frameH/2)*frameW : (frameH/2+1)*frameW]
+
+		var lit float32
+		for _, value := range row {
+			if value[0] > lit {
+				lit = value[0]
+			}
+		}
+		if lit <= 0 {
+			t.Fatalf("[diameter %f] expected the ground to be lit by the sun", angularDiameter)
+		}
+
+		var count int
+		for _, value := range row[6 : frameW-6] {
+			if v := value[0] / lit; v > 0.1 && v < 0.9 {
+				count++
+			}
+		}
+		return count
+	}
+
+	if hard := penumbraPixels(0); hard > 2 {
+		t.Errorf("expected zero diameter sun to cast a hard shadow; got %d partially lit pixels", hard)
+	}
+	if soft := penumbraPixels(20); soft < 6 {
+		t.Errorf("expected 20 degree sun to cast a soft shadow; got %d partially lit pixels", soft)
+	}
+}
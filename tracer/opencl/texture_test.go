@@ -0,0 +1,77 @@
+package opencl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestProceduralTextures(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	sc, err := reader.ReadScene("fixtures/procedural.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 4,
+		NumBounces:      1,
+		Exposure:        1,
+	}
+	radiance := traceTestScene(t, tr, sc, blockReq)
+	pixel := func(x, y int) types.Vec3 { return radiance[y*frameW+x] }
+
+	isColor := func(got, exp types.Vec3) bool {
+		for c := 0; c < 3; c++ {
+			if math.Abs(float64(got[c]-exp[c])) > 1e-3 {
+				return false
+			}
+		}
+		return true
+	}
+	white, black := types.Vec3{1, 1, 1}, types.Vec3{}
+
+	// The checker cell boundaries of the left quad project to column 6
+	// and row 16; the sampled pixels stay clear of them so that the
+	// reconstruction filter does not blend neighboring cells.
+	topLeft, topRight := pixel(3, 8), pixel(11, 8)
+	bottomLeft, bottomRight := pixel(3, 24), pixel(11, 24)
+	for _, color := range []types.Vec3{topLeft, topRight} {
+		if !isColor(color, white) && !isColor(color, black) {
+			t.Fatalf("expected checker cells to be either %v or %v; got %v", white, black, color)
+		}
+	}
+	if isColor(topLeft, topRight) {
+		t.Fatalf("expected adjacent checker cells to differ; got %v and %v", topLeft, topRight)
+	}
+	if !isColor(topLeft, bottomRight) || !isColor(topRight, bottomLeft) {
+		t.Fatalf("expected diagonal checker cells to match; got %v, %v, %v, %v", topLeft, topRight, bottomLeft, bottomRight)
+	}
+
+	// The noise blends between white and black so all channels match
+	var minValue, maxValue float32 = 1, 0
+	for y := 0; y < frameH; y++ {
+		for x := 18; x < frameW; x++ {
+			color := pixel(x, y)
+			if color[0] < -1e-4 || color[0] > 1+1e-4 || color[0] != color[1] || color[0] != color[2] {
+				t.Fatalf("expected noise at pixel (%d, %d) to be a gray value in [0, 1]; got %v", x, y, color)
+			}
+			minValue = float32(math.Min(float64(minValue), float64(color[0])))
+			maxValue = float32(math.Max(float64(maxValue), float64(color[0])))
+		}
+	}
+	if maxValue-minValue < 0.1 {
+		t.Fatalf("expected noise values to vary across the quad; got range [%f, %f]", minValue, maxValue)
+	}
+}
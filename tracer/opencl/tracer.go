@@ -1,8 +1,8 @@
 package opencl
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
 	"path"
 	"runtime"
 	"sync"
@@ -49,9 +49,16 @@ type Tracer struct {
 	// The uploaded optimized scene data.
 	sceneData *scene.Scene
 
+	// The caustic photon map for the uploaded scene. It is lazily built
+	// when a block request enables the caustics pass.
+	photonMap *tracer.PhotonMap
+
 	// Camera attributes
-	cameraPosition types.Vec3
-	cameraFrustrum scene.Frustrum
+	cameraPosition  types.Vec3
+	cameraFrustrum  scene.Frustrum
+	cameraLens      scene.CameraLens
+	cameraLensBasis [3]types.Vec3
+	cameraClip      types.Vec2
 }
 
 // Create a new opencl tracer.
@@ -100,7 +107,7 @@ func (tr *Tracer) Init() error {
 	// Init device
 	_, thisFile, _, _ := runtime.Caller(0)
 	pathToMainKernel := path.Join(path.Dir(thisFile), relativePathToMainKernel)
-	err = tr.device.Init(pathToMainKernel, tr.ctx)
+	err = tr.device.Init(pathToMainKernel, tr.ctx, tr.pipeline.Defines...)
 	if err != nil {
 		tr.cleanup()
 		return err
@@ -139,6 +146,7 @@ func (tr *Tracer) cleanup() {
 	}
 
 	tr.sceneData = nil
+	tr.photonMap = nil
 }
 
 // Retrieve last frame statistics.
@@ -171,12 +179,18 @@ func (tr *Tracer) commitChanges() (time.Duration, error) {
 			dims := data.([2]uint32)
 			err = tr.resources.ResizeBuffers(dims[0], dims[1])
 		case tracer.SceneData:
-			tr.sceneData = data.(*scene.Scene)
+			// The kernels can only intersect triangles
+			tr.sceneData = data.(*scene.Scene).TriangulateQuads()
+			tr.photonMap = nil
 			err = tr.resources.buffers.UploadSceneData(tr.sceneData)
 		case tracer.CameraData:
 			camera := data.(*scene.Camera)
 			tr.cameraPosition = camera.Position
 			tr.cameraFrustrum = camera.Frustrum
+			tr.cameraLens = camera.Lens
+			right, up, forward := camera.LensBasis()
+			tr.cameraLensBasis = [3]types.Vec3{right, up, forward}
+			tr.cameraClip = types.Vec2{camera.NearClip, camera.FarClip}
 		default:
 			err = fmt.Errorf("unsupported change type %d", changeType)
 		}
@@ -191,7 +205,7 @@ func (tr *Tracer) commitChanges() (time.Duration, error) {
 }
 
 // Process block request.
-func (tr *Tracer) Trace(blockReq *tracer.BlockRequest) (time.Duration, error) {
+func (tr *Tracer) Trace(ctx context.Context, blockReq *tracer.BlockRequest) (time.Duration, error) {
 	var err error
 	start := time.Now()
 
@@ -204,6 +218,19 @@ func (tr *Tracer) Trace(blockReq *tracer.BlockRequest) (time.Duration, error) {
 		return time.Since(start), ErrNoSceneData
 	}
 
+	// Derive the ray epsilon from the scene units unless explicitly set
+	if blockReq.RayEpsilon == 0 {
+		blockReq.RayEpsilon = tr.sceneData.RayEpsilon()
+	}
+
+	// Build the caustic photon map if the caustics pass is enabled
+	if blockReq.CausticPhotons > 0 && tr.photonMap == nil {
+		_, err = tr.buildPhotonMap(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
 	// If we have reset our sample counter, reset the accumulator
 	if blockReq.AccumulatedSamples == 0 && tr.pipeline.Reset != nil {
 		_, err = tr.pipeline.Reset(tr, blockReq)
@@ -217,9 +244,46 @@ func (tr *Tracer) Trace(blockReq *tracer.BlockRequest) (time.Duration, error) {
 		return time.Since(start), err
 	}
 
+	err = tr.resources.ResetNonFiniteCounter()
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	if blockReq.BounceAOVDepth > 0 {
+		_, err = tr.resetBounceAOVs(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	if tr.resources.buffers.NumRenderLayers > 0 {
+		_, err = tr.resetLayerCoverage(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	if blockReq.BackgroundMode.Transparent() {
+		_, err = tr.resetCoverage(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	tr.stats.Rays = tracer.RayStats{}
+	tr.stats.Samples = 0
+
+	// Derive per-sample seeds from the block seed so that the output
+	// is deterministic for a given seed.
+	rng := tracer.NewRNG(blockReq.RNG, uint64(blockReq.Seed))
+
 	var sample uint32
 	for sample = 0; sample < blockReq.SamplesPerPixel; sample++ {
-		blockReq.Seed = rand.Uint32()
+		if err = ctx.Err(); err != nil {
+			return time.Since(start), err
+		}
+
+		blockReq.Seed = rng.Uint32()
 
 		// Generate primary rays
 		if tr.pipeline.PrimaryRayGenerator != nil {
@@ -231,13 +295,42 @@ func (tr *Tracer) Trace(blockReq *tracer.BlockRequest) (time.Duration, error) {
 
 		// Run integrator
 		if tr.pipeline.Integrator != nil {
+			// The device traversal counters are 32-bit wide so
+			// we collect them after each sample to avoid overflows.
+			if blockReq.CollectStats {
+				err = tr.resources.ResetTraversalStats()
+				if err != nil {
+					return time.Since(start), err
+				}
+			}
+
 			_, err = tr.pipeline.Integrator(tr, blockReq)
 			if err != nil {
 				return time.Since(start), err
 			}
+
+			if blockReq.CollectStats {
+				err = tr.collectTraversalStats()
+				if err != nil {
+					return time.Since(start), err
+				}
+			}
 		}
 
 		blockReq.AccumulatedSamples++
+		tr.stats.Samples++
+	}
+
+	if blockReq.NonFiniteGuard == tracer.CountNonFinite {
+		err = tr.device.WaitForKernels()
+		if err == nil {
+			tr.stats.NonFiniteSamples, err = tr.resources.ReadNonFiniteCounter()
+		}
+		if err != nil {
+			return time.Since(start), err
+		}
+	} else {
+		tr.stats.NonFiniteSamples = 0
 	}
 
 	tr.stats.BlockW = blockReq.BlockW
@@ -246,6 +339,222 @@ func (tr *Tracer) Trace(blockReq *tracer.BlockRequest) (time.Duration, error) {
 	return tr.stats.RenderTime, nil
 }
 
+// Add the device BVH traversal counters to the tracer stats.
+func (tr *Tracer) collectTraversalStats() error {
+	err := tr.device.WaitForKernels()
+	if err != nil {
+		return err
+	}
+
+	nodeVisits, triangleTests, err := tr.resources.ReadTraversalStats()
+	if err != nil {
+		return err
+	}
+
+	tr.stats.Rays.BvhNodeVisits += uint64(nodeVisits)
+	tr.stats.Rays.TriangleTests += uint64(triangleTests)
+	return nil
+}
+
+// Allocate the per-bounce AOV buffers if required and clear the per-bounce
+// trace accumulator. The per-bounce frame accumulator is cleared whenever the
+// frame accumulator is reset or the buffers get reallocated.
+func (tr *Tracer) resetBounceAOVs(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	start := time.Now()
+
+	reallocated, err := tr.resources.buffers.AllocateBounceBuffers(blockReq.FrameW, blockReq.FrameH, blockReq.BounceAOVDepth)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	if reallocated || blockReq.AccumulatedSamples == 0 {
+		_, err = tr.resources.ClearFrameBounceAccumulator(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	_, err = tr.resources.ClearTraceBounceAccumulator(blockReq)
+	return time.Since(start), err
+}
+
+// Allocate the per-layer coverage buffers if required and clear the per-layer
+// trace accumulator. The per-layer frame accumulator is cleared whenever the
+// frame accumulator is reset or the buffers get reallocated.
+func (tr *Tracer) resetLayerCoverage(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	start := time.Now()
+
+	reallocated, err := tr.resources.buffers.AllocateLayerBuffers(blockReq.FrameW, blockReq.FrameH, tr.resources.buffers.NumRenderLayers)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	if reallocated || blockReq.AccumulatedSamples == 0 {
+		_, err = tr.resources.ClearFrameLayerAccumulator(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	_, err = tr.resources.ClearTraceLayerAccumulator(blockReq)
+	return time.Since(start), err
+}
+
+// Allocate the primary ray coverage buffers if required and clear the coverage
+// trace accumulator. The coverage frame accumulator is cleared whenever the
+// frame accumulator is reset or the buffers get reallocated.
+func (tr *Tracer) resetCoverage(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	start := time.Now()
+
+	reallocated, err := tr.resources.buffers.AllocateCoverageBuffers(blockReq.FrameW, blockReq.FrameH)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	if reallocated || blockReq.AccumulatedSamples == 0 {
+		_, err = tr.resources.ClearFrameCoverageAccumulator(blockReq)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	_, err = tr.resources.ClearTraceCoverageAccumulator(blockReq)
+	return time.Since(start), err
+}
+
+// Read back the coverage mask for the render layer with the specified index.
+// Each mask value is the fraction of the pixel's primary rays whose first hit
+// belongs to a mesh instance assigned to the layer.
+func (tr *Tracer) RenderLayerMask(layer uint32, blockReq *tracer.BlockRequest) ([]float32, error) {
+	if layer >= tr.resources.buffers.NumRenderLayers {
+		return nil, fmt.Errorf("render layer %d exceeds the number of scene render layers %d", layer, tr.resources.buffers.NumRenderLayers)
+	}
+
+	err := tr.device.WaitForKernels()
+	if err != nil {
+		return nil, err
+	}
+
+	numPixels := int(blockReq.FrameW * blockReq.FrameH)
+	samples := make([]types.Vec4, numPixels)
+	err = tr.resources.buffers.FrameLayerAccumulator.ReadData(int(layer)*numPixels*sizeofAccumulatorSample, 0, numPixels*sizeofAccumulatorSample, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	sampleWeight := float32(1.0 / float32(blockReq.AccumulatedSamples+blockReq.SamplesPerPixel))
+	coverage := make([]float32, numPixels)
+	for index, sample := range samples {
+		coverage[index] = sample[0] * sampleWeight
+	}
+
+	return coverage, nil
+}
+
+// Read back the average radiance that reaches the camera after the specified
+// number of bounces. Depth 0 corresponds to directly visible emitters and
+// direct lighting. The block request must have BounceAOVDepth > depth.
+func (tr *Tracer) BounceAOV(depth uint32, blockReq *tracer.BlockRequest) ([]types.Vec3, error) {
+	if depth >= blockReq.BounceAOVDepth {
+		return nil, fmt.Errorf("bounce AOV depth %d exceeds the captured depth %d", depth, blockReq.BounceAOVDepth)
+	}
+
+	err := tr.device.WaitForKernels()
+	if err != nil {
+		return nil, err
+	}
+
+	numPixels := int(blockReq.FrameW * blockReq.FrameH)
+	samples := make([]types.Vec4, numPixels)
+	err = tr.resources.buffers.FrameBounceAccumulator.ReadData(int(depth)*numPixels*sizeofAccumulatorSample, 0, numPixels*sizeofAccumulatorSample, samples)
+	if err != nil {
+		return nil, err
+	}
+
+	sampleWeight := float32(1.0 / float32(blockReq.AccumulatedSamples+blockReq.SamplesPerPixel))
+	radiance := make([]types.Vec3, numPixels)
+	for index, sample := range samples {
+		radiance[index] = sample.Vec3().Mul(sampleWeight)
+	}
+
+	return radiance, nil
+}
+
+// Trace photons from the scene area lights and build a photon map with the
+// photons that reach a diffuse surface after bouncing off one or more singular
+// surfaces. Photons are emitted in batches that fit in the ray buffers.
+func (tr *Tracer) buildPhotonMap(blockReq *tracer.BlockRequest) (time.Duration, error) {
+	start := time.Now()
+
+	numEmissives := uint32(len(tr.sceneData.EmissivePrimitives))
+	if numEmissives == 0 {
+		tr.photonMap = tracer.BuildPhotonMap(nil, blockReq.CausticRadius)
+		return time.Since(start), nil
+	}
+
+	err := tr.resources.buffers.AllocatePhotonBuffers(blockReq.CausticPhotons)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	rng := tracer.NewRNG(blockReq.RNG, uint64(blockReq.Seed))
+	batchSize := blockReq.FrameW * blockReq.FrameH
+	for emitted := uint32(0); emitted < blockReq.CausticPhotons; emitted += batchSize {
+		numPhotons := blockReq.CausticPhotons - emitted
+		if numPhotons > batchSize {
+			numPhotons = batchSize
+		}
+
+		_, err = tr.resources.EmitPhotons(rng.Uint32(), numEmissives, numPhotons, blockReq.CausticPhotons, blockReq.RayEpsilon)
+		if err != nil {
+			return time.Since(start), err
+		}
+
+		var activeRayBuf uint32 = 0
+		for bounce := uint32(0); bounce < blockReq.NumBounces; bounce++ {
+			_, err = tr.resources.RayIntersectionQuery(activeRayBuf, blockReq.RayBatchSize, int(numPhotons), false)
+			if err != nil {
+				return time.Since(start), err
+			}
+
+			_, err = tr.resources.ShadePhotonHits(rng.Uint32(), blockReq.CausticPhotons, activeRayBuf, int(numPhotons), blockReq.RayEpsilon)
+			if err != nil {
+				return time.Since(start), err
+			}
+			activeRayBuf = 1 - activeRayBuf
+		}
+	}
+
+	// The photon counter keeps increasing even if the photon buffer is full
+	numStored := make([]uint32, 1)
+	err = tr.resources.buffers.PhotonCounter.ReadData(0, 0, 4, numStored)
+	if err != nil {
+		return time.Since(start), err
+	}
+	if numStored[0] > blockReq.CausticPhotons {
+		numStored[0] = blockReq.CausticPhotons
+	}
+
+	photons := make([]tracer.Photon, numStored[0])
+	if len(photons) > 0 {
+		err = tr.resources.buffers.Photons.ReadData(0, 0, len(photons)*sizeofPhoton, photons)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	tr.photonMap = tracer.BuildPhotonMap(photons, blockReq.CausticRadius)
+	if len(tr.photonMap.Photons) > 0 {
+		err = tr.resources.buffers.UploadPhotonMap(tr.photonMap)
+		if err != nil {
+			return time.Since(start), err
+		}
+	}
+
+	tr.logger.Noticef("stored %d caustic photons out of %d emitted photons in %d ms", len(photons), blockReq.CausticPhotons, time.Since(start).Nanoseconds()/1e6)
+	return time.Since(start), nil
+}
+
 // Run post-process filters and update the framebuffer with the processed output.
 func (tr *Tracer) SyncFramebuffer(blockReq *tracer.BlockRequest) (time.Duration, error) {
 	var err error
@@ -275,6 +584,16 @@ func (tr *Tracer) SyncFramebuffer(blockReq *tracer.BlockRequest) (time.Duration,
 	return time.Since(start), nil
 }
 
+// Read the accumulated radiance sums from the frame accumulator.
+func (tr *Tracer) ReadAccumulator(blockReq *tracer.BlockRequest) ([]float32, error) {
+	data := make([]float32, 4*blockReq.FrameW*blockReq.FrameH)
+	err := tr.resources.buffers.FrameAccumulator.ReadData(0, 0, len(data)*4, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 // Merge accumulator output from another tracer into this tracer's buffer.
 func (tr *Tracer) MergeOutput(other tracer.Tracer, blockReq *tracer.BlockRequest) (time.Duration, error) {
 	src, isClTracer := other.(*Tracer)
@@ -282,5 +601,34 @@ func (tr *Tracer) MergeOutput(other tracer.Tracer, blockReq *tracer.BlockRequest
 		return 0, fmt.Errorf("merge failed: unsupported tracer instance")
 	}
 
-	return tr.resources.AggregateAccumulator(src.resources.buffers.TraceAccumulator, blockReq)
+	elapsed, err := tr.resources.AggregateAccumulator(src.resources.buffers.TraceAccumulator, blockReq)
+	if err != nil {
+		return elapsed, err
+	}
+
+	if blockReq.BounceAOVDepth > 0 {
+		bounceElapsed, err := tr.resources.AggregateBounceAccumulator(src.resources.buffers.TraceBounceAccumulator, blockReq)
+		elapsed += bounceElapsed
+		if err != nil {
+			return elapsed, err
+		}
+	}
+
+	if tr.resources.buffers.NumRenderLayers > 0 {
+		layerElapsed, err := tr.resources.AggregateLayerAccumulator(src.resources.buffers.TraceLayerAccumulator, blockReq)
+		elapsed += layerElapsed
+		if err != nil {
+			return elapsed, err
+		}
+	}
+
+	if blockReq.BackgroundMode.Transparent() {
+		coverageElapsed, err := tr.resources.AggregateCoverageAccumulator(src.resources.buffers.TraceCoverageAccumulator, blockReq)
+		elapsed += coverageElapsed
+		if err != nil {
+			return elapsed, err
+		}
+	}
+
+	return elapsed, nil
 }
@@ -0,0 +1,71 @@
+package opencl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/tracer/opencl/device"
+	"github.com/achilleasa/polaris/types"
+)
+
+// Create and initialize a tracer for the CPU opencl device using the supplied
+// pipeline and allocate its buffers for a frameW x frameH frame.
+func createTestTracer(t *testing.T, pipeline *Pipeline, frameW, frameH uint32) *Tracer {
+	devList, err := device.SelectDevices(device.CpuDevice, "CPU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devList) != 1 {
+		t.Fatalf("expected to get 1 CPU opencl device; got %d; check that openCL drivers are installed", len(devList))
+	}
+
+	tr, err := NewTracer("test", devList[0], nil, pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tr.UpdateState(tracer.Synchronous, tracer.FrameDimensions, [2]uint32{frameW, frameH})
+	if err != nil {
+		tr.Close()
+		t.Fatal(err)
+	}
+
+	return tr.(*Tracer)
+}
+
+// Upload a scene to the tracer, trace the block request and return the
+// average radiance of each frame pixel.
+func traceTestScene(t *testing.T, tr *Tracer, sc *scene.Scene, blockReq tracer.BlockRequest) []types.Vec3 {
+	sc.Camera.SetupProjection(float32(blockReq.FrameW) / float32(blockReq.FrameH))
+	tr.UpdateState(tracer.Synchronous, tracer.SceneData, sc)
+	tr.UpdateState(tracer.Synchronous, tracer.CameraData, sc.Camera)
+
+	// Trace modifies the block request so we need to pass a copy
+	traceReq := blockReq
+	_, err := tr.Trace(context.Background(), &traceReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.MergeOutput(tr, &blockReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := tr.ReadAccumulator(&blockReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampleWeight := 1.0 / float32(blockReq.AccumulatedSamples+blockReq.SamplesPerPixel)
+	radiance := make([]types.Vec3, len(data)/4)
+	for index := range radiance {
+		radiance[index] = types.Vec3{data[4*index], data[4*index+1], data[4*index+2]}.Mul(sampleWeight)
+	}
+	return radiance
+}
@@ -0,0 +1,49 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestRayEpsilonMillimeterScene(t *testing.T) {
+	const frameW, frameH = 32, 32
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A diffuse quad modelled in millimeters far away from the origin
+	// inside a furnace whose background radiance is 0.5
+	sc, err := reader.ReadScene("fixtures/millimeter.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.UnitsPerMeter = 1000
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 16,
+		NumBounces:      2,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+		Seed:            1,
+	}
+	radiance := traceTestScene(t, tr, sc, blockReq)
+
+	// Bounce rays that hit the quad they leave from are not shaded by the
+	// background so self-intersections darken the quad below its albedo
+	// times the background radiance
+	expRadiance := types.Vec3{0.25, 0.25, 0.25}
+	for y := frameH/2 - 4; y < frameH/2+4; y++ {
+		for x := frameW/2 - 4; x < frameW/2+4; x++ {
+			if got := radiance[y*frameW+x]; got.Sub(expRadiance).Len() > 1e-3 {
+				t.Fatalf("expected the derived ray epsilon for a millimeter scene to prevent self-intersections; got radiance %v at pixel (%d, %d)", got, x, y)
+			}
+		}
+	}
+}
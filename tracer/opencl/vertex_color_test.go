@@ -0,0 +1,58 @@
+package opencl
+
+import (
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/scene/reader"
+	"github.com/achilleasa/polaris/tracer"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestVertexColors(t *testing.T) {
+	const frameW, frameH = 16, 16
+
+	tr := createTestTracer(t, DefaultPipeline(NoDebug), frameW, frameH)
+	defer tr.Close()
+
+	// A diffuse quad that uses vertex colors inside a furnace whose
+	// background radiance is 0.5
+	sc, err := reader.ReadScene("fixtures/vertex_color.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockReq := tracer.BlockRequest{
+		FrameW:          frameW,
+		FrameH:          frameH,
+		BlockW:          frameW,
+		BlockH:          frameH,
+		SamplesPerPixel: 16,
+		NumBounces:      2,
+		MinBouncesForRR: 2,
+		Exposure:        1,
+		Seed:            1,
+	}
+
+	meanRadiance := func() types.Vec3 {
+		var mean types.Vec3
+		radiance := traceTestScene(t, tr, sc, blockReq)
+		for _, value := range radiance {
+			mean = mean.Add(value)
+		}
+		return mean.Mul(1.0 / float32(len(radiance)))
+	}
+
+	// Without vertex colors the node reflectance is used
+	if got, exp := meanRadiance(), (types.Vec3{0.4, 0.4, 0.4}); got.Sub(exp).Len() > 0.01 {
+		t.Fatalf("expected mean radiance %v; got %v", exp, got)
+	}
+
+	// With a constant vertex color the color replaces the node reflectance
+	sc.VertexColorList = make([]types.Vec4, len(sc.VertexList))
+	for i := range sc.VertexColorList {
+		sc.VertexColorList[i] = types.Vec4{0.2, 0.4, 0.6, 1}
+	}
+	if got, exp := meanRadiance(), (types.Vec3{0.1, 0.2, 0.3}); got.Sub(exp).Len() > 0.01 {
+		t.Fatalf("expected mean radiance %v; got %v", exp, got)
+	}
+}
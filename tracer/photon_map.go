@@ -0,0 +1,178 @@
+package tracer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The max number of cells in a photon map grid. If the scene bounds are
+// too large for the requested gather radius, the grid cell size is increased
+// until the grid fits within this limit.
+const maxPhotonGridCells = 1 << 22
+
+// A photon deposited on a diffuse surface after a light path has bounced
+// off one or more specular surfaces. Its memory layout matches the Photon
+// struct used by the opencl kernels.
+type Photon struct {
+	Position types.Vec3
+	_        float32
+	Power    types.Vec3
+	_        float32
+}
+
+// A photon map that uses a uniform grid for accelerating photon lookups.
+// Photons are sorted by the grid cell they belong to; the photons for cell
+// c are stored at Photons[CellStart[c]:CellStart[c+1]].
+type PhotonMap struct {
+	Photons   []Photon
+	CellStart []uint32
+
+	// Grid layout.
+	GridMin  types.Vec3
+	GridDims [3]uint32
+	CellSize float32
+
+	// The photon gather radius.
+	Radius float32
+}
+
+// Build a photon map for the supplied photon list. The radius defines the
+// max distance between a lookup point and the photons that contribute to
+// its density estimate.
+func BuildPhotonMap(photons []Photon, radius float32) *PhotonMap {
+	pm := &PhotonMap{
+		GridDims: [3]uint32{1, 1, 1},
+		CellSize: radius,
+		Radius:   radius,
+	}
+
+	if len(photons) == 0 || radius <= 0 {
+		pm.Photons = make([]Photon, 0)
+		pm.CellStart = []uint32{0, 0}
+		return pm
+	}
+
+	gridMax := photons[0].Position
+	pm.GridMin = photons[0].Position
+	for _, photon := range photons {
+		pm.GridMin = types.MinVec3(pm.GridMin, photon.Position)
+		gridMax = types.MaxVec3(gridMax, photon.Position)
+	}
+	extent := gridMax.Sub(pm.GridMin)
+
+	// Grow cells until the grid fits within the cell limit
+	for {
+		numCells := uint64(1)
+		for axis := 0; axis < 3; axis++ {
+			pm.GridDims[axis] = uint32(extent[axis]/pm.CellSize) + 1
+			numCells *= uint64(pm.GridDims[axis])
+		}
+		if numCells <= maxPhotonGridCells {
+			break
+		}
+		pm.CellSize *= 2
+	}
+
+	// Sort photons by cell using a counting sort
+	numCells := pm.GridDims[0] * pm.GridDims[1] * pm.GridDims[2]
+	pm.CellStart = make([]uint32, numCells+1)
+	cellIndices := make([]uint32, len(photons))
+	for index, photon := range photons {
+		cellIndices[index] = pm.cellIndex(pm.cellCoords(photon.Position))
+		pm.CellStart[cellIndices[index]+1]++
+	}
+	for cell := uint32(1); cell <= numCells; cell++ {
+		pm.CellStart[cell] += pm.CellStart[cell-1]
+	}
+
+	pm.Photons = make([]Photon, len(photons))
+	cellOffsets := make([]uint32, numCells)
+	copy(cellOffsets, pm.CellStart[:numCells])
+	for index, photon := range photons {
+		cell := cellIndices[index]
+		pm.Photons[cellOffsets[cell]] = photon
+		cellOffsets[cell]++
+	}
+
+	// The tracers store photons using atomic counters so their order
+	// varies between runs. Sort the photons within each cell so that
+	// density estimates always sum photon contributions in the same order.
+	for cell := uint32(0); cell < numCells; cell++ {
+		cellPhotons := pm.Photons[pm.CellStart[cell]:pm.CellStart[cell+1]]
+		if len(cellPhotons) > 1 {
+			sort.Slice(cellPhotons, func(i, j int) bool {
+				return photonLess(&cellPhotons[i], &cellPhotons[j])
+			})
+		}
+	}
+
+	return pm
+}
+
+// Compare two photons by position and then by power.
+func photonLess(a, b *Photon) bool {
+	for axis := 0; axis < 3; axis++ {
+		if a.Position[axis] != b.Position[axis] {
+			return a.Position[axis] < b.Position[axis]
+		}
+	}
+	for c := 0; c < 3; c++ {
+		if a.Power[c] != b.Power[c] {
+			return a.Power[c] < b.Power[c]
+		}
+	}
+	return false
+}
+
+// Estimate the irradiance at the given point by summing the power of all
+// photons within the gather radius and dividing by the gather disc area.
+func (pm *PhotonMap) Estimate(point types.Vec3) types.Vec3 {
+	var power types.Vec3
+	if len(pm.Photons) == 0 {
+		return power
+	}
+
+	radiusSq := pm.Radius * pm.Radius
+	minCoords := pm.cellCoords(point.Sub(types.Vec3{pm.Radius, pm.Radius, pm.Radius}))
+	maxCoords := pm.cellCoords(point.Add(types.Vec3{pm.Radius, pm.Radius, pm.Radius}))
+	for z := minCoords[2]; z <= maxCoords[2]; z++ {
+		for y := minCoords[1]; y <= maxCoords[1]; y++ {
+			for x := minCoords[0]; x <= maxCoords[0]; x++ {
+				cell := pm.cellIndex([3]uint32{x, y, z})
+				for _, photon := range pm.Photons[pm.CellStart[cell]:pm.CellStart[cell+1]] {
+					delta := photon.Position.Sub(point)
+					if delta.Dot(delta) <= radiusSq {
+						power = power.Add(photon.Power)
+					}
+				}
+			}
+		}
+	}
+
+	return power.Mul(1.0 / (math.Pi * radiusSq))
+}
+
+// Get the grid coordinates of the cell containing the given point. Points
+// outside the grid are clamped to the nearest cell.
+func (pm *PhotonMap) cellCoords(point types.Vec3) [3]uint32 {
+	var coords [3]uint32
+	for axis := 0; axis < 3; axis++ {
+		c := (point[axis] - pm.GridMin[axis]) / pm.CellSize
+		if c < 0 {
+			c = 0
+		}
+		coords[axis] = uint32(c)
+		if coords[axis] >= pm.GridDims[axis] {
+			coords[axis] = pm.GridDims[axis] - 1
+		}
+	}
+
+	return coords
+}
+
+// Get the linear index of a grid cell.
+func (pm *PhotonMap) cellIndex(coords [3]uint32) uint32 {
+	return coords[0] + pm.GridDims[0]*(coords[1]+pm.GridDims[1]*coords[2])
+}
@@ -0,0 +1,239 @@
+package tracer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/asset/material"
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestPhotonMapEstimate(t *testing.T) {
+	// Scatter photons on a regular 0.01 spaced grid over the [0, 1] x [0, 1]
+	// square of the XZ plane. Each photon carries a unit of power so the
+	// irradiance inside the square should be approximately 100 * 100.
+	photons := make([]Photon, 0)
+	for z := 0; z <= 100; z++ {
+		for x := 0; x <= 100; x++ {
+			photons = append(photons, Photon{
+				Position: types.Vec3{float32(x) * 0.01, 0, float32(z) * 0.01},
+				Power:    types.Vec3{1, 1, 1},
+			})
+		}
+	}
+
+	pm := BuildPhotonMap(photons, 0.1)
+	if len(pm.Photons) != len(photons) {
+		t.Fatalf("expected photon map to contain %d photons; got %d", len(photons), len(pm.Photons))
+	}
+	if pm.CellStart[len(pm.CellStart)-1] != uint32(len(photons)) {
+		t.Fatalf("expected last cell to end at %d; got %d", len(photons), pm.CellStart[len(pm.CellStart)-1])
+	}
+
+	type spec struct {
+		point  types.Vec3
+		expIrr float32
+	}
+	specs := []spec{
+		spec{types.Vec3{0.5, 0, 0.5}, 10000},
+		spec{types.Vec3{0.25, 0, 0.75}, 10000},
+		spec{types.Vec3{2, 0, 2}, 0},
+	}
+	for index, s := range specs {
+		irr := pm.Estimate(s.point)
+		if math.Abs(float64(irr[0]-s.expIrr)) > 0.05*float64(s.expIrr) {
+			t.Fatalf("[spec %d] expected irradiance to be approx %f; got %f", index, s.expIrr, irr[0])
+		}
+	}
+
+	// An empty photon map should not contribute anything
+	pm = BuildPhotonMap(nil, 0.1)
+	if irr := pm.Estimate(types.Vec3{}); irr != (types.Vec3{}) {
+		t.Fatalf("expected empty photon map estimate to be 0; got %v", irr)
+	}
+}
+
+func TestPhotonMapOrderIndependence(t *testing.T) {
+	rng := NewRNG(PCG32, 1)
+	photons := make([]Photon, 2000)
+	for index := range photons {
+		photons[index] = Photon{
+			Position: types.Vec3{rng.Float32(), 0, rng.Float32()},
+			Power:    types.Vec3{rng.Float32() * 1e3, rng.Float32() * 1e-3, rng.Float32()},
+		}
+	}
+	pm := BuildPhotonMap(photons, 0.2)
+
+	// Photons are stored by the tracers in arbitrary order
+	shuffled := make([]Photon, len(photons))
+	copy(shuffled, photons)
+	for index := len(shuffled) - 1; index > 0; index-- {
+		other := int(rng.Uint32() % uint32(index+1))
+		shuffled[index], shuffled[other] = shuffled[other], shuffled[index]
+	}
+	shuffledPm := BuildPhotonMap(shuffled, 0.2)
+
+	for index := 0; index < 100; index++ {
+		point := types.Vec3{rng.Float32(), 0, rng.Float32()}
+		exp, got := pm.Estimate(point), shuffledPm.Estimate(point)
+		if exp != got {
+			t.Fatalf("expected estimate at %v to be %v regardless of photon order; got %v", point, exp, got)
+		}
+	}
+}
+
+// Caustic test scene: a glass sphere with unit radius centered at the origin,
+// a small downward facing disc light above it and a diffuse plane below it.
+const (
+	causticSphereIOR     = 1.5
+	causticLightY        = 6.0
+	causticLightRadius   = 0.02
+	causticLightRadiance = 1.0
+	causticPlaneY        = -2.5
+)
+
+func TestPhotonMapCaustics(t *testing.T) {
+	const (
+		numTrials  = 8
+		numSamples = 20000
+		radius     = 0.1
+	)
+
+	// Pick a point inside the caustic spot and calculate a reference
+	// irradiance value using a large number of path traced samples.
+	causticPoint := types.Vec3{0.1, causticPlaneY, 0}
+	refIrradiance := float64(pathTraceCaustic(NewRNG(PCG32, 0), causticPoint, 1000000))
+
+	var photonEstimates, ptEstimates [numTrials]float64
+	for trial := 0; trial < numTrials; trial++ {
+		rng := NewRNG(PCG32, uint64(trial+1))
+
+		pm := BuildPhotonMap(traceCausticPhotons(rng, numSamples), radius)
+		photonEstimates[trial] = float64(pm.Estimate(causticPoint)[0])
+		ptEstimates[trial] = float64(pathTraceCaustic(rng, causticPoint, numSamples))
+
+		// The caustic should be focused below the sphere
+		if offCaustic := pm.Estimate(types.Vec3{2, causticPlaneY, 0})[0]; float64(offCaustic) > 0.1*photonEstimates[trial] {
+			t.Fatalf("[trial %d] expected irradiance outside the caustic spot to be negligible; got %f (caustic spot: %f)", trial, offCaustic, photonEstimates[trial])
+		}
+	}
+
+	photonMean, photonStdDev := meanAndStdDev(photonEstimates[:])
+	ptMean, ptStdDev := meanAndStdDev(ptEstimates[:])
+	if math.Abs(photonMean-refIrradiance) > 0.2*refIrradiance {
+		t.Fatalf("expected photon map irradiance %f to approximate reference irradiance %f", photonMean, refIrradiance)
+	}
+
+	// The photon map estimate should be much less noisy
+	photonNoise, ptNoise := photonStdDev/photonMean, ptStdDev/ptMean
+	if photonNoise > 0.25*ptNoise {
+		t.Fatalf("expected photon map relative noise (%f) to be much lower than path tracing relative noise (%f)", photonNoise, ptNoise)
+	}
+}
+
+// Emit photons from the disc light and deposit the ones that reach the plane
+// after passing through the glass sphere.
+func traceCausticPhotons(rng RNG, numPhotons int) []Photon {
+	lightArea := float32(math.Pi * causticLightRadius * causticLightRadius)
+	photonPower := causticLightRadiance * math.Pi * lightArea / float32(numPhotons)
+
+	photons := make([]Photon, 0)
+	for index := 0; index < numPhotons; index++ {
+		r := causticLightRadius * float32(math.Sqrt(float64(rng.Float32())))
+		phi := 2 * math.Pi * float64(rng.Float32())
+		origin := types.Vec3{r * float32(math.Cos(phi)), causticLightY, r * float32(math.Sin(phi))}
+		dir := cosWeightedDir(rng, types.Vec3{0, -1, 0})
+
+		origin, dir, refracted := traceGlassSphere(origin, dir)
+		if !refracted || dir[1] >= 0 {
+			continue
+		}
+
+		t := (causticPlaneY - origin[1]) / dir[1]
+		photons = append(photons, Photon{
+			Position: origin.Add(dir.Mul(t)),
+			Power:    types.Vec3{photonPower, photonPower, photonPower},
+		})
+	}
+
+	return photons
+}
+
+// Estimate the caustic irradiance at a plane point by tracing cosine-weighted
+// paths through the glass sphere towards the light.
+func pathTraceCaustic(rng RNG, point types.Vec3, numSamples int) float32 {
+	var sum float32
+	for index := 0; index < numSamples; index++ {
+		origin, dir, refracted := traceGlassSphere(point, cosWeightedDir(rng, types.Vec3{0, 1, 0}))
+		if !refracted || dir[1] <= 0 {
+			continue
+		}
+
+		t := (causticLightY - origin[1]) / dir[1]
+		hit := origin.Add(dir.Mul(t))
+		if hit[0]*hit[0]+hit[2]*hit[2] <= causticLightRadius*causticLightRadius {
+			sum += causticLightRadiance
+		}
+	}
+
+	return math.Pi * sum / float32(numSamples)
+}
+
+// Refract a ray through the glass sphere. Returns the exit point and direction
+// or false if the ray misses the sphere.
+func traceGlassSphere(origin, dir types.Vec3) (types.Vec3, types.Vec3, bool) {
+	refracted := false
+	for bounce := 0; bounce < 8; bounce++ {
+		b := origin.Dot(dir)
+		disc := b*b - (origin.Dot(origin) - 1)
+		if disc < 0 {
+			break
+		}
+
+		sqrtDisc := float32(math.Sqrt(float64(disc)))
+		t := -b - sqrtDisc
+		if t < 1e-4 {
+			t = -b + sqrtDisc
+		}
+		if t < 1e-4 {
+			break
+		}
+
+		origin = origin.Add(dir.Mul(t))
+		normal := origin.Normalize()
+		outDir, ok := material.Refract(dir.Mul(-1), normal, causticSphereIOR, 1.0)
+		if !ok {
+			// Total internal reflection
+			outDir = dir.Sub(normal.Mul(2 * dir.Dot(normal)))
+		}
+		dir = outDir.Normalize()
+		refracted = true
+
+		// Stop once the ray leaves the sphere
+		if dir.Dot(normal) > 0 {
+			break
+		}
+	}
+
+	return origin, dir, refracted
+}
+
+func cosWeightedDir(rng RNG, normal types.Vec3) types.Vec3 {
+	r := float32(math.Sqrt(float64(rng.Float32())))
+	phi := 2 * math.Pi * float64(rng.Float32())
+	x, z := r*float32(math.Cos(phi)), r*float32(math.Sin(phi))
+	y := float32(math.Sqrt(float64(1 - x*x - z*z)))
+	return types.Vec3{x, normal[1] * y, z}
+}
+
+func meanAndStdDev(values []float64) (float64, float64) {
+	var mean, variance float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,30 @@
+package tracer
+
+// Ray tracing counters collected while rendering. Collecting the counters
+// requires additional device synchronization so they are only collected if
+// enabled by the block request.
+type RayStats struct {
+	// The number of traced primary, shadow (occlusion) and bounce rays.
+	PrimaryRays uint64
+	ShadowRays  uint64
+	BounceRays  uint64
+
+	// The number of visited BVH nodes and ray/triangle intersection tests
+	// performed while tracing the above rays.
+	BvhNodeVisits uint64
+	TriangleTests uint64
+}
+
+// Get the total number of traced rays.
+func (s RayStats) TotalRays() uint64 {
+	return s.PrimaryRays + s.ShadowRays + s.BounceRays
+}
+
+// Add the counters from another RayStats instance.
+func (s *RayStats) Add(other RayStats) {
+	s.PrimaryRays += other.PrimaryRays
+	s.ShadowRays += other.ShadowRays
+	s.BounceRays += other.BounceRays
+	s.BvhNodeVisits += other.BvhNodeVisits
+	s.TriangleTests += other.TriangleTests
+}
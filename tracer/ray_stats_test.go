@@ -0,0 +1,16 @@
+package tracer
+
+import "testing"
+
+func TestRayStatsAdd(t *testing.T) {
+	stats := RayStats{PrimaryRays: 1, ShadowRays: 2, BounceRays: 3, BvhNodeVisits: 4, TriangleTests: 5}
+	stats.Add(RayStats{PrimaryRays: 10, ShadowRays: 20, BounceRays: 30, BvhNodeVisits: 40, TriangleTests: 50})
+
+	exp := RayStats{PrimaryRays: 11, ShadowRays: 22, BounceRays: 33, BvhNodeVisits: 44, TriangleTests: 55}
+	if stats != exp {
+		t.Fatalf("expected stats to be %+v; got %+v", exp, stats)
+	}
+	if got := stats.TotalRays(); got != 66 {
+		t.Fatalf("expected 66 total rays; got %d", got)
+	}
+}
@@ -0,0 +1,166 @@
+package tracer
+
+import "fmt"
+
+// The RNG interface is implemented by all pseudo-random number generators
+// that can be used by the tracers. All implementations are deterministic;
+// two generators of the same type that are seeded with the same value always
+// produce the same sequence.
+type RNG interface {
+	// Reset generator state using the specified seed.
+	Seed(seed uint64)
+
+	// Generate a random uint32 value.
+	Uint32() uint32
+
+	// Generate a random float32 value in the [0, 1) range.
+	Float32() float32
+}
+
+// The type of a random number generator.
+type RNGType uint8
+
+// Supported RNG algorithms.
+const (
+	PCG32 RNGType = iota
+	XorShift128Plus
+)
+
+// Implements Stringer.
+func (t RNGType) String() string {
+	switch t {
+	case PCG32:
+		return "pcg32"
+	case XorShift128Plus:
+		return "xorshift128+"
+	}
+
+	return "invalid"
+}
+
+// Lookup a RNG type by its name.
+func RNGTypeFromName(name string) (RNGType, error) {
+	switch name {
+	case "pcg32":
+		return PCG32, nil
+	case "xorshift128+":
+		return XorShift128Plus, nil
+	}
+
+	return 0, fmt.Errorf("unsupported rng algorithm %q; supported algorithms: pcg32, xorshift128+", name)
+}
+
+// Create a new RNG of the given type using the specified seed.
+func NewRNG(rngType RNGType, seed uint64) RNG {
+	switch rngType {
+	case XorShift128Plus:
+		return NewXorShift128Plus(seed)
+	default:
+		return NewPCG32(seed, pcg32DefaultSeq)
+	}
+}
+
+// Combine a global seed with an animation frame index so that each frame uses
+// a decorrelated random sequence that is still reproducible given the seed and
+// the frame index. Frame index 0 always maps to the unmodified seed.
+func FrameSeed(seed uint64, frameIndex uint32) uint64 {
+	if frameIndex == 0 {
+		return seed
+	}
+
+	// Scramble the frame index using a splitmix64 finalizer
+	z := seed + uint64(frameIndex)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Convert a random uint32 to a float32 in the [0, 1) range using its top 24 bits.
+func uint32ToFloat32(v uint32) float32 {
+	return float32(v>>8) * (1.0 / (1 << 24))
+}
+
+const (
+	pcg32Multiplier uint64 = 6364136223846793005
+	pcg32DefaultSeq uint64 = 0xda3e39cb94b95bdb
+)
+
+// A PCG32 (XSH-RR variant) random number generator as described
+// by M.E. O'Neill: http://www.pcg-random.org.
+type pcg32 struct {
+	state uint64
+	inc   uint64
+	seq   uint64
+}
+
+// Create a new PCG32 generator using the given initial state and sequence selector.
+func NewPCG32(initState, initSeq uint64) RNG {
+	rng := &pcg32{seq: initSeq}
+	rng.Seed(initState)
+	return rng
+}
+
+// Reset generator state using the specified seed.
+func (r *pcg32) Seed(seed uint64) {
+	r.state = 0
+	r.inc = (r.seq << 1) | 1
+	r.Uint32()
+	r.state += seed
+	r.Uint32()
+}
+
+// Generate a random uint32 value.
+func (r *pcg32) Uint32() uint32 {
+	oldState := r.state
+	r.state = oldState*pcg32Multiplier + r.inc
+	xorShifted := uint32(((oldState >> 18) ^ oldState) >> 27)
+	rot := uint32(oldState >> 59)
+	return (xorShifted >> rot) | (xorShifted << ((-rot) & 31))
+}
+
+// Generate a random float32 value in the [0, 1) range.
+func (r *pcg32) Float32() float32 {
+	return uint32ToFloat32(r.Uint32())
+}
+
+// A xorshift128+ random number generator as described by S. Vigna in
+// "Further scramblings of Marsaglia's xorshift generators".
+type xorShift128Plus struct {
+	state [2]uint64
+}
+
+// Create a new xorshift128+ generator using the specified seed.
+func NewXorShift128Plus(seed uint64) RNG {
+	rng := &xorShift128Plus{}
+	rng.Seed(seed)
+	return rng
+}
+
+// Reset generator state using the specified seed. The generator state is
+// initialized using a splitmix64 generator to ensure that it never ends
+// up being all zeroes.
+func (r *xorShift128Plus) Seed(seed uint64) {
+	for i := 0; i < 2; i++ {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		r.state[i] = z ^ (z >> 31)
+	}
+}
+
+// Generate a random uint32 value. The upper 32 bits of the generator output
+// are used as they have better statistical quality.
+func (r *xorShift128Plus) Uint32() uint32 {
+	s1 := r.state[0]
+	s0 := r.state[1]
+	r.state[0] = s0
+	s1 ^= s1 << 23
+	r.state[1] = s1 ^ s0 ^ (s1 >> 17) ^ (s0 >> 26)
+	return uint32((r.state[1] + s0) >> 32)
+}
+
+// Generate a random float32 value in the [0, 1) range.
+func (r *xorShift128Plus) Float32() float32 {
+	return uint32ToFloat32(r.Uint32())
+}
@@ -0,0 +1,75 @@
+package tracer
+
+import "testing"
+
+func TestRNGDeterminism(t *testing.T) {
+	type spec struct {
+		rng    RNG
+		expSeq []uint32
+	}
+	specs := []spec{
+		// Reference output from the pcg32-demo program (initstate=42, initseq=54)
+		spec{NewPCG32(42, 54), []uint32{0xa15c02b7, 0x7b47f409, 0xba1d3330, 0x83d2f293, 0xbfa4784b, 0xcbed606e}},
+		spec{NewRNG(PCG32, 42), []uint32{0x713066ea, 0x3c7a0d56, 0xf424216a, 0x25c89145, 0x43e7ef3e, 0x90cff60c}},
+		spec{NewRNG(XorShift128Plus, 42), []uint32{0xaf1f56fc, 0xbd496f01, 0x8c8b2271, 0x5438402a, 0x36bcfece, 0xe8231a6d}},
+	}
+
+	for index, s := range specs {
+		// Check the sequence twice to ensure that re-seeding resets the generator state
+		for pass := 0; pass < 2; pass++ {
+			for seqIndex, expVal := range s.expSeq {
+				if val := s.rng.Uint32(); val != expVal {
+					t.Fatalf("[spec %d] expected value %d of pass %d to be 0x%08x; got 0x%08x", index, seqIndex, pass, expVal, val)
+				}
+			}
+
+			s.rng.Seed(42)
+		}
+	}
+}
+
+func TestRNGFloat32Range(t *testing.T) {
+	for _, rngType := range []RNGType{PCG32, XorShift128Plus} {
+		rng := NewRNG(rngType, 1)
+		for i := 0; i < 10000; i++ {
+			if v := rng.Float32(); v < 0 || v >= 1.0 {
+				t.Fatalf("[%s] expected value to be in the [0, 1) range; got %f", rngType, v)
+			}
+		}
+	}
+}
+
+func TestRNGTypeFromName(t *testing.T) {
+	for _, rngType := range []RNGType{PCG32, XorShift128Plus} {
+		got, err := RNGTypeFromName(rngType.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != rngType {
+			t.Fatalf("expected to get rng type %s; got %s", rngType, got)
+		}
+	}
+
+	if _, err := RNGTypeFromName("foo"); err == nil {
+		t.Fatal("expected to get an error for an unsupported rng algorithm")
+	}
+}
+
+func TestFrameSeed(t *testing.T) {
+	if seed := FrameSeed(1234, 0); seed != 1234 {
+		t.Fatalf("expected frame 0 to use the unmodified seed; got %d", seed)
+	}
+
+	seen := make(map[uint64]uint32)
+	for frame := uint32(0); frame < 100; frame++ {
+		seed := FrameSeed(1234, frame)
+		if prevFrame, exists := seen[seed]; exists {
+			t.Fatalf("frames %d and %d map to the same seed %d", prevFrame, frame, seed)
+		}
+		seen[seed] = frame
+
+		if again := FrameSeed(1234, frame); again != seed {
+			t.Fatalf("[frame %d] expected seed to be reproducible; got %d and %d", frame, seed, again)
+		}
+	}
+}
@@ -13,6 +13,9 @@ type BlockScheduler interface {
 // reported speed estimate.
 type naiveScheduler struct {
 	blockAssignment []uint32
+
+	// The frame height for the cached block assignment.
+	frameH uint32
 }
 
 // Create a new naive scheduler
@@ -22,8 +25,9 @@ func NaiveScheduler() BlockScheduler {
 
 // Split frame into blocks and assign blocks bases on reported tracer speeds.
 func (sch *naiveScheduler) Schedule(tracers []Tracer, frameH uint32) []uint32 {
-	if len(sch.blockAssignment) != len(tracers) {
+	if len(sch.blockAssignment) != len(tracers) || sch.frameH != frameH {
 		sch.blockAssignment = assignBlocksBasedOnSpeed(tracers, frameH)
+		sch.frameH = frameH
 	}
 
 	return sch.blockAssignment
@@ -1,6 +1,7 @@
 package tracer
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -34,6 +35,12 @@ func TestNaiveScheduler(t *testing.T) {
 		if blockAssignment[1] != s.expRows2 {
 			t.Fatalf("[spec %d] expected tracer 1 to be assigned %d rows; got %d", index, s.expRows2, blockAssignment[1])
 		}
+
+		// Changing the frame height should trigger a new assignment
+		blockAssignment = sch.Schedule(tracers, 2*s.frameH)
+		if rows := blockAssignment[0] + blockAssignment[1]; rows != 2*s.frameH {
+			t.Fatalf("[spec %d] expected %d rows to be assigned after resizing the frame; got %d", index, 2*s.frameH, rows)
+		}
 	}
 }
 
@@ -112,12 +119,22 @@ func (mt *mockTracer) Init() error {
 func (mt *mockTracer) Close() {
 }
 
-func (mt *mockTracer) Enqueue(_ BlockRequest) {
+func (mt *mockTracer) Stats() *Stats {
+	return mt.stats
 }
 
-func (mt *mockTracer) Update(_ UpdateType, _ interface{}) {
+func (mt *mockTracer) UpdateState(_ UpdateMode, _ ChangeType, _ interface{}) (time.Duration, error) {
+	return 0, nil
 }
 
-func (mt *mockTracer) Stats() *Stats {
-	return mt.stats
+func (mt *mockTracer) Trace(_ context.Context, _ *BlockRequest) (time.Duration, error) {
+	return 0, nil
+}
+
+func (mt *mockTracer) MergeOutput(_ Tracer, _ *BlockRequest) (time.Duration, error) {
+	return 0, nil
+}
+
+func (mt *mockTracer) SyncFramebuffer(_ *BlockRequest) (time.Duration, error) {
+	return 0, nil
 }
@@ -0,0 +1,147 @@
+package tracer
+
+import "fmt"
+
+// The type of sampler used for generating the samples that drive the
+// camera and the integrator.
+type SamplerType uint8
+
+// Supported sampler types.
+const (
+	RandomSampler SamplerType = iota
+	SobolSampler
+)
+
+// Implements Stringer.
+func (t SamplerType) String() string {
+	switch t {
+	case RandomSampler:
+		return "random"
+	case SobolSampler:
+		return "sobol"
+	}
+
+	return "invalid"
+}
+
+// Lookup a sampler type by its name.
+func SamplerTypeFromName(name string) (SamplerType, error) {
+	switch name {
+	case "random":
+		return RandomSampler, nil
+	case "sobol":
+		return SobolSampler, nil
+	}
+
+	return 0, fmt.Errorf("unsupported sampler %q; supported samplers: random, sobol", name)
+}
+
+const (
+	// The max number of sobol dimensions. Samplers must fall back to
+	// pseudo-random sampling for any dimensions past this limit.
+	SobolMaxDimensions = 16
+
+	// Number of direction numbers for each sobol dimension.
+	sobolBits = 32
+
+	// Sample dimensions allocated to the camera and each path bounce.
+	// These values must match the ones defined by the opencl kernels.
+	SobolCameraDim       = 0
+	SobolBounceDimOffset = 2
+	SobolDimsPerBounce   = 6
+)
+
+// Primitive polynomial coefficients and initial direction numbers for
+// dimensions 2 to SobolMaxDimensions from the new-joe-kuo-6.21201 table:
+// http://web.maths.unsw.edu.au/~fkuo/sobol/
+var sobolPolynomials = []struct {
+	s uint32
+	a uint32
+	m []uint32
+}{
+	{1, 0, []uint32{1}},
+	{2, 1, []uint32{1, 3}},
+	{3, 1, []uint32{1, 3, 1}},
+	{3, 2, []uint32{1, 1, 1}},
+	{4, 1, []uint32{1, 1, 3, 3}},
+	{4, 4, []uint32{1, 3, 5, 13}},
+	{5, 2, []uint32{1, 1, 5, 5, 17}},
+	{5, 4, []uint32{1, 1, 5, 5, 5}},
+	{5, 7, []uint32{1, 1, 7, 11, 19}},
+	{5, 11, []uint32{1, 1, 5, 1, 1}},
+	{5, 13, []uint32{1, 1, 1, 3, 11}},
+	{5, 14, []uint32{1, 3, 5, 5, 31}},
+	{6, 1, []uint32{1, 3, 3, 9, 7, 49}},
+	{6, 13, []uint32{1, 1, 1, 15, 21, 21}},
+	{6, 16, []uint32{1, 3, 1, 13, 27, 49}},
+}
+
+// Generate the direction numbers for the first SobolMaxDimensions dimensions.
+// The returned slice contains sobolBits direction numbers for each dimension
+// and can be uploaded as-is to the device.
+func SobolDirections() []uint32 {
+	dirs := make([]uint32, SobolMaxDimensions*sobolBits)
+
+	// The first dimension is the van der Corput sequence
+	for bit := uint32(0); bit < sobolBits; bit++ {
+		dirs[bit] = 1 << (31 - bit)
+	}
+
+	for dim := 1; dim < SobolMaxDimensions; dim++ {
+		poly := sobolPolynomials[dim-1]
+		v := dirs[dim*sobolBits : (dim+1)*sobolBits]
+
+		for bit := uint32(0); bit < sobolBits; bit++ {
+			if bit < poly.s {
+				v[bit] = poly.m[bit] << (31 - bit)
+				continue
+			}
+
+			v[bit] = v[bit-poly.s] ^ (v[bit-poly.s] >> poly.s)
+			for k := uint32(1); k < poly.s; k++ {
+				v[bit] ^= ((poly.a >> (poly.s - 1 - k)) & 1) * v[bit-k]
+			}
+		}
+	}
+
+	return dirs
+}
+
+// Get the number of sample dimensions consumed by a path that is traced for
+// up to numBounces bounces and the number of those dimensions that exceed
+// the sobol dimension budget maxDims (or SobolMaxDimensions if maxDims is
+// 0) and are padded by reusing the bounce dimensions.
+func SobolDimensionUsage(numBounces, maxDims uint32) (used, padded uint32) {
+	if maxDims == 0 {
+		maxDims = SobolMaxDimensions
+	}
+
+	used = SobolBounceDimOffset + numBounces*SobolDimsPerBounce
+	if used > maxDims {
+		padded = used - maxDims
+	}
+	return used, padded
+}
+
+// Ensure that a sobol dimension budget is valid. Dimensions are consumed in
+// pairs so the budget must be an even number that fits the camera dimensions
+// and the dimensions of at least one bounce. A budget of 0 selects
+// SobolMaxDimensions.
+func ValidateSobolDimensionBudget(maxDims uint32) error {
+	const minDims = SobolBounceDimOffset + SobolDimsPerBounce
+	if maxDims == 0 {
+		return nil
+	}
+	if maxDims < minDims || maxDims > SobolMaxDimensions || maxDims%2 != 0 {
+		return fmt.Errorf("tracer: sobol dimension budget must be an even number in the [%d, %d] range; got %d", minDims, SobolMaxDimensions, maxDims)
+	}
+	return nil
+}
+
+// Get the max number of sobol dimensions used by each path.
+func (req *BlockRequest) SobolDimensionBudget() uint32 {
+	if req.SobolDimensions == 0 {
+		return SobolMaxDimensions
+	}
+	return req.SobolDimensions
+}
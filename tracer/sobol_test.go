@@ -0,0 +1,106 @@
+package tracer
+
+import (
+	"testing"
+)
+
+func TestSobolSequence(t *testing.T) {
+	dirs := SobolDirections()
+
+	// First points of the sequence (using direct, non gray-code, ordering)
+	expPoints := [][3]float32{
+		{0, 0, 0},
+		{0.5, 0.5, 0.5},
+		{0.25, 0.75, 0.75},
+		{0.75, 0.25, 0.25},
+	}
+	for index, expPoint := range expPoints {
+		for dim := uint32(0); dim < 3; dim++ {
+			if v := uint32ToFloat32(sobol(dirs, uint32(index), dim)); v != expPoint[dim] {
+				t.Fatalf("[point %d] expected dimension %d to be %f; got %f", index, dim, expPoint[dim], v)
+			}
+		}
+	}
+}
+
+func TestSobolStratification(t *testing.T) {
+	dirs := SobolDirections()
+
+	// The first 2^m points of each dimension should fall into different
+	// [i/2^m, (i+1)/2^m) intervals.
+	const numPoints = 64
+	for dim := uint32(0); dim < SobolMaxDimensions; dim++ {
+		var seen [numPoints]bool
+		for index := uint32(0); index < numPoints; index++ {
+			bucket := int(uint32ToFloat32(sobol(dirs, index, dim)) * numPoints)
+			if seen[bucket] {
+				t.Fatalf("[dim %d] point %d falls into already occupied interval %d", dim, index, bucket)
+			}
+			seen[bucket] = true
+		}
+	}
+
+	// The first 16 points of the first two dimensions should fall into
+	// different cells of a 4x4 grid.
+	var seen [4][4]bool
+	for index := uint32(0); index < 16; index++ {
+		x := int(uint32ToFloat32(sobol(dirs, index, 0)) * 4)
+		y := int(uint32ToFloat32(sobol(dirs, index, 1)) * 4)
+		if seen[x][y] {
+			t.Fatalf("point %d falls into already occupied cell (%d, %d)", index, x, y)
+		}
+		seen[x][y] = true
+	}
+}
+
+func TestSamplerTypeFromName(t *testing.T) {
+	for _, samplerType := range []SamplerType{RandomSampler, SobolSampler} {
+		got, err := SamplerTypeFromName(samplerType.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != samplerType {
+			t.Fatalf("expected to get sampler type %s; got %s", samplerType, got)
+		}
+	}
+}
+
+func TestSobolDimensionBudget(t *testing.T) {
+	type spec struct {
+		maxDims uint32
+		valid   bool
+	}
+	specs := []spec{
+		spec{0, true},
+		spec{8, true},
+		spec{SobolMaxDimensions, true},
+		spec{6, false},
+		spec{9, false},
+		spec{SobolMaxDimensions + 2, false},
+	}
+	for index, s := range specs {
+		if err := ValidateSobolDimensionBudget(s.maxDims); (err == nil) != s.valid {
+			t.Fatalf("[spec %d] expected budget %d validity to be %t; got error %v", index, s.maxDims, s.valid, err)
+		}
+	}
+
+	if used, padded := SobolDimensionUsage(7, 0); used != 44 || padded != 44-SobolMaxDimensions {
+		t.Fatalf("expected a 7-bounce path to use 44 dimensions with %d padded; got %d and %d", 44-SobolMaxDimensions, used, padded)
+	}
+	if used, padded := SobolDimensionUsage(2, 0); used != 14 || padded != 0 {
+		t.Fatalf("expected a 2-bounce path to use 14 dimensions without padding; got %d and %d", used, padded)
+	}
+}
+
+// Generate the unscrambled sobol sample for the given index and dimension
+// using the supplied direction numbers.
+func sobol(dirs []uint32, index, dim uint32) uint32 {
+	var x uint32
+	v := dirs[dim*sobolBits : (dim+1)*sobolBits]
+	for bit := 0; index != 0; index, bit = index>>1, bit+1 {
+		if index&1 != 0 {
+			x ^= v[bit]
+		}
+	}
+	return x
+}
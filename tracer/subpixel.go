@@ -0,0 +1,117 @@
+package tracer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The pattern used for placing primary ray samples inside each pixel before
+// they are warped by the reconstruction (tent) filter.
+type SubpixelPattern uint8
+
+// Supported sub-pixel patterns.
+const (
+	// Use the camera samples generated by the selected sampler.
+	SamplerPattern SubpixelPattern = iota
+
+	// A regular grid of SubpixelPatternSize cells. Samples visit the grid
+	// cells in an order where every 4^k consecutive samples form a
+	// regular 2^k x 2^k grid.
+	GridPattern
+
+	// The Halton sequence using bases 2 and 3. Provides good coverage for
+	// any sample count.
+	HaltonPattern
+
+	// A progressive Poisson-disk point set with SubpixelPatternSize points
+	// that provides a blue-noise-like sample distribution.
+	PoissonPattern
+)
+
+const (
+	// The number of distinct samples generated by the grid and Poisson
+	// patterns. Samples past this count repeat the pattern.
+	SubpixelPatternSize = 64
+
+	// The grid pattern dimensions.
+	subpixelGridDim = 8
+
+	// The seed for generating the Poisson-disk pattern uploaded to the
+	// opencl devices.
+	PoissonPatternSeed = 0x5eed
+)
+
+// Implements Stringer.
+func (p SubpixelPattern) String() string {
+	switch p {
+	case SamplerPattern:
+		return "sampler"
+	case GridPattern:
+		return "grid"
+	case HaltonPattern:
+		return "halton"
+	case PoissonPattern:
+		return "poisson"
+	}
+
+	return "invalid"
+}
+
+// Lookup a sub-pixel pattern by its name.
+func SubpixelPatternFromName(name string) (SubpixelPattern, error) {
+	switch name {
+	case "sampler":
+		return SamplerPattern, nil
+	case "grid":
+		return GridPattern, nil
+	case "halton":
+		return HaltonPattern, nil
+	case "poisson":
+		return PoissonPattern, nil
+	}
+
+	return 0, fmt.Errorf("unsupported sub-pixel pattern %q; supported patterns: sampler, grid, halton, poisson", name)
+}
+
+// Generate a progressive Poisson-disk pattern with SubpixelPatternSize points
+// in the [0, 1) range using Mitchell's best candidate algorithm. Distances
+// are measured on the unit torus so the pattern can be tiled and shifted
+// without introducing clumps. The generated pattern only depends on the seed.
+func PoissonDiskPattern(seed int64) []types.Vec2 {
+	rng := rand.New(rand.NewSource(seed))
+	points := make([]types.Vec2, 0, SubpixelPatternSize)
+	for len(points) < SubpixelPatternSize {
+		var best types.Vec2
+		bestDist := float32(-1)
+		for candidate := 0; candidate <= 10*len(points); candidate++ {
+			p := types.Vec2{rng.Float32(), rng.Float32()}
+			dist := float32(math.MaxFloat32)
+			for _, q := range points {
+				if d := toroidalDistSq(p, q); d < dist {
+					dist = d
+				}
+			}
+			if dist > bestDist {
+				best, bestDist = p, dist
+			}
+		}
+		points = append(points, best)
+	}
+	return points
+}
+
+// Get the squared distance between two points on the unit torus.
+func toroidalDistSq(a, b types.Vec2) float32 {
+	var distSq float32
+	for axis := 0; axis < 2; axis++ {
+		d := float32(math.Abs(float64(a[axis] - b[axis])))
+		if d > 0.5 {
+			d = 1 - d
+		}
+		distSq += d * d
+	}
+	return distSq
+}
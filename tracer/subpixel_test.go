@@ -0,0 +1,51 @@
+package tracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPoissonDiskPattern(t *testing.T) {
+	a := PoissonDiskPattern(42)
+	b := PoissonDiskPattern(42)
+	if len(a) != SubpixelPatternSize {
+		t.Fatalf("expected pattern to contain %d points; got %d", SubpixelPatternSize, len(a))
+	}
+
+	// Points should be well separated; a jittered pattern would
+	// routinely contain points closer than half the cell spacing.
+	minDist := float32(math.MaxFloat32)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected patterns generated with the same seed to match; point %d differs: %v vs %v", i, a[i], b[i])
+		}
+		for j := 0; j < i; j++ {
+			if d := float32(math.Sqrt(float64(toroidalDistSq(a[i], a[j])))); d < minDist {
+				minDist = d
+			}
+		}
+	}
+	if minCellDist := float32(0.5 / math.Sqrt(SubpixelPatternSize)); minDist < minCellDist {
+		t.Fatalf("expected min point distance to be at least %f; got %f", minCellDist, minDist)
+	}
+
+	if c := PoissonDiskPattern(43); c[0] == a[0] && c[1] == a[1] {
+		t.Fatal("expected patterns generated with different seeds to differ")
+	}
+}
+
+func TestSubpixelPatternFromName(t *testing.T) {
+	for _, pattern := range []SubpixelPattern{SamplerPattern, GridPattern, HaltonPattern, PoissonPattern} {
+		got, err := SubpixelPatternFromName(pattern.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != pattern {
+			t.Fatalf("expected pattern %q; got %q", pattern, got)
+		}
+	}
+
+	if _, err := SubpixelPatternFromName("foo"); err == nil {
+		t.Fatal("expected an error for an unsupported pattern")
+	}
+}
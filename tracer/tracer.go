@@ -1,6 +1,11 @@
 package tracer
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/achilleasa/polaris/asset/material"
+)
 
 // A unit of work that is processed by a tracer.
 type BlockRequest struct {
@@ -26,9 +31,101 @@ type BlockRequest struct {
 	// The exposure value controls HDR -> LDR mapping.
 	Exposure float32
 
+	// The color space that radiance is converted to before tone-mapping.
+	ColorSpace ColorSpace
+
+	// White balance applied to radiance before converting it to the
+	// output color space.
+	WhiteBalance WhiteBalance
+
 	// A random seed value for the tracer's random number generator.
 	Seed uint32
 
+	// The random number generator algorithm for deriving per-sample seeds.
+	RNG RNGType
+
+	// The sampler used for generating camera and bounce samples.
+	Sampler SamplerType
+
+	// The max number of sobol dimensions used by each path. Dimensions
+	// past this budget wrap around the bounce dimensions using a rehashed
+	// scramble seed and a shuffled sample index so the wrapped bounces are
+	// not correlated with the earlier ones. If set to 0,
+	// SobolMaxDimensions is used.
+	SobolDimensions uint32
+
+	// The placement of primary ray samples inside each pixel.
+	SubpixelPattern SubpixelPattern
+
+	// The number of photons to emit for the caustic photon map and the
+	// photon gather radius. The caustics pass is disabled if set to 0.
+	CausticPhotons uint32
+	CausticRadius  float32
+
+	// The number of bounce depths whose radiance contribution is captured
+	// into separate buffers. Contributions from deeper bounces are added to
+	// the last captured depth. Bounce capturing is disabled if set to 0.
+	BounceAOVDepth uint32
+
+	// Clamp thresholds for the radiance contributed by direct and indirect
+	// lighting. Clamping suppresses fireflies at the cost of some energy
+	// loss. Each clamp is disabled if set to 0.
+	DirectClamp   float32
+	IndirectClamp float32
+
+	// The min roughness of glossy bxdfs encountered after the first diffuse
+	// bounce. Regularizing such bxdfs suppresses fireflies from caustic
+	// paths at the cost of some bias; see material.RegularizeRoughness.
+	// Regularization is disabled if set to 0.
+	RoughnessClamp float32
+
+	// The max number of glossy bounces along each path. Glossy bounces
+	// past this budget either terminate the path or are shaded as diffuse
+	// according to GlossyBudgetPolicy; see material.ApplyGlossyBudget.
+	// Unlike roughness clamping, the budget trades the accuracy of deep
+	// glossy interreflections for render time. The budget is disabled if
+	// set to 0.
+	MaxGlossyBounces   uint32
+	GlossyBudgetPolicy material.GlossyBudgetPolicy
+
+	// The max number of transparent surfaces that occlusion rays can pass
+	// through. Occlusion rays passing through dielectric or semi-transparent
+	// surfaces are attenuated by the surface transmittance so that such
+	// surfaces cast tinted shadows. If set to 0, any surface blocks
+	// occlusion rays.
+	ShadowTransparencyDepth uint32
+
+	// Firefly filter settings. Before tone-mapping, pixels that are more
+	// than FireflyThreshold times brighter than the median of their
+	// neighborhood with radius FireflyRadius are scaled down while keeping
+	// their hue. Pixels with a zero neighborhood median are not modified.
+	// The filter is disabled if FireflyThreshold is 0.
+	FireflyThreshold float32
+	FireflyRadius    uint32
+
+	// The max number of rays processed by each intersection kernel
+	// dispatch. Smaller batches reduce the amount of work in flight which
+	// can improve throughput on devices with limited resources. If set
+	// to 0, DefaultRayBatchSize is used.
+	RayBatchSize uint32
+
+	// The distance by which secondary ray origins are offset from surfaces
+	// to avoid self-intersections. Occlusion rays are also clipped by a
+	// multiple of this value before reaching light samples. If set to 0,
+	// the tracer derives it from the scene units.
+	RayEpsilon float32
+
+	// The policy for handling samples with NaN or Inf components. By
+	// default, such samples are dropped and counted.
+	NonFiniteGuard NonFiniteGuard
+
+	// Controls whether the background is visible in the frame buffer
+	// alpha channel. By default, all pixels are opaque.
+	BackgroundMode BackgroundMode
+
+	// Collect ray and BVH traversal counters while tracing; see RayStats.
+	CollectStats bool
+
 	// Number of sequential rendered frames from current camera position.
 	AccumulatedSamples uint32
 }
@@ -44,6 +141,20 @@ type Stats struct {
 
 	// The time for rendering this block
 	RenderTime time.Duration
+
+	// The number of samples per pixel that were traced for this block. If
+	// tracing is aborted, only the samples completed before the abort are
+	// counted and the trace accumulator contains their output.
+	Samples uint32
+
+	// The number of non-finite samples that were dropped while rendering
+	// this block. Dropped samples are only counted if the block request
+	// uses the CountNonFinite guard.
+	NonFiniteSamples uint32
+
+	// Ray counters for this block. Counters are only collected if the
+	// block request enables CollectStats.
+	Rays RayStats
 }
 
 type Flag uint8
@@ -99,8 +210,11 @@ type Tracer interface {
 	// Update tracer state.
 	UpdateState(UpdateMode, ChangeType, interface{}) (time.Duration, error)
 
-	// Process block request.
-	Trace(*BlockRequest) (time.Duration, error)
+	// Process block request. Implementations should check the supplied
+	// context between samples and abort with ctx.Err() if it is cancelled.
+	// Samples completed before the abort must remain in the trace
+	// accumulator so they can still be merged.
+	Trace(context.Context, *BlockRequest) (time.Duration, error)
 
 	// Merge accumulator output from another tracer into this tracer's buffer.
 	MergeOutput(Tracer, *BlockRequest) (time.Duration, error)
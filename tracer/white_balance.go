@@ -0,0 +1,156 @@
+package tracer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+// The color temperature range (in Kelvin) supported by white balancing.
+const (
+	MinWhiteBalanceTemperature float32 = 1667
+	MaxWhiteBalanceTemperature float32 = 25000
+)
+
+var (
+	// Conversion matrices between the working color space and CIE XYZ
+	// (column-major order).
+	rgbToXYZ = types.Mat3{
+		0.4124564, 0.2126729, 0.0193339,
+		0.3575761, 0.7151522, 0.1191920,
+		0.1804375, 0.0721750, 0.9503041,
+	}
+	xyzToRGB = types.Mat3{
+		3.2404542, -0.9692660, 0.0556434,
+		-1.5371385, 1.8760108, -0.2040259,
+		-0.4985314, 0.0415560, 1.0572252,
+	}
+
+	// Conversion matrices between CIE XYZ and the Bradford cone response
+	// space (column-major order).
+	xyzToBradford = types.Mat3{
+		0.8951, -0.7502, 0.0389,
+		0.2664, 1.7135, -0.0685,
+		-0.1614, 0.0367, 1.0296,
+	}
+	bradfordToXYZ = types.Mat3{
+		0.9869929, 0.4323053, -0.0085287,
+		-0.1470543, 0.5183603, 0.0400428,
+		0.1599627, 0.0492912, 0.9684867,
+	}
+
+	// The XYZ coordinates of the D65 white point of the working color space.
+	whitePointD65 = types.Vec3{0.95047, 1.0, 1.08883}
+)
+
+// White balance settings for neutralizing the color cast of the scene
+// illumination. The illuminant is described by its correlated color
+// temperature and its distance from the Planckian locus.
+//
+// White balancing is applied to the linear HDR radiance before it is
+// converted to the output color space and tone-mapped. It is implemented as
+// a von Kries chromatic adaptation in the Bradford cone response space that
+// maps the illuminant white to the D65 white point of the working color
+// space.
+type WhiteBalance struct {
+	// The color temperature (in Kelvin) of the illuminant to neutralize.
+	// Higher values produce warmer images. White balancing is disabled if
+	// set to 0.
+	Temperature float32
+
+	// The signed distance (Duv) of the illuminant from the Planckian locus.
+	// Positive values neutralize greenish illuminants and shift the image
+	// towards magenta. Typical values are in the [-0.02, 0.02] range.
+	Tint float32
+}
+
+// Returns true if white balancing is enabled.
+func (wb WhiteBalance) Enabled() bool {
+	return wb.Temperature != 0
+}
+
+// Ensure that the white balance settings are valid.
+func (wb WhiteBalance) Validate() error {
+	if wb.Enabled() && (wb.Temperature < MinWhiteBalanceTemperature || wb.Temperature > MaxWhiteBalanceTemperature) {
+		return fmt.Errorf("tracer: white balance temperature must be 0 or in the [%.0f, %.0f] range; got %f", MinWhiteBalanceTemperature, MaxWhiteBalanceTemperature, wb.Temperature)
+	}
+	return nil
+}
+
+// Get the matrix for white balancing linear radiance in the working color
+// space. If white balancing is disabled, the identity matrix is returned.
+func (wb WhiteBalance) Matrix() types.Mat3 {
+	if !wb.Enabled() {
+		return types.Ident3()
+	}
+
+	// Calculate the illuminant XYZ coordinates for Y = 1
+	x, y := wb.illuminantChromaticity()
+	illuminant := types.Vec3{x / y, 1.0, (1.0 - x - y) / y}
+
+	// Scale cone responses so that the illuminant maps to D65
+	srcLMS := xyzToBradford.Mul3x1(illuminant)
+	dstLMS := xyzToBradford.Mul3x1(whitePointD65)
+	adapt := types.Mat3{
+		dstLMS[0] / srcLMS[0], 0, 0,
+		0, dstLMS[1] / srcLMS[1], 0,
+		0, 0, dstLMS[2] / srcLMS[2],
+	}
+
+	return xyzToRGB.Mul3(bradfordToXYZ).Mul3(adapt).Mul3(xyzToBradford).Mul3(rgbToXYZ)
+}
+
+// Calculate the CIE xy chromaticity of the illuminant by offsetting the
+// Planckian locus point for its temperature by Tint along the locus normal
+// in the CIE 1960 UCS.
+func (wb WhiteBalance) illuminantChromaticity() (float32, float32) {
+	temp := float64(wb.Temperature)
+	u0, v0 := xyToUV(planckianLocus(temp))
+	if wb.Tint == 0 {
+		return uvToXY(u0, v0)
+	}
+
+	// Rotate the locus tangent so the normal points towards the green
+	// side of the locus.
+	u1, v1 := xyToUV(planckianLocus(temp * 1.01))
+	du, dv := u1-u0, v1-v0
+	norm := math.Hypot(du, dv)
+	tint := float64(wb.Tint)
+	return uvToXY(u0+tint*dv/norm, v0-tint*du/norm)
+}
+
+// Approximate the CIE xy chromaticity of a black body radiator with the
+// given temperature using the cubic spline fit by Kim et al.
+func planckianLocus(temp float64) (float64, float64) {
+	t, t2, t3 := temp, temp*temp, temp*temp*temp
+
+	var x float64
+	if temp <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+
+	x2, x3 := x*x, x*x*x
+	switch {
+	case temp <= 2222:
+		return x, -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	case temp <= 4000:
+		return x, -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	default:
+		return x, 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+}
+
+// Convert CIE xy chromaticity coordinates to CIE 1960 uv coordinates.
+func xyToUV(x, y float64) (float64, float64) {
+	denom := -2*x + 12*y + 3
+	return 4 * x / denom, 6 * y / denom
+}
+
+// Convert CIE 1960 uv coordinates to CIE xy chromaticity coordinates.
+func uvToXY(u, v float64) (float32, float32) {
+	denom := 2*u - 8*v + 4
+	return float32(3 * u / denom), float32(2 * v / denom)
+}
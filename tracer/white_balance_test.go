@@ -0,0 +1,78 @@
+package tracer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/achilleasa/polaris/types"
+)
+
+func TestWhiteBalanceTemperature(t *testing.T) {
+	white := types.Vec3{1, 1, 1}
+
+	// Shifting the temperature up should gradually increase the red
+	// channel gain relative to the blue channel gain.
+	var lastRatio float32
+	for index, temp := range []float32{2000, 3200, 5000, 6500, 8000, 12000} {
+		out := WhiteBalance{Temperature: temp}.Matrix().Mul3x1(white)
+		ratio := out[0] / out[2]
+		if index > 0 && ratio <= lastRatio {
+			t.Errorf("[temp %.0f] expected red/blue gain ratio to increase from %f; got %f", temp, lastRatio, ratio)
+		}
+		lastRatio = ratio
+	}
+
+	// The D65 white point is close to the 6504K locus point so
+	// white should be preserved
+	out := WhiteBalance{Temperature: 6504}.Matrix().Mul3x1(white)
+	for c := 0; c < 3; c++ {
+		if math.Abs(float64(out[c]-1)) > 0.05 {
+			t.Errorf("expected 6504K white balance to approximately preserve white; got %v", out)
+			break
+		}
+	}
+
+	// White balancing a color with the illuminant chromaticity should yield a neutral color
+	x, y := WhiteBalance{Temperature: 3200}.illuminantChromaticity()
+	illuminant := xyzToRGB.Mul3x1(types.Vec3{x / y, 1, (1 - x - y) / y})
+	out = WhiteBalance{Temperature: 3200}.Matrix().Mul3x1(illuminant)
+	if math.Abs(float64(out[0]-out[1])) > 1e-3 || math.Abs(float64(out[2]-out[1])) > 1e-3 {
+		t.Errorf("expected illuminant to be mapped to a neutral color; got %v", out)
+	}
+}
+
+func TestWhiteBalanceTint(t *testing.T) {
+	white := types.Vec3{1, 1, 1}
+	neutral := WhiteBalance{Temperature: 5000}.Matrix().Mul3x1(white)
+	magenta := WhiteBalance{Temperature: 5000, Tint: 0.01}.Matrix().Mul3x1(white)
+
+	if magenta[1]/magenta[0] >= neutral[1]/neutral[0] || magenta[1]/magenta[2] >= neutral[1]/neutral[2] {
+		t.Fatalf("expected a positive tint to reduce the green channel gain; got %v (no tint: %v)", magenta, neutral)
+	}
+}
+
+func TestWhiteBalanceValidation(t *testing.T) {
+	type spec struct {
+		wb       WhiteBalance
+		expError bool
+	}
+	specs := []spec{
+		spec{WhiteBalance{}, false},
+		spec{WhiteBalance{Temperature: 4500, Tint: -0.01}, false},
+		spec{WhiteBalance{Temperature: 1000}, true},
+		spec{WhiteBalance{Temperature: 30000}, true},
+	}
+
+	for index, s := range specs {
+		err := s.wb.Validate()
+		if s.expError && err == nil {
+			t.Errorf("[spec %d] expected validation to fail", index)
+		} else if !s.expError && err != nil {
+			t.Errorf("[spec %d] unexpected error: %v", index, err)
+		}
+	}
+
+	if (WhiteBalance{}).Matrix() != types.Ident3() {
+		t.Fatal("expected disabled white balance to return the identity matrix")
+	}
+}
@@ -33,6 +33,21 @@ func (m Mat3) Mul3x1(v Vec3) Vec3 {
 	}
 }
 
+// Multiply two 3x3 matrices.
+func (m1 Mat3) Mul3(m2 Mat3) Mat3 {
+	return Mat3{
+		m1[0]*m2[0] + m1[3]*m2[1] + m1[6]*m2[2],
+		m1[1]*m2[0] + m1[4]*m2[1] + m1[7]*m2[2],
+		m1[2]*m2[0] + m1[5]*m2[1] + m1[8]*m2[2],
+		m1[0]*m2[3] + m1[3]*m2[4] + m1[6]*m2[5],
+		m1[1]*m2[3] + m1[4]*m2[4] + m1[7]*m2[5],
+		m1[2]*m2[3] + m1[5]*m2[4] + m1[8]*m2[5],
+		m1[0]*m2[6] + m1[3]*m2[7] + m1[6]*m2[8],
+		m1[1]*m2[6] + m1[4]*m2[7] + m1[7]*m2[8],
+		m1[2]*m2[6] + m1[5]*m2[7] + m1[8]*m2[8],
+	}
+}
+
 // Create a 4x4 identity matrix.
 func Ident4() Mat4 {
 	return Mat4{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
@@ -104,9 +119,14 @@ func (m Mat4) Row(row int) Vec4 {
 	return Vec4{m[row+0], m[row+4], m[row+8], m[row+12]}
 }
 
+// Calculate matrix determinant.
+func (m Mat4) Det() float32 {
+	return m[0]*m[5]*m[10]*m[15] - m[0]*m[5]*m[11]*m[14] - m[0]*m[6]*m[9]*m[15] + m[0]*m[6]*m[11]*m[13] + m[0]*m[7]*m[9]*m[14] - m[0]*m[7]*m[10]*m[13] - m[1]*m[4]*m[10]*m[15] + m[1]*m[4]*m[11]*m[14] + m[1]*m[6]*m[8]*m[15] - m[1]*m[6]*m[11]*m[12] - m[1]*m[7]*m[8]*m[14] + m[1]*m[7]*m[10]*m[12] + m[2]*m[4]*m[9]*m[15] - m[2]*m[4]*m[11]*m[13] - m[2]*m[5]*m[8]*m[15] + m[2]*m[5]*m[11]*m[12] + m[2]*m[7]*m[8]*m[13] - m[2]*m[7]*m[9]*m[12] - m[3]*m[4]*m[9]*m[14] + m[3]*m[4]*m[10]*m[13] + m[3]*m[5]*m[8]*m[14] - m[3]*m[5]*m[10]*m[12] - m[3]*m[6]*m[8]*m[13] + m[3]*m[6]*m[9]*m[12]
+}
+
 // Invert matrix
 func (m Mat4) Inv() Mat4 {
-	det := m[0]*m[5]*m[10]*m[15] - m[0]*m[5]*m[11]*m[14] - m[0]*m[6]*m[9]*m[15] + m[0]*m[6]*m[11]*m[13] + m[0]*m[7]*m[9]*m[14] - m[0]*m[7]*m[10]*m[13] - m[1]*m[4]*m[10]*m[15] + m[1]*m[4]*m[11]*m[14] + m[1]*m[6]*m[8]*m[15] - m[1]*m[6]*m[11]*m[12] - m[1]*m[7]*m[8]*m[14] + m[1]*m[7]*m[10]*m[12] + m[2]*m[4]*m[9]*m[15] - m[2]*m[4]*m[11]*m[13] - m[2]*m[5]*m[8]*m[15] + m[2]*m[5]*m[11]*m[12] + m[2]*m[7]*m[8]*m[13] - m[2]*m[7]*m[9]*m[12] - m[3]*m[4]*m[9]*m[14] + m[3]*m[4]*m[10]*m[13] + m[3]*m[5]*m[8]*m[14] - m[3]*m[5]*m[10]*m[12] - m[3]*m[6]*m[8]*m[13] + m[3]*m[6]*m[9]*m[12]
+	det := m.Det()
 	absDet := det
 	if absDet < 0 {
 		absDet = -absDet
@@ -94,3 +94,67 @@ func (q1 Quat) Mat4() Mat4 {
 		0, 0, 0, 1,
 	}
 }
+
+// Convert the rotation part of a 4x4 matrix to a quaternion. The upper 3x3
+// matrix must be a pure rotation.
+func QuatFromMat4(m Mat4) Quat {
+	// http://www.euclideanspace.com/maths/geometry/rotations/conversions/matrixToQuaternion/index.htm
+	if tr := m[0] + m[5] + m[10]; tr > 0 {
+		s := float32(0.5 / math.Sqrt(float64(tr+1.0)))
+		return Quat{
+			Vec3{(m[6] - m[9]) * s, (m[8] - m[2]) * s, (m[1] - m[4]) * s},
+			0.25 / s,
+		}
+	}
+	if m[0] > m[5] && m[0] > m[10] {
+		s := float32(2.0 * math.Sqrt(float64(1.0+m[0]-m[5]-m[10])))
+		return Quat{
+			Vec3{0.25 * s, (m[4] + m[1]) / s, (m[8] + m[2]) / s},
+			(m[6] - m[9]) / s,
+		}
+	}
+	if m[5] > m[10] {
+		s := float32(2.0 * math.Sqrt(float64(1.0+m[5]-m[0]-m[10])))
+		return Quat{
+			Vec3{(m[4] + m[1]) / s, 0.25 * s, (m[9] + m[6]) / s},
+			(m[8] - m[2]) / s,
+		}
+	}
+	s := float32(2.0 * math.Sqrt(float64(1.0+m[10]-m[0]-m[5])))
+	return Quat{
+		Vec3{(m[8] + m[2]) / s, (m[9] + m[6]) / s, 0.25 * s},
+		(m[1] - m[4]) / s,
+	}
+}
+
+// The dot product between two quaternions, equivalent to the Vec4 dot product.
+func (q1 Quat) Dot(q2 Quat) float32 {
+	return q1.V.Dot(q2.V) + q1.W*q2.W
+}
+
+// Spherical linear interpolation between two unit quaternions. The
+// interpolation follows the shortest arc between the two rotations.
+func QuatSlerp(q1, q2 Quat, amount float32) Quat {
+	dot := q1.Dot(q2)
+	if dot < 0 {
+		q2 = Quat{q2.V.Mul(-1), -q2.W}
+		dot = -dot
+	}
+
+	// Use normalized linear interpolation for nearly identical rotations
+	if dot > 0.9995 {
+		return Quat{
+			q1.V.Add(q2.V.Sub(q1.V).Mul(amount)),
+			q1.W + (q2.W-q1.W)*amount,
+		}.Normalize()
+	}
+
+	theta := math.Acos(float64(dot))
+	sinTheta := math.Sin(theta)
+	s1 := float32(math.Sin((1-float64(amount))*theta) / sinTheta)
+	s2 := float32(math.Sin(float64(amount)*theta) / sinTheta)
+	return Quat{
+		q1.V.Mul(s1).Add(q2.V.Mul(s2)),
+		q1.W*s1 + q2.W*s2,
+	}
+}
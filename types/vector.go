@@ -89,6 +89,19 @@ func (v Vec3) Cross(v2 Vec3) Vec3 {
 	return Vec3{v[1]*v2[2] - v[2]*v2[1], v[2]*v2[0] - v[0]*v2[2], v[0]*v2[1] - v[1]*v2[0]}
 }
 
+// Build an orthonormal basis (t, b, n) around the unit vector n using the
+// branchless method by Duff et al. ("Building an Orthonormal Basis,
+// Revisited"). The basis is right-handed (t x b = n) and is numerically
+// stable for any n, including normals close to the poles.
+func BuildOrthonormalBasis(n Vec3) (t, b Vec3) {
+	sign := float32(math.Copysign(1, float64(n[2])))
+	a := -1 / (sign + n[2])
+	c := n[0] * n[1] * a
+	t = Vec3{1 + sign*n[0]*n[0]*a, sign * c, -sign * n[0]}
+	b = Vec3{c, sign + n[1]*n[1]*a, -n[1]}
+	return t, b
+}
+
 // Return max vector component.
 func (v Vec3) MaxComponent() float32 {
 	out := v[0]
@@ -136,6 +149,11 @@ func MaxVec3(v1, v2 Vec3) Vec3 {
 	return out
 }
 
+// Add a vector.
+func (v Vec4) Add(v2 Vec4) Vec4 {
+	return Vec4{v[0] + v2[0], v[1] + v2[1], v[2] + v2[2], v[3] + v2[3]}
+}
+
 // Subtract a vector.
 func (v Vec4) Sub(v2 Vec4) Vec4 {
 	return Vec4{v[0] - v2[0], v[1] - v2[1], v[2] - v2[2], v[3] - v2[3]}
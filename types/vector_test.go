@@ -0,0 +1,42 @@
+package types
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBuildOrthonormalBasis(t *testing.T) {
+	normals := []Vec3{
+		{1, 0, 0}, {-1, 0, 0},
+		{0, 1, 0}, {0, -1, 0},
+		{0, 0, 1}, {0, 0, -1},
+		// Close to the poles
+		Vec3{1e-4, -2e-4, -1}.Normalize(),
+		Vec3{-3e-7, 1e-7, 1}.Normalize(),
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		n := Vec3{float32(rng.NormFloat64()), float32(rng.NormFloat64()), float32(rng.NormFloat64())}.Normalize()
+		normals = append(normals, n)
+	}
+
+	const epsilon = 1e-5
+	for index, n := range normals {
+		tangent, bitangent := BuildOrthonormalBasis(n)
+		for _, dot := range []float32{tangent.Dot(bitangent), tangent.Dot(n), bitangent.Dot(n)} {
+			if math.Abs(float64(dot)) > epsilon {
+				t.Fatalf("[normal %d] expected basis %v, %v, %v to be orthogonal", index, tangent, bitangent, n)
+			}
+		}
+		for _, v := range []Vec3{tangent, bitangent} {
+			if math.Abs(float64(v.Len()-1)) > epsilon {
+				t.Fatalf("[normal %d] expected basis vector %v to be unit length", index, v)
+			}
+		}
+		if tangent.Cross(bitangent).Sub(n).Len() > epsilon {
+			t.Fatalf("[normal %d] expected basis for %v to be right-handed", index, n)
+		}
+	}
+}